@@ -0,0 +1,93 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package adaptor
+
+import (
+	"math"
+	"time"
+)
+
+// LatencyBounds are the upper bounds of the buckets of a latency histogram.
+// Round-trip times above the last bound go into an additional overflow bucket.
+var LatencyBounds = [...]time.Duration{
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Latency is a histogram of ping round-trip times with fixed buckets, so that
+// it stays small no matter how many samples it holds. Histograms of several
+// peers can be merged to get the latency of the network as a whole.
+type Latency struct {
+	Samples uint64
+	Counts  [len(LatencyBounds) + 1]uint64
+}
+
+// Add puts a round-trip time into the histogram.
+func (l *Latency) Add(rtt time.Duration) {
+	i := 0
+	for i < len(LatencyBounds) && rtt > LatencyBounds[i] {
+		i++
+	}
+
+	l.Counts[i]++
+	l.Samples++
+}
+
+// Merge adds the samples of another histogram to this one.
+func (l *Latency) Merge(other Latency) {
+	for i, count := range other.Counts {
+		l.Counts[i] += count
+	}
+
+	l.Samples += other.Samples
+}
+
+// Percentile returns the upper bound of the bucket holding the given quantile,
+// between zero and one, of the round-trip times. If it falls in the overflow
+// bucket, the last bound is returned. Without samples, it returns zero.
+func (l *Latency) Percentile(q float64) time.Duration {
+	if l.Samples == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(q * float64(l.Samples)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	var total uint64
+	for i, count := range l.Counts {
+		total += count
+		if total >= rank && i < len(LatencyBounds) {
+			return LatencyBounds[i]
+		}
+	}
+
+	return LatencyBounds[len(LatencyBounds)-1]
+}
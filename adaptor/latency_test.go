@@ -0,0 +1,97 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package adaptor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyPercentile(t *testing.T) {
+	var l Latency
+	if l.Percentile(0.5) != 0 {
+		t.Error("percentile without samples")
+	}
+
+	ms := time.Millisecond
+	for _, rtt := range []time.Duration{
+		5 * ms, 8 * ms, 10 * ms, 20 * ms, 30 * ms,
+		40 * ms, 60 * ms, 200 * ms, 900 * ms, time.Minute,
+	} {
+		l.Add(rtt)
+	}
+
+	if l.Samples != 10 {
+		t.Fatalf("%v samples", l.Samples)
+	}
+
+	// a bound belongs to its own bucket and slow pings overflow
+	if l.Counts[0] != 3 || l.Counts[len(LatencyBounds)] != 1 {
+		t.Errorf("bucket counts %v", l.Counts)
+	}
+
+	tests := []struct {
+		q   float64
+		rtt time.Duration
+	}{
+		{0, 10 * ms},
+		{0.3, 10 * ms},
+		{0.31, 25 * ms},
+		{0.5, 50 * ms},
+		{0.7, 100 * ms},
+		{0.8, 250 * ms},
+		{0.9, time.Second},
+		{0.99, 10 * time.Second},
+		{1, 10 * time.Second},
+	}
+
+	for _, test := range tests {
+		rtt := l.Percentile(test.q)
+		if rtt != test.rtt {
+			t.Errorf("percentile %v is %v instead of %v", test.q, rtt,
+				test.rtt)
+		}
+	}
+}
+
+func TestLatencyMerge(t *testing.T) {
+	var fast, slow Latency
+	for i := 0; i < 9; i++ {
+		fast.Add(time.Millisecond)
+	}
+	slow.Add(3 * time.Second)
+
+	var network Latency
+	network.Merge(fast)
+	network.Merge(slow)
+
+	if network.Samples != 10 {
+		t.Fatalf("%v samples after merge", network.Samples)
+	}
+
+	if network.Percentile(0.9) != 10*time.Millisecond {
+		t.Errorf("90th percentile %v", network.Percentile(0.9))
+	}
+
+	if network.Percentile(0.95) != 5*time.Second {
+		t.Errorf("tail %v", network.Percentile(0.95))
+	}
+}
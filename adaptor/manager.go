@@ -20,20 +20,36 @@
 
 package adaptor
 
+import (
+	"net"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
 // Manager defines the interface used by peers to communicate with their
 // manager. It is notified of peer state, keeps track of shared state and
 // decides on actions depending on state. Different managers can implement
 // different behaviours.
 type Manager interface {
 	SetLog(Log)
+	SetPeerLog(Log)
+	Name() string
+	Network() wire.BitcoinNet
 	SetRepository(Repository)
 	SetTracker(Tracker)
 	AddProcessor(Processor)
-	Incoming(Peer)
-	Outgoing(Peer)
+	RemoveProcessor(Processor)
+	SetProcessors([]Processor)
+	Processors() []Processor
+	GetPeers() []PeerStats
+	DropPeer(*net.TCPAddr) error
+	ReconnectPeer(*net.TCPAddr) error
+	AdoptConn(net.Conn, bool) error
 	Connected(Peer)
 	Ready(Peer)
+	Harvested(Peer)
 	Stopped(Peer)
 	Start()
 	Stop()
+	Healthy() (bool, error)
 }
@@ -22,8 +22,40 @@ package adaptor
 
 import (
 	"net"
+	"time"
 )
 
+// PeerStats is a snapshot of the traffic we received from a peer. The rates
+// are given per second and computed over a sliding window. Routines is the
+// number of handler go routines currently running for the peer. Connected is
+// the time the connection was established, zero if it is not yet. Unknown is
+// the number of messages skipped because we don't support their command. Relay
+// is the relay flag the peer advertised in its version message. Commands is
+// the number of messages recorded for each command. Latency holds the round-
+// trip times of our pings. Inbound tells whether the peer connected to us and
+// UserAgent is the one it sent in its version message. Useful is the number of
+// messages other than handshake and pings, and LastUseful the time the last of
+// them was received, or the handshake completed if there was none. Reason is
+// why the connection was closed, empty while it is open.
+type PeerStats struct {
+	Address      string
+	BytesRead    uint64
+	MessagesRead uint64
+	ByteRate     float64
+	MessageRate  float64
+	Routines     int
+	Unknown      uint64
+	Relay        bool
+	Connected    time.Time
+	Commands     map[string]uint64
+	Latency      Latency
+	Inbound      bool
+	UserAgent    string
+	Useful       uint64
+	LastUseful   time.Time
+	Reason       string
+}
+
 // Peer defines a common interface for managers to communicate with peers. It
 // can be used to treat various peers differently.
 type Peer interface {
@@ -31,7 +63,9 @@ type Peer interface {
 	Addr() *net.TCPAddr
 	Start()
 	Stop()
+	Drop(reason string)
 	Connect()
 	Greet()
 	Poll()
+	Stats() PeerStats
 }
@@ -26,8 +26,16 @@ package adaptor
 // records and output them to certain media.
 type Processor interface {
 	SetLog(Log)
+	Name() string
 	AddNext(Processor)
 	Process(Record)
+	Backpressure() bool
+	Pause()
+	Resume()
+	Dropped() uint64
+	Written() uint64
+	Next() []Processor
 	Start()
 	Stop()
+	Healthy() (bool, error)
 }
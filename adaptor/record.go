@@ -28,11 +28,14 @@ import (
 // Record defines a common interface for records that describe an event on the
 // Bitcoin network. A top-level record will be able to provide the remote
 // address and message command that it relates to, while a sub-record only
-// provides a string representation of the data.
+// provides a string representation of the data. Every record provides its
+// text representation with String and the binary representation of its
+// payload with Bytes, so that processors can handle all of them the same way.
 type Record interface {
 	Timestamp() time.Time
 	RemoteAddress() *net.TCPAddr
 	LocalAddress() *net.TCPAddr
 	Command() string
 	String() string
+	Bytes() []byte
 }
@@ -22,18 +22,43 @@ package adaptor
 
 import (
 	"net"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
 )
 
+// RepositoryStats is a snapshot of the node pool of a repository. Nodes is the
+// number of nodes on our network, Foreign the number kept for other networks.
+// Failed is the number of nodes that dropped a connection on us since startup.
+// Misadvertised is the number of nodes whose version message advertised an
+// address other than the one we observed since startup.
+// Candidates lists the best known nodes, most recently successful first.
+type RepositoryStats struct {
+	Nodes         int
+	Foreign       int
+	Attempted     int
+	Succeeded     int
+	Failed        int
+	Misadvertised int
+	Candidates    []string
+}
+
 // Repository defines a common interface for a node repository. It keeps track
 // of all addresses seen on the Bitcoin network and their characteristics. It
 // provides clients with a stream of addresses ordered by favourability.
 type Repository interface {
 	SetLog(Log)
-	Discovered(*net.TCPAddr)
+	Name() string
+	SetNetwork(wire.BitcoinNet)
+	Discovered(*net.TCPAddr, *net.TCPAddr, time.Time)
 	Attempted(*net.TCPAddr)
 	Connected(*net.TCPAddr)
 	Succeeded(*net.TCPAddr)
+	Failed(*net.TCPAddr)
+	Advertised(*net.TCPAddr, *net.TCPAddr)
 	Retrieve(chan<- *net.TCPAddr)
+	Stats() RepositoryStats
 	Start()
 	Stop()
+	Healthy() (bool, error)
 }
@@ -0,0 +1,34 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package adaptor
+
+import (
+	"net"
+)
+
+// ReputationSource defines a common interface for external intelligence on
+// Bitcoin nodes, like good and bad peer lists from other tools. The score of
+// an address is used as a weight when selecting nodes to connect to: 1 is
+// neutral, higher values favour the node, lower values disfavour it and zero
+// or less excludes it.
+type ReputationSource interface {
+	Score(*net.TCPAddr) float64
+}
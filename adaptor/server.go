@@ -22,7 +22,9 @@ package adaptor
 
 type Server interface {
 	SetLog(Log)
+	Name() string
 	SetManager(Manager)
 	Start()
 	Stop()
+	Healthy() (bool, error)
 }
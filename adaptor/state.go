@@ -0,0 +1,48 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package adaptor
+
+// State is the lifecycle state of a module, as reported to the state handlers
+// that embedders can set to follow the modules. A module is faulty while it
+// runs, but fails at its job, like a writer that can not write.
+type State uint32
+
+const (
+	StateIdle State = iota
+	StateRunning
+	StateFaulty
+)
+
+func (state State) String() string {
+	switch state {
+	case StateIdle:
+		return "idle"
+
+	case StateRunning:
+		return "running"
+
+	case StateFaulty:
+		return "faulty"
+
+	default:
+		return "unknown"
+	}
+}
@@ -21,15 +21,62 @@
 package adaptor
 
 import (
+	"time"
+
 	"github.com/btcsuite/btcd/wire"
 )
 
+// Sighting describes what the tracker knows about the propagation of an item:
+// whether it was already received before, how many peers have announced it so
+// far and when it was first seen. Announcers lists the first peers that
+// announced it, in order, if the tracker keeps them.
+type Sighting struct {
+	Duplicate  bool
+	Peers      int
+	First      time.Time
+	Announcers []Announcement
+}
+
+// Announcement records that a peer announced an item at a given time.
+type Announcement struct {
+	Peer  string
+	Stamp time.Time
+}
+
+// FeeDistribution summarizes the minimum relay fees last announced by peers in
+// their feefilter messages, in satoshis per kilobyte. Fees maps each announced
+// fee to the number of peers announcing it.
+type FeeDistribution struct {
+	Peers  int
+	Min    int64
+	Lower  int64
+	Median int64
+	Upper  int64
+	Max    int64
+	Fees   map[int64]int
+}
+
 type Tracker interface {
 	SetLog(Log)
+	Name() string
 	AddTx(hash wire.ShaHash)
 	KnowsTx(hash wire.ShaHash) bool
+	AnnounceTx(hash wire.ShaHash, peer string, stamp time.Time) time.Time
+	SightTx(hash wire.ShaHash, peer string, stamp time.Time) Sighting
+	Announcers(hash wire.ShaHash) []Announcement
 	AddBlock(hash wire.ShaHash)
 	KnowsBlock(hash wire.ShaHash) bool
+	ReportHeight(peer string, height int32)
+	AnnounceBlock(hash wire.ShaHash, peer string, stamp time.Time)
+	DeliverBlock(hash wire.ShaHash, peer string, stamp time.Time)
+	BlockDelivery(peer string) Latency
+	BlockDeliveryDistribution() Latency
+	RemovePeer(peer string)
+	TipHeight() int32
+	TipHash() wire.ShaHash
+	ReportFeeFilter(peer string, fee int64)
+	MinRelayFeeDistribution() FeeDistribution
 	Start()
 	Stop()
+	Healthy() (bool, error)
 }
@@ -32,6 +32,14 @@ import (
 	"github.com/CIRCL/pbtc/supervisor"
 )
 
+// The exit codes, so that whoever runs us can tell why we stopped. A forced
+// shutdown panics, for which the runtime exits with code 2.
+const (
+	exitInit     = 1
+	exitShutdown = 3
+	exitHalt     = 4
+)
+
 func main() {
 	fmt.Println("Copyright (c) 2015 Max Wolter")
 	fmt.Println("Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg")
@@ -69,30 +77,35 @@ func main() {
 	supervisor, err := supervisor.New()
 	if err != nil {
 		fmt.Printf("Initialization failed (%v)\n", err)
-		os.Exit(1)
+		os.Exit(exitInit)
 	}
 
 	// start supervisor
 	supervisor.Start()
 
-	// wait for signals in blocking loop
+	// wait for signals or a module failure in blocking loop
+	var halt error
 SigLoop:
-	for sig := range sigc {
-		switch sig {
-		case syscall.SIGINT:
+	for {
+		select {
+		case sig := <-sigc:
+			if sig == syscall.SIGHUP {
+				continue
+			}
+
 			fmt.Printf("\nSIGNAL CAUSED SHUTDOWN (%v)\n", sig.String())
 			break SigLoop
 
-		case syscall.SIGHUP:
-			continue
+		case halt = <-supervisor.Halted():
+			fmt.Printf("\nFAILURE CAUSED SHUTDOWN (%v)\n", halt)
+			break SigLoop
 		}
 	}
 
 	// we will initialize shutdown in a non-blocking way
-	c := make(chan struct{})
+	c := make(chan error)
 	go func() {
-		supervisor.Stop()
-		c <- struct{}{}
+		c <- supervisor.Stop()
 	}()
 
 	// if the shutdown completes, we simple quit normally
@@ -103,10 +116,22 @@ SigLoop:
 		fmt.Printf("\n")
 		panic("FORCED PANIC SHUTDOWN (" + sig.String() + ")")
 
-	case <-c:
+	case err = <-c:
 		break
 	}
+
+	// modules that failed on shutdown might have lost data, so we let
+	// whoever runs us know
+	if err != nil {
+		fmt.Printf("Shutdown failed (%v)\n", err)
+		os.Exit(exitShutdown)
+	}
+
 	fmt.Printf("Shutdown complete\n")
 
+	if halt != nil {
+		os.Exit(exitHalt)
+	}
+
 	os.Exit(0)
 }
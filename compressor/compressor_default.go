@@ -21,9 +21,36 @@
 package compressor
 
 import (
+	"errors"
+
 	"github.com/CIRCL/pbtc/adaptor"
 )
 
+type CompressorType int
+
+const (
+	NoneType CompressorType = iota
+	LZ4Type
+	GzipType
+)
+
+// ParseType returns the compressor type for the given configuration string.
+func ParseType(compressor string) (CompressorType, error) {
+	switch compressor {
+	case "NONE":
+		return NoneType, nil
+
+	case "LZ4":
+		return LZ4Type, nil
+
+	case "GZIP":
+		return GzipType, nil
+
+	default:
+		return -1, errors.New("invalid compressor string")
+	}
+}
+
 // New is a shortcut to create a default compressor. If you want to change the
 // type and options of the default compressor, this is where you can do so.
 func New() adaptor.Compressor {
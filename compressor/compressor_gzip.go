@@ -0,0 +1,59 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package compressor
+
+import (
+	"compress/gzip"
+	"io"
+
+	"github.com/CIRCL/pbtc/adaptor"
+)
+
+// CompressorGzip is a wrapper around the gzip compression of the standard
+// library implementing the compressor interface. Its writers can be flushed,
+// so when the file writer compresses while writing, each flush ends with a
+// sync point and the file written so far can be decompressed by standard
+// tools like zcat. Every flush costs a few bytes and resets the compression
+// window, which slightly lowers the compression ratio.
+type CompressorGzip struct {
+	Compressor
+}
+
+// NewGzip creates a new wrapper around the gzip compression.
+func NewGzip(options ...func(adaptor.Compressor)) *CompressorGzip {
+	comp := &CompressorGzip{}
+
+	for _, option := range options {
+		option(comp)
+	}
+
+	return comp
+}
+
+// GetWriter wraps a new gzip writer around the provided writer.
+func (comp *CompressorGzip) GetWriter(writer io.Writer) (io.Writer, error) {
+	return gzip.NewWriter(writer), nil
+}
+
+// GetReader wraps a new gzip reader around the provided reader.
+func (comp *CompressorGzip) GetReader(reader io.Reader) (io.Reader, error) {
+	return gzip.NewReader(reader)
+}
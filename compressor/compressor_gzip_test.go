@@ -0,0 +1,93 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package compressor
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestGzipFlush(t *testing.T) {
+	buf := new(bytes.Buffer)
+	comp := NewGzip()
+	writer, err := comp.GetWriter(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flusher, ok := writer.(interface {
+		Flush() error
+	})
+	if !ok {
+		t.Fatal("gzip writer can not be flushed")
+	}
+
+	lines := "first line\nsecond line\n"
+	_, err = io.WriteString(writer, lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = flusher.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the stream is not finished yet, but everything up to the flush can be
+	// read with the standard library
+	reader, err := gzip.NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	partial, err := ioutil.ReadAll(reader)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("read unfinished stream with %v", err)
+	}
+
+	if string(partial) != lines {
+		t.Errorf("read %q from flushed stream", partial)
+	}
+
+	// once closed, the stream reads back completely through the compressor
+	_, err = io.WriteString(writer, "third line\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = writer.(io.Closer).Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	finished, err := comp.GetReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	full, err := ioutil.ReadAll(finished)
+	if err != nil || string(full) != lines+"third line\n" {
+		t.Errorf("read %q (%v) from finished stream", full, err)
+	}
+}
@@ -22,6 +22,7 @@ package convertor
 
 import (
 	"net"
+	"time"
 
 	"github.com/btcsuite/btcd/wire"
 
@@ -31,71 +32,74 @@ import (
 
 // Message is used by the convertor package to convert one message of the
 // Bitcoin network into our own record format. As long as convertor has no
-// configuration options, we don't need a struct to hold data.
-func Message(msg wire.Message, r *net.TCPAddr, l *net.TCPAddr) adaptor.Record {
+// configuration options, we don't need a struct to hold data. The timestamp is
+// provided by the caller, so that replayed messages can keep their original
+// time.
+func Message(msg wire.Message, r *net.TCPAddr, l *net.TCPAddr,
+	t time.Time) adaptor.Record {
 	switch m := msg.(type) {
 	case *wire.MsgAddr:
-		return records.NewAddressRecord(m, r, l)
+		return records.NewAddressRecord(m, r, l, t)
 
 	case *wire.MsgAlert:
-		return records.NewAlertRecord(m, r, l)
+		return records.NewAlertRecord(m, r, l, t)
 
 	case *wire.MsgBlock:
-		return records.NewBlockRecord(m, r, l)
+		return records.NewBlockRecord(m, r, l, t)
 
 	case *wire.MsgHeaders:
-		return records.NewHeadersRecord(m, r, l)
+		return records.NewHeadersRecord(m, r, l, t)
 
 	case *wire.MsgInv:
-		return records.NewInventoryRecord(m, r, l)
+		return records.NewInventoryRecord(m, r, l, t)
 
 	case *wire.MsgPing:
-		return records.NewPingRecord(m, r, l)
+		return records.NewPingRecord(m, r, l, t)
 
 	case *wire.MsgPong:
-		return records.NewPongRecord(m, r, l)
+		return records.NewPongRecord(m, r, l, t)
 
 	case *wire.MsgReject:
-		return records.NewRejectRecord(m, r, l)
+		return records.NewRejectRecord(m, r, l, t)
 
 	case *wire.MsgVersion:
-		return records.NewVersionRecord(m, r, l)
+		return records.NewVersionRecord(m, r, l, t)
 
 	case *wire.MsgTx:
-		return records.NewTransactionRecord(m, r, l)
+		return records.NewTransactionRecord(m, r, l, t)
 
 	case *wire.MsgFilterAdd:
-		return records.NewFilterAddRecord(m, r, l)
+		return records.NewFilterAddRecord(m, r, l, t)
 
 	case *wire.MsgFilterClear:
-		return records.NewFilterClearRecord(m, r, l)
+		return records.NewFilterClearRecord(m, r, l, t)
 
 	case *wire.MsgFilterLoad:
-		return records.NewFilterLoadRecord(m, r, l)
+		return records.NewFilterLoadRecord(m, r, l, t)
 
 	case *wire.MsgGetAddr:
-		return records.NewGetAddrRecord(m, r, l)
+		return records.NewGetAddrRecord(m, r, l, t)
 
 	case *wire.MsgGetBlocks:
-		return records.NewGetBlocksRecord(m, r, l)
+		return records.NewGetBlocksRecord(m, r, l, t)
 
 	case *wire.MsgGetData:
-		return records.NewGetDataRecord(m, r, l)
+		return records.NewGetDataRecord(m, r, l, t)
 
 	case *wire.MsgGetHeaders:
-		return records.NewGetHeadersRecord(m, r, l)
+		return records.NewGetHeadersRecord(m, r, l, t)
 
 	case *wire.MsgMemPool:
-		return records.NewMemPoolRecord(m, r, l)
+		return records.NewMemPoolRecord(m, r, l, t)
 
 	case *wire.MsgMerkleBlock:
-		return records.NewMerkleBlockRecord(m, r, l)
+		return records.NewMerkleBlockRecord(m, r, l, t)
 
 	case *wire.MsgNotFound:
-		return records.NewNotFoundRecord(m, r, l)
+		return records.NewNotFoundRecord(m, r, l, t)
 
 	case *wire.MsgVerAck:
-		return records.NewVerAckRecord(m, r, l)
+		return records.NewVerAckRecord(m, r, l, t)
 
 	default:
 		return nil
@@ -0,0 +1,213 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package geo looks up the country and autonomous system of IP addresses in a
+// local database, so that records can be annotated for geographic analysis.
+package geo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// cacheLimit is the number of lookups we keep before starting over, so that
+// the cache can not grow without bounds on long captures.
+const cacheLimit = 65536
+
+// Location is what the database knows about an IP. The country is the ISO
+// 3166 code and ASN the number of the autonomous system, zero if unknown.
+type Location struct {
+	Country string
+	ASN     uint32
+}
+
+// DB holds the networks of a database, sorted by their first address.
+type DB struct {
+	networks []network
+
+	cacheMutex *sync.Mutex
+	cache      map[string]Location
+}
+
+type network struct {
+	first    net.IP
+	last     net.IP
+	location Location
+}
+
+var (
+	openMutex = &sync.Mutex{}
+	opened    = make(map[string]*DB)
+)
+
+// Open loads the database at the given path. The database is a text file with
+// one network per line, given as CIDR, country code and AS number separated by
+// commas, as can be generated from the CSV exports of MaxMind and others.
+// Empty lines and lines starting with # are skipped. Databases are loaded only
+// once for each path, so that processors can share them.
+func Open(path string) (*DB, error) {
+	openMutex.Lock()
+	defer openMutex.Unlock()
+
+	db, ok := opened[path]
+	if ok {
+		return db, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	db = &DB{
+		cacheMutex: &sync.Mutex{},
+		cache:      make(map[string]Location),
+	}
+
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		n, err := parseNetwork(text)
+		if err != nil {
+			return nil, errors.New("line " + strconv.Itoa(line) + ": " +
+				err.Error())
+		}
+
+		db.networks = append(db.networks, n)
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(byFirst(db.networks))
+	opened[path] = db
+
+	return db, nil
+}
+
+// Lookup returns the location of the given IP. If the IP is in none of the
+// networks of the database, the location is empty.
+func (db *DB) Lookup(ip net.IP) Location {
+	ip = ip.To16()
+	if ip == nil {
+		return Location{}
+	}
+
+	key := string(ip)
+	db.cacheMutex.Lock()
+	loc, ok := db.cache[key]
+	db.cacheMutex.Unlock()
+	if ok {
+		return loc
+	}
+
+	// find the last network starting at or before the IP
+	i := sort.Search(len(db.networks), func(i int) bool {
+		return bytes.Compare(db.networks[i].first, ip) > 0
+	}) - 1
+	if i >= 0 && bytes.Compare(ip, db.networks[i].last) <= 0 {
+		loc = db.networks[i].location
+	}
+
+	db.cacheMutex.Lock()
+	if len(db.cache) >= cacheLimit {
+		db.cache = make(map[string]Location)
+	}
+	db.cache[key] = loc
+	db.cacheMutex.Unlock()
+
+	return loc
+}
+
+// parseNetwork parses a line of the database.
+func parseNetwork(text string) (network, error) {
+	fields := strings.Split(text, ",")
+	if len(fields) != 3 {
+		return network{}, errors.New("expected network, country and ASN")
+	}
+
+	_, ipNet, err := net.ParseCIDR(strings.TrimSpace(fields[0]))
+	if err != nil {
+		return network{}, err
+	}
+
+	asn := uint64(0)
+	field := strings.TrimPrefix(strings.TrimSpace(fields[2]), "AS")
+	if field != "" {
+		asn, err = strconv.ParseUint(field, 10, 32)
+		if err != nil {
+			return network{}, err
+		}
+	}
+
+	first := ipNet.IP.To16()
+	last := make(net.IP, net.IPv6len)
+	mask := ipNet.Mask
+	if len(mask) == net.IPv4len {
+		mask = append(net.CIDRMask(96, 128)[:12], mask...)
+	}
+
+	for i := range last {
+		last[i] = first[i] | ^mask[i]
+	}
+
+	n := network{
+		first: first,
+		last:  last,
+		location: Location{
+			Country: strings.ToUpper(strings.TrimSpace(fields[1])),
+			ASN:     uint32(asn),
+		},
+	}
+
+	return n, nil
+}
+
+// byFirst sorts networks by their first address.
+type byFirst []network
+
+func (networks byFirst) Len() int {
+	return len(networks)
+}
+
+func (networks byFirst) Less(i, j int) bool {
+	return bytes.Compare(networks[i].first, networks[j].first) < 0
+}
+
+func (networks byFirst) Swap(i, j int) {
+	networks[i], networks[j] = networks[j], networks[i]
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package geo
+
+import (
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+// testDB is a small database in the format we load, with networks out of
+// order and lines to skip.
+const testDB = `# network,country,asn
+8.8.8.0/24,us,AS15169
+
+2001:4860::/32,US,15169
+193.0.0.0/21,NL,3333
+194.154.192.0/19,LU,
+`
+
+func writeDB(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "geo.csv")
+	err := ioutil.WriteFile(path, []byte(content), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLookup(t *testing.T) {
+	db, err := Open(writeDB(t, testDB))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ip  string
+		loc Location
+	}{
+		{"8.8.8.8", Location{"US", 15169}},
+		{"8.8.9.1", Location{}},
+		{"::ffff:8.8.8.8", Location{"US", 15169}},
+		{"2001:4860:4860::8888", Location{"US", 15169}},
+		{"193.0.7.255", Location{"NL", 3333}},
+		{"194.154.200.1", Location{"LU", 0}},
+		{"192.0.2.1", Location{}},
+	}
+
+	// the second round is answered from the cache
+	for i := 0; i < 2; i++ {
+		for _, test := range tests {
+			loc := db.Lookup(net.ParseIP(test.ip))
+			if loc != test.loc {
+				t.Errorf("%v located at %v instead of %v", test.ip, loc,
+					test.loc)
+			}
+		}
+	}
+
+	if db.Lookup(nil) != (Location{}) {
+		t.Error("located invalid IP")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	path := writeDB(t, testDB)
+	first, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := Open(path)
+	if err != nil || second != first {
+		t.Error("database loaded twice")
+	}
+
+	_, err = Open(filepath.Join(t.TempDir(), "missing.csv"))
+	if err == nil {
+		t.Error("opened missing database")
+	}
+
+	for _, content := range []string{
+		"8.8.8.0/24,US\n",
+		"8.8.8.300/24,US,15169\n",
+		"8.8.8.0/24,US,ASX\n",
+	} {
+		_, err = Open(writeDB(t, content))
+		if err == nil {
+			t.Errorf("opened invalid database %q", content)
+		}
+	}
+}
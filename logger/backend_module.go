@@ -0,0 +1,124 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package logger
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+
+	"github.com/CIRCL/pbtc/processor"
+)
+
+// moduleBackend is a logging backend that writes the log lines of each module
+// to a separate rotating file, so that verbose modules do not drown out the
+// others. The files are created in the given directory on the first log line
+// of their module and are named after the module.
+type moduleBackend struct {
+	mutex     sync.Mutex
+	dir       string
+	format    logging.Formatter
+	level     logging.Level
+	sizelimit int64
+	agelimit  time.Duration
+	files     map[string]*processor.FileWriter
+	backends  map[string]logging.Backend
+}
+
+func newModuleBackend(dir string, format logging.Formatter,
+	level logging.Level, sizelimit int64,
+	agelimit time.Duration) *moduleBackend {
+	return &moduleBackend{
+		dir:       dir,
+		format:    format,
+		level:     level,
+		sizelimit: sizelimit,
+		agelimit:  agelimit,
+		files:     make(map[string]*processor.FileWriter),
+		backends:  make(map[string]logging.Backend),
+	}
+}
+
+// Log implements the logging backend interface by forwarding the record to the
+// backend of its module.
+func (mb *moduleBackend) Log(level logging.Level, calldepth int,
+	rec *logging.Record) error {
+	backend, err := mb.backend(rec.Module)
+	if err != nil {
+		return err
+	}
+
+	return backend.Log(level, calldepth+1, rec)
+}
+
+// Close closes the files of all modules.
+func (mb *moduleBackend) Close() {
+	mb.mutex.Lock()
+	defer mb.mutex.Unlock()
+
+	for _, file := range mb.files {
+		file.Stop()
+	}
+}
+
+// backend returns the backend of the given module, creating its file if this
+// is the first line logged by the module.
+func (mb *moduleBackend) backend(module string) (logging.Backend, error) {
+	mb.mutex.Lock()
+	defer mb.mutex.Unlock()
+
+	backend, ok := mb.backends[module]
+	if ok {
+		return backend, nil
+	}
+
+	path := filepath.Join(mb.dir, moduleFile(module))
+	file, err := newRotatingFile(path, mb.sizelimit, mb.agelimit)
+	if err != nil {
+		return nil, err
+	}
+
+	fBackend := logging.NewLogBackend(file, "", 0)
+	fFormatted := logging.NewBackendFormatter(fBackend, mb.format)
+	fLeveled := logging.AddModuleLevel(fFormatted)
+	fLeveled.SetLevel(mb.level, "")
+
+	mb.files[module] = file
+	mb.backends[module] = fLeveled
+
+	return fLeveled, nil
+}
+
+// moduleFile returns the file name for a module. The supervisor names modules
+// by type and configuration key, like "repo___main", which gives the file name
+// "repo-main.log", or just "repo.log" for the default key.
+func moduleFile(module string) string {
+	name := strings.Replace(module, "___", "-", -1)
+	name = strings.Trim(name, "-")
+	if name == "" {
+		name = "default"
+	}
+
+	return name + ".log"
+}
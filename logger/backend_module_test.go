@@ -0,0 +1,105 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package logger
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/op/go-logging"
+)
+
+func TestModuleBackend(t *testing.T) {
+	dir := t.TempDir()
+	mb := newModuleBackend(dir, logging.MustStringFormatter("%{message}"),
+		logging.DEBUG, 0, 0)
+	logging.SetBackend(mb)
+
+	logging.MustGetLogger("repo").Info("[REPO] %v", "from repo")
+	logging.MustGetLogger("mgr___main").Info("[MGR] %v", "from mgr")
+	mb.Close()
+
+	tests := map[string]string{
+		"repo.log":     "[REPO] from repo\n",
+		"mgr-main.log": "[MGR] from mgr\n",
+	}
+
+	for name, want := range tests {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+			continue
+		}
+
+		if string(data) != want {
+			t.Errorf("%v: got %q, want %q", name, data, want)
+		}
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	file, err := newRotatingFile(filepath.Join(dir, "pbtc.log"), 16, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err = file.Write([]byte(strings.Repeat("x", 20) + "\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file.Stop()
+
+	_, err = file.Write([]byte("late\n"))
+	if err == nil {
+		t.Error("wrote to stopped file")
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "pbtc-*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := 0
+	for _, name := range files {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// every line fills a file, so each has at most one
+		count := strings.Count(string(data), "\n")
+		if count > 1 || strings.HasPrefix(string(data), "#") {
+			t.Errorf("%v: unexpected content %q", name, data)
+		}
+
+		lines += count
+	}
+
+	if lines != 3 {
+		t.Errorf("%v lines in %v files", lines, len(files))
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package logger
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/CIRCL/pbtc/processor"
+)
+
+// newRotatingFile starts a file writer for the log lines written to the given
+// path, so that log files rotate like the output of the file writer. Without
+// limits, the lines go to the path itself. Otherwise, each file gets the time
+// it was started appended to its name. A limit of zero disables rotation for
+// that limit. The files are written as a stream, so that they are not copied on
+// rotation.
+func newRotatingFile(path string, sizelimit int64,
+	agelimit time.Duration) (*processor.FileWriter, error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "./"
+	}
+
+	suffix := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, suffix)
+
+	// the name is a time format, so the prefix has to stay out of it; busy
+	// logs can fill a file within a second, so we keep the nanoseconds
+	name := ""
+	if sizelimit > 0 || agelimit > 0 {
+		prefix += "-"
+		name = "2006-01-02T15:04:05.000000000Z07:00"
+	}
+
+	w, err := processor.NewFileWriter(
+		processor.SetFilePath(dir),
+		processor.SetFilePrefix(prefix),
+		processor.SetFileName(name),
+		processor.SetFileSuffix(suffix),
+		processor.SetFileSizelimit(sizelimit),
+		processor.SetFileAgelimit(agelimit),
+		processor.SetFileHeader(false),
+		processor.SetFileStream(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// the writer can't log to the files it writes, so it reports its problems
+	// on the console
+	w.SetLog(&consoleLog{log: log.New(os.Stderr, "", log.LstdFlags)})
+	w.Start()
+
+	return w, nil
+}
+
+// consoleLog writes warnings and errors to the console, while dropping the
+// messages about normal operation.
+type consoleLog struct {
+	log *log.Logger
+}
+
+func (cl *consoleLog) Debug(format string, args ...interface{}) {
+}
+
+func (cl *consoleLog) Info(format string, args ...interface{}) {
+}
+
+func (cl *consoleLog) Notice(format string, args ...interface{}) {
+}
+
+func (cl *consoleLog) Warning(format string, args ...interface{}) {
+	cl.log.Printf(format, args...)
+}
+
+func (cl *consoleLog) Error(format string, args ...interface{}) {
+	cl.log.Printf(format, args...)
+}
+
+func (cl *consoleLog) Critical(format string, args ...interface{}) {
+	cl.log.Printf(format, args...)
+}
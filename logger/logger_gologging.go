@@ -23,10 +23,12 @@ package logger
 import (
 	"errors"
 	"os"
+	"time"
 
 	"github.com/op/go-logging"
 
 	"github.com/CIRCL/pbtc/adaptor"
+	"github.com/CIRCL/pbtc/processor"
 )
 
 // GologgingLogger is a wrapper around the Go-logging library. It uses package
@@ -35,7 +37,8 @@ import (
 // to change the library in the future without having to rewrite other packages.
 type GologgingLogger struct {
 	backends []logging.Backend
-	file     *os.File
+	file     *processor.FileWriter
+	modules  *moduleBackend
 	name     string
 
 	consoleEnabled bool
@@ -45,6 +48,9 @@ type GologgingLogger struct {
 	fileFormat     logging.Formatter
 	fileLevel      logging.Level
 	filePath       string
+	fileSplit      bool
+	fileSizelimit  int64
+	fileAgelimit   time.Duration
 
 	log adaptor.Log
 }
@@ -105,8 +111,20 @@ func NewGologging(options ...func(log *GologgingLogger)) (*GologgingLogger,
 		logr.backends = append(logr.backends, cLeveled)
 	}
 
-	if logr.fileEnabled {
-		file, err := os.Create(logr.filePath)
+	if logr.fileEnabled && logr.fileSplit {
+		err := os.MkdirAll(logr.filePath, 0777)
+		if err != nil {
+			return nil, err
+		}
+
+		logr.modules = newModuleBackend(logr.filePath, logr.fileFormat,
+			logr.fileLevel, logr.fileSizelimit, logr.fileAgelimit)
+		logr.backends = append(logr.backends, logr.modules)
+	}
+
+	if logr.fileEnabled && !logr.fileSplit {
+		file, err := newRotatingFile(logr.filePath, logr.fileSizelimit,
+			logr.fileAgelimit)
 		if err != nil {
 			return nil, err
 		}
@@ -176,6 +194,37 @@ func SetFilePath(filePath string) func(*GologgingLogger) {
 	}
 }
 
+// SetFileSplit has to be passed as a parameter on logger construction. It
+// writes the log lines of each module to a separate file, named after the
+// module, instead of a single file. The file path is then used as the
+// directory for the module files.
+// EnableFile must be passed as a parameter for this option to have an effect.
+func SetFileSplit(split bool) func(*GologgingLogger) {
+	return func(logr *GologgingLogger) {
+		logr.fileSplit = split
+	}
+}
+
+// SetFileSizelimit has to be passed as a parameter on logger construction. It
+// sets the size in bytes upon which log files are rotated. Zero disables
+// rotation by size.
+// EnableFile must be passed as a parameter for this option to have an effect.
+func SetFileSizelimit(sizelimit int64) func(*GologgingLogger) {
+	return func(logr *GologgingLogger) {
+		logr.fileSizelimit = sizelimit
+	}
+}
+
+// SetFileAgelimit has to be passed as a parameter on logger construction. It
+// sets the age upon which log files are rotated. Zero disables rotation by
+// age.
+// EnableFile must be passed as a parameter for this option to have an effect.
+func SetFileAgelimit(agelimit time.Duration) func(*GologgingLogger) {
+	return func(logr *GologgingLogger) {
+		logr.fileAgelimit = agelimit
+	}
+}
+
 // SetFileFormat has to be passed as a parameter on logger construction. It
 // defines the format to be used by Gologging to write log lines to a file.
 // EnableFile must be passed as parameter for this option to have an effect.
@@ -203,7 +252,13 @@ func (logr *GologgingLogger) Start() {
 func (logr *GologgingLogger) Stop() {
 	logr.log.Info("[LOG] Stop: begin")
 
-	_ = logr.file.Close()
+	if logr.file != nil {
+		logr.file.Stop()
+	}
+
+	if logr.modules != nil {
+		logr.modules.Close()
+	}
 
 	logr.log.Info("[LOG] Stop: completed")
 }
@@ -0,0 +1,249 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package manager
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/gob"
+	"io/ioutil"
+	"net"
+	"os"
+	"time"
+
+	"github.com/CIRCL/pbtc/adaptor"
+	"github.com/CIRCL/pbtc/util"
+)
+
+// PeerHistory aggregates the traffic of all past connections to an address,
+// so that the behaviour of a peer can be followed across connections and,
+// if the history is persisted, across restarts. Connected is the total time
+// we were connected to the peer.
+type PeerHistory struct {
+	Address      string
+	Connections  uint64
+	FirstSeen    time.Time
+	LastSeen     time.Time
+	Connected    time.Duration
+	BytesRead    uint64
+	MessagesRead uint64
+	Useful       uint64
+	Commands     map[string]uint64
+	Latency      adaptor.Latency
+}
+
+// ByteRate returns the average bytes per second received from the peer while
+// we were connected to it.
+func (h *PeerHistory) ByteRate() float64 {
+	if h.Connected <= 0 {
+		return 0
+	}
+
+	return float64(h.BytesRead) / h.Connected.Seconds()
+}
+
+// MessageRate returns the average messages per second received from the peer
+// while we were connected to it.
+func (h *PeerHistory) MessageRate() float64 {
+	if h.Connected <= 0 {
+		return 0
+	}
+
+	return float64(h.MessagesRead) / h.Connected.Seconds()
+}
+
+// copy returns a deep copy of the history, which can be handed out while the
+// original keeps accumulating.
+func (h *PeerHistory) copy() PeerHistory {
+	c := *h
+	c.Commands = make(map[string]uint64, len(h.Commands))
+	for cmd, count := range h.Commands {
+		c.Commands[cmd] = count
+	}
+
+	return c
+}
+
+// SetPeerHistory has to be passed as a parameter on manager creation. It
+// makes the manager keep the aggregate statistics of the given number of
+// addresses, adding the traffic of each connection once it is closed. If the
+// limit is reached, the address that was seen the longest time ago is
+// forgotten. If a path is given, the history is restored from that file on
+// start and saved to it on stop, so it accumulates over restarts. A limit of
+// zero disables the history.
+func SetPeerHistory(path string, limit int) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.histPath = path
+		mgr.histLimit = limit
+	}
+}
+
+// PeerHistory returns the aggregate statistics of all past connections to the
+// given address, if there are any.
+func (mgr *Manager) PeerHistory(addr *net.TCPAddr) (PeerHistory, bool) {
+	mgr.histMutex.Lock()
+	defer mgr.histMutex.Unlock()
+
+	e, ok := mgr.histIndex[util.CanonicalAddr(addr)]
+	if !ok {
+		return PeerHistory{}, false
+	}
+
+	return e.Value.(*PeerHistory).copy(), true
+}
+
+// addHistory adds the traffic of a stopped peer to the history of its address.
+// Peers that never connected have no traffic and are left out.
+func (mgr *Manager) addHistory(p adaptor.Peer, stats adaptor.PeerStats) {
+	if mgr.histLimit <= 0 || stats.Connected.IsZero() {
+		return
+	}
+
+	mgr.histMutex.Lock()
+	defer mgr.histMutex.Unlock()
+
+	now := mgr.clock()
+	key := util.CanonicalAddr(p.Addr())
+	e, ok := mgr.histIndex[key]
+	if !ok {
+		if mgr.histOrder.Len() >= mgr.histLimit {
+			mgr.forgetHistory(mgr.histOrder.Back())
+		}
+
+		e = mgr.histOrder.PushFront(&PeerHistory{
+			Address:   key,
+			FirstSeen: stats.Connected,
+			Commands:  make(map[string]uint64),
+		})
+		mgr.histIndex[key] = e
+	}
+
+	mgr.histOrder.MoveToFront(e)
+
+	h := e.Value.(*PeerHistory)
+	h.Connections++
+	h.LastSeen = now
+	if now.After(stats.Connected) {
+		h.Connected += now.Sub(stats.Connected)
+	}
+
+	h.BytesRead += stats.BytesRead
+	h.MessagesRead += stats.MessagesRead
+	h.Useful += stats.Useful
+	for cmd, count := range stats.Commands {
+		h.Commands[cmd] += count
+	}
+
+	h.Latency.Merge(stats.Latency)
+}
+
+// forgetHistory removes the history of an address. It needs to be called with
+// the mutex held.
+func (mgr *Manager) forgetHistory(e *list.Element) {
+	h := mgr.histOrder.Remove(e).(*PeerHistory)
+	delete(mgr.histIndex, h.Address)
+}
+
+// restoreHistory loads the history saved on the last stop. A missing file
+// means we start without history; a corrupt one is logged and ignored.
+func (mgr *Manager) restoreHistory() {
+	if mgr.histLimit <= 0 || mgr.histPath == "" {
+		return
+	}
+
+	data, err := ioutil.ReadFile(mgr.histPath)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		mgr.log.Warning("[MGR] Could not read peer history (%v)", err)
+		return
+	}
+
+	var histories []*PeerHistory
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	err = dec.Decode(&histories)
+	if err != nil {
+		mgr.log.Warning("[MGR] Could not restore peer history (%v)", err)
+		return
+	}
+
+	mgr.histMutex.Lock()
+	defer mgr.histMutex.Unlock()
+
+	// the file is ordered from the most to the least recently seen address,
+	// so we keep the most recent ones if the limit was lowered
+	for _, h := range histories {
+		if mgr.histOrder.Len() >= mgr.histLimit {
+			break
+		}
+
+		if _, ok := mgr.histIndex[h.Address]; ok {
+			continue
+		}
+
+		if h.Commands == nil {
+			h.Commands = make(map[string]uint64)
+		}
+
+		mgr.histIndex[h.Address] = mgr.histOrder.PushBack(h)
+	}
+
+	mgr.log.Info("[MGR] Restored history of %v peers from %v",
+		mgr.histOrder.Len(), mgr.histPath)
+}
+
+// saveHistory writes the history to its file, ordered from the most to the
+// least recently seen address. The file is written under a temporary name and
+// then renamed, so that an interrupted save never leaves a broken file behind.
+func (mgr *Manager) saveHistory() {
+	if mgr.histLimit <= 0 || mgr.histPath == "" {
+		return
+	}
+
+	mgr.histMutex.Lock()
+	histories := make([]*PeerHistory, 0, mgr.histOrder.Len())
+	for e := mgr.histOrder.Front(); e != nil; e = e.Next() {
+		histories = append(histories, e.Value.(*PeerHistory))
+	}
+
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+	err := enc.Encode(histories)
+	mgr.histMutex.Unlock()
+	if err != nil {
+		mgr.log.Error("[MGR] Could not encode peer history (%v)", err)
+		return
+	}
+
+	temp := mgr.histPath + ".tmp"
+	err = ioutil.WriteFile(temp, buf.Bytes(), 0666)
+	if err == nil {
+		err = os.Rename(temp, mgr.histPath)
+	}
+	if err != nil {
+		mgr.log.Error("[MGR] Could not save peer history (%v)", err)
+		return
+	}
+
+	mgr.log.Info("[MGR] Saved history of %v peers to %v", len(histories),
+		mgr.histPath)
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package manager
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/CIRCL/pbtc/adaptor"
+	"github.com/CIRCL/pbtc/pbtctest"
+	"github.com/CIRCL/pbtc/tracker"
+)
+
+// startHistory starts a manager that keeps the peer history in the given
+// file, without connecting to any peers.
+func startHistory(t *testing.T, path string, limit int,
+	now time.Time) *Manager {
+	mgr := newTestManager(t, SetConnectOut(false),
+		SetPeerHistory(path, limit),
+		SetClock(func() time.Time { return now }))
+	mgr.SetRepository(pbtctest.NewRepository())
+
+	tkr, err := tracker.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr.SetTracker(tkr)
+
+	mgr.Start()
+
+	return mgr
+}
+
+func TestPeerHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.dat")
+	now := time.Unix(1500000000, 0)
+	addr := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 8333}
+	stats := adaptor.PeerStats{
+		Connected:    now.Add(-time.Minute),
+		BytesRead:    6000,
+		MessagesRead: 60,
+		Useful:       6,
+		Commands:     map[string]uint64{"inv": 50, "addr": 10},
+	}
+	stats.Latency.Add(20 * time.Millisecond)
+
+	mgr := startHistory(t, path, 2, now)
+	mgr.addHistory(pbtctest.NewPeer(addr), stats)
+
+	// peers that never connected leave no history
+	never := &net.TCPAddr{IP: net.ParseIP("192.0.2.9"), Port: 8333}
+	mgr.addHistory(pbtctest.NewPeer(never), adaptor.PeerStats{})
+	mgr.Stop()
+
+	// the history is restored on start and keeps accumulating, also when
+	// the address comes in another form
+	mgr = startHistory(t, path, 2, now.Add(time.Hour))
+	mapped := &net.TCPAddr{IP: net.ParseIP("::ffff:192.0.2.1"), Port: 8333}
+	stats.Connected = now.Add(time.Hour - time.Minute)
+	mgr.addHistory(pbtctest.NewPeer(mapped), stats)
+	mgr.Stop()
+
+	mgr = startHistory(t, path, 2, now.Add(time.Hour))
+	defer mgr.Stop()
+
+	h, ok := mgr.PeerHistory(addr)
+	if !ok {
+		t.Fatal("history not restored")
+	}
+
+	if h.Connections != 2 || h.Connected != 2*time.Minute ||
+		h.BytesRead != 12000 || h.MessagesRead != 120 || h.Useful != 12 ||
+		h.Commands["inv"] != 100 || h.Latency.Samples != 2 {
+		t.Errorf("history %+v", h)
+	}
+
+	if !h.FirstSeen.Equal(now.Add(-time.Minute)) ||
+		!h.LastSeen.Equal(now.Add(time.Hour)) {
+		t.Errorf("seen from %v to %v", h.FirstSeen, h.LastSeen)
+	}
+
+	if h.ByteRate() != 100 || h.MessageRate() != 1 {
+		t.Errorf("rates of %v bytes and %v messages", h.ByteRate(),
+			h.MessageRate())
+	}
+
+	_, ok = mgr.PeerHistory(never)
+	if ok {
+		t.Error("history of a peer that never connected")
+	}
+}
+
+func TestPeerHistoryLimit(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	mgr := newTestManager(t, SetPeerHistory("", 2),
+		SetClock(func() time.Time { return now }))
+
+	addrs := make([]*net.TCPAddr, 3)
+	for i := range addrs {
+		addrs[i] = &net.TCPAddr{IP: net.IPv4(192, 0, 2, byte(i+1)),
+			Port: 8333}
+	}
+
+	stats := adaptor.PeerStats{Connected: now.Add(-time.Minute)}
+	mgr.addHistory(pbtctest.NewPeer(addrs[0]), stats)
+	mgr.addHistory(pbtctest.NewPeer(addrs[1]), stats)
+	mgr.addHistory(pbtctest.NewPeer(addrs[0]), stats)
+
+	// the address seen the longest time ago is forgotten first
+	mgr.addHistory(pbtctest.NewPeer(addrs[2]), stats)
+
+	tests := []bool{true, false, true}
+	for i, kept := range tests {
+		_, ok := mgr.PeerHistory(addrs[i])
+		if ok != kept {
+			t.Errorf("history of %v kept: %v", addrs[i], ok)
+		}
+	}
+}
@@ -21,59 +21,179 @@
 package manager
 
 import (
+	"container/list"
+	"errors"
+	"math/rand"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/btcsuite/btcd/wire"
 
 	"github.com/CIRCL/pbtc/adaptor"
 	"github.com/CIRCL/pbtc/parmap"
+	"github.com/CIRCL/pbtc/peer"
+	"github.com/CIRCL/pbtc/records"
+	"github.com/CIRCL/pbtc/util"
 )
 
+const (
+	stateIdle = iota
+	stateRunning
+)
+
+// The processors are checked for backpressure at every pressure interval. The
+// manager only starts or stops backing off after the same result was seen for
+// the given number of checks in a row, so that short bursts are ignored.
+const (
+	pressureInterval = time.Second
+	pressureSamples  = 5
+)
+
+// Peers are checked for being idle at every idle interval.
+const idleInterval = 10 * time.Second
+
 // Manager is the module responsible for peer management. It will initialize
 // new incoming & outgoing peers and take care of state transitions. As the
 // main control instance, it defines most of the behaviour of our peer.
 type Manager struct {
-	wg  *sync.WaitGroup
-	sig chan struct{}
+	wg        *sync.WaitGroup
+	sig       chan struct{}
+	state     uint32
+	slots     int32
+	throttled uint32
+	pressured uint32
+	pressures int
 
-	incomingQ  chan adaptor.Peer
-	outgoingQ  chan adaptor.Peer
 	connectedQ chan adaptor.Peer
 	readyQ     chan adaptor.Peer
 	stoppedQ   chan adaptor.Peer
+	addrQ      chan *net.TCPAddr
 
 	tickerT *time.Ticker
+	connT   *time.Ticker
+	ageT    *time.Timer
 
 	peerIndex   *parmap.ParMap
 	listenIndex map[string]*net.TCPListener
 
 	network        wire.BitcoinNet
 	version        uint32
+	minVersion     uint32
 	connRate       time.Duration
 	tickerInterval time.Duration
 	connLimit      int
-
-	log  adaptor.Log
-	repo adaptor.Repository
-	tkr  adaptor.Tracker
-	pro  []adaptor.Processor
+	dialLimit      int
+	routineLimit   int
+	peerMaxAge     time.Duration
+	lifetimeMin    time.Duration
+	lifetimeMax    time.Duration
+	idleOutbound   time.Duration
+	idleInbound    time.Duration
+	addrMaxAge     time.Duration
+	addrLimit      int
+	msgCap         uint64
+	pollCooldown   time.Duration
+	groupLimit     int
+	groupPrefix    int
+	topologyRate   time.Duration
+	versionWait    time.Duration
+	handshakeWait  time.Duration
+	keepAlive      time.Duration
+	jitter         float64
+	budget         uint64
+	proxies        []peer.ProxySpec
+	proxyRotation  bool
+	proxyFallback  bool
+	locals         []net.IP
+	trustedList    []string
+	passive        bool
+	relay          bool
+	raw            bool
+	connectOut     bool
+	learnAddr      bool
+	learnVersion   bool
+	learnInbound   bool
+	checkServices  bool
+
+	dialer  *peer.Dialer
+	clock   func() time.Time
+	onState func(adaptor.State, adaptor.State)
+	name    string
+
+	log     adaptor.Log
+	peerLog adaptor.Log
+	repo    adaptor.Repository
+	tkr     adaptor.Tracker
+
+	proMutex *sync.Mutex
+	pro      *atomic.Value
+
+	harvestMutex *sync.Mutex
+	harvested    map[string]time.Time
+
+	groupMutex *sync.Mutex
+
+	reconnectMutex *sync.Mutex
+	reconnects     map[string]*net.TCPAddr
+
+	dialMutex *sync.Mutex
+	dialing   map[string]struct{}
+
+	lifetimeMutex *sync.Mutex
+	lifetimes     map[string]*time.Timer
+
+	trusted    []*net.TCPAddr
+	trustedIPs map[string]struct{}
+
+	sesMutex    *sync.Mutex
+	sesStart    time.Time
+	sesPeers    map[string]struct{}
+	sesBytes    uint64
+	sesBans     uint64
+	sesCommands map[string]uint64
+
+	histMutex *sync.Mutex
+	histPath  string
+	histLimit int
+	histIndex map[string]*list.Element
+	histOrder *list.List
 
 	nonce uint64
 }
 
+// Throughput is the aggregate traffic received from all peers of a manager.
+// The rates are given per second and computed over a sliding window. Routines
+// is the total number of handler go routines running for the peers. Budget is
+// the bandwidth budget in bytes per second, with zero meaning no budget, and
+// Throttled tells whether the manager is currently backing off because the
+// budget was exceeded. Backpressure tells whether it is backing off because
+// the processors can not keep up. Latency merges the ping round-trip times of
+// all peers.
+type Throughput struct {
+	Peers        int
+	Routines     int
+	BytesRead    uint64
+	MessagesRead uint64
+	ByteRate     float64
+	MessageRate  float64
+	Budget       uint64
+	Throttled    bool
+	Backpressure bool
+	Latency      adaptor.Latency
+}
+
 // New returns a new manager initialized with the given options.
 func New(options ...func(mgr *Manager)) (*Manager, error) {
 	mgr := &Manager{
 		wg:  &sync.WaitGroup{},
 		sig: make(chan struct{}),
 
-		incomingQ:  make(chan adaptor.Peer, 1),
-		outgoingQ:  make(chan adaptor.Peer, 1),
 		connectedQ: make(chan adaptor.Peer, 1),
 		readyQ:     make(chan adaptor.Peer, 1),
 		stoppedQ:   make(chan adaptor.Peer, 1),
+		addrQ:      make(chan *net.TCPAddr, 8),
 
 		peerIndex:   parmap.New(),
 		listenIndex: make(map[string]*net.TCPListener),
@@ -83,8 +203,45 @@ func New(options ...func(mgr *Manager)) (*Manager, error) {
 		connRate:       time.Second / 10,
 		connLimit:      100,
 		tickerInterval: time.Second * 10,
+		jitter:         0.1,
+		relay:          true,
+		connectOut:     true,
+		learnAddr:      true,
+		learnInbound:   true,
+		checkServices:  true,
+
+		clock: time.Now,
+
+		proMutex: &sync.Mutex{},
+		pro:      &atomic.Value{},
+
+		harvestMutex: &sync.Mutex{},
+		harvested:    make(map[string]time.Time),
+
+		groupMutex: &sync.Mutex{},
+
+		reconnectMutex: &sync.Mutex{},
+		reconnects:     make(map[string]*net.TCPAddr),
+
+		dialMutex: &sync.Mutex{},
+		dialing:   make(map[string]struct{}),
+
+		lifetimeMutex: &sync.Mutex{},
+		lifetimes:     make(map[string]*time.Timer),
+
+		trustedIPs: make(map[string]struct{}),
+
+		sesMutex:    &sync.Mutex{},
+		sesPeers:    make(map[string]struct{}),
+		sesCommands: make(map[string]uint64),
+
+		histMutex: &sync.Mutex{},
+		histIndex: make(map[string]*list.Element),
+		histOrder: list.New(),
 	}
 
+	mgr.pro.Store([]adaptor.Processor{})
+
 	nonce, err := wire.RandomUint64()
 	if err != nil {
 		return nil, err
@@ -96,6 +253,15 @@ func New(options ...func(mgr *Manager)) (*Manager, error) {
 		option(mgr)
 	}
 
+	if mgr.dialer == nil {
+		mgr.dialer = peer.NewDialer(
+			peer.SetProxyList(mgr.proxies),
+			peer.SetProxyRotation(mgr.proxyRotation),
+			peer.SetProxyFallback(mgr.proxyFallback),
+			peer.SetLocalAddrs(mgr.locals),
+		)
+	}
+
 	return mgr, nil
 }
 
@@ -108,13 +274,25 @@ func SetProtocolMagic(network wire.BitcoinNet) func(*Manager) {
 }
 
 // SetVersion has to be passed as a parameter on manager creation. It sets the
-// maximum protocol version to be used for peer communication.
+// maximum protocol version to be used for peer communication. Peers only send
+// fee filters if it is at least 70013.
 func SetProtocolVersion(version uint32) func(*Manager) {
 	return func(mgr *Manager) {
 		mgr.version = version
 	}
 }
 
+// SetMinProtocolVersion has to be passed as a parameter on manager creation. It
+// sets the lowest protocol version we accept from peers, so that we only keep
+// the ones supporting the messages we care about. Peers below are disconnected
+// during the handshake; as they never complete it, the repository does not
+// hand out their addresses again.
+func SetMinProtocolVersion(version uint32) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.minVersion = version
+	}
+}
+
 // SetConnectionRate has to be passed as a parameter on manager creation. It
 // sets the maximum number of attempted TCP connections per second.
 func SetConnectionRate(connRate time.Duration) func(*Manager) {
@@ -132,31 +310,385 @@ func SetConnectionLimit(connLimit int) func(*Manager) {
 	}
 }
 
+// SetDialConcurrency has to be passed as a parameter on manager creation. It
+// sets the maximum number of outgoing connections that are being dialed at the
+// same time. Once it is reached, we stop retrieving new addresses until a dial
+// either connects or fails, so a batch of slow or unreachable nodes does not
+// pile up half-open connections. The connection rate and limit still apply.
+// Zero means no limit.
+func SetDialConcurrency(dialLimit int) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.dialLimit = dialLimit
+	}
+}
+
+// SetRoutineLimit has to be passed as a parameter on manager creation. It sets
+// the maximum number of handler go routines all peers may run together. If the
+// limit is reached, no new peers are created until some have stopped. This is
+// meant as a safety valve for large deployments; zero means no limit.
+func SetRoutineLimit(routineLimit int) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.routineLimit = routineLimit
+	}
+}
+
+// SetPeerMaxAge has to be passed as a parameter on manager creation. It sets
+// the maximum time we stay connected to an outgoing peer, so that we sample
+// more of the network over time. Expired peers are disconnected one at a time,
+// spread out so that all peers are cycled about once per maximum age, and
+// replaced by new ones. Zero means peers are kept indefinitely.
+func SetPeerMaxAge(peerMaxAge time.Duration) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.peerMaxAge = peerMaxAge
+	}
+}
+
+// SetConnectionLifetime has to be passed as a parameter on manager creation.
+// It gives every outgoing peer a random lifetime, uniformly distributed between
+// the given minimum and maximum, after which it is disconnected. The freed slot
+// is filled again by the regular connection attempts, so that no single peer
+// dominates long captures. Unlike the maximum age, which cycles the oldest peer
+// at a fixed pace, each connection has its own limit. A maximum of zero
+// disables lifetimes.
+func SetConnectionLifetime(min time.Duration,
+	max time.Duration) func(*Manager) {
+	return func(mgr *Manager) {
+		if max < min {
+			min, max = max, min
+		}
+
+		mgr.lifetimeMin = min
+		mgr.lifetimeMax = max
+	}
+}
+
+// SetPeerIdleTimeout has to be passed as a parameter on manager creation. It
+// sets how long peers may go without sending us anything but pings after the
+// handshake, before they are dropped to make room for more talkative peers.
+// Inbound peers have a timeout of their own, as they might be light clients
+// with little to say. Zero disables the timeout.
+func SetPeerIdleTimeout(outbound time.Duration,
+	inbound time.Duration) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.idleOutbound = outbound
+		mgr.idleInbound = inbound
+	}
+}
+
+// SetAddrMaxAge has to be passed as a parameter on manager creation. It sets
+// the maximum age of the entries of address messages that are stored in the
+// repository. Many entries are stale, so skipping old ones when they come in
+// keeps the node pool clean. Zero means entries of any age are stored.
+func SetAddrMaxAge(maxAge time.Duration) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.addrMaxAge = maxAge
+	}
+}
+
+// SetAddrLimit has to be passed as a parameter on manager creation. It sets
+// the maximum number of entries of a single address message that are stored in
+// the repository, which limits how fast the node pool grows. Zero means all
+// entries are stored.
+func SetAddrLimit(limit int) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.addrLimit = limit
+	}
+}
+
+// SetPerPeerMessageCap has to be passed as a parameter on manager creation. It
+// limits the recorded messages of each connection to the given number, which
+// gives a bounded and comparable sample of connection openings. Peers stay
+// connected once they reach the cap, so that we keep harvesting addresses, and
+// the count starts again when we reconnect. Zero means there is no limit.
+func SetPerPeerMessageCap(limit uint64) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.msgCap = limit
+	}
+}
+
+// SetMaxPeersPerGroup has to be passed as a parameter on manager creation. It
+// sets the maximum number of peers, inbound and outbound, that we manage at
+// the same time from one network group, so that our view of the network is
+// never concentrated on a few providers. By default, peers are grouped like
+// Bitcoin Core does; a prefix length other than zero groups IPv4 addresses by
+// that prefix instead, and IPv6 addresses by twice the length. A limit of zero
+// means there is no limit.
+func SetMaxPeersPerGroup(limit int, prefix int) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.groupLimit = limit
+		mgr.groupPrefix = prefix
+	}
+}
+
+// SetVersionTimeout has to be passed as a parameter on manager creation. It
+// sets the time within which peers have to send their version message once
+// connected. Peers that don't are disconnected, which is recorded as timeout
+// in the version stage. Zero means there is no limit.
+func SetVersionTimeout(timeout time.Duration) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.versionWait = timeout
+	}
+}
+
+// SetHandshakeTimeout has to be passed as a parameter on manager creation. It
+// sets the time within which the handshake with peers has to be complete once
+// connected. Peers that don't are disconnected, which is recorded as timeout
+// in the stage the handshake stalled in. Zero means there is no limit.
+func SetHandshakeTimeout(timeout time.Duration) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.handshakeWait = timeout
+	}
+}
+
+// SetTopologyInterval has to be passed as a parameter on manager creation. It
+// sets the interval at which a snapshot of the connected peers, by direction,
+// network group and software family, is sent to the processors, so that the
+// composition of our view of the network can be charted over time. The
+// network groups use the prefix length of the group limit. Zero means no
+// snapshots are emitted.
+func SetTopologyInterval(interval time.Duration) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.topologyRate = interval
+	}
+}
+
+// SetTCPKeepAlive has to be passed as a parameter on manager creation. It sets
+// the idle time after which the operating system starts probing peer
+// connections, so that half-open connections are closed without waiting for a
+// failed write. Zero keeps the default behaviour of the connections.
+func SetTCPKeepAlive(idle time.Duration) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.keepAlive = idle
+	}
+}
+
+// SetPollCooldown has to be passed as a parameter on manager creation. It sets
+// for how long we don't ask a peer for addresses again after it sent us a full
+// batch, as it is unlikely to know many new ones so soon. Zero means peers are
+// always asked.
+func SetPollCooldown(cooldown time.Duration) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.pollCooldown = cooldown
+	}
+}
+
+// SetTimerJitter has to be passed as a parameter on manager creation. It sets
+// the fraction by which the peer rotation timer is randomly spread, so that
+// several instances do not cycle their peers in lockstep.
+func SetTimerJitter(fraction float64) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.jitter = fraction
+	}
+}
+
+// SetBandwidthBudget has to be passed as a parameter on manager creation. It
+// sets a soft cap on the ingress traffic of all peers together, in bytes per
+// second. While the budget is exceeded, no new connections are made and newly
+// connected peers are not polled for addresses; both resume once the traffic
+// falls back below nine tenths of the budget. Zero means no budget.
+func SetBandwidthBudget(bytesPerSec uint64) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.budget = bytesPerSec
+	}
+}
+
 func SetTickerInterval(tickerInterval time.Duration) func(*Manager) {
 	return func(mgr *Manager) {
 		mgr.tickerInterval = tickerInterval
 	}
 }
 
+// SetClock has to be passed as a parameter on manager creation. It sets the
+// function used by peers to timestamp their records, which defaults to the
+// current time.
+func SetClock(clock func() time.Time) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.clock = clock
+	}
+}
+
+// SetPassiveOnly has to be passed as a parameter on manager creation. It makes
+// all peers strictly passive: they only send what is needed to complete the
+// handshake and to answer pings, and never request or relay anything. This
+// overrides all polling options.
+func SetPassiveOnly(passive bool) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.passive = passive
+	}
+}
+
+// SetConnectOut has to be passed as a parameter on manager creation. If it is
+// disabled, the manager never dials out and only manages the peers that connect
+// to us, which turns the node into a honeypot for studying who connects to it.
+// Addresses are still learned from the peers, but not acted upon. It is
+// enabled by default.
+func SetConnectOut(out bool) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.connectOut = out
+	}
+}
+
+// SetRecordRaw has to be passed as a parameter on manager creation. If it is
+// set, messages with commands we do not support are recorded with their raw
+// payload, so they can be analyzed offline, instead of being skipped.
+func SetRecordRaw(raw bool) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.raw = raw
+	}
+}
+
+// SetAddrSources has to be passed as a parameter on manager creation. It sets
+// whether peers learn addresses from address messages, from the address in
+// version messages and from inbound peers at all, as a control against address
+// poisoning. By default, addresses are learned from address messages of all
+// peers.
+func SetAddrSources(addr bool, version bool, inbound bool) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.learnAddr = addr
+		mgr.learnVersion = version
+		mgr.learnInbound = inbound
+	}
+}
+
+// SetServiceChecks has to be passed as a parameter on manager creation. It
+// sets whether peers only request the data that the services advertised in
+// the version message of the remote node allow for. Checks are enabled by
+// default.
+func SetServiceChecks(check bool) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.checkServices = check
+	}
+}
+
+// SetRelay has to be passed as a parameter on manager creation. It sets the
+// relay flag of our version messages, which decides whether peers announce new
+// transactions to us unsolicited. It is enabled by default.
+func SetRelay(relay bool) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.relay = relay
+	}
+}
+
+// SetProxies has to be passed as a parameter on manager creation. It sets the
+// list of SOCKS5 proxies used for outgoing connections. For each connection
+// attempt, the proxies are tried in order until one of them succeeds.
+func SetProxies(proxies []peer.ProxySpec) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.proxies = proxies
+	}
+}
+
+// SetProxyRotation has to be passed as a parameter on manager creation. It
+// makes every connection attempt start with the next proxy in the list, so
+// that the load is spread over all proxies.
+func SetProxyRotation(rotation bool) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.proxyRotation = rotation
+	}
+}
+
+// SetDialer has to be passed as a parameter on manager creation. It sets the
+// dialer used by the peers for outgoing connections, like one connecting them
+// over in-memory pipes for testing. The proxy options are ignored in that case.
+func SetDialer(dialer *peer.Dialer) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.dialer = dialer
+	}
+}
+
+// SetProxyFallback has to be passed as a parameter on manager creation. It
+// allows a direct connection to the peer if all proxies failed.
+func SetProxyFallback(fallback bool) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.proxyFallback = fallback
+	}
+}
+
+// SetLocalAddrs has to be passed as a parameter on manager creation. It sets
+// the local IPs that outgoing connections originate from, which are used in
+// turn for each family. The option is ignored if a dialer is set.
+func SetLocalAddrs(ips []net.IP) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.locals = ips
+	}
+}
+
+// SetTrustedPeers has to be passed as a parameter on manager creation. It sets
+// the addresses, in host:port format, of peers we always want to be connected
+// to. We dial them whenever we are not connected, regardless of the retry
+// interval of the repository, and they are exempt from the connection, group
+// and routine limits, as well as from the maximum age, lifetime and idle
+// timeout. Inbound connections from their IPs are trusted as well.
+func SetTrustedPeers(addresses []string) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.trustedList = addresses
+	}
+}
+
+// SetName has to be passed as a parameter on manager creation. It sets the
+// name of the manager, which is added to its log messages and those of its
+// peers, so that several managers can be told apart.
+func SetName(name string) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.name = name
+	}
+}
+
+// SetStateHandler has to be passed as a parameter on manager creation. It sets
+// a function that is called with the old and the new state whenever the
+// manager is started or stopped. It is called without holding any lock of the
+// manager, so it may call its methods.
+func SetStateHandler(
+	handler func(adaptor.State, adaptor.State)) func(*Manager) {
+	return func(mgr *Manager) {
+		mgr.onState = handler
+	}
+}
+
 func (mgr *Manager) Start() {
 	mgr.log.Info("[MGR] Start: begin")
 
+	mgr.resolveTrusted()
+	mgr.restoreHistory()
+
 	mgr.tickerT = time.NewTicker(mgr.tickerInterval)
+	if mgr.connectOut {
+		mgr.connT = time.NewTicker(mgr.connRate)
+	}
 
-	mgr.wg.Add(2)
+	if mgr.peerMaxAge > 0 {
+		mgr.ageT = time.NewTimer(util.Jitter(mgr.ageInterval(), mgr.jitter))
+	}
+
+	mgr.sesStart = mgr.clock()
+
+	mgr.wg.Add(3)
 	go mgr.goTicker()
 	go mgr.goEvents()
 	go mgr.goPeers()
 
+	mgr.transition(stateRunning)
+
 	mgr.log.Info("[MGR] Start: completed")
 }
 
-// Close will clean-up before shutdown.
+// Close will clean-up before shutdown. Once all peers are stopped, a summary of
+// the session is logged and sent to the processors, and the peer history is
+// saved.
 func (mgr *Manager) Stop() {
 	mgr.log.Info("[MGR] Stop: begin")
 
+	mgr.transition(stateIdle)
+
 	close(mgr.sig)
 
+	if mgr.connT != nil {
+		mgr.connT.Stop()
+	}
+	if mgr.ageT != nil {
+		mgr.ageT.Stop()
+	}
+
 	for s := range mgr.peerIndex.Iter() {
 		p := s.(adaptor.Peer)
 		p.Stop()
@@ -164,14 +696,60 @@ func (mgr *Manager) Stop() {
 
 	mgr.wg.Wait()
 
+	mgr.summarize()
+	mgr.saveHistory()
+
 	mgr.log.Info("[MGR] Stop: completed")
 }
 
+// transition sets the state of the manager and reports the change to the state
+// handler.
+func (mgr *Manager) transition(state uint32) {
+	old := atomic.SwapUint32(&mgr.state, state)
+	if mgr.onState != nil && old != state {
+		mgr.onState(adaptor.State(old), adaptor.State(state))
+	}
+}
+
+// Healthy returns whether the manager is running and has peers to work with.
+func (mgr *Manager) Healthy() (bool, error) {
+	if atomic.LoadUint32(&mgr.state) != stateRunning {
+		return false, errors.New("manager not running")
+	}
+
+	if mgr.peerIndex.Count() == 0 {
+		return false, errors.New("manager has no peers")
+	}
+
+	return true, nil
+}
+
 func (mgr *Manager) SetLog(log adaptor.Log) {
-	mgr.log = log
+	mgr.log = util.NamedLog(log, mgr.name)
+}
+
+// SetPeerLog sets the log used by the peers of the manager, so that their
+// messages can go to their own file. Without it, peers use the log of the
+// manager.
+func (mgr *Manager) SetPeerLog(log adaptor.Log) {
+	mgr.peerLog = util.NamedLog(log, mgr.name)
 }
 
+// Name returns the name the manager was created with.
+func (mgr *Manager) Name() string {
+	return mgr.name
+}
+
+// Network returns the Bitcoin network the manager connects to.
+func (mgr *Manager) Network() wire.BitcoinNet {
+	return mgr.network
+}
+
+// SetRepository sets the repository used by the manager to find and keep
+// track of nodes. The repository is set to the network of the manager, so it
+// only hands out addresses for that network.
 func (mgr *Manager) SetRepository(repo adaptor.Repository) {
+	repo.SetNetwork(mgr.network)
 	mgr.repo = repo
 }
 
@@ -179,20 +757,87 @@ func (mgr *Manager) SetTracker(tkr adaptor.Tracker) {
 	mgr.tkr = tkr
 }
 
+// AddProcessor adds a processor that will receive the records of all peers. It
+// is safe to call while the manager is running; peers pick up the change with
+// their next message.
 func (mgr *Manager) AddProcessor(pro adaptor.Processor) {
-	mgr.pro = append(mgr.pro, pro)
+	mgr.proMutex.Lock()
+	defer mgr.proMutex.Unlock()
+
+	current := mgr.Processors()
+	list := make([]adaptor.Processor, 0, len(current)+1)
+	list = append(list, current...)
+	list = append(list, pro)
+	mgr.pro.Store(list)
+}
+
+// RemoveProcessor removes a processor from the list of processors receiving the
+// records of all peers. It is safe to call while the manager is running.
+func (mgr *Manager) RemoveProcessor(pro adaptor.Processor) {
+	mgr.proMutex.Lock()
+	defer mgr.proMutex.Unlock()
+
+	current := mgr.Processors()
+	list := make([]adaptor.Processor, 0, len(current))
+	for _, item := range current {
+		if item != pro {
+			list = append(list, item)
+		}
+	}
+
+	mgr.pro.Store(list)
+}
+
+// SetProcessors replaces all processors receiving the records of all peers in
+// one step, like on a configuration reload. Peers handing out a record see
+// either the complete old or the complete new list, never a mix of both. The
+// given slice is copied, so the caller may reuse it.
+func (mgr *Manager) SetProcessors(pros []adaptor.Processor) {
+	mgr.proMutex.Lock()
+	defer mgr.proMutex.Unlock()
+
+	list := make([]adaptor.Processor, len(pros))
+	copy(list, pros)
+	mgr.pro.Store(list)
 }
 
-func (mgr *Manager) Outgoing(p adaptor.Peer) {
-	mgr.log.Debug("[MGR] Outgoing: %v", p)
+// Processors returns the current list of processors receiving the records of
+// all peers. The returned slice must not be modified.
+func (mgr *Manager) Processors() []adaptor.Processor {
+	return mgr.pro.Load().([]adaptor.Processor)
+}
+
+// GetPeers returns a snapshot of the traffic statistics of all peers that are
+// currently managed.
+func (mgr *Manager) GetPeers() []adaptor.PeerStats {
+	peers := make([]adaptor.PeerStats, 0, mgr.peerIndex.Count())
+	for s := range mgr.peerIndex.Iter() {
+		p := s.(adaptor.Peer)
+		peers = append(peers, p.Stats())
+	}
 
-	mgr.outgoingQ <- p
+	return peers
 }
 
-func (mgr *Manager) Incoming(p adaptor.Peer) {
-	mgr.log.Debug("[MGR] Incoming: %v", p)
+// ThroughputStats returns the aggregate traffic received from all peers that
+// are currently managed.
+func (mgr *Manager) ThroughputStats() Throughput {
+	tp := Throughput{}
+	for _, stats := range mgr.GetPeers() {
+		tp.Peers++
+		tp.Routines += stats.Routines
+		tp.BytesRead += stats.BytesRead
+		tp.MessagesRead += stats.MessagesRead
+		tp.ByteRate += stats.ByteRate
+		tp.MessageRate += stats.MessageRate
+		tp.Latency.Merge(stats.Latency)
+	}
+
+	tp.Budget = mgr.budget
+	tp.Throttled = atomic.LoadUint32(&mgr.throttled) == 1
+	tp.Backpressure = atomic.LoadUint32(&mgr.pressured) == 1
 
-	mgr.incomingQ <- p
+	return tp
 }
 
 // Connected signals to the manager that we have successfully established a
@@ -211,6 +856,21 @@ func (mgr *Manager) Ready(p adaptor.Peer) {
 	mgr.readyQ <- p
 }
 
+// Harvested signals to the manager that a peer sent us a full batch of
+// addresses.
+func (mgr *Manager) Harvested(p adaptor.Peer) {
+	mgr.log.Debug("[MGR] Harvested: %v", p)
+
+	if mgr.pollCooldown == 0 {
+		return
+	}
+
+	mgr.harvestMutex.Lock()
+	defer mgr.harvestMutex.Unlock()
+
+	mgr.harvested[p.String()] = mgr.clock()
+}
+
 // Stopped signals to the manager that the connection to this peer has been
 // shut down.
 func (mgr *Manager) Stopped(p adaptor.Peer) {
@@ -219,9 +879,26 @@ func (mgr *Manager) Stopped(p adaptor.Peer) {
 	mgr.stoppedQ <- p
 }
 
-func (mgr Manager) goTicker() {
+func (mgr *Manager) goTicker() {
 	defer mgr.wg.Done()
 
+	pressureT := time.NewTicker(pressureInterval)
+	defer pressureT.Stop()
+
+	var topologyC <-chan time.Time
+	if mgr.topologyRate > 0 {
+		topologyT := time.NewTicker(mgr.topologyRate)
+		defer topologyT.Stop()
+		topologyC = topologyT.C
+	}
+
+	var idleC <-chan time.Time
+	if mgr.idleOutbound > 0 || mgr.idleInbound > 0 {
+		idleT := time.NewTicker(idleInterval)
+		defer idleT.Stop()
+		idleC = idleT.C
+	}
+
 TickerLoop:
 	for {
 		select {
@@ -232,12 +909,30 @@ TickerLoop:
 
 		// print manager information to the log
 		case <-mgr.tickerT.C:
-			mgr.log.Info("[MGR] %v total peers managed", mgr.peerIndex.Count())
+			tp := mgr.ThroughputStats()
+			mgr.log.Info("[MGR] %v total peers managed, %v routines "+
+				"(%.0f B/s, %.1f msg/s)", tp.Peers, tp.Routines, tp.ByteRate,
+				tp.MessageRate)
+			mgr.checkBudget(tp.ByteRate)
+
+		// check whether the processors keep up with the records
+		case <-pressureT.C:
+			mgr.checkPressure()
+
+		// emit a snapshot of the peers we are connected to
+		case <-topologyC:
+			mgr.snapshotTopology()
+
+		// drop peers that have been silent for too long
+		case <-idleC:
+			mgr.reapIdle()
 		}
 	}
 }
 
 func (mgr *Manager) goEvents() {
+	defer mgr.wg.Done()
+
 PeerLoop:
 	for {
 		select {
@@ -248,6 +943,7 @@ PeerLoop:
 
 		// manage peers that have successfully connected
 		case p := <-mgr.connectedQ:
+			mgr.dialed(p)
 			if !mgr.peerIndex.Has(p) {
 				mgr.log.Warning("[MGR] %v connected unknown", p)
 				p.Stop()
@@ -255,9 +951,11 @@ PeerLoop:
 			}
 
 			mgr.log.Debug("[MGR] %v connected", p)
+			mgr.addSessionPeer(p)
 			mgr.repo.Connected(p.Addr())
 			p.Start()
 			p.Greet()
+			mgr.limitLifetime(p)
 
 		// manage peers that have completed the handshake
 		case p := <-mgr.readyQ:
@@ -269,10 +967,21 @@ PeerLoop:
 
 			mgr.log.Debug("[MGR] %v ready", p)
 			mgr.repo.Succeeded(p.Addr())
+			if mgr.isThrottled() {
+				mgr.log.Debug("[MGR] %v not polled, budget exceeded", p)
+				continue
+			}
+
+			if mgr.recentlyHarvested(p) {
+				mgr.log.Debug("[MGR] %v not polled, harvested recently", p)
+				continue
+			}
+
 			p.Poll()
 
 		// manage peers that have dropped the connection
 		case p := <-mgr.stoppedQ:
+			mgr.dialed(p)
 			if !mgr.peerIndex.Has(p) {
 				mgr.log.Warning("[MGR] %v done unknown", p)
 				continue
@@ -280,6 +989,10 @@ PeerLoop:
 
 			mgr.log.Debug("[MGR] %v: done", p)
 			mgr.peerIndex.Remove(p)
+			mgr.unlimitLifetime(p)
+			mgr.releaseSlot()
+			mgr.addStats(p)
+			mgr.redial(p)
 		}
 	}
 
@@ -296,6 +1009,9 @@ PeerLoop:
 
 		case p := <-mgr.stoppedQ:
 			mgr.peerIndex.Remove(p)
+			mgr.unlimitLifetime(p)
+			mgr.releaseSlot()
+			mgr.addStats(p)
 			break
 		}
 	}
@@ -306,6 +1022,27 @@ PeerLoop:
 func (mgr *Manager) goPeers() {
 	defer mgr.wg.Done()
 
+	var ageC <-chan time.Time
+	if mgr.ageT != nil {
+		ageC = mgr.ageT.C
+	}
+
+	// without outgoing connections, we never ask for addresses
+	var connC <-chan time.Time
+	if mgr.connT != nil {
+		connC = mgr.connT.C
+	}
+
+	// trusted peers are dialed right away and checked at every ticker
+	// interval, without waiting for the repository
+	var trustedC <-chan time.Time
+	if mgr.connectOut && len(mgr.trusted) > 0 {
+		trustedT := time.NewTicker(mgr.tickerInterval)
+		defer trustedT.Stop()
+		trustedC = trustedT.C
+		mgr.connectTrusted()
+	}
+
 PeerLoop:
 	for {
 		select {
@@ -314,11 +1051,665 @@ PeerLoop:
 				break PeerLoop
 			}
 
-		// manage peers that have successfully connected
-		case _ = <-mgr.incomingQ:
+		// ask the repository for a new address if we have free slots, but
+		// only if the previous ones have been processed already
+		case <-connC:
+			if int(atomic.LoadInt32(&mgr.slots)) >= mgr.connLimit {
+				continue
+			}
 
-		case _ = <-mgr.outgoingQ:
+			if len(mgr.addrQ) > 0 {
+				continue
+			}
 
+			if mgr.isThrottled() {
+				continue
+			}
+
+			if mgr.dialsFull() {
+				continue
+			}
+
+			mgr.repo.Retrieve(mgr.addrQ)
+
+		// create a new outgoing peer for each address we receive
+		case addr := <-mgr.addrQ:
+			mgr.addPeer(addr)
+
+		// reconnect the trusted peers we lost
+		case <-trustedC:
+			mgr.connectTrusted()
+
+		// cycle out the oldest peer if it has expired
+		case <-ageC:
+			mgr.expirePeer()
+			mgr.ageT.Reset(util.Jitter(mgr.ageInterval(), mgr.jitter))
+		}
+	}
+}
+
+// reserveSlot atomically takes one of the connection slots before we dial a
+// peer, so that attempts in flight count against the connection limit. It
+// returns false if all slots are taken. Trusted peers always get a slot, even
+// if that takes us over the limit.
+func (mgr *Manager) reserveSlot(trusted bool) bool {
+	if trusted {
+		atomic.AddInt32(&mgr.slots, 1)
+		return true
+	}
+
+	for {
+		slots := atomic.LoadInt32(&mgr.slots)
+		if int(slots) >= mgr.connLimit {
+			return false
+		}
+
+		if atomic.CompareAndSwapInt32(&mgr.slots, slots, slots+1) {
+			return true
+		}
+	}
+}
+
+// releaseSlot gives back a connection slot once a peer is done or could not be
+// created.
+func (mgr *Manager) releaseSlot() {
+	atomic.AddInt32(&mgr.slots, -1)
+}
+
+// checkBudget compares the given ingress rate to the bandwidth budget and
+// starts or stops throttling accordingly. To avoid flapping around the limit,
+// throttling only stops once the rate is below nine tenths of the budget.
+func (mgr *Manager) checkBudget(rate float64) {
+	if mgr.budget == 0 {
+		return
+	}
+
+	budget := float64(mgr.budget)
+	switch {
+	case rate > budget && atomic.LoadUint32(&mgr.throttled) == 0:
+		atomic.StoreUint32(&mgr.throttled, 1)
+		mgr.log.Warning("[MGR] Bandwidth budget exceeded (%.0f B/s of %v "+
+			"B/s), throttling", rate, mgr.budget)
+
+	case rate < budget*0.9 && atomic.LoadUint32(&mgr.throttled) == 1:
+		atomic.StoreUint32(&mgr.throttled, 0)
+		mgr.log.Info("[MGR] Bandwidth usage back to %.0f B/s, no longer "+
+			"throttling", rate)
+	}
+}
+
+// checkPressure asks the processors whether they can keep up with the records.
+// If they report backpressure for several checks in a row, we back off like
+// for the bandwidth budget, so that we ingest less until they have caught up.
+func (mgr *Manager) checkPressure() {
+	pressure := false
+	for _, pro := range mgr.Processors() {
+		if pro.Backpressure() {
+			pressure = true
+			break
+		}
+	}
+
+	pressured := atomic.LoadUint32(&mgr.pressured) == 1
+	if pressure == pressured {
+		mgr.pressures = 0
+		return
+	}
+
+	mgr.pressures++
+	if mgr.pressures < pressureSamples {
+		return
+	}
+
+	mgr.pressures = 0
+	if pressure {
+		atomic.StoreUint32(&mgr.pressured, 1)
+		mgr.log.Warning("[MGR] Processors can't keep up, backing off")
+		return
+	}
+
+	atomic.StoreUint32(&mgr.pressured, 0)
+	mgr.log.Info("[MGR] Processors caught up, no longer backing off")
+}
+
+// recentlyHarvested returns whether the peer sent us a full batch of addresses
+// within the poll cooldown. Expired entries are removed on the way.
+func (mgr *Manager) recentlyHarvested(p adaptor.Peer) bool {
+	mgr.harvestMutex.Lock()
+	defer mgr.harvestMutex.Unlock()
+
+	now := mgr.clock()
+	for addr, stamp := range mgr.harvested {
+		if now.Sub(stamp) >= mgr.pollCooldown {
+			delete(mgr.harvested, addr)
+		}
+	}
+
+	_, ok := mgr.harvested[p.String()]
+	return ok
+}
+
+// isThrottled returns whether the manager is backing off, either because the
+// bandwidth budget was exceeded or because the processors can't keep up.
+func (mgr *Manager) isThrottled() bool {
+	return atomic.LoadUint32(&mgr.throttled) == 1 ||
+		atomic.LoadUint32(&mgr.pressured) == 1
+}
+
+// limitLifetime schedules the disconnection of the given peer after a random
+// lifetime, if connection lifetimes are enabled.
+func (mgr *Manager) limitLifetime(p adaptor.Peer) {
+	if mgr.lifetimeMax == 0 || mgr.isTrusted(p.Addr().IP) {
+		return
+	}
+
+	lifetime := mgr.lifetimeMin
+	spread := mgr.lifetimeMax - mgr.lifetimeMin
+	if spread > 0 {
+		lifetime += time.Duration(rand.Int63n(int64(spread) + 1))
+	}
+
+	mgr.log.Debug("[MGR] %v lifetime set to %v", p, lifetime)
+	timer := time.AfterFunc(lifetime, func() {
+		mgr.log.Info("[MGR] %v reached lifetime of %v", p, lifetime)
+		p.Drop(records.ReasonLifetime)
+	})
+
+	mgr.lifetimeMutex.Lock()
+	defer mgr.lifetimeMutex.Unlock()
+
+	mgr.lifetimes[p.String()] = timer
+}
+
+// unlimitLifetime stops the lifetime timer of a peer that is removed, so that
+// it does not keep the peer alive until it fires.
+func (mgr *Manager) unlimitLifetime(p adaptor.Peer) {
+	mgr.lifetimeMutex.Lock()
+	defer mgr.lifetimeMutex.Unlock()
+
+	timer, ok := mgr.lifetimes[p.String()]
+	if !ok {
+		return
+	}
+
+	timer.Stop()
+	delete(mgr.lifetimes, p.String())
+}
+
+// reapIdle stops the peers that sent nothing useful for longer than their
+// idle timeout since the handshake or their last useful message.
+func (mgr *Manager) reapIdle() {
+	now := mgr.clock()
+	for s := range mgr.peerIndex.Iter() {
+		p := s.(adaptor.Peer)
+		if mgr.isTrusted(p.Addr().IP) {
+			continue
+		}
+
+		stats := p.Stats()
+		if stats.LastUseful.IsZero() {
+			continue
+		}
+
+		timeout := mgr.idleOutbound
+		if stats.Inbound {
+			timeout = mgr.idleInbound
+		}
+
+		if timeout == 0 || now.Sub(stats.LastUseful) < timeout {
+			continue
+		}
+
+		mgr.log.Info("[MGR] %v idle for %v (%v useful messages)", p,
+			now.Sub(stats.LastUseful), stats.Useful)
+		p.Drop(records.ReasonIdle)
+	}
+}
+
+// addSessionPeer counts the given peer towards the unique peers we connected
+// to during this session.
+func (mgr *Manager) addSessionPeer(p adaptor.Peer) {
+	mgr.sesMutex.Lock()
+	defer mgr.sesMutex.Unlock()
+
+	mgr.sesPeers[p.String()] = struct{}{}
+}
+
+// addStats adds the traffic of a stopped peer to the session totals and to the
+// history of its address.
+func (mgr *Manager) addStats(p adaptor.Peer) {
+	stats := p.Stats()
+	mgr.addSessionStats(stats)
+	mgr.addHistory(p, stats)
+}
+
+// addSessionStats adds the traffic of a stopped peer to the session totals.
+func (mgr *Manager) addSessionStats(stats adaptor.PeerStats) {
+	mgr.sesMutex.Lock()
+	defer mgr.sesMutex.Unlock()
+
+	mgr.sesBytes += stats.BytesRead
+	if records.Banned(stats.Reason) {
+		mgr.sesBans++
+	}
+
+	for cmd, count := range stats.Commands {
+		mgr.sesCommands[cmd] += count
+	}
+}
+
+// summarize logs a summary of the session and sends it to the processors as a
+// summary record, so that it ends up in the output as well.
+func (mgr *Manager) summarize() {
+	mgr.sesMutex.Lock()
+	defer mgr.sesMutex.Unlock()
+
+	record := records.NewSummaryRecord(mgr.sesStart, mgr.clock(),
+		len(mgr.sesPeers), mgr.sesBytes, mgr.written(), mgr.sesBans,
+		mgr.sesCommands)
+
+	mgr.log.Notice("[MGR] Session summary: %v, %v unique peers, %v bytes "+
+		"received, %v bytes written, %v bans, %v", record.Duration(),
+		record.Peers(), record.Received(), record.Written(), record.Bans(),
+		record.Commands())
+
+	for _, pro := range mgr.Processors() {
+		pro.Process(record)
+	}
+}
+
+// written returns the number of bytes written by the processors we feed,
+// directly or through other processors. Processors fed on several paths are
+// counted once.
+func (mgr *Manager) written() uint64 {
+	var total uint64
+	seen := make(map[adaptor.Processor]bool)
+	pending := mgr.Processors()
+	for len(pending) > 0 {
+		pro := pending[0]
+		pending = pending[1:]
+		if seen[pro] {
+			continue
+		}
+
+		seen[pro] = true
+		total += pro.Written()
+		pending = append(pending, pro.Next()...)
+	}
+
+	return total
+}
+
+// snapshotTopology sends a snapshot of the connected peers to the processors.
+// Peers that are still connecting are left out, while peers that did not send
+// their version message yet count as unknown software.
+func (mgr *Manager) snapshotTopology() {
+	inbound := 0
+	outbound := 0
+	groups := make(map[string]uint64)
+	software := make(map[string]uint64)
+	for s := range mgr.peerIndex.Iter() {
+		p := s.(adaptor.Peer)
+		stats := p.Stats()
+		if stats.Connected.IsZero() {
+			continue
+		}
+
+		if stats.Inbound {
+			inbound++
+		} else {
+			outbound++
+		}
+
+		groups[mgr.networkGroup(p.Addr().IP)]++
+		software[util.SoftwareFamily(stats.UserAgent)]++
+	}
+
+	record := records.NewTopologySnapshotRecord(mgr.clock(), inbound,
+		outbound, groups, software)
+
+	mgr.log.Info("[MGR] Topology: %v peers (%v inbound, %v outbound) in %v "+
+		"network groups", record.Peers(), inbound, outbound, len(groups))
+
+	for _, pro := range mgr.Processors() {
+		pro.Process(record)
+	}
+}
+
+// ageInterval returns the interval at which we check for expired peers, so
+// that all peers are cycled about once per maximum age.
+func (mgr *Manager) ageInterval() time.Duration {
+	interval := mgr.peerMaxAge
+	if mgr.connLimit > 0 {
+		interval /= time.Duration(mgr.connLimit)
+	}
+
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	return interval
+}
+
+// expirePeer disconnects the oldest outgoing peer if it is older than the
+// maximum age. Its slot is filled again by the regular connection attempts.
+func (mgr *Manager) expirePeer() {
+	now := mgr.clock()
+
+	var oldest adaptor.Peer
+	var connected time.Time
+	for s := range mgr.peerIndex.Iter() {
+		p := s.(adaptor.Peer)
+		if mgr.isTrusted(p.Addr().IP) {
+			continue
+		}
+
+		stats := p.Stats()
+		if stats.Connected.IsZero() || stats.Inbound {
+			continue
+		}
+
+		if oldest == nil || stats.Connected.Before(connected) {
+			oldest = p
+			connected = stats.Connected
+		}
+	}
+
+	if oldest == nil || now.Sub(connected) < mgr.peerMaxAge {
+		return
+	}
+
+	mgr.log.Info("[MGR] %v expired after %v", oldest, now.Sub(connected))
+	oldest.Drop(records.ReasonExpired)
+}
+
+// addPeer creates a new outgoing peer for the given address and launches the
+// connection attempt.
+func (mgr *Manager) addPeer(addr *net.TCPAddr) {
+	if mgr.peerIndex.HasKey(addr.String()) {
+		mgr.log.Debug("[MGR] %v already connected", addr)
+		return
+	}
+
+	trusted := mgr.isTrusted(addr.IP)
+	if !mgr.reserveSlot(trusted) {
+		mgr.log.Debug("[MGR] %v rejected, connection limit reached", addr)
+		return
+	}
+
+	p, err := mgr.newPeer(trusted, peer.SetAddress(addr))
+	if err != nil {
+		mgr.log.Warning("[MGR] %v peer creation failed (%v)", addr, err)
+		mgr.releaseSlot()
+		return
+	}
+
+	if !mgr.admit(p) {
+		mgr.log.Debug("[MGR] %v rejected, network group full", addr)
+		mgr.releaseSlot()
+		return
+	}
+
+	mgr.repo.Attempted(addr)
+	mgr.dialMutex.Lock()
+	mgr.dialing[p.String()] = struct{}{}
+	mgr.dialMutex.Unlock()
+	p.Connect()
+}
+
+// dialed removes a peer from the dials in flight once it connected or
+// stopped.
+func (mgr *Manager) dialed(p adaptor.Peer) {
+	mgr.dialMutex.Lock()
+	defer mgr.dialMutex.Unlock()
+
+	delete(mgr.dialing, p.String())
+}
+
+// dialsFull returns whether the number of dials in flight reached the dial
+// concurrency.
+func (mgr *Manager) dialsFull() bool {
+	if mgr.dialLimit == 0 {
+		return false
+	}
+
+	mgr.dialMutex.Lock()
+	defer mgr.dialMutex.Unlock()
+
+	return len(mgr.dialing) >= mgr.dialLimit
+}
+
+// DropPeer disconnects the peer with the given address. The peer is stopped
+// like on any other disconnect, so it leaves the managed peers once its
+// handlers are done.
+func (mgr *Manager) DropPeer(addr *net.TCPAddr) error {
+	s, ok := mgr.peerIndex.Get(addr.String())
+	if !ok {
+		return errors.New("peer not managed")
+	}
+
+	mgr.log.Info("[MGR] %v dropped on request", addr)
+	s.(adaptor.Peer).Drop(records.ReasonDropped)
+
+	return nil
+}
+
+// ReconnectPeer dials the given address right away, without waiting for the
+// connection timer. If we are connected to it already, the peer is dropped
+// first and dialed again once it stopped. The usual limits still apply.
+func (mgr *Manager) ReconnectPeer(addr *net.TCPAddr) error {
+	if atomic.LoadUint32(&mgr.state) != stateRunning {
+		return errors.New("manager not running")
+	}
+
+	if !mgr.connectOut {
+		return errors.New("outgoing connections disabled")
+	}
+
+	s, ok := mgr.peerIndex.Get(addr.String())
+	if ok {
+		mgr.reconnectMutex.Lock()
+		mgr.reconnects[addr.String()] = addr
+		mgr.reconnectMutex.Unlock()
+
+		mgr.log.Info("[MGR] %v reconnecting on request", addr)
+		s.(adaptor.Peer).Drop(records.ReasonDropped)
+		return nil
+	}
+
+	mgr.log.Info("[MGR] %v connecting on request", addr)
+
+	select {
+	case mgr.addrQ <- addr:
+		return nil
+
+	case <-mgr.sig:
+		return errors.New("manager stopped")
+	}
+}
+
+// redial dials a stopped peer again if a reconnect was requested for it. The
+// address is queued without blocking, as we are called from the event loop.
+func (mgr *Manager) redial(p adaptor.Peer) {
+	mgr.reconnectMutex.Lock()
+	addr, ok := mgr.reconnects[p.String()]
+	delete(mgr.reconnects, p.String())
+	mgr.reconnectMutex.Unlock()
+
+	if !ok {
+		return
+	}
+
+	select {
+	case mgr.addrQ <- addr:
+
+	default:
+		mgr.log.Warning("[MGR] %v not reconnected, address queue full",
+			addr)
+	}
+}
+
+// AdoptConn hands an established connection to the manager, which wraps it in
+// a peer and manages it like the ones it connected itself. This allows using
+// connections made elsewhere, like over a custom transport. The connection
+// does not need to be TCP, but its addresses have to be in host:port format.
+// For outbound connections, we send our version message first, while for
+// inbound connections we wait for the version message of the peer.
+func (mgr *Manager) AdoptConn(conn net.Conn, outbound bool) error {
+	if atomic.LoadUint32(&mgr.state) != stateRunning {
+		return errors.New("manager not running")
+	}
+
+	trusted := false
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err == nil {
+		trusted = mgr.isTrusted(net.ParseIP(host))
+	}
+
+	if !mgr.reserveSlot(trusted) {
+		return errors.New("connection limit reached")
+	}
+
+	p, err := mgr.newPeer(trusted, peer.SetConnection(conn),
+		peer.SetInbound(!outbound))
+	if err != nil {
+		mgr.releaseSlot()
+		return err
+	}
+
+	if mgr.peerIndex.Has(p) {
+		mgr.releaseSlot()
+		return errors.New("peer already managed")
+	}
+
+	if !mgr.admit(p) {
+		mgr.releaseSlot()
+		return errors.New("network group full")
+	}
+
+	mgr.log.Debug("[MGR] %v adopted", p)
+
+	if outbound {
+		mgr.Connected(p)
+		return nil
+	}
+
+	mgr.addSessionPeer(p)
+	p.Start()
+
+	return nil
+}
+
+// networkGroup returns the network group of the given IP, either the one of
+// Bitcoin Core or the one given by the configured prefix length.
+func (mgr *Manager) networkGroup(ip net.IP) string {
+	if mgr.groupPrefix == 0 {
+		return util.GroupName(ip)
+	}
+
+	return util.NetworkGroup(ip, mgr.groupPrefix)
+}
+
+// admit adds the peer to the managed peers, unless its network group already
+// has the maximum number of peers. The check and the insertion happen under
+// one lock, so that concurrent admissions can not exceed the limit. Trusted
+// peers are always admitted.
+func (mgr *Manager) admit(p adaptor.Peer) bool {
+	mgr.groupMutex.Lock()
+	defer mgr.groupMutex.Unlock()
+
+	if mgr.groupLimit > 0 && !mgr.isTrusted(p.Addr().IP) {
+		group := mgr.networkGroup(p.Addr().IP)
+		count := 0
+		for s := range mgr.peerIndex.Iter() {
+			other := s.(adaptor.Peer)
+			if mgr.networkGroup(other.Addr().IP) == group {
+				count++
+			}
+		}
+
+		if count >= mgr.groupLimit {
+			return false
 		}
 	}
+
+	mgr.peerIndex.Insert(p)
+
+	return true
+}
+
+// resolveTrusted resolves the addresses of the trusted peers. Addresses that
+// can not be resolved are skipped with a warning.
+func (mgr *Manager) resolveTrusted() {
+	for _, address := range mgr.trustedList {
+		addr, err := net.ResolveTCPAddr("tcp", address)
+		if err != nil {
+			mgr.log.Warning("[MGR] Trusted peer %v not resolved (%v)",
+				address, err)
+			continue
+		}
+
+		mgr.trusted = append(mgr.trusted, addr)
+		mgr.trustedIPs[addr.IP.String()] = struct{}{}
+	}
+}
+
+// isTrusted returns whether the given IP belongs to a trusted peer.
+func (mgr *Manager) isTrusted(ip net.IP) bool {
+	_, ok := mgr.trustedIPs[ip.String()]
+	return ok
+}
+
+// connectTrusted dials the trusted peers we are not connected to.
+func (mgr *Manager) connectTrusted() {
+	for _, addr := range mgr.trusted {
+		if mgr.peerIndex.HasKey(addr.String()) {
+			continue
+		}
+
+		mgr.log.Debug("[MGR] %v trusted, connecting", addr)
+		mgr.addPeer(addr)
+	}
+}
+
+// newPeer creates a new peer with the settings of the manager and the given
+// options. Unless the peer is trusted, it fails once the peers run as many go
+// routines as the routine limit allows.
+func (mgr *Manager) newPeer(trusted bool,
+	options ...func(*peer.Peer)) (*peer.Peer, error) {
+	if !trusted && mgr.routineLimit > 0 &&
+		mgr.ThroughputStats().Routines >= mgr.routineLimit {
+		return nil, errors.New("routine limit reached")
+	}
+
+	log := mgr.peerLog
+	if log == nil {
+		log = mgr.log
+	}
+
+	options = append([]func(*peer.Peer){
+		peer.SetLog(log),
+		peer.SetManager(mgr),
+		peer.SetRepository(mgr.repo),
+		peer.SetTracker(mgr.tkr),
+		peer.SetNetwork(mgr.network),
+		peer.SetVersion(mgr.version),
+		peer.SetNonce(mgr.nonce),
+		peer.SetDialer(mgr.dialer),
+		peer.SetClock(mgr.clock),
+		peer.SetPassive(mgr.passive),
+		peer.SetRelay(mgr.relay),
+		peer.SetRecordRaw(mgr.raw),
+		peer.SetAddrSources(mgr.learnAddr, mgr.learnVersion, mgr.learnInbound),
+		peer.SetServiceChecks(mgr.checkServices),
+		peer.SetMinProtocolVersion(mgr.minVersion),
+		peer.SetVersionTimeout(mgr.versionWait),
+		peer.SetHandshakeTimeout(mgr.handshakeWait),
+		peer.SetAddrMaxAge(mgr.addrMaxAge),
+		peer.SetAddrLimit(mgr.addrLimit),
+		peer.SetMessageCap(mgr.msgCap),
+		peer.SetTCPKeepAlive(mgr.keepAlive),
+	}, options...)
+
+	return peer.New(options...)
 }
@@ -0,0 +1,1379 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package manager
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/CIRCL/pbtc/adaptor"
+	"github.com/CIRCL/pbtc/pbtctest"
+	"github.com/CIRCL/pbtc/peer"
+	"github.com/CIRCL/pbtc/records"
+	"github.com/CIRCL/pbtc/tracker"
+)
+
+func newTestManager(t *testing.T, options ...func(*Manager)) *Manager {
+	mgr, err := New(options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mgr.SetLog(pbtctest.Log{})
+
+	return mgr
+}
+
+// addTestPeer puts a mock peer with the given statistics into the index of
+// the manager.
+func addTestPeer(mgr *Manager, ip string,
+	stats adaptor.PeerStats) *pbtctest.Peer {
+	p := pbtctest.NewPeer(&net.TCPAddr{IP: net.ParseIP(ip), Port: 8333})
+	p.SetStats(stats)
+	mgr.peerIndex.Insert(p)
+
+	return p
+}
+
+func TestExpirePeer(t *testing.T) {
+	mgr := newTestManager(t, SetPeerMaxAge(time.Hour))
+	now := time.Now()
+
+	inbound := addTestPeer(mgr, "192.0.2.1", adaptor.PeerStats{
+		Connected: now.Add(-3 * time.Hour),
+		Inbound:   true,
+	})
+	older := addTestPeer(mgr, "192.0.2.2", adaptor.PeerStats{
+		Connected: now.Add(-2 * time.Hour),
+	})
+	younger := addTestPeer(mgr, "192.0.2.3", adaptor.PeerStats{
+		Connected: now.Add(-90 * time.Minute),
+	})
+
+	mgr.expirePeer()
+
+	if inbound.Calls("Drop") != 0 || younger.Calls("Drop") != 0 {
+		t.Error("expired peer other than the oldest outgoing one")
+	}
+
+	if older.Calls("Drop") != 1 || older.Reason() != records.ReasonExpired {
+		t.Errorf("oldest outgoing peer not expired (reason %q)",
+			older.Reason())
+	}
+}
+
+func TestExpirePeerClock(t *testing.T) {
+	now := time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+	mgr := newTestManager(t, SetPeerMaxAge(time.Hour),
+		SetClock(func() time.Time { return now }))
+
+	// measured against the wall clock this peer is years old, but it only
+	// connected half an hour ago according to the manager
+	p := addTestPeer(mgr, "192.0.2.1", adaptor.PeerStats{
+		Connected: now.Add(-30 * time.Minute),
+	})
+
+	mgr.expirePeer()
+	if p.Calls("Drop") != 0 {
+		t.Fatal("peer expired before its maximum age on the manager clock")
+	}
+
+	now = now.Add(time.Hour)
+	mgr.expirePeer()
+	if p.Calls("Drop") != 1 || p.Reason() != records.ReasonExpired {
+		t.Errorf("peer not expired after its maximum age (reason %q)",
+			p.Reason())
+	}
+}
+
+func TestAgeInterval(t *testing.T) {
+	tests := []struct {
+		age      time.Duration
+		limit    int
+		interval time.Duration
+	}{
+		{time.Hour, 0, time.Hour},
+		{time.Hour, 60, time.Minute},
+		{time.Minute, 120, time.Second},
+	}
+
+	// peers are cycled one at a time, spread over the maximum age
+	for _, test := range tests {
+		mgr := newTestManager(t, SetPeerMaxAge(test.age),
+			SetConnectionLimit(test.limit))
+
+		interval := mgr.ageInterval()
+		if interval != test.interval {
+			t.Errorf("interval %v for %v and %v peers", interval,
+				test.age, test.limit)
+		}
+	}
+}
+
+func TestReapIdle(t *testing.T) {
+	now := time.Now()
+	mgr := newTestManager(t, SetPeerIdleTimeout(time.Minute, time.Hour),
+		SetClock(func() time.Time { return now }))
+
+	idle := addTestPeer(mgr, "192.0.2.1", adaptor.PeerStats{
+		LastUseful: now.Add(-2 * time.Minute),
+	})
+	inbound := addTestPeer(mgr, "192.0.2.2", adaptor.PeerStats{
+		LastUseful: now.Add(-2 * time.Minute),
+		Inbound:    true,
+	})
+
+	mgr.reapIdle()
+
+	if idle.Calls("Drop") != 1 || idle.Reason() != records.ReasonIdle {
+		t.Errorf("idle peer not dropped (reason %q)", idle.Reason())
+	}
+
+	if inbound.Calls("Drop") != 0 {
+		t.Error("inbound peer dropped before its own timeout")
+	}
+}
+
+func TestDropPeer(t *testing.T) {
+	mgr := newTestManager(t)
+	p := addTestPeer(mgr, "192.0.2.1", adaptor.PeerStats{})
+
+	err := mgr.DropPeer(p.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Calls("Drop") != 1 || p.Reason() != records.ReasonDropped {
+		t.Errorf("peer not dropped (reason %q)", p.Reason())
+	}
+
+	err = mgr.DropPeer(&net.TCPAddr{IP: net.ParseIP("192.0.2.9"), Port: 1})
+	if err == nil {
+		t.Error("dropped peer that is not managed")
+	}
+}
+
+func TestAddPeer(t *testing.T) {
+	handshakes := make(chan *wire.MsgVersion, 1)
+	handler := func(conn net.Conn) {
+		node := pbtctest.NewNode(conn, wire.TestNet3)
+		version, err := node.Handshake()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		handshakes <- version
+		for {
+			_, err := node.Receive()
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	mgr := newTestManager(t, SetProtocolMagic(wire.TestNet3),
+		SetConnectOut(false), SetDialer(pbtctest.NewDialer(handler)))
+
+	repo := pbtctest.NewRepository()
+	mgr.SetRepository(repo)
+
+	tkr, err := tracker.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr.SetTracker(tkr)
+
+	pro := pbtctest.NewProcessor()
+	mgr.AddProcessor(pro)
+
+	mgr.Start()
+
+	addr := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 18333}
+	mgr.addPeer(addr)
+
+	if repo.Calls("Attempted") != 1 || repo.Last("Attempted") != addr {
+		t.Errorf("attempted %v times", repo.Calls("Attempted"))
+	}
+
+	select {
+	case version := <-handshakes:
+		if version.Nonce != mgr.nonce {
+			t.Errorf("handshake with nonce %v", version.Nonce)
+		}
+
+	case <-time.After(time.Second):
+		t.Fatal("no handshake")
+	}
+
+	// the version and verack of the node are recorded
+	if !pro.Wait(2, time.Second) {
+		t.Errorf("received %v records", len(pro.Records()))
+	}
+
+	mgr.addPeer(addr)
+	if repo.Calls("Attempted") != 1 {
+		t.Error("connected twice to the same address")
+	}
+
+	mgr.Stop()
+
+	if mgr.peerIndex.Count() != 0 {
+		t.Errorf("%v peers left after stop", mgr.peerIndex.Count())
+	}
+}
+
+// handshake completes the handshake of a test node with an adopted peer. If
+// the peer is inbound, the node already sent its version, and the peer answers
+// with its own version and verack in any order.
+func handshake(node *pbtctest.Node, outbound bool) (*wire.MsgVersion,
+	error) {
+	if outbound {
+		return node.Handshake()
+	}
+
+	var version *wire.MsgVersion
+	verack := false
+	for version == nil || !verack {
+		msg, err := node.Receive()
+		if err != nil {
+			return nil, err
+		}
+
+		switch m := msg.(type) {
+		case *wire.MsgVersion:
+			version = m
+
+		case *wire.MsgVerAck:
+			verack = true
+		}
+	}
+
+	return version, node.Send(wire.NewMsgVerAck())
+}
+
+// TestAdoptConn runs the manager in listen-only mode, so that it only manages
+// the connections it is handed and never dials out on its own.
+func TestAdoptConn(t *testing.T) {
+	var dials int32
+	dial := func(conn net.Conn) {
+		atomic.AddInt32(&dials, 1)
+		conn.Close()
+	}
+
+	mgr := newTestManager(t, SetProtocolMagic(wire.TestNet3),
+		SetConnectOut(false), SetConnectionRate(time.Millisecond),
+		SetDialer(pbtctest.NewDialer(dial)))
+	repo := &retrievalRepository{Repository: pbtctest.NewRepository()}
+	mgr.SetRepository(repo)
+
+	tkr, err := tracker.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr.SetTracker(tkr)
+
+	pro := pbtctest.NewProcessor()
+	mgr.AddProcessor(pro)
+
+	mgr.Start()
+	defer mgr.Stop()
+
+	local := &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 18333}
+	for i, outbound := range []bool{true, false} {
+		remote := &net.TCPAddr{IP: net.IPv4(192, 0, 2, byte(10+i)),
+			Port: 18333}
+		near, far := pbtctest.Pipe(local, remote)
+		defer far.Close()
+
+		err := mgr.AdoptConn(near, outbound)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// adopted outbound peers greet the far end, inbound ones wait for it
+		node := pbtctest.NewNode(far, wire.TestNet3)
+		if !outbound {
+			me, _ := wire.NewNetAddress(remote, 0)
+			you, _ := wire.NewNetAddress(local, 0)
+			err = node.Send(wire.NewMsgVersion(me, you, node.Nonce, 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		version, err := handshake(node, outbound)
+		if err != nil {
+			t.Fatalf("outbound %v: %v", outbound, err)
+		}
+
+		if version.Nonce != mgr.nonce {
+			t.Errorf("outbound %v: handshake with nonce %v", outbound,
+				version.Nonce)
+		}
+
+		go func() {
+			for {
+				_, err := node.Receive()
+				if err != nil {
+					return
+				}
+			}
+		}()
+
+		if !pro.Wait(2*(i+1), time.Second) {
+			t.Errorf("outbound %v: received %v records", outbound,
+				len(pro.Records()))
+		}
+
+		err = mgr.AdoptConn(near, outbound)
+		if err == nil {
+			t.Errorf("outbound %v: adopted connection twice", outbound)
+		}
+	}
+
+	if mgr.peerIndex.Count() != 2 {
+		t.Errorf("%v peers managed", mgr.peerIndex.Count())
+	}
+
+	if atomic.LoadInt32(&repo.retrievals) != 0 ||
+		atomic.LoadInt32(&dials) != 0 {
+		t.Errorf("asked for %v addresses and dialed %v in listen-only mode",
+			atomic.LoadInt32(&repo.retrievals), atomic.LoadInt32(&dials))
+	}
+
+	addr := &net.TCPAddr{IP: net.ParseIP("192.0.2.20"), Port: 18333}
+	err = mgr.ReconnectPeer(addr)
+	if err == nil {
+		t.Error("reconnected in listen-only mode")
+	}
+}
+
+func TestProcessorsRuntime(t *testing.T) {
+	pings := make(chan wire.Message)
+	handler := func(conn net.Conn) {
+		node := pbtctest.NewNode(conn, wire.TestNet3)
+		_, err := node.Handshake()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			for {
+				_, err := node.Receive()
+				if err != nil {
+					return
+				}
+			}
+		}()
+
+		for msg := range pings {
+			err := node.Send(msg)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	mgr := newTestManager(t, SetProtocolMagic(wire.TestNet3),
+		SetConnectOut(false), SetDialer(pbtctest.NewDialer(handler)))
+	mgr.SetRepository(pbtctest.NewRepository())
+
+	tkr, err := tracker.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr.SetTracker(tkr)
+
+	first := pbtctest.NewProcessor()
+	mgr.AddProcessor(first)
+
+	mgr.Start()
+	defer mgr.Stop()
+	defer close(pings)
+
+	mgr.addPeer(&net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 18333})
+
+	if !first.Wait(2, time.Second) {
+		t.Fatalf("received %v records", len(first.Records()))
+	}
+
+	// the running peer feeds a processor added after it connected
+	second := pbtctest.NewProcessor()
+	mgr.AddProcessor(second)
+
+	if len(mgr.Processors()) != 2 {
+		t.Errorf("%v processors listed", len(mgr.Processors()))
+	}
+
+	pings <- wire.NewMsgPing(1)
+	if !second.Wait(1, time.Second) {
+		t.Fatal("added processor received no records")
+	}
+
+	if !first.Wait(3, time.Second) {
+		t.Errorf("first processor received %v records",
+			len(first.Records()))
+	}
+
+	// and stops feeding one that was removed
+	mgr.RemoveProcessor(first)
+
+	if len(mgr.Processors()) != 1 || mgr.Processors()[0] != second {
+		t.Errorf("processors %v listed after removal", mgr.Processors())
+	}
+
+	pings <- wire.NewMsgPing(2)
+	if !second.Wait(2, time.Second) {
+		t.Fatalf("added processor received %v records",
+			len(second.Records()))
+	}
+
+	if len(first.Records()) != 3 {
+		t.Errorf("removed processor received %v records",
+			len(first.Records()))
+	}
+}
+
+// TestSetProcessors swaps complete processor sets while peers hand out
+// records, which must always see one of the sets in full.
+func TestSetProcessors(t *testing.T) {
+	mgr := newTestManager(t)
+
+	sets := [][]adaptor.Processor{
+		{pbtctest.NewProcessor(), pbtctest.NewProcessor()},
+		{pbtctest.NewProcessor(), pbtctest.NewProcessor(),
+			pbtctest.NewProcessor()},
+	}
+	mgr.SetProcessors(sets[0])
+
+	complete := func(pros []adaptor.Processor) bool {
+		for _, set := range sets {
+			if len(pros) != len(set) {
+				continue
+			}
+
+			same := true
+			for i := range set {
+				same = same && pros[i] == set[i]
+			}
+
+			if same {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	var partial uint32
+	done := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				if !complete(mgr.Processors()) {
+					atomic.AddUint32(&partial, 1)
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 10000; i++ {
+		mgr.SetProcessors(sets[i%2])
+	}
+	close(done)
+	wg.Wait()
+
+	if partial != 0 {
+		t.Errorf("peers saw %v partial processor sets", partial)
+	}
+
+	// the manager keeps its own copy of the set
+	set := []adaptor.Processor{pbtctest.NewProcessor()}
+	mgr.SetProcessors(set)
+	set[0] = nil
+	if mgr.Processors()[0] == nil {
+		t.Error("processor set changed with the caller's slice")
+	}
+}
+
+func TestRoutineLimit(t *testing.T) {
+	mgr := newTestManager(t, SetRoutineLimit(4))
+	mgr.SetRepository(pbtctest.NewRepository())
+	atomic.StoreUint32(&mgr.state, stateRunning)
+
+	busy := addTestPeer(mgr, "192.0.2.1", adaptor.PeerStats{Routines: 4})
+
+	// inbound connections, like the ones accepted by the server, are limited
+	near, far := pbtctest.Pipe(
+		&net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 8333},
+		&net.TCPAddr{IP: net.ParseIP("192.0.2.3"), Port: 50000})
+	defer near.Close()
+	defer far.Close()
+
+	err := mgr.AdoptConn(near, false)
+	if err == nil {
+		t.Error("adopted connection over the routine limit")
+	}
+
+	slots := atomic.LoadInt32(&mgr.slots)
+	if slots != 0 {
+		t.Errorf("%v slots taken after rejection", slots)
+	}
+
+	mgr.addPeer(&net.TCPAddr{IP: net.ParseIP("192.0.2.4"), Port: 8333})
+	if mgr.peerIndex.Count() != 1 {
+		t.Error("added peer over the routine limit")
+	}
+
+	busy.SetStats(adaptor.PeerStats{Routines: 3})
+
+	_, err = mgr.newPeer(false, peer.SetConnection(near))
+	if err != nil {
+		t.Errorf("peer below the routine limit not created (%v)", err)
+	}
+}
+
+func TestSlotLimit(t *testing.T) {
+	mgr := newTestManager(t, SetConnectionLimit(10))
+
+	// concurrent reservations never exceed the limit, not even briefly
+	var wg sync.WaitGroup
+	var reserved, peak int32
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if !mgr.reserveSlot(false) {
+					continue
+				}
+
+				count := atomic.AddInt32(&reserved, 1)
+				for {
+					max := atomic.LoadInt32(&peak)
+					if count <= max ||
+						atomic.CompareAndSwapInt32(&peak, max, count) {
+						break
+					}
+				}
+
+				atomic.AddInt32(&reserved, -1)
+				mgr.releaseSlot()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if peak > 10 {
+		t.Errorf("%v slots reserved at once", peak)
+	}
+
+	if mgr.slots != 0 {
+		t.Errorf("%v slots left after release", mgr.slots)
+	}
+
+	// without releases, exactly the limit is handed out
+	var granted int32
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if mgr.reserveSlot(false) {
+				atomic.AddInt32(&granted, 1)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if granted != 10 || mgr.slots != 10 {
+		t.Errorf("granted %v slots, %v taken", granted, mgr.slots)
+	}
+}
+
+// retrievalRepository counts how often the manager asks for addresses.
+type retrievalRepository struct {
+	*pbtctest.Repository
+
+	retrievals int32
+}
+
+func (repo *retrievalRepository) Retrieve(addrQ chan<- *net.TCPAddr) {
+	atomic.AddInt32(&repo.retrievals, 1)
+}
+
+// freshRepository hands out a new address on every retrieval.
+type freshRepository struct {
+	*pbtctest.Repository
+
+	handed uint32
+}
+
+func (repo *freshRepository) Retrieve(addrQ chan<- *net.TCPAddr) {
+	n := atomic.AddUint32(&repo.handed, 1)
+	addr := &net.TCPAddr{IP: net.IPv4(192, 0, 2, byte(n)), Port: 8333}
+	select {
+	case addrQ <- addr:
+	default:
+	}
+}
+
+func TestDialConcurrency(t *testing.T) {
+	var dials, current, max int32
+	release := make(chan struct{})
+	dial := func(addr *net.TCPAddr, timeout time.Duration) (net.Conn,
+		error) {
+		atomic.AddInt32(&dials, 1)
+		n := atomic.AddInt32(&current, 1)
+		defer atomic.AddInt32(&current, -1)
+		for {
+			old := atomic.LoadInt32(&max)
+			if n <= old || atomic.CompareAndSwapInt32(&max, old, n) {
+				break
+			}
+		}
+
+		<-release
+		return nil, fmt.Errorf("unreachable")
+	}
+
+	mgr := newTestManager(t, SetDialConcurrency(3),
+		SetConnectionRate(time.Millisecond),
+		SetDialer(peer.NewDialer(peer.SetDialFunc(dial))))
+	mgr.SetRepository(&freshRepository{Repository: pbtctest.NewRepository()})
+
+	tkr, err := tracker.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr.SetTracker(tkr)
+
+	mgr.Start()
+	defer mgr.Stop()
+
+	// slow dials pile up to the concurrency and no further
+	time.Sleep(100 * time.Millisecond)
+	if atomic.LoadInt32(&max) != 3 || atomic.LoadInt32(&dials) != 3 {
+		t.Errorf("%v dials, %v at once", atomic.LoadInt32(&dials),
+			atomic.LoadInt32(&max))
+	}
+
+	// once they fail, the next ones are dialed
+	close(release)
+	for i := 0; i < 1000 && atomic.LoadInt32(&dials) <= 3; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	if atomic.LoadInt32(&dials) <= 3 {
+		t.Error("no dials after the first ones failed")
+	}
+
+	if atomic.LoadInt32(&max) > 3 {
+		t.Errorf("%v dials at once", atomic.LoadInt32(&max))
+	}
+}
+
+func TestBandwidthBudget(t *testing.T) {
+	mgr := newTestManager(t, SetBandwidthBudget(1000),
+		SetConnectionRate(time.Millisecond))
+
+	repo := &retrievalRepository{Repository: pbtctest.NewRepository()}
+	mgr.SetRepository(repo)
+
+	tkr, err := tracker.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr.SetTracker(tkr)
+
+	busy := addTestPeer(mgr, "192.0.2.1", adaptor.PeerStats{ByteRate: 800})
+	addTestPeer(mgr, "192.0.2.2", adaptor.PeerStats{ByteRate: 400})
+
+	mgr.checkBudget(mgr.ThroughputStats().ByteRate)
+	if !mgr.ThroughputStats().Throttled {
+		t.Fatal("not throttled over the budget")
+	}
+
+	mgr.Start()
+	defer func() {
+		// the mock peers never report back when stopped
+		var peers []fmt.Stringer
+		for s := range mgr.peerIndex.Iter() {
+			peers = append(peers, s)
+		}
+
+		for _, s := range peers {
+			mgr.peerIndex.Remove(s)
+		}
+
+		mgr.Stop()
+	}()
+
+	// over the budget, we neither poll nor ask for new addresses
+	throttled := addTestPeer(mgr, "192.0.2.3", adaptor.PeerStats{})
+	mgr.Ready(throttled)
+
+	for i := 0; i < 100 && repo.Calls("Succeeded") < 1; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	if throttled.Calls("Poll") != 0 {
+		t.Error("polled peer over the budget")
+	}
+
+	if atomic.LoadInt32(&repo.retrievals) != 0 {
+		t.Error("asked for addresses over the budget")
+	}
+
+	// slightly under the budget, we keep backing off to avoid flapping
+	busy.SetStats(adaptor.PeerStats{ByteRate: 550})
+	mgr.checkBudget(mgr.ThroughputStats().ByteRate)
+	if !mgr.ThroughputStats().Throttled {
+		t.Error("stopped throttling right under the budget")
+	}
+
+	busy.SetStats(adaptor.PeerStats{ByteRate: 100})
+	mgr.checkBudget(mgr.ThroughputStats().ByteRate)
+	if mgr.ThroughputStats().Throttled {
+		t.Fatal("still throttled well under the budget")
+	}
+
+	polled := addTestPeer(mgr, "192.0.2.4", adaptor.PeerStats{})
+	mgr.Ready(polled)
+
+	for i := 0; i < 100 && polled.Calls("Poll") == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	if polled.Calls("Poll") != 1 {
+		t.Error("peer not polled under the budget")
+	}
+
+	for i := 0; i < 100 && atomic.LoadInt32(&repo.retrievals) == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	if atomic.LoadInt32(&repo.retrievals) == 0 {
+		t.Error("no addresses asked for under the budget")
+	}
+}
+
+// slowProcessor is a processor whose queue can be marked as saturated.
+type slowProcessor struct {
+	*pbtctest.Processor
+
+	pressure uint32
+}
+
+func (pro *slowProcessor) Backpressure() bool {
+	return atomic.LoadUint32(&pro.pressure) == 1
+}
+
+func TestBackpressure(t *testing.T) {
+	mgr := newTestManager(t, SetConnectionRate(time.Millisecond))
+
+	repo := &retrievalRepository{Repository: pbtctest.NewRepository()}
+	mgr.SetRepository(repo)
+
+	tkr, err := tracker.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr.SetTracker(tkr)
+
+	slow := &slowProcessor{Processor: pbtctest.NewProcessor()}
+	mgr.AddProcessor(pbtctest.NewProcessor())
+	mgr.AddProcessor(slow)
+
+	// short bursts of pressure are ignored
+	atomic.StoreUint32(&slow.pressure, 1)
+	for i := 0; i < pressureSamples-1; i++ {
+		mgr.checkPressure()
+	}
+
+	atomic.StoreUint32(&slow.pressure, 0)
+	mgr.checkPressure()
+	atomic.StoreUint32(&slow.pressure, 1)
+	for i := 0; i < pressureSamples-1; i++ {
+		mgr.checkPressure()
+	}
+
+	if mgr.ThroughputStats().Backpressure {
+		t.Fatal("backing off on a short burst")
+	}
+
+	// sustained pressure makes us back off
+	mgr.checkPressure()
+	if !mgr.ThroughputStats().Backpressure {
+		t.Fatal("not backing off under sustained pressure")
+	}
+
+	if mgr.ThroughputStats().Throttled {
+		t.Error("backpressure reported as bandwidth throttling")
+	}
+
+	mgr.Start()
+	defer func() {
+		// the mock peers never report back when stopped
+		var peers []fmt.Stringer
+		for s := range mgr.peerIndex.Iter() {
+			peers = append(peers, s)
+		}
+
+		for _, s := range peers {
+			mgr.peerIndex.Remove(s)
+		}
+
+		mgr.Stop()
+	}()
+
+	throttled := addTestPeer(mgr, "192.0.2.1", adaptor.PeerStats{})
+	mgr.Ready(throttled)
+
+	for i := 0; i < 100 && repo.Calls("Succeeded") < 1; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	if throttled.Calls("Poll") != 0 {
+		t.Error("polled peer under backpressure")
+	}
+
+	if atomic.LoadInt32(&repo.retrievals) != 0 {
+		t.Error("asked for addresses under backpressure")
+	}
+}
+
+func TestPollCooldown(t *testing.T) {
+	now := time.Unix(1000, 0)
+	mgr := newTestManager(t, SetPollCooldown(time.Hour),
+		SetClock(func() time.Time { return now }))
+
+	harvested := addTestPeer(mgr, "192.0.2.1", adaptor.PeerStats{})
+	other := addTestPeer(mgr, "192.0.2.2", adaptor.PeerStats{})
+
+	mgr.Harvested(harvested)
+	if !mgr.recentlyHarvested(harvested) {
+		t.Error("asking again right after a full batch")
+	}
+
+	if mgr.recentlyHarvested(other) {
+		t.Error("other peer in cooldown")
+	}
+
+	now = now.Add(59 * time.Minute)
+	if !mgr.recentlyHarvested(harvested) {
+		t.Error("asking again within the cooldown")
+	}
+
+	now = now.Add(time.Minute)
+	if mgr.recentlyHarvested(harvested) {
+		t.Error("still in cooldown after it expired")
+	}
+
+	if len(mgr.harvested) != 0 {
+		t.Errorf("%v expired harvests kept", len(mgr.harvested))
+	}
+
+	// without a cooldown, peers are always asked again
+	mgr = newTestManager(t)
+	mgr.Harvested(harvested)
+	if mgr.recentlyHarvested(harvested) {
+		t.Error("cooldown without it being configured")
+	}
+}
+
+func TestMaxPeersPerGroup(t *testing.T) {
+	mgr := newTestManager(t, SetMaxPeersPerGroup(2, 16))
+
+	tests := []struct {
+		ip       string
+		admitted bool
+	}{
+		{"8.8.1.1", true},
+		{"8.8.2.2", true},
+		{"8.8.3.3", false},
+		{"8.9.1.1", true},
+		{"2001:4860:1::1", true},
+		{"2001:4860:2::1", true},
+		{"2001:4860:3::1", false},
+	}
+
+	for _, test := range tests {
+		p := pbtctest.NewPeer(&net.TCPAddr{IP: net.ParseIP(test.ip),
+			Port: 8333})
+		if mgr.admit(p) != test.admitted {
+			t.Errorf("%v admitted: %v", test.ip, !test.admitted)
+		}
+	}
+
+	if mgr.peerIndex.Count() != 5 {
+		t.Errorf("%v peers managed", mgr.peerIndex.Count())
+	}
+
+	// once a peer of a full group is gone, another one can take its place
+	mgr.peerIndex.Remove(pbtctest.NewPeer(&net.TCPAddr{
+		IP: net.ParseIP("8.8.1.1"), Port: 8333}))
+
+	p := pbtctest.NewPeer(&net.TCPAddr{IP: net.ParseIP("8.8.3.3"),
+		Port: 8333})
+	if !mgr.admit(p) {
+		t.Error("peer not admitted after its group freed up")
+	}
+}
+
+func TestTopologySnapshot(t *testing.T) {
+	mgr := newTestManager(t, SetTopologyInterval(20*time.Millisecond))
+	mgr.SetRepository(pbtctest.NewRepository())
+
+	tkr, err := tracker.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr.SetTracker(tkr)
+
+	pro := pbtctest.NewProcessor()
+	mgr.AddProcessor(pro)
+
+	now := time.Now()
+	addTestPeer(mgr, "8.8.1.1", adaptor.PeerStats{Connected: now,
+		Inbound: true, UserAgent: "/Satoshi:0.11.0/"})
+	addTestPeer(mgr, "8.8.2.2", adaptor.PeerStats{Connected: now,
+		UserAgent: "/Satoshi:0.10.2/"})
+	addTestPeer(mgr, "9.9.9.9", adaptor.PeerStats{Connected: now,
+		UserAgent: "/btcd:0.12.0/"})
+
+	// peers that are still connecting are left out
+	addTestPeer(mgr, "9.9.8.8", adaptor.PeerStats{})
+
+	mgr.Start()
+	time.Sleep(110 * time.Millisecond)
+
+	// the mock peers never report back when stopped
+	var peers []fmt.Stringer
+	for s := range mgr.peerIndex.Iter() {
+		peers = append(peers, s)
+	}
+
+	for _, s := range peers {
+		mgr.peerIndex.Remove(s)
+	}
+
+	mgr.Stop()
+
+	var snapshots []*records.TopologySnapshotRecord
+	for _, record := range pro.Records() {
+		tr, ok := record.(*records.TopologySnapshotRecord)
+		if ok {
+			snapshots = append(snapshots, tr)
+		}
+	}
+
+	if len(snapshots) < 3 || len(snapshots) > 6 {
+		t.Fatalf("%v snapshots in 110ms at 20ms intervals", len(snapshots))
+	}
+
+	tr := snapshots[0]
+	if tr.Peers() != 3 || tr.Inbound() != 1 || tr.Outbound() != 2 {
+		t.Errorf("%v peers, %v inbound, %v outbound", tr.Peers(),
+			tr.Inbound(), tr.Outbound())
+	}
+
+	groups := tr.Groups()
+	if len(groups) != 2 || groups["ipv4:8.8"] != 2 ||
+		groups["ipv4:9.9"] != 1 {
+		t.Errorf("network groups %v", groups)
+	}
+
+	software := tr.Software()
+	if len(software) != 2 || software["Satoshi"] != 2 ||
+		software["btcd"] != 1 {
+		t.Errorf("software families %v", software)
+	}
+}
+
+func TestDropReconnectPeer(t *testing.T) {
+	dials := make(chan struct{}, 8)
+	handler := func(conn net.Conn) {
+		dials <- struct{}{}
+		node := pbtctest.NewNode(conn, wire.TestNet3)
+		_, err := node.Handshake()
+		if err != nil {
+			return
+		}
+
+		for {
+			_, err := node.Receive()
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	mgr := newTestManager(t, SetProtocolMagic(wire.TestNet3),
+		SetDialer(pbtctest.NewDialer(handler)))
+	mgr.SetRepository(pbtctest.NewRepository())
+
+	tkr, err := tracker.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr.SetTracker(tkr)
+
+	mgr.Start()
+	defer mgr.Stop()
+
+	addr := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 18333}
+	dialed := func(what string) {
+		select {
+		case <-dials:
+
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no dial on %v", what)
+		}
+	}
+
+	managed := func() bool {
+		_, ok := mgr.peerIndex.Get(addr.String())
+		return ok
+	}
+
+	// an address we are not connected to is dialed right away
+	err = mgr.ReconnectPeer(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialed("reconnect")
+
+	for i := 0; i < 100 && !managed(); i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	// a connected peer is dropped and dialed again once stopped
+	err = mgr.ReconnectPeer(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialed("reconnect of connected peer")
+
+	for i := 0; i < 100 && !managed(); i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	// a dropped peer is stopped, removed and not dialed again
+	err = mgr.DropPeer(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 500 && managed(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if managed() {
+		t.Fatal("dropped peer still managed")
+	}
+
+	select {
+	case <-dials:
+		t.Error("dropped peer dialed again")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	err = mgr.DropPeer(addr)
+	if err == nil {
+		t.Error("dropped peer that is not managed")
+	}
+}
+
+func TestLimitLifetime(t *testing.T) {
+	mgr := newTestManager(t, SetConnectionLifetime(20*time.Millisecond,
+		20*time.Millisecond))
+
+	expired := addTestPeer(mgr, "192.0.2.1", adaptor.PeerStats{})
+	removed := addTestPeer(mgr, "192.0.2.2", adaptor.PeerStats{})
+
+	mgr.limitLifetime(expired)
+	mgr.limitLifetime(removed)
+	mgr.unlimitLifetime(removed)
+
+	if len(mgr.lifetimes) != 1 {
+		t.Errorf("%v lifetime timers kept", len(mgr.lifetimes))
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if expired.Calls("Drop") != 1 ||
+		expired.Reason() != records.ReasonLifetime {
+		t.Errorf("expired peer not dropped (reason %q)", expired.Reason())
+	}
+
+	if removed.Calls("Drop") != 0 {
+		t.Error("removed peer dropped by its lifetime timer")
+	}
+}
+
+func TestSummary(t *testing.T) {
+	handler := func(conn net.Conn) {
+		node := pbtctest.NewNode(conn, wire.TestNet3)
+		_, err := node.Handshake()
+		if err != nil {
+			return
+		}
+
+		for {
+			_, err := node.Receive()
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	mgr := newTestManager(t, SetProtocolMagic(wire.TestNet3),
+		SetConnectOut(false), SetDialer(pbtctest.NewDialer(handler)))
+	mgr.SetRepository(pbtctest.NewRepository())
+
+	tkr, err := tracker.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr.SetTracker(tkr)
+
+	pro := pbtctest.NewProcessor()
+	mgr.AddProcessor(pro)
+
+	mgr.Start()
+
+	addr := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 18333}
+	mgr.addPeer(addr)
+
+	if !pro.Wait(2, time.Second) {
+		t.Fatalf("received %v records", len(pro.Records()))
+	}
+
+	err = mgr.DropPeer(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100 && mgr.peerIndex.Count() > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	written := pro.Written()
+	mgr.Stop()
+
+	all := pro.Records()
+	summary, ok := all[len(all)-1].(*records.SummaryRecord)
+	if !ok {
+		t.Fatalf("last record is %v", all[len(all)-1].Command())
+	}
+
+	if summary.Peers() != 1 || summary.Bans() != 1 {
+		t.Errorf("summary has %v peers and %v bans", summary.Peers(),
+			summary.Bans())
+	}
+
+	if summary.Written() != written {
+		t.Errorf("summary has %v bytes written instead of %v",
+			summary.Written(), written)
+	}
+
+	commands := summary.Commands()
+	if commands["version"] != 1 || commands["verack"] != 1 {
+		t.Errorf("summary has commands %v", commands)
+	}
+
+	if summary.Received() == 0 {
+		t.Error("summary has no bytes received")
+	}
+}
+
+func TestHealthy(t *testing.T) {
+	mgr := newTestManager(t)
+
+	ok, _ := mgr.Healthy()
+	if ok {
+		t.Error("healthy before start")
+	}
+
+	atomic.StoreUint32(&mgr.state, stateRunning)
+
+	ok, _ = mgr.Healthy()
+	if ok {
+		t.Error("healthy without peers")
+	}
+
+	addTestPeer(mgr, "192.0.2.1", adaptor.PeerStats{})
+
+	ok, err := mgr.Healthy()
+	if !ok {
+		t.Errorf("unhealthy with peers (%v)", err)
+	}
+}
+
+func TestTrustedPeers(t *testing.T) {
+	now := time.Now()
+	mgr := newTestManager(t, SetMaxPeersPerGroup(1, 16),
+		SetPeerMaxAge(time.Hour), SetPeerIdleTimeout(time.Minute, time.Minute),
+		SetConnectionLifetime(time.Minute, time.Minute),
+		SetClock(func() time.Time { return now }),
+		SetTrustedPeers([]string{"192.0.2.1:8333", "invalid"}))
+	mgr.resolveTrusted()
+
+	if len(mgr.trusted) != 1 {
+		t.Fatalf("%v trusted peers resolved, want 1", len(mgr.trusted))
+	}
+
+	other := addTestPeer(mgr, "192.0.2.2", adaptor.PeerStats{
+		Connected:  now.Add(-time.Minute),
+		LastUseful: now,
+	})
+
+	// the trusted peer is admitted to a full group and kept regardless of
+	// its age and idle time
+	p := pbtctest.NewPeer(&net.TCPAddr{IP: net.ParseIP("192.0.2.1"),
+		Port: 8333})
+	p.SetStats(adaptor.PeerStats{
+		Connected:  now.Add(-2 * time.Hour),
+		LastUseful: now.Add(-time.Hour),
+	})
+	if !mgr.admit(p) {
+		t.Fatal("trusted peer not admitted to a full group")
+	}
+
+	mgr.expirePeer()
+	mgr.reapIdle()
+	mgr.limitLifetime(p)
+
+	if p.Calls("Drop") != 0 {
+		t.Errorf("trusted peer dropped (reason %q)", p.Reason())
+	}
+
+	if len(mgr.lifetimes) != 0 {
+		t.Error("lifetime of trusted peer limited")
+	}
+
+	if other.Calls("Drop") != 0 {
+		t.Error("untrusted peer dropped instead of the trusted one")
+	}
+
+	// trusted peers take a slot even when all slots are taken
+	mgr.slots = int32(mgr.connLimit)
+	if !mgr.reserveSlot(true) || mgr.reserveSlot(false) {
+		t.Error("slot not reserved for trusted peer only")
+	}
+}
+
+func TestTrustedReconnect(t *testing.T) {
+	dials := make(chan struct{}, 8)
+	handler := func(conn net.Conn) {
+		dials <- struct{}{}
+		node := pbtctest.NewNode(conn, wire.TestNet3)
+		node.Handshake()
+		conn.Close()
+	}
+
+	mgr := newTestManager(t, SetProtocolMagic(wire.TestNet3),
+		SetDialer(pbtctest.NewDialer(handler)), SetConnectionLimit(1),
+		SetMaxPeersPerGroup(1, 16), SetTickerInterval(20*time.Millisecond),
+		SetTrustedPeers([]string{"192.0.2.1:18333"}))
+	mgr.SetRepository(pbtctest.NewRepository())
+
+	tkr, err := tracker.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr.SetTracker(tkr)
+
+	// the connection limit is reached and the group of the trusted peer is
+	// full, which must not keep us from connecting to it
+	full := addTestPeer(mgr, "192.0.2.2", adaptor.PeerStats{})
+	mgr.slots = 1
+
+	mgr.Start()
+	defer mgr.Stop()
+
+	// the mock peer never reports that it stopped
+	defer mgr.peerIndex.Remove(full)
+
+	// the trusted peer is dialed right away and again once it is lost
+	for i := 0; i < 2; i++ {
+		select {
+		case <-dials:
+
+		case <-time.After(5 * time.Second):
+			t.Fatalf("trusted peer not dialed (%v dials)", i)
+		}
+	}
+}
+
+func TestStateHandler(t *testing.T) {
+	var mgr *Manager
+	var transitions [][2]adaptor.State
+	handler := func(old adaptor.State, state adaptor.State) {
+		// the handler may call back into the manager
+		mgr.Healthy()
+		transitions = append(transitions, [2]adaptor.State{old, state})
+	}
+
+	mgr = newTestManager(t, SetConnectOut(false), SetStateHandler(handler))
+	mgr.SetRepository(pbtctest.NewRepository())
+
+	tkr, err := tracker.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr.SetTracker(tkr)
+
+	mgr.Start()
+	mgr.Stop()
+
+	expected := [][2]adaptor.State{
+		{adaptor.StateIdle, adaptor.StateRunning},
+		{adaptor.StateRunning, adaptor.StateIdle},
+	}
+
+	if !reflect.DeepEqual(transitions, expected) {
+		t.Errorf("transitions %v instead of %v", transitions, expected)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package pbtctest provides fakes for the modules of pbtc, so that they can be
+// tested without touching the network: connections backed by in-memory pipes,
+// a dialer handing them out, a remote node driving the handshake, mock peers
+// and repositories for the manager and a processor capturing the records it
+// receives.
+package pbtctest
+
+import (
+	"net"
+)
+
+// Conn is one end of an in-memory connection. Unlike a plain pipe, it reports
+// TCP addresses, so that peers can parse them like on a real connection.
+type Conn struct {
+	net.Conn
+
+	local  *net.TCPAddr
+	remote *net.TCPAddr
+}
+
+// Pipe returns both ends of an in-memory connection between the two addresses.
+// What is written to one end can be read from the other.
+func Pipe(local *net.TCPAddr, remote *net.TCPAddr) (*Conn, *Conn) {
+	c1, c2 := net.Pipe()
+
+	near := &Conn{Conn: c1, local: local, remote: remote}
+	far := &Conn{Conn: c2, local: remote, remote: local}
+
+	return near, far
+}
+
+// LocalAddr returns the address of this end of the connection.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.local
+}
+
+// RemoteAddr returns the address of the other end of the connection.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.remote
+}
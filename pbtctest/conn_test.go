@@ -0,0 +1,70 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package pbtctest
+
+import (
+	"io"
+	"net"
+	"testing"
+)
+
+var (
+	testLocal  = &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 50000}
+	testRemote = &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 8333}
+)
+
+func TestPipe(t *testing.T) {
+	near, far := Pipe(testLocal, testRemote)
+	defer near.Close()
+	defer far.Close()
+
+	if near.LocalAddr() != testLocal || near.RemoteAddr() != testRemote {
+		t.Errorf("near end between %v and %v", near.LocalAddr(),
+			near.RemoteAddr())
+	}
+
+	if far.LocalAddr() != testRemote || far.RemoteAddr() != testLocal {
+		t.Errorf("far end between %v and %v", far.LocalAddr(),
+			far.RemoteAddr())
+	}
+
+	go near.Write([]byte("ping"))
+
+	buf := make([]byte, 4)
+	_, err := io.ReadFull(far, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buf) != "ping" {
+		t.Errorf("read %q", buf)
+	}
+}
+
+func TestPipeClose(t *testing.T) {
+	near, far := Pipe(testLocal, testRemote)
+	near.Close()
+
+	_, err := far.Read(make([]byte, 1))
+	if err == nil {
+		t.Error("read from closed pipe")
+	}
+}
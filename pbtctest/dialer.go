@@ -0,0 +1,52 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package pbtctest
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/CIRCL/pbtc/peer"
+)
+
+// localPort is the port of the local end of the next dialed connection, so
+// that every connection has a distinct local address.
+var localPort uint32 = 40000
+
+// NewDialer returns a dialer for peers that connects them over in-memory
+// pipes instead of TCP. For every connection, the handler is launched as a go
+// routine with the remote end, where it can act as the node we dialed.
+func NewDialer(handler func(net.Conn)) *peer.Dialer {
+	dial := func(addr *net.TCPAddr, timeout time.Duration) (net.Conn, error) {
+		local := &net.TCPAddr{
+			IP:   net.IPv4(127, 0, 0, 1),
+			Port: int(atomic.AddUint32(&localPort, 1)),
+		}
+
+		near, far := Pipe(local, addr)
+		go handler(far)
+
+		return near, nil
+	}
+
+	return peer.NewDialer(peer.SetDialFunc(dial))
+}
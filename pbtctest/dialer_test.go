@@ -0,0 +1,78 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package pbtctest
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDialer(t *testing.T) {
+	remotes := make(chan net.Conn, 2)
+	dialer := NewDialer(func(conn net.Conn) {
+		remotes <- conn
+	})
+
+	conn1, err := dialer.Dial(testRemote, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn1.Close()
+
+	conn2, err := dialer.Dial(testRemote, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn2.Close()
+
+	if conn1.RemoteAddr().String() != testRemote.String() {
+		t.Errorf("dialed %v", conn1.RemoteAddr())
+	}
+
+	if conn1.LocalAddr().String() == conn2.LocalAddr().String() {
+		t.Errorf("both connections from %v", conn1.LocalAddr())
+	}
+
+	far := <-remotes
+	if far.RemoteAddr().String() != conn1.LocalAddr().String() {
+		far = <-remotes
+	}
+
+	if far.LocalAddr().String() != testRemote.String() ||
+		far.RemoteAddr().String() != conn1.LocalAddr().String() {
+		t.Errorf("handler got connection between %v and %v",
+			far.LocalAddr(), far.RemoteAddr())
+	}
+
+	go conn1.Write([]byte("ping"))
+
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(far, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buf) != "ping" {
+		t.Errorf("handler read %q", buf)
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package pbtctest
+
+// Log is a log that discards all messages.
+type Log struct{}
+
+func (Log) Debug(format string, args ...interface{})    {}
+func (Log) Info(format string, args ...interface{})     {}
+func (Log) Notice(format string, args ...interface{})   {}
+func (Log) Warning(format string, args ...interface{})  {}
+func (Log) Error(format string, args ...interface{})    {}
+func (Log) Critical(format string, args ...interface{}) {}
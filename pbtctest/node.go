@@ -0,0 +1,118 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package pbtctest
+
+import (
+	"errors"
+	"net"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Node acts as the remote Bitcoin node on a connection, answering the
+// handshake of a peer and sending it messages.
+type Node struct {
+	Conn    net.Conn
+	Network wire.BitcoinNet
+	Version uint32
+	Nonce   uint64
+}
+
+// NewNode creates a node speaking on the given connection and network.
+func NewNode(conn net.Conn, network wire.BitcoinNet) *Node {
+	node := &Node{
+		Conn:    conn,
+		Network: network,
+		Version: wire.RejectVersion,
+		Nonce:   1,
+	}
+
+	return node
+}
+
+// Handshake waits for the version message of the peer, answers with our own
+// version and a verack and waits for the verack of the peer. It returns the
+// version message received. Other messages received in between are skipped.
+func (node *Node) Handshake() (*wire.MsgVersion, error) {
+	var version *wire.MsgVersion
+	for version == nil {
+		msg, err := node.Receive()
+		if err != nil {
+			return nil, err
+		}
+
+		version, _ = msg.(*wire.MsgVersion)
+	}
+
+	me, err := wire.NewNetAddress(node.Conn.LocalAddr(), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	you, err := wire.NewNetAddress(node.Conn.RemoteAddr(), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	answer := wire.NewMsgVersion(me, you, node.Nonce, 0)
+	answer.ProtocolVersion = int32(node.Version)
+
+	err = node.Send(answer)
+	if err != nil {
+		return nil, err
+	}
+
+	err = node.Send(wire.NewMsgVerAck())
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		msg, err := node.Receive()
+		if err != nil {
+			return nil, err
+		}
+
+		_, ok := msg.(*wire.MsgVerAck)
+		if ok {
+			return version, nil
+		}
+	}
+}
+
+// Send writes a message to the peer.
+func (node *Node) Send(msg wire.Message) error {
+	return wire.WriteMessage(node.Conn, msg, node.Version, node.Network)
+}
+
+// Receive reads the next message from the peer.
+func (node *Node) Receive() (wire.Message, error) {
+	msg, _, err := wire.ReadMessage(node.Conn, node.Version, node.Network)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg == nil {
+		return nil, errors.New("empty message")
+	}
+
+	return msg, nil
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package pbtctest
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestNodeHandshake(t *testing.T) {
+	near, far := Pipe(testLocal, testRemote)
+	defer near.Close()
+
+	node := NewNode(far, wire.TestNet3)
+	done := make(chan *wire.MsgVersion, 1)
+	go func() {
+		version, err := node.Handshake()
+		if err != nil {
+			t.Error(err)
+		}
+
+		done <- version
+	}()
+
+	// act as the peer on the near end
+	us := NewNode(near, wire.TestNet3)
+	me, _ := wire.NewNetAddress(testLocal, 0)
+	you, _ := wire.NewNetAddress(testRemote, 0)
+	version := wire.NewMsgVersion(me, you, 42, 0)
+	version.ProtocolVersion = int32(wire.RejectVersion)
+
+	err := us.Send(wire.NewMsgPing(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = us.Send(version)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := us.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	answer, ok := msg.(*wire.MsgVersion)
+	if !ok {
+		t.Fatalf("received %v instead of version", msg.Command())
+	}
+
+	if answer.Nonce != node.Nonce {
+		t.Errorf("received nonce %v", answer.Nonce)
+	}
+
+	msg, err = us.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, ok = msg.(*wire.MsgVerAck)
+	if !ok {
+		t.Fatalf("received %v instead of verack", msg.Command())
+	}
+
+	err = us.Send(wire.NewMsgVerAck())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := <-done
+	if received == nil || received.Nonce != 42 {
+		t.Errorf("handshake returned %v", received)
+	}
+}
+
+func TestNodeClosed(t *testing.T) {
+	near, far := Pipe(testLocal, testRemote)
+	near.Close()
+
+	_, err := NewNode(far, wire.TestNet3).Handshake()
+	if err == nil {
+		t.Error("handshake on closed connection")
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package pbtctest
+
+import (
+	"net"
+	"sync"
+
+	"github.com/CIRCL/pbtc/adaptor"
+)
+
+// Peer is a mock peer for testing managers. It does not connect anywhere and
+// only counts how often each of its methods was called.
+type Peer struct {
+	mutex  *sync.Mutex
+	addr   *net.TCPAddr
+	calls  map[string]int
+	stats  adaptor.PeerStats
+	reason string
+}
+
+// NewPeer creates a mock peer with the given address.
+func NewPeer(addr *net.TCPAddr) *Peer {
+	p := &Peer{
+		mutex: &sync.Mutex{},
+		addr:  addr,
+		calls: make(map[string]int),
+		stats: adaptor.PeerStats{Address: addr.String()},
+	}
+
+	return p
+}
+
+func (p *Peer) String() string {
+	return p.addr.String()
+}
+
+func (p *Peer) Addr() *net.TCPAddr {
+	return p.addr
+}
+
+func (p *Peer) Start() {
+	p.call("Start")
+}
+
+func (p *Peer) Stop() {
+	p.call("Stop")
+}
+
+// Drop counts as a call to Drop and remembers the reason.
+func (p *Peer) Drop(reason string) {
+	p.mutex.Lock()
+	p.reason = reason
+	p.mutex.Unlock()
+
+	p.call("Drop")
+}
+
+// Reason returns the reason of the last call to Drop.
+func (p *Peer) Reason() string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.reason
+}
+
+func (p *Peer) Connect() {
+	p.call("Connect")
+}
+
+func (p *Peer) Greet() {
+	p.call("Greet")
+}
+
+func (p *Peer) Poll() {
+	p.call("Poll")
+}
+
+// Stats returns the statistics set with SetStats.
+func (p *Peer) Stats() adaptor.PeerStats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.stats
+}
+
+// SetStats sets the statistics the peer reports.
+func (p *Peer) SetStats(stats adaptor.PeerStats) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.stats = stats
+}
+
+// Calls returns how often the method with the given name was called.
+func (p *Peer) Calls(method string) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.calls[method]
+}
+
+func (p *Peer) call(method string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.calls[method]++
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package pbtctest
+
+import (
+	"testing"
+
+	"github.com/CIRCL/pbtc/adaptor"
+)
+
+func TestPeer(t *testing.T) {
+	var p adaptor.Peer = NewPeer(testRemote)
+	mock := p.(*Peer)
+
+	if p.Addr() != testRemote || p.String() != testRemote.String() {
+		t.Errorf("peer at %v", p)
+	}
+
+	if p.Stats().Address != testRemote.String() {
+		t.Errorf("stats for %v", p.Stats().Address)
+	}
+
+	p.Connect()
+	p.Start()
+	p.Greet()
+	p.Poll()
+	p.Poll()
+	p.Drop("TEST")
+
+	tests := map[string]int{
+		"Connect": 1,
+		"Start":   1,
+		"Greet":   1,
+		"Poll":    2,
+		"Drop":    1,
+		"Stop":    0,
+	}
+
+	for method, count := range tests {
+		if mock.Calls(method) != count {
+			t.Errorf("%v called %v times", method, mock.Calls(method))
+		}
+	}
+
+	if mock.Reason() != "TEST" {
+		t.Errorf("dropped for %q", mock.Reason())
+	}
+
+	mock.SetStats(adaptor.PeerStats{Inbound: true})
+	if !p.Stats().Inbound {
+		t.Error("stats not set")
+	}
+}
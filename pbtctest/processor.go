@@ -0,0 +1,163 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package pbtctest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/CIRCL/pbtc/adaptor"
+)
+
+// Processor is a writer for testing that keeps all records it receives in
+// memory, so they can be checked afterwards.
+type Processor struct {
+	mutex   *sync.Mutex
+	cond    *sync.Cond
+	records []adaptor.Record
+	next    []adaptor.Processor
+	paused  bool
+	dropped uint64
+	written uint64
+}
+
+// NewProcessor creates a new capturing processor.
+func NewProcessor() *Processor {
+	pro := &Processor{
+		mutex: &sync.Mutex{},
+	}
+
+	pro.cond = sync.NewCond(pro.mutex)
+
+	return pro
+}
+
+func (pro *Processor) SetLog(log adaptor.Log) {
+}
+
+func (pro *Processor) Name() string {
+	return "test"
+}
+
+func (pro *Processor) AddNext(next adaptor.Processor) {
+	pro.next = append(pro.next, next)
+}
+
+func (pro *Processor) Next() []adaptor.Processor {
+	return pro.next
+}
+
+// Process keeps the record and forwards it to the next processors.
+func (pro *Processor) Process(record adaptor.Record) {
+	pro.mutex.Lock()
+	if pro.paused {
+		pro.dropped++
+		pro.mutex.Unlock()
+		return
+	}
+
+	pro.records = append(pro.records, record)
+	pro.written += uint64(len(record.String()) + 1)
+	pro.cond.Broadcast()
+	pro.mutex.Unlock()
+
+	for _, next := range pro.next {
+		next.Process(record)
+	}
+}
+
+func (pro *Processor) Backpressure() bool {
+	return false
+}
+
+func (pro *Processor) Pause() {
+	pro.mutex.Lock()
+	defer pro.mutex.Unlock()
+
+	pro.paused = true
+}
+
+func (pro *Processor) Resume() {
+	pro.mutex.Lock()
+	defer pro.mutex.Unlock()
+
+	pro.paused = false
+}
+
+func (pro *Processor) Dropped() uint64 {
+	pro.mutex.Lock()
+	defer pro.mutex.Unlock()
+
+	return pro.dropped
+}
+
+// Written returns the number of bytes the captured records take as lines of
+// text, as if they had been written to a file.
+func (pro *Processor) Written() uint64 {
+	pro.mutex.Lock()
+	defer pro.mutex.Unlock()
+
+	return pro.written
+}
+
+func (pro *Processor) Start() {
+}
+
+func (pro *Processor) Stop() {
+}
+
+func (pro *Processor) Healthy() (bool, error) {
+	return true, nil
+}
+
+// Records returns the records received so far.
+func (pro *Processor) Records() []adaptor.Record {
+	pro.mutex.Lock()
+	defer pro.mutex.Unlock()
+
+	return append([]adaptor.Record{}, pro.records...)
+}
+
+// Wait waits until the given number of records was received and returns
+// whether they arrived before the timeout.
+func (pro *Processor) Wait(count int, timeout time.Duration) bool {
+	timer := time.AfterFunc(timeout, func() {
+		pro.mutex.Lock()
+		pro.cond.Broadcast()
+		pro.mutex.Unlock()
+	})
+	defer timer.Stop()
+
+	deadline := time.Now().Add(timeout)
+
+	pro.mutex.Lock()
+	defer pro.mutex.Unlock()
+
+	for len(pro.records) < count {
+		if !time.Now().Before(deadline) {
+			return false
+		}
+
+		pro.cond.Wait()
+	}
+
+	return true
+}
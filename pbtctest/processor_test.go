@@ -0,0 +1,84 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package pbtctest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/CIRCL/pbtc/adaptor"
+	"github.com/CIRCL/pbtc/records"
+)
+
+func testRecord(nonce uint64) adaptor.Record {
+	return records.NewPingRecord(wire.NewMsgPing(nonce), testRemote,
+		testLocal, time.Now())
+}
+
+func TestProcessor(t *testing.T) {
+	pro := NewProcessor()
+	next := NewProcessor()
+	pro.AddNext(next)
+
+	var _ adaptor.Processor = pro
+
+	pro.Process(testRecord(1))
+	pro.Process(testRecord(2))
+
+	if len(pro.Records()) != 2 || len(next.Records()) != 2 {
+		t.Errorf("captured %v and forwarded %v records",
+			len(pro.Records()), len(next.Records()))
+	}
+
+	pro.Pause()
+	pro.Process(testRecord(3))
+	pro.Resume()
+	pro.Process(testRecord(4))
+
+	if len(pro.Records()) != 3 || pro.Dropped() != 1 {
+		t.Errorf("captured %v and dropped %v records", len(pro.Records()),
+			pro.Dropped())
+	}
+}
+
+func TestProcessorWait(t *testing.T) {
+	pro := NewProcessor()
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		pro.Process(testRecord(1))
+	}()
+
+	if !pro.Wait(1, time.Second) {
+		t.Error("record not received")
+	}
+
+	start := time.Now()
+	if pro.Wait(2, 50*time.Millisecond) {
+		t.Error("waited for record that was not sent")
+	}
+
+	if time.Since(start) < 50*time.Millisecond {
+		t.Error("wait returned before timeout")
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package pbtctest
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/CIRCL/pbtc/adaptor"
+)
+
+// Repository is a mock repository for testing managers and peers. It never
+// hands out addresses and only counts how often each of its methods was
+// called, together with the last address it was called for.
+type Repository struct {
+	mutex *sync.Mutex
+	calls map[string]int
+	last  map[string]*net.TCPAddr
+}
+
+// NewRepository creates an empty mock repository.
+func NewRepository() *Repository {
+	repo := &Repository{
+		mutex: &sync.Mutex{},
+		calls: make(map[string]int),
+		last:  make(map[string]*net.TCPAddr),
+	}
+
+	return repo
+}
+
+func (repo *Repository) SetLog(log adaptor.Log) {
+}
+
+func (repo *Repository) Name() string {
+	return "test"
+}
+
+func (repo *Repository) SetNetwork(network wire.BitcoinNet) {
+}
+
+func (repo *Repository) Discovered(addr *net.TCPAddr, src *net.TCPAddr,
+	stamp time.Time) {
+	repo.call("Discovered", addr)
+}
+
+func (repo *Repository) Attempted(addr *net.TCPAddr) {
+	repo.call("Attempted", addr)
+}
+
+func (repo *Repository) Connected(addr *net.TCPAddr) {
+	repo.call("Connected", addr)
+}
+
+func (repo *Repository) Succeeded(addr *net.TCPAddr) {
+	repo.call("Succeeded", addr)
+}
+
+func (repo *Repository) Failed(addr *net.TCPAddr) {
+	repo.call("Failed", addr)
+}
+
+func (repo *Repository) Advertised(addr *net.TCPAddr, src *net.TCPAddr) {
+	repo.call("Advertised", addr)
+}
+
+// Retrieve does not send any address, so managers never dial on their own.
+func (repo *Repository) Retrieve(addrQ chan<- *net.TCPAddr) {
+}
+
+func (repo *Repository) Stats() adaptor.RepositoryStats {
+	return adaptor.RepositoryStats{}
+}
+
+func (repo *Repository) Start() {
+}
+
+func (repo *Repository) Stop() {
+}
+
+func (repo *Repository) Healthy() (bool, error) {
+	return true, nil
+}
+
+// Calls returns how often the method with the given name was called.
+func (repo *Repository) Calls(method string) int {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	return repo.calls[method]
+}
+
+// Last returns the address of the last call to the method with the given
+// name, or nil if it was not called.
+func (repo *Repository) Last(method string) *net.TCPAddr {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	return repo.last[method]
+}
+
+func (repo *Repository) call(method string, addr *net.TCPAddr) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	repo.calls[method]++
+	repo.last[method] = addr
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package pbtctest
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/CIRCL/pbtc/adaptor"
+)
+
+func TestRepository(t *testing.T) {
+	var repo adaptor.Repository = NewRepository()
+	mock := repo.(*Repository)
+
+	other := &net.TCPAddr{IP: net.ParseIP("192.0.2.3"), Port: 8333}
+	repo.Discovered(other, testRemote, time.Now())
+	repo.Attempted(testRemote)
+	repo.Attempted(other)
+	repo.Succeeded(testRemote)
+
+	if mock.Calls("Attempted") != 2 || mock.Last("Attempted") != other {
+		t.Errorf("attempted %v times, last %v", mock.Calls("Attempted"),
+			mock.Last("Attempted"))
+	}
+
+	if mock.Calls("Succeeded") != 1 || mock.Last("Succeeded") != testRemote {
+		t.Errorf("succeeded %v times, last %v", mock.Calls("Succeeded"),
+			mock.Last("Succeeded"))
+	}
+
+	if mock.Calls("Failed") != 0 || mock.Last("Failed") != nil {
+		t.Error("failed without call")
+	}
+
+	addrQ := make(chan *net.TCPAddr, 1)
+	repo.Retrieve(addrQ)
+	if len(addrQ) != 0 {
+		t.Error("retrieved an address")
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package peer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/CIRCL/pbtc/records"
+)
+
+// errUnknownCommand is returned when a peer sent a well-framed message with a
+// command we do not support. Its payload has been skipped, so the connection
+// can be used further.
+var errUnknownCommand = errors.New("unknown message command")
+
+// errHandledCommand is returned when a message that the wire package does not
+// support was decoded and processed by ourselves.
+var errHandledCommand = errors.New("message command handled")
+
+// errWitnessTx is returned when a peer sent a transaction with witness, which
+// the wire package can't decode. Its payload is kept, so we can decode it
+// ourselves.
+var errWitnessTx = errors.New("transaction with witness")
+
+// unknownFrame describes a well-framed message with a command we do not
+// support. The payload is only kept if it was asked for.
+type unknownFrame struct {
+	command string
+	size    int
+	payload []byte
+}
+
+// decodedCommands are the message commands the wire package does not support,
+// but which we decode ourselves, so their payload is always kept.
+var decodedCommands = map[string]bool{
+	records.CmdFeeFilter:   true,
+	records.CmdGetCFilters: true,
+	records.CmdCFilter:     true,
+	records.CmdCFHeaders:   true,
+}
+
+// knownCommands are the message commands supported by the wire package. Other
+// commands are skipped instead of being handed to the wire package, which would
+// treat them as errors.
+var knownCommands = map[string]bool{
+	wire.CmdVersion:     true,
+	wire.CmdVerAck:      true,
+	wire.CmdGetAddr:     true,
+	wire.CmdAddr:        true,
+	wire.CmdGetBlocks:   true,
+	wire.CmdInv:         true,
+	wire.CmdGetData:     true,
+	wire.CmdNotFound:    true,
+	wire.CmdBlock:       true,
+	wire.CmdTx:          true,
+	wire.CmdGetHeaders:  true,
+	wire.CmdHeaders:     true,
+	wire.CmdPing:        true,
+	wire.CmdPong:        true,
+	wire.CmdAlert:       true,
+	wire.CmdMemPool:     true,
+	wire.CmdFilterAdd:   true,
+	wire.CmdFilterClear: true,
+	wire.CmdFilterLoad:  true,
+	wire.CmdMerkleBlock: true,
+	wire.CmdReject:      true,
+}
+
+// readFrame reads the header of the next message and checks its framing. For
+// known commands, it returns a reader that replays the header followed by the
+// payload, to be decoded by the wire package. For unknown commands,
+// errUnknownCommand is returned together with a description of the skipped
+// frame; its payload is discarded, unless keep is set or it is one of the
+// decoded commands. Transactions with witness are returned as frame together
+// with errWitnessTx. Any other error means the stream can't
+// be trusted.
+func readFrame(r io.Reader, network wire.BitcoinNet,
+	keep bool) (io.Reader, *unknownFrame, error) {
+	header := make([]byte, wire.MessageHeaderSize)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	magic := binary.LittleEndian.Uint32(header[0:4])
+	if wire.BitcoinNet(magic) != network {
+		return nil, nil, errors.New("message from other network")
+	}
+
+	length := binary.LittleEndian.Uint32(header[16:20])
+	if length > wire.MaxMessagePayload {
+		return nil, nil, errors.New("message payload too large")
+	}
+
+	command := string(bytes.TrimRight(header[4:16], "\x00"))
+	if command == wire.CmdTx {
+		return readTx(r, header)
+	}
+
+	if !knownCommands[command] {
+		frame := &unknownFrame{
+			command: command,
+			size:    wire.MessageHeaderSize + int(length),
+		}
+
+		if keep || decodedCommands[command] {
+			frame.payload = make([]byte, length)
+			_, err = io.ReadFull(r, frame.payload)
+		} else {
+			_, err = io.CopyN(ioutil.Discard, r, int64(length))
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return nil, frame, errUnknownCommand
+	}
+
+	return io.MultiReader(bytes.NewReader(header), r), nil, nil
+}
+
+// readTx reads the payload of a tx message. Transactions with witness are
+// returned as frame once their checksum was verified, while others are
+// replayed to be decoded by the wire package, which verifies it.
+func readTx(r io.Reader, header []byte) (io.Reader, *unknownFrame, error) {
+	length := binary.LittleEndian.Uint32(header[16:20])
+	payload := make([]byte, length)
+	_, err := io.ReadFull(r, payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !hasWitness(payload) {
+		return io.MultiReader(bytes.NewReader(header),
+			bytes.NewReader(payload)), nil, nil
+	}
+
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+	if !bytes.Equal(second[:4], header[20:24]) {
+		return nil, nil, &wire.MessageError{Func: "readTx",
+			Description: "payload checksum mismatch"}
+	}
+
+	frame := &unknownFrame{
+		command: wire.CmdTx,
+		size:    wire.MessageHeaderSize + len(payload),
+		payload: payload,
+	}
+
+	return nil, frame, errWitnessTx
+}
+
+// writeFrame writes a message with a command the wire package does not
+// support, framing the payload of the given frame with the header and
+// checksum of the network.
+func writeFrame(conn net.Conn, network wire.BitcoinNet,
+	frame *unknownFrame) error {
+	conn.SetWriteDeadline(time.Now().Add(timeoutSend))
+
+	first := sha256.Sum256(frame.payload)
+	second := sha256.Sum256(first[:])
+
+	msg := make([]byte, wire.MessageHeaderSize, wire.MessageHeaderSize+
+		len(frame.payload))
+	binary.LittleEndian.PutUint32(msg[0:4], uint32(network))
+	copy(msg[4:16], frame.command)
+	binary.LittleEndian.PutUint32(msg[16:20], uint32(len(frame.payload)))
+	copy(msg[20:24], second[:4])
+	msg = append(msg, frame.payload...)
+
+	_, err := conn.Write(msg)
+
+	return err
+}
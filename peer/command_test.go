@@ -0,0 +1,112 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package peer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// testFrame returns a correctly framed message with the given command and
+// payload, like a peer with a newer protocol version would send it.
+func testFrame(network wire.BitcoinNet, command string,
+	payload []byte) []byte {
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+
+	frame := make([]byte, wire.MessageHeaderSize)
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(network))
+	copy(frame[4:16], command)
+	binary.LittleEndian.PutUint32(frame[16:20], uint32(len(payload)))
+	copy(frame[20:24], second[:4])
+
+	return append(frame, payload...)
+}
+
+func TestReadFrame(t *testing.T) {
+	payload := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9}
+
+	// an unknown frame is skipped, and the message behind it is read fine
+	buf := new(bytes.Buffer)
+	buf.Write(testFrame(wire.MainNet, "sendcmpct", payload))
+	err := wire.WriteMessage(buf, wire.NewMsgPing(7), wire.ProtocolVersion,
+		wire.MainNet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, frame, err := readFrame(buf, wire.MainNet, false)
+	if err != errUnknownCommand {
+		t.Fatalf("unknown command returned %v", err)
+	}
+
+	if frame.command != "sendcmpct" ||
+		frame.size != wire.MessageHeaderSize+len(payload) ||
+		frame.payload != nil {
+		t.Errorf("skipped frame %+v", frame)
+	}
+
+	r, _, err := readFrame(buf, wire.MainNet, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, _, err := wire.ReadMessage(r, wire.ProtocolVersion, wire.MainNet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ping, ok := msg.(*wire.MsgPing)
+	if !ok || ping.Nonce != 7 {
+		t.Errorf("read %v after unknown frame", msg.Command())
+	}
+
+	// the payload is only kept when asked for
+	buf = bytes.NewBuffer(testFrame(wire.MainNet, "sendcmpct", payload))
+	_, frame, err = readFrame(buf, wire.MainNet, true)
+	if err != errUnknownCommand || !bytes.Equal(frame.payload, payload) {
+		t.Errorf("kept payload %v (%v)", frame.payload, err)
+	}
+
+	// broken framing is an error that ends the connection
+	buf = bytes.NewBuffer(testFrame(wire.TestNet3, "sendcmpct", payload))
+	_, _, err = readFrame(buf, wire.MainNet, false)
+	if err == nil || err == errUnknownCommand {
+		t.Errorf("frame of other network returned %v", err)
+	}
+
+	header := testFrame(wire.MainNet, "sendcmpct", nil)
+	binary.LittleEndian.PutUint32(header[16:20], wire.MaxMessagePayload+1)
+	_, _, err = readFrame(bytes.NewBuffer(header), wire.MainNet, false)
+	if err == nil || err == errUnknownCommand {
+		t.Errorf("oversized frame returned %v", err)
+	}
+
+	buf = bytes.NewBuffer(testFrame(wire.MainNet, "sendcmpct", payload)[:30])
+	_, _, err = readFrame(buf, wire.MainNet, false)
+	if err == nil || err == errUnknownCommand {
+		t.Errorf("truncated frame returned %v", err)
+	}
+}
@@ -0,0 +1,422 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package peer
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProxySpec describes a SOCKS5 proxy that can be used to reach peers. The
+// username and password are optional and only sent if the proxy asks for them.
+type ProxySpec struct {
+	Address  string
+	Username string
+	Password string
+}
+
+// ParseProxy parses a proxy in the "user:password@host:port" format, where the
+// credentials part is optional.
+func ParseProxy(proxy string) (ProxySpec, error) {
+	spec := ProxySpec{}
+
+	at := strings.LastIndex(proxy, "@")
+	if at >= 0 {
+		creds := strings.SplitN(proxy[:at], ":", 2)
+		spec.Username = creds[0]
+		if len(creds) == 2 {
+			spec.Password = creds[1]
+		}
+
+		proxy = proxy[at+1:]
+	}
+
+	_, _, err := net.SplitHostPort(proxy)
+	if err != nil {
+		return spec, err
+	}
+
+	spec.Address = proxy
+
+	return spec, nil
+}
+
+// proxyState keeps track of the health of one proxy across connection attempts.
+type proxyState struct {
+	spec     ProxySpec
+	failures uint32
+	disabled time.Time
+}
+
+// Dialer establishes the TCP connections for outgoing peers. If proxies are
+// configured, they are tried one after the other for each connection attempt,
+// and a proxy that keeps failing is skipped for a while. It is shared by all
+// peers of a manager, so the health of the proxies is known across attempts.
+type Dialer struct {
+	mutex     *sync.Mutex
+	proxies   []*proxyState
+	next      int
+	locals    []net.IP
+	nextLocal int
+
+	rotation  bool
+	fallback  bool
+	failLimit uint32
+	failDelay time.Duration
+
+	dial func(*net.TCPAddr, time.Duration) (net.Conn, error)
+}
+
+// NewDialer creates a new dialer with the given options. Without any proxies,
+// it connects to peers directly.
+func NewDialer(options ...func(*Dialer)) *Dialer {
+	d := &Dialer{
+		mutex: &sync.Mutex{},
+
+		rotation:  false,
+		fallback:  false,
+		failLimit: 3,
+		failDelay: 5 * time.Minute,
+	}
+
+	for _, option := range options {
+		option(d)
+	}
+
+	return d
+}
+
+// SetProxyList sets the list of SOCKS5 proxies to connect through, in order of
+// preference.
+func SetProxyList(proxies []ProxySpec) func(*Dialer) {
+	return func(d *Dialer) {
+		d.proxies = make([]*proxyState, 0, len(proxies))
+		for _, spec := range proxies {
+			d.proxies = append(d.proxies, &proxyState{spec: spec})
+		}
+	}
+}
+
+// SetProxyRotation makes the dialer start each attempt with the next proxy in
+// the list, spreading the load, instead of always starting with the first.
+func SetProxyRotation(rotation bool) func(*Dialer) {
+	return func(d *Dialer) {
+		d.rotation = rotation
+	}
+}
+
+// SetProxyFallback allows the dialer to connect directly when all proxies have
+// failed.
+func SetProxyFallback(fallback bool) func(*Dialer) {
+	return func(d *Dialer) {
+		d.fallback = fallback
+	}
+}
+
+// SetProxyFailures sets the number of consecutive failures after which a proxy
+// is considered unhealthy and how long it will be skipped.
+func SetProxyFailures(limit uint32, delay time.Duration) func(*Dialer) {
+	return func(d *Dialer) {
+		d.failLimit = limit
+		d.failDelay = delay
+	}
+}
+
+// SetLocalAddrs sets the local IPs that connections originate from, for hosts
+// with several public addresses. Each connection binds the next IP in the list
+// of the same family as the address dialed, so that we appear as distinct
+// observers or spread rate limits across addresses. If there is none of the
+// right family, the system picks the local address as usual. When going
+// through a proxy, the connection to the proxy is bound instead.
+func SetLocalAddrs(ips []net.IP) func(*Dialer) {
+	return func(d *Dialer) {
+		d.locals = ips
+	}
+}
+
+// SetDialFunc replaces the way the dialer establishes connections, bypassing
+// proxies altogether. It allows peers to run over other transports, like the
+// in-memory connections used for testing.
+func SetDialFunc(dial func(*net.TCPAddr, time.Duration) (net.Conn,
+	error)) func(*Dialer) {
+	return func(d *Dialer) {
+		d.dial = dial
+	}
+}
+
+// Dial connects to the given address, trying all healthy proxies in order
+// before falling back to a direct connection if allowed.
+func (d *Dialer) Dial(addr *net.TCPAddr,
+	timeout time.Duration) (net.Conn, error) {
+	if d.dial != nil {
+		return d.dial(addr, timeout)
+	}
+
+	if len(d.proxies) == 0 {
+		return dialDirect(addr, d.local(addr.IP), timeout)
+	}
+
+	err := errors.New("no healthy proxy available")
+	for _, proxy := range d.candidates() {
+		var conn *net.TCPConn
+		conn, err = d.dialSOCKS5(proxy.spec, addr, timeout)
+		d.report(proxy, err)
+		if err == nil {
+			return conn, nil
+		}
+	}
+
+	if !d.fallback {
+		return nil, err
+	}
+
+	return dialDirect(addr, d.local(addr.IP), timeout)
+}
+
+// local returns the next local IP of the same family as the given remote IP,
+// or nil if there is none.
+func (d *Dialer) local(remote net.IP) net.IP {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	v4 := remote.To4() != nil
+	for i := range d.locals {
+		index := (d.nextLocal + i) % len(d.locals)
+		ip := d.locals[index]
+		if (ip.To4() != nil) != v4 {
+			continue
+		}
+
+		d.nextLocal = (index + 1) % len(d.locals)
+		return ip
+	}
+
+	return nil
+}
+
+// candidates returns the healthy proxies in the order they should be tried.
+func (d *Dialer) candidates() []*proxyState {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	start := 0
+	if d.rotation {
+		start = d.next
+		d.next = (d.next + 1) % len(d.proxies)
+	}
+
+	now := time.Now()
+	list := make([]*proxyState, 0, len(d.proxies))
+	for i := range d.proxies {
+		proxy := d.proxies[(start+i)%len(d.proxies)]
+		if proxy.disabled.After(now) {
+			continue
+		}
+
+		list = append(list, proxy)
+	}
+
+	return list
+}
+
+// report updates the health of a proxy after a connection attempt.
+func (d *Dialer) report(proxy *proxyState, err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if err == nil {
+		proxy.failures = 0
+		return
+	}
+
+	proxy.failures++
+	if proxy.failures < d.failLimit {
+		return
+	}
+
+	proxy.failures = 0
+	proxy.disabled = time.Now().Add(d.failDelay)
+}
+
+// dialDirect connects to the given address without going through a proxy. If a
+// local IP is given, the connection originates from it.
+func dialDirect(addr *net.TCPAddr, local net.IP,
+	timeout time.Duration) (*net.TCPConn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	if local != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: local}
+	}
+
+	connGen, err := dialer.Dial("tcp", addr.String())
+	if err != nil {
+		return nil, err
+	}
+
+	conn, ok := connGen.(*net.TCPConn)
+	if !ok {
+		connGen.Close()
+		return nil, errors.New("connection type assert failed")
+	}
+
+	return conn, nil
+}
+
+// dialSOCKS5 connects to the given address through a SOCKS5 proxy, as
+// described in RFC 1928, with username authentication from RFC 1929.
+func (d *Dialer) dialSOCKS5(spec ProxySpec, addr *net.TCPAddr,
+	timeout time.Duration) (*net.TCPConn, error) {
+	proxyAddr, err := net.ResolveTCPAddr("tcp", spec.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := dialDirect(proxyAddr, d.local(proxyAddr.IP), timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	err = handshakeSOCKS5(conn, spec, addr)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	return conn, nil
+}
+
+// handshakeSOCKS5 negotiates authentication and the connect command on an
+// established connection to a SOCKS5 proxy.
+func handshakeSOCKS5(conn *net.TCPConn, spec ProxySpec,
+	addr *net.TCPAddr) error {
+	methods := []byte{0x00}
+	if spec.Username != "" {
+		methods = append(methods, 0x02)
+	}
+
+	greeting := append([]byte{0x05, byte(len(methods))}, methods...)
+	_, err := conn.Write(greeting)
+	if err != nil {
+		return err
+	}
+
+	reply := make([]byte, 2)
+	_, err = io.ReadFull(conn, reply)
+	if err != nil {
+		return err
+	}
+
+	if reply[0] != 0x05 {
+		return errors.New("invalid socks version from proxy")
+	}
+
+	switch reply[1] {
+	case 0x00:
+
+	case 0x02:
+		if len(spec.Username) > 255 || len(spec.Password) > 255 {
+			return errors.New("socks credentials too long")
+		}
+
+		auth := []byte{0x01, byte(len(spec.Username))}
+		auth = append(auth, spec.Username...)
+		auth = append(auth, byte(len(spec.Password)))
+		auth = append(auth, spec.Password...)
+		_, err = conn.Write(auth)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.ReadFull(conn, reply)
+		if err != nil {
+			return err
+		}
+
+		if reply[1] != 0x00 {
+			return errors.New("socks authentication rejected")
+		}
+
+	default:
+		return errors.New("no acceptable socks authentication method")
+	}
+
+	request := []byte{0x05, 0x01, 0x00}
+	if ip := addr.IP.To4(); ip != nil {
+		request = append(request, 0x01)
+		request = append(request, ip...)
+	} else {
+		request = append(request, 0x04)
+		request = append(request, addr.IP.To16()...)
+	}
+
+	port := make([]byte, 2)
+	binary.BigEndian.PutUint16(port, uint16(addr.Port))
+	request = append(request, port...)
+
+	_, err = conn.Write(request)
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, 4)
+	_, err = io.ReadFull(conn, header)
+	if err != nil {
+		return err
+	}
+
+	if header[1] != 0x00 {
+		return errors.New("socks connect failed")
+	}
+
+	// skip the bound address and port, which we have no use for
+	var skip int
+	switch header[3] {
+	case 0x01:
+		skip = net.IPv4len + 2
+
+	case 0x04:
+		skip = net.IPv6len + 2
+
+	case 0x03:
+		length := make([]byte, 1)
+		_, err = io.ReadFull(conn, length)
+		if err != nil {
+			return err
+		}
+
+		skip = int(length[0]) + 2
+
+	default:
+		return errors.New("invalid socks address type")
+	}
+
+	_, err = io.ReadFull(conn, make([]byte, skip))
+
+	return err
+}
@@ -0,0 +1,207 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package peer
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// testProxy is a minimal SOCKS5 proxy on loopback that answers every connect
+// request with the given status. On success, it greets the client instead of
+// relaying to the target.
+type testProxy struct {
+	listener net.Listener
+	targets  chan *net.TCPAddr
+	status   byte
+}
+
+func newTestProxy(t *testing.T, status byte) *testProxy {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxy := &testProxy{
+		listener: listener,
+		targets:  make(chan *net.TCPAddr, 8),
+		status:   status,
+	}
+
+	go proxy.serve()
+
+	return proxy
+}
+
+func (proxy *testProxy) Spec() ProxySpec {
+	return ProxySpec{Address: proxy.listener.Addr().String()}
+}
+
+func (proxy *testProxy) Close() {
+	proxy.listener.Close()
+}
+
+func (proxy *testProxy) serve() {
+	for {
+		conn, err := proxy.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go proxy.handle(conn)
+	}
+}
+
+func (proxy *testProxy) handle(conn net.Conn) {
+	defer conn.Close()
+
+	greeting := make([]byte, 2)
+	_, err := io.ReadFull(conn, greeting)
+	if err != nil {
+		return
+	}
+
+	_, err = io.ReadFull(conn, make([]byte, greeting[1]))
+	if err != nil {
+		return
+	}
+
+	conn.Write([]byte{0x05, 0x00})
+
+	// we only ever connect to IPv4 addresses in these tests
+	request := make([]byte, 10)
+	_, err = io.ReadFull(conn, request)
+	if err != nil {
+		return
+	}
+
+	proxy.targets <- &net.TCPAddr{
+		IP:   net.IP(request[4:8]),
+		Port: int(request[8])<<8 | int(request[9]),
+	}
+
+	conn.Write([]byte{0x05, proxy.status, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+	if proxy.status == 0x00 {
+		conn.Write([]byte("hello"))
+	}
+}
+
+func TestDialProxyFailover(t *testing.T) {
+	failing := newTestProxy(t, 0x05)
+	defer failing.Close()
+	working := newTestProxy(t, 0x00)
+	defer working.Close()
+
+	d := NewDialer(SetProxyList([]ProxySpec{failing.Spec(),
+		working.Spec()}), SetProxyFailures(1, time.Hour))
+
+	target := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 8333}
+	conn, err := d.Dial(target, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	hello := make([]byte, 5)
+	_, err = io.ReadFull(conn, hello)
+	if err != nil || string(hello) != "hello" {
+		t.Errorf("got %q (%v) through the working proxy", hello, err)
+	}
+
+	for _, proxy := range []*testProxy{failing, working} {
+		got := <-proxy.targets
+		if !got.IP.Equal(target.IP) || got.Port != target.Port {
+			t.Errorf("proxy asked for %v, want %v", got, target)
+		}
+	}
+
+	// the failing proxy is now skipped altogether
+	conn, err = d.Dial(target, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	<-working.targets
+	select {
+	case <-failing.targets:
+		t.Error("dialed through disabled proxy")
+	default:
+	}
+}
+
+func TestDialProxyNoFallback(t *testing.T) {
+	failing := newTestProxy(t, 0x05)
+	defer failing.Close()
+
+	d := NewDialer(SetProxyList([]ProxySpec{failing.Spec()}))
+	_, err := d.Dial(&net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 8333},
+		time.Second)
+	if err == nil {
+		t.Error("connected without a working proxy")
+	}
+}
+
+func TestDialLocalAddrs(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			conn.Close()
+		}
+	}()
+
+	// connections take turns among the local IPs of the right family
+	d := NewDialer(SetLocalAddrs([]net.IP{net.ParseIP("127.0.0.2"),
+		net.IPv6loopback, net.ParseIP("127.0.0.3")}))
+	target := listener.Addr().(*net.TCPAddr)
+	for _, expected := range []string{"127.0.0.2", "127.0.0.3",
+		"127.0.0.2"} {
+		conn, err := d.Dial(target, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		local := conn.LocalAddr().(*net.TCPAddr)
+		conn.Close()
+
+		if !local.IP.Equal(net.ParseIP(expected)) {
+			t.Errorf("dialed from %v instead of %v", local.IP, expected)
+		}
+	}
+
+	// without a local IP of the family, the system picks one
+	d = NewDialer(SetLocalAddrs([]net.IP{net.IPv6loopback}))
+	if d.local(target.IP) != nil {
+		t.Error("bound IPv4 connection to IPv6 address")
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package peer
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/CIRCL/pbtc/adaptor"
+)
+
+// The peer package can't use the fakes of pbtctest, which imports it, so the
+// tests bring their own. Embedded interfaces leave the methods the tests do
+// not need unimplemented.
+
+type testLog struct{ adaptor.Log }
+
+func (testLog) Debug(format string, args ...interface{}) {}
+
+type testProcessor struct {
+	adaptor.Processor
+
+	mutex   sync.Mutex
+	records []adaptor.Record
+}
+
+func (pro *testProcessor) Process(record adaptor.Record) {
+	pro.mutex.Lock()
+	defer pro.mutex.Unlock()
+
+	pro.records = append(pro.records, record)
+}
+
+func (pro *testProcessor) Records() []adaptor.Record {
+	pro.mutex.Lock()
+	defer pro.mutex.Unlock()
+
+	return append([]adaptor.Record{}, pro.records...)
+}
+
+type testManager struct {
+	adaptor.Manager
+
+	pro       *testProcessor
+	stopped   chan adaptor.Peer
+	harvested uint32
+}
+
+func (mgr *testManager) Processors() []adaptor.Processor {
+	return []adaptor.Processor{mgr.pro}
+}
+
+func (mgr *testManager) Stopped(p adaptor.Peer) {
+	mgr.stopped <- p
+}
+
+func (mgr *testManager) Ready(p adaptor.Peer) {}
+
+func (mgr *testManager) Harvested(p adaptor.Peer) {
+	atomic.AddUint32(&mgr.harvested, 1)
+}
+
+type testRepository struct{ adaptor.Repository }
+
+func (testRepository) Failed(addr *net.TCPAddr) {}
+
+func (testRepository) Advertised(addr *net.TCPAddr, advertised *net.TCPAddr) {}
+
+type testTracker struct {
+	adaptor.Tracker
+
+	height int32
+	hash   wire.ShaHash
+}
+
+func (tkr testTracker) RemovePeer(peer string) {}
+
+func (tkr testTracker) ReportHeight(peer string, height int32) {}
+
+func (tkr testTracker) ReportFeeFilter(peer string, fee int64) {}
+
+func (tkr testTracker) AnnounceTx(hash wire.ShaHash, peer string,
+	stamp time.Time) time.Time {
+	return stamp
+}
+
+func (tkr testTracker) AnnounceBlock(hash wire.ShaHash, peer string,
+	stamp time.Time) {
+}
+
+func (tkr testTracker) SightTx(hash wire.ShaHash, peer string,
+	stamp time.Time) adaptor.Sighting {
+	return adaptor.Sighting{Peers: 1, First: stamp}
+}
+
+func (tkr testTracker) AddTx(hash wire.ShaHash) {}
+
+func (tkr testTracker) KnowsTx(hash wire.ShaHash) bool {
+	return false
+}
+
+func (tkr testTracker) KnowsBlock(hash wire.ShaHash) bool {
+	return false
+}
+
+func (tkr testTracker) TipHeight() int32 {
+	return tkr.height
+}
+
+func (tkr testTracker) TipHash() wire.ShaHash {
+	return tkr.hash
+}
+
+// testConn is one end of a pipe that reports TCP addresses.
+type testConn struct {
+	net.Conn
+
+	local  *net.TCPAddr
+	remote *net.TCPAddr
+}
+
+func (c *testConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c *testConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+var (
+	testLocal  = &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 50000}
+	testRemote = &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 8333}
+)
+
+// newTestPeer returns a peer on one end of a pipe, which is connected but not
+// started, together with the other end and the fake manager it reports to.
+func newTestPeer(options ...func(*Peer)) (*Peer, net.Conn, *testManager,
+	error) {
+	near, far := net.Pipe()
+	conn := &testConn{Conn: near, local: testLocal, remote: testRemote}
+	mgr := &testManager{
+		pro:     &testProcessor{},
+		stopped: make(chan adaptor.Peer, 1),
+	}
+
+	options = append([]func(*Peer){
+		SetLog(testLog{}),
+		SetManager(mgr),
+		SetRepository(testRepository{}),
+		SetTracker(testTracker{}),
+		SetNetwork(wire.MainNet),
+		SetConnection(conn),
+	}, options...)
+
+	p, err := New(options...)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return p, far, mgr, nil
+}
+
+// sendMessage writes a message to the peer from the far end of the pipe.
+func sendMessage(t *testing.T, far net.Conn, msg wire.Message) {
+	err := wire.WriteMessage(far, msg, wire.ProtocolVersion, wire.MainNet)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readMessages reads the messages the peer sends on the far end of the pipe,
+// so that the peer never blocks on sending. The channel is closed with the
+// pipe.
+func readMessages(far net.Conn) <-chan wire.Message {
+	msgs := make(chan wire.Message, 16)
+	go func() {
+		defer close(msgs)
+		for {
+			msg, _, err := wire.ReadMessage(far, wire.ProtocolVersion,
+				wire.MainNet)
+			if err != nil {
+				return
+			}
+
+			msgs <- msg
+		}
+	}()
+
+	return msgs
+}
+
+// waitRecord waits for the peer to process a record of the given command.
+func waitRecord(t *testing.T, pro *testProcessor,
+	command string) adaptor.Record {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		for _, record := range pro.Records() {
+			if record.Command() == command {
+				return record
+			}
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Fatalf("no %v record", command)
+	return nil
+}
+
+// testVersion returns the version message of the far end, which advertises
+// the address we see it on.
+func testVersion() *wire.MsgVersion {
+	me, _ := wire.NewNetAddress(testRemote, wire.SFNodeNetwork)
+	you, _ := wire.NewNetAddress(testLocal, wire.SFNodeNetwork)
+	return wire.NewMsgVersion(me, you, 1, 0)
+}
+
+// handshake completes the handshake with a greeted peer from the far end,
+// with the given version message, and returns once the peer sent its version
+// and verack.
+func handshake(t *testing.T, far net.Conn, msgs <-chan wire.Message,
+	version *wire.MsgVersion) {
+	sendMessage(t, far, version)
+	sendMessage(t, far, wire.NewMsgVerAck())
+
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-msgs:
+			switch msg.(type) {
+			case *wire.MsgVersion, *wire.MsgVerAck:
+			default:
+				t.Fatalf("%v during handshake", msg.Command())
+			}
+
+		case <-time.After(time.Second):
+			t.Fatal("handshake timed out")
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package peer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sync/atomic"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/CIRCL/pbtc/adaptor"
+	"github.com/CIRCL/pbtc/records"
+)
+
+// The compact block filter messages of BIP 157 are not supported by the wire
+// package, so we decode them ourselves and send our requests as raw frames.
+// We only ask for the basic filters of BIP 158, which are the only ones
+// defined so far.
+
+const (
+	cmdGetCFHeaders       = "getcfheaders"
+	filterBasic     uint8 = 0
+	maxFilterHashes       = 2000
+)
+
+// servesFilters returns whether the peer advertised that it serves compact
+// block filters. Unlike other data, filters are never requested without the
+// service flag, even if service checks are disabled, as peers disconnect us
+// for such requests.
+func (p *Peer) servesFilters() bool {
+	services := wire.ServiceFlag(atomic.LoadUint64(&p.services))
+	return services&sfNodeCompactFilters != 0
+}
+
+// pushGetCFilters asks the peer for the filter and the filter header of the
+// block at the tip of the chain, if it serves filters and the tip advanced
+// since we last asked.
+func (p *Peer) pushGetCFilters() {
+	if p.passive || !p.servesFilters() {
+		return
+	}
+
+	// the height and hash are read separately, so we make sure the tip did
+	// not move in between, as peers disconnect us for inconsistent ranges
+	height := p.tracker.TipHeight()
+	hash := p.tracker.TipHash()
+	if hash == (wire.ShaHash{}) || height < 0 ||
+		p.tracker.TipHeight() != height {
+		return
+	}
+
+	p.cmdMutex.Lock()
+	if p.filtered == hash {
+		p.cmdMutex.Unlock()
+		return
+	}
+	p.filtered = hash
+	p.cmdMutex.Unlock()
+
+	payload := make([]byte, 37)
+	payload[0] = filterBasic
+	binary.LittleEndian.PutUint32(payload[1:5], uint32(height))
+	copy(payload[5:37], hash[:])
+
+	p.frameQ <- &unknownFrame{command: records.CmdGetCFilters,
+		payload: payload}
+	p.frameQ <- &unknownFrame{command: cmdGetCFHeaders, payload: payload}
+}
+
+// processGetCFilters decodes a getcfilters message and forwards it to the
+// processors. We do not serve filters, so the request is only recorded.
+func (p *Peer) processGetCFilters(frame *unknownFrame) {
+	if len(frame.payload) != 37 {
+		p.log.Debug("[PEER] %v: invalid getcfilters message", p)
+		return
+	}
+
+	filter := frame.payload[0]
+	start := binary.LittleEndian.Uint32(frame.payload[1:5])
+	var stop [32]byte
+	copy(stop[:], frame.payload[5:37])
+
+	la := tcpAddr(p.conn.LocalAddr())
+	if la == nil {
+		return
+	}
+
+	record := records.NewGetCFiltersRecord(filter, start, stop, p.addr, la,
+		p.clock())
+	p.forwardFrame(record)
+}
+
+// processCFilter decodes a cfilter message and forwards it to the processors.
+// Its payload is the filter type, the block hash and the filter, prefixed
+// with its size.
+func (p *Peer) processCFilter(frame *unknownFrame) {
+	r := bytes.NewReader(frame.payload)
+	filter, hash, err := readFilterHead(r)
+	if err != nil {
+		p.log.Debug("[PEER] %v: invalid cfilter message (%v)", p, err)
+		return
+	}
+
+	size, err := wire.ReadVarInt(r, 0)
+	if err != nil || size != uint64(r.Len()) {
+		p.log.Debug("[PEER] %v: invalid cfilter message", p)
+		return
+	}
+
+	data := make([]byte, size)
+	io.ReadFull(r, data)
+	p.markUseful()
+
+	la := tcpAddr(p.conn.LocalAddr())
+	if la == nil {
+		return
+	}
+
+	record := records.NewCFilterRecord(filter, hash, data, p.addr, la,
+		p.clock())
+	p.forwardFrame(record)
+}
+
+// processCFHeaders decodes a cfheaders message and forwards it to the
+// processors. Its payload is the filter type, the stop hash, the previous
+// filter header and the filter hashes, prefixed with their count.
+func (p *Peer) processCFHeaders(frame *unknownFrame) {
+	r := bytes.NewReader(frame.payload)
+	filter, stop, err := readFilterHead(r)
+	if err != nil {
+		p.log.Debug("[PEER] %v: invalid cfheaders message (%v)", p, err)
+		return
+	}
+
+	var prev [32]byte
+	_, err = io.ReadFull(r, prev[:])
+	if err != nil {
+		p.log.Debug("[PEER] %v: invalid cfheaders message (%v)", p, err)
+		return
+	}
+
+	count, err := wire.ReadVarInt(r, 0)
+	if err != nil || count > maxFilterHashes ||
+		count*32 != uint64(r.Len()) {
+		p.log.Debug("[PEER] %v: invalid cfheaders message", p)
+		return
+	}
+
+	hashes := make([][32]byte, count)
+	for i := range hashes {
+		io.ReadFull(r, hashes[i][:])
+	}
+	p.markUseful()
+
+	la := tcpAddr(p.conn.LocalAddr())
+	if la == nil {
+		return
+	}
+
+	record := records.NewCFHeadersRecord(filter, stop, prev, hashes, p.addr,
+		la, p.clock())
+	p.forwardFrame(record)
+}
+
+// forwardFrame forwards the record of a message we decoded ourselves and
+// counts its command, like processMessage does for the other messages.
+func (p *Peer) forwardFrame(record adaptor.Record) {
+	p.forward(record)
+
+	p.cmdMutex.Lock()
+	p.commands[record.Command()]++
+	p.cmdMutex.Unlock()
+}
+
+// readFilterHead reads the filter type and the block hash that start the
+// cfilter and cfheaders messages.
+func readFilterHead(r io.Reader) (uint8, [32]byte, error) {
+	var head [33]byte
+	_, err := io.ReadFull(r, head[:])
+	if err != nil {
+		return 0, [32]byte{}, err
+	}
+
+	var hash [32]byte
+	copy(hash[:], head[1:])
+
+	return head[0], hash, nil
+}
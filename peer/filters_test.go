@@ -0,0 +1,156 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package peer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync/atomic"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/CIRCL/pbtc/records"
+)
+
+func TestCompactFilterMessages(t *testing.T) {
+	p, far, mgr, err := newTestPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer far.Close()
+
+	readMessages(far)
+	p.Start()
+	defer p.Stop()
+
+	stop := [32]byte{0xaa}
+	prev := [32]byte{0xbb}
+	send := func(command string, payload []byte) {
+		_, err := far.Write(testFrame(wire.MainNet, command, payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	request := make([]byte, 37)
+	binary.LittleEndian.PutUint32(request[1:5], 375000)
+	copy(request[5:], stop[:])
+	send(records.CmdGetCFilters, request)
+
+	get, ok := waitRecord(t, mgr.pro,
+		records.CmdGetCFilters).(*records.GetCFiltersRecord)
+	if !ok || get.FilterType() != 0 || get.StartHeight() != 375000 ||
+		get.StopHash() != stop {
+		t.Errorf("recorded getcfilters %v", get)
+	}
+
+	// the filter is long enough to need a three byte size
+	filter := make([]byte, 300)
+	for i := range filter {
+		filter[i] = byte(i * 7)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(0)
+	buf.Write(stop[:])
+	wire.WriteVarInt(&buf, 0, uint64(len(filter)))
+	buf.Write(filter)
+
+	// a filter that does not match its size is not recorded
+	invalid := append([]byte(nil), buf.Bytes()[:buf.Len()-1]...)
+	send(records.CmdCFilter, invalid)
+	send(records.CmdCFilter, buf.Bytes())
+
+	cfilter, ok := waitRecord(t, mgr.pro,
+		records.CmdCFilter).(*records.CFilterRecord)
+	if !ok || cfilter.BlockHash() != stop ||
+		!bytes.Equal(cfilter.Filter(), filter) {
+		t.Errorf("recorded cfilter %v", cfilter)
+	}
+
+	buf.Reset()
+	buf.WriteByte(0)
+	buf.Write(stop[:])
+	buf.Write(prev[:])
+	wire.WriteVarInt(&buf, 0, 2)
+	buf.Write(bytes.Repeat([]byte{1}, 32))
+	buf.Write(bytes.Repeat([]byte{2}, 32))
+	send(records.CmdCFHeaders, buf.Bytes())
+
+	headers, ok := waitRecord(t, mgr.pro,
+		records.CmdCFHeaders).(*records.CFHeadersRecord)
+	if !ok || headers.StopHash() != stop ||
+		headers.PreviousHeader() != prev ||
+		len(headers.FilterHashes()) != 2 ||
+		headers.FilterHashes()[1][31] != 2 {
+		t.Errorf("recorded cfheaders %v", headers)
+	}
+
+	count := 0
+	for _, record := range mgr.pro.Records() {
+		if record.Command() == records.CmdCFilter {
+			count++
+		}
+	}
+
+	if count != 1 {
+		t.Errorf("recorded %v cfilter messages", count)
+	}
+}
+
+func TestCompactFilterRequests(t *testing.T) {
+	tip := wire.ShaHash{1}
+	p, far, _, err := newTestPeer(SetTracker(testTracker{height: 100,
+		hash: tip}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer far.Close()
+
+	// filters are never requested from peers that don't serve them
+	p.pushGetCFilters()
+	if len(p.frameQ) != 0 {
+		t.Fatal("filters requested without the service flag")
+	}
+
+	atomic.StoreUint64(&p.services, uint64(sfNodeCompactFilters))
+	p.pushGetCFilters()
+	if len(p.frameQ) != 2 {
+		t.Fatalf("%v requests for the tip", len(p.frameQ))
+	}
+
+	for _, command := range []string{records.CmdGetCFilters,
+		cmdGetCFHeaders} {
+		frame := <-p.frameQ
+		if frame.command != command || frame.payload[0] != filterBasic ||
+			binary.LittleEndian.Uint32(frame.payload[1:5]) != 100 ||
+			!bytes.Equal(frame.payload[5:], tip[:]) {
+			t.Errorf("requested %v %x", frame.command, frame.payload)
+		}
+	}
+
+	// the same tip is only requested once
+	p.pushGetCFilters()
+	if len(p.frameQ) != 0 {
+		t.Error("filters of the same tip requested twice")
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package peer
+
+import (
+	"sync"
+	"time"
+)
+
+// slot counts the traffic for one time slice of the meter window.
+type slot struct {
+	epoch int64
+	bytes uint64
+	msgs  uint64
+}
+
+// meter keeps track of the traffic received from a peer. Besides the totals,
+// it keeps the traffic of the last window in a ring of time slices, so we can
+// compute the rate over that window without storing every single message.
+type meter struct {
+	mutex *sync.Mutex
+	width time.Duration
+	slots []slot
+
+	bytes uint64
+	msgs  uint64
+}
+
+// newMeter creates a new meter computing rates over the given window, divided
+// in the given number of slices.
+func newMeter(window time.Duration, count int) *meter {
+	m := &meter{
+		mutex: &sync.Mutex{},
+		width: window / time.Duration(count),
+		slots: make([]slot, count),
+	}
+
+	return m
+}
+
+// add registers one message of the given size as received at the given time.
+func (m *meter) add(size int, now time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	epoch := now.UnixNano() / int64(m.width)
+	s := &m.slots[epoch%int64(len(m.slots))]
+	if s.epoch != epoch {
+		*s = slot{epoch: epoch}
+	}
+
+	s.bytes += uint64(size)
+	s.msgs++
+	m.bytes += uint64(size)
+	m.msgs++
+}
+
+// totals returns the total number of bytes and messages received.
+func (m *meter) totals() (uint64, uint64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.bytes, m.msgs
+}
+
+// rates returns the number of bytes and messages received per second over the
+// window ending at the given time.
+func (m *meter) rates(now time.Time) (float64, float64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	epoch := now.UnixNano() / int64(m.width)
+	oldest := epoch - int64(len(m.slots)) + 1
+
+	var bytes, msgs uint64
+	for _, s := range m.slots {
+		if s.epoch < oldest || s.epoch > epoch {
+			continue
+		}
+
+		bytes += s.bytes
+		msgs += s.msgs
+	}
+
+	seconds := (m.width * time.Duration(len(m.slots))).Seconds()
+
+	return float64(bytes) / seconds, float64(msgs) / seconds
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package peer
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMeterRates(t *testing.T) {
+	m := newMeter(10*time.Second, 10)
+	start := time.Unix(1000, 0)
+
+	// 1000 bytes every 100 milliseconds for ten seconds
+	now := start
+	for i := 0; i < 100; i++ {
+		m.add(1000, now)
+		now = now.Add(100 * time.Millisecond)
+	}
+
+	bytes, msgs := m.totals()
+	if bytes != 100000 || msgs != 100 {
+		t.Errorf("totals %v bytes and %v messages", bytes, msgs)
+	}
+
+	byteRate, msgRate := m.rates(now.Add(-time.Millisecond))
+	if math.Abs(byteRate-10000) > 1000 || math.Abs(msgRate-10) > 1 {
+		t.Errorf("rates %v B/s and %v msg/s, want 10000 and 10", byteRate,
+			msgRate)
+	}
+
+	// once the traffic is out of the window, the rate goes back to zero
+	byteRate, msgRate = m.rates(now.Add(20 * time.Second))
+	if byteRate != 0 || msgRate != 0 {
+		t.Errorf("rates %v B/s and %v msg/s after silence", byteRate,
+			msgRate)
+	}
+}
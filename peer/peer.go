@@ -21,8 +21,12 @@
 package peer
 
 import (
+	"encoding/binary"
 	"errors"
+	"io"
 	"net"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -31,6 +35,7 @@ import (
 
 	"github.com/CIRCL/pbtc/adaptor"
 	"github.com/CIRCL/pbtc/convertor"
+	"github.com/CIRCL/pbtc/records"
 	"github.com/CIRCL/pbtc/util"
 )
 
@@ -49,10 +54,29 @@ const (
 	timeoutPing  = 1 * time.Minute
 	timeoutIdle  = 3 * time.Minute
 	timeoutDrain = 2 * time.Second
+	meterWindow  = 1 * time.Minute
+	meterSlots   = 12
 	agentName    = "Satoshi"
 	agentVersion = "0.9.3"
 )
 
+// Service flags that btcd does not define yet, and the flag that marks the
+// witness variants of inventory types.
+const (
+	sfNodeBloom          wire.ServiceFlag = 1 << 2
+	sfNodeWitness        wire.ServiceFlag = 1 << 3
+	sfNodeCompactFilters wire.ServiceFlag = 1 << 6
+	sfNodeNetworkLimited wire.ServiceFlag = 1 << 10
+	invWitnessFlag       wire.InvType     = 1 << 30
+)
+
+// The stages of the handshake that can time out, recorded as detail of the
+// disconnect.
+const (
+	stageVersion = "version"
+	stageVerAck  = "verack"
+)
+
 // Peer represents a single peer that we communicate with on the network. It
 // groups together all necessary parameters, as well as queues and communication
 // functions.
@@ -61,27 +85,69 @@ type Peer struct {
 	sigSend    chan struct{}
 	sigRecv    chan struct{}
 	sigProcess chan struct{}
+	sigShake   chan struct{}
 	sendQ      chan wire.Message
+	frameQ     chan *unknownFrame
 	recvQ      chan wire.Message
 
 	log     adaptor.Log
 	mgr     adaptor.Manager
-	recs    []adaptor.Processor
 	repo    adaptor.Repository
 	tracker adaptor.Tracker
+	dialer  *Dialer
+	clock   func() time.Time
 
 	network wire.BitcoinNet
 	version uint32
 	nonce   uint64
 	addr    *net.TCPAddr
-	conn    *net.TCPConn
+	conn    net.Conn
 	me      *wire.NetAddress
 	you     *wire.NetAddress
+	meter   *meter
+	passive bool
+	relay   bool
+	maxAge  time.Duration
+	limit   int
+	alive   time.Duration
+	raw     bool
+	minimum uint32
+	inbound bool
+	checked bool
+	msgCap  uint64
+
+	learnAddr    bool
+	learnVersion bool
+	learnInbound bool
+
+	versionTimeout   time.Duration
+	handshakeTimeout time.Duration
+
+	routines  int32
+	connected int64
+	unknown   uint64
+	relayed   uint32
+	useful    uint64
+	recorded  uint64
+	lastUsed  int64
+	services  uint64
+
+	cmdMutex *sync.Mutex
+	commands map[string]uint64
+	rejected string
+	agent    string
+	reason   string
+	latency  adaptor.Latency
+	filtered wire.ShaHash
+
+	pingNonce uint64
+	pingSent  int64
 
 	started uint32
 	done    uint32
 	sent    uint32
 	rcvd    uint32
+	ready   uint32
 }
 
 // New creates a new Peer with the given options. Communication on state is done
@@ -92,8 +158,19 @@ func New(options ...func(*Peer)) (*Peer, error) {
 		sigSend:    make(chan struct{}),
 		sigRecv:    make(chan struct{}),
 		sigProcess: make(chan struct{}),
+		sigShake:   make(chan struct{}),
 		sendQ:      make(chan wire.Message, 1),
+		frameQ:     make(chan *unknownFrame, 2),
 		recvQ:      make(chan wire.Message, 1),
+		meter:      newMeter(meterWindow, meterSlots),
+		clock:      time.Now,
+		relay:      true,
+		checked:    true,
+		cmdMutex:   &sync.Mutex{},
+		commands:   make(map[string]uint64),
+
+		learnAddr:    true,
+		learnInbound: true,
 
 		network: wire.TestNet3,
 		version: wire.RejectVersion,
@@ -104,6 +181,10 @@ func New(options ...func(*Peer)) (*Peer, error) {
 		option(p)
 	}
 
+	if p.dialer == nil {
+		p.dialer = NewDialer()
+	}
+
 	// we need either an address to connect to or an established connection
 	if p.addr == nil && p.conn == nil {
 		return nil, errors.New("Must provide address or connection")
@@ -116,12 +197,14 @@ func New(options ...func(*Peer)) (*Peer, error) {
 
 	// if we have a connection, we will try to parse the address from it
 	// a peer always will have an address associated with it
-	addr, ok := p.conn.RemoteAddr().(*net.TCPAddr)
-	if !ok {
+	addr := tcpAddr(p.conn.RemoteAddr())
+	if addr == nil {
 		return nil, errors.New("Could not parse remote address from connection")
 	}
 
 	p.addr = addr
+	p.keepAlive()
+	atomic.StoreInt64(&p.connected, time.Now().UnixNano())
 
 	err := p.parse()
 	if err != nil {
@@ -145,13 +228,6 @@ func SetManager(mgr adaptor.Manager) func(*Peer) {
 	}
 }
 
-// SetRecorder injects the recorder to be used to log events on this connection.
-func SetProcessors(recs []adaptor.Processor) func(*Peer) {
-	return func(p *Peer) {
-		p.recs = recs
-	}
-}
-
 // SetRepository injects the repository to notify about newly discovered peers.
 func SetRepository(repo adaptor.Repository) func(*Peer) {
 	return func(p *Peer) {
@@ -168,7 +244,8 @@ func SetNetwork(network wire.BitcoinNet) func(*Peer) {
 }
 
 // SetVersion sets the maximum supported Bitcoin protocol version that we will
-// use to communicate with this peer.
+// use to communicate with this peer. It is the version we advertise, so peers
+// only send messages of later versions, like feefilter, if it is high enough.
 func SetVersion(version uint32) func(*Peer) {
 	return func(p *Peer) {
 		p.version = version
@@ -190,9 +267,10 @@ func SetAddress(addr *net.TCPAddr) func(*Peer) {
 	}
 }
 
-// SetConnection sets an established TCP connection that this peer will use
-// for his handshake.
-func SetConnection(conn *net.TCPConn) func(*Peer) {
+// SetConnection sets an established connection that this peer will use for
+// his handshake. It does not need to be a TCP connection, but its addresses
+// have to be in host:port format.
+func SetConnection(conn net.Conn) func(*Peer) {
 	return func(p *Peer) {
 		p.conn = conn
 	}
@@ -206,6 +284,172 @@ func SetTracker(tracker adaptor.Tracker) func(*Peer) {
 	}
 }
 
+// SetDialer sets the dialer used to establish the connection, which allows
+// connecting through proxies.
+func SetDialer(dialer *Dialer) func(*Peer) {
+	return func(p *Peer) {
+		p.dialer = dialer
+	}
+}
+
+// SetClock sets the function used to timestamp the records of this peer. It
+// defaults to the current time and can be replaced for replays or tests.
+func SetClock(clock func() time.Time) func(*Peer) {
+	return func(p *Peer) {
+		p.clock = clock
+	}
+}
+
+// SetPassive makes the peer strictly passive. It will only send the messages
+// required by the protocol to stay connected, which are the version & verack
+// messages for the handshake and pong messages in reply to pings. All other
+// messages, like polling for addresses or requesting inventory, are disabled.
+func SetPassive(passive bool) func(*Peer) {
+	return func(p *Peer) {
+		p.passive = passive
+	}
+}
+
+// SetAddrMaxAge sets the maximum age of the entries of address messages that
+// are passed on to the repository. Entries with an older timestamp are stale
+// and skipped. Zero means entries of any age are accepted.
+func SetAddrMaxAge(maxAge time.Duration) func(*Peer) {
+	return func(p *Peer) {
+		p.maxAge = maxAge
+	}
+}
+
+// SetAddrLimit sets the maximum number of entries of a single address message
+// that are passed on to the repository. Further entries are skipped. Zero
+// means all entries are accepted.
+func SetAddrLimit(limit int) func(*Peer) {
+	return func(p *Peer) {
+		p.limit = limit
+	}
+}
+
+// SetTCPKeepAlive enables TCP keepalive on the connection of the peer, with
+// the given idle time before the first probe. It lets the operating system
+// detect half-open connections to peers that vanished behind a NAT. Zero leaves
+// the connection as it is.
+func SetTCPKeepAlive(idle time.Duration) func(*Peer) {
+	return func(p *Peer) {
+		p.alive = idle
+	}
+}
+
+// SetMinProtocolVersion sets the lowest protocol version we accept from peers.
+// Peers advertising an older version in their version message are disconnected
+// during the handshake, which is recorded, and reported to the repository.
+func SetMinProtocolVersion(version uint32) func(*Peer) {
+	return func(p *Peer) {
+		p.minimum = version
+	}
+}
+
+// SetVersionTimeout sets the time within which the peer has to send its
+// version message once the connection is established. Zero means there is no
+// limit.
+func SetVersionTimeout(timeout time.Duration) func(*Peer) {
+	return func(p *Peer) {
+		p.versionTimeout = timeout
+	}
+}
+
+// SetHandshakeTimeout sets the time within which the handshake has to be
+// complete once the connection is established. Zero means there is no limit.
+func SetHandshakeTimeout(timeout time.Duration) func(*Peer) {
+	return func(p *Peer) {
+		p.handshakeTimeout = timeout
+	}
+}
+
+// SetInbound marks the peer as having connected to us, rather than us having
+// connected to it.
+func SetInbound(inbound bool) func(*Peer) {
+	return func(p *Peer) {
+		p.inbound = inbound
+	}
+}
+
+// SetAddrSources sets where the peer learns new addresses from: the entries
+// of address messages, the address the peer advertises for itself in its
+// version message, and whether any of these are taken from inbound peers at
+// all. Not learning from inbound peers makes it harder for an attacker to feed
+// us poisoned addresses. By default, we learn from address messages of all
+// peers.
+func SetAddrSources(addr bool, version bool, inbound bool) func(*Peer) {
+	return func(p *Peer) {
+		p.learnAddr = addr
+		p.learnVersion = version
+		p.learnInbound = inbound
+	}
+}
+
+// SetServiceChecks sets whether the data we request from the peer depends on
+// the services it advertised in its version message. With checks, we only ask
+// for blocks if the peer serves them, for filtered blocks if it supports bloom
+// filters and for witness data if it supports segregated witness, so we do
+// not waste bandwidth on requests it can not satisfy or look abusive. Checks
+// are enabled by default.
+func SetServiceChecks(check bool) func(*Peer) {
+	return func(p *Peer) {
+		p.checked = check
+	}
+}
+
+// SetRecordRaw makes the peer emit a raw record with the hex dump of the
+// payload for every well-framed message with a command we do not support,
+// instead of silently skipping it.
+func SetRecordRaw(raw bool) func(*Peer) {
+	return func(p *Peer) {
+		p.raw = raw
+	}
+}
+
+// SetMessageCap limits the records of received messages to the given number,
+// so that we only record the opening of each connection. The peer stays
+// connected and keeps handling messages, like addresses, but the records are
+// no longer sent to the processors; the disconnect record still is. Zero means
+// there is no limit.
+func SetMessageCap(limit uint64) func(*Peer) {
+	return func(p *Peer) {
+		p.msgCap = limit
+	}
+}
+
+// SetRelay sets the relay flag of our version message. If it is set, the peer
+// will announce new transactions to us without being asked; this is the
+// default, as we want to see as much transaction traffic as possible.
+func SetRelay(relay bool) func(*Peer) {
+	return func(p *Peer) {
+		p.relay = relay
+	}
+}
+
+// keepAliveConn is a connection that supports TCP keepalive, like the TCP
+// connections of the net package.
+type keepAliveConn interface {
+	SetKeepAlive(keepalive bool) error
+	SetKeepAlivePeriod(d time.Duration) error
+}
+
+// keepAlive enables TCP keepalive on the connection if configured. Connections
+// that are not TCP, like in-memory ones, are left alone.
+func (p *Peer) keepAlive() {
+	if p.alive == 0 {
+		return
+	}
+
+	conn, ok := p.conn.(keepAliveConn)
+	if !ok {
+		return
+	}
+
+	conn.SetKeepAlive(true)
+	conn.SetKeepAlivePeriod(p.alive)
+}
+
 // String returns the address of this peer as string value.
 func (p *Peer) String() string {
 	return p.addr.String()
@@ -216,8 +460,50 @@ func (p *Peer) Addr() *net.TCPAddr {
 	return p.addr
 }
 
+// Stats returns a snapshot of the traffic received from this peer.
+func (p *Peer) Stats() adaptor.PeerStats {
+	bytes, msgs := p.meter.totals()
+	byteRate, msgRate := p.meter.rates(time.Now())
+
+	stats := adaptor.PeerStats{
+		Address:      p.String(),
+		BytesRead:    bytes,
+		MessagesRead: msgs,
+		ByteRate:     byteRate,
+		MessageRate:  msgRate,
+		Routines:     int(atomic.LoadInt32(&p.routines)),
+		Unknown:      atomic.LoadUint64(&p.unknown),
+		Relay:        atomic.LoadUint32(&p.relayed) == 1,
+		Inbound:      p.inbound,
+		Useful:       atomic.LoadUint64(&p.useful),
+		Commands:     make(map[string]uint64),
+	}
+
+	lastUsed := atomic.LoadInt64(&p.lastUsed)
+	if lastUsed != 0 {
+		stats.LastUseful = time.Unix(0, lastUsed)
+	}
+
+	p.cmdMutex.Lock()
+	for cmd, count := range p.commands {
+		stats.Commands[cmd] = count
+	}
+	stats.Latency = p.latency
+	stats.UserAgent = p.agent
+	stats.Reason = p.reason
+	p.cmdMutex.Unlock()
+
+	connected := atomic.LoadInt64(&p.connected)
+	if connected != 0 {
+		stats.Connected = time.Unix(0, connected)
+	}
+
+	return stats
+}
+
 // Connect will try to start a connection attempt in a non-blocking manner.
 func (p *Peer) Connect() {
+	atomic.AddInt32(&p.routines, 1)
 	go p.connect()
 }
 
@@ -231,6 +517,20 @@ func (p *Peer) Stop() {
 	go p.shutdown()
 }
 
+// Drop stops the peer like Stop, but first records that we closed the
+// connection for the given reason. Stopping closes the connection only after
+// the routines are done, so the receive routine never sees the disconnect.
+// Nothing is recorded if the peer never connected or is already stopping.
+func (p *Peer) Drop(reason string) {
+	if atomic.LoadInt64(&p.connected) != 0 &&
+		atomic.LoadUint32(&p.done) == 0 {
+		p.log.Debug("[PEER] %v: dropped (%v)", p, reason)
+		p.record(reason, "")
+	}
+
+	p.Stop()
+}
+
 // Greet will queue a greeting message to this peer, used to conform to the
 // protocol.
 func (p *Peer) Greet() {
@@ -245,6 +545,8 @@ func (p *Peer) Poll() {
 // connect will try to connect to the address of the peer, if there is not
 // yet a connection that has been established
 func (p *Peer) connect() {
+	defer atomic.AddInt32(&p.routines, -1)
+
 	if atomic.LoadUint32(&p.done) != 0 {
 		p.log.Debug("[PEER] %v can't connect when done", p)
 		return
@@ -255,21 +557,13 @@ func (p *Peer) connect() {
 		return
 	}
 
-	connGen, err := net.DialTimeout("tcp", p.addr.String(), timeoutDial)
+	conn, err := p.dialer.Dial(p.addr, timeoutDial)
 	if err != nil {
 		p.log.Debug("[PEER] %v connection failed (%v)", p, err)
 		p.shutdown()
 		return
 	}
 
-	// we only care about TCP connections, but this should never fail
-	conn, ok := connGen.(*net.TCPConn)
-	if !ok {
-		p.log.Debug("[PEER] %v connection type assert failed", p)
-		p.shutdown()
-		return
-	}
-
 	// if the peer was stoppe while trying to connect, we can discard everything
 	if atomic.LoadUint32(&p.done) == 1 {
 		p.log.Debug("[PEER] %v connection late", p)
@@ -279,6 +573,8 @@ func (p *Peer) connect() {
 	}
 
 	p.conn = conn
+	p.keepAlive()
+	atomic.StoreInt64(&p.connected, time.Now().UnixNano())
 
 	err = p.parse()
 	if err != nil {
@@ -297,9 +593,16 @@ func (p *Peer) startup() {
 	}
 
 	p.wg.Add(3)
+	atomic.AddInt32(&p.routines, 3)
 	go p.goSend()
 	go p.goReceive()
 	go p.goProcess()
+
+	if p.versionTimeout > 0 || p.handshakeTimeout > 0 {
+		p.wg.Add(1)
+		atomic.AddInt32(&p.routines, 1)
+		go p.goHandshake()
+	}
 }
 
 func (p *Peer) shutdown() {
@@ -310,6 +613,7 @@ func (p *Peer) shutdown() {
 	close(p.sigRecv)
 	close(p.sigProcess)
 	close(p.sigSend)
+	close(p.sigShake)
 
 	p.wg.Wait()
 
@@ -317,6 +621,7 @@ func (p *Peer) shutdown() {
 		p.conn.Close()
 	}
 
+	p.tracker.RemovePeer(p.addr.String())
 	p.mgr.Stopped(p)
 }
 
@@ -331,8 +636,8 @@ func (p *Peer) parse() error {
 		return err
 	}
 
-	local, ok := p.conn.LocalAddr().(*net.TCPAddr)
-	if !ok {
+	local := tcpAddr(p.conn.LocalAddr())
+	if local == nil {
 		return errors.New("could not parse local address from connection")
 	}
 
@@ -359,8 +664,43 @@ func (p *Peer) sendMessage(msg wire.Message) error {
 // recvMessage is used internally to receive a message; it blocks for timeout
 func (p *Peer) recvMessage() (wire.Message, error) {
 	p.conn.SetReadDeadline(time.Now().Add(timeoutRecv))
+	r, frame, err := readFrame(p.conn, p.network, p.raw)
+	if err == errWitnessTx {
+		p.meter.add(frame.size, time.Now())
+		return p.processSegwitTx(frame)
+	}
+	if err == errUnknownCommand {
+		p.meter.add(frame.size, time.Now())
+		switch frame.command {
+		case records.CmdFeeFilter:
+			p.processFeeFilter(frame)
+			return nil, errHandledCommand
+
+		case records.CmdGetCFilters:
+			p.processGetCFilters(frame)
+			return nil, errHandledCommand
+
+		case records.CmdCFilter:
+			p.processCFilter(frame)
+			return nil, errHandledCommand
+
+		case records.CmdCFHeaders:
+			p.processCFHeaders(frame)
+			return nil, errHandledCommand
+		}
+
+		atomic.AddUint64(&p.unknown, 1)
+		p.processRaw(frame)
+	}
+	if err != nil {
+		return nil, err
+	}
+
 	version := atomic.LoadUint32(&p.version)
-	msg, _, err := wire.ReadMessage(p.conn, version, p.network)
+	msg, payload, err := wire.ReadMessage(r, version, p.network)
+	if err == nil {
+		p.meter.add(wire.MessageHeaderSize+len(payload), time.Now())
+	}
 
 	return msg, err
 }
@@ -369,6 +709,7 @@ func (p *Peer) recvMessage() (wire.Message, error) {
 // wire
 func (p *Peer) goSend() {
 	defer p.wg.Done()
+	defer atomic.AddInt32(&p.routines, -1)
 
 	p.log.Debug("[PEER] %v send routine started", p)
 
@@ -401,6 +742,19 @@ SendLoop:
 				break SendLoop
 			}
 
+			idleTimer.Reset(timeoutPing)
+
+		// messages the wire package does not support are sent as frames
+		case frame := <-p.frameQ:
+			err := writeFrame(p.conn, p.network, frame)
+			if e, ok := err.(net.Error); ok && e.Timeout() {
+				continue
+			}
+			if err != nil {
+				p.log.Debug("[PEER] %v: disconnected (%v)", p, err)
+				break SendLoop
+			}
+
 			idleTimer.Reset(timeoutPing)
 		}
 	}
@@ -420,6 +774,9 @@ DrainLoop:
 
 		case <-p.sendQ:
 			p.log.Debug("[PEER] %v drained message", p)
+
+		case <-p.frameQ:
+			p.log.Debug("[PEER] %v drained frame", p)
 		}
 	}
 
@@ -429,6 +786,7 @@ DrainLoop:
 // goReceive handles incoming messages and queues them for processing
 func (p *Peer) goReceive() {
 	defer p.wg.Done()
+	defer atomic.AddInt32(&p.routines, -1)
 
 	p.log.Debug("[PEER] %v receive routine started", p)
 
@@ -445,6 +803,7 @@ ReceiveLoop:
 		// if we haven't received a message in a while, disconnect the peer
 		case <-idleTimer.C:
 			p.log.Debug("[PEER] %v: peer timed out", p)
+			p.disconnected(records.ReasonTimeout, "idle")
 			break ReceiveLoop
 
 		// try to receive a message and put in on the receive queue
@@ -453,15 +812,34 @@ ReceiveLoop:
 			if e, ok := err.(net.Error); ok && e.Timeout() {
 				continue
 			}
+			if err == errUnknownCommand {
+				p.log.Debug("[PEER] %v: skipped unknown command (%v total)",
+					p, atomic.LoadUint64(&p.unknown))
+				idleTimer.Reset(timeoutIdle)
+				continue
+			}
+			if err == errHandledCommand {
+				idleTimer.Reset(timeoutIdle)
+				continue
+			}
 			if _, ok := err.(*wire.MessageError); ok {
 				p.log.Debug("[PEER] %v: received ignored (%v)", p, err)
 				continue
 			}
 			if err != nil {
 				p.log.Debug("[PEER] %v : disconnected (%v)", p, err)
+				p.disconnected(classifyDisconnect(err), err.Error())
 				break ReceiveLoop
 			}
 
+			// remember why the peer rejected us, as it might disconnect
+			// next, before the message is even processed
+			if m, ok := msg.(*wire.MsgReject); ok {
+				p.cmdMutex.Lock()
+				p.rejected = m.Cmd + ": " + m.Reason
+				p.cmdMutex.Unlock()
+			}
+
 			idleTimer.Reset(timeoutIdle)
 			p.recvQ <- msg
 		}
@@ -472,11 +850,68 @@ ReceiveLoop:
 	p.log.Debug("[PEER] %v receive routine stopped", p)
 }
 
+// goHandshake disconnects the peer if it does not send its version message or
+// complete the handshake in time. The stage that timed out is recorded as
+// detail of the disconnect, so stalling peers can be told apart.
+func (p *Peer) goHandshake() {
+	defer p.wg.Done()
+	defer atomic.AddInt32(&p.routines, -1)
+
+	var versionC <-chan time.Time
+	if p.versionTimeout > 0 {
+		versionT := time.NewTimer(p.versionTimeout)
+		defer versionT.Stop()
+		versionC = versionT.C
+	}
+
+	var handshakeC <-chan time.Time
+	if p.handshakeTimeout > 0 {
+		handshakeT := time.NewTimer(p.handshakeTimeout)
+		defer handshakeT.Stop()
+		handshakeC = handshakeT.C
+	}
+
+	for versionC != nil || handshakeC != nil {
+		select {
+		case <-p.sigShake:
+			return
+
+		case <-versionC:
+			versionC = nil
+			if atomic.LoadUint32(&p.rcvd) == 1 {
+				continue
+			}
+
+			p.log.Debug("[PEER] %v: version timed out", p)
+			p.disconnected(records.ReasonTimeout, stageVersion)
+			p.Stop()
+			return
+
+		case <-handshakeC:
+			handshakeC = nil
+			if atomic.LoadUint32(&p.ready) == 1 {
+				return
+			}
+
+			stage := stageVerAck
+			if atomic.LoadUint32(&p.rcvd) == 0 {
+				stage = stageVersion
+			}
+
+			p.log.Debug("[PEER] %v: handshake timed out (%v)", p, stage)
+			p.disconnected(records.ReasonTimeout, stage)
+			p.Stop()
+			return
+		}
+	}
+}
+
 // goProcess processes the messages in the receive queue
 // we had to separate it from the reception handler so that messages wouldn't
 // start queuing directly on the os socket
 func (p *Peer) goProcess() {
 	defer p.wg.Done()
+	defer atomic.AddInt32(&p.routines, -1)
 ProcessLoop:
 	for {
 		select {
@@ -507,16 +942,90 @@ DrainRecvLoop:
 	}
 }
 
+// processFeeFilter decodes a feefilter message, which the wire package does
+// not support, reports the fee to the tracker and forwards it to the
+// processors. Its payload is the fee in satoshis per kilobyte.
+func (p *Peer) processFeeFilter(frame *unknownFrame) {
+	if len(frame.payload) != 8 {
+		p.log.Debug("[PEER] %v: invalid feefilter message", p)
+		return
+	}
+
+	fee := int64(binary.LittleEndian.Uint64(frame.payload))
+	p.markUseful()
+	p.tracker.ReportFeeFilter(p.addr.String(), fee)
+
+	la := tcpAddr(p.conn.LocalAddr())
+	if la == nil {
+		return
+	}
+
+	record := records.NewFeeFilterRecord(fee, p.addr, la, p.clock())
+	p.forwardFrame(record)
+}
+
+// processRaw forwards a message with a command we do not support to the
+// processors, if its payload was kept.
+func (p *Peer) processRaw(frame *unknownFrame) {
+	if frame.payload == nil {
+		return
+	}
+
+	la := tcpAddr(p.conn.LocalAddr())
+	if la == nil {
+		return
+	}
+
+	record := records.NewRawRecord(frame.command, frame.payload, p.addr, la,
+		p.clock())
+	p.forward(record)
+}
+
+// forward sends the record of a received message to the processors, unless
+// the message cap of the peer is reached.
+func (p *Peer) forward(record adaptor.Record) {
+	if p.msgCap > 0 {
+		recorded := atomic.AddUint64(&p.recorded, 1)
+		if recorded == p.msgCap+1 {
+			p.log.Debug("%v: message cap of %v reached, no longer recording",
+				p, p.msgCap)
+		}
+
+		if recorded > p.msgCap {
+			return
+		}
+	}
+
+	for _, rec := range p.mgr.Processors() {
+		rec.Process(record)
+	}
+}
+
 // processMessage does basic processing of the message to be in conformity
 // with the bitcoin protocol and then forwards it to the respective filters
 func (p *Peer) processMessage(msg wire.Message) {
-	ra, ok1 := p.conn.RemoteAddr().(*net.TCPAddr)
-	la, ok2 := p.conn.LocalAddr().(*net.TCPAddr)
-	if ok1 && ok2 {
-		record := convertor.Message(msg, ra, la)
-		for _, rec := range p.recs {
-			rec.Process(record)
+	// transactions with witness are handled as stripped ones, with the witness
+	// added to their record
+	tx, witness := msg.(*segwitTx)
+	if witness {
+		msg = tx.MsgTx
+	}
+
+	// the remote address of the connection is the proxy if we use one, so we
+	// always use the address of the peer for the records
+	la := tcpAddr(p.conn.LocalAddr())
+	if la != nil {
+		record := convertor.Message(msg, p.addr, la, p.clock())
+		if tr, ok := record.(*records.TransactionRecord); ok && witness {
+			tr.SetWitness(tx.wtxid, tx.sizes)
 		}
+
+		p.trackMessage(msg, record)
+		p.forward(record)
+
+		p.cmdMutex.Lock()
+		p.commands[record.Command()]++
+		p.cmdMutex.Unlock()
 	}
 
 	// if we have not yet received a version message and we receive any other
@@ -530,6 +1039,8 @@ func (p *Peer) processMessage(msg wire.Message) {
 		}
 	}
 
+	p.countUseful(msg)
+
 	// we read a message from the queue, process it depending on type
 	switch m := msg.(type) {
 
@@ -554,6 +1065,43 @@ func (p *Peer) processMessage(msg wire.Message) {
 			return
 		}
 
+		if uint32(m.ProtocolVersion) < p.minimum {
+			p.log.Debug("[PEER] %v: protocol version %v below minimum", p,
+				m.ProtocolVersion)
+			p.closed(records.ReasonOutdated,
+				strconv.FormatInt(int64(m.ProtocolVersion), 10))
+			p.Stop()
+			return
+		}
+
+		p.cmdMutex.Lock()
+		p.agent = m.UserAgent
+		p.cmdMutex.Unlock()
+
+		// the services decide which data we can request from the peer
+		atomic.StoreUint64(&p.services, uint64(m.Services))
+
+		// the start height helps the tracker follow the best chain
+		p.tracker.ReportHeight(p.addr.String(), m.LastBlock)
+
+		// flag nodes that advertise an address other than the one we see
+		advertised := util.ParseNetAddress(&m.AddrMe)
+		if util.IsMisadvertised(p.addr, advertised) {
+			p.repo.Advertised(p.addr, advertised)
+		}
+
+		// the address has no timestamp, so we only check that it is usable
+		if p.learns(p.learnVersion) && util.IsRoutable(m.AddrMe.IP) &&
+			m.AddrMe.Port != 0 {
+			p.repo.Discovered(advertised, p.addr, p.clock())
+		}
+
+		// remember whether the peer wants transactions relayed to it, which
+		// also tells us whether it will announce its transactions to others
+		if !m.DisableRelayTx {
+			atomic.StoreUint32(&p.relayed, 1)
+		}
+
 		// synchronize our protocol version to lowest supported one
 		version := atomic.LoadUint32(&p.version)
 		version = util.MinUint32(version, uint32(m.ProtocolVersion))
@@ -564,17 +1112,17 @@ func (p *Peer) processMessage(msg wire.Message) {
 
 		// if we have not sent our version yet, do so
 		// if we have, the handshake is now complete
-		if atomic.SwapUint32(&p.sent, 1) != 1 {
+		if atomic.LoadUint32(&p.sent) != 1 {
 			p.pushVersion()
 		} else {
-			p.mgr.Ready(p)
+			p.handshaken()
 		}
 
 	// verack messages only matter if we are waiting to finish handshake
 	// if we have both received and sent version, it is complete
 	case *wire.MsgVerAck:
 		if atomic.LoadUint32(&p.sent) == 1 && atomic.LoadUint32(&p.rcvd) == 1 {
-			p.mgr.Ready(p)
+			p.handshaken()
 		}
 
 	// only send a pong message if the protocol version expects it
@@ -583,24 +1131,48 @@ func (p *Peer) processMessage(msg wire.Message) {
 			p.pushPong(m.Nonce)
 		}
 
+	// measure the round-trip time if the pong answers our last ping
 	case *wire.MsgPong:
+		p.measurePong(m.Nonce)
 
 	case *wire.MsgGetAddr:
 
 	// if we get an address message, add the addresses to the repository
+	// a full batch means the peer shared all it had, so let the manager know
 	case *wire.MsgAddr:
+		accepted := 0
 		for _, na := range m.AddrList {
+			if !p.learns(p.learnAddr) {
+				break
+			}
+
+			if p.limit > 0 && accepted >= p.limit {
+				break
+			}
+
+			if !p.acceptAddr(na) {
+				continue
+			}
+
 			addr := util.ParseNetAddress(na)
-			p.repo.Discovered(addr)
+			p.repo.Discovered(addr, p.addr, na.Timestamp)
+			accepted++
 		}
 
-	// if we get an inventory message, ask for the inventory
+		if len(m.AddrList) >= wire.MaxAddrPerMsg {
+			p.mgr.Harvested(p)
+		}
+
+	// if we get an inventory message, ask for the inventory, and for the
+	// filters of the new tip if it advanced
 	case *wire.MsgInv:
 		p.pushGetData(m)
+		p.pushGetCFilters()
 
 	case *wire.MsgGetHeaders:
 
 	case *wire.MsgHeaders:
+		p.pushGetCFilters()
 
 	case *wire.MsgGetBlocks:
 
@@ -621,6 +1193,193 @@ func (p *Peer) processMessage(msg wire.Message) {
 	}
 }
 
+// handshaken marks the handshake as complete and lets the manager know. The
+// time without useful messages is counted from here.
+func (p *Peer) handshaken() {
+	atomic.StoreInt64(&p.lastUsed, p.clock().UnixNano())
+	atomic.StoreUint32(&p.ready, 1)
+	p.mgr.Ready(p)
+	p.pushGetCFilters()
+}
+
+// countUseful counts the messages that carry information, which excludes the
+// handshake and keeping the connection alive, and remembers when the last one
+// was received. This allows spotting peers that never relay anything.
+func (p *Peer) countUseful(msg wire.Message) {
+	switch msg.(type) {
+	case *wire.MsgVersion, *wire.MsgVerAck, *wire.MsgPing, *wire.MsgPong:
+		return
+	}
+
+	p.markUseful()
+}
+
+// markUseful counts a message that carries information.
+func (p *Peer) markUseful() {
+	atomic.AddUint64(&p.useful, 1)
+	atomic.StoreInt64(&p.lastUsed, p.clock().UnixNano())
+}
+
+// learns returns whether we take addresses from the given source of this
+// peer, which depends on whether the peer is inbound.
+func (p *Peer) learns(source bool) bool {
+	return source && (p.learnInbound || !p.inbound)
+}
+
+// acceptAddr checks whether an entry of an address message is worth storing.
+// We skip entries that are stale or that we could never connect to anyway.
+func (p *Peer) acceptAddr(na *wire.NetAddress) bool {
+	if p.maxAge > 0 && p.clock().Sub(na.Timestamp) > p.maxAge {
+		return false
+	}
+
+	return util.IsRoutable(na.IP)
+}
+
+// disconnected records that the peer closed the connection on us and reports
+// it to the repository. If the peer rejected one of our messages before, the
+// rejection is given as reason instead of the connection error.
+func (p *Peer) disconnected(reason string, detail string) {
+	p.cmdMutex.Lock()
+	rejected := p.rejected
+	p.cmdMutex.Unlock()
+
+	if rejected != "" &&
+		(reason == records.ReasonEOF || reason == records.ReasonReset) {
+		reason = records.ReasonReject
+		detail = rejected
+	}
+
+	p.log.Debug("[PEER] %v: closed by peer (%v: %v)", p, reason, detail)
+
+	p.closed(reason, detail)
+}
+
+// closed records that the connection to the peer was closed for the given
+// reason and reports it to the repository as failure.
+func (p *Peer) closed(reason string, detail string) {
+	p.record(reason, detail)
+	p.repo.Failed(p.addr)
+}
+
+// record sends a disconnect record with the given reason to the processors.
+// It is not subject to the message cap. The first reason is kept for the
+// statistics of the peer.
+func (p *Peer) record(reason string, detail string) {
+	p.cmdMutex.Lock()
+	if p.reason == "" {
+		p.reason = reason
+	}
+	p.cmdMutex.Unlock()
+
+	la := tcpAddr(p.conn.LocalAddr())
+	if la == nil {
+		return
+	}
+
+	record := records.NewDisconnectRecord(reason, detail, p.addr, la,
+		p.clock())
+	for _, rec := range p.mgr.Processors() {
+		rec.Process(record)
+	}
+}
+
+// tcpAddr returns the given address as TCP address. Addresses of other types
+// are parsed from their host:port representation; nil is returned if that
+// fails.
+func tcpAddr(addr net.Addr) *net.TCPAddr {
+	tcp, ok := addr.(*net.TCPAddr)
+	if ok {
+		return tcp
+	}
+
+	tcp, err := net.ResolveTCPAddr("tcp", addr.String())
+	if err != nil {
+		return nil
+	}
+
+	return tcp
+}
+
+// classifyDisconnect returns the reason for the given error on reading from
+// the connection.
+func classifyDisconnect(err error) string {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return records.ReasonEOF
+	}
+
+	// the reset is wrapped differently depending on the platform, so we need
+	// to find out like this
+	if strings.Contains(err.Error(), "connection reset") {
+		return records.ReasonReset
+	}
+
+	e, ok := err.(net.Error)
+	if ok && e.Timeout() {
+		return records.ReasonTimeout
+	}
+
+	return records.ReasonError
+}
+
+// trackMessage registers announced and received transactions with the tracker
+// and adds the propagation details to transaction records. Announced blocks
+// are registered so the tracker can follow the best chain.
+func (p *Peer) trackMessage(msg wire.Message, record adaptor.Record) {
+	switch m := msg.(type) {
+	case *wire.MsgInv:
+		ir, ok := record.(*records.InventoryRecord)
+		if !ok {
+			return
+		}
+
+		// annotate each announced transaction with how long after its first
+		// sighting this peer announced it, for propagation studies
+		items := ir.Items()
+		for i, inv := range m.InvList {
+			if inv.Type == wire.InvTypeBlock {
+				p.tracker.AnnounceBlock(inv.Hash, p.addr.String(),
+					record.Timestamp())
+				continue
+			}
+
+			if inv.Type != wire.InvTypeTx {
+				continue
+			}
+
+			first := p.tracker.AnnounceTx(inv.Hash, p.addr.String(),
+				record.Timestamp())
+			items[i].SetSince(record.Timestamp().Sub(first))
+		}
+
+	// headers are announced in order, so each one extends the chain of the
+	// peer by one block
+	case *wire.MsgHeaders:
+		for _, header := range m.Headers {
+			p.tracker.AnnounceBlock(header.BlockSha(), p.addr.String(),
+				record.Timestamp())
+		}
+
+	// a received block completes its delivery for all peers announcing it
+	case *wire.MsgBlock:
+		p.tracker.DeliverBlock(m.BlockSha(), p.addr.String(),
+			record.Timestamp())
+
+	case *wire.MsgTx:
+		tx, ok := record.(*records.TransactionRecord)
+		if !ok {
+			return
+		}
+
+		s := p.tracker.SightTx(m.TxSha(), p.addr.String(),
+			record.Timestamp())
+		tx.SetPropagation(s.Duplicate, s.Peers, s.First)
+		if len(s.Announcers) > 0 {
+			tx.SetAnnouncers(s.Announcers)
+		}
+	}
+}
+
 func (p *Peer) pushVerAck() {
 	p.sendQ <- wire.NewMsgVerAck()
 }
@@ -634,12 +1393,43 @@ func (p *Peer) pushVersion() {
 	msg.AddUserAgent(agentName, agentVersion)
 	msg.AddrYou.Services = wire.SFNodeNetwork
 	msg.Services = wire.SFNodeNetwork
-	msg.ProtocolVersion = int32(wire.RejectVersion)
+	msg.ProtocolVersion = int32(atomic.LoadUint32(&p.version))
+	msg.DisableRelayTx = !p.relay
 	p.sendQ <- msg
 }
 
 func (p *Peer) pushPing() {
-	p.sendQ <- wire.NewMsgPing(p.nonce)
+	if p.passive {
+		return
+	}
+
+	nonce, err := wire.RandomUint64()
+	if err != nil {
+		nonce = p.nonce
+	}
+
+	atomic.StoreUint64(&p.pingNonce, nonce)
+	atomic.StoreInt64(&p.pingSent, p.clock().UnixNano())
+	p.sendQ <- wire.NewMsgPing(nonce)
+}
+
+// measurePong adds the round-trip time of our last ping to the latency
+// histogram, if the pong carries its nonce. Every ping is only measured once.
+func (p *Peer) measurePong(nonce uint64) {
+	if nonce != atomic.LoadUint64(&p.pingNonce) {
+		return
+	}
+
+	sent := atomic.SwapInt64(&p.pingSent, 0)
+	if sent == 0 {
+		return
+	}
+
+	rtt := p.clock().Sub(time.Unix(0, sent))
+
+	p.cmdMutex.Lock()
+	p.latency.Add(rtt)
+	p.cmdMutex.Unlock()
 }
 
 func (p *Peer) pushPong(nonce uint64) {
@@ -647,10 +1437,18 @@ func (p *Peer) pushPong(nonce uint64) {
 }
 
 func (p *Peer) pushGetAddr() {
+	if p.passive {
+		return
+	}
+
 	p.sendQ <- wire.NewMsgGetAddr()
 }
 
 func (p *Peer) pushAddr() {
+	if p.passive {
+		return
+	}
+
 	msg := wire.NewMsgAddr()
 	na, err := wire.NewNetAddress(p.conn.LocalAddr(), wire.SFNodeNetwork)
 	if err != nil {
@@ -662,9 +1460,17 @@ func (p *Peer) pushAddr() {
 }
 
 func (p *Peer) pushGetData(m *wire.MsgInv) {
+	if p.passive {
+		return
+	}
+
 	msg := wire.NewMsgGetData()
 
 	for _, inv := range m.InvList {
+		if !p.serves(inv.Type) {
+			continue
+		}
+
 		if inv.Type == 0 && p.tracker.KnowsBlock(inv.Hash) {
 			continue
 		}
@@ -673,8 +1479,43 @@ func (p *Peer) pushGetData(m *wire.MsgInv) {
 			continue
 		}
 
+		// transactions only come with their witness if we ask for it
+		if inv.Type == wire.InvTypeTx && p.servesWitness() {
+			inv = wire.NewInvVect(invWitnessFlag|wire.InvTypeTx, &inv.Hash)
+		}
+
 		msg.AddInvVect(inv)
 	}
 
+	if len(msg.InvList) == 0 {
+		return
+	}
+
 	p.sendQ <- msg
 }
+
+// serves returns whether the services advertised by the peer allow us to
+// request inventory of the given type from it. Transactions can be requested
+// from all peers, as relaying them is not a service.
+func (p *Peer) serves(invType wire.InvType) bool {
+	if !p.checked {
+		return true
+	}
+
+	services := wire.ServiceFlag(atomic.LoadUint64(&p.services))
+	if invType&invWitnessFlag != 0 && services&sfNodeWitness == 0 {
+		return false
+	}
+
+	blocks := services&(wire.SFNodeNetwork|sfNodeNetworkLimited) != 0
+	switch invType &^ invWitnessFlag {
+	case wire.InvTypeBlock:
+		return blocks
+
+	case wire.InvTypeFilteredBlock:
+		return blocks && services&sfNodeBloom != 0
+
+	default:
+		return true
+	}
+}
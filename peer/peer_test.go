@@ -0,0 +1,1014 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package peer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/CIRCL/pbtc/records"
+	"github.com/CIRCL/pbtc/tracker"
+)
+
+func TestDropRecordsDisconnect(t *testing.T) {
+	p, far, mgr, err := newTestPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer far.Close()
+
+	p.Drop(records.ReasonIdle)
+
+	select {
+	case <-mgr.stopped:
+	case <-time.After(time.Second):
+		t.Fatal("peer did not stop")
+	}
+
+	recs := mgr.pro.Records()
+	if len(recs) != 1 {
+		t.Fatalf("got %v records, want 1", len(recs))
+	}
+
+	dr, ok := recs[0].(*records.DisconnectRecord)
+	if !ok || dr.Reason() != records.ReasonIdle {
+		t.Errorf("got %v, want disconnect record for idle peer", recs[0])
+	}
+
+	// dropping a stopped peer records nothing more
+	p.Drop(records.ReasonIdle)
+	if len(mgr.pro.Records()) != 1 {
+		t.Error("disconnect recorded twice")
+	}
+}
+
+func TestClock(t *testing.T) {
+	stamp := time.Unix(1234567890, 0)
+	p, far, mgr, err := newTestPeer(SetClock(func() time.Time {
+		return stamp
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer far.Close()
+
+	readMessages(far)
+	p.Start()
+	defer p.Stop()
+
+	sendMessage(t, far, wire.NewMsgPing(1))
+	record := waitRecord(t, mgr.pro, "ping")
+	if !record.Timestamp().Equal(stamp) {
+		t.Errorf("stamped %v, want %v", record.Timestamp(), stamp)
+	}
+}
+
+func TestPassive(t *testing.T) {
+	p, far, _, err := newTestPeer(SetPassive(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer far.Close()
+
+	msgs := readMessages(far)
+	p.Start()
+	defer p.Stop()
+
+	p.Greet()
+	handshake(t, far, msgs, testVersion())
+
+	p.pushPing()
+	p.pushGetAddr()
+	p.pushAddr()
+
+	inv := wire.NewMsgInv()
+	inv.AddInvVect(wire.NewInvVect(wire.InvTypeTx, &wire.ShaHash{1}))
+	sendMessage(t, far, inv)
+	sendMessage(t, far, wire.NewMsgPing(7))
+
+	// replies keep their order, so anything we sent on our own would come
+	// before the pong
+	select {
+	case msg := <-msgs:
+		pong, ok := msg.(*wire.MsgPong)
+		if !ok || pong.Nonce != 7 {
+			t.Errorf("passive peer sent %v", msg.Command())
+		}
+
+	case <-time.After(time.Second):
+		t.Fatal("no pong from passive peer")
+	}
+}
+
+func TestServiceChecks(t *testing.T) {
+	witnessTx := invWitnessFlag | wire.InvTypeTx
+	all := []wire.InvType{wire.InvTypeTx, witnessTx, wire.InvTypeBlock,
+		wire.InvTypeFilteredBlock}
+	tests := []struct {
+		name     string
+		services wire.ServiceFlag
+		checked  bool
+		expected []wire.InvType
+	}{
+		{"none", 0, true, []wire.InvType{wire.InvTypeTx}},
+		{"network", wire.SFNodeNetwork, true,
+			[]wire.InvType{wire.InvTypeTx, wire.InvTypeBlock}},
+		{"limited", sfNodeNetworkLimited | sfNodeWitness, true,
+			[]wire.InvType{witnessTx, witnessTx, wire.InvTypeBlock}},
+		{"full", wire.SFNodeNetwork | sfNodeBloom | sfNodeWitness, true,
+			[]wire.InvType{witnessTx, witnessTx, wire.InvTypeBlock,
+				wire.InvTypeFilteredBlock}},
+		{"unchecked", 0, false, all},
+	}
+
+	for _, test := range tests {
+		p, far, _, err := newTestPeer(SetServiceChecks(test.checked))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		msgs := readMessages(far)
+		p.Start()
+
+		version := testVersion()
+		version.Services = test.services
+		p.Greet()
+		handshake(t, far, msgs, version)
+
+		inv := wire.NewMsgInv()
+		for i, invType := range all {
+			inv.AddInvVect(wire.NewInvVect(invType,
+				&wire.ShaHash{byte(i + 1)}))
+		}
+		sendMessage(t, far, inv)
+		sendMessage(t, far, wire.NewMsgPing(7))
+
+		// the requests come before the pong to the ping that follows
+		var requested []wire.InvType
+	ReadLoop:
+		for {
+			select {
+			case msg := <-msgs:
+				switch m := msg.(type) {
+				case *wire.MsgGetData:
+					for _, iv := range m.InvList {
+						requested = append(requested, iv.Type)
+					}
+
+				case *wire.MsgPong:
+					break ReadLoop
+				}
+
+			case <-time.After(time.Second):
+				t.Fatalf("%v: no pong", test.name)
+			}
+		}
+
+		p.Stop()
+		far.Close()
+
+		if fmt.Sprint(requested) != fmt.Sprint(test.expected) {
+			t.Errorf("%v: requested %v instead of %v", test.name, requested,
+				test.expected)
+		}
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	p, far, mgr, err := newTestPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer far.Close()
+
+	readMessages(far)
+	p.Start()
+	defer p.Stop()
+
+	_, err = far.Write(testFrame(wire.MainNet, "sendcmpct",
+		[]byte{0, 1, 0, 0, 0, 0, 0, 0, 0}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the peer stays connected and reads the messages that follow
+	sendMessage(t, far, wire.NewMsgPing(1))
+	waitRecord(t, mgr.pro, "ping")
+
+	select {
+	case <-mgr.stopped:
+		t.Fatal("peer stopped on unknown command")
+	default:
+	}
+
+	if p.Stats().Unknown != 1 {
+		t.Errorf("counted %v unknown commands", p.Stats().Unknown)
+	}
+}
+
+func TestRelay(t *testing.T) {
+	for _, relay := range []bool{true, false} {
+		p, far, _, err := newTestPeer(SetRelay(relay))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		msgs := readMessages(far)
+		p.Start()
+		p.Greet()
+
+		select {
+		case msg := <-msgs:
+			version, ok := msg.(*wire.MsgVersion)
+			if !ok {
+				t.Fatalf("sent %v instead of version", msg.Command())
+			}
+
+			if version.DisableRelayTx == relay {
+				t.Errorf("sent relay flag %v instead of %v",
+					!version.DisableRelayTx, relay)
+			}
+
+		case <-time.After(time.Second):
+			t.Fatal("no version sent")
+		}
+
+		// the far end asks for the opposite of what we asked for
+		version := testVersion()
+		version.DisableRelayTx = relay
+		sendMessage(t, far, version)
+		sendMessage(t, far, wire.NewMsgVerAck())
+
+		select {
+		case <-msgs:
+		case <-time.After(time.Second):
+			t.Fatal("no verack sent")
+		}
+
+		if p.Stats().Relay == relay {
+			t.Errorf("captured relay flag %v instead of %v",
+				p.Stats().Relay, !relay)
+		}
+
+		p.Stop()
+		far.Close()
+	}
+}
+
+func TestRejectThenClose(t *testing.T) {
+	tests := map[string]wire.Message{
+		records.ReasonReject: wire.NewMsgReject("tx", wire.RejectInvalid,
+			"bad-txns"),
+		records.ReasonEOF: wire.NewMsgPing(1),
+	}
+
+	for reason, msg := range tests {
+		p, far, mgr, err := newTestPeer()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		readMessages(far)
+		p.Start()
+
+		sendMessage(t, far, msg)
+		waitRecord(t, mgr.pro, msg.Command())
+		far.Close()
+
+		// the disconnect is recorded before the peer stops
+		record := waitRecord(t, mgr.pro, records.CmdDisconnect)
+		dr := record.(*records.DisconnectRecord)
+		if dr.Reason() != reason {
+			t.Errorf("recorded %v instead of %v", dr.Reason(), reason)
+		}
+
+		if reason == records.ReasonReject &&
+			!strings.Contains(dr.String(), "tx: bad-txns") {
+			t.Errorf("rejection missing from %q", dr.String())
+		}
+	}
+}
+
+// discoveryRepository remembers the addresses discovered through the peer.
+type discoveryRepository struct {
+	testRepository
+
+	mutex      sync.Mutex
+	discovered []string
+}
+
+func (repo *discoveryRepository) Discovered(addr *net.TCPAddr,
+	src *net.TCPAddr, stamp time.Time) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	repo.discovered = append(repo.discovered, addr.String())
+}
+
+func TestAddrMaxAge(t *testing.T) {
+	repo := &discoveryRepository{}
+	p, far, mgr, err := newTestPeer(SetRepository(repo),
+		SetAddrMaxAge(3*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer far.Close()
+
+	msgs := readMessages(far)
+	p.Start()
+	defer p.Stop()
+
+	p.Greet()
+	handshake(t, far, msgs, testVersion())
+
+	entries := []struct {
+		ip  string
+		age time.Duration
+	}{
+		{"8.8.8.8", time.Hour},
+		{"8.8.4.4", 30 * 24 * time.Hour},
+		{"10.0.0.1", time.Hour},
+		{"2001:4860:4860::8888", 2 * time.Hour},
+		{"2001:db8::1", time.Hour},
+	}
+
+	msg := wire.NewMsgAddr()
+	for _, entry := range entries {
+		na := wire.NewNetAddressIPPort(net.ParseIP(entry.ip), 8333, 0)
+		na.Timestamp = time.Now().Add(-entry.age)
+		msg.AddAddress(na)
+	}
+
+	// messages are processed in order, so the addresses are stored once the
+	// ping that follows is recorded
+	sendMessage(t, far, msg)
+	sendMessage(t, far, wire.NewMsgPing(1))
+	waitRecord(t, mgr.pro, "ping")
+
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	expected := []string{"8.8.8.8:8333", "[2001:4860:4860::8888]:8333"}
+	if strings.Join(repo.discovered, " ") != strings.Join(expected, " ") {
+		t.Errorf("stored %v instead of %v", repo.discovered, expected)
+	}
+}
+
+func TestAddrMaxAgeClock(t *testing.T) {
+	now := time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+	p, far, _, err := newTestPeer(SetAddrMaxAge(3*time.Hour),
+		SetClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer far.Close()
+
+	// the age of an entry is measured against the clock of the peer, not
+	// against the wall clock
+	na := wire.NewNetAddressIPPort(net.ParseIP("8.8.8.8"), 8333, 0)
+	na.Timestamp = now.Add(-time.Hour)
+	if !p.acceptAddr(na) {
+		t.Error("fresh address rejected")
+	}
+
+	na.Timestamp = now.Add(-4 * time.Hour)
+	if p.acceptAddr(na) {
+		t.Error("stale address accepted")
+	}
+}
+
+func TestAddrSources(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []func(*Peer)
+		expected []string
+	}{
+		{"default", nil, []string{"8.8.8.8:8333"}},
+		{"version", []func(*Peer){SetAddrSources(true, true, true)},
+			[]string{"8.8.4.4:8333", "8.8.8.8:8333"}},
+		{"version only", []func(*Peer){SetAddrSources(false, true, true)},
+			[]string{"8.8.4.4:8333"}},
+		{"inbound", []func(*Peer){SetInbound(true)},
+			[]string{"8.8.8.8:8333"}},
+		{"no inbound", []func(*Peer){SetAddrSources(true, true, false),
+			SetInbound(true)}, nil},
+		{"no inbound outbound", []func(*Peer){SetAddrSources(true, true,
+			false)}, []string{"8.8.4.4:8333", "8.8.8.8:8333"}},
+	}
+
+	for _, test := range tests {
+		repo := &discoveryRepository{}
+		options := append([]func(*Peer){SetRepository(repo)},
+			test.options...)
+		p, far, mgr, err := newTestPeer(options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		msgs := readMessages(far)
+		p.Start()
+
+		// inbound peers answer our version instead of greeting
+		version := testVersion()
+		version.AddrMe.IP = net.ParseIP("8.8.4.4")
+		if !p.inbound {
+			p.Greet()
+		}
+		handshake(t, far, msgs, version)
+
+		msg := wire.NewMsgAddr()
+		na := wire.NewNetAddressIPPort(net.ParseIP("8.8.8.8"), 8333, 0)
+		na.Timestamp = time.Now()
+		msg.AddAddress(na)
+		sendMessage(t, far, msg)
+		sendMessage(t, far, wire.NewMsgPing(1))
+		waitRecord(t, mgr.pro, "ping")
+
+		p.Stop()
+		far.Close()
+
+		if strings.Join(repo.discovered, " ") !=
+			strings.Join(test.expected, " ") {
+			t.Errorf("%v: stored %v instead of %v", test.name,
+				repo.discovered, test.expected)
+		}
+	}
+}
+
+func TestAddrLimit(t *testing.T) {
+	repo := &discoveryRepository{}
+	p, far, mgr, err := newTestPeer(SetRepository(repo), SetAddrLimit(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer far.Close()
+
+	msgs := readMessages(far)
+	p.Start()
+	defer p.Stop()
+
+	p.Greet()
+	handshake(t, far, msgs, testVersion())
+
+	// a partial batch is capped, but does not count as harvest
+	msg := wire.NewMsgAddr()
+	for i := 0; i < 3; i++ {
+		ip := net.IPv4(8, 8, 0, byte(i+1))
+		msg.AddAddress(wire.NewNetAddressIPPort(ip, 8333, 0))
+	}
+
+	sendMessage(t, far, msg)
+	sendMessage(t, far, wire.NewMsgPing(1))
+	waitRecord(t, mgr.pro, "ping")
+
+	repo.mutex.Lock()
+	discovered := len(repo.discovered)
+	repo.mutex.Unlock()
+
+	if discovered != 2 {
+		t.Errorf("stored %v addresses with a limit of 2", discovered)
+	}
+
+	if atomic.LoadUint32(&mgr.harvested) != 0 {
+		t.Error("partial batch reported as harvest")
+	}
+
+	// a full batch lets the manager know not to ask the peer again for a while
+	msg = wire.NewMsgAddr()
+	for i := 0; i < wire.MaxAddrPerMsg; i++ {
+		ip := net.IPv4(8, 9, byte(i>>8), byte(i))
+		msg.AddAddress(wire.NewNetAddressIPPort(ip, 8333, 0))
+	}
+
+	sendMessage(t, far, msg)
+	sendMessage(t, far, wire.NewMsgPing(2))
+	for i := 0; i < 1000 && atomic.LoadUint32(&mgr.harvested) == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	if atomic.LoadUint32(&mgr.harvested) != 1 {
+		t.Error("full batch not reported as harvest")
+	}
+
+	repo.mutex.Lock()
+	discovered = len(repo.discovered)
+	repo.mutex.Unlock()
+
+	if discovered != 4 {
+		t.Errorf("stored %v addresses after two capped batches", discovered)
+	}
+}
+
+func TestPingLatency(t *testing.T) {
+	var now int64
+	clock := func() time.Time {
+		return time.Unix(0, atomic.LoadInt64(&now))
+	}
+
+	atomic.StoreInt64(&now, time.Unix(1000, 0).UnixNano())
+	p, far, mgr, err := newTestPeer(SetClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer far.Close()
+
+	msgs := readMessages(far)
+	p.Start()
+	defer p.Stop()
+
+	p.Greet()
+	handshake(t, far, msgs, testVersion())
+
+	p.pushPing()
+	ping, ok := (<-msgs).(*wire.MsgPing)
+	if !ok {
+		t.Fatal("no ping sent")
+	}
+
+	// pongs for other pings are not measured
+	atomic.AddInt64(&now, int64(30*time.Millisecond))
+	sendMessage(t, far, wire.NewMsgPong(ping.Nonce+1))
+	sendMessage(t, far, wire.NewMsgPong(ping.Nonce))
+	sendMessage(t, far, wire.NewMsgPong(ping.Nonce))
+	sendMessage(t, far, wire.NewMsgGetAddr())
+	waitRecord(t, mgr.pro, "getaddr")
+
+	latency := p.Stats().Latency
+	if latency.Samples != 1 {
+		t.Fatalf("%v round-trip times measured", latency.Samples)
+	}
+
+	if latency.Percentile(0.5) != 50*time.Millisecond {
+		t.Errorf("median latency %v", latency.Percentile(0.5))
+	}
+}
+
+// TestUseful checks that a silent peer is seen as idle since the handshake,
+// and that only messages other than pings count as useful.
+func TestUseful(t *testing.T) {
+	var now int64
+	clock := func() time.Time {
+		return time.Unix(0, atomic.LoadInt64(&now))
+	}
+
+	handshaken := time.Unix(1000, 0)
+	atomic.StoreInt64(&now, handshaken.UnixNano())
+	p, far, mgr, err := newTestPeer(SetClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer far.Close()
+
+	msgs := readMessages(far)
+	p.Start()
+	defer p.Stop()
+
+	p.Greet()
+	handshake(t, far, msgs, testVersion())
+
+	atomic.AddInt64(&now, int64(time.Minute))
+	sendMessage(t, far, wire.NewMsgPing(1))
+	waitRecord(t, mgr.pro, "ping")
+
+	stats := p.Stats()
+	if stats.Useful != 0 || !stats.LastUseful.Equal(handshaken) {
+		t.Errorf("silent peer has %v useful messages, last at %v",
+			stats.Useful, stats.LastUseful)
+	}
+
+	atomic.AddInt64(&now, int64(time.Minute))
+	sendMessage(t, far, wire.NewMsgGetAddr())
+	waitRecord(t, mgr.pro, "getaddr")
+
+	stats = p.Stats()
+	if stats.Useful != 1 || !stats.LastUseful.Equal(clock()) {
+		t.Errorf("peer has %v useful messages, last at %v", stats.Useful,
+			stats.LastUseful)
+	}
+}
+
+// advertisingRepository remembers the addresses peers advertised for
+// themselves.
+type advertisingRepository struct {
+	testRepository
+
+	advertised chan *net.TCPAddr
+}
+
+func (repo *advertisingRepository) Advertised(addr *net.TCPAddr,
+	advertised *net.TCPAddr) {
+	repo.advertised <- advertised
+}
+
+func TestMisadvertised(t *testing.T) {
+	repo := &advertisingRepository{advertised: make(chan *net.TCPAddr, 1)}
+	p, far, mgr, err := newTestPeer(SetRepository(repo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer far.Close()
+
+	msgs := readMessages(far)
+	p.Start()
+	defer p.Stop()
+
+	// the peer claims a routable address other than the one we connected to
+	version := testVersion()
+	version.AddrMe.IP = net.ParseIP("8.8.8.8")
+	p.Greet()
+	handshake(t, far, msgs, version)
+
+	select {
+	case addr := <-repo.advertised:
+		if !addr.IP.Equal(version.AddrMe.IP) {
+			t.Errorf("flagged as advertising %v", addr)
+		}
+
+	case <-time.After(time.Second):
+		t.Fatal("misadvertised address not reported")
+	}
+
+	record, ok := waitRecord(t, mgr.pro, "version").(*records.VersionRecord)
+	if !ok {
+		t.Fatal("no version record")
+	}
+
+	if !record.Misadvertised() ||
+		!record.Advertised().IP.Equal(version.AddrMe.IP) {
+		t.Errorf("version record advertised %v", record.Advertised())
+	}
+}
+
+// aliveConn records the keepalive settings of the connection.
+type aliveConn struct {
+	*testConn
+
+	keepalive bool
+	period    time.Duration
+}
+
+func (c *aliveConn) SetKeepAlive(keepalive bool) error {
+	c.keepalive = keepalive
+	return nil
+}
+
+func (c *aliveConn) SetKeepAlivePeriod(d time.Duration) error {
+	c.period = d
+	return nil
+}
+
+func TestTCPKeepAlive(t *testing.T) {
+	for _, idle := range []time.Duration{0, 45 * time.Second} {
+		near, far := net.Pipe()
+		conn := &aliveConn{
+			testConn: &testConn{Conn: near, local: testLocal,
+				remote: testRemote},
+		}
+
+		_, pipe, _, err := newTestPeer(SetConnection(conn),
+			SetTCPKeepAlive(idle))
+		far.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pipe.Close()
+
+		if conn.keepalive != (idle != 0) || conn.period != idle {
+			t.Errorf("keepalive %v with period %v for idle time %v",
+				conn.keepalive, conn.period, idle)
+		}
+	}
+}
+
+func TestRecordRaw(t *testing.T) {
+	p, far, mgr, err := newTestPeer(SetRecordRaw(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer far.Close()
+
+	readMessages(far)
+	p.Start()
+	defer p.Stop()
+
+	payload := []byte{0, 1, 0, 0, 0, 0, 0, 0, 0xab}
+	_, err = far.Write(testFrame(wire.MainNet, "sendcmpct", payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record, ok := waitRecord(t, mgr.pro, "sendcmpct").(*records.RawRecord)
+	if !ok {
+		t.Fatal("unknown message not recorded as raw record")
+	}
+
+	if !bytes.Equal(record.Payload(), payload) {
+		t.Errorf("recorded payload %x", record.Payload())
+	}
+
+	if !strings.HasSuffix(record.String(), "|0001000000000000ab") {
+		t.Errorf("recorded %q", record.String())
+	}
+}
+
+func TestMinProtocolVersion(t *testing.T) {
+	tests := []struct {
+		version  int32
+		admitted bool
+	}{
+		{70001, false},
+		{70002, true},
+		{70012, true},
+	}
+
+	for _, test := range tests {
+		p, far, mgr, err := newTestPeer(SetMinProtocolVersion(70002))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		readMessages(far)
+		p.Start()
+
+		version := testVersion()
+		version.ProtocolVersion = test.version
+		sendMessage(t, far, version)
+
+		if !test.admitted {
+			record := waitRecord(t, mgr.pro, records.CmdDisconnect)
+			dr := record.(*records.DisconnectRecord)
+			if dr.Reason() != records.ReasonOutdated {
+				t.Errorf("version %v disconnected as %v", test.version,
+					dr.Reason())
+			}
+
+			far.Close()
+			continue
+		}
+
+		// messages after the version are processed as usual
+		sendMessage(t, far, wire.NewMsgPing(1))
+		waitRecord(t, mgr.pro, "ping")
+		for _, record := range mgr.pro.Records() {
+			if record.Command() == records.CmdDisconnect {
+				t.Errorf("version %v disconnected", test.version)
+			}
+		}
+
+		p.Stop()
+		far.Close()
+	}
+}
+
+func TestFeeFilterVersion(t *testing.T) {
+	tests := []struct {
+		options  []func(*Peer)
+		version  int32
+		filtered bool
+	}{
+		{nil, int32(wire.RejectVersion), false},
+		{[]func(*Peer){SetVersion(70013)}, 70013, true},
+	}
+
+	for _, test := range tests {
+		p, far, mgr, err := newTestPeer(test.options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		msgs := readMessages(far)
+		p.Start()
+		p.Greet()
+
+		var advertised int32
+		select {
+		case msg := <-msgs:
+			version, ok := msg.(*wire.MsgVersion)
+			if !ok {
+				t.Fatalf("sent %v instead of version", msg.Command())
+			}
+
+			advertised = version.ProtocolVersion
+
+		case <-time.After(time.Second):
+			t.Fatal("no version sent")
+		}
+
+		if advertised != test.version {
+			t.Errorf("advertised version %v instead of %v", advertised,
+				test.version)
+		}
+
+		// like other nodes, the far end only sends its fee filter once the
+		// version we advertised supports it
+		version := testVersion()
+		version.ProtocolVersion = 70013
+		sendMessage(t, far, version)
+		sendMessage(t, far, wire.NewMsgVerAck())
+		if advertised >= 70013 {
+			payload := make([]byte, 8)
+			binary.LittleEndian.PutUint64(payload, 1000)
+			_, err = far.Write(testFrame(wire.MainNet, records.CmdFeeFilter,
+				payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		sendMessage(t, far, wire.NewMsgPing(1))
+		waitRecord(t, mgr.pro, "ping")
+
+		filtered := false
+		for _, record := range mgr.pro.Records() {
+			if record.Command() == records.CmdFeeFilter {
+				filtered = true
+			}
+		}
+
+		if filtered != test.filtered {
+			t.Errorf("version %v recorded fee filter: %v", advertised,
+				filtered)
+		}
+
+		p.Stop()
+		far.Close()
+	}
+}
+
+func TestHandshakeTimeouts(t *testing.T) {
+	// a peer that sends its version first waits for our verack, while our
+	// version is answered by the version of the peer
+	tests := []struct {
+		name     string
+		version  time.Duration
+		shake    time.Duration
+		greet    bool
+		messages []wire.Message
+		stage    string
+	}{
+		{"stall before version", 20 * time.Millisecond, time.Second, true,
+			nil, stageVersion},
+		{"stall before verack", time.Second, 50 * time.Millisecond, false,
+			[]wire.Message{testVersion()}, stageVerAck},
+		{"complete", 20 * time.Millisecond, 50 * time.Millisecond, false,
+			[]wire.Message{testVersion(), wire.NewMsgVerAck()}, ""},
+	}
+
+	for _, test := range tests {
+		p, far, mgr, err := newTestPeer(SetVersionTimeout(test.version),
+			SetHandshakeTimeout(test.shake))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		readMessages(far)
+		p.Start()
+		if test.greet {
+			p.Greet()
+		}
+
+		for _, msg := range test.messages {
+			sendMessage(t, far, msg)
+		}
+
+		if test.stage == "" {
+			time.Sleep(100 * time.Millisecond)
+			for _, record := range mgr.pro.Records() {
+				if record.Command() == records.CmdDisconnect {
+					t.Errorf("%v: disconnected %v", test.name, record)
+				}
+			}
+
+			p.Stop()
+			far.Close()
+			continue
+		}
+
+		record := waitRecord(t, mgr.pro, records.CmdDisconnect)
+		dr := record.(*records.DisconnectRecord)
+		if dr.Reason() != records.ReasonTimeout ||
+			!strings.HasSuffix(dr.String(), "|"+test.stage) {
+			t.Errorf("%v: recorded %q", test.name, dr.String())
+		}
+
+		far.Close()
+	}
+}
+
+func TestAnnouncedSince(t *testing.T) {
+	var now int64
+	clock := func() time.Time {
+		return time.Unix(0, atomic.LoadInt64(&now))
+	}
+
+	tkr, err := tracker.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Unix(1443650000, 0)
+	hash := wire.ShaHash{1}
+	delays := []time.Duration{0, 3 * time.Second}
+	for _, delay := range delays {
+		atomic.StoreInt64(&now, start.Add(delay).UnixNano())
+		p, far, mgr, err := newTestPeer(SetTracker(tkr), SetClock(clock))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		msgs := readMessages(far)
+		p.Start()
+		p.Greet()
+		handshake(t, far, msgs, testVersion())
+
+		msg := wire.NewMsgInv()
+		msg.AddInvVect(wire.NewInvVect(wire.InvTypeTx, &hash))
+		sendMessage(t, far, msg)
+
+		record := waitRecord(t, mgr.pro, "inv").(*records.InventoryRecord)
+		since, ok := record.Items()[0].Since()
+		if !ok || since != delay {
+			t.Errorf("announced %v after first sighting, want %v", since,
+				delay)
+		}
+
+		p.Stop()
+		far.Close()
+	}
+}
+
+func TestMessageCap(t *testing.T) {
+	p, far, mgr, err := newTestPeer(SetMessageCap(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer far.Close()
+
+	msgs := readMessages(far)
+	p.Start()
+
+	// the version and verack take up the first two records
+	p.Greet()
+	handshake(t, far, msgs, testVersion())
+
+	for nonce := uint64(1); nonce <= 5; nonce++ {
+		sendMessage(t, far, wire.NewMsgPing(nonce))
+	}
+
+	// past the cap, the peer keeps handling messages
+	timeout := time.After(time.Second)
+	for pong := false; !pong; {
+		select {
+		case msg := <-msgs:
+			m, ok := msg.(*wire.MsgPong)
+			pong = ok && m.Nonce == 5
+
+		case <-timeout:
+			t.Fatal("no pong past the message cap")
+		}
+	}
+
+	// the disconnect is recorded regardless of the cap
+	p.Drop(records.ReasonIdle)
+
+	recs := mgr.pro.Records()
+	if len(recs) != 4 {
+		t.Fatalf("got %v records, want 3 and the disconnect", len(recs))
+	}
+
+	if recs[2].Command() != "ping" {
+		t.Errorf("got %v as last record, want the first ping", recs[2])
+	}
+
+	_, ok := recs[3].(*records.DisconnectRecord)
+	if !ok {
+		t.Errorf("got %v instead of disconnect record", recs[3])
+	}
+}
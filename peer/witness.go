@@ -0,0 +1,188 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package peer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sync/atomic"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Transactions with witness data are serialized as described in BIP 144,
+// which the wire package does not support: a marker and a flag follow the
+// version, and the witness of each input comes before the lock time. We
+// decode them into the stripped transaction, which the wire package does
+// support, and keep the witness hash and witness sizes for the record.
+
+const (
+	witnessMarker = 0x00
+	witnessFlag   = 0x01
+)
+
+// segwitTx is a transaction that was sent with its witness. It is handled as
+// the stripped transaction, with the witness hash and the witness size of
+// each input kept aside.
+type segwitTx struct {
+	*wire.MsgTx
+
+	wtxid [32]byte
+	sizes []uint32
+}
+
+// servesWitness returns whether the peer advertised that it serves witness
+// data, so that we can ask it for transactions with their witness.
+func (p *Peer) servesWitness() bool {
+	services := wire.ServiceFlag(atomic.LoadUint64(&p.services))
+	return services&sfNodeWitness != 0
+}
+
+// hasWitness returns whether the payload of a tx message holds a transaction
+// with witness. Transactions without inputs are invalid, so the marker can't
+// be mistaken for the input count of a stripped transaction.
+func hasWitness(payload []byte) bool {
+	return len(payload) > 6 && payload[4] == witnessMarker &&
+		payload[5] == witnessFlag
+}
+
+// decodeSegwitTx decodes a tx payload with witness. The stripped transaction
+// is rebuilt from the payload without the marker, flag and witnesses, so that
+// its hash is the transaction id, while the hash of the full payload is the
+// witness hash.
+func decodeSegwitTx(payload []byte) (*segwitTx, error) {
+	r := bytes.NewReader(payload[6:])
+	ins, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if ins == 0 {
+		return nil, errors.New("transaction without inputs")
+	}
+
+	// an input is its previous outpoint, script and sequence number
+	for i := uint64(0); i < ins; i++ {
+		err = skipBytes(r, 36)
+		if err == nil {
+			err = skipVarBytes(r)
+		}
+		if err == nil {
+			err = skipBytes(r, 4)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	outs, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// an output is its value and script
+	for i := uint64(0); i < outs; i++ {
+		err = skipBytes(r, 8)
+		if err == nil {
+			err = skipVarBytes(r)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the witness of an input is a count of items, each prefixed with its
+	// size, and there is one for every input
+	start := len(payload) - r.Len()
+	sizes := make([]uint32, ins)
+	for i := range sizes {
+		before := r.Len()
+		items, err := wire.ReadVarInt(r, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		for j := uint64(0); j < items; j++ {
+			err = skipVarBytes(r)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		sizes[i] = uint32(before - r.Len())
+	}
+
+	if r.Len() != 4 {
+		return nil, errors.New("invalid lock time")
+	}
+
+	stripped := make([]byte, 0, len(payload))
+	stripped = append(stripped, payload[:4]...)
+	stripped = append(stripped, payload[6:start]...)
+	stripped = append(stripped, payload[len(payload)-4:]...)
+
+	msg := &wire.MsgTx{}
+	err = msg.Deserialize(bytes.NewReader(stripped))
+	if err != nil {
+		return nil, err
+	}
+
+	first := sha256.Sum256(payload)
+	wtxid := sha256.Sum256(first[:])
+
+	return &segwitTx{MsgTx: msg, wtxid: wtxid, sizes: sizes}, nil
+}
+
+// processSegwitTx decodes a transaction with witness, which is then handled
+// like any other transaction.
+func (p *Peer) processSegwitTx(frame *unknownFrame) (wire.Message, error) {
+	tx, err := decodeSegwitTx(frame.payload)
+	if err != nil {
+		p.log.Debug("[PEER] %v: invalid witness transaction (%v)", p, err)
+		return nil, errHandledCommand
+	}
+
+	return tx, nil
+}
+
+// skipBytes advances the reader over the given number of bytes.
+func skipBytes(r *bytes.Reader, n uint64) error {
+	if n > uint64(r.Len()) {
+		return io.ErrUnexpectedEOF
+	}
+
+	_, err := io.CopyN(ioutil.Discard, r, int64(n))
+
+	return err
+}
+
+// skipVarBytes advances the reader over a byte string prefixed with its size.
+func skipVarBytes(r *bytes.Reader) error {
+	n, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return err
+	}
+
+	return skipBytes(r, n)
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package peer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/CIRCL/pbtc/records"
+)
+
+// testSegwitTx returns the payload of a transaction with witness, together
+// with the stripped transaction and the witness size of its input.
+func testSegwitTx(t *testing.T) ([]byte, *wire.MsgTx, uint32) {
+	tx := &wire.MsgTx{
+		Version: 1,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{Hash: wire.ShaHash{1}},
+			Sequence:         0xffffffff,
+		}},
+		TxOut: []*wire.TxOut{{Value: 5000, PkScript: []byte{0x51}}},
+	}
+
+	buf := &bytes.Buffer{}
+	err := tx.Serialize(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stripped := buf.Bytes()
+
+	// the witness of a key hash spend is a signature and a public key
+	witness := []byte{2, 71}
+	witness = append(witness, bytes.Repeat([]byte{0x30}, 71)...)
+	witness = append(witness, 33)
+	witness = append(witness, bytes.Repeat([]byte{0x02}, 33)...)
+
+	payload := append([]byte{}, stripped[:4]...)
+	payload = append(payload, witnessMarker, witnessFlag)
+	payload = append(payload, stripped[4:len(stripped)-4]...)
+	payload = append(payload, witness...)
+	payload = append(payload, stripped[len(stripped)-4:]...)
+
+	return payload, tx, uint32(len(witness))
+}
+
+func TestDecodeSegwitTx(t *testing.T) {
+	payload, tx, size := testSegwitTx(t)
+	if !hasWitness(payload) {
+		t.Fatal("witness not detected")
+	}
+
+	decoded, err := decodeSegwitTx(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := sha256.Sum256(payload)
+	wtxid := sha256.Sum256(first[:])
+	txid := decoded.TxSha()
+	if txid != tx.TxSha() || decoded.wtxid != wtxid ||
+		bytes.Equal(txid[:], wtxid[:]) {
+		t.Errorf("decoded txid %v and wtxid %x", txid, decoded.wtxid)
+	}
+
+	if len(decoded.sizes) != 1 || decoded.sizes[0] != size {
+		t.Errorf("decoded witness sizes %v instead of [%v]", decoded.sizes,
+			size)
+	}
+
+	// a stripped transaction is left to the wire package
+	buf := &bytes.Buffer{}
+	tx.Serialize(buf)
+	if hasWitness(buf.Bytes()) {
+		t.Error("witness detected in stripped transaction")
+	}
+
+	// cutting the payload anywhere makes it invalid
+	for _, n := range []int{7, 50, len(payload) - 60, len(payload) - 1} {
+		_, err = decodeSegwitTx(payload[:n])
+		if err == nil {
+			t.Errorf("decoded payload cut at %v", n)
+		}
+	}
+}
+
+func TestSegwitTxRelay(t *testing.T) {
+	p, far, mgr, err := newTestPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer far.Close()
+
+	msgs := readMessages(far)
+	p.Start()
+	defer p.Stop()
+
+	version := testVersion()
+	version.Services = wire.SFNodeNetwork | sfNodeWitness
+	p.Greet()
+	handshake(t, far, msgs, version)
+
+	payload, tx, size := testSegwitTx(t)
+	txid := tx.TxSha()
+	inv := wire.NewMsgInv()
+	inv.AddInvVect(wire.NewInvVect(wire.InvTypeTx, &txid))
+	sendMessage(t, far, inv)
+
+	// peers serving witnesses are asked for the transaction with witness
+	select {
+	case msg := <-msgs:
+		getdata, ok := msg.(*wire.MsgGetData)
+		if !ok || len(getdata.InvList) != 1 ||
+			getdata.InvList[0].Type != invWitnessFlag|wire.InvTypeTx {
+			t.Fatalf("sent %v instead of witness getdata", msg.Command())
+		}
+
+	case <-time.After(time.Second):
+		t.Fatal("no getdata sent")
+	}
+
+	// a corrupted transaction is skipped, without dropping the connection
+	corrupted := testFrame(wire.MainNet, wire.CmdTx, payload)
+	corrupted[wire.MessageHeaderSize+10] ^= 0xff
+	frame := append(corrupted, testFrame(wire.MainNet, wire.CmdTx,
+		payload)...)
+	_, err = far.Write(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := waitRecord(t, mgr.pro, wire.CmdTx)
+	tr, ok := record.(*records.TransactionRecord)
+	if !ok {
+		t.Fatalf("recorded %T", record)
+	}
+
+	first := sha256.Sum256(payload)
+	wtxid := sha256.Sum256(first[:])
+	if tr.Hash() != txid || tr.WitnessHash() != wtxid || !tr.Segwit() {
+		t.Errorf("recorded txid %x, wtxid %x", tr.Hash(), tr.WitnessHash())
+	}
+
+	sizes := tr.WitnessSizes()
+	if len(sizes) != 1 || sizes[0] != size {
+		t.Errorf("recorded witness sizes %v instead of [%v]", sizes, size)
+	}
+
+	sendMessage(t, far, wire.NewMsgPing(1))
+	waitRecord(t, mgr.pro, "ping")
+	count := 0
+	for _, record := range mgr.pro.Records() {
+		if record.Command() == wire.CmdTx {
+			count++
+		}
+	}
+
+	if count != 1 {
+		t.Errorf("recorded %v transactions", count)
+	}
+}
@@ -76,6 +76,8 @@ func (filter *AddressFilter) Start() {
 	filter.wg.Add(1)
 	go filter.goProcess()
 
+	filter.markRunning()
+
 	filter.log.Info("[PFA] Start: completed")
 }
 
@@ -83,6 +85,8 @@ func (filter *AddressFilter) Start() {
 func (filter *AddressFilter) Stop() {
 	filter.log.Info("[PFA] Stop: begin")
 
+	filter.markStopped()
+
 	close(filter.sig)
 	filter.wg.Wait()
 
@@ -93,33 +97,30 @@ func (filter *AddressFilter) Stop() {
 func (filter *AddressFilter) Process(record adaptor.Record) {
 	filter.log.Debug("[PFA] PRocess: %v", record.Command())
 
+	if filter.discard() {
+		return
+	}
+
 	filter.recordQ <- record
 }
 
+// Backpressure returns whether the filter or one of the processors it forwards
+// to can not keep up with the records.
+func (filter *AddressFilter) Backpressure() bool {
+	return len(filter.recordQ) == cap(filter.recordQ) || filter.nextPressure()
+}
+
 // goProcess is to be launched as a go routine. It reads the records added to
 // the queue and forwards valid records to the next set of processors.
 func (filter *AddressFilter) goProcess() {
 	defer filter.wg.Done()
 
-ProcessLoop:
-	for {
-		select {
-		case _, ok := <-filter.sig:
-			if !ok {
-				break ProcessLoop
-			}
-
-		case record := <-filter.recordQ:
-			if filter.valid(record) {
-				filter.forward(record)
-			}
-		}
-	}
+	filter.runFilter(filter.sig, filter.recordQ, filter.valid, filter.forward)
 }
 
 // valid checks whether a record fulfills the criteria for forwarding.
 func (filter *AddressFilter) valid(record adaptor.Record) bool {
-	tx, ok := record.(*records.TransactionRecord)
+	tx, ok := records.Unwrap(record).(*records.TransactionRecord)
 	if !ok {
 		return false
 	}
@@ -135,6 +136,7 @@ func (filter *AddressFilter) valid(record adaptor.Record) bool {
 
 // forward will send the message to all processors following this filter.
 func (filter *AddressFilter) forward(record adaptor.Record) {
+	record = filter.tagged(record)
 	for _, processor := range filter.next {
 		processor.Process(record)
 	}
@@ -0,0 +1,74 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package processor
+
+import (
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/CIRCL/pbtc/adaptor"
+	"github.com/CIRCL/pbtc/records"
+)
+
+// testAddress is the address paying to the key hash in testScript.
+const testAddress = "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH"
+
+var testScript, _ = hex.DecodeString(
+	"76a914751e76e8199196d454941c45d1b3a323f1433bd688ac")
+
+// testTx returns a transaction record with the given output scripts.
+func testTx(scripts ...[]byte) adaptor.Record {
+	msg := &wire.MsgTx{Version: 1}
+	for _, script := range scripts {
+		msg.AddTxOut(wire.NewTxOut(1000, script))
+	}
+
+	ra := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 8333}
+	la := &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 50000}
+
+	return records.NewTransactionRecord(msg, ra, la, time.Unix(1, 0))
+}
+
+// wrapped returns the record as it comes out of a tagging processor with a
+// geolocation database, which is what downstream filters receive.
+func wrapped(record adaptor.Record) adaptor.Record {
+	return records.NewTaggedRecord(records.NewGeoRecord(record, "LU", 6661),
+		"upstream")
+}
+
+func TestAddressFilterWrapped(t *testing.T) {
+	filter, err := NewAddressFilter(SetAddresses(testAddress))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !filter.valid(wrapped(testTx(testScript))) {
+		t.Error("wrapped transaction paying to the address dropped")
+	}
+
+	if filter.valid(wrapped(testTx())) {
+		t.Error("wrapped transaction without the address forwarded")
+	}
+}
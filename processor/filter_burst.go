@@ -0,0 +1,221 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package processor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/CIRCL/pbtc/adaptor"
+)
+
+// BurstFilter represents a filter that normally only forwards a sample of the
+// records, but forwards all of them for a while once the rate of the trigger
+// commands spikes, like on a flood of inventory or a new block. This captures
+// the interesting events in full without storing everything.
+type BurstFilter struct {
+	Processor
+
+	wg      *sync.WaitGroup
+	sig     chan struct{}
+	recordQ chan adaptor.Record
+
+	triggers map[string]bool
+	rate     float64
+	window   time.Duration
+	sample   uint64
+
+	seen        uint64
+	bucketStart time.Time
+	bucketCount uint64
+	burstEnd    time.Time
+	bursting    bool
+}
+
+// NewBurstFilter returns a new filter that forwards one in a hundred records,
+// and all of them for a minute once more than a hundred inventory messages are
+// received within a second.
+func NewBurstFilter(options ...func(adaptor.Processor)) (*BurstFilter, error) {
+	filter := &BurstFilter{
+		wg:      &sync.WaitGroup{},
+		sig:     make(chan struct{}),
+		recordQ: make(chan adaptor.Record, 1),
+
+		triggers: map[string]bool{wire.CmdInv: true},
+		rate:     100,
+		window:   time.Minute,
+		sample:   100,
+	}
+
+	for _, option := range options {
+		option(filter)
+	}
+
+	return filter, nil
+}
+
+// SetBurstCapture sets the rate, in trigger messages per second, above which
+// all records are forwarded, and for how long after the rate was last
+// exceeded.
+func SetBurstCapture(rate float64, window time.Duration) func(
+	adaptor.Processor) {
+	return func(pro adaptor.Processor) {
+		filter, ok := pro.(*BurstFilter)
+		if !ok {
+			return
+		}
+
+		filter.rate = rate
+		filter.window = window
+	}
+}
+
+// SetBurstTriggers sets the commands whose rate is watched for bursts.
+func SetBurstTriggers(cmds ...string) func(adaptor.Processor) {
+	return func(pro adaptor.Processor) {
+		filter, ok := pro.(*BurstFilter)
+		if !ok {
+			return
+		}
+
+		filter.triggers = make(map[string]bool)
+		for _, cmd := range cmds {
+			filter.triggers[cmd] = true
+		}
+	}
+}
+
+// SetBurstSample sets the sampling outside of bursts: one in the given number
+// of records is forwarded. Zero or less forwards nothing outside of bursts.
+func SetBurstSample(sample int) func(adaptor.Processor) {
+	return func(pro adaptor.Processor) {
+		filter, ok := pro.(*BurstFilter)
+		if !ok {
+			return
+		}
+
+		if sample < 0 {
+			sample = 0
+		}
+
+		filter.sample = uint64(sample)
+	}
+}
+
+func (filter *BurstFilter) Start() {
+	filter.log.Info("[PFB] Start: begin")
+
+	filter.wg.Add(1)
+	go filter.goProcess()
+
+	filter.markRunning()
+
+	filter.log.Info("[PFB] Start: completed")
+}
+
+func (filter *BurstFilter) Stop() {
+	filter.log.Info("[PFB] Stop: begin")
+
+	filter.markStopped()
+
+	close(filter.sig)
+	filter.wg.Wait()
+
+	filter.log.Info("[PFB] Stop: completed")
+}
+
+// Process adds one messages to the filter for processing and forwarding.
+func (filter *BurstFilter) Process(record adaptor.Record) {
+	filter.log.Debug("[PFB] Process: %v", record.Command())
+
+	if filter.discard() {
+		return
+	}
+
+	filter.recordQ <- record
+}
+
+// Backpressure returns whether the filter or one of the processors it forwards
+// to can not keep up with the records.
+func (filter *BurstFilter) Backpressure() bool {
+	return len(filter.recordQ) == cap(filter.recordQ) || filter.nextPressure()
+}
+
+// goProcess has to be launched as a go routine.
+func (filter *BurstFilter) goProcess() {
+	defer filter.wg.Done()
+
+	filter.runFilter(filter.sig, filter.recordQ, filter.valid, filter.forward)
+}
+
+// valid checks whether a record is to be forwarded: all of them during a
+// burst, and a sample otherwise.
+func (filter *BurstFilter) valid(record adaptor.Record) bool {
+	stamp := record.Timestamp()
+	if filter.triggers[record.Command()] {
+		filter.count(stamp)
+	}
+
+	if stamp.Before(filter.burstEnd) {
+		return true
+	}
+
+	if filter.bursting {
+		filter.bursting = false
+		filter.log.Info("[PFB] Burst over, back to sampling")
+	}
+
+	filter.seen++
+	return filter.sample > 0 && filter.seen%filter.sample == 0
+}
+
+// count adds a trigger message to the count of the current second and starts
+// or extends the burst if the count exceeds the rate.
+func (filter *BurstFilter) count(stamp time.Time) {
+	if stamp.Sub(filter.bucketStart) >= time.Second ||
+		stamp.Before(filter.bucketStart) {
+		filter.bucketStart = stamp
+		filter.bucketCount = 0
+	}
+
+	filter.bucketCount++
+	if float64(filter.bucketCount) <= filter.rate {
+		return
+	}
+
+	if !filter.bursting {
+		filter.bursting = true
+		filter.log.Info("[PFB] Burst of more than %v messages per second, "+
+			"capturing all for %v", filter.rate, filter.window)
+	}
+
+	filter.burstEnd = stamp.Add(filter.window)
+}
+
+// forward will send the message to all processors following this filter.
+func (filter *BurstFilter) forward(record adaptor.Record) {
+	record = filter.tagged(record)
+	for _, processor := range filter.next {
+		processor.Process(record)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/CIRCL/pbtc/adaptor"
+	"github.com/CIRCL/pbtc/pbtctest"
+	"github.com/CIRCL/pbtc/records"
+)
+
+// TestBurstCapture drives a spike of inventory messages through the filter and
+// checks that it forwards everything during the window that follows, and only
+// a sample before and after.
+func TestBurstCapture(t *testing.T) {
+	filter, err := NewBurstFilter(SetBurstCapture(3, 10*time.Second),
+		SetBurstSample(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filter.SetLog(pbtctest.Log{})
+
+	start := time.Unix(1000, 0)
+	forwarded := func(cmd string, offset time.Duration, n int) int {
+		stamp := start.Add(offset)
+		count := 0
+		for i := 0; i < n; i++ {
+			var record adaptor.Record
+			if cmd == wire.CmdInv {
+				record = records.NewInventoryRecord(wire.NewMsgInv(), nil,
+					nil, stamp)
+			} else {
+				record = records.NewPingRecord(wire.NewMsgPing(1), nil, nil,
+					stamp)
+			}
+
+			if filter.valid(record) {
+				count++
+			}
+		}
+
+		return count
+	}
+
+	steps := []struct {
+		name      string
+		cmd       string
+		offset    time.Duration
+		n         int
+		forwarded int
+	}{
+		{"sampled", wire.CmdPing, 0, 20, 2},
+		{"below rate", wire.CmdInv, 10 * time.Second, 3, 0},
+		{"spike", wire.CmdInv, 10 * time.Second, 2, 2},
+		{"burst", wire.CmdPing, 15 * time.Second, 5, 5},
+		{"relaxed", wire.CmdPing, 25 * time.Second, 10, 1},
+	}
+
+	for _, step := range steps {
+		count := forwarded(step.cmd, step.offset, step.n)
+		if count != step.forwarded {
+			t.Errorf("%v: forwarded %v of %v records, want %v", step.name,
+				count, step.n, step.forwarded)
+		}
+	}
+}
@@ -77,12 +77,16 @@ func (filter *CommandFilter) Start() {
 	filter.wg.Add(1)
 	go filter.goProcess()
 
+	filter.markRunning()
+
 	filter.log.Info("[PFC] Start: completed")
 }
 
 func (filter *CommandFilter) Stop() {
 	filter.log.Info("[PFC] Stop: begin")
 
+	filter.markStopped()
+
 	close(filter.sig)
 	filter.wg.Wait()
 
@@ -93,27 +97,24 @@ func (filter *CommandFilter) Stop() {
 func (filter *CommandFilter) Process(record adaptor.Record) {
 	filter.log.Debug("[PFC] Process: %v", record.Command())
 
+	if filter.discard() {
+		return
+	}
+
 	filter.recordQ <- record
 }
 
+// Backpressure returns whether the filter or one of the processors it forwards
+// to can not keep up with the records.
+func (filter *CommandFilter) Backpressure() bool {
+	return len(filter.recordQ) == cap(filter.recordQ) || filter.nextPressure()
+}
+
 // goProcess has to be launched as a go routine.
 func (filter *CommandFilter) goProcess() {
 	defer filter.wg.Done()
 
-ProcessLoop:
-	for {
-		select {
-		case _, ok := <-filter.sig:
-			if !ok {
-				break ProcessLoop
-			}
-
-		case record := <-filter.recordQ:
-			if filter.valid(record) {
-				filter.forward(record)
-			}
-		}
-	}
+	filter.runFilter(filter.sig, filter.recordQ, filter.valid, filter.forward)
 }
 
 // valid checks whether a record fulfills the criteria for forwarding.
@@ -123,6 +124,7 @@ func (filter *CommandFilter) valid(record adaptor.Record) bool {
 
 // forward will send the message to all processors following this filter.
 func (filter *CommandFilter) forward(record adaptor.Record) {
+	record = filter.tagged(record)
 	for _, processor := range filter.next {
 		processor.Process(record)
 	}
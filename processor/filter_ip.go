@@ -21,9 +21,11 @@
 package processor
 
 import (
+	"errors"
 	"sync"
 
 	"github.com/CIRCL/pbtc/adaptor"
+	"github.com/CIRCL/pbtc/util"
 )
 
 // IPFilter is a filter to forward only messages that come from a peer whose
@@ -35,6 +37,7 @@ type IPFilter struct {
 	sig     chan struct{}
 	recordQ chan adaptor.Record
 	config  map[string]bool
+	invalid []string
 }
 
 // NewIP creates a new IP filter that will only forward messages coming from
@@ -51,11 +54,17 @@ func NewIPFilter(options ...func(adaptor.Processor)) (*IPFilter, error) {
 		option(filter)
 	}
 
+	if len(filter.invalid) > 0 {
+		return nil, errors.New("invalid ip in filter: " + filter.invalid[0])
+	}
+
 	return filter, nil
 }
 
 // SetIPs can be passed as a parameter to NewIP to set the list of IP addresses
-// to filter for. If no list is provided, all messages are filtered out.
+// to filter for. If no list is provided, all messages are filtered out. The
+// IPs are matched in canonical form, so that a peer can not evade the filter by
+// using another textual or IPv4-mapped form of its IP.
 func SetIPs(ips ...string) func(adaptor.Processor) {
 	return func(pro adaptor.Processor) {
 		filter, ok := pro.(*IPFilter)
@@ -63,8 +72,14 @@ func SetIPs(ips ...string) func(adaptor.Processor) {
 			return
 		}
 
-		for _, ip := range ips {
-			filter.config[ip] = true
+		for _, text := range ips {
+			ip := util.ParseIP(text)
+			if ip == nil {
+				filter.invalid = append(filter.invalid, text)
+				continue
+			}
+
+			filter.config[ip.String()] = true
 		}
 	}
 }
@@ -75,12 +90,16 @@ func (filter *IPFilter) Start() {
 	filter.wg.Add(1)
 	go filter.goProcess()
 
+	filter.markRunning()
+
 	filter.log.Info("[PFI] Start: completed")
 }
 
 func (filter *IPFilter) Stop() {
 	filter.log.Info("[PFI] Stop: begin")
 
+	filter.markStopped()
+
 	close(filter.sig)
 	filter.wg.Wait()
 
@@ -91,36 +110,44 @@ func (filter *IPFilter) Stop() {
 func (filter *IPFilter) Process(record adaptor.Record) {
 	filter.log.Debug("[PFI] Process: %v", record.Command())
 
+	if filter.discard() {
+		return
+	}
+
 	filter.recordQ <- record
 }
 
+// Backpressure returns whether the filter or one of the processors it forwards
+// to can not keep up with the records.
+func (filter *IPFilter) Backpressure() bool {
+	return len(filter.recordQ) == cap(filter.recordQ) || filter.nextPressure()
+}
+
 // goProcess has to be launched as a go routine.
 func (filter *IPFilter) goProcess() {
 	defer filter.wg.Done()
 
-ProcessLoop:
-	for {
-		select {
-		case _, ok := <-filter.sig:
-			if !ok {
-				break ProcessLoop
-			}
-
-		case record := <-filter.recordQ:
-			if filter.valid(record) {
-				filter.forward(record)
-			}
-		}
-	}
+	filter.runFilter(filter.sig, filter.recordQ, filter.valid, filter.forward)
 }
 
-// valid for dummy filter simply returns true for every record
+// valid returns whether the record comes from one of the configured IPs.
 func (filter *IPFilter) valid(record adaptor.Record) bool {
-	return filter.config[record.RemoteAddress().IP.String()]
+	ra := record.RemoteAddress()
+	if ra == nil {
+		return false
+	}
+
+	ip := util.NormalizeIP(ra.IP)
+	if ip == nil {
+		return false
+	}
+
+	return filter.config[ip.String()]
 }
 
 // forward will send the message to the following processors for processing.
 func (filter *IPFilter) forward(record adaptor.Record) {
+	record = filter.tagged(record)
 	for _, processor := range filter.next {
 		processor.Process(record)
 	}
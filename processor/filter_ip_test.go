@@ -0,0 +1,67 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package processor
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/CIRCL/pbtc/records"
+)
+
+func TestIPFilterForms(t *testing.T) {
+	filter, err := NewIPFilter(SetIPs("::FFFF:192.0.2.1", " [2001:DB8::1] "))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	la := &net.TCPAddr{IP: net.ParseIP("192.0.2.100"), Port: 8333}
+	tests := []struct {
+		ip    net.IP
+		valid bool
+	}{
+		{net.IP{192, 0, 2, 1}, true},
+		{net.ParseIP("192.0.2.1"), true},
+		{net.ParseIP("::ffff:c000:201"), true},
+		{net.ParseIP("2001:db8:0::1"), true},
+		{net.ParseIP("192.0.2.2"), false},
+		{net.ParseIP("2001:db8::2"), false},
+		{net.IP{1, 2, 3}, false},
+	}
+
+	// every form of a configured IP matches, whatever form it was given in
+	for _, test := range tests {
+		ra := &net.TCPAddr{IP: test.ip, Port: 8333}
+		record := records.NewPingRecord(wire.NewMsgPing(1), ra, la,
+			time.Unix(1, 0))
+		if filter.valid(record) != test.valid {
+			t.Errorf("%#v valid: %v", test.ip, !test.valid)
+		}
+	}
+
+	_, err = NewIPFilter(SetIPs("192.0.2.1", "192.0.2.256"))
+	if err == nil {
+		t.Error("filter created with invalid IP")
+	}
+}
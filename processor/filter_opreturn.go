@@ -0,0 +1,159 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package processor
+
+import (
+	"encoding/hex"
+	"sync"
+
+	"github.com/CIRCL/pbtc/adaptor"
+	"github.com/CIRCL/pbtc/records"
+)
+
+// OpReturnFilter is a filter which only forwards transactions if they contain
+// an OP_RETURN output, optionally with data starting with one of the given
+// prefixes. All other messages pass through unaffected.
+type OpReturnFilter struct {
+	Processor
+
+	wg       *sync.WaitGroup
+	sig      chan struct{}
+	recordQ  chan adaptor.Record
+	config   []string
+	prefixes [][]byte
+}
+
+// NewOpReturnFilter creates a new filter that only forwards transactions with
+// embedded data. Without prefixes, every transaction with an OP_RETURN output
+// is forwarded.
+func NewOpReturnFilter(options ...func(adaptor.Processor)) (*OpReturnFilter,
+	error) {
+	filter := &OpReturnFilter{
+		wg:      &sync.WaitGroup{},
+		sig:     make(chan struct{}),
+		recordQ: make(chan adaptor.Record, 1),
+	}
+
+	for _, option := range options {
+		option(filter)
+	}
+
+	for _, prefix := range filter.config {
+		data, err := hex.DecodeString(prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		filter.prefixes = append(filter.prefixes, data)
+	}
+
+	return filter, nil
+}
+
+// SetOpReturnPrefixes can be passed as parameter to NewOpReturnFilter in order
+// to only forward transactions whose OP_RETURN data starts with one of the
+// given prefixes, in hexadecimal.
+func SetOpReturnPrefixes(prefixes ...string) func(adaptor.Processor) {
+	return func(pro adaptor.Processor) {
+		filter, ok := pro.(*OpReturnFilter)
+		if !ok {
+			return
+		}
+
+		filter.config = prefixes
+	}
+}
+
+func (filter *OpReturnFilter) Start() {
+	filter.log.Info("[PFO] Start: begin")
+
+	filter.wg.Add(1)
+	go filter.goProcess()
+
+	filter.markRunning()
+
+	filter.log.Info("[PFO] Start: completed")
+}
+
+// Stop will end the filter and wait for the go routine to quit.
+func (filter *OpReturnFilter) Stop() {
+	filter.log.Info("[PFO] Stop: begin")
+
+	filter.markStopped()
+
+	close(filter.sig)
+	filter.wg.Wait()
+
+	filter.log.Info("[PFO] Stop: completed")
+}
+
+// Process adds one messages to the filter for processing and forwarding.
+func (filter *OpReturnFilter) Process(record adaptor.Record) {
+	filter.log.Debug("[PFO] Process: %v", record.Command())
+
+	if filter.discard() {
+		return
+	}
+
+	filter.recordQ <- record
+}
+
+// Backpressure returns whether the filter or one of the processors it forwards
+// to can not keep up with the records.
+func (filter *OpReturnFilter) Backpressure() bool {
+	return len(filter.recordQ) == cap(filter.recordQ) || filter.nextPressure()
+}
+
+// goProcess is to be launched as a go routine. It reads the records added to
+// the queue and forwards valid records to the next set of processors.
+func (filter *OpReturnFilter) goProcess() {
+	defer filter.wg.Done()
+
+	filter.runFilter(filter.sig, filter.recordQ, filter.valid, filter.forward)
+}
+
+// valid checks whether a record fulfills the criteria for forwarding.
+func (filter *OpReturnFilter) valid(record adaptor.Record) bool {
+	tx, ok := records.Unwrap(record).(*records.TransactionRecord)
+	if !ok {
+		return true
+	}
+
+	if len(filter.prefixes) == 0 {
+		return tx.HasNullData(nil)
+	}
+
+	for _, prefix := range filter.prefixes {
+		if tx.HasNullData(prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// forward will send the message to all processors following this filter.
+func (filter *OpReturnFilter) forward(record adaptor.Record) {
+	record = filter.tagged(record)
+	for _, processor := range filter.next {
+		processor.Process(record)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package processor
+
+import (
+	"testing"
+)
+
+func TestOpReturnFilterWrapped(t *testing.T) {
+	filter, err := NewOpReturnFilter(SetOpReturnPrefixes("cafe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte{0x6a, 0x04, 0xca, 0xfe, 0xba, 0xbe}
+	if !filter.valid(wrapped(testTx(testScript, data))) {
+		t.Error("wrapped transaction with matching data dropped")
+	}
+
+	if filter.valid(wrapped(testTx(testScript))) {
+		t.Error("wrapped transaction without data forwarded")
+	}
+}
+
+func TestOpReturnFilterNonStandard(t *testing.T) {
+	filter, err := NewOpReturnFilter(SetOpReturnPrefixes("cafe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a single push of 100 bytes is above the standard size
+	large := append([]byte{0x6a, 0x4c, 100, 0xca, 0xfe},
+		make([]byte, 98)...)
+	if !filter.valid(testTx(testScript, large)) {
+		t.Error("transaction with large data dropped")
+	}
+
+	// data followed by a non-push opcode is not a standard script
+	mixed := []byte{0x6a, 0x02, 0xca, 0xfe, 0x51}
+	if !filter.valid(testTx(mixed)) {
+		t.Error("transaction with non-push script dropped")
+	}
+
+	unfiltered, err := NewOpReturnFilter()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !unfiltered.valid(testTx([]byte{0x6a})) {
+		t.Error("transaction with bare OP_RETURN dropped")
+	}
+
+	if unfiltered.valid(testTx(testScript)) {
+		t.Error("transaction without OP_RETURN forwarded")
+	}
+}
@@ -0,0 +1,315 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package processor
+
+import (
+	"errors"
+	"flag"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/CIRCL/pbtc/adaptor"
+	"github.com/CIRCL/pbtc/convertor"
+	"github.com/CIRCL/pbtc/pbtctest"
+)
+
+// The pipeline benchmarks feed synthetic records through a real processor
+// chain, made of a dummy filter and a file writer, to measure how many records
+// per second it sustains independently of the network. Besides the time per
+// record, they report how often the chain signalled backpressure, how many
+// records it dropped and the p99 latency of handing a record to the chain.
+// Rate and command mix can be set on the command line:
+//
+//	go test -run XXX -bench Pipeline ./processor -args -pbtc.rate 50000 \
+//		-pbtc.mix tx=70,inv=25,addr=4,ping=1
+var (
+	benchRate = flag.Int("pbtc.rate", 0,
+		"records per second for pipeline benchmarks, zero for no limit")
+	benchMix = flag.String("pbtc.mix", "tx=70,inv=25,addr=4,ping=1",
+		"weights of the commands generated for pipeline benchmarks")
+)
+
+// share is the weight of one command in the generated mix.
+type share struct {
+	command string
+	weight  int
+}
+
+func BenchmarkPipeline(b *testing.B) {
+	shares, err := parseMix(*benchMix)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, encoding := range []string{"TEXT", "PROTO", "COMPACT", "FLAT"} {
+		b.Run(encoding, func(b *testing.B) {
+			benchPipeline(b, encoding, shares)
+		})
+	}
+}
+
+func BenchmarkPipelineCommands(b *testing.B) {
+	for _, command := range []string{"tx", "inv", "addr", "ping"} {
+		b.Run(command, func(b *testing.B) {
+			benchPipeline(b, "TEXT", []share{{command: command, weight: 1}})
+		})
+	}
+}
+
+func TestParseMix(t *testing.T) {
+	shares, err := parseMix("tx=70, inv=30")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(shares) != 2 || shares[1].command != "inv" ||
+		shares[1].weight != 30 {
+		t.Errorf("parsed %v", shares)
+	}
+
+	for _, mix := range []string{"tx", "tx=-1", "tx=0", "foo=1"} {
+		_, err := parseMix(mix)
+		if err == nil {
+			t.Errorf("parsed invalid mix %q", mix)
+		}
+	}
+}
+
+// benchPipeline runs b.N records of the given mix through a chain writing
+// files with the given encoding.
+func benchPipeline(b *testing.B, encoding string, shares []share) {
+	enc, err := ParseEncoding(encoding)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "pbtc-benchmark-")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filter, err := NewDummy()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	writer, err := NewFileWriter(SetFilePath(dir), SetFileEncoding(enc))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	filter.SetLog(pbtctest.Log{})
+	writer.SetLog(pbtctest.Log{})
+	filter.AddNext(writer)
+
+	// generate the records up front, so we only measure the pipeline
+	records := make([]adaptor.Record, 1024)
+	for i := range records {
+		records[i] = generate(pick(shares), uint64(i))
+	}
+
+	writer.Start()
+	filter.Start()
+
+	var pressured uint64
+	latencies := make([]time.Duration, 0, b.N)
+
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		// pace ourselves so that we never get ahead of the rate
+		if *benchRate > 0 {
+			due := start.Add(time.Duration(i) * time.Second /
+				time.Duration(*benchRate))
+			wait := due.Sub(time.Now())
+			if wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+
+		if filter.Backpressure() {
+			pressured++
+		}
+
+		before := time.Now()
+		filter.Process(records[i%len(records)])
+		latencies = append(latencies, time.Since(before))
+	}
+
+	filter.Stop()
+	writer.Stop()
+	b.StopTimer()
+
+	sort.Sort(durations(latencies))
+
+	b.ReportMetric(float64(pressured)*100/float64(b.N), "%pressured")
+	b.ReportMetric(float64(filter.Dropped()+writer.Dropped()), "dropped")
+	b.ReportMetric(float64(percentile(latencies, 0.99).Nanoseconds()),
+		"p99-ns")
+}
+
+// parseMix parses a command mix in the "command=weight,..." format.
+func parseMix(mix string) ([]share, error) {
+	shares := []share{}
+	total := 0
+	for _, entry := range strings.Split(mix, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.New("missing weight for " + entry)
+		}
+
+		if generate(parts[0], 0) == nil {
+			return nil, errors.New("unknown command " + parts[0])
+		}
+
+		weight, err := strconv.Atoi(parts[1])
+		if err != nil || weight < 0 {
+			return nil, errors.New("invalid weight for " + parts[0])
+		}
+
+		shares = append(shares, share{command: parts[0], weight: weight})
+		total += weight
+	}
+
+	if total == 0 {
+		return nil, errors.New("all weights are zero")
+	}
+
+	return shares, nil
+}
+
+// pick returns a random command according to the weights of the mix.
+func pick(shares []share) string {
+	total := 0
+	for _, s := range shares {
+		total += s.weight
+	}
+
+	n := rand.Intn(total)
+	for _, s := range shares {
+		if n < s.weight {
+			return s.command
+		}
+
+		n -= s.weight
+	}
+
+	return shares[len(shares)-1].command
+}
+
+// generate creates a synthetic record for the given command, or nil if the
+// command is unknown.
+func generate(command string, n uint64) adaptor.Record {
+	ra := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 8333}
+	la := &net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 8333}
+
+	var msg wire.Message
+	switch command {
+	case "tx":
+		tx := &wire.MsgTx{Version: 1}
+		tx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: wire.OutPoint{Hash: randomHash(),
+				Index: uint32(n)},
+			SignatureScript: randomBytes(107),
+			Sequence:        0xffffffff,
+		})
+		for i := 0; i < 2; i++ {
+			tx.AddTxOut(&wire.TxOut{
+				Value:    rand.Int63n(100000000),
+				PkScript: randomBytes(25),
+			})
+		}
+
+		msg = tx
+
+	case "inv":
+		inv := wire.NewMsgInv()
+		for i := 0; i < 10; i++ {
+			h := randomHash()
+			inv.AddInvVect(wire.NewInvVect(wire.InvTypeTx, &h))
+		}
+
+		msg = inv
+
+	case "addr":
+		addr := wire.NewMsgAddr()
+		for i := 0; i < 10; i++ {
+			ip := net.IPv4(byte(rand.Intn(223)+1), byte(rand.Intn(256)),
+				byte(rand.Intn(256)), byte(rand.Intn(256)))
+			addr.AddAddress(wire.NewNetAddressIPPort(ip, 8333,
+				wire.SFNodeNetwork))
+		}
+
+		msg = addr
+
+	case "ping":
+		msg = wire.NewMsgPing(n)
+
+	default:
+		return nil
+	}
+
+	return convertor.Message(msg, ra, la, time.Now())
+}
+
+// randomHash returns a random hash.
+func randomHash() wire.ShaHash {
+	var h wire.ShaHash
+	copy(h[:], randomBytes(len(h)))
+	return h
+}
+
+// randomBytes returns the given number of random bytes.
+func randomBytes(n int) []byte {
+	buf := make([]byte, n)
+	for i := range buf {
+		buf[i] = byte(rand.Intn(256))
+	}
+
+	return buf
+}
+
+// percentile returns the given percentile of the sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	i := int(float64(len(sorted)-1) * p)
+	return sorted[i]
+}
+
+// durations implements sort.Interface for a slice of durations.
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
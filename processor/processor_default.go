@@ -22,8 +22,13 @@ package processor
 
 import (
 	"errors"
+	"sync"
+	"sync/atomic"
 
 	"github.com/CIRCL/pbtc/adaptor"
+	"github.com/CIRCL/pbtc/geo"
+	"github.com/CIRCL/pbtc/records"
+	"github.com/CIRCL/pbtc/util"
 )
 
 type ProcessorType int
@@ -35,6 +40,10 @@ const (
 	FileWriterType
 	RedisWriterType
 	ZeroMQWriterType
+	FifoWriterType
+	OpReturnFilterType
+	PeerWriterType
+	BurstFilterType
 )
 
 func ParseType(processor string) (ProcessorType, error) {
@@ -57,6 +66,18 @@ func ParseType(processor string) (ProcessorType, error) {
 	case "ZEROMQ_WRITER":
 		return ZeroMQWriterType, nil
 
+	case "FIFO_WRITER":
+		return FifoWriterType, nil
+
+	case "OPRETURN_FILTER":
+		return OpReturnFilterType, nil
+
+	case "PEER_WRITER":
+		return PeerWriterType, nil
+
+	case "BURST_FILTER":
+		return BurstFilterType, nil
+
 	default:
 		return -1, errors.New("invalid processor string")
 	}
@@ -67,15 +88,344 @@ func New() (adaptor.Processor, error) {
 	return NewDummy()
 }
 
+const (
+	stateIdle = iota
+	stateRunning
+)
+
+// Processor contains the functionality shared by all filters and writers.
 type Processor struct {
-	log  adaptor.Log
-	next []adaptor.Processor
+	log   adaptor.Log
+	next  []adaptor.Processor
+	state uint32
+	mutex sync.Mutex
+	fault error
+	tag   string
+	name  string
+	geo   *geo.DB
+
+	stateHandler func(adaptor.State, adaptor.State)
+
+	paused  uint32
+	dropped uint64
+	output  uint64
+}
+
+// tagger is implemented by all processors embedding the default processor.
+type tagger interface {
+	setTag(string)
+}
+
+// SetTag sets a tag that is added to every record emitted by the processor,
+// so that records from several processors can share one output and still be
+// told apart downstream. Records that are already tagged keep their tag.
+func SetTag(tag string) func(adaptor.Processor) {
+	return func(pro adaptor.Processor) {
+		t, ok := pro.(tagger)
+		if !ok {
+			return
+		}
+
+		t.setTag(tag)
+	}
+}
+
+// namer is implemented by all processors embedding the default processor.
+type namer interface {
+	setName(string)
+}
+
+// SetName sets the name of the processor, which is added to its log messages,
+// so that several processors of the same type can be told apart.
+func SetName(name string) func(adaptor.Processor) {
+	return func(pro adaptor.Processor) {
+		n, ok := pro.(namer)
+		if !ok {
+			return
+		}
+
+		n.setName(name)
+	}
+}
+
+// stateNotifier is implemented by all processors embedding the default
+// processor.
+type stateNotifier interface {
+	setStateHandler(func(adaptor.State, adaptor.State))
+}
+
+// SetStateHandler sets a function that is called with the old and the new
+// state whenever the processor is started or stopped, or a writer becomes
+// faulty or recovers. It is called without holding any lock of the processor,
+// so it may call its methods.
+func SetStateHandler(
+	handler func(adaptor.State, adaptor.State)) func(adaptor.Processor) {
+	return func(pro adaptor.Processor) {
+		sn, ok := pro.(stateNotifier)
+		if !ok {
+			return
+		}
+
+		sn.setStateHandler(handler)
+	}
+}
+
+// geoSetter is implemented by all processors embedding the default processor.
+type geoSetter interface {
+	setGeo(*geo.DB)
+}
+
+// SetGeoDB sets the path of a geolocation database used to annotate every
+// record emitted by the processor with the country and autonomous system of
+// its remote peer. If the database can not be loaded, records are emitted
+// without annotation.
+func SetGeoDB(path string) func(adaptor.Processor) {
+	return func(pro adaptor.Processor) {
+		g, ok := pro.(geoSetter)
+		if !ok {
+			return
+		}
+
+		db, err := geo.Open(path)
+		if err != nil {
+			return
+		}
+
+		g.setGeo(db)
+	}
+}
+
+// SetGeo sets an already loaded geolocation database, so that callers who
+// want to report failures to load it can open it themselves.
+func SetGeo(db *geo.DB) func(adaptor.Processor) {
+	return func(pro adaptor.Processor) {
+		g, ok := pro.(geoSetter)
+		if !ok {
+			return
+		}
+
+		g.setGeo(db)
+	}
+}
+
+// Healthy returns whether the processor is running and its last operation
+// succeeded.
+func (pro *Processor) Healthy() (bool, error) {
+	if atomic.LoadUint32(&pro.state) != stateRunning {
+		return false, errors.New("processor not running")
+	}
+
+	pro.mutex.Lock()
+	defer pro.mutex.Unlock()
+
+	if pro.fault != nil {
+		return false, pro.fault
+	}
+
+	return true, nil
 }
 
 func (pro *Processor) SetLog(log adaptor.Log) {
-	pro.log = log
+	pro.log = util.NamedLog(log, pro.name)
+}
+
+// Name returns the name the processor was created with.
+func (pro *Processor) Name() string {
+	return pro.name
 }
 
 func (pro *Processor) AddNext(next adaptor.Processor) {
 	pro.next = append(pro.next, next)
 }
+
+// Next returns the processors we forward records to.
+func (pro *Processor) Next() []adaptor.Processor {
+	return pro.next
+}
+
+// nextPressure returns whether one of the processors we forward to reports
+// backpressure.
+func (pro *Processor) nextPressure() bool {
+	for _, next := range pro.next {
+		if next.Backpressure() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Pause makes the processor discard all records it receives until it is
+// resumed, without losing its configuration or place in the chain. Discarded
+// records are counted.
+func (pro *Processor) Pause() {
+	atomic.StoreUint32(&pro.paused, 1)
+}
+
+// Resume makes a paused processor handle records again.
+func (pro *Processor) Resume() {
+	atomic.StoreUint32(&pro.paused, 0)
+}
+
+// Dropped returns the number of records discarded while the processor was
+// paused.
+func (pro *Processor) Dropped() uint64 {
+	return atomic.LoadUint64(&pro.dropped)
+}
+
+// Written returns the number of bytes the processor wrote to its output. It
+// is zero for filters, which only forward records.
+func (pro *Processor) Written() uint64 {
+	return atomic.LoadUint64(&pro.output)
+}
+
+// addWritten counts bytes written to the output.
+func (pro *Processor) addWritten(n int) {
+	atomic.AddUint64(&pro.output, uint64(n))
+}
+
+// runFilter hands the records received on the queue to the validation of a
+// filter until the signal channel is closed, forwarding the ones that pass.
+// Session summaries describe the whole session and always pass. Once stopped,
+// what is left in the queue is handled as well, so that the summary sent on
+// shutdown is not lost.
+func (pro *Processor) runFilter(sig <-chan struct{},
+	recordQ <-chan adaptor.Record, valid func(adaptor.Record) bool,
+	forward func(adaptor.Record)) {
+	handle := func(record adaptor.Record) {
+		if record.Command() == records.CmdSummary || valid(record) {
+			forward(record)
+		}
+	}
+
+ProcessLoop:
+	for {
+		select {
+		case _, ok := <-sig:
+			if !ok {
+				break ProcessLoop
+			}
+
+		case record := <-recordQ:
+			handle(record)
+		}
+	}
+
+	for {
+		select {
+		case record := <-recordQ:
+			handle(record)
+
+		default:
+			return
+		}
+	}
+}
+
+// discard returns whether a received record should be dropped because the
+// processor is paused, in which case it is counted.
+func (pro *Processor) discard() bool {
+	if atomic.LoadUint32(&pro.paused) == 0 {
+		return false
+	}
+
+	atomic.AddUint64(&pro.dropped, 1)
+	return true
+}
+
+// Fault returns the error of the last operation of the processor, if it
+// failed. Unlike Healthy, it keeps reporting it once the processor is stopped,
+// so that failures on shutdown, like a file that could not be closed, can be
+// noticed.
+func (pro *Processor) Fault() error {
+	pro.mutex.Lock()
+	defer pro.mutex.Unlock()
+
+	return pro.fault
+}
+
+// markRunning flags the processor as started.
+func (pro *Processor) markRunning() {
+	pro.transition(func() {
+		atomic.StoreUint32(&pro.state, stateRunning)
+	})
+}
+
+// markStopped flags the processor as stopped.
+func (pro *Processor) markStopped() {
+	pro.transition(func() {
+		atomic.StoreUint32(&pro.state, stateIdle)
+	})
+}
+
+// markFault remembers the result of the last operation of the processor, so
+// it can be reported on health checks. A nil error clears the fault.
+func (pro *Processor) markFault(err error) {
+	pro.transition(func() {
+		pro.fault = err
+	})
+}
+
+// transition applies a change to the state or the fault of the processor and
+// reports the resulting change of state to the state handler, once the mutex
+// is released.
+func (pro *Processor) transition(change func()) {
+	pro.mutex.Lock()
+	old := pro.current()
+	change()
+	state := pro.current()
+	handler := pro.stateHandler
+	pro.mutex.Unlock()
+
+	if handler != nil && state != old {
+		handler(old, state)
+	}
+}
+
+// current returns the state of the processor as reported to the state
+// handler. It has to be called with the mutex held.
+func (pro *Processor) current() adaptor.State {
+	if atomic.LoadUint32(&pro.state) == stateIdle {
+		return adaptor.StateIdle
+	}
+
+	if pro.fault != nil {
+		return adaptor.StateFaulty
+	}
+
+	return adaptor.StateRunning
+}
+
+func (pro *Processor) setName(name string) {
+	pro.name = name
+}
+
+func (pro *Processor) setTag(tag string) {
+	pro.tag = tag
+}
+
+func (pro *Processor) setStateHandler(
+	handler func(adaptor.State, adaptor.State)) {
+	pro.stateHandler = handler
+}
+
+func (pro *Processor) setGeo(db *geo.DB) {
+	pro.geo = db
+}
+
+// tagged returns the record with the location of its remote peer, if a
+// geolocation database is set, and the tag of the processor, if one is set.
+func (pro *Processor) tagged(record adaptor.Record) adaptor.Record {
+	if pro.geo != nil && record.RemoteAddress() != nil {
+		loc := pro.geo.Lookup(record.RemoteAddress().IP)
+		if loc.Country != "" || loc.ASN != 0 {
+			record = records.NewGeoRecord(record, loc.Country, loc.ASN)
+		}
+	}
+
+	if pro.tag == "" {
+		return record
+	}
+
+	return records.NewTaggedRecord(record, pro.tag)
+}
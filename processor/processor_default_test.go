@@ -0,0 +1,339 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package processor
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/CIRCL/pbtc/adaptor"
+	"github.com/CIRCL/pbtc/pbtctest"
+	"github.com/CIRCL/pbtc/records"
+)
+
+func TestRunFilter(t *testing.T) {
+	filter, err := NewCommandFilter(SetCommands("tx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := pbtctest.NewProcessor()
+	filter.SetLog(pbtctest.Log{})
+	filter.AddNext(next)
+	filter.Start()
+
+	stamp := time.Unix(1, 0)
+	filter.Process(testTx(testScript))
+	filter.Process(records.NewPingRecord(wire.NewMsgPing(1), nil, nil,
+		stamp))
+	filter.Process(records.NewSummaryRecord(stamp, stamp, 0, 0, 0, 0,
+		map[string]uint64{}))
+	filter.Stop()
+
+	forwarded := next.Records()
+	if len(forwarded) != 2 {
+		t.Fatalf("forwarded %v records", len(forwarded))
+	}
+
+	if forwarded[0].Command() != "tx" ||
+		forwarded[1].Command() != records.CmdSummary {
+		t.Errorf("forwarded %v and %v", forwarded[0].Command(),
+			forwarded[1].Command())
+	}
+}
+
+func TestTagged(t *testing.T) {
+	dir := t.TempDir()
+	writer, err := NewFileWriter(SetFilePath(dir), SetFileHeader(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txs, err := NewCommandFilter(SetCommands("tx"), SetTag("txs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pings, err := NewCommandFilter(SetCommands("ping"), SetTag("pings"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pro := range []adaptor.Processor{writer, txs, pings} {
+		pro.SetLog(pbtctest.Log{})
+		pro.Start()
+	}
+
+	txs.AddNext(writer)
+	pings.AddNext(writer)
+
+	tx := testTx(testScript)
+	ping := records.NewPingRecord(wire.NewMsgPing(1), nil, nil,
+		time.Unix(1, 0))
+	txs.Process(tx)
+	pings.Process(ping)
+
+	txs.Stop()
+	pings.Stop()
+	writer.Stop()
+
+	data, err := ioutil.ReadFile(writer.file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("wrote %v lines", len(lines))
+	}
+
+	// each line carries the tag of the filter that captured it
+	expected := map[string]bool{
+		"txs" + records.Delimiter1 + tx.String():     true,
+		"pings" + records.Delimiter1 + ping.String(): true,
+	}
+	for _, line := range lines {
+		if !expected[line] {
+			t.Errorf("unexpected line %q", line)
+		}
+
+		delete(expected, line)
+	}
+}
+
+func TestGeoDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geo.csv")
+	err := ioutil.WriteFile(path, []byte("8.8.8.0/24,US,15169\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a missing database leaves records as they are
+	for _, geoPath := range []string{path, path + ".missing"} {
+		filter, err := NewCommandFilter(SetCommands("ping"),
+			SetGeoDB(geoPath))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		next := pbtctest.NewProcessor()
+		filter.SetLog(pbtctest.Log{})
+		filter.AddNext(next)
+		filter.Start()
+
+		ra := &net.TCPAddr{IP: net.ParseIP("8.8.8.8"), Port: 8333}
+		filter.Process(records.NewPingRecord(wire.NewMsgPing(1), ra, nil,
+			time.Unix(1, 0)))
+		filter.Stop()
+
+		forwarded := next.Records()
+		if len(forwarded) != 1 {
+			t.Fatalf("%v: forwarded %v records", geoPath, len(forwarded))
+		}
+
+		record, ok := forwarded[0].(*records.GeoRecord)
+		if geoPath != path {
+			if ok {
+				t.Error("annotated without database")
+			}
+			continue
+		}
+
+		if !ok || record.Country() != "US" || record.ASN() != 15169 {
+			t.Errorf("forwarded %v", forwarded[0])
+		}
+	}
+}
+
+func TestWritten(t *testing.T) {
+	dir := t.TempDir()
+	writer, err := NewFileWriter(SetFilePath(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer.SetLog(pbtctest.Log{})
+	writer.Start()
+
+	record := testTx(testScript)
+	writer.Process(record)
+	writer.Stop()
+
+	if writer.Written() < uint64(len(record.String())) {
+		t.Errorf("wrote %v bytes for a %v byte record", writer.Written(),
+			len(record.String()))
+	}
+}
+
+func TestHealthy(t *testing.T) {
+	pro, err := NewDummy()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pro.SetLog(pbtctest.Log{})
+
+	ok, _ := pro.Healthy()
+	if ok {
+		t.Error("healthy before start")
+	}
+
+	pro.Start()
+
+	ok, err = pro.Healthy()
+	if !ok {
+		t.Errorf("unhealthy while running (%v)", err)
+	}
+
+	pro.markFault(errors.New("disk full"))
+	ok, err = pro.Healthy()
+	if ok || err == nil || err.Error() != "disk full" {
+		t.Errorf("healthy %v after fault (%v)", ok, err)
+	}
+
+	pro.markFault(nil)
+	ok, _ = pro.Healthy()
+	if !ok {
+		t.Error("unhealthy after recovery")
+	}
+
+	pro.Stop()
+
+	ok, _ = pro.Healthy()
+	if ok {
+		t.Error("healthy after stop")
+	}
+}
+
+func TestBackpressure(t *testing.T) {
+	// the writer is not started, so nothing takes lines off its queue
+	writer, err := NewFileWriter(SetFilePath(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filter, err := NewCommandFilter(SetCommands("tx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filter.AddNext(writer)
+
+	if writer.Backpressure() || filter.Backpressure() {
+		t.Fatal("backpressure with empty queues")
+	}
+
+	for i := 0; i < cap(writer.txtQ); i++ {
+		writer.Write([]byte("line\n"))
+	}
+
+	if !writer.Backpressure() {
+		t.Error("no backpressure with full writer queue")
+	}
+
+	if !filter.Backpressure() {
+		t.Error("backpressure of writer not passed on by filter")
+	}
+}
+
+func TestPause(t *testing.T) {
+	filter, err := NewCommandFilter(SetCommands("ping"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := pbtctest.NewProcessor()
+	filter.AddNext(next)
+	filter.SetLog(pbtctest.Log{})
+	filter.Start()
+
+	ping := func(nonce uint64) adaptor.Record {
+		return records.NewPingRecord(wire.NewMsgPing(nonce), nil, nil,
+			time.Unix(1, 0))
+	}
+
+	filter.Process(ping(1))
+	filter.Pause()
+	filter.Process(ping(2))
+	filter.Process(ping(3))
+	filter.Resume()
+	filter.Process(ping(4))
+
+	if !next.Wait(2, time.Second) {
+		t.Fatalf("forwarded %v records", len(next.Records()))
+	}
+
+	filter.Stop()
+
+	if filter.Dropped() != 2 {
+		t.Errorf("dropped %v records while paused", filter.Dropped())
+	}
+
+	// the records received while paused are dropped, the others flow through
+	recs := next.Records()
+	if len(recs) != 2 || recs[0].String() != ping(1).String() ||
+		recs[1].String() != ping(4).String() {
+		t.Errorf("forwarded %v", recs)
+	}
+}
+
+func TestStateHandler(t *testing.T) {
+	var pro *DummyFilter
+	var transitions [][2]adaptor.State
+	handler := func(old adaptor.State, state adaptor.State) {
+		// the handler may call back into the processor
+		pro.Fault()
+		transitions = append(transitions, [2]adaptor.State{old, state})
+	}
+
+	pro, err := NewDummy(SetStateHandler(handler))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pro.SetLog(pbtctest.Log{})
+
+	pro.Start()
+	pro.markFault(errors.New("disk full"))
+	pro.markFault(errors.New("still full"))
+	pro.markFault(nil)
+	pro.Stop()
+
+	// only changes of state are reported
+	expected := [][2]adaptor.State{
+		{adaptor.StateIdle, adaptor.StateRunning},
+		{adaptor.StateRunning, adaptor.StateFaulty},
+		{adaptor.StateFaulty, adaptor.StateRunning},
+		{adaptor.StateRunning, adaptor.StateIdle},
+	}
+
+	if !reflect.DeepEqual(transitions, expected) {
+		t.Errorf("transitions %v instead of %v", transitions, expected)
+	}
+}
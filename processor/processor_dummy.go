@@ -56,12 +56,16 @@ func (filter *DummyFilter) Start() {
 	filter.wg.Add(1)
 	go filter.goProcess()
 
+	filter.markRunning()
+
 	filter.log.Info("[PFD] Start: completed")
 }
 
 func (filter *DummyFilter) Stop() {
 	filter.log.Info("[PFD] Stop: begin")
 
+	filter.markStopped()
+
 	close(filter.sig)
 	filter.wg.Wait()
 
@@ -71,28 +75,25 @@ func (filter *DummyFilter) Stop() {
 // Process will add a new record to the queue of the dummy filter, which will
 // in turn be forwarded to the following processors.
 func (filter *DummyFilter) Process(record adaptor.Record) {
+	if filter.discard() {
+		return
+	}
+
 	filter.recordQ <- record
 }
 
+// Backpressure returns whether the filter or one of the processors it forwards
+// to can not keep up with the records.
+func (filter *DummyFilter) Backpressure() bool {
+	return len(filter.recordQ) == cap(filter.recordQ) || filter.nextPressure()
+}
+
 // goProcess has to be called as a go routine. It will process and forward
 // all messages in the record queue.
 func (filter *DummyFilter) goProcess() {
 	defer filter.wg.Done()
 
-ProcessLoop:
-	for {
-		select {
-		case _, ok := <-filter.sig:
-			if !ok {
-				break ProcessLoop
-			}
-
-		case record := <-filter.recordQ:
-			if filter.valid(record) {
-				filter.forward(record)
-			}
-		}
-	}
+	filter.runFilter(filter.sig, filter.recordQ, filter.valid, filter.forward)
 }
 
 // valid for dummy filter simply returns true for every record
@@ -102,6 +103,7 @@ func (filter *DummyFilter) valid(record adaptor.Record) bool {
 
 // forward will send the message to the following processors for processing.
 func (filter *DummyFilter) forward(record adaptor.Record) {
+	record = filter.tagged(record)
 	for _, processor := range filter.next {
 		processor.Process(record)
 	}
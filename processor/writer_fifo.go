@@ -0,0 +1,197 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package processor
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/CIRCL/pbtc/adaptor"
+)
+
+const (
+	fifoRetry   = time.Second
+	fifoTimeout = 100 * time.Millisecond
+)
+
+// FifoWriter writes records to a named pipe, so that local consumers can read
+// them without going through the disk. If no reader is attached, or it can't
+// keep up, records are dropped and counted instead of blocking the processors
+// feeding the writer. The pipe is reopened whenever a new reader attaches.
+type FifoWriter struct {
+	Processor
+
+	path    string
+	pipe    *os.File
+	lineQ   chan string
+	sig     chan struct{}
+	wg      *sync.WaitGroup
+	dropped uint64
+}
+
+func NewFifoWriter(options ...func(adaptor.Processor)) (*FifoWriter, error) {
+	w := &FifoWriter{
+		path:  "pbtc.fifo",
+		lineQ: make(chan string, 1024),
+		sig:   make(chan struct{}),
+		wg:    &sync.WaitGroup{},
+	}
+
+	for _, option := range options {
+		option(w)
+	}
+
+	// create the named pipe if it doesn't exist yet
+	_, err := os.Stat(w.path)
+	if os.IsNotExist(err) {
+		err = syscall.Mkfifo(w.path, 0666)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// SetFifoPath sets the path of the named pipe to write to.
+func SetFifoPath(path string) func(adaptor.Processor) {
+	return func(pro adaptor.Processor) {
+		w, ok := pro.(*FifoWriter)
+		if !ok {
+			return
+		}
+
+		w.path = path
+	}
+}
+
+func (w *FifoWriter) Start() {
+	w.log.Info("[PWP] Start: begin")
+
+	w.wg.Add(1)
+	go w.goLines()
+
+	w.markRunning()
+
+	w.log.Info("[PWP] Start: completed")
+}
+
+func (w *FifoWriter) Stop() {
+	w.log.Info("[PWP] Stop: begin")
+
+	w.markStopped()
+
+	close(w.sig)
+	w.wg.Wait()
+
+	w.log.Info("[PWP] Stop: completed")
+}
+
+// Process queues the record for writing, or drops it if the queue is full.
+func (w *FifoWriter) Process(record adaptor.Record) {
+	w.log.Debug("[PWP] Process: %v", record.Command())
+
+	if w.discard() {
+		return
+	}
+
+	select {
+	case w.lineQ <- w.tagged(record).String():
+	default:
+		atomic.AddUint64(&w.dropped, 1)
+	}
+}
+
+// Backpressure returns whether the writer can not keep up with the records, in
+// which case its queue is full.
+func (w *FifoWriter) Backpressure() bool {
+	return len(w.lineQ) == cap(w.lineQ)
+}
+
+// Dropped returns the number of records dropped because no reader was attached
+// or it was too slow.
+func (w *FifoWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+func (w *FifoWriter) goLines() {
+	defer w.wg.Done()
+
+	retry := time.NewTicker(fifoRetry)
+	defer retry.Stop()
+
+LineLoop:
+	for {
+		select {
+		case _, ok := <-w.sig:
+			if !ok {
+				break LineLoop
+			}
+
+		case <-retry.C:
+			if w.pipe == nil {
+				w.open()
+			}
+
+		case line := <-w.lineQ:
+			if w.pipe == nil {
+				atomic.AddUint64(&w.dropped, 1)
+				continue
+			}
+
+			w.pipe.SetWriteDeadline(time.Now().Add(fifoTimeout))
+			n, err := w.pipe.WriteString(line + "\n")
+			w.addWritten(n)
+			if err == nil {
+				continue
+			}
+
+			atomic.AddUint64(&w.dropped, 1)
+			if os.IsTimeout(err) {
+				continue
+			}
+
+			// the reader went away, wait for the next one
+			w.log.Info("[PWP] Reader detached from %v (%v)", w.path, err)
+			w.pipe.Close()
+			w.pipe = nil
+		}
+	}
+
+	if w.pipe != nil {
+		w.pipe.Close()
+	}
+}
+
+// open tries to open the named pipe for writing. Opening it in non-blocking
+// mode fails right away if there is no reader, so we can try again later.
+func (w *FifoWriter) open() {
+	pipe, err := os.OpenFile(w.path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return
+	}
+
+	w.log.Info("[PWP] Reader attached to %v", w.path)
+	w.pipe = pipe
+}
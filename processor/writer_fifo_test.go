@@ -0,0 +1,109 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package processor
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/CIRCL/pbtc/pbtctest"
+)
+
+func TestFifoWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pbtc.fifo")
+	w, err := NewFifoWriter(SetFifoPath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.SetLog(pbtctest.Log{})
+	w.Start()
+	defer w.Stop()
+
+	// without a reader, records are dropped instead of blocking
+	record := testTx(testScript)
+	w.Process(record)
+
+	for i := 0; i < 100 && w.Dropped() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if w.Dropped() != 1 {
+		t.Fatalf("dropped %v records without reader", w.Dropped())
+	}
+
+	pipe, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pipe.Close()
+
+	// reading an unattached pipe returns EOF right away, so we keep trying
+	lines := make(chan string, 1)
+	go func() {
+		reader := bufio.NewReader(pipe)
+		partial := ""
+		for {
+			line, err := reader.ReadString('\n')
+			partial += line
+			if err == io.EOF {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			if err != nil {
+				return
+			}
+
+			select {
+			case lines <- strings.TrimSuffix(partial, "\n"):
+			default:
+			}
+
+			partial = ""
+		}
+	}()
+
+	// the writer attaches to the reader on its next retry
+	deadline := time.After(3 * fifoRetry)
+	for {
+		w.Process(record)
+
+		select {
+		case line := <-lines:
+			if line != record.String() {
+				t.Errorf("read %q", line)
+			}
+
+			return
+
+		case <-time.After(10 * time.Millisecond):
+
+		case <-deadline:
+			t.Fatal("nothing read from the pipe")
+		}
+	}
+}
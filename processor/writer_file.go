@@ -21,17 +21,84 @@
 package processor
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/CIRCL/pbtc/adaptor"
 	"github.com/CIRCL/pbtc/compressor"
+	"github.com/CIRCL/pbtc/records"
 )
 
 const Version = "PBTC Log Version 1"
 
+// Encoding defines how the file writer encodes records. Text writes one record
+// per line, preceded by a version line, while proto writes each record as a
+// protobuf message prefixed by its length as a varint, as described in the
+// records package. Compact writes the binary payload of each record and keeps
+// commands and addresses in a dictionary for each file, which makes it the
+// smallest for archives; the replay package reads it back. Flat writes each
+// record as size prefixed flatbuffer, which consumers can read in place.
+type Encoding int
+
+const (
+	TextEncoding Encoding = iota
+	ProtoEncoding
+	CompactEncoding
+	FlatEncoding
+)
+
+// ParseEncoding returns the encoding for the given configuration string.
+func ParseEncoding(encoding string) (Encoding, error) {
+	switch encoding {
+	case "TEXT":
+		return TextEncoding, nil
+
+	case "PROTO":
+		return ProtoEncoding, nil
+
+	case "COMPACT":
+		return CompactEncoding, nil
+
+	case "FLAT":
+		return FlatEncoding, nil
+
+	default:
+		return -1, errors.New("invalid file encoding string")
+	}
+}
+
+// CompressionStats describes how well the files of a writer compress. The
+// sizes and ratio are those of the last rotated file, while the average ratio
+// is taken over all files. The ratio is the original size divided by the
+// compressed size.
+type CompressionStats struct {
+	Files          uint64
+	OriginalSize   int64
+	CompressedSize int64
+	Ratio          float64
+	AverageRatio   float64
+}
+
+// IndexEntry describes a finished output file in the index of a file writer.
+// File is the path of the file to load, which is the compressed copy unless
+// its compression failed. Size is its size in bytes.
+type IndexEntry struct {
+	File    string
+	Start   time.Time
+	End     time.Time
+	Records uint64
+	Size    int64
+}
+
 type FileWriter struct {
 	Processor
 
@@ -39,8 +106,14 @@ type FileWriter struct {
 	comp       adaptor.Compressor
 	fileTicker *time.Ticker
 	file       *os.File
+	out        io.Writer
+	written    int64
+	started    time.Time
+	records    uint64
 	sig        chan struct{}
 	txtQ       chan string
+	recQ       chan adaptor.Record
+	compact    *records.CompactEncoder
 
 	filePath      string
 	filePrefix    string
@@ -48,6 +121,18 @@ type FileWriter struct {
 	fileSuffix    string
 	fileSizelimit int64
 	fileAgelimit  time.Duration
+	fileStream    bool
+	fileHeader    bool
+	encoding      Encoding
+	indexPath     string
+	diskLimit     int64
+	upload        func(string) error
+
+	statsMutex sync.Mutex
+	compStats  CompressionStats
+
+	uploadMutex sync.Mutex
+	uploads     map[string]struct{}
 }
 
 func NewFileWriter(options ...func(adaptor.Processor)) (*FileWriter, error) {
@@ -58,10 +143,16 @@ func NewFileWriter(options ...func(adaptor.Processor)) (*FileWriter, error) {
 		fileSuffix:    ".log",
 		fileSizelimit: 1048576,
 		fileAgelimit:  3600 * time.Second,
+		fileHeader:    true,
 
 		sig:  make(chan struct{}),
 		wg:   &sync.WaitGroup{},
 		txtQ: make(chan string, 1),
+		recQ: make(chan adaptor.Record, 1),
+
+		uploads: make(map[string]struct{}),
+
+		compact: records.NewCompactEncoder(),
 	}
 
 	for _, option := range options {
@@ -137,6 +228,48 @@ func SetFileSuffix(suffix string) func(adaptor.Processor) {
 	}
 }
 
+// SetFileHeader sets whether text files start with a line giving the version
+// of the format. Users of the writer that write other text, like the logger,
+// turn it off.
+func SetFileHeader(header bool) func(adaptor.Processor) {
+	return func(pro adaptor.Processor) {
+		w, ok := pro.(*FileWriter)
+		if !ok {
+			return
+		}
+
+		w.fileHeader = header
+	}
+}
+
+// SetFileStream makes the writer compress the output while writing, instead of
+// compressing each file on rotation. This avoids keeping the uncompressed file
+// on disk, but the active file can no longer be followed with tools like tail.
+// The compressed output is flushed every second, if the compressor supports
+// it. The size limit then applies to the compressed size.
+func SetFileStream(stream bool) func(adaptor.Processor) {
+	return func(pro adaptor.Processor) {
+		w, ok := pro.(*FileWriter)
+		if !ok {
+			return
+		}
+
+		w.fileStream = stream
+	}
+}
+
+// SetFileEncoding sets the encoding used to write the records to the file.
+func SetFileEncoding(encoding Encoding) func(adaptor.Processor) {
+	return func(pro adaptor.Processor) {
+		w, ok := pro.(*FileWriter)
+		if !ok {
+			return
+		}
+
+		w.encoding = encoding
+	}
+}
+
 // SetSizeLimit sets the size limit upon which the logs will rotate.
 func SetFileSizelimit(sizelimit int64) func(adaptor.Processor) {
 	return func(pro adaptor.Processor) {
@@ -161,61 +294,347 @@ func SetFileAgelimit(agelimit time.Duration) func(adaptor.Processor) {
 	}
 }
 
+// SetIndexPath sets the path of an index file. Whenever an output file is
+// finished, a line describing it is appended to the index as JSON, so that
+// loaders can find the files in order without scanning the directory.
+func SetIndexPath(path string) func(adaptor.Processor) {
+	return func(pro adaptor.Processor) {
+		w, ok := pro.(*FileWriter)
+		if !ok {
+			return
+		}
+
+		w.indexPath = path
+	}
+}
+
+// SetMaxDiskUsage sets the number of bytes the files of the writer may take up
+// in its directory. After each rotation, the oldest finished files are deleted
+// until the total is below the limit, so that unattended captures do not fill
+// the disk. Only files with the prefix and suffix of the writer are counted;
+// the file being written and files still being uploaded are never deleted.
+// Zero means no limit.
+func SetMaxDiskUsage(limit int64) func(adaptor.Processor) {
+	return func(pro adaptor.Processor) {
+		w, ok := pro.(*FileWriter)
+		if !ok {
+			return
+		}
+
+		w.diskLimit = limit
+	}
+}
+
+// SetFileUpload sets a function that is called with the path of each finished
+// file, for example to upload it to S3. It runs in the background and the
+// file is not pruned before it returns. Stopping the writer waits for running
+// uploads.
+func SetFileUpload(upload func(path string) error) func(adaptor.Processor) {
+	return func(pro adaptor.Processor) {
+		w, ok := pro.(*FileWriter)
+		if !ok {
+			return
+		}
+
+		w.upload = upload
+	}
+}
+
 func (w *FileWriter) Start() {
 	w.log.Info("[PWF] Start: begin")
 
 	w.rotateLog()
 
-	w.fileTicker = time.NewTicker(w.fileAgelimit)
+	// without an age limit, there is no timer, as a ticker can't have a zero
+	// interval
+	if w.fileAgelimit > 0 {
+		w.fileTicker = time.NewTicker(w.fileAgelimit)
+	}
 
 	w.wg.Add(1)
 	go w.goProcess()
 
+	w.markRunning()
+
 	w.log.Info("[PWF] Start: completed")
 }
 
 func (w *FileWriter) Stop() {
 	w.log.Info("[PWF] Stop: begin")
 
+	w.markStopped()
+
 	close(w.sig)
 	w.wg.Wait()
 
+	if w.fileTicker != nil {
+		w.fileTicker.Stop()
+	}
+
 	w.log.Info("[PWF] Stop: completed")
 }
 
 func (w *FileWriter) Process(record adaptor.Record) {
 	w.log.Debug("[PWF] Process: %v", record.Command())
 
-	w.txtQ <- record.String()
+	if w.discard() {
+		return
+	}
+
+	if w.encoding == ProtoEncoding {
+		w.processProto(record)
+		return
+	}
+
+	if w.encoding == FlatEncoding {
+		w.txtQ <- string(records.Flat(w.tagged(record)))
+		return
+	}
+
+	// the dictionary belongs to the file, so compact records are encoded by
+	// the write loop, which also rotates the files
+	if w.encoding == CompactEncoding {
+		w.recQ <- w.tagged(record)
+		return
+	}
+
+	w.txtQ <- w.tagged(record).String() + "\n"
+}
+
+// Write queues the given data for the output as it is, so that the writer and
+// its rotation can be used for other text than records, like log lines. Once
+// the writer is stopped, the data is discarded.
+func (w *FileWriter) Write(data []byte) (int, error) {
+	// a stopped writer could still have room in its queue
+	select {
+	case <-w.sig:
+		return 0, errors.New("writer stopped")
+
+	default:
+	}
+
+	select {
+	case w.txtQ <- string(data):
+		return len(data), nil
+
+	case <-w.sig:
+		return 0, errors.New("writer stopped")
+	}
+}
+
+// Backpressure returns whether the writer can not keep up with the records, in
+// which case its queue is full.
+func (w *FileWriter) Backpressure() bool {
+	return len(w.txtQ) == cap(w.txtQ) || len(w.recQ) == cap(w.recQ)
+}
+
+// processProto queues the record as length-delimited protobuf message.
+func (w *FileWriter) processProto(record adaptor.Record) {
+	pr, ok := w.tagged(record).(interface {
+		Proto() ([]byte, error)
+	})
+	if !ok {
+		w.log.Warning("[PWF] No protobuf encoding for %v", record.Command())
+		return
+	}
+
+	msg, err := pr.Proto()
+	if err != nil {
+		w.log.Warning("[PWF] Could not encode %v (%v)", record.Command(), err)
+		return
+	}
+
+	w.txtQ <- string(records.ProtoDelimited(msg))
 }
 
+// goProcess is to be launched as a go routine. It runs the write loop and
+// restarts it if it fails unexpectedly, so that a single error does not stop
+// all recording for good.
 func (w *FileWriter) goProcess() {
 	defer w.wg.Done()
 
-WriteLoop:
+	for !w.process() {
+		w.log.Error("[PWF] Write loop failed, restarting")
+		time.Sleep(time.Second)
+	}
+
+	w.closeLog()
+	w.indexLog()
+	w.uploadLog()
+}
+
+// finalName returns the path of the finished file, which is the compressed
+// copy if there is one.
+func (w *FileWriter) finalName() string {
+	name := w.file.Name()
+	if !w.fileStream {
+		_, err := os.Stat(name + ".out")
+		if err == nil {
+			name += ".out"
+		}
+	}
+
+	return name
+}
+
+// uploadLog hands the finished file to the upload function, if there is one,
+// and keeps it from being pruned until the upload is done.
+func (w *FileWriter) uploadLog() {
+	if w.upload == nil {
+		return
+	}
+
+	name := w.finalName()
+
+	w.uploadMutex.Lock()
+	w.uploads[filepath.Base(name)] = struct{}{}
+	w.uploadMutex.Unlock()
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+
+		err := w.upload(name)
+		if err != nil {
+			w.log.Warning("[PWF] Could not upload %v (%v)", name, err)
+		}
+
+		w.uploadMutex.Lock()
+		delete(w.uploads, filepath.Base(name))
+		w.uploadMutex.Unlock()
+	}()
+}
+
+// uploading returns whether the file with the given name is being uploaded.
+func (w *FileWriter) uploading(name string) bool {
+	w.uploadMutex.Lock()
+	defer w.uploadMutex.Unlock()
+
+	_, ok := w.uploads[name]
+	return ok
+}
+
+// process runs the write loop. It returns true if the writer was stopped and
+// false if the loop panicked, in which case the writer is marked as faulty.
+func (w *FileWriter) process() (stopped bool) {
+	defer func() {
+		r := recover()
+		if r != nil {
+			w.markFault(fmt.Errorf("write loop panic: %v", r))
+			w.log.Critical("[PWF] Write loop panic (%v)", r)
+			stopped = false
+		}
+	}()
+
+	var flushC <-chan time.Time
+	if w.fileStream {
+		flushTicker := time.NewTicker(time.Second)
+		defer flushTicker.Stop()
+		flushC = flushTicker.C
+	}
+
+	var ageC <-chan time.Time
+	if w.fileTicker != nil {
+		ageC = w.fileTicker.C
+	}
+
 	for {
 		select {
 		case _, ok := <-w.sig:
 			if !ok {
-				break WriteLoop
+				w.drain()
+				return true
 			}
 
-		case <-w.fileTicker.C:
+		case <-ageC:
 			w.checkTime()
 
+		case <-flushC:
+			w.flush()
+
+		case txt := <-w.txtQ:
+			w.writeQueued(txt)
+
+		case record := <-w.recQ:
+			w.writeQueued(string(w.compact.Encode(record)))
+		}
+	}
+}
+
+// writeQueued writes what we took from the queue and rotates the file if it
+// is full.
+func (w *FileWriter) writeQueued(txt string) {
+	err := w.write(txt)
+	w.markFault(err)
+	if err != nil {
+		w.log.Error("[REC] Could not write txt file (%v)", err)
+	}
+
+	w.checkSize()
+}
+
+// drain writes what is left in the queue on shutdown, like the session
+// summary, so that it is not lost.
+func (w *FileWriter) drain() {
+	for {
+		select {
 		case txt := <-w.txtQ:
-			_, err := w.file.WriteString(txt + "\n")
+			err := w.write(txt)
+			if err != nil {
+				w.markFault(err)
+				w.log.Error("[PWF] Could not write txt file (%v)", err)
+			}
+
+		case record := <-w.recQ:
+			err := w.write(string(w.compact.Encode(record)))
 			if err != nil {
-				w.log.Error("[REC] Could not write txt file (%v)", err)
+				w.markFault(err)
+				w.log.Error("[PWF] Could not write txt file (%v)", err)
 			}
+
+		default:
+			return
 		}
 	}
+}
+
+// write writes the text to the output, which is either the file itself or the
+// compressing writer around it.
+func (w *FileWriter) write(txt string) error {
+	if w.out == nil {
+		return errors.New("no output file")
+	}
 
-	w.file.Close()
+	n, err := io.WriteString(w.out, txt)
+	w.written += int64(n)
+	w.addWritten(n)
+	if err != nil {
+		return err
+	}
+
+	w.records++
+
+	return nil
+}
+
+// flush pushes buffered data of the compressing writer to the file, so that
+// little is lost if we crash.
+func (w *FileWriter) flush() {
+	flusher, ok := w.out.(interface {
+		Flush() error
+	})
+	if !ok {
+		return
+	}
+
+	err := flusher.Flush()
+	if err != nil {
+		w.log.Warning("[PWF] Could not flush output (%v)", err)
+	}
 }
 
 func (w *FileWriter) checkTime() {
-	if w.fileAgelimit == 0 {
+	if w.fileAgelimit <= 0 {
 		return
 	}
 
@@ -227,9 +646,13 @@ func (w *FileWriter) checkSize() {
 		return
 	}
 
+	// if we can't check the file, we start a new one to be safe
 	fileStat, err := w.file.Stat()
 	if err != nil {
-		panic(err)
+		w.markFault(err)
+		w.log.Error("[PWF] Could not stat output file (%v)", err)
+		w.rotateLog()
+		return
 	}
 
 	if fileStat.Size() < w.fileSizelimit {
@@ -240,28 +663,135 @@ func (w *FileWriter) checkSize() {
 }
 
 func (w *FileWriter) rotateLog() {
-	stamp := time.Now().Format(w.fileName)
+	now := time.Now()
+	stamp := now.Format(w.fileName)
 	file, err := os.Create(w.filePath + w.filePrefix + stamp + w.fileSuffix)
 	if err != nil {
 		w.log.Error("Could not create file (%v)", err)
 		return
 	}
 
-	_, err = file.WriteString("#" + Version + "\n")
-	if err != nil {
-		w.log.Error("Could not write to file (%v)", err)
-		return
+	var out io.Writer = file
+	if w.fileStream {
+		out, err = w.comp.GetWriter(file)
+		if err != nil {
+			w.log.Error("Could not create compressing writer (%v)", err)
+			file.Close()
+			return
+		}
 	}
 
-	if w.file != nil {
-		w.compressLog()
-		err = w.file.Close()
+	if w.encoding == TextEncoding && w.fileHeader {
+		_, err = io.WriteString(out, "#"+Version+"\n")
 		if err != nil {
-			w.log.Warning("[REC] Could not close file on rotate (%v)", err)
+			w.log.Error("Could not write to file (%v)", err)
+			return
 		}
 	}
 
+	if w.encoding == CompactEncoding {
+		_, err = out.Write(records.CompactHeader())
+		if err != nil {
+			w.log.Error("Could not write to file (%v)", err)
+			return
+		}
+	}
+
+	if w.file != nil {
+		w.finishLog()
+	}
+
 	w.file = file
+	w.pruneDisk()
+
+	w.out = out
+	w.compact = records.NewCompactEncoder()
+	w.written = 0
+	w.started = now
+	w.records = 0
+}
+
+// finishLog compresses and closes the current file, then adds it to the index.
+func (w *FileWriter) finishLog() {
+	if !w.fileStream {
+		w.compressLog()
+	}
+
+	w.closeLog()
+	w.indexLog()
+	w.uploadLog()
+}
+
+// indexLog appends the entry of the current file to the index, if there is
+// one. The entry is written with a single append, so that readers never see
+// a partial line.
+func (w *FileWriter) indexLog() {
+	if w.indexPath == "" {
+		return
+	}
+
+	name := w.finalName()
+	entry := IndexEntry{
+		File:    name,
+		Start:   w.started,
+		End:     time.Now(),
+		Records: w.records,
+	}
+
+	fileStat, err := os.Stat(name)
+	if err == nil {
+		entry.Size = fileStat.Size()
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		w.log.Error("[PWF] Could not encode index entry (%v)", err)
+		return
+	}
+
+	index, err := os.OpenFile(w.indexPath,
+		os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		w.log.Error("[PWF] Could not open index file (%v)", err)
+		return
+	}
+
+	_, err = index.Write(append(line, '\n'))
+	if err == nil {
+		err = index.Sync()
+	}
+	if err != nil {
+		w.log.Error("[PWF] Could not write index entry (%v)", err)
+	}
+
+	index.Close()
+}
+
+// closeLog closes the current file. When compressing while writing, the
+// compressing writer is closed first to write out its buffers, and the sizes
+// are added to the compression statistics.
+func (w *FileWriter) closeLog() {
+	if w.fileStream {
+		closer, ok := w.out.(io.Closer)
+		if ok && w.out != io.Writer(w.file) {
+			err := closer.Close()
+			if err != nil {
+				w.markFault(err)
+				w.log.Error("[PWF] Failed to flush compressed file (%v)", err)
+			}
+		}
+
+		fileStat, err := w.file.Stat()
+		if err == nil {
+			w.addCompression(w.written, fileStat.Size())
+		}
+	}
+
+	err := w.file.Close()
+	if err != nil {
+		w.markFault(err)
+		w.log.Warning("[REC] Could not close file on rotate (%v)", err)
+	}
 }
 
 func (w *FileWriter) compressLog() {
@@ -277,15 +807,135 @@ func (w *FileWriter) compressLog() {
 		return
 	}
 
+	defer output.Close()
+
 	writer, err := w.comp.GetWriter(output)
 	if err != nil {
 		w.log.Error("[REC] Failed to create output writer (%v)", err)
 		return
 	}
 
-	_, err = io.Copy(writer, w.file)
+	original, err := io.Copy(writer, w.file)
 	if err != nil {
 		w.log.Error("[REC] Failed to compress log file (%v)", err)
 		return
 	}
+
+	// compressing writers need to be closed to flush their buffers
+	closer, ok := writer.(io.Closer)
+	if ok && writer != io.Writer(output) {
+		err = closer.Close()
+		if err != nil {
+			w.log.Error("[REC] Failed to flush compressed file (%v)", err)
+			return
+		}
+	}
+
+	outStat, err := output.Stat()
+	if err != nil {
+		w.log.Warning("[REC] Failed to stat compressed file (%v)", err)
+		return
+	}
+
+	w.addCompression(original, outStat.Size())
+}
+
+// pruneDisk deletes the oldest files of the writer while their total size is
+// above the disk usage limit.
+func (w *FileWriter) pruneDisk() {
+	if w.diskLimit == 0 {
+		return
+	}
+
+	infos, err := ioutil.ReadDir(w.filePath)
+	if err != nil {
+		w.log.Warning("[PWF] Could not list files to prune (%v)", err)
+		return
+	}
+
+	current := filepath.Base(w.file.Name())
+	total := int64(0)
+	files := make([]os.FileInfo, 0, len(infos))
+	for _, info := range infos {
+		if info.IsDir() || !w.ownsFile(info.Name()) {
+			continue
+		}
+
+		total += info.Size()
+		if info.Name() == current || w.uploading(info.Name()) {
+			continue
+		}
+
+		files = append(files, info)
+	}
+
+	sort.Sort(byModTime(files))
+	for _, info := range files {
+		if total <= w.diskLimit {
+			break
+		}
+
+		err = os.Remove(filepath.Join(w.filePath, info.Name()))
+		if err != nil {
+			w.log.Warning("[PWF] Could not prune file (%v)", err)
+			continue
+		}
+
+		w.log.Info("[PWF] Pruned %v to stay below disk limit", info.Name())
+		total -= info.Size()
+	}
+}
+
+// ownsFile returns whether a file in the directory was written by the writer,
+// either as it was written or as its compressed copy.
+func (w *FileWriter) ownsFile(name string) bool {
+	name = strings.TrimSuffix(name, ".out")
+	return strings.HasPrefix(name, w.filePrefix) &&
+		strings.HasSuffix(name, w.fileSuffix) &&
+		len(name) > len(w.filePrefix)+len(w.fileSuffix)
+}
+
+// byModTime sorts files from the oldest to the newest.
+type byModTime []os.FileInfo
+
+func (files byModTime) Len() int {
+	return len(files)
+}
+
+func (files byModTime) Less(i, j int) bool {
+	return files[i].ModTime().Before(files[j].ModTime())
+}
+
+func (files byModTime) Swap(i, j int) {
+	files[i], files[j] = files[j], files[i]
+}
+
+// addCompression adds the sizes of a rotated file to the compression
+// statistics and logs them.
+func (w *FileWriter) addCompression(original int64, compressed int64) {
+	w.statsMutex.Lock()
+	defer w.statsMutex.Unlock()
+
+	ratio := 0.0
+	if compressed > 0 {
+		ratio = float64(original) / float64(compressed)
+	}
+
+	stats := &w.compStats
+	stats.Files++
+	stats.OriginalSize = original
+	stats.CompressedSize = compressed
+	stats.Ratio = ratio
+	stats.AverageRatio += (ratio - stats.AverageRatio) / float64(stats.Files)
+
+	w.log.Info("[PWF] Compressed %v bytes to %v bytes (ratio %.2f, "+
+		"average %.2f)", original, compressed, ratio, stats.AverageRatio)
+}
+
+// CompressionStats returns the compression statistics of the writer.
+func (w *FileWriter) CompressionStats() CompressionStats {
+	w.statsMutex.Lock()
+	defer w.statsMutex.Unlock()
+
+	return w.compStats
 }
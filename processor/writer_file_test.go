@@ -0,0 +1,419 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package processor
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CIRCL/pbtc/compressor"
+	"github.com/CIRCL/pbtc/pbtctest"
+)
+
+// testStamp names files to the nanosecond, so that quick rotations in tests
+// do not overwrite each other.
+const testStamp = "2006-01-02T15:04:05.000000000Z07:00"
+
+func TestPruneDisk(t *testing.T) {
+	dir := t.TempDir() + "/"
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("%v.log", i))
+		err := ioutil.WriteFile(name, make([]byte, 100), 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stamp := now.Add(time.Duration(i-10) * time.Hour)
+		err = os.Chtimes(name, stamp, stamp)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// files without the prefix and suffix of the writer are not ours
+	err := ioutil.WriteFile(filepath.Join(dir, "notes.txt"),
+		make([]byte, 1000), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewFileWriter(SetFilePath(dir), SetFilePrefix(""),
+		SetFileName(testStamp), SetFileHeader(false), SetMaxDiskUsage(250))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.SetLog(pbtctest.Log{})
+	w.Start()
+	w.Stop()
+
+	for i := 0; i < 5; i++ {
+		_, err = os.Stat(filepath.Join(dir, fmt.Sprintf("%v.log", i)))
+		if i < 3 && err == nil {
+			t.Errorf("old file %v was not pruned", i)
+		}
+		if i >= 3 && err != nil {
+			t.Errorf("new file %v was pruned", i)
+		}
+	}
+
+	_, err = os.Stat(filepath.Join(dir, "notes.txt"))
+	if err != nil {
+		t.Error("pruned a file of somebody else")
+	}
+}
+
+func TestPruneDiskUpload(t *testing.T) {
+	dir := t.TempDir() + "/"
+	started := make(chan string, 8)
+	release := make(chan struct{})
+	upload := func(path string) error {
+		started <- path
+		<-release
+		return nil
+	}
+
+	w, err := NewFileWriter(SetFilePath(dir), SetFileName(testStamp),
+		SetFileHeader(false), SetFileSizelimit(1), SetMaxDiskUsage(1),
+		SetFileUpload(upload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.SetLog(pbtctest.Log{})
+	w.Start()
+
+	// the second upload starts after the first rotation pruned the directory
+	w.Write([]byte("a\n"))
+	first := <-started
+	w.Write([]byte("b\n"))
+	second := <-started
+
+	_, err = os.Stat(first)
+	if err != nil {
+		t.Errorf("pruned %v while uploading", first)
+	}
+
+	close(release)
+	for w.uploading(filepath.Base(first)) ||
+		w.uploading(filepath.Base(second)) {
+		time.Sleep(time.Millisecond)
+	}
+
+	w.Write([]byte("c\n"))
+	<-started
+	w.Stop()
+
+	for _, name := range []string{first, second} {
+		_, err = os.Stat(name)
+		if err == nil {
+			t.Errorf("did not prune %v after upload", name)
+		}
+	}
+}
+
+func TestStatError(t *testing.T) {
+	dir := t.TempDir() + "/"
+	w, err := NewFileWriter(SetFilePath(dir), SetFileName(testStamp),
+		SetFileHeader(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.SetLog(pbtctest.Log{})
+	w.Start()
+
+	// once the third line is queued, the first one is written
+	w.Write([]byte("a\n"))
+	w.Write([]byte("b\n"))
+	w.Write([]byte("c\n"))
+
+	// closing the file under the writer makes writing and stat fail, after
+	// which it has to start a new file and go on
+	w.file.Close()
+	w.Write([]byte("d\n"))
+	w.Write([]byte("e\n"))
+	w.Write([]byte("f\n"))
+	w.Stop()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) < 2 {
+		t.Fatalf("wrote %v files", len(files))
+	}
+
+	last, err := ioutil.ReadFile(files[len(files)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// depending on whether the close hit the third line, the fourth one
+	// might be lost too, but the last two are always in the new file
+	if !strings.HasSuffix(string(last), "e\nf\n") {
+		t.Errorf("last file has %q", last)
+	}
+}
+
+func TestCompressionStats(t *testing.T) {
+	// only rotated files are compressed, so we rotate once the lines are in
+	line := strings.Repeat("compressible ", 10) + "\n"
+	dir := t.TempDir() + "/"
+	w, err := NewFileWriter(SetFilePath(dir), SetFileName(testStamp),
+		SetFileHeader(false), SetFileCompressor(compressor.NewGzip()),
+		SetFileSizelimit(int64(1000*len(line))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.SetLog(pbtctest.Log{})
+	w.Start()
+
+	for i := 0; i < 1000; i++ {
+		w.Write([]byte(line))
+	}
+
+	for i := 0; i < 100 && w.CompressionStats().Files == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	w.Stop()
+
+	stats := w.CompressionStats()
+	if stats.Files != 1 {
+		t.Fatalf("%v files compressed", stats.Files)
+	}
+
+	if stats.OriginalSize != int64(1000*len(line)) {
+		t.Errorf("original size %v", stats.OriginalSize)
+	}
+
+	outputs, err := filepath.Glob(filepath.Join(dir, "*.out"))
+	if err != nil || len(outputs) != 1 {
+		t.Fatalf("found compressed files %v (%v)", outputs, err)
+	}
+
+	info, err := os.Stat(outputs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.CompressedSize != info.Size() {
+		t.Errorf("compressed size %v instead of %v", stats.CompressedSize,
+			info.Size())
+	}
+
+	// repeated lines compress very well, but never to nothing
+	if stats.Ratio < 10 || stats.Ratio > float64(stats.OriginalSize) {
+		t.Errorf("ratio %v", stats.Ratio)
+	}
+
+	if stats.AverageRatio != stats.Ratio {
+		t.Errorf("average ratio %v for a single file", stats.AverageRatio)
+	}
+}
+
+func TestFileStream(t *testing.T) {
+	// the compressed header alone exceeds the size limit, so we rotate after
+	// each line
+	dir := t.TempDir() + "/"
+	w, err := NewFileWriter(SetFilePath(dir), SetFileName(testStamp),
+		SetFileCompressor(compressor.NewGzip()), SetFileStream(true),
+		SetFileSizelimit(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.SetLog(pbtctest.Log{})
+	w.Start()
+	defer w.Stop()
+
+	lines := []string{"a\n", "b\n", "c\n"}
+	for _, line := range lines {
+		w.Write([]byte(line))
+	}
+
+	for i := 0; i < 100 && w.CompressionStats().Files < 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	outputs, err := filepath.Glob(filepath.Join(dir, "*.out"))
+	if err != nil || len(outputs) != 0 {
+		t.Errorf("found separately compressed files %v (%v)", outputs, err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != len(lines)+1 {
+		t.Fatalf("wrote %v files", len(files))
+	}
+
+	// the active file is compressed from the start
+	active, err := ioutil.ReadFile(files[len(files)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(active, []byte{0x1f, 0x8b}) {
+		t.Errorf("active file starts with %x", active)
+	}
+
+	for i, line := range lines {
+		data, err := ioutil.ReadFile(files[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("file %v is not compressed (%v)", i, err)
+		}
+
+		text, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("file %v is truncated (%v)", i, err)
+		}
+
+		if string(text) != "#"+Version+"\n"+line {
+			t.Errorf("file %v has %q", i, text)
+		}
+	}
+}
+
+func TestIndex(t *testing.T) {
+	dir := t.TempDir() + "/"
+	index := filepath.Join(dir, "index.json")
+	w, err := NewFileWriter(SetFilePath(dir), SetFileName(testStamp),
+		SetFileHeader(false), SetFileCompressor(compressor.NewGzip()),
+		SetFileSizelimit(1), SetIndexPath(index))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.SetLog(pbtctest.Log{})
+	w.Start()
+
+	// every line goes into its own file, and the last one is finished on stop
+	lines := []string{"a\n", "b\n", "c\n"}
+	for _, line := range lines {
+		w.Write([]byte(line))
+	}
+
+	w.Stop()
+
+	data, err := ioutil.ReadFile(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(rows) != len(lines)+1 {
+		t.Fatalf("index has %v entries", len(rows))
+	}
+
+	var previous IndexEntry
+	for i, row := range rows {
+		var entry IndexEntry
+		err = json.Unmarshal([]byte(row), &entry)
+		if err != nil {
+			t.Fatalf("entry %v is invalid (%v)", i, err)
+		}
+
+		if entry.File <= previous.File ||
+			entry.Start.Before(previous.Start) ||
+			entry.End.Before(entry.Start) {
+			t.Errorf("entry %+v out of order after %+v", entry, previous)
+		}
+
+		info, err := os.Stat(entry.File)
+		if err != nil {
+			t.Fatalf("indexed file is missing (%v)", err)
+		}
+
+		if entry.Size != info.Size() {
+			t.Errorf("entry %v has size %v instead of %v", i, entry.Size,
+				info.Size())
+		}
+
+		// finished files are compressed, except the last one on stop
+		if i < len(lines) {
+			if !strings.HasSuffix(entry.File, ".out") || entry.Records != 1 {
+				t.Errorf("rotated entry %+v", entry)
+			}
+		} else if entry.Records != 0 {
+			t.Errorf("last entry %+v", entry)
+		}
+
+		previous = entry
+	}
+}
+
+func TestFileAgelimit(t *testing.T) {
+	tests := []struct {
+		age     time.Duration
+		rotated bool
+	}{
+		{0, false},
+		{10 * time.Millisecond, true},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir() + "/"
+		w, err := NewFileWriter(SetFilePath(dir), SetFileName(testStamp),
+			SetFileHeader(false), SetFileAgelimit(test.age))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w.SetLog(pbtctest.Log{})
+		w.Start()
+		w.Write([]byte("a\n"))
+		time.Sleep(100 * time.Millisecond)
+		w.Stop()
+
+		if (w.fileTicker != nil) != test.rotated {
+			t.Errorf("ticker %v with age limit %v", w.fileTicker, test.age)
+		}
+
+		files, err := filepath.Glob(filepath.Join(dir, "*.log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if (len(files) > 1) != test.rotated {
+			t.Errorf("%v files with age limit %v", len(files), test.age)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package processor
+
+import (
+	"container/list"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/CIRCL/pbtc/adaptor"
+	"github.com/CIRCL/pbtc/records"
+	"github.com/CIRCL/pbtc/util"
+)
+
+// PeerWriter writes the records of each peer to files of its own, so that the
+// traffic of single peers can be analysed in isolation. Each peer gets a file
+// writer of its own, created on its first record and stopped when it
+// disconnects. If too many files are open, the peer that was idle the longest
+// has its file closed; it will get a new file if it sends more records.
+type PeerWriter struct {
+	Processor
+
+	wg      *sync.WaitGroup
+	options []func(adaptor.Processor)
+	limit   int
+	prefix  string
+
+	peerMutex *sync.Mutex
+	writers   map[string]*list.Element
+	idle      *list.List
+	opened    uint64
+}
+
+// peerFile is the file writer of a single peer, kept in the idle list.
+type peerFile struct {
+	addr   string
+	writer *FileWriter
+}
+
+func NewPeerWriter(options ...func(adaptor.Processor)) (*PeerWriter, error) {
+	w := &PeerWriter{
+		wg:     &sync.WaitGroup{},
+		limit:  64,
+		prefix: "pbtc-",
+
+		peerMutex: &sync.Mutex{},
+		writers:   make(map[string]*list.Element),
+		idle:      list.New(),
+	}
+
+	for _, option := range options {
+		option(w)
+	}
+
+	// the file options can be validated with a writer that we never start
+	_, err := NewFileWriter(w.options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// SetPeerFileOptions sets the options of the file writers used for each peer.
+// The file prefix is extended with the address of the peer and the number of
+// the file.
+func SetPeerFileOptions(options ...func(adaptor.Processor)) func(
+	adaptor.Processor) {
+	return func(pro adaptor.Processor) {
+		w, ok := pro.(*PeerWriter)
+		if !ok {
+			return
+		}
+
+		w.options = options
+
+		// we need to know the configured prefix to extend it
+		fw := &FileWriter{filePrefix: w.prefix}
+		for _, option := range options {
+			option(fw)
+		}
+
+		w.prefix = fw.filePrefix
+	}
+}
+
+// SetPeerLimit sets the number of peer files that can be open at the same
+// time.
+func SetPeerLimit(limit int) func(adaptor.Processor) {
+	return func(pro adaptor.Processor) {
+		w, ok := pro.(*PeerWriter)
+		if !ok {
+			return
+		}
+
+		w.limit = limit
+	}
+}
+
+func (w *PeerWriter) Start() {
+	w.log.Info("[PWP] Start: begin")
+
+	w.markRunning()
+
+	w.log.Info("[PWP] Start: completed")
+}
+
+func (w *PeerWriter) Stop() {
+	w.log.Info("[PWP] Stop: begin")
+
+	w.markStopped()
+
+	w.peerMutex.Lock()
+	for w.idle.Len() > 0 {
+		w.close(w.idle.Back())
+	}
+	w.peerMutex.Unlock()
+
+	w.wg.Wait()
+
+	w.log.Info("[PWP] Stop: completed")
+}
+
+func (w *PeerWriter) Process(record adaptor.Record) {
+	w.log.Debug("[PWP] Process: %v", record.Command())
+
+	if w.discard() {
+		return
+	}
+
+	ra := record.RemoteAddress()
+	if ra == nil {
+		return
+	}
+
+	w.peerMutex.Lock()
+	defer w.peerMutex.Unlock()
+
+	// key on the canonical address, so that a peer always gets one file
+	key := util.CanonicalAddr(ra)
+	e, ok := w.writers[key]
+	if !ok {
+		e = w.open(key)
+		if e == nil {
+			return
+		}
+	}
+
+	w.idle.MoveToFront(e)
+	e.Value.(*peerFile).writer.Process(w.tagged(record))
+
+	// once the peer is gone, there will be nothing more to write
+	if record.Command() == records.CmdDisconnect {
+		w.close(e)
+	}
+}
+
+// Backpressure returns whether one of the peer files can not keep up with the
+// records.
+func (w *PeerWriter) Backpressure() bool {
+	w.peerMutex.Lock()
+	defer w.peerMutex.Unlock()
+
+	for e := w.idle.Front(); e != nil; e = e.Next() {
+		if e.Value.(*peerFile).writer.Backpressure() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// open starts the file writer for a peer, closing the file of the peer that
+// was idle the longest if we are at the limit. It needs to be called with the
+// mutex held.
+func (w *PeerWriter) open(addr string) *list.Element {
+	if w.limit > 0 && w.idle.Len() >= w.limit {
+		w.close(w.idle.Back())
+	}
+
+	// a peer can get a new file in the same second its last one was closed,
+	// so we number the files to keep them from overwriting each other
+	w.opened++
+	prefix := w.prefix + peerName(addr) + "-" +
+		strconv.FormatUint(w.opened, 10) + "-"
+
+	options := append([]func(adaptor.Processor){}, w.options...)
+	options = append(options, SetFilePrefix(prefix))
+	writer, err := NewFileWriter(options...)
+	w.markFault(err)
+	if err != nil {
+		w.log.Error("[PWP] Could not create writer for %v (%v)", addr, err)
+		return nil
+	}
+
+	writer.SetLog(w.log)
+	writer.Start()
+
+	e := w.idle.PushFront(&peerFile{addr: addr, writer: writer})
+	w.writers[addr] = e
+
+	return e
+}
+
+// close removes the file writer of a peer and stops it in the background, as
+// finishing the file might take a while. It needs to be called with the mutex
+// held.
+func (w *PeerWriter) close(e *list.Element) {
+	pf := w.idle.Remove(e).(*peerFile)
+	delete(w.writers, pf.addr)
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		pf.writer.Stop()
+		w.addWritten(int(pf.writer.Written()))
+	}()
+}
+
+// Written returns the number of bytes written to the files of all peers. Files
+// that are being closed are counted once they are done.
+func (w *PeerWriter) Written() uint64 {
+	w.peerMutex.Lock()
+	defer w.peerMutex.Unlock()
+
+	written := w.Processor.Written()
+	for e := w.idle.Front(); e != nil; e = e.Next() {
+		written += e.Value.(*peerFile).writer.Written()
+	}
+
+	return written
+}
+
+// peerName turns the address of a peer into something we can use in file
+// names.
+func peerName(addr string) string {
+	return strings.NewReplacer(":", "_", "[", "", "]", "").Replace(addr)
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package processor
+
+import (
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/CIRCL/pbtc/pbtctest"
+	"github.com/CIRCL/pbtc/records"
+)
+
+// TestPeerFiles sends the records of two peers through the peer writer and
+// checks that they end up in separate files, also once the first peer
+// disconnected and had its file closed.
+func TestPeerFiles(t *testing.T) {
+	dir := t.TempDir() + "/"
+	w, err := NewPeerWriter(SetPeerFileOptions(SetFilePath(dir),
+		SetFileName(testStamp), SetFileHeader(false)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.SetLog(pbtctest.Log{})
+	w.Start()
+
+	la := &net.TCPAddr{IP: net.ParseIP("192.0.2.100"), Port: 8333}
+	peerA := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 8333}
+	peerB := &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 8333}
+	stamp := time.Unix(1, 0)
+	ping := func(ra *net.TCPAddr, nonce uint64) {
+		w.Process(records.NewPingRecord(wire.NewMsgPing(nonce), ra, la,
+			stamp))
+	}
+
+	ping(peerA, 1)
+	ping(peerB, 2)
+	ping(peerA, 3)
+	w.Process(records.NewDisconnectRecord(records.ReasonDropped, "",
+		peerA, la, stamp))
+	ping(peerB, 4)
+	w.Stop()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 2 {
+		t.Fatalf("wrote %v files, want 2", len(files))
+	}
+
+	tests := []struct {
+		addr   *net.TCPAddr
+		nonces []string
+		lines  int
+	}{
+		{peerA, []string{"1", "3"}, 3},
+		{peerB, []string{"2", "4"}, 2},
+	}
+
+	for _, test := range tests {
+		name := peerName(test.addr.String())
+		var content []byte
+		for _, file := range files {
+			if !strings.Contains(filepath.Base(file), name) {
+				continue
+			}
+
+			content, err = ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		if len(lines) != test.lines {
+			t.Errorf("%v: file has %v lines, want %v", test.addr,
+				len(lines), test.lines)
+			continue
+		}
+
+		for i, nonce := range test.nonces {
+			if !strings.HasSuffix(lines[i], records.Delimiter1+nonce) ||
+				!strings.Contains(lines[i], test.addr.String()) {
+				t.Errorf("%v: line %v is %q", test.addr, i, lines[i])
+			}
+		}
+	}
+}
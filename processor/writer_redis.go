@@ -109,12 +109,16 @@ func (w *RedisWriter) Start() {
 	w.wg.Add(1)
 	go w.goProcess()
 
+	w.markRunning()
+
 	w.log.Info("[PWR] Start: completed")
 }
 
 func (w *RedisWriter) Stop() {
 	w.log.Info("[PWR] Stop: begin")
 
+	w.markStopped()
+
 	close(w.sig)
 	w.wg.Wait()
 
@@ -124,7 +128,17 @@ func (w *RedisWriter) Stop() {
 func (w *RedisWriter) Process(record adaptor.Record) {
 	w.log.Debug("[PWR] Process: %v", record.Command())
 
-	w.lineQ <- record.String()
+	if w.discard() {
+		return
+	}
+
+	w.lineQ <- w.tagged(record).String()
+}
+
+// Backpressure returns whether the writer can not keep up with the records, in
+// which case its queue is full.
+func (w *RedisWriter) Backpressure() bool {
+	return len(w.lineQ) == cap(w.lineQ)
 }
 
 func (w *RedisWriter) goProcess() {
@@ -140,10 +154,13 @@ LineLoop:
 
 		case line := <-w.lineQ:
 			err := w.client.Publish("", line).Err()
+			w.markFault(err)
 			if err != nil {
 				w.log.Error("Could not send line to redis (%v)", err)
 				continue
 			}
+
+			w.addWritten(len(line))
 		}
 	}
 }
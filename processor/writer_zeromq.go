@@ -22,12 +22,19 @@ package processor
 
 import (
 	"sync"
+	"sync/atomic"
+	"time"
 
 	zmq "github.com/pebbe/zmq4"
 
 	"github.com/CIRCL/pbtc/adaptor"
 )
 
+// subscriptionInterval is how often we check for new subscriptions while no
+// records come in, so that buffered lines are sent soon after a subscriber
+// attaches.
+const subscriptionInterval = time.Second
+
 type ZeroMQWriter struct {
 	Processor
 
@@ -36,6 +43,13 @@ type ZeroMQWriter struct {
 	lineQ chan string
 	sig   chan struct{}
 	wg    *sync.WaitGroup
+
+	warn    bool
+	limit   int
+	topics  map[string]struct{}
+	buffer  []string
+	dropped uint64
+	warned  bool
 }
 
 func NewZeroMQWriter(options ...func(adaptor.Processor)) (*ZeroMQWriter, error) {
@@ -44,13 +58,22 @@ func NewZeroMQWriter(options ...func(adaptor.Processor)) (*ZeroMQWriter, error)
 		lineQ: make(chan string, 1),
 		sig:   make(chan struct{}),
 		wg:    &sync.WaitGroup{},
+
+		topics: make(map[string]struct{}),
 	}
 
 	for _, option := range options {
 		option(w)
 	}
 
-	pub, err := zmq.NewSocket(zmq.PUB)
+	// an XPUB socket tells us about subscriptions, which we need to know
+	// whether anyone receives our lines
+	socketType := zmq.PUB
+	if w.tracksSubscribers() {
+		socketType = zmq.XPUB
+	}
+
+	pub, err := zmq.NewSocket(socketType)
 	if err != nil {
 		return nil, err
 	}
@@ -78,18 +101,57 @@ func SetZeromqHost(addr string) func(adaptor.Processor) {
 	}
 }
 
+// SetZeromqWarnOnNoSubscribers makes the writer log a warning when it publishes
+// while no subscriber is attached, as the lines are then lost. The warning is
+// logged once each time the last subscriber goes away.
+func SetZeromqWarnOnNoSubscribers(warn bool) func(adaptor.Processor) {
+	return func(pro adaptor.Processor) {
+		w, ok := pro.(*ZeroMQWriter)
+		if !ok {
+			return
+		}
+
+		w.warn = warn
+	}
+}
+
+// SetZeromqBuffer sets the number of lines kept while no subscriber is
+// attached. They are sent as soon as one subscribes; if the buffer is full,
+// the oldest lines are dropped. Zero means lines are published regardless and
+// lost if nobody listens.
+func SetZeromqBuffer(limit int) func(adaptor.Processor) {
+	return func(pro adaptor.Processor) {
+		w, ok := pro.(*ZeroMQWriter)
+		if !ok {
+			return
+		}
+
+		w.limit = limit
+	}
+}
+
+// DroppedLines returns the number of lines dropped because the buffer was full
+// while no subscriber was attached.
+func (w *ZeroMQWriter) DroppedLines() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
 func (w *ZeroMQWriter) Start() {
 	w.log.Info("[PWZ] Start: begin")
 
 	w.wg.Add(1)
 	go w.goLines()
 
+	w.markRunning()
+
 	w.log.Info("[PWZ] Start: completed")
 }
 
 func (w *ZeroMQWriter) Stop() {
 	w.log.Info("[PWZ] Stop: begin")
 
+	w.markStopped()
+
 	close(w.sig)
 	w.wg.Wait()
 
@@ -99,12 +161,29 @@ func (w *ZeroMQWriter) Stop() {
 func (w *ZeroMQWriter) Process(record adaptor.Record) {
 	w.log.Debug("[PWZ] Process: %v", record.Command())
 
-	w.lineQ <- record.String()
+	if w.discard() {
+		return
+	}
+
+	w.lineQ <- w.tagged(record).String()
+}
+
+// Backpressure returns whether the writer can not keep up with the records, in
+// which case its queue is full.
+func (w *ZeroMQWriter) Backpressure() bool {
+	return len(w.lineQ) == cap(w.lineQ)
 }
 
 func (w *ZeroMQWriter) goLines() {
 	defer w.wg.Done()
 
+	var subscriptionC <-chan time.Time
+	if w.tracksSubscribers() {
+		subscriptionT := time.NewTicker(subscriptionInterval)
+		defer subscriptionT.Stop()
+		subscriptionC = subscriptionT.C
+	}
+
 LineLoop:
 	for {
 		select {
@@ -113,12 +192,98 @@ LineLoop:
 				break LineLoop
 			}
 
+		case <-subscriptionC:
+			w.readSubscriptions()
+			w.flush()
+
 		case line := <-w.lineQ:
-			_, err := w.pub.Send(line, 0)
-			if err != nil {
-				w.log.Error("Could not send line on zmq (%v)", err)
+			if !w.tracksSubscribers() {
+				w.send(line)
 				continue
 			}
+
+			w.readSubscriptions()
+			if len(w.topics) > 0 {
+				w.flush()
+				w.send(line)
+				continue
+			}
+
+			if w.warn && !w.warned {
+				w.log.Warning("[PWZ] No subscribers, records are not consumed")
+				w.warned = true
+			}
+
+			if w.limit == 0 {
+				w.send(line)
+				continue
+			}
+
+			if len(w.buffer) >= w.limit {
+				w.buffer = w.buffer[1:]
+				atomic.AddUint64(&w.dropped, 1)
+			}
+
+			w.buffer = append(w.buffer, line)
+		}
+	}
+}
+
+// tracksSubscribers returns whether we need to know about subscriptions.
+func (w *ZeroMQWriter) tracksSubscribers() bool {
+	return w.warn || w.limit > 0
+}
+
+// readSubscriptions reads the pending subscription messages of the XPUB
+// socket without blocking. Each one starts with one for a subscription or zero
+// for an unsubscription, followed by the topic. The socket only reports the
+// first subscription and the last unsubscription of each topic, so some
+// subscriber is attached as long as we know of any topic.
+func (w *ZeroMQWriter) readSubscriptions() {
+	for {
+		msg, err := w.pub.RecvBytes(zmq.DONTWAIT)
+		if err != nil || len(msg) == 0 {
+			break
 		}
+
+		topic := string(msg[1:])
+		switch msg[0] {
+		case 1:
+			if len(w.topics) == 0 {
+				w.log.Info("[PWZ] Subscriber attached")
+			}
+
+			w.topics[topic] = struct{}{}
+
+		case 0:
+			delete(w.topics, topic)
+			if len(w.topics) == 0 {
+				w.warned = false
+			}
+		}
+	}
+}
+
+// flush sends the buffered lines once a subscriber is attached.
+func (w *ZeroMQWriter) flush() {
+	if len(w.topics) == 0 || len(w.buffer) == 0 {
+		return
+	}
+
+	w.log.Info("[PWZ] Sending %v buffered lines", len(w.buffer))
+	for _, line := range w.buffer {
+		w.send(line)
+	}
+
+	w.buffer = nil
+}
+
+// send publishes a line on the socket.
+func (w *ZeroMQWriter) send(line string) {
+	n, err := w.pub.Send(line, 0)
+	w.addWritten(n)
+	w.markFault(err)
+	if err != nil {
+		w.log.Error("Could not send line on zmq (%v)", err)
 	}
 }
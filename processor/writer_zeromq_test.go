@@ -0,0 +1,116 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package processor
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	zmq "github.com/pebbe/zmq4"
+
+	"github.com/CIRCL/pbtc/pbtctest"
+	"github.com/CIRCL/pbtc/records"
+)
+
+// warningLog counts the warnings logged.
+type warningLog struct {
+	pbtctest.Log
+
+	warnings uint32
+}
+
+func (log *warningLog) Warning(format string, args ...interface{}) {
+	atomic.AddUint32(&log.warnings, 1)
+}
+
+func TestZeromqNoSubscribers(t *testing.T) {
+	w, err := NewZeroMQWriter(SetZeromqHost("tcp://127.0.0.1:*"),
+		SetZeromqWarnOnNoSubscribers(true), SetZeromqBuffer(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	endpoint, err := w.pub.GetLastEndpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log := &warningLog{}
+	w.SetLog(log)
+	w.Start()
+	defer w.Stop()
+
+	ping := func(nonce uint64) {
+		w.Process(records.NewPingRecord(wire.NewMsgPing(nonce), nil, nil,
+			time.Unix(1, 0)))
+	}
+
+	// the warning fires once, and the oldest line is dropped from the buffer
+	for nonce := uint64(1); nonce <= 3; nonce++ {
+		ping(nonce)
+	}
+
+	for i := 0; i < 1000 && w.DroppedLines() == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	if atomic.LoadUint32(&log.warnings) != 1 || w.DroppedLines() != 1 {
+		t.Errorf("%v warnings and %v dropped lines without subscriber",
+			atomic.LoadUint32(&log.warnings), w.DroppedLines())
+	}
+
+	sub, err := zmq.NewSocket(zmq.SUB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	err = sub.Connect(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sub.SetSubscribe("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sub.SetRcvtimeo(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the buffered lines are sent once the subscription comes in
+	for nonce := uint64(2); nonce <= 3; nonce++ {
+		line, err := sub.Recv(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.HasSuffix(line, fmt.Sprintf("|%v", nonce)) {
+			t.Errorf("received %q instead of ping %v", line, nonce)
+		}
+	}
+}
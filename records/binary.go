@@ -0,0 +1,77 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package records
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+// This file holds the helpers for the Bytes methods of the records. Numbers
+// are little endian, strings and byte slices are prefixed by their length as
+// 2 byte number and counts of sub-records take 2 bytes as well.
+
+func putUint16(buf []byte, v uint16) []byte {
+	var b [2]byte
+	binary.LittleEndian.PutUint16(b[:], v)
+	return append(buf, b[:]...)
+}
+
+func putUint32(buf []byte, v uint32) []byte {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	return append(buf, b[:]...)
+}
+
+func putUint64(buf []byte, v uint64) []byte {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	return append(buf, b[:]...)
+}
+
+func putBool(buf []byte, v bool) []byte {
+	if v {
+		return append(buf, 1)
+	}
+
+	return append(buf, 0)
+}
+
+func putBytes(buf []byte, v []byte) []byte {
+	buf = putUint16(buf, uint16(len(v)))
+	return append(buf, v...)
+}
+
+func putString(buf []byte, v string) []byte {
+	return putBytes(buf, []byte(v))
+}
+
+// putAddr appends an address as 16 byte IP followed by the 2 byte port, in
+// big endian like on the wire. A missing address is all zero.
+func putAddr(buf []byte, addr *net.TCPAddr) []byte {
+	var b [18]byte
+	if addr != nil {
+		copy(b[0:16], addr.IP.To16())
+		binary.BigEndian.PutUint16(b[16:18], uint16(addr.Port))
+	}
+
+	return append(buf, b[:]...)
+}
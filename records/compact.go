@@ -0,0 +1,142 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package records
+
+import (
+	"encoding/binary"
+	"net"
+
+	"github.com/CIRCL/pbtc/adaptor"
+)
+
+// This file holds the compact encoding of records for archival captures. The
+// commands, addresses and tags repeat for almost every record, so they are
+// kept in a dictionary for each file and records only refer to them by index.
+//
+// A file starts with the magic and version. It is followed by entries, which
+// start with their type and the length of their content as varint. A define
+// entry adds the string it holds to the dictionary, at the next free index;
+// index zero is the empty string. Strings are defined right before the first
+// record that uses them, so that files can be read while they are written. A
+// record entry holds the timestamp in nanoseconds as 8 byte number, the
+// indexes of the command, remote address, local address, tag and country as
+// varints, the ASN as varint, and the payload as returned by Bytes, prefixed
+// by its length as varint. Version 1 files lack the country and the ASN.
+
+const (
+	CompactMagic   = "PBTCDICT"
+	CompactVersion = 2
+)
+
+// The types of entries in a compact file.
+const (
+	CompactDefine = 1
+	CompactRecord = 2
+)
+
+// CompactEncoder encodes records in the compact format, keeping the dictionary
+// of the file being written. It is not safe for concurrent use.
+type CompactEncoder struct {
+	index map[string]uint64
+}
+
+// NewCompactEncoder returns an encoder with an empty dictionary, for a new
+// file.
+func NewCompactEncoder() *CompactEncoder {
+	e := &CompactEncoder{
+		index: map[string]uint64{"": 0},
+	}
+
+	return e
+}
+
+// CompactHeader returns the header a compact file starts with.
+func CompactHeader() []byte {
+	return append([]byte(CompactMagic), CompactVersion)
+}
+
+// Encode returns the record entry for the given record, preceded by the define
+// entries for the strings that are not yet in the dictionary.
+func (e *CompactEncoder) Encode(record adaptor.Record) []byte {
+	var buf []byte
+
+	tag, country, asn := annotations(record)
+
+	cmd := e.intern(&buf, record.Command())
+	ra := e.intern(&buf, addrString(record.RemoteAddress()))
+	la := e.intern(&buf, addrString(record.LocalAddress()))
+	tg := e.intern(&buf, tag)
+	cc := e.intern(&buf, country)
+
+	payload := record.Bytes()
+	body := make([]byte, 8, 8+7*binary.MaxVarintLen64+len(payload))
+	binary.LittleEndian.PutUint64(body, uint64(record.Timestamp().UnixNano()))
+	body = appendVarint(body, cmd)
+	body = appendVarint(body, ra)
+	body = appendVarint(body, la)
+	body = appendVarint(body, tg)
+	body = appendVarint(body, cc)
+	body = appendVarint(body, uint64(asn))
+	body = appendVarint(body, uint64(len(payload)))
+	body = append(body, payload...)
+
+	return appendEntry(buf, CompactRecord, body)
+}
+
+// intern returns the dictionary index of the string, appending a define entry
+// to the buffer if it is new.
+func (e *CompactEncoder) intern(buf *[]byte, s string) uint64 {
+	i, ok := e.index[s]
+	if ok {
+		return i
+	}
+
+	i = uint64(len(e.index))
+	e.index[s] = i
+	*buf = appendEntry(*buf, CompactDefine, []byte(s))
+
+	return i
+}
+
+func appendEntry(buf []byte, kind byte, content []byte) []byte {
+	buf = append(buf, kind)
+	buf = appendVarint(buf, uint64(len(content)))
+	return append(buf, content...)
+}
+
+func appendVarint(buf []byte, v uint64) []byte {
+	for v >= 0x80 {
+		buf = append(buf, byte(v)|0x80)
+		v >>= 7
+	}
+
+	return append(buf, byte(v))
+}
+
+// addrString returns the address as string, or the empty string if there is
+// none.
+func addrString(addr *net.TCPAddr) string {
+	if addr == nil {
+		return ""
+	}
+
+	return addr.String()
+}
@@ -0,0 +1,75 @@
+// Code generated by the FlatBuffers compiler. DO NOT EDIT.
+
+package fb
+
+import (
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+type Announcer struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsAnnouncer(buf []byte, offset flatbuffers.UOffsetT) *Announcer {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &Announcer{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishAnnouncerBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsAnnouncer(buf []byte, offset flatbuffers.UOffsetT) *Announcer {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &Announcer{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedAnnouncerBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *Announcer) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *Announcer) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func (rcv *Announcer) Peer() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(4))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func (rcv *Announcer) Offset() int64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(6))
+	if o != 0 {
+		return rcv._tab.GetInt64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *Announcer) MutateOffset(n int64) bool {
+	return rcv._tab.MutateInt64Slot(6, n)
+}
+
+func AnnouncerStart(builder *flatbuffers.Builder) {
+	builder.StartObject(2)
+}
+func AnnouncerAddPeer(builder *flatbuffers.Builder, peer flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(0, flatbuffers.UOffsetT(peer), 0)
+}
+func AnnouncerAddOffset(builder *flatbuffers.Builder, offset int64) {
+	builder.PrependInt64Slot(1, offset, 0)
+}
+func AnnouncerEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
@@ -0,0 +1,113 @@
+// Code generated by the FlatBuffers compiler. DO NOT EDIT.
+
+package fb
+
+import "strconv"
+
+type Body byte
+
+const (
+	BodyNONE        Body = 0
+	BodyVersion     Body = 1
+	BodyVerAck      Body = 2
+	BodyAddr        Body = 3
+	BodyGetAddr     Body = 4
+	BodyInv         Body = 5
+	BodyGetData     Body = 6
+	BodyNotFound    Body = 7
+	BodyGetBlocks   Body = 8
+	BodyGetHeaders  Body = 9
+	BodyHeaders     Body = 10
+	BodyBlock       Body = 11
+	BodyTx          Body = 12
+	BodyPing        Body = 13
+	BodyPong        Body = 14
+	BodyReject      Body = 15
+	BodyAlert       Body = 16
+	BodyMemPool     Body = 17
+	BodyMerkleBlock Body = 18
+	BodyFilterAdd   Body = 19
+	BodyFilterClear Body = 20
+	BodyFilterLoad  Body = 21
+	BodyFeeFilter   Body = 22
+	BodyGetCFilters Body = 23
+	BodyCFilter     Body = 24
+	BodyCFHeaders   Body = 25
+	BodyRaw         Body = 26
+	BodyDisconnect  Body = 27
+	BodySummary     Body = 28
+	BodyTopology    Body = 29
+)
+
+var EnumNamesBody = map[Body]string{
+	BodyNONE:        "NONE",
+	BodyVersion:     "Version",
+	BodyVerAck:      "VerAck",
+	BodyAddr:        "Addr",
+	BodyGetAddr:     "GetAddr",
+	BodyInv:         "Inv",
+	BodyGetData:     "GetData",
+	BodyNotFound:    "NotFound",
+	BodyGetBlocks:   "GetBlocks",
+	BodyGetHeaders:  "GetHeaders",
+	BodyHeaders:     "Headers",
+	BodyBlock:       "Block",
+	BodyTx:          "Tx",
+	BodyPing:        "Ping",
+	BodyPong:        "Pong",
+	BodyReject:      "Reject",
+	BodyAlert:       "Alert",
+	BodyMemPool:     "MemPool",
+	BodyMerkleBlock: "MerkleBlock",
+	BodyFilterAdd:   "FilterAdd",
+	BodyFilterClear: "FilterClear",
+	BodyFilterLoad:  "FilterLoad",
+	BodyFeeFilter:   "FeeFilter",
+	BodyGetCFilters: "GetCFilters",
+	BodyCFilter:     "CFilter",
+	BodyCFHeaders:   "CFHeaders",
+	BodyRaw:         "Raw",
+	BodyDisconnect:  "Disconnect",
+	BodySummary:     "Summary",
+	BodyTopology:    "Topology",
+}
+
+var EnumValuesBody = map[string]Body{
+	"NONE":        BodyNONE,
+	"Version":     BodyVersion,
+	"VerAck":      BodyVerAck,
+	"Addr":        BodyAddr,
+	"GetAddr":     BodyGetAddr,
+	"Inv":         BodyInv,
+	"GetData":     BodyGetData,
+	"NotFound":    BodyNotFound,
+	"GetBlocks":   BodyGetBlocks,
+	"GetHeaders":  BodyGetHeaders,
+	"Headers":     BodyHeaders,
+	"Block":       BodyBlock,
+	"Tx":          BodyTx,
+	"Ping":        BodyPing,
+	"Pong":        BodyPong,
+	"Reject":      BodyReject,
+	"Alert":       BodyAlert,
+	"MemPool":     BodyMemPool,
+	"MerkleBlock": BodyMerkleBlock,
+	"FilterAdd":   BodyFilterAdd,
+	"FilterClear": BodyFilterClear,
+	"FilterLoad":  BodyFilterLoad,
+	"FeeFilter":   BodyFeeFilter,
+	"GetCFilters": BodyGetCFilters,
+	"CFilter":     BodyCFilter,
+	"CFHeaders":   BodyCFHeaders,
+	"Raw":         BodyRaw,
+	"Disconnect":  BodyDisconnect,
+	"Summary":     BodySummary,
+	"Topology":    BodyTopology,
+}
+
+func (v Body) String() string {
+	if s, ok := EnumNamesBody[v]; ok {
+		return s
+	}
+	return "Body(" + strconv.FormatInt(int64(v), 10) + ")"
+}
@@ -0,0 +1,75 @@
+// Code generated by the FlatBuffers compiler. DO NOT EDIT.
+
+package fb
+
+import (
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+type Count struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsCount(buf []byte, offset flatbuffers.UOffsetT) *Count {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &Count{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishCountBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsCount(buf []byte, offset flatbuffers.UOffsetT) *Count {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &Count{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedCountBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *Count) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *Count) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func (rcv *Count) Name() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(4))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func (rcv *Count) Count() uint64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(6))
+	if o != 0 {
+		return rcv._tab.GetUint64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *Count) MutateCount(n uint64) bool {
+	return rcv._tab.MutateUint64Slot(6, n)
+}
+
+func CountStart(builder *flatbuffers.Builder) {
+	builder.StartObject(2)
+}
+func CountAddName(builder *flatbuffers.Builder, name flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(0, flatbuffers.UOffsetT(name), 0)
+}
+func CountAddCount(builder *flatbuffers.Builder, count uint64) {
+	builder.PrependUint64Slot(1, count, 0)
+}
+func CountEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
@@ -0,0 +1,71 @@
+// Code generated by the FlatBuffers compiler. DO NOT EDIT.
+
+package fb
+
+import (
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+type Disconnect struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsDisconnect(buf []byte, offset flatbuffers.UOffsetT) *Disconnect {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &Disconnect{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishDisconnectBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsDisconnect(buf []byte, offset flatbuffers.UOffsetT) *Disconnect {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &Disconnect{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedDisconnectBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *Disconnect) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *Disconnect) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func (rcv *Disconnect) Reason() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(4))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func (rcv *Disconnect) Detail() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(6))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func DisconnectStart(builder *flatbuffers.Builder) {
+	builder.StartObject(2)
+}
+func DisconnectAddReason(builder *flatbuffers.Builder, reason flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(0, flatbuffers.UOffsetT(reason), 0)
+}
+func DisconnectAddDetail(builder *flatbuffers.Builder, detail flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(1, flatbuffers.UOffsetT(detail), 0)
+}
+func DisconnectEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
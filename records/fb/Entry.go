@@ -0,0 +1,90 @@
+// Code generated by the FlatBuffers compiler. DO NOT EDIT.
+
+package fb
+
+import (
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+type Entry struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsEntry(buf []byte, offset flatbuffers.UOffsetT) *Entry {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &Entry{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishEntryBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsEntry(buf []byte, offset flatbuffers.UOffsetT) *Entry {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &Entry{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedEntryBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *Entry) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *Entry) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func (rcv *Entry) Advertised() int64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(4))
+	if o != 0 {
+		return rcv._tab.GetInt64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *Entry) MutateAdvertised(n int64) bool {
+	return rcv._tab.MutateInt64Slot(4, n)
+}
+
+func (rcv *Entry) Services() uint64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(6))
+	if o != 0 {
+		return rcv._tab.GetUint64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *Entry) MutateServices(n uint64) bool {
+	return rcv._tab.MutateUint64Slot(6, n)
+}
+
+func (rcv *Entry) Address() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(8))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func EntryStart(builder *flatbuffers.Builder) {
+	builder.StartObject(3)
+}
+func EntryAddAdvertised(builder *flatbuffers.Builder, advertised int64) {
+	builder.PrependInt64Slot(0, advertised, 0)
+}
+func EntryAddServices(builder *flatbuffers.Builder, services uint64) {
+	builder.PrependUint64Slot(1, services, 0)
+}
+func EntryAddAddress(builder *flatbuffers.Builder, address flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(2, flatbuffers.UOffsetT(address), 0)
+}
+func EntryEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
@@ -0,0 +1,64 @@
+// Code generated by the FlatBuffers compiler. DO NOT EDIT.
+
+package fb
+
+import (
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+type FeeFilter struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsFeeFilter(buf []byte, offset flatbuffers.UOffsetT) *FeeFilter {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &FeeFilter{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishFeeFilterBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsFeeFilter(buf []byte, offset flatbuffers.UOffsetT) *FeeFilter {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &FeeFilter{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedFeeFilterBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *FeeFilter) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *FeeFilter) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func (rcv *FeeFilter) Fee() int64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(4))
+	if o != 0 {
+		return rcv._tab.GetInt64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *FeeFilter) MutateFee(n int64) bool {
+	return rcv._tab.MutateInt64Slot(4, n)
+}
+
+func FeeFilterStart(builder *flatbuffers.Builder) {
+	builder.StartObject(1)
+}
+func FeeFilterAddFee(builder *flatbuffers.Builder, fee int64) {
+	builder.PrependInt64Slot(0, fee, 0)
+}
+func FeeFilterEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
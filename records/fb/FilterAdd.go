@@ -0,0 +1,49 @@
+// Code generated by the FlatBuffers compiler. DO NOT EDIT.
+
+package fb
+
+import (
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+type FilterAdd struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsFilterAdd(buf []byte, offset flatbuffers.UOffsetT) *FilterAdd {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &FilterAdd{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishFilterAddBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsFilterAdd(buf []byte, offset flatbuffers.UOffsetT) *FilterAdd {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &FilterAdd{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedFilterAddBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *FilterAdd) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *FilterAdd) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func FilterAddStart(builder *flatbuffers.Builder) {
+	builder.StartObject(0)
+}
+func FilterAddEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
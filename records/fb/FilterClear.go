@@ -0,0 +1,49 @@
+// Code generated by the FlatBuffers compiler. DO NOT EDIT.
+
+package fb
+
+import (
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+type FilterClear struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsFilterClear(buf []byte, offset flatbuffers.UOffsetT) *FilterClear {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &FilterClear{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishFilterClearBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsFilterClear(buf []byte, offset flatbuffers.UOffsetT) *FilterClear {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &FilterClear{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedFilterClearBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *FilterClear) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *FilterClear) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func FilterClearStart(builder *flatbuffers.Builder) {
+	builder.StartObject(0)
+}
+func FilterClearEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
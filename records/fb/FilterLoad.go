@@ -0,0 +1,49 @@
+// Code generated by the FlatBuffers compiler. DO NOT EDIT.
+
+package fb
+
+import (
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+type FilterLoad struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsFilterLoad(buf []byte, offset flatbuffers.UOffsetT) *FilterLoad {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &FilterLoad{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishFilterLoadBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsFilterLoad(buf []byte, offset flatbuffers.UOffsetT) *FilterLoad {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &FilterLoad{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedFilterLoadBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *FilterLoad) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *FilterLoad) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func FilterLoadStart(builder *flatbuffers.Builder) {
+	builder.StartObject(0)
+}
+func FilterLoadEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
@@ -0,0 +1,49 @@
+// Code generated by the FlatBuffers compiler. DO NOT EDIT.
+
+package fb
+
+import (
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+type GetAddr struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsGetAddr(buf []byte, offset flatbuffers.UOffsetT) *GetAddr {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &GetAddr{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishGetAddrBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsGetAddr(buf []byte, offset flatbuffers.UOffsetT) *GetAddr {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &GetAddr{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedGetAddrBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *GetAddr) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *GetAddr) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func GetAddrStart(builder *flatbuffers.Builder) {
+	builder.StartObject(0)
+}
+func GetAddrEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
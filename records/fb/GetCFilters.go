@@ -0,0 +1,119 @@
+// Code generated by the FlatBuffers compiler. DO NOT EDIT.
+
+package fb
+
+import (
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+type GetCFilters struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsGetCFilters(buf []byte, offset flatbuffers.UOffsetT) *GetCFilters {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &GetCFilters{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishGetCFiltersBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsGetCFilters(buf []byte, offset flatbuffers.UOffsetT) *GetCFilters {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &GetCFilters{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedGetCFiltersBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *GetCFilters) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *GetCFilters) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func (rcv *GetCFilters) FilterType() uint32 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(4))
+	if o != 0 {
+		return rcv._tab.GetUint32(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *GetCFilters) MutateFilterType(n uint32) bool {
+	return rcv._tab.MutateUint32Slot(4, n)
+}
+
+func (rcv *GetCFilters) StartHeight() uint32 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(6))
+	if o != 0 {
+		return rcv._tab.GetUint32(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *GetCFilters) MutateStartHeight(n uint32) bool {
+	return rcv._tab.MutateUint32Slot(6, n)
+}
+
+func (rcv *GetCFilters) StopHash(j int) byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(8))
+	if o != 0 {
+		a := rcv._tab.Vector(o)
+		return rcv._tab.GetByte(a + flatbuffers.UOffsetT(j*1))
+	}
+	return 0
+}
+
+func (rcv *GetCFilters) StopHashLength() int {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(8))
+	if o != 0 {
+		return rcv._tab.VectorLen(o)
+	}
+	return 0
+}
+
+func (rcv *GetCFilters) StopHashBytes() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(8))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func (rcv *GetCFilters) MutateStopHash(j int, n byte) bool {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(8))
+	if o != 0 {
+		a := rcv._tab.Vector(o)
+		return rcv._tab.MutateByte(a+flatbuffers.UOffsetT(j*1), n)
+	}
+	return false
+}
+
+func GetCFiltersStart(builder *flatbuffers.Builder) {
+	builder.StartObject(3)
+}
+func GetCFiltersAddFilterType(builder *flatbuffers.Builder, filterType uint32) {
+	builder.PrependUint32Slot(0, filterType, 0)
+}
+func GetCFiltersAddStartHeight(builder *flatbuffers.Builder, startHeight uint32) {
+	builder.PrependUint32Slot(1, startHeight, 0)
+}
+func GetCFiltersAddStopHash(builder *flatbuffers.Builder, stopHash flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(2, flatbuffers.UOffsetT(stopHash), 0)
+}
+func GetCFiltersStartStopHashVector(builder *flatbuffers.Builder, numElems int) flatbuffers.UOffsetT {
+	return builder.StartVector(1, numElems, 1)
+}
+func GetCFiltersEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
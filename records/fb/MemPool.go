@@ -0,0 +1,49 @@
+// Code generated by the FlatBuffers compiler. DO NOT EDIT.
+
+package fb
+
+import (
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+type MemPool struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsMemPool(buf []byte, offset flatbuffers.UOffsetT) *MemPool {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &MemPool{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishMemPoolBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsMemPool(buf []byte, offset flatbuffers.UOffsetT) *MemPool {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &MemPool{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedMemPoolBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *MemPool) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *MemPool) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func MemPoolStart(builder *flatbuffers.Builder) {
+	builder.StartObject(0)
+}
+func MemPoolEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
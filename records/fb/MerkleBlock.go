@@ -0,0 +1,49 @@
+// Code generated by the FlatBuffers compiler. DO NOT EDIT.
+
+package fb
+
+import (
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+type MerkleBlock struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsMerkleBlock(buf []byte, offset flatbuffers.UOffsetT) *MerkleBlock {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &MerkleBlock{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishMerkleBlockBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsMerkleBlock(buf []byte, offset flatbuffers.UOffsetT) *MerkleBlock {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &MerkleBlock{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedMerkleBlockBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *MerkleBlock) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *MerkleBlock) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func MerkleBlockStart(builder *flatbuffers.Builder) {
+	builder.StartObject(0)
+}
+func MerkleBlockEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
@@ -0,0 +1,150 @@
+// Code generated by the FlatBuffers compiler. DO NOT EDIT.
+
+package fb
+
+import (
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+type Summary struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsSummary(buf []byte, offset flatbuffers.UOffsetT) *Summary {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &Summary{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishSummaryBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsSummary(buf []byte, offset flatbuffers.UOffsetT) *Summary {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &Summary{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedSummaryBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *Summary) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *Summary) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func (rcv *Summary) Duration() int64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(4))
+	if o != 0 {
+		return rcv._tab.GetInt64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *Summary) MutateDuration(n int64) bool {
+	return rcv._tab.MutateInt64Slot(4, n)
+}
+
+func (rcv *Summary) Peers() int32 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(6))
+	if o != 0 {
+		return rcv._tab.GetInt32(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *Summary) MutatePeers(n int32) bool {
+	return rcv._tab.MutateInt32Slot(6, n)
+}
+
+func (rcv *Summary) Bytes() uint64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(8))
+	if o != 0 {
+		return rcv._tab.GetUint64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *Summary) MutateBytes(n uint64) bool {
+	return rcv._tab.MutateUint64Slot(8, n)
+}
+
+func (rcv *Summary) Commands(obj *Count, j int) bool {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(10))
+	if o != 0 {
+		x := rcv._tab.Vector(o)
+		x += flatbuffers.UOffsetT(j) * 4
+		x = rcv._tab.Indirect(x)
+		obj.Init(rcv._tab.Bytes, x)
+		return true
+	}
+	return false
+}
+
+func (rcv *Summary) CommandsLength() int {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(10))
+	if o != 0 {
+		return rcv._tab.VectorLen(o)
+	}
+	return 0
+}
+
+func (rcv *Summary) Written() uint64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(12))
+	if o != 0 {
+		return rcv._tab.GetUint64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *Summary) MutateWritten(n uint64) bool {
+	return rcv._tab.MutateUint64Slot(12, n)
+}
+
+func (rcv *Summary) Bans() uint64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(14))
+	if o != 0 {
+		return rcv._tab.GetUint64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *Summary) MutateBans(n uint64) bool {
+	return rcv._tab.MutateUint64Slot(14, n)
+}
+
+func SummaryStart(builder *flatbuffers.Builder) {
+	builder.StartObject(6)
+}
+func SummaryAddDuration(builder *flatbuffers.Builder, duration int64) {
+	builder.PrependInt64Slot(0, duration, 0)
+}
+func SummaryAddPeers(builder *flatbuffers.Builder, peers int32) {
+	builder.PrependInt32Slot(1, peers, 0)
+}
+func SummaryAddBytes(builder *flatbuffers.Builder, bytes uint64) {
+	builder.PrependUint64Slot(2, bytes, 0)
+}
+func SummaryAddCommands(builder *flatbuffers.Builder, commands flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(3, flatbuffers.UOffsetT(commands), 0)
+}
+func SummaryStartCommandsVector(builder *flatbuffers.Builder, numElems int) flatbuffers.UOffsetT {
+	return builder.StartVector(4, numElems, 4)
+}
+func SummaryAddWritten(builder *flatbuffers.Builder, written uint64) {
+	builder.PrependUint64Slot(4, written, 0)
+}
+func SummaryAddBans(builder *flatbuffers.Builder, bans uint64) {
+	builder.PrependUint64Slot(5, bans, 0)
+}
+func SummaryEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
@@ -0,0 +1,49 @@
+// Code generated by the FlatBuffers compiler. DO NOT EDIT.
+
+package fb
+
+import (
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+type VerAck struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsVerAck(buf []byte, offset flatbuffers.UOffsetT) *VerAck {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &VerAck{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishVerAckBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsVerAck(buf []byte, offset flatbuffers.UOffsetT) *VerAck {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &VerAck{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedVerAckBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *VerAck) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *VerAck) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func VerAckStart(builder *flatbuffers.Builder) {
+	builder.StartObject(0)
+}
+func VerAckEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
@@ -0,0 +1,172 @@
+// Code generated by the FlatBuffers compiler. DO NOT EDIT.
+
+package fb
+
+import (
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+type Version struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsVersion(buf []byte, offset flatbuffers.UOffsetT) *Version {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &Version{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishVersionBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsVersion(buf []byte, offset flatbuffers.UOffsetT) *Version {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &Version{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedVersionBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *Version) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *Version) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func (rcv *Version) Version() int32 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(4))
+	if o != 0 {
+		return rcv._tab.GetInt32(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *Version) MutateVersion(n int32) bool {
+	return rcv._tab.MutateInt32Slot(4, n)
+}
+
+func (rcv *Version) Services() uint64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(6))
+	if o != 0 {
+		return rcv._tab.GetUint64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *Version) MutateServices(n uint64) bool {
+	return rcv._tab.MutateUint64Slot(6, n)
+}
+
+func (rcv *Version) Sent() int64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(8))
+	if o != 0 {
+		return rcv._tab.GetInt64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *Version) MutateSent(n int64) bool {
+	return rcv._tab.MutateInt64Slot(8, n)
+}
+
+func (rcv *Version) Remote() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(10))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func (rcv *Version) Local() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(12))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func (rcv *Version) Agent() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(14))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func (rcv *Version) Block() int32 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(16))
+	if o != 0 {
+		return rcv._tab.GetInt32(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *Version) MutateBlock(n int32) bool {
+	return rcv._tab.MutateInt32Slot(16, n)
+}
+
+func (rcv *Version) Relay() bool {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(18))
+	if o != 0 {
+		return rcv._tab.GetBool(o + rcv._tab.Pos)
+	}
+	return false
+}
+
+func (rcv *Version) MutateRelay(n bool) bool {
+	return rcv._tab.MutateBoolSlot(18, n)
+}
+
+func (rcv *Version) Nonce() uint64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(20))
+	if o != 0 {
+		return rcv._tab.GetUint64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *Version) MutateNonce(n uint64) bool {
+	return rcv._tab.MutateUint64Slot(20, n)
+}
+
+func VersionStart(builder *flatbuffers.Builder) {
+	builder.StartObject(9)
+}
+func VersionAddVersion(builder *flatbuffers.Builder, version int32) {
+	builder.PrependInt32Slot(0, version, 0)
+}
+func VersionAddServices(builder *flatbuffers.Builder, services uint64) {
+	builder.PrependUint64Slot(1, services, 0)
+}
+func VersionAddSent(builder *flatbuffers.Builder, sent int64) {
+	builder.PrependInt64Slot(2, sent, 0)
+}
+func VersionAddRemote(builder *flatbuffers.Builder, remote flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(3, flatbuffers.UOffsetT(remote), 0)
+}
+func VersionAddLocal(builder *flatbuffers.Builder, local flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(4, flatbuffers.UOffsetT(local), 0)
+}
+func VersionAddAgent(builder *flatbuffers.Builder, agent flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(5, flatbuffers.UOffsetT(agent), 0)
+}
+func VersionAddBlock(builder *flatbuffers.Builder, block int32) {
+	builder.PrependInt32Slot(6, block, 0)
+}
+func VersionAddRelay(builder *flatbuffers.Builder, relay bool) {
+	builder.PrependBoolSlot(7, relay, false)
+}
+func VersionAddNonce(builder *flatbuffers.Builder, nonce uint64) {
+	builder.PrependUint64Slot(8, nonce, 0)
+}
+func VersionEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package records
+
+import (
+	"encoding/binary"
+	"sort"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/adaptor"
+	"github.com/CIRCL/pbtc/records/fb"
+)
+
+// This file holds the flatbuffers encoding of records, described by
+// fb/record.fbs, for consumers that want to read records in place without
+// parsing them. The buffers are built through the bindings generated from the
+// schema. They are size prefixed, so that buffers can be written back to back,
+// and padded to a multiple of eight bytes, which keeps the timestamps aligned
+// in a stream of buffers.
+
+// flatBuilder is implemented by all records with a flatbuffers encoding. It
+// builds the table of the record and returns it with its type in the body
+// union.
+type flatBuilder interface {
+	flat(b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT)
+}
+
+// Flat returns the record encoded as a size prefixed flatbuffer, as described
+// by fb/record.fbs. Tags and locations added by processors are included.
+// Records without a flatbuffers encoding are written without body.
+func Flat(record adaptor.Record) []byte {
+	tag, country, asn := annotations(record)
+	b := flatbuffers.NewBuilder(256)
+
+	kind, body := fb.BodyNONE, flatbuffers.UOffsetT(0)
+	inner, ok := Unwrap(record).(flatBuilder)
+	if ok {
+		kind, body = inner.flat(b)
+	}
+
+	cmd := b.CreateString(record.Command())
+	remote := b.CreateString(addrString(record.RemoteAddress()))
+	local := b.CreateString(addrString(record.LocalAddress()))
+	tg := b.CreateString(tag)
+	cc := b.CreateString(country)
+
+	fb.RecordStart(b)
+	if !record.Timestamp().IsZero() {
+		fb.RecordAddTimestamp(b, record.Timestamp().UnixNano())
+	}
+
+	fb.RecordAddCommand(b, cmd)
+	fb.RecordAddRemote(b, remote)
+	fb.RecordAddLocal(b, local)
+	fb.RecordAddTag(b, tg)
+	fb.RecordAddCountry(b, cc)
+	fb.RecordAddAsn(b, asn)
+	if ok {
+		fb.RecordAddBodyType(b, kind)
+		fb.RecordAddBody(b, body)
+	}
+
+	b.FinishSizePrefixed(fb.RecordEnd(b))
+
+	buf := b.FinishedBytes()
+	for len(buf)%8 != 0 {
+		buf = append(buf, 0)
+	}
+
+	binary.LittleEndian.PutUint32(buf, uint32(len(buf)-4))
+
+	return buf
+}
+
+// flatTables builds a vector of tables or strings that were built before.
+func flatTables(b *flatbuffers.Builder,
+	offsets []flatbuffers.UOffsetT) flatbuffers.UOffsetT {
+	b.StartVector(4, len(offsets), 4)
+	for i := len(offsets) - 1; i >= 0; i-- {
+		b.PrependUOffsetT(offsets[i])
+	}
+
+	return b.EndVector(len(offsets))
+}
+
+// flatStrings builds a vector of strings.
+func flatStrings(b *flatbuffers.Builder, strs []string) flatbuffers.UOffsetT {
+	offsets := make([]flatbuffers.UOffsetT, 0, len(strs))
+	for _, s := range strs {
+		offsets = append(offsets, b.CreateString(s))
+	}
+
+	return flatTables(b, offsets)
+}
+
+// flatHashes builds a vector of the concatenated hashes.
+func flatHashes(b *flatbuffers.Builder,
+	hashes [][32]byte) flatbuffers.UOffsetT {
+	buf := make([]byte, 0, 32*len(hashes))
+	for _, hash := range hashes {
+		buf = append(buf, hash[:]...)
+	}
+
+	return b.CreateByteVector(buf)
+}
+
+// flatItems builds a vector of inventory items.
+func flatItems(b *flatbuffers.Builder,
+	items []*ItemRecord) flatbuffers.UOffsetT {
+	offsets := make([]flatbuffers.UOffsetT, 0, len(items))
+	for _, item := range items {
+		offsets = append(offsets, item.flat(b))
+	}
+
+	return flatTables(b, offsets)
+}
+
+// flatCounts builds a vector of counts from the map, sorted by name so that
+// the encoding does not depend on the order of the map.
+func flatCounts(b *flatbuffers.Builder,
+	counts map[string]uint64) flatbuffers.UOffsetT {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	offsets := make([]flatbuffers.UOffsetT, 0, len(names))
+	for _, name := range names {
+		n := b.CreateString(name)
+		fb.CountStart(b)
+		fb.CountAddName(b, n)
+		fb.CountAddCount(b, counts[name])
+		offsets = append(offsets, fb.CountEnd(b))
+	}
+
+	return flatTables(b, offsets)
+}
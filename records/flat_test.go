@@ -0,0 +1,647 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package records
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/adaptor"
+	"github.com/CIRCL/pbtc/records/fb"
+)
+
+// flatBody decodes the record from the buffer and initializes the table of its
+// body, returning the type of the body.
+func flatBody(t *testing.T, record adaptor.Record,
+	table interface {
+		Init([]byte, flatbuffers.UOffsetT)
+	}) fb.Body {
+	buf := Flat(record)
+	if len(buf)%8 != 0 {
+		t.Errorf("%v: buffer of %v bytes is not padded", record.Command(),
+			len(buf))
+	}
+
+	if binary.LittleEndian.Uint32(buf) != uint32(len(buf)-4) {
+		t.Errorf("%v: wrong size prefix", record.Command())
+	}
+
+	rec := fb.GetSizePrefixedRootAsRecord(buf, 0)
+	if string(rec.Command()) != record.Command() {
+		t.Errorf("%v: decoded command %q", record.Command(), rec.Command())
+	}
+
+	var tab flatbuffers.Table
+	if !rec.Body(&tab) {
+		t.Fatalf("%v: no body", record.Command())
+	}
+
+	table.Init(tab.Bytes, tab.Pos)
+
+	return rec.BodyType()
+}
+
+func TestFlatRecord(t *testing.T) {
+	ping := NewPingRecord(&wire.MsgPing{Nonce: 7}, testRemote, testLocal,
+		testStamp)
+	record := NewGeoRecord(NewTaggedRecord(ping, "a"), "LU", 6661)
+
+	rec := fb.GetSizePrefixedRootAsRecord(Flat(record), 0)
+	if rec.Timestamp() != testStamp.UnixNano() {
+		t.Errorf("decoded timestamp %v", rec.Timestamp())
+	}
+
+	if string(rec.Command()) != "ping" {
+		t.Errorf("decoded command %q", rec.Command())
+	}
+
+	if string(rec.Remote()) != testRemote.String() {
+		t.Errorf("decoded remote %q", rec.Remote())
+	}
+
+	if string(rec.Local()) != testLocal.String() {
+		t.Errorf("decoded local %q", rec.Local())
+	}
+
+	if string(rec.Tag()) != "a" || string(rec.Country()) != "LU" ||
+		rec.Asn() != 6661 {
+		t.Errorf("decoded annotations %q, %q, %v", rec.Tag(), rec.Country(),
+			rec.Asn())
+	}
+
+	if rec.BodyType() != fb.BodyPing {
+		t.Fatalf("decoded body %v", rec.BodyType())
+	}
+
+	var tab flatbuffers.Table
+	rec.Body(&tab)
+	body := new(fb.Ping)
+	body.Init(tab.Bytes, tab.Pos)
+	if body.Nonce() != 7 {
+		t.Errorf("decoded nonce %v", body.Nonce())
+	}
+}
+
+func TestFlatStream(t *testing.T) {
+	var stream []byte
+	for nonce := uint64(1); nonce <= 3; nonce++ {
+		ping := NewPingRecord(&wire.MsgPing{Nonce: nonce}, testRemote,
+			testLocal, testStamp)
+		stream = append(stream, Flat(ping)...)
+	}
+
+	for nonce := uint64(1); nonce <= 3; nonce++ {
+		rec := fb.GetSizePrefixedRootAsRecord(stream, 0)
+
+		var tab flatbuffers.Table
+		rec.Body(&tab)
+		body := new(fb.Ping)
+		body.Init(tab.Bytes, tab.Pos)
+		if body.Nonce() != nonce {
+			t.Errorf("decoded nonce %v, want %v", body.Nonce(), nonce)
+		}
+
+		stream = stream[4+binary.LittleEndian.Uint32(stream):]
+	}
+
+	if len(stream) != 0 {
+		t.Errorf("%v bytes left over", len(stream))
+	}
+}
+
+func TestFlatEmpty(t *testing.T) {
+	tests := []struct {
+		record adaptor.Record
+		kind   fb.Body
+	}{
+		{NewVerAckRecord(&wire.MsgVerAck{}, testRemote, testLocal, testStamp),
+			fb.BodyVerAck},
+		{NewGetAddrRecord(&wire.MsgGetAddr{}, testRemote, testLocal,
+			testStamp), fb.BodyGetAddr},
+		{NewMemPoolRecord(&wire.MsgMemPool{}, testRemote, testLocal,
+			testStamp), fb.BodyMemPool},
+		{NewMerkleBlockRecord(&wire.MsgMerkleBlock{}, testRemote, testLocal,
+			testStamp), fb.BodyMerkleBlock},
+		{NewFilterAddRecord(&wire.MsgFilterAdd{}, testRemote, testLocal,
+			testStamp), fb.BodyFilterAdd},
+		{NewFilterClearRecord(&wire.MsgFilterClear{}, testRemote, testLocal,
+			testStamp), fb.BodyFilterClear},
+		{NewFilterLoadRecord(&wire.MsgFilterLoad{}, testRemote, testLocal,
+			testStamp), fb.BodyFilterLoad},
+	}
+
+	for _, test := range tests {
+		kind := flatBody(t, test.record, new(fb.VerAck))
+		if kind != test.kind {
+			t.Errorf("%v: decoded body %v", test.record.Command(), kind)
+		}
+	}
+}
+
+func TestFlatVersion(t *testing.T) {
+	record := NewVersionRecord(&wire.MsgVersion{
+		ProtocolVersion: 70002,
+		Services:        wire.SFNodeNetwork,
+		Timestamp:       time.Unix(1443657600, 0),
+		AddrYou:         *wire.NewNetAddressIPPort(testLocal.IP, 50000, 0),
+		AddrMe:          *wire.NewNetAddressIPPort(testRemote.IP, 8333, 1),
+		Nonce:           42,
+		UserAgent:       "/Satoshi:0.11.0/",
+		LastBlock:       375000,
+	}, testRemote, testLocal, testStamp)
+
+	body := new(fb.Version)
+	if flatBody(t, record, body) != fb.BodyVersion {
+		t.Fatalf("wrong body type")
+	}
+
+	if body.Version() != 70002 || body.Services() != 1 ||
+		body.Sent() != time.Unix(1443657600, 0).UnixNano() ||
+		string(body.Remote()) != testLocal.String() ||
+		string(body.Local()) != testRemote.String() ||
+		string(body.Agent()) != "/Satoshi:0.11.0/" ||
+		body.Block() != 375000 || !body.Relay() || body.Nonce() != 42 {
+		t.Errorf("decoded version %v %v %v %q %q %q %v %v %v",
+			body.Version(), body.Services(), body.Sent(), body.Remote(),
+			body.Local(), body.Agent(), body.Block(), body.Relay(),
+			body.Nonce())
+	}
+}
+
+func TestFlatAddr(t *testing.T) {
+	record := NewAddressRecord(&wire.MsgAddr{AddrList: []*wire.NetAddress{{
+		Timestamp: time.Unix(1443650000, 0),
+		Services:  wire.SFNodeNetwork,
+		IP:        net.ParseIP("198.51.100.7"),
+		Port:      8333,
+	}}}, testRemote, testLocal, testStamp)
+
+	body := new(fb.Addr)
+	if flatBody(t, record, body) != fb.BodyAddr {
+		t.Fatalf("wrong body type")
+	}
+
+	entry := new(fb.Entry)
+	if body.EntriesLength() != 1 || !body.Entries(entry, 0) {
+		t.Fatalf("decoded %v entries", body.EntriesLength())
+	}
+
+	if entry.Advertised() != time.Unix(1443650000, 0).UnixNano() ||
+		entry.Services() != 1 ||
+		string(entry.Address()) != "198.51.100.7:8333" {
+		t.Errorf("decoded entry %v %v %q", entry.Advertised(),
+			entry.Services(), entry.Address())
+	}
+}
+
+func TestFlatInventory(t *testing.T) {
+	list := []*wire.InvVect{
+		wire.NewInvVect(wire.InvTypeTx, &testHash),
+		wire.NewInvVect(wire.InvTypeBlock, &testStop),
+	}
+
+	inv := new(fb.Inv)
+	getdata := new(fb.GetData)
+	notfound := new(fb.NotFound)
+	tests := []struct {
+		record adaptor.Record
+		kind   fb.Body
+		body   interface {
+			Init([]byte, flatbuffers.UOffsetT)
+		}
+		items func(*fb.Item, int) bool
+	}{
+		{NewInventoryRecord(&wire.MsgInv{InvList: list}, testRemote,
+			testLocal, testStamp), fb.BodyInv, inv, inv.Items},
+		{NewGetDataRecord(&wire.MsgGetData{InvList: list}, testRemote,
+			testLocal, testStamp), fb.BodyGetData, getdata, getdata.Items},
+		{NewNotFoundRecord(&wire.MsgNotFound{InvList: list}, testRemote,
+			testLocal, testStamp), fb.BodyNotFound, notfound,
+			notfound.Items},
+	}
+
+	for _, test := range tests {
+		cmd := test.record.Command()
+		if flatBody(t, test.record, test.body) != test.kind {
+			t.Errorf("%v: wrong body type", cmd)
+			continue
+		}
+
+		for i, vec := range list {
+			item := new(fb.Item)
+			if !test.items(item, i) {
+				t.Errorf("%v: missing item %v", cmd, i)
+				continue
+			}
+
+			if item.Type() != uint32(vec.Type) ||
+				!bytes.Equal(item.HashBytes(), vec.Hash[:]) {
+				t.Errorf("%v: decoded item %v %x", cmd, item.Type(),
+					item.HashBytes())
+			}
+		}
+	}
+}
+
+func TestFlatLocator(t *testing.T) {
+	hashes := []*wire.ShaHash{&testHash, &testStop}
+	concat := append(append([]byte{}, testHash[:]...), testStop[:]...)
+
+	getblocks := NewGetBlocksRecord(&wire.MsgGetBlocks{
+		ProtocolVersion:    70002,
+		BlockLocatorHashes: hashes,
+		HashStop:           testStop,
+	}, testRemote, testLocal, testStamp)
+
+	blocks := new(fb.GetBlocks)
+	if flatBody(t, getblocks, blocks) != fb.BodyGetBlocks {
+		t.Fatalf("wrong body type")
+	}
+
+	if blocks.Version() != 70002 ||
+		!bytes.Equal(blocks.HashesBytes(), concat) ||
+		!bytes.Equal(blocks.StopBytes(), testStop[:]) {
+		t.Errorf("decoded getblocks %v %x %x", blocks.Version(),
+			blocks.HashesBytes(), blocks.StopBytes())
+	}
+
+	getheaders := NewGetHeadersRecord(&wire.MsgGetHeaders{
+		ProtocolVersion:    70002,
+		BlockLocatorHashes: hashes,
+		HashStop:           testStop,
+	}, testRemote, testLocal, testStamp)
+
+	headers := new(fb.GetHeaders)
+	if flatBody(t, getheaders, headers) != fb.BodyGetHeaders {
+		t.Fatalf("wrong body type")
+	}
+
+	if headers.Version() != 70002 ||
+		!bytes.Equal(headers.HashesBytes(), concat) ||
+		!bytes.Equal(headers.StopBytes(), testStop[:]) {
+		t.Errorf("decoded getheaders %v %x %x", headers.Version(),
+			headers.HashesBytes(), headers.StopBytes())
+	}
+}
+
+// checkFlatHeader compares a decoded header with the test header.
+func checkFlatHeader(t *testing.T, hdr *fb.Header) {
+	want := testHeader()
+	hash := want.BlockSha()
+	if !bytes.Equal(hdr.HashBytes(), hash[:]) || hdr.Version() != 3 ||
+		!bytes.Equal(hdr.PrevBlockBytes(), testHash[:]) ||
+		!bytes.Equal(hdr.MerkleRootBytes(), testStop[:]) ||
+		hdr.Timestamp() != want.Timestamp.UnixNano() ||
+		hdr.Bits() != want.Bits || hdr.Nonce() != want.Nonce {
+		t.Errorf("decoded header %x %v %x %x %v %v %v", hdr.HashBytes(),
+			hdr.Version(), hdr.PrevBlockBytes(), hdr.MerkleRootBytes(),
+			hdr.Timestamp(), hdr.Bits(), hdr.Nonce())
+	}
+}
+
+// checkFlatDetails compares decoded transaction details with the test
+// transaction.
+func checkFlatDetails(t *testing.T, details *fb.Details) {
+	tx := testDetailsTx()
+	hash := tx.TxSha()
+	if !bytes.Equal(details.HashBytes(), hash[:]) {
+		t.Errorf("decoded hash %x", details.HashBytes())
+	}
+
+	in := new(fb.Input)
+	if details.InputsLength() != 1 || !details.Inputs(in, 0) {
+		t.Fatalf("decoded %v inputs", details.InputsLength())
+	}
+
+	if !bytes.Equal(in.HashBytes(), testHash[:]) || in.Index() != 2 ||
+		in.Sequence() != 0xffffffff {
+		t.Errorf("decoded input %x %v %v", in.HashBytes(), in.Index(),
+			in.Sequence())
+	}
+
+	out := new(fb.Output)
+	if details.OutputsLength() != 1 || !details.Outputs(out, 0) {
+		t.Fatalf("decoded %v outputs", details.OutputsLength())
+	}
+
+	if out.Value() != 5000 || string(out.Class()) != "pubkeyhash" ||
+		out.Sigs() != 1 || out.AddressesLength() != 1 ||
+		string(out.Addresses(0)) != "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH" {
+		t.Errorf("decoded output %v %q %v %v", out.Value(), out.Class(),
+			out.Sigs(), out.AddressesLength())
+	}
+}
+
+func TestFlatHeaders(t *testing.T) {
+	hdr := testHeader()
+	record := NewHeadersRecord(&wire.MsgHeaders{
+		Headers: []*wire.BlockHeader{&hdr},
+	}, testRemote, testLocal, testStamp)
+
+	body := new(fb.Headers)
+	if flatBody(t, record, body) != fb.BodyHeaders {
+		t.Fatalf("wrong body type")
+	}
+
+	decoded := new(fb.Header)
+	if body.HeadersLength() != 1 || !body.Headers(decoded, 0) {
+		t.Fatalf("decoded %v headers", body.HeadersLength())
+	}
+
+	checkFlatHeader(t, decoded)
+}
+
+func TestFlatBlock(t *testing.T) {
+	record := NewBlockRecord(&wire.MsgBlock{
+		Header:       testHeader(),
+		Transactions: []*wire.MsgTx{testDetailsTx()},
+	}, testRemote, testLocal, testStamp)
+
+	body := new(fb.Block)
+	if flatBody(t, record, body) != fb.BodyBlock {
+		t.Fatalf("wrong body type")
+	}
+
+	checkFlatHeader(t, body.Header(nil))
+
+	details := new(fb.Details)
+	if body.TransactionsLength() != 1 || !body.Transactions(details, 0) {
+		t.Fatalf("decoded %v transactions", body.TransactionsLength())
+	}
+
+	checkFlatDetails(t, details)
+}
+
+func TestFlatTransaction(t *testing.T) {
+	record := NewTransactionRecord(testDetailsTx(), testRemote, testLocal,
+		testStamp)
+	record.SetAnnouncers([]adaptor.Announcement{
+		{Peer: "198.51.100.7:8333", Stamp: testStamp.Add(time.Second)},
+	})
+
+	body := new(fb.Tx)
+	if flatBody(t, record, body) != fb.BodyTx {
+		t.Fatalf("wrong body type")
+	}
+
+	checkFlatDetails(t, body.Details(nil))
+
+	ann := new(fb.Announcer)
+	if body.AnnouncersLength() != 1 || !body.Announcers(ann, 0) {
+		t.Fatalf("decoded %v announcers", body.AnnouncersLength())
+	}
+
+	if string(ann.Peer()) != "198.51.100.7:8333" ||
+		ann.Offset() != int64(time.Second) {
+		t.Errorf("decoded announcer %q %v", ann.Peer(), ann.Offset())
+	}
+
+	if body.Segwit() || body.WtxidLength() != 0 || body.WitnessLength() != 0 {
+		t.Errorf("decoded witness without segwit")
+	}
+
+	record.SetWitness(testStop, []uint32{107, 0})
+	body = new(fb.Tx)
+	flatBody(t, record, body)
+	if !body.Segwit() || !bytes.Equal(body.WtxidBytes(), testStop[:]) ||
+		body.WitnessLength() != 2 || body.Witness(0) != 107 ||
+		body.Witness(1) != 0 {
+		t.Errorf("decoded wtxid %x and %v witness sizes", body.WtxidBytes(),
+			body.WitnessLength())
+	}
+}
+
+func TestFlatNonce(t *testing.T) {
+	ping := new(fb.Ping)
+	record := NewPingRecord(&wire.MsgPing{Nonce: 7}, testRemote, testLocal,
+		testStamp)
+	if flatBody(t, record, ping) != fb.BodyPing || ping.Nonce() != 7 {
+		t.Errorf("decoded ping %v", ping.Nonce())
+	}
+
+	pong := new(fb.Pong)
+	record2 := NewPongRecord(&wire.MsgPong{Nonce: 8}, testRemote, testLocal,
+		testStamp)
+	if flatBody(t, record2, pong) != fb.BodyPong || pong.Nonce() != 8 {
+		t.Errorf("decoded pong %v", pong.Nonce())
+	}
+}
+
+func TestFlatReject(t *testing.T) {
+	record := NewRejectRecord(&wire.MsgReject{
+		Cmd:    "tx",
+		Code:   wire.RejectDuplicate,
+		Reason: "txn-already-known",
+		Hash:   testHash,
+	}, testRemote, testLocal, testStamp)
+
+	body := new(fb.Reject)
+	if flatBody(t, record, body) != fb.BodyReject {
+		t.Fatalf("wrong body type")
+	}
+
+	if body.Code() != uint32(wire.RejectDuplicate) ||
+		string(body.Command()) != "tx" ||
+		!bytes.Equal(body.HashBytes(), testHash[:]) ||
+		string(body.Reason()) != "txn-already-known" {
+		t.Errorf("decoded reject %v %q %x %q", body.Code(), body.Command(),
+			body.HashBytes(), body.Reason())
+	}
+}
+
+func TestFlatAlert(t *testing.T) {
+	record := NewAlertRecord(&wire.MsgAlert{Payload: &wire.Alert{
+		Version:    1,
+		RelayUntil: 1443657600,
+		Expiration: 1443744000,
+		ID:         1010,
+		Cancel:     1009,
+		SetCancel:  []int32{1001, 1002},
+		MinVer:     10000,
+		MaxVer:     70002,
+		SetSubVer:  []string{"/Satoshi:0.9.0/"},
+		Priority:   5000,
+		Comment:    "comment",
+		StatusBar:  "URGENT: upgrade required",
+		Reserved:   "reserved",
+	}}, testRemote, testLocal, testStamp)
+
+	body := new(fb.Alert)
+	if flatBody(t, record, body) != fb.BodyAlert {
+		t.Fatalf("wrong body type")
+	}
+
+	if body.Version() != 1 || body.RelayUntil() != 1443657600 ||
+		body.Expiration() != 1443744000 || body.Id() != 1010 ||
+		body.Cancel() != 1009 || body.MinVer() != 10000 ||
+		body.MaxVer() != 70002 || body.Priority() != 5000 {
+		t.Errorf("decoded alert %v %v %v %v %v %v %v %v", body.Version(),
+			body.RelayUntil(), body.Expiration(), body.Id(), body.Cancel(),
+			body.MinVer(), body.MaxVer(), body.Priority())
+	}
+
+	if body.SetCancelLength() != 2 || body.SetCancel(0) != 1001 ||
+		body.SetCancel(1) != 1002 {
+		t.Errorf("decoded %v cancels", body.SetCancelLength())
+	}
+
+	if body.SetSubVerLength() != 1 ||
+		string(body.SetSubVer(0)) != "/Satoshi:0.9.0/" {
+		t.Errorf("decoded %v sub versions", body.SetSubVerLength())
+	}
+
+	if string(body.Comment()) != "comment" ||
+		string(body.StatusBar()) != "URGENT: upgrade required" ||
+		string(body.Reserved()) != "reserved" {
+		t.Errorf("decoded alert %q %q %q", body.Comment(), body.StatusBar(),
+			body.Reserved())
+	}
+}
+
+func TestFlatFilters(t *testing.T) {
+	fee := new(fb.FeeFilter)
+	record := NewFeeFilterRecord(1000, testRemote, testLocal, testStamp)
+	if flatBody(t, record, fee) != fb.BodyFeeFilter || fee.Fee() != 1000 {
+		t.Errorf("decoded fee %v", fee.Fee())
+	}
+
+	rng := new(fb.GetCFilters)
+	record2 := NewGetCFiltersRecord(0, 375000, testStop, testRemote,
+		testLocal, testStamp)
+	if flatBody(t, record2, rng) != fb.BodyGetCFilters ||
+		rng.FilterType() != 0 || rng.StartHeight() != 375000 ||
+		!bytes.Equal(rng.StopHashBytes(), testStop[:]) {
+		t.Errorf("decoded getcfilters %v %v %x", rng.FilterType(),
+			rng.StartHeight(), rng.StopHashBytes())
+	}
+
+	data := []byte{0x13, 0x37, 0x00, 0xfe, 0x5a, 0xa5, 0x01, 0x80, 0x7f}
+	filter := new(fb.CFilter)
+	record3 := NewCFilterRecord(0, testHash, data, testRemote, testLocal,
+		testStamp)
+	if flatBody(t, record3, filter) != fb.BodyCFilter ||
+		!bytes.Equal(filter.BlockHashBytes(), testHash[:]) ||
+		!bytes.Equal(filter.FilterBytes(), data) {
+		t.Errorf("decoded cfilter %x %x", filter.BlockHashBytes(),
+			filter.FilterBytes())
+	}
+
+	headers := new(fb.CFHeaders)
+	record4 := NewCFHeadersRecord(0, testStop, testHash,
+		[][32]byte{testHash, testStop}, testRemote, testLocal, testStamp)
+	concat := append(append([]byte{}, testHash[:]...), testStop[:]...)
+	if flatBody(t, record4, headers) != fb.BodyCFHeaders ||
+		!bytes.Equal(headers.StopHashBytes(), testStop[:]) ||
+		!bytes.Equal(headers.PreviousHeaderBytes(), testHash[:]) ||
+		!bytes.Equal(headers.FilterHashesBytes(), concat) {
+		t.Errorf("decoded cfheaders %x %x %x", headers.StopHashBytes(),
+			headers.PreviousHeaderBytes(), headers.FilterHashesBytes())
+	}
+}
+
+func TestFlatRaw(t *testing.T) {
+	record := NewRawRecord("sendheaders", []byte{0xde, 0xad}, testRemote,
+		testLocal, testStamp)
+
+	body := new(fb.Raw)
+	if flatBody(t, record, body) != fb.BodyRaw ||
+		!bytes.Equal(body.PayloadBytes(), []byte{0xde, 0xad}) {
+		t.Errorf("decoded payload %x", body.PayloadBytes())
+	}
+}
+
+func TestFlatDisconnect(t *testing.T) {
+	record := NewDisconnectRecord(ReasonIdle, "no message for 5m0s",
+		testRemote, testLocal, testStamp)
+
+	body := new(fb.Disconnect)
+	if flatBody(t, record, body) != fb.BodyDisconnect ||
+		string(body.Reason()) != ReasonIdle ||
+		string(body.Detail()) != "no message for 5m0s" {
+		t.Errorf("decoded disconnect %q %q", body.Reason(), body.Detail())
+	}
+}
+
+// flatCountMap collects decoded counts into a map.
+func flatCountMap(n int, get func(*fb.Count, int) bool) map[string]uint64 {
+	counts := make(map[string]uint64)
+	for i := 0; i < n; i++ {
+		count := new(fb.Count)
+		get(count, i)
+		counts[string(count.Name())] = count.Count()
+	}
+
+	return counts
+}
+
+func TestFlatSummary(t *testing.T) {
+	record := NewSummaryRecord(testStamp.Add(-time.Minute), testStamp, 8,
+		4096, 1024, 2, map[string]uint64{"inv": 12, "tx": 3})
+
+	body := new(fb.Summary)
+	if flatBody(t, record, body) != fb.BodySummary {
+		t.Fatalf("wrong body type")
+	}
+
+	if body.Duration() != int64(time.Minute) || body.Peers() != 8 ||
+		body.Bytes() != 4096 || body.Written() != 1024 || body.Bans() != 2 {
+		t.Errorf("decoded summary %v %v %v %v %v", body.Duration(),
+			body.Peers(), body.Bytes(), body.Written(), body.Bans())
+	}
+
+	commands := flatCountMap(body.CommandsLength(), body.Commands)
+	if len(commands) != 2 || commands["inv"] != 12 || commands["tx"] != 3 {
+		t.Errorf("decoded commands %v", commands)
+	}
+}
+
+func TestFlatTopology(t *testing.T) {
+	record := NewTopologySnapshotRecord(testStamp, 2, 6,
+		map[string]uint64{"192.0": 8},
+		map[string]uint64{"/Satoshi:0.11.0/": 5, "/btcd:0.12.0/": 3})
+
+	body := new(fb.Topology)
+	if flatBody(t, record, body) != fb.BodyTopology {
+		t.Fatalf("wrong body type")
+	}
+
+	if body.Peers() != 8 || body.Inbound() != 2 || body.Outbound() != 6 {
+		t.Errorf("decoded topology %v %v %v", body.Peers(), body.Inbound(),
+			body.Outbound())
+	}
+
+	groups := flatCountMap(body.GroupsLength(), body.Groups)
+	if len(groups) != 1 || groups["192.0"] != 8 {
+		t.Errorf("decoded groups %v", groups)
+	}
+
+	software := flatCountMap(body.SoftwareLength(), body.Software)
+	if len(software) != 2 || software["/Satoshi:0.11.0/"] != 5 ||
+		software["/btcd:0.12.0/"] != 3 {
+		t.Errorf("decoded software %v", software)
+	}
+}
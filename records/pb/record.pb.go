@@ -0,0 +1,2521 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: record.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Record struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Timestamp int64                  `protobuf:"varint,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Command   string                 `protobuf:"bytes,2,opt,name=command,proto3" json:"command,omitempty"`
+	Remote    string                 `protobuf:"bytes,3,opt,name=remote,proto3" json:"remote,omitempty"`
+	Local     string                 `protobuf:"bytes,4,opt,name=local,proto3" json:"local,omitempty"`
+	Tag       string                 `protobuf:"bytes,5,opt,name=tag,proto3" json:"tag,omitempty"`
+	Country   string                 `protobuf:"bytes,6,opt,name=country,proto3" json:"country,omitempty"`
+	Asn       uint32                 `protobuf:"varint,7,opt,name=asn,proto3" json:"asn,omitempty"`
+	// Types that are valid to be assigned to Body:
+	//
+	//	*Record_Version
+	//	*Record_Addr
+	//	*Record_Inv
+	//	*Record_Getdata
+	//	*Record_Notfound
+	//	*Record_Getblocks
+	//	*Record_Getheaders
+	//	*Record_Headers
+	//	*Record_Block
+	//	*Record_Tx
+	//	*Record_Ping
+	//	*Record_Pong
+	//	*Record_Reject
+	//	*Record_Alert
+	//	*Record_Disconnect
+	//	*Record_Summary
+	//	*Record_Empty
+	//	*Record_Raw
+	//	*Record_Topology
+	//	*Record_Feefilter
+	//	*Record_Getcfilters
+	//	*Record_Cfilter
+	//	*Record_Cfheaders
+	Body          isRecord_Body `protobuf_oneof:"body"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Record) Reset() {
+	*x = Record{}
+	mi := &file_record_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Record) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Record) ProtoMessage() {}
+
+func (x *Record) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Record.ProtoReflect.Descriptor instead.
+func (*Record) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Record) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *Record) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+func (x *Record) GetRemote() string {
+	if x != nil {
+		return x.Remote
+	}
+	return ""
+}
+
+func (x *Record) GetLocal() string {
+	if x != nil {
+		return x.Local
+	}
+	return ""
+}
+
+func (x *Record) GetTag() string {
+	if x != nil {
+		return x.Tag
+	}
+	return ""
+}
+
+func (x *Record) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+func (x *Record) GetAsn() uint32 {
+	if x != nil {
+		return x.Asn
+	}
+	return 0
+}
+
+func (x *Record) GetBody() isRecord_Body {
+	if x != nil {
+		return x.Body
+	}
+	return nil
+}
+
+func (x *Record) GetVersion() *Version {
+	if x != nil {
+		if x, ok := x.Body.(*Record_Version); ok {
+			return x.Version
+		}
+	}
+	return nil
+}
+
+func (x *Record) GetAddr() *Addr {
+	if x != nil {
+		if x, ok := x.Body.(*Record_Addr); ok {
+			return x.Addr
+		}
+	}
+	return nil
+}
+
+func (x *Record) GetInv() *Inventory {
+	if x != nil {
+		if x, ok := x.Body.(*Record_Inv); ok {
+			return x.Inv
+		}
+	}
+	return nil
+}
+
+func (x *Record) GetGetdata() *Inventory {
+	if x != nil {
+		if x, ok := x.Body.(*Record_Getdata); ok {
+			return x.Getdata
+		}
+	}
+	return nil
+}
+
+func (x *Record) GetNotfound() *Inventory {
+	if x != nil {
+		if x, ok := x.Body.(*Record_Notfound); ok {
+			return x.Notfound
+		}
+	}
+	return nil
+}
+
+func (x *Record) GetGetblocks() *Locator {
+	if x != nil {
+		if x, ok := x.Body.(*Record_Getblocks); ok {
+			return x.Getblocks
+		}
+	}
+	return nil
+}
+
+func (x *Record) GetGetheaders() *Locator {
+	if x != nil {
+		if x, ok := x.Body.(*Record_Getheaders); ok {
+			return x.Getheaders
+		}
+	}
+	return nil
+}
+
+func (x *Record) GetHeaders() *Headers {
+	if x != nil {
+		if x, ok := x.Body.(*Record_Headers); ok {
+			return x.Headers
+		}
+	}
+	return nil
+}
+
+func (x *Record) GetBlock() *Block {
+	if x != nil {
+		if x, ok := x.Body.(*Record_Block); ok {
+			return x.Block
+		}
+	}
+	return nil
+}
+
+func (x *Record) GetTx() *Transaction {
+	if x != nil {
+		if x, ok := x.Body.(*Record_Tx); ok {
+			return x.Tx
+		}
+	}
+	return nil
+}
+
+func (x *Record) GetPing() *Nonce {
+	if x != nil {
+		if x, ok := x.Body.(*Record_Ping); ok {
+			return x.Ping
+		}
+	}
+	return nil
+}
+
+func (x *Record) GetPong() *Nonce {
+	if x != nil {
+		if x, ok := x.Body.(*Record_Pong); ok {
+			return x.Pong
+		}
+	}
+	return nil
+}
+
+func (x *Record) GetReject() *Reject {
+	if x != nil {
+		if x, ok := x.Body.(*Record_Reject); ok {
+			return x.Reject
+		}
+	}
+	return nil
+}
+
+func (x *Record) GetAlert() *Alert {
+	if x != nil {
+		if x, ok := x.Body.(*Record_Alert); ok {
+			return x.Alert
+		}
+	}
+	return nil
+}
+
+func (x *Record) GetDisconnect() *Disconnect {
+	if x != nil {
+		if x, ok := x.Body.(*Record_Disconnect); ok {
+			return x.Disconnect
+		}
+	}
+	return nil
+}
+
+func (x *Record) GetSummary() *Summary {
+	if x != nil {
+		if x, ok := x.Body.(*Record_Summary); ok {
+			return x.Summary
+		}
+	}
+	return nil
+}
+
+func (x *Record) GetEmpty() *Empty {
+	if x != nil {
+		if x, ok := x.Body.(*Record_Empty); ok {
+			return x.Empty
+		}
+	}
+	return nil
+}
+
+func (x *Record) GetRaw() *Raw {
+	if x != nil {
+		if x, ok := x.Body.(*Record_Raw); ok {
+			return x.Raw
+		}
+	}
+	return nil
+}
+
+func (x *Record) GetTopology() *Topology {
+	if x != nil {
+		if x, ok := x.Body.(*Record_Topology); ok {
+			return x.Topology
+		}
+	}
+	return nil
+}
+
+func (x *Record) GetFeefilter() *FeeFilter {
+	if x != nil {
+		if x, ok := x.Body.(*Record_Feefilter); ok {
+			return x.Feefilter
+		}
+	}
+	return nil
+}
+
+func (x *Record) GetGetcfilters() *FilterRange {
+	if x != nil {
+		if x, ok := x.Body.(*Record_Getcfilters); ok {
+			return x.Getcfilters
+		}
+	}
+	return nil
+}
+
+func (x *Record) GetCfilter() *CompactFilter {
+	if x != nil {
+		if x, ok := x.Body.(*Record_Cfilter); ok {
+			return x.Cfilter
+		}
+	}
+	return nil
+}
+
+func (x *Record) GetCfheaders() *FilterHeaders {
+	if x != nil {
+		if x, ok := x.Body.(*Record_Cfheaders); ok {
+			return x.Cfheaders
+		}
+	}
+	return nil
+}
+
+type isRecord_Body interface {
+	isRecord_Body()
+}
+
+type Record_Version struct {
+	Version *Version `protobuf:"bytes,10,opt,name=version,proto3,oneof"`
+}
+
+type Record_Addr struct {
+	Addr *Addr `protobuf:"bytes,11,opt,name=addr,proto3,oneof"`
+}
+
+type Record_Inv struct {
+	Inv *Inventory `protobuf:"bytes,12,opt,name=inv,proto3,oneof"`
+}
+
+type Record_Getdata struct {
+	Getdata *Inventory `protobuf:"bytes,13,opt,name=getdata,proto3,oneof"`
+}
+
+type Record_Notfound struct {
+	Notfound *Inventory `protobuf:"bytes,14,opt,name=notfound,proto3,oneof"`
+}
+
+type Record_Getblocks struct {
+	Getblocks *Locator `protobuf:"bytes,15,opt,name=getblocks,proto3,oneof"`
+}
+
+type Record_Getheaders struct {
+	Getheaders *Locator `protobuf:"bytes,16,opt,name=getheaders,proto3,oneof"`
+}
+
+type Record_Headers struct {
+	Headers *Headers `protobuf:"bytes,17,opt,name=headers,proto3,oneof"`
+}
+
+type Record_Block struct {
+	Block *Block `protobuf:"bytes,18,opt,name=block,proto3,oneof"`
+}
+
+type Record_Tx struct {
+	Tx *Transaction `protobuf:"bytes,19,opt,name=tx,proto3,oneof"`
+}
+
+type Record_Ping struct {
+	Ping *Nonce `protobuf:"bytes,20,opt,name=ping,proto3,oneof"`
+}
+
+type Record_Pong struct {
+	Pong *Nonce `protobuf:"bytes,21,opt,name=pong,proto3,oneof"`
+}
+
+type Record_Reject struct {
+	Reject *Reject `protobuf:"bytes,22,opt,name=reject,proto3,oneof"`
+}
+
+type Record_Alert struct {
+	Alert *Alert `protobuf:"bytes,23,opt,name=alert,proto3,oneof"`
+}
+
+type Record_Disconnect struct {
+	Disconnect *Disconnect `protobuf:"bytes,24,opt,name=disconnect,proto3,oneof"`
+}
+
+type Record_Summary struct {
+	Summary *Summary `protobuf:"bytes,25,opt,name=summary,proto3,oneof"`
+}
+
+type Record_Empty struct {
+	Empty *Empty `protobuf:"bytes,26,opt,name=empty,proto3,oneof"`
+}
+
+type Record_Raw struct {
+	Raw *Raw `protobuf:"bytes,27,opt,name=raw,proto3,oneof"`
+}
+
+type Record_Topology struct {
+	Topology *Topology `protobuf:"bytes,28,opt,name=topology,proto3,oneof"`
+}
+
+type Record_Feefilter struct {
+	Feefilter *FeeFilter `protobuf:"bytes,29,opt,name=feefilter,proto3,oneof"`
+}
+
+type Record_Getcfilters struct {
+	Getcfilters *FilterRange `protobuf:"bytes,30,opt,name=getcfilters,proto3,oneof"`
+}
+
+type Record_Cfilter struct {
+	Cfilter *CompactFilter `protobuf:"bytes,31,opt,name=cfilter,proto3,oneof"`
+}
+
+type Record_Cfheaders struct {
+	Cfheaders *FilterHeaders `protobuf:"bytes,32,opt,name=cfheaders,proto3,oneof"`
+}
+
+func (*Record_Version) isRecord_Body() {}
+
+func (*Record_Addr) isRecord_Body() {}
+
+func (*Record_Inv) isRecord_Body() {}
+
+func (*Record_Getdata) isRecord_Body() {}
+
+func (*Record_Notfound) isRecord_Body() {}
+
+func (*Record_Getblocks) isRecord_Body() {}
+
+func (*Record_Getheaders) isRecord_Body() {}
+
+func (*Record_Headers) isRecord_Body() {}
+
+func (*Record_Block) isRecord_Body() {}
+
+func (*Record_Tx) isRecord_Body() {}
+
+func (*Record_Ping) isRecord_Body() {}
+
+func (*Record_Pong) isRecord_Body() {}
+
+func (*Record_Reject) isRecord_Body() {}
+
+func (*Record_Alert) isRecord_Body() {}
+
+func (*Record_Disconnect) isRecord_Body() {}
+
+func (*Record_Summary) isRecord_Body() {}
+
+func (*Record_Empty) isRecord_Body() {}
+
+func (*Record_Raw) isRecord_Body() {}
+
+func (*Record_Topology) isRecord_Body() {}
+
+func (*Record_Feefilter) isRecord_Body() {}
+
+func (*Record_Getcfilters) isRecord_Body() {}
+
+func (*Record_Cfilter) isRecord_Body() {}
+
+func (*Record_Cfheaders) isRecord_Body() {}
+
+type Version struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Version       int32                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Services      uint64                 `protobuf:"varint,2,opt,name=services,proto3" json:"services,omitempty"`
+	Sent          int64                  `protobuf:"varint,3,opt,name=sent,proto3" json:"sent,omitempty"`
+	Remote        string                 `protobuf:"bytes,4,opt,name=remote,proto3" json:"remote,omitempty"`
+	Local         string                 `protobuf:"bytes,5,opt,name=local,proto3" json:"local,omitempty"`
+	Agent         string                 `protobuf:"bytes,6,opt,name=agent,proto3" json:"agent,omitempty"`
+	Block         int32                  `protobuf:"varint,7,opt,name=block,proto3" json:"block,omitempty"`
+	Relay         bool                   `protobuf:"varint,8,opt,name=relay,proto3" json:"relay,omitempty"`
+	Nonce         uint64                 `protobuf:"varint,9,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Version) Reset() {
+	*x = Version{}
+	mi := &file_record_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Version) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Version) ProtoMessage() {}
+
+func (x *Version) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Version.ProtoReflect.Descriptor instead.
+func (*Version) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Version) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Version) GetServices() uint64 {
+	if x != nil {
+		return x.Services
+	}
+	return 0
+}
+
+func (x *Version) GetSent() int64 {
+	if x != nil {
+		return x.Sent
+	}
+	return 0
+}
+
+func (x *Version) GetRemote() string {
+	if x != nil {
+		return x.Remote
+	}
+	return ""
+}
+
+func (x *Version) GetLocal() string {
+	if x != nil {
+		return x.Local
+	}
+	return ""
+}
+
+func (x *Version) GetAgent() string {
+	if x != nil {
+		return x.Agent
+	}
+	return ""
+}
+
+func (x *Version) GetBlock() int32 {
+	if x != nil {
+		return x.Block
+	}
+	return 0
+}
+
+func (x *Version) GetRelay() bool {
+	if x != nil {
+		return x.Relay
+	}
+	return false
+}
+
+func (x *Version) GetNonce() uint64 {
+	if x != nil {
+		return x.Nonce
+	}
+	return 0
+}
+
+type Entry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Advertised    int64                  `protobuf:"varint,1,opt,name=advertised,proto3" json:"advertised,omitempty"`
+	Services      uint64                 `protobuf:"varint,2,opt,name=services,proto3" json:"services,omitempty"`
+	Address       string                 `protobuf:"bytes,3,opt,name=address,proto3" json:"address,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Entry) Reset() {
+	*x = Entry{}
+	mi := &file_record_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Entry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Entry) ProtoMessage() {}
+
+func (x *Entry) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Entry.ProtoReflect.Descriptor instead.
+func (*Entry) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Entry) GetAdvertised() int64 {
+	if x != nil {
+		return x.Advertised
+	}
+	return 0
+}
+
+func (x *Entry) GetServices() uint64 {
+	if x != nil {
+		return x.Services
+	}
+	return 0
+}
+
+func (x *Entry) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+type Addr struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Entries       []*Entry               `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Addr) Reset() {
+	*x = Addr{}
+	mi := &file_record_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Addr) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Addr) ProtoMessage() {}
+
+func (x *Addr) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Addr.ProtoReflect.Descriptor instead.
+func (*Addr) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Addr) GetEntries() []*Entry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+type Item struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          uint32                 `protobuf:"varint,1,opt,name=type,proto3" json:"type,omitempty"`
+	Hash          []byte                 `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+	Since         int64                  `protobuf:"varint,3,opt,name=since,proto3" json:"since,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Item) Reset() {
+	*x = Item{}
+	mi := &file_record_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Item) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Item) ProtoMessage() {}
+
+func (x *Item) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Item.ProtoReflect.Descriptor instead.
+func (*Item) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Item) GetType() uint32 {
+	if x != nil {
+		return x.Type
+	}
+	return 0
+}
+
+func (x *Item) GetHash() []byte {
+	if x != nil {
+		return x.Hash
+	}
+	return nil
+}
+
+func (x *Item) GetSince() int64 {
+	if x != nil {
+		return x.Since
+	}
+	return 0
+}
+
+type Inventory struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Items         []*Item                `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Inventory) Reset() {
+	*x = Inventory{}
+	mi := &file_record_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Inventory) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Inventory) ProtoMessage() {}
+
+func (x *Inventory) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Inventory.ProtoReflect.Descriptor instead.
+func (*Inventory) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Inventory) GetItems() []*Item {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+type Locator struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Version       uint32                 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Hashes        [][]byte               `protobuf:"bytes,2,rep,name=hashes,proto3" json:"hashes,omitempty"`
+	Stop          []byte                 `protobuf:"bytes,3,opt,name=stop,proto3" json:"stop,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Locator) Reset() {
+	*x = Locator{}
+	mi := &file_record_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Locator) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Locator) ProtoMessage() {}
+
+func (x *Locator) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Locator.ProtoReflect.Descriptor instead.
+func (*Locator) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Locator) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Locator) GetHashes() [][]byte {
+	if x != nil {
+		return x.Hashes
+	}
+	return nil
+}
+
+func (x *Locator) GetStop() []byte {
+	if x != nil {
+		return x.Stop
+	}
+	return nil
+}
+
+type Header struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Hash          []byte                 `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	Version       int32                  `protobuf:"varint,2,opt,name=version,proto3" json:"version,omitempty"`
+	PrevBlock     []byte                 `protobuf:"bytes,3,opt,name=prev_block,json=prevBlock,proto3" json:"prev_block,omitempty"`
+	MerkleRoot    []byte                 `protobuf:"bytes,4,opt,name=merkle_root,json=merkleRoot,proto3" json:"merkle_root,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Bits          uint32                 `protobuf:"varint,6,opt,name=bits,proto3" json:"bits,omitempty"`
+	Nonce         uint32                 `protobuf:"varint,7,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	TxnCount      uint32                 `protobuf:"varint,8,opt,name=txn_count,json=txnCount,proto3" json:"txn_count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Header) Reset() {
+	*x = Header{}
+	mi := &file_record_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Header) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Header) ProtoMessage() {}
+
+func (x *Header) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Header.ProtoReflect.Descriptor instead.
+func (*Header) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Header) GetHash() []byte {
+	if x != nil {
+		return x.Hash
+	}
+	return nil
+}
+
+func (x *Header) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Header) GetPrevBlock() []byte {
+	if x != nil {
+		return x.PrevBlock
+	}
+	return nil
+}
+
+func (x *Header) GetMerkleRoot() []byte {
+	if x != nil {
+		return x.MerkleRoot
+	}
+	return nil
+}
+
+func (x *Header) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *Header) GetBits() uint32 {
+	if x != nil {
+		return x.Bits
+	}
+	return 0
+}
+
+func (x *Header) GetNonce() uint32 {
+	if x != nil {
+		return x.Nonce
+	}
+	return 0
+}
+
+func (x *Header) GetTxnCount() uint32 {
+	if x != nil {
+		return x.TxnCount
+	}
+	return 0
+}
+
+type Headers struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Headers       []*Header              `protobuf:"bytes,1,rep,name=headers,proto3" json:"headers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Headers) Reset() {
+	*x = Headers{}
+	mi := &file_record_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Headers) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Headers) ProtoMessage() {}
+
+func (x *Headers) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Headers.ProtoReflect.Descriptor instead.
+func (*Headers) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Headers) GetHeaders() []*Header {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+type Input struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Hash          []byte                 `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	Index         uint32                 `protobuf:"varint,2,opt,name=index,proto3" json:"index,omitempty"`
+	Sequence      uint32                 `protobuf:"varint,3,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Input) Reset() {
+	*x = Input{}
+	mi := &file_record_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Input) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Input) ProtoMessage() {}
+
+func (x *Input) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Input.ProtoReflect.Descriptor instead.
+func (*Input) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Input) GetHash() []byte {
+	if x != nil {
+		return x.Hash
+	}
+	return nil
+}
+
+func (x *Input) GetIndex() uint32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *Input) GetSequence() uint32 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+type Output struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Value         int64                  `protobuf:"varint,1,opt,name=value,proto3" json:"value,omitempty"`
+	Class         string                 `protobuf:"bytes,2,opt,name=class,proto3" json:"class,omitempty"`
+	Sigs          uint32                 `protobuf:"varint,3,opt,name=sigs,proto3" json:"sigs,omitempty"`
+	Addresses     []string               `protobuf:"bytes,4,rep,name=addresses,proto3" json:"addresses,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Output) Reset() {
+	*x = Output{}
+	mi := &file_record_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Output) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Output) ProtoMessage() {}
+
+func (x *Output) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Output.ProtoReflect.Descriptor instead.
+func (*Output) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Output) GetValue() int64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+func (x *Output) GetClass() string {
+	if x != nil {
+		return x.Class
+	}
+	return ""
+}
+
+func (x *Output) GetSigs() uint32 {
+	if x != nil {
+		return x.Sigs
+	}
+	return 0
+}
+
+func (x *Output) GetAddresses() []string {
+	if x != nil {
+		return x.Addresses
+	}
+	return nil
+}
+
+type Details struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Hash          []byte                 `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	Inputs        []*Input               `protobuf:"bytes,2,rep,name=inputs,proto3" json:"inputs,omitempty"`
+	Outputs       []*Output              `protobuf:"bytes,3,rep,name=outputs,proto3" json:"outputs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Details) Reset() {
+	*x = Details{}
+	mi := &file_record_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Details) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Details) ProtoMessage() {}
+
+func (x *Details) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Details.ProtoReflect.Descriptor instead.
+func (*Details) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Details) GetHash() []byte {
+	if x != nil {
+		return x.Hash
+	}
+	return nil
+}
+
+func (x *Details) GetInputs() []*Input {
+	if x != nil {
+		return x.Inputs
+	}
+	return nil
+}
+
+func (x *Details) GetOutputs() []*Output {
+	if x != nil {
+		return x.Outputs
+	}
+	return nil
+}
+
+type Block struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Header        *Header                `protobuf:"bytes,1,opt,name=header,proto3" json:"header,omitempty"`
+	Transactions  []*Details             `protobuf:"bytes,2,rep,name=transactions,proto3" json:"transactions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Block) Reset() {
+	*x = Block{}
+	mi := &file_record_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Block) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Block) ProtoMessage() {}
+
+func (x *Block) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Block.ProtoReflect.Descriptor instead.
+func (*Block) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Block) GetHeader() *Header {
+	if x != nil {
+		return x.Header
+	}
+	return nil
+}
+
+func (x *Block) GetTransactions() []*Details {
+	if x != nil {
+		return x.Transactions
+	}
+	return nil
+}
+
+type Transaction struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Details       *Details               `protobuf:"bytes,1,opt,name=details,proto3" json:"details,omitempty"`
+	Tracked       bool                   `protobuf:"varint,2,opt,name=tracked,proto3" json:"tracked,omitempty"`
+	Duplicate     bool                   `protobuf:"varint,3,opt,name=duplicate,proto3" json:"duplicate,omitempty"`
+	Peers         int32                  `protobuf:"varint,4,opt,name=peers,proto3" json:"peers,omitempty"`
+	Since         int64                  `protobuf:"varint,5,opt,name=since,proto3" json:"since,omitempty"`
+	Announcers    []*Announcer           `protobuf:"bytes,6,rep,name=announcers,proto3" json:"announcers,omitempty"`
+	Segwit        bool                   `protobuf:"varint,7,opt,name=segwit,proto3" json:"segwit,omitempty"`
+	Wtxid         []byte                 `protobuf:"bytes,8,opt,name=wtxid,proto3" json:"wtxid,omitempty"`
+	Witness       []uint32               `protobuf:"varint,9,rep,packed,name=witness,proto3" json:"witness,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Transaction) Reset() {
+	*x = Transaction{}
+	mi := &file_record_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Transaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Transaction) ProtoMessage() {}
+
+func (x *Transaction) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Transaction.ProtoReflect.Descriptor instead.
+func (*Transaction) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *Transaction) GetDetails() *Details {
+	if x != nil {
+		return x.Details
+	}
+	return nil
+}
+
+func (x *Transaction) GetTracked() bool {
+	if x != nil {
+		return x.Tracked
+	}
+	return false
+}
+
+func (x *Transaction) GetDuplicate() bool {
+	if x != nil {
+		return x.Duplicate
+	}
+	return false
+}
+
+func (x *Transaction) GetPeers() int32 {
+	if x != nil {
+		return x.Peers
+	}
+	return 0
+}
+
+func (x *Transaction) GetSince() int64 {
+	if x != nil {
+		return x.Since
+	}
+	return 0
+}
+
+func (x *Transaction) GetAnnouncers() []*Announcer {
+	if x != nil {
+		return x.Announcers
+	}
+	return nil
+}
+
+func (x *Transaction) GetSegwit() bool {
+	if x != nil {
+		return x.Segwit
+	}
+	return false
+}
+
+func (x *Transaction) GetWtxid() []byte {
+	if x != nil {
+		return x.Wtxid
+	}
+	return nil
+}
+
+func (x *Transaction) GetWitness() []uint32 {
+	if x != nil {
+		return x.Witness
+	}
+	return nil
+}
+
+// Announcer is a peer that announced a transaction, with the time of its
+// announcement relative to when the transaction was first seen.
+type Announcer struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Peer          string                 `protobuf:"bytes,1,opt,name=peer,proto3" json:"peer,omitempty"`
+	Offset        int64                  `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Announcer) Reset() {
+	*x = Announcer{}
+	mi := &file_record_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Announcer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Announcer) ProtoMessage() {}
+
+func (x *Announcer) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Announcer.ProtoReflect.Descriptor instead.
+func (*Announcer) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *Announcer) GetPeer() string {
+	if x != nil {
+		return x.Peer
+	}
+	return ""
+}
+
+func (x *Announcer) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+type Nonce struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Nonce         uint64                 `protobuf:"varint,1,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Nonce) Reset() {
+	*x = Nonce{}
+	mi := &file_record_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Nonce) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Nonce) ProtoMessage() {}
+
+func (x *Nonce) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Nonce.ProtoReflect.Descriptor instead.
+func (*Nonce) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *Nonce) GetNonce() uint64 {
+	if x != nil {
+		return x.Nonce
+	}
+	return 0
+}
+
+type Reject struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          uint32                 `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
+	Command       string                 `protobuf:"bytes,2,opt,name=command,proto3" json:"command,omitempty"`
+	Hash          []byte                 `protobuf:"bytes,3,opt,name=hash,proto3" json:"hash,omitempty"`
+	Reason        string                 `protobuf:"bytes,4,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Reject) Reset() {
+	*x = Reject{}
+	mi := &file_record_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Reject) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Reject) ProtoMessage() {}
+
+func (x *Reject) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Reject.ProtoReflect.Descriptor instead.
+func (*Reject) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *Reject) GetCode() uint32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *Reject) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+func (x *Reject) GetHash() []byte {
+	if x != nil {
+		return x.Hash
+	}
+	return nil
+}
+
+func (x *Reject) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type Alert struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Version       int32                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	RelayUntil    int64                  `protobuf:"varint,2,opt,name=relay_until,json=relayUntil,proto3" json:"relay_until,omitempty"`
+	Expiration    int64                  `protobuf:"varint,3,opt,name=expiration,proto3" json:"expiration,omitempty"`
+	Id            int32                  `protobuf:"varint,4,opt,name=id,proto3" json:"id,omitempty"`
+	Cancel        int32                  `protobuf:"varint,5,opt,name=cancel,proto3" json:"cancel,omitempty"`
+	MinVer        int32                  `protobuf:"varint,6,opt,name=min_ver,json=minVer,proto3" json:"min_ver,omitempty"`
+	MaxVer        int32                  `protobuf:"varint,7,opt,name=max_ver,json=maxVer,proto3" json:"max_ver,omitempty"`
+	Priority      int32                  `protobuf:"varint,8,opt,name=priority,proto3" json:"priority,omitempty"`
+	SetCancel     []int32                `protobuf:"varint,9,rep,packed,name=set_cancel,json=setCancel,proto3" json:"set_cancel,omitempty"`
+	SetSubVer     []string               `protobuf:"bytes,10,rep,name=set_sub_ver,json=setSubVer,proto3" json:"set_sub_ver,omitempty"`
+	Comment       string                 `protobuf:"bytes,11,opt,name=comment,proto3" json:"comment,omitempty"`
+	StatusBar     string                 `protobuf:"bytes,12,opt,name=status_bar,json=statusBar,proto3" json:"status_bar,omitempty"`
+	Reserved      string                 `protobuf:"bytes,13,opt,name=reserved,proto3" json:"reserved,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Alert) Reset() {
+	*x = Alert{}
+	mi := &file_record_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Alert) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Alert) ProtoMessage() {}
+
+func (x *Alert) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Alert.ProtoReflect.Descriptor instead.
+func (*Alert) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *Alert) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Alert) GetRelayUntil() int64 {
+	if x != nil {
+		return x.RelayUntil
+	}
+	return 0
+}
+
+func (x *Alert) GetExpiration() int64 {
+	if x != nil {
+		return x.Expiration
+	}
+	return 0
+}
+
+func (x *Alert) GetId() int32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Alert) GetCancel() int32 {
+	if x != nil {
+		return x.Cancel
+	}
+	return 0
+}
+
+func (x *Alert) GetMinVer() int32 {
+	if x != nil {
+		return x.MinVer
+	}
+	return 0
+}
+
+func (x *Alert) GetMaxVer() int32 {
+	if x != nil {
+		return x.MaxVer
+	}
+	return 0
+}
+
+func (x *Alert) GetPriority() int32 {
+	if x != nil {
+		return x.Priority
+	}
+	return 0
+}
+
+func (x *Alert) GetSetCancel() []int32 {
+	if x != nil {
+		return x.SetCancel
+	}
+	return nil
+}
+
+func (x *Alert) GetSetSubVer() []string {
+	if x != nil {
+		return x.SetSubVer
+	}
+	return nil
+}
+
+func (x *Alert) GetComment() string {
+	if x != nil {
+		return x.Comment
+	}
+	return ""
+}
+
+func (x *Alert) GetStatusBar() string {
+	if x != nil {
+		return x.StatusBar
+	}
+	return ""
+}
+
+func (x *Alert) GetReserved() string {
+	if x != nil {
+		return x.Reserved
+	}
+	return ""
+}
+
+type Disconnect struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Reason        string                 `protobuf:"bytes,1,opt,name=reason,proto3" json:"reason,omitempty"`
+	Detail        string                 `protobuf:"bytes,2,opt,name=detail,proto3" json:"detail,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Disconnect) Reset() {
+	*x = Disconnect{}
+	mi := &file_record_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Disconnect) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Disconnect) ProtoMessage() {}
+
+func (x *Disconnect) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Disconnect.ProtoReflect.Descriptor instead.
+func (*Disconnect) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *Disconnect) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *Disconnect) GetDetail() string {
+	if x != nil {
+		return x.Detail
+	}
+	return ""
+}
+
+type Summary struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Duration      int64                  `protobuf:"varint,1,opt,name=duration,proto3" json:"duration,omitempty"`
+	Peers         int32                  `protobuf:"varint,2,opt,name=peers,proto3" json:"peers,omitempty"`
+	Bytes         uint64                 `protobuf:"varint,3,opt,name=bytes,proto3" json:"bytes,omitempty"`
+	Commands      map[string]uint64      `protobuf:"bytes,4,rep,name=commands,proto3" json:"commands,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Written       uint64                 `protobuf:"varint,5,opt,name=written,proto3" json:"written,omitempty"`
+	Bans          uint64                 `protobuf:"varint,6,opt,name=bans,proto3" json:"bans,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Summary) Reset() {
+	*x = Summary{}
+	mi := &file_record_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Summary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Summary) ProtoMessage() {}
+
+func (x *Summary) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Summary.ProtoReflect.Descriptor instead.
+func (*Summary) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *Summary) GetDuration() int64 {
+	if x != nil {
+		return x.Duration
+	}
+	return 0
+}
+
+func (x *Summary) GetPeers() int32 {
+	if x != nil {
+		return x.Peers
+	}
+	return 0
+}
+
+func (x *Summary) GetBytes() uint64 {
+	if x != nil {
+		return x.Bytes
+	}
+	return 0
+}
+
+func (x *Summary) GetCommands() map[string]uint64 {
+	if x != nil {
+		return x.Commands
+	}
+	return nil
+}
+
+func (x *Summary) GetWritten() uint64 {
+	if x != nil {
+		return x.Written
+	}
+	return 0
+}
+
+func (x *Summary) GetBans() uint64 {
+	if x != nil {
+		return x.Bans
+	}
+	return 0
+}
+
+type Empty struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Empty) Reset() {
+	*x = Empty{}
+	mi := &file_record_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Empty) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Empty) ProtoMessage() {}
+
+func (x *Empty) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Empty.ProtoReflect.Descriptor instead.
+func (*Empty) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{20}
+}
+
+type Topology struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Peers         int32                  `protobuf:"varint,1,opt,name=peers,proto3" json:"peers,omitempty"`
+	Inbound       int32                  `protobuf:"varint,2,opt,name=inbound,proto3" json:"inbound,omitempty"`
+	Outbound      int32                  `protobuf:"varint,3,opt,name=outbound,proto3" json:"outbound,omitempty"`
+	Groups        map[string]uint64      `protobuf:"bytes,4,rep,name=groups,proto3" json:"groups,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Software      map[string]uint64      `protobuf:"bytes,5,rep,name=software,proto3" json:"software,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Topology) Reset() {
+	*x = Topology{}
+	mi := &file_record_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Topology) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Topology) ProtoMessage() {}
+
+func (x *Topology) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Topology.ProtoReflect.Descriptor instead.
+func (*Topology) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *Topology) GetPeers() int32 {
+	if x != nil {
+		return x.Peers
+	}
+	return 0
+}
+
+func (x *Topology) GetInbound() int32 {
+	if x != nil {
+		return x.Inbound
+	}
+	return 0
+}
+
+func (x *Topology) GetOutbound() int32 {
+	if x != nil {
+		return x.Outbound
+	}
+	return 0
+}
+
+func (x *Topology) GetGroups() map[string]uint64 {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+func (x *Topology) GetSoftware() map[string]uint64 {
+	if x != nil {
+		return x.Software
+	}
+	return nil
+}
+
+type Raw struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Payload       []byte                 `protobuf:"bytes,1,opt,name=payload,proto3" json:"payload,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Raw) Reset() {
+	*x = Raw{}
+	mi := &file_record_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Raw) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Raw) ProtoMessage() {}
+
+func (x *Raw) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Raw.ProtoReflect.Descriptor instead.
+func (*Raw) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *Raw) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+type FeeFilter struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Fee           int64                  `protobuf:"varint,1,opt,name=fee,proto3" json:"fee,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FeeFilter) Reset() {
+	*x = FeeFilter{}
+	mi := &file_record_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FeeFilter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FeeFilter) ProtoMessage() {}
+
+func (x *FeeFilter) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FeeFilter.ProtoReflect.Descriptor instead.
+func (*FeeFilter) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *FeeFilter) GetFee() int64 {
+	if x != nil {
+		return x.Fee
+	}
+	return 0
+}
+
+type FilterRange struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FilterType    uint32                 `protobuf:"varint,1,opt,name=filter_type,json=filterType,proto3" json:"filter_type,omitempty"`
+	StartHeight   uint32                 `protobuf:"varint,2,opt,name=start_height,json=startHeight,proto3" json:"start_height,omitempty"`
+	StopHash      []byte                 `protobuf:"bytes,3,opt,name=stop_hash,json=stopHash,proto3" json:"stop_hash,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FilterRange) Reset() {
+	*x = FilterRange{}
+	mi := &file_record_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FilterRange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FilterRange) ProtoMessage() {}
+
+func (x *FilterRange) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FilterRange.ProtoReflect.Descriptor instead.
+func (*FilterRange) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *FilterRange) GetFilterType() uint32 {
+	if x != nil {
+		return x.FilterType
+	}
+	return 0
+}
+
+func (x *FilterRange) GetStartHeight() uint32 {
+	if x != nil {
+		return x.StartHeight
+	}
+	return 0
+}
+
+func (x *FilterRange) GetStopHash() []byte {
+	if x != nil {
+		return x.StopHash
+	}
+	return nil
+}
+
+type CompactFilter struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FilterType    uint32                 `protobuf:"varint,1,opt,name=filter_type,json=filterType,proto3" json:"filter_type,omitempty"`
+	BlockHash     []byte                 `protobuf:"bytes,2,opt,name=block_hash,json=blockHash,proto3" json:"block_hash,omitempty"`
+	Filter        []byte                 `protobuf:"bytes,3,opt,name=filter,proto3" json:"filter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CompactFilter) Reset() {
+	*x = CompactFilter{}
+	mi := &file_record_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CompactFilter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CompactFilter) ProtoMessage() {}
+
+func (x *CompactFilter) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CompactFilter.ProtoReflect.Descriptor instead.
+func (*CompactFilter) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *CompactFilter) GetFilterType() uint32 {
+	if x != nil {
+		return x.FilterType
+	}
+	return 0
+}
+
+func (x *CompactFilter) GetBlockHash() []byte {
+	if x != nil {
+		return x.BlockHash
+	}
+	return nil
+}
+
+func (x *CompactFilter) GetFilter() []byte {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+type FilterHeaders struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	FilterType     uint32                 `protobuf:"varint,1,opt,name=filter_type,json=filterType,proto3" json:"filter_type,omitempty"`
+	StopHash       []byte                 `protobuf:"bytes,2,opt,name=stop_hash,json=stopHash,proto3" json:"stop_hash,omitempty"`
+	PreviousHeader []byte                 `protobuf:"bytes,3,opt,name=previous_header,json=previousHeader,proto3" json:"previous_header,omitempty"`
+	FilterHashes   [][]byte               `protobuf:"bytes,4,rep,name=filter_hashes,json=filterHashes,proto3" json:"filter_hashes,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *FilterHeaders) Reset() {
+	*x = FilterHeaders{}
+	mi := &file_record_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FilterHeaders) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FilterHeaders) ProtoMessage() {}
+
+func (x *FilterHeaders) ProtoReflect() protoreflect.Message {
+	mi := &file_record_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FilterHeaders.ProtoReflect.Descriptor instead.
+func (*FilterHeaders) Descriptor() ([]byte, []int) {
+	return file_record_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *FilterHeaders) GetFilterType() uint32 {
+	if x != nil {
+		return x.FilterType
+	}
+	return 0
+}
+
+func (x *FilterHeaders) GetStopHash() []byte {
+	if x != nil {
+		return x.StopHash
+	}
+	return nil
+}
+
+func (x *FilterHeaders) GetPreviousHeader() []byte {
+	if x != nil {
+		return x.PreviousHeader
+	}
+	return nil
+}
+
+func (x *FilterHeaders) GetFilterHashes() [][]byte {
+	if x != nil {
+		return x.FilterHashes
+	}
+	return nil
+}
+
+var File_record_proto protoreflect.FileDescriptor
+
+const file_record_proto_rawDesc = "" +
+	"\n" +
+	"\frecord.proto\x12\arecords\"\xce\t\n" +
+	"\x06Record\x12\x1c\n" +
+	"\ttimestamp\x18\x01 \x01(\x03R\ttimestamp\x12\x18\n" +
+	"\acommand\x18\x02 \x01(\tR\acommand\x12\x16\n" +
+	"\x06remote\x18\x03 \x01(\tR\x06remote\x12\x14\n" +
+	"\x05local\x18\x04 \x01(\tR\x05local\x12\x10\n" +
+	"\x03tag\x18\x05 \x01(\tR\x03tag\x12\x18\n" +
+	"\acountry\x18\x06 \x01(\tR\acountry\x12\x10\n" +
+	"\x03asn\x18\a \x01(\rR\x03asn\x12,\n" +
+	"\aversion\x18\n" +
+	" \x01(\v2\x10.records.VersionH\x00R\aversion\x12#\n" +
+	"\x04addr\x18\v \x01(\v2\r.records.AddrH\x00R\x04addr\x12&\n" +
+	"\x03inv\x18\f \x01(\v2\x12.records.InventoryH\x00R\x03inv\x12.\n" +
+	"\agetdata\x18\r \x01(\v2\x12.records.InventoryH\x00R\agetdata\x120\n" +
+	"\bnotfound\x18\x0e \x01(\v2\x12.records.InventoryH\x00R\bnotfound\x120\n" +
+	"\tgetblocks\x18\x0f \x01(\v2\x10.records.LocatorH\x00R\tgetblocks\x122\n" +
+	"\n" +
+	"getheaders\x18\x10 \x01(\v2\x10.records.LocatorH\x00R\n" +
+	"getheaders\x12,\n" +
+	"\aheaders\x18\x11 \x01(\v2\x10.records.HeadersH\x00R\aheaders\x12&\n" +
+	"\x05block\x18\x12 \x01(\v2\x0e.records.BlockH\x00R\x05block\x12&\n" +
+	"\x02tx\x18\x13 \x01(\v2\x14.records.TransactionH\x00R\x02tx\x12$\n" +
+	"\x04ping\x18\x14 \x01(\v2\x0e.records.NonceH\x00R\x04ping\x12$\n" +
+	"\x04pong\x18\x15 \x01(\v2\x0e.records.NonceH\x00R\x04pong\x12)\n" +
+	"\x06reject\x18\x16 \x01(\v2\x0f.records.RejectH\x00R\x06reject\x12&\n" +
+	"\x05alert\x18\x17 \x01(\v2\x0e.records.AlertH\x00R\x05alert\x125\n" +
+	"\n" +
+	"disconnect\x18\x18 \x01(\v2\x13.records.DisconnectH\x00R\n" +
+	"disconnect\x12,\n" +
+	"\asummary\x18\x19 \x01(\v2\x10.records.SummaryH\x00R\asummary\x12&\n" +
+	"\x05empty\x18\x1a \x01(\v2\x0e.records.EmptyH\x00R\x05empty\x12 \n" +
+	"\x03raw\x18\x1b \x01(\v2\f.records.RawH\x00R\x03raw\x12/\n" +
+	"\btopology\x18\x1c \x01(\v2\x11.records.TopologyH\x00R\btopology\x122\n" +
+	"\tfeefilter\x18\x1d \x01(\v2\x12.records.FeeFilterH\x00R\tfeefilter\x128\n" +
+	"\vgetcfilters\x18\x1e \x01(\v2\x14.records.FilterRangeH\x00R\vgetcfilters\x122\n" +
+	"\acfilter\x18\x1f \x01(\v2\x16.records.CompactFilterH\x00R\acfilter\x126\n" +
+	"\tcfheaders\x18  \x01(\v2\x16.records.FilterHeadersH\x00R\tcfheadersB\x06\n" +
+	"\x04body\"\xd9\x01\n" +
+	"\aVersion\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\x05R\aversion\x12\x1a\n" +
+	"\bservices\x18\x02 \x01(\x04R\bservices\x12\x12\n" +
+	"\x04sent\x18\x03 \x01(\x03R\x04sent\x12\x16\n" +
+	"\x06remote\x18\x04 \x01(\tR\x06remote\x12\x14\n" +
+	"\x05local\x18\x05 \x01(\tR\x05local\x12\x14\n" +
+	"\x05agent\x18\x06 \x01(\tR\x05agent\x12\x14\n" +
+	"\x05block\x18\a \x01(\x05R\x05block\x12\x14\n" +
+	"\x05relay\x18\b \x01(\bR\x05relay\x12\x14\n" +
+	"\x05nonce\x18\t \x01(\x04R\x05nonce\"]\n" +
+	"\x05Entry\x12\x1e\n" +
+	"\n" +
+	"advertised\x18\x01 \x01(\x03R\n" +
+	"advertised\x12\x1a\n" +
+	"\bservices\x18\x02 \x01(\x04R\bservices\x12\x18\n" +
+	"\aaddress\x18\x03 \x01(\tR\aaddress\"0\n" +
+	"\x04Addr\x12(\n" +
+	"\aentries\x18\x01 \x03(\v2\x0e.records.EntryR\aentries\"D\n" +
+	"\x04Item\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\rR\x04type\x12\x12\n" +
+	"\x04hash\x18\x02 \x01(\fR\x04hash\x12\x14\n" +
+	"\x05since\x18\x03 \x01(\x03R\x05since\"0\n" +
+	"\tInventory\x12#\n" +
+	"\x05items\x18\x01 \x03(\v2\r.records.ItemR\x05items\"O\n" +
+	"\aLocator\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\rR\aversion\x12\x16\n" +
+	"\x06hashes\x18\x02 \x03(\fR\x06hashes\x12\x12\n" +
+	"\x04stop\x18\x03 \x01(\fR\x04stop\"\xdb\x01\n" +
+	"\x06Header\x12\x12\n" +
+	"\x04hash\x18\x01 \x01(\fR\x04hash\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\x05R\aversion\x12\x1d\n" +
+	"\n" +
+	"prev_block\x18\x03 \x01(\fR\tprevBlock\x12\x1f\n" +
+	"\vmerkle_root\x18\x04 \x01(\fR\n" +
+	"merkleRoot\x12\x1c\n" +
+	"\ttimestamp\x18\x05 \x01(\x03R\ttimestamp\x12\x12\n" +
+	"\x04bits\x18\x06 \x01(\rR\x04bits\x12\x14\n" +
+	"\x05nonce\x18\a \x01(\rR\x05nonce\x12\x1b\n" +
+	"\ttxn_count\x18\b \x01(\rR\btxnCount\"4\n" +
+	"\aHeaders\x12)\n" +
+	"\aheaders\x18\x01 \x03(\v2\x0f.records.HeaderR\aheaders\"M\n" +
+	"\x05Input\x12\x12\n" +
+	"\x04hash\x18\x01 \x01(\fR\x04hash\x12\x14\n" +
+	"\x05index\x18\x02 \x01(\rR\x05index\x12\x1a\n" +
+	"\bsequence\x18\x03 \x01(\rR\bsequence\"f\n" +
+	"\x06Output\x12\x14\n" +
+	"\x05value\x18\x01 \x01(\x03R\x05value\x12\x14\n" +
+	"\x05class\x18\x02 \x01(\tR\x05class\x12\x12\n" +
+	"\x04sigs\x18\x03 \x01(\rR\x04sigs\x12\x1c\n" +
+	"\taddresses\x18\x04 \x03(\tR\taddresses\"p\n" +
+	"\aDetails\x12\x12\n" +
+	"\x04hash\x18\x01 \x01(\fR\x04hash\x12&\n" +
+	"\x06inputs\x18\x02 \x03(\v2\x0e.records.InputR\x06inputs\x12)\n" +
+	"\aoutputs\x18\x03 \x03(\v2\x0f.records.OutputR\aoutputs\"f\n" +
+	"\x05Block\x12'\n" +
+	"\x06header\x18\x01 \x01(\v2\x0f.records.HeaderR\x06header\x124\n" +
+	"\ftransactions\x18\x02 \x03(\v2\x10.records.DetailsR\ftransactions\"\x99\x02\n" +
+	"\vTransaction\x12*\n" +
+	"\adetails\x18\x01 \x01(\v2\x10.records.DetailsR\adetails\x12\x18\n" +
+	"\atracked\x18\x02 \x01(\bR\atracked\x12\x1c\n" +
+	"\tduplicate\x18\x03 \x01(\bR\tduplicate\x12\x14\n" +
+	"\x05peers\x18\x04 \x01(\x05R\x05peers\x12\x14\n" +
+	"\x05since\x18\x05 \x01(\x03R\x05since\x122\n" +
+	"\n" +
+	"announcers\x18\x06 \x03(\v2\x12.records.AnnouncerR\n" +
+	"announcers\x12\x16\n" +
+	"\x06segwit\x18\a \x01(\bR\x06segwit\x12\x14\n" +
+	"\x05wtxid\x18\b \x01(\fR\x05wtxid\x12\x18\n" +
+	"\awitness\x18\t \x03(\rR\awitness\"7\n" +
+	"\tAnnouncer\x12\x12\n" +
+	"\x04peer\x18\x01 \x01(\tR\x04peer\x12\x16\n" +
+	"\x06offset\x18\x02 \x01(\x03R\x06offset\"\x1d\n" +
+	"\x05Nonce\x12\x14\n" +
+	"\x05nonce\x18\x01 \x01(\x04R\x05nonce\"b\n" +
+	"\x06Reject\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\rR\x04code\x12\x18\n" +
+	"\acommand\x18\x02 \x01(\tR\acommand\x12\x12\n" +
+	"\x04hash\x18\x03 \x01(\fR\x04hash\x12\x16\n" +
+	"\x06reason\x18\x04 \x01(\tR\x06reason\"\xec\x02\n" +
+	"\x05Alert\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\x05R\aversion\x12\x1f\n" +
+	"\vrelay_until\x18\x02 \x01(\x03R\n" +
+	"relayUntil\x12\x1e\n" +
+	"\n" +
+	"expiration\x18\x03 \x01(\x03R\n" +
+	"expiration\x12\x0e\n" +
+	"\x02id\x18\x04 \x01(\x05R\x02id\x12\x16\n" +
+	"\x06cancel\x18\x05 \x01(\x05R\x06cancel\x12\x17\n" +
+	"\amin_ver\x18\x06 \x01(\x05R\x06minVer\x12\x17\n" +
+	"\amax_ver\x18\a \x01(\x05R\x06maxVer\x12\x1a\n" +
+	"\bpriority\x18\b \x01(\x05R\bpriority\x12\x1d\n" +
+	"\n" +
+	"set_cancel\x18\t \x03(\x05R\tsetCancel\x12\x1e\n" +
+	"\vset_sub_ver\x18\n" +
+	" \x03(\tR\tsetSubVer\x12\x18\n" +
+	"\acomment\x18\v \x01(\tR\acomment\x12\x1d\n" +
+	"\n" +
+	"status_bar\x18\f \x01(\tR\tstatusBar\x12\x1a\n" +
+	"\breserved\x18\r \x01(\tR\breserved\"<\n" +
+	"\n" +
+	"Disconnect\x12\x16\n" +
+	"\x06reason\x18\x01 \x01(\tR\x06reason\x12\x16\n" +
+	"\x06detail\x18\x02 \x01(\tR\x06detail\"\xf8\x01\n" +
+	"\aSummary\x12\x1a\n" +
+	"\bduration\x18\x01 \x01(\x03R\bduration\x12\x14\n" +
+	"\x05peers\x18\x02 \x01(\x05R\x05peers\x12\x14\n" +
+	"\x05bytes\x18\x03 \x01(\x04R\x05bytes\x12:\n" +
+	"\bcommands\x18\x04 \x03(\v2\x1e.records.Summary.CommandsEntryR\bcommands\x12\x18\n" +
+	"\awritten\x18\x05 \x01(\x04R\awritten\x12\x12\n" +
+	"\x04bans\x18\x06 \x01(\x04R\x04bans\x1a;\n" +
+	"\rCommandsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x04R\x05value:\x028\x01\"\a\n" +
+	"\x05Empty\"\xc2\x02\n" +
+	"\bTopology\x12\x14\n" +
+	"\x05peers\x18\x01 \x01(\x05R\x05peers\x12\x18\n" +
+	"\ainbound\x18\x02 \x01(\x05R\ainbound\x12\x1a\n" +
+	"\boutbound\x18\x03 \x01(\x05R\boutbound\x125\n" +
+	"\x06groups\x18\x04 \x03(\v2\x1d.records.Topology.GroupsEntryR\x06groups\x12;\n" +
+	"\bsoftware\x18\x05 \x03(\v2\x1f.records.Topology.SoftwareEntryR\bsoftware\x1a9\n" +
+	"\vGroupsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x04R\x05value:\x028\x01\x1a;\n" +
+	"\rSoftwareEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x04R\x05value:\x028\x01\"\x1f\n" +
+	"\x03Raw\x12\x18\n" +
+	"\apayload\x18\x01 \x01(\fR\apayload\"\x1d\n" +
+	"\tFeeFilter\x12\x10\n" +
+	"\x03fee\x18\x01 \x01(\x03R\x03fee\"n\n" +
+	"\vFilterRange\x12\x1f\n" +
+	"\vfilter_type\x18\x01 \x01(\rR\n" +
+	"filterType\x12!\n" +
+	"\fstart_height\x18\x02 \x01(\rR\vstartHeight\x12\x1b\n" +
+	"\tstop_hash\x18\x03 \x01(\fR\bstopHash\"g\n" +
+	"\rCompactFilter\x12\x1f\n" +
+	"\vfilter_type\x18\x01 \x01(\rR\n" +
+	"filterType\x12\x1d\n" +
+	"\n" +
+	"block_hash\x18\x02 \x01(\fR\tblockHash\x12\x16\n" +
+	"\x06filter\x18\x03 \x01(\fR\x06filter\"\x9b\x01\n" +
+	"\rFilterHeaders\x12\x1f\n" +
+	"\vfilter_type\x18\x01 \x01(\rR\n" +
+	"filterType\x12\x1b\n" +
+	"\tstop_hash\x18\x02 \x01(\fR\bstopHash\x12'\n" +
+	"\x0fprevious_header\x18\x03 \x01(\fR\x0epreviousHeader\x12#\n" +
+	"\rfilter_hashes\x18\x04 \x03(\fR\ffilterHashesB\"Z github.com/CIRCL/pbtc/records/pbb\x06proto3"
+
+var (
+	file_record_proto_rawDescOnce sync.Once
+	file_record_proto_rawDescData []byte
+)
+
+func file_record_proto_rawDescGZIP() []byte {
+	file_record_proto_rawDescOnce.Do(func() {
+		file_record_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_record_proto_rawDesc), len(file_record_proto_rawDesc)))
+	})
+	return file_record_proto_rawDescData
+}
+
+var file_record_proto_msgTypes = make([]protoimpl.MessageInfo, 30)
+var file_record_proto_goTypes = []any{
+	(*Record)(nil),        // 0: records.Record
+	(*Version)(nil),       // 1: records.Version
+	(*Entry)(nil),         // 2: records.Entry
+	(*Addr)(nil),          // 3: records.Addr
+	(*Item)(nil),          // 4: records.Item
+	(*Inventory)(nil),     // 5: records.Inventory
+	(*Locator)(nil),       // 6: records.Locator
+	(*Header)(nil),        // 7: records.Header
+	(*Headers)(nil),       // 8: records.Headers
+	(*Input)(nil),         // 9: records.Input
+	(*Output)(nil),        // 10: records.Output
+	(*Details)(nil),       // 11: records.Details
+	(*Block)(nil),         // 12: records.Block
+	(*Transaction)(nil),   // 13: records.Transaction
+	(*Announcer)(nil),     // 14: records.Announcer
+	(*Nonce)(nil),         // 15: records.Nonce
+	(*Reject)(nil),        // 16: records.Reject
+	(*Alert)(nil),         // 17: records.Alert
+	(*Disconnect)(nil),    // 18: records.Disconnect
+	(*Summary)(nil),       // 19: records.Summary
+	(*Empty)(nil),         // 20: records.Empty
+	(*Topology)(nil),      // 21: records.Topology
+	(*Raw)(nil),           // 22: records.Raw
+	(*FeeFilter)(nil),     // 23: records.FeeFilter
+	(*FilterRange)(nil),   // 24: records.FilterRange
+	(*CompactFilter)(nil), // 25: records.CompactFilter
+	(*FilterHeaders)(nil), // 26: records.FilterHeaders
+	nil,                   // 27: records.Summary.CommandsEntry
+	nil,                   // 28: records.Topology.GroupsEntry
+	nil,                   // 29: records.Topology.SoftwareEntry
+}
+var file_record_proto_depIdxs = []int32{
+	1,  // 0: records.Record.version:type_name -> records.Version
+	3,  // 1: records.Record.addr:type_name -> records.Addr
+	5,  // 2: records.Record.inv:type_name -> records.Inventory
+	5,  // 3: records.Record.getdata:type_name -> records.Inventory
+	5,  // 4: records.Record.notfound:type_name -> records.Inventory
+	6,  // 5: records.Record.getblocks:type_name -> records.Locator
+	6,  // 6: records.Record.getheaders:type_name -> records.Locator
+	8,  // 7: records.Record.headers:type_name -> records.Headers
+	12, // 8: records.Record.block:type_name -> records.Block
+	13, // 9: records.Record.tx:type_name -> records.Transaction
+	15, // 10: records.Record.ping:type_name -> records.Nonce
+	15, // 11: records.Record.pong:type_name -> records.Nonce
+	16, // 12: records.Record.reject:type_name -> records.Reject
+	17, // 13: records.Record.alert:type_name -> records.Alert
+	18, // 14: records.Record.disconnect:type_name -> records.Disconnect
+	19, // 15: records.Record.summary:type_name -> records.Summary
+	20, // 16: records.Record.empty:type_name -> records.Empty
+	22, // 17: records.Record.raw:type_name -> records.Raw
+	21, // 18: records.Record.topology:type_name -> records.Topology
+	23, // 19: records.Record.feefilter:type_name -> records.FeeFilter
+	24, // 20: records.Record.getcfilters:type_name -> records.FilterRange
+	25, // 21: records.Record.cfilter:type_name -> records.CompactFilter
+	26, // 22: records.Record.cfheaders:type_name -> records.FilterHeaders
+	2,  // 23: records.Addr.entries:type_name -> records.Entry
+	4,  // 24: records.Inventory.items:type_name -> records.Item
+	7,  // 25: records.Headers.headers:type_name -> records.Header
+	9,  // 26: records.Details.inputs:type_name -> records.Input
+	10, // 27: records.Details.outputs:type_name -> records.Output
+	7,  // 28: records.Block.header:type_name -> records.Header
+	11, // 29: records.Block.transactions:type_name -> records.Details
+	11, // 30: records.Transaction.details:type_name -> records.Details
+	14, // 31: records.Transaction.announcers:type_name -> records.Announcer
+	27, // 32: records.Summary.commands:type_name -> records.Summary.CommandsEntry
+	28, // 33: records.Topology.groups:type_name -> records.Topology.GroupsEntry
+	29, // 34: records.Topology.software:type_name -> records.Topology.SoftwareEntry
+	35, // [35:35] is the sub-list for method output_type
+	35, // [35:35] is the sub-list for method input_type
+	35, // [35:35] is the sub-list for extension type_name
+	35, // [35:35] is the sub-list for extension extendee
+	0,  // [0:35] is the sub-list for field type_name
+}
+
+func init() { file_record_proto_init() }
+func file_record_proto_init() {
+	if File_record_proto != nil {
+		return
+	}
+	file_record_proto_msgTypes[0].OneofWrappers = []any{
+		(*Record_Version)(nil),
+		(*Record_Addr)(nil),
+		(*Record_Inv)(nil),
+		(*Record_Getdata)(nil),
+		(*Record_Notfound)(nil),
+		(*Record_Getblocks)(nil),
+		(*Record_Getheaders)(nil),
+		(*Record_Headers)(nil),
+		(*Record_Block)(nil),
+		(*Record_Tx)(nil),
+		(*Record_Ping)(nil),
+		(*Record_Pong)(nil),
+		(*Record_Reject)(nil),
+		(*Record_Alert)(nil),
+		(*Record_Disconnect)(nil),
+		(*Record_Summary)(nil),
+		(*Record_Empty)(nil),
+		(*Record_Raw)(nil),
+		(*Record_Topology)(nil),
+		(*Record_Feefilter)(nil),
+		(*Record_Getcfilters)(nil),
+		(*Record_Cfilter)(nil),
+		(*Record_Cfheaders)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_record_proto_rawDesc), len(file_record_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   30,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_record_proto_goTypes,
+		DependencyIndexes: file_record_proto_depIdxs,
+		MessageInfos:      file_record_proto_msgTypes,
+	}.Build()
+	File_record_proto = out.File
+	file_record_proto_goTypes = nil
+	file_record_proto_depIdxs = nil
+}
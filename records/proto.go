@@ -0,0 +1,126 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package records
+
+import (
+	"errors"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/CIRCL/pbtc/records/pb"
+)
+
+// This file holds the helpers shared by the Proto methods of the records. The
+// messages are described by pb/record.proto and encoded through the bindings
+// generated from it.
+
+// ProtoDelimited prefixes an encoded message with its length as a varint, so
+// that a stream of messages can be split again by the reader.
+func ProtoDelimited(msg []byte) []byte {
+	buf := make([]byte, 0, len(msg)+10)
+	buf = protowire.AppendVarint(buf, uint64(len(msg)))
+	buf = append(buf, msg...)
+
+	return buf
+}
+
+// DecodeProto decodes a single record, as encoded by the Proto method.
+func DecodeProto(buf []byte) (*pb.Record, error) {
+	msg := &pb.Record{}
+	err := proto.Unmarshal(buf, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// DecodeProtoDelimited decodes the first record of a stream written with
+// ProtoDelimited. It returns the number of bytes consumed, so the caller can
+// continue with the next record.
+func DecodeProtoDelimited(buf []byte) (*pb.Record, int, error) {
+	size, n := protowire.ConsumeVarint(buf)
+	if n < 0 {
+		return nil, 0, protowire.ParseError(n)
+	}
+
+	if uint64(len(buf)-n) < size {
+		return nil, 0, errors.New("truncated protobuf message")
+	}
+
+	msg, err := DecodeProto(buf[n : n+int(size)])
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return msg, n + int(size), nil
+}
+
+// protoMessager is implemented by all records with a protobuf encoding. It is
+// used by the wrapping records to annotate the message of the inner record.
+type protoMessager interface {
+	protoMessage() *pb.Record
+}
+
+// marshal encodes the given record message.
+func marshal(msg *pb.Record) ([]byte, error) {
+	return proto.Marshal(msg)
+}
+
+// proto returns a message with the common fields of all records set. The
+// caller sets the body of the record.
+func (r *Record) proto() *pb.Record {
+	msg := &pb.Record{Command: r.cmd}
+	if !r.stamp.IsZero() {
+		msg.Timestamp = r.stamp.UnixNano()
+	}
+
+	if r.ra != nil {
+		msg.Remote = r.ra.String()
+	}
+
+	if r.la != nil {
+		msg.Local = r.la.String()
+	}
+
+	return msg
+}
+
+// protoEmpty returns a message for records that carry nothing but the common
+// fields.
+func (r *Record) protoEmpty() *pb.Record {
+	msg := r.proto()
+	msg.Body = &pb.Record_Empty{Empty: &pb.Empty{}}
+
+	return msg
+}
+
+// protoHashes copies a list of hashes into their byte slices.
+func protoHashes(hashes [][32]byte) [][]byte {
+	bufs := make([][]byte, 0, len(hashes))
+	for _, hash := range hashes {
+		hash := hash
+		bufs = append(bufs, hash[:])
+	}
+
+	return bufs
+}
@@ -0,0 +1,537 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package records
+
+import (
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/CIRCL/pbtc/adaptor"
+	"github.com/CIRCL/pbtc/records/pb"
+)
+
+var (
+	testRemote = &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 8333}
+	testLocal  = &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 50000}
+	testStamp  = time.Unix(1443657600, 5)
+	testHash   = wire.ShaHash{1, 2, 3, 4, 5, 6, 7, 8}
+	testStop   = wire.ShaHash{8, 7, 6, 5, 4, 3, 2, 1}
+)
+
+// testScript pays to the address 1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH.
+var testScript, _ = hex.DecodeString(
+	"76a914751e76e8199196d454941c45d1b3a323f1433bd688ac")
+
+func testHeader() wire.BlockHeader {
+	return wire.BlockHeader{
+		Version:    3,
+		PrevBlock:  testHash,
+		MerkleRoot: testStop,
+		Timestamp:  time.Unix(1443657000, 0),
+		Bits:       0x181b8330,
+		Nonce:      12345,
+	}
+}
+
+func testDetailsTx() *wire.MsgTx {
+	return &wire.MsgTx{
+		Version: 1,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{Hash: testHash, Index: 2},
+			Sequence:         0xffffffff,
+		}},
+		TxOut: []*wire.TxOut{{Value: 5000, PkScript: testScript}},
+	}
+}
+
+func protoHeader(hdr wire.BlockHeader) *pb.Header {
+	hash := hdr.BlockSha()
+
+	return &pb.Header{
+		Hash:       hash[:],
+		Version:    hdr.Version,
+		PrevBlock:  testHash[:],
+		MerkleRoot: testStop[:],
+		Timestamp:  hdr.Timestamp.UnixNano(),
+		Bits:       hdr.Bits,
+		Nonce:      hdr.Nonce,
+	}
+}
+
+func protoDetails(tx *wire.MsgTx) *pb.Details {
+	hash := tx.TxSha()
+
+	return &pb.Details{
+		Hash: hash[:],
+		Inputs: []*pb.Input{{
+			Hash:     testHash[:],
+			Index:    2,
+			Sequence: 0xffffffff,
+		}},
+		Outputs: []*pb.Output{{
+			Value:     5000,
+			Class:     "pubkeyhash",
+			Sigs:      1,
+			Addresses: []string{"1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH"},
+		}},
+	}
+}
+
+func TestProtoRoundTrip(t *testing.T) {
+	hdr := testHeader()
+	tx := testDetailsTx()
+	filter := []byte{0x13, 0x37, 0x00, 0xfe, 0x5a, 0xa5, 0x01, 0x80, 0x7f}
+	segwit := NewTransactionRecord(tx, testRemote, testLocal, testStamp)
+	segwit.SetWitness(testStop, []uint32{107})
+
+	tests := []struct {
+		record adaptor.Record
+		want   *pb.Record
+	}{{
+		NewVersionRecord(&wire.MsgVersion{
+			ProtocolVersion: 70002,
+			Services:        wire.SFNodeNetwork,
+			Timestamp:       time.Unix(1443657600, 0),
+			AddrYou:         *wire.NewNetAddressIPPort(testLocal.IP, 50000, 0),
+			AddrMe:          *wire.NewNetAddressIPPort(testRemote.IP, 8333, 1),
+			Nonce:           42,
+			UserAgent:       "/Satoshi:0.11.0/",
+			LastBlock:       375000,
+		}, testRemote, testLocal, testStamp),
+		&pb.Record{
+			Command: "version",
+			Body: &pb.Record_Version{Version: &pb.Version{
+				Version:  70002,
+				Services: 1,
+				Sent:     time.Unix(1443657600, 0).UnixNano(),
+				Remote:   testLocal.String(),
+				Local:    testRemote.String(),
+				Agent:    "/Satoshi:0.11.0/",
+				Block:    375000,
+				Relay:    true,
+				Nonce:    42,
+			}},
+		},
+	}, {
+		NewVerAckRecord(&wire.MsgVerAck{}, testRemote, testLocal, testStamp),
+		&pb.Record{
+			Command: "verack",
+			Body:    &pb.Record_Empty{Empty: &pb.Empty{}},
+		},
+	}, {
+		NewGetAddrRecord(&wire.MsgGetAddr{}, testRemote, testLocal, testStamp),
+		&pb.Record{
+			Command: "getaddr",
+			Body:    &pb.Record_Empty{Empty: &pb.Empty{}},
+		},
+	}, {
+		NewMemPoolRecord(&wire.MsgMemPool{}, testRemote, testLocal, testStamp),
+		&pb.Record{
+			Command: "mempool",
+			Body:    &pb.Record_Empty{Empty: &pb.Empty{}},
+		},
+	}, {
+		NewFilterAddRecord(&wire.MsgFilterAdd{}, testRemote, testLocal,
+			testStamp),
+		&pb.Record{
+			Command: "filteradd",
+			Body:    &pb.Record_Empty{Empty: &pb.Empty{}},
+		},
+	}, {
+		NewFilterClearRecord(&wire.MsgFilterClear{}, testRemote, testLocal,
+			testStamp),
+		&pb.Record{
+			Command: "filterclear",
+			Body:    &pb.Record_Empty{Empty: &pb.Empty{}},
+		},
+	}, {
+		NewFilterLoadRecord(&wire.MsgFilterLoad{}, testRemote, testLocal,
+			testStamp),
+		&pb.Record{
+			Command: "filterload",
+			Body:    &pb.Record_Empty{Empty: &pb.Empty{}},
+		},
+	}, {
+		NewMerkleBlockRecord(&wire.MsgMerkleBlock{Header: hdr}, testRemote,
+			testLocal, testStamp),
+		&pb.Record{
+			Command: "merkleblock",
+			Body:    &pb.Record_Empty{Empty: &pb.Empty{}},
+		},
+	}, {
+		NewAddressRecord(&wire.MsgAddr{AddrList: []*wire.NetAddress{{
+			Timestamp: time.Unix(1443650000, 0),
+			Services:  wire.SFNodeNetwork,
+			IP:        net.ParseIP("198.51.100.7"),
+			Port:      8333,
+		}}}, testRemote, testLocal, testStamp),
+		&pb.Record{
+			Command: "addr",
+			Body: &pb.Record_Addr{Addr: &pb.Addr{Entries: []*pb.Entry{{
+				Advertised: time.Unix(1443650000, 0).UnixNano(),
+				Services:   1,
+				Address:    "198.51.100.7:8333",
+			}}}},
+		},
+	}, {
+		NewInventoryRecord(&wire.MsgInv{InvList: []*wire.InvVect{
+			wire.NewInvVect(wire.InvTypeTx, &testHash),
+		}}, testRemote, testLocal, testStamp),
+		&pb.Record{
+			Command: "inv",
+			Body: &pb.Record_Inv{Inv: &pb.Inventory{Items: []*pb.Item{{
+				Type: uint32(wire.InvTypeTx),
+				Hash: testHash[:],
+			}}}},
+		},
+	}, {
+		NewGetDataRecord(&wire.MsgGetData{InvList: []*wire.InvVect{
+			wire.NewInvVect(wire.InvTypeBlock, &testHash),
+		}}, testRemote, testLocal, testStamp),
+		&pb.Record{
+			Command: "getdata",
+			Body: &pb.Record_Getdata{Getdata: &pb.Inventory{Items: []*pb.Item{{
+				Type: uint32(wire.InvTypeBlock),
+				Hash: testHash[:],
+			}}}},
+		},
+	}, {
+		NewNotFoundRecord(&wire.MsgNotFound{InvList: []*wire.InvVect{
+			wire.NewInvVect(wire.InvTypeTx, &testStop),
+		}}, testRemote, testLocal, testStamp),
+		&pb.Record{
+			Command: "notfound",
+			Body: &pb.Record_Notfound{Notfound: &pb.Inventory{Items: []*pb.Item{{
+				Type: uint32(wire.InvTypeTx),
+				Hash: testStop[:],
+			}}}},
+		},
+	}, {
+		NewGetBlocksRecord(&wire.MsgGetBlocks{
+			ProtocolVersion:    70002,
+			BlockLocatorHashes: []*wire.ShaHash{&testHash},
+			HashStop:           testStop,
+		}, testRemote, testLocal, testStamp),
+		&pb.Record{
+			Command: "getblocks",
+			Body: &pb.Record_Getblocks{Getblocks: &pb.Locator{
+				Version: 70002,
+				Hashes:  [][]byte{testHash[:]},
+				Stop:    testStop[:],
+			}},
+		},
+	}, {
+		NewGetHeadersRecord(&wire.MsgGetHeaders{
+			ProtocolVersion:    70002,
+			BlockLocatorHashes: []*wire.ShaHash{&testHash, &testStop},
+			HashStop:           testStop,
+		}, testRemote, testLocal, testStamp),
+		&pb.Record{
+			Command: "getheaders",
+			Body: &pb.Record_Getheaders{Getheaders: &pb.Locator{
+				Version: 70002,
+				Hashes:  [][]byte{testHash[:], testStop[:]},
+				Stop:    testStop[:],
+			}},
+		},
+	}, {
+		NewHeadersRecord(&wire.MsgHeaders{Headers: []*wire.BlockHeader{&hdr}},
+			testRemote, testLocal, testStamp),
+		&pb.Record{
+			Command: "headers",
+			Body: &pb.Record_Headers{Headers: &pb.Headers{
+				Headers: []*pb.Header{protoHeader(hdr)},
+			}},
+		},
+	}, {
+		NewBlockRecord(&wire.MsgBlock{
+			Header:       hdr,
+			Transactions: []*wire.MsgTx{tx},
+		}, testRemote, testLocal, testStamp),
+		&pb.Record{
+			Command: "block",
+			Body: &pb.Record_Block{Block: &pb.Block{
+				Header:       protoHeader(hdr),
+				Transactions: []*pb.Details{protoDetails(tx)},
+			}},
+		},
+	}, {
+		NewTransactionRecord(tx, testRemote, testLocal, testStamp),
+		&pb.Record{
+			Command: "tx",
+			Body:    &pb.Record_Tx{Tx: &pb.Transaction{Details: protoDetails(tx)}},
+		},
+	}, {
+		segwit,
+		&pb.Record{
+			Command: "tx",
+			Body: &pb.Record_Tx{Tx: &pb.Transaction{
+				Details: protoDetails(tx),
+				Segwit:  true,
+				Wtxid:   testStop[:],
+				Witness: []uint32{107},
+			}},
+		},
+	}, {
+		NewPingRecord(&wire.MsgPing{Nonce: 7}, testRemote, testLocal,
+			testStamp),
+		&pb.Record{
+			Command: "ping",
+			Body:    &pb.Record_Ping{Ping: &pb.Nonce{Nonce: 7}},
+		},
+	}, {
+		NewPongRecord(&wire.MsgPong{Nonce: 7}, testRemote, testLocal,
+			testStamp),
+		&pb.Record{
+			Command: "pong",
+			Body:    &pb.Record_Pong{Pong: &pb.Nonce{Nonce: 7}},
+		},
+	}, {
+		NewRejectRecord(&wire.MsgReject{
+			Cmd:    "tx",
+			Code:   wire.RejectDuplicate,
+			Reason: "txn-already-known",
+			Hash:   testHash,
+		}, testRemote, testLocal, testStamp),
+		&pb.Record{
+			Command: "reject",
+			Body: &pb.Record_Reject{Reject: &pb.Reject{
+				Code:    uint32(wire.RejectDuplicate),
+				Command: "tx",
+				Hash:    testHash[:],
+				Reason:  "txn-already-known",
+			}},
+		},
+	}, {
+		NewAlertRecord(&wire.MsgAlert{Payload: &wire.Alert{
+			Version:    1,
+			RelayUntil: 1443657600,
+			Expiration: 1443744000,
+			ID:         1010,
+			Cancel:     1009,
+			SetCancel:  []int32{1001, 1002},
+			MinVer:     10000,
+			MaxVer:     70002,
+			SetSubVer:  []string{"/Satoshi:0.9.0/"},
+			Priority:   5000,
+			Comment:    "comment",
+			StatusBar:  "URGENT: upgrade required",
+			Reserved:   "reserved",
+		}}, testRemote, testLocal, testStamp),
+		&pb.Record{
+			Command: "alert",
+			Body: &pb.Record_Alert{Alert: &pb.Alert{
+				Version:    1,
+				RelayUntil: 1443657600,
+				Expiration: 1443744000,
+				Id:         1010,
+				Cancel:     1009,
+				MinVer:     10000,
+				MaxVer:     70002,
+				Priority:   5000,
+				SetCancel:  []int32{1001, 1002},
+				SetSubVer:  []string{"/Satoshi:0.9.0/"},
+				Comment:    "comment",
+				StatusBar:  "URGENT: upgrade required",
+				Reserved:   "reserved",
+			}},
+		},
+	}, {
+		NewFeeFilterRecord(1000, testRemote, testLocal, testStamp),
+		&pb.Record{
+			Command: CmdFeeFilter,
+			Body:    &pb.Record_Feefilter{Feefilter: &pb.FeeFilter{Fee: 1000}},
+		},
+	}, {
+		NewRawRecord("sendheaders", []byte{0xde, 0xad}, testRemote,
+			testLocal, testStamp),
+		&pb.Record{
+			Command: "sendheaders",
+			Body:    &pb.Record_Raw{Raw: &pb.Raw{Payload: []byte{0xde, 0xad}}},
+		},
+	}, {
+		NewDisconnectRecord(ReasonIdle, "no message for 5m0s", testRemote,
+			testLocal, testStamp),
+		&pb.Record{
+			Command: CmdDisconnect,
+			Body: &pb.Record_Disconnect{Disconnect: &pb.Disconnect{
+				Reason: ReasonIdle,
+				Detail: "no message for 5m0s",
+			}},
+		},
+	}, {
+		NewGetCFiltersRecord(0, 375000, testStop, testRemote, testLocal,
+			testStamp),
+		&pb.Record{
+			Command: CmdGetCFilters,
+			Body: &pb.Record_Getcfilters{Getcfilters: &pb.FilterRange{
+				StartHeight: 375000,
+				StopHash:    testStop[:],
+			}},
+		},
+	}, {
+		NewCFilterRecord(0, testHash, filter, testRemote, testLocal,
+			testStamp),
+		&pb.Record{
+			Command: CmdCFilter,
+			Body: &pb.Record_Cfilter{Cfilter: &pb.CompactFilter{
+				BlockHash: testHash[:],
+				Filter:    filter,
+			}},
+		},
+	}, {
+		NewCFHeadersRecord(0, testStop, testHash,
+			[][32]byte{testHash, testStop}, testRemote, testLocal, testStamp),
+		&pb.Record{
+			Command: CmdCFHeaders,
+			Body: &pb.Record_Cfheaders{Cfheaders: &pb.FilterHeaders{
+				StopHash:       testStop[:],
+				PreviousHeader: testHash[:],
+				FilterHashes:   [][]byte{testHash[:], testStop[:]},
+			}},
+		},
+	}, {
+		NewSummaryRecord(testStamp.Add(-time.Minute), testStamp, 8, 4096,
+			1024, 2, map[string]uint64{"inv": 12, "tx": 3}),
+		&pb.Record{
+			Command: CmdSummary,
+			Body: &pb.Record_Summary{Summary: &pb.Summary{
+				Duration: int64(time.Minute),
+				Peers:    8,
+				Bytes:    4096,
+				Commands: map[string]uint64{"inv": 12, "tx": 3},
+				Written:  1024,
+				Bans:     2,
+			}},
+		},
+	}, {
+		NewTopologySnapshotRecord(testStamp, 2, 6,
+			map[string]uint64{"192.0": 8},
+			map[string]uint64{"/Satoshi:0.11.0/": 8}),
+		&pb.Record{
+			Command: CmdTopology,
+			Body: &pb.Record_Topology{Topology: &pb.Topology{
+				Peers:    8,
+				Inbound:  2,
+				Outbound: 6,
+				Groups:   map[string]uint64{"192.0": 8},
+				Software: map[string]uint64{"/Satoshi:0.11.0/": 8},
+			}},
+		},
+	}}
+
+	for _, test := range tests {
+		cmd := test.want.Command
+		test.want.Timestamp = test.record.Timestamp().UnixNano()
+		test.want.Remote = test.record.RemoteAddress().String()
+		test.want.Local = test.record.LocalAddress().String()
+
+		buf, err := test.record.(interface {
+			Proto() ([]byte, error)
+		}).Proto()
+		if err != nil {
+			t.Errorf("%v: could not encode (%v)", cmd, err)
+			continue
+		}
+
+		msg, err := DecodeProto(buf)
+		if err != nil {
+			t.Errorf("%v: could not decode (%v)", cmd, err)
+			continue
+		}
+
+		if !proto.Equal(msg, test.want) {
+			t.Errorf("%v: decoded %v, want %v", cmd, msg, test.want)
+		}
+	}
+}
+
+func TestProtoAnnotations(t *testing.T) {
+	ping := NewPingRecord(&wire.MsgPing{Nonce: 7}, testRemote, testLocal,
+		testStamp)
+	record := NewGeoRecord(NewTaggedRecord(ping, "a"), "LU", 6661)
+
+	buf, err := record.(*GeoRecord).Proto()
+	if err != nil {
+		t.Fatalf("could not encode (%v)", err)
+	}
+
+	msg, err := DecodeProto(buf)
+	if err != nil {
+		t.Fatalf("could not decode (%v)", err)
+	}
+
+	want := &pb.Record{
+		Timestamp: testStamp.UnixNano(),
+		Command:   "ping",
+		Remote:    testRemote.String(),
+		Local:     testLocal.String(),
+		Tag:       "a",
+		Country:   "LU",
+		Asn:       6661,
+		Body:      &pb.Record_Ping{Ping: &pb.Nonce{Nonce: 7}},
+	}
+	if !proto.Equal(msg, want) {
+		t.Errorf("decoded %v, want %v", msg, want)
+	}
+}
+
+func TestProtoDelimited(t *testing.T) {
+	var stream []byte
+	for nonce := uint64(1); nonce <= 3; nonce++ {
+		ping := NewPingRecord(&wire.MsgPing{Nonce: nonce}, testRemote,
+			testLocal, testStamp)
+		buf, err := ping.Proto()
+		if err != nil {
+			t.Fatalf("could not encode (%v)", err)
+		}
+
+		stream = append(stream, ProtoDelimited(buf)...)
+	}
+
+	for nonce := uint64(1); nonce <= 3; nonce++ {
+		msg, n, err := DecodeProtoDelimited(stream)
+		if err != nil {
+			t.Fatalf("could not decode %v (%v)", nonce, err)
+		}
+
+		if msg.GetPing().GetNonce() != nonce {
+			t.Errorf("decoded nonce %v, want %v", msg.GetPing().GetNonce(),
+				nonce)
+		}
+
+		stream = stream[n:]
+	}
+
+	if len(stream) != 0 {
+		t.Errorf("%v bytes left over", len(stream))
+	}
+
+	_, _, err := DecodeProtoDelimited([]byte{5, 1, 2})
+	if err == nil {
+		t.Errorf("decoded truncated message")
+	}
+}
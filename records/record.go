@@ -25,6 +25,8 @@ import (
 	"time"
 
 	"github.com/btcsuite/btcd/txscript"
+
+	"github.com/CIRCL/pbtc/adaptor"
 )
 
 const (
@@ -86,3 +88,43 @@ func (r *Record) LocalAddress() *net.TCPAddr {
 func (r *Record) Command() string {
 	return r.cmd
 }
+
+// Unwrap returns the record without the tags and locations that processors
+// wrapped it in, so that it can be checked for its concrete type.
+func Unwrap(record adaptor.Record) adaptor.Record {
+	for {
+		switch r := record.(type) {
+		case *TaggedRecord:
+			record = r.Record
+
+		case *GeoRecord:
+			record = r.Record
+
+		default:
+			return record
+		}
+	}
+}
+
+// annotations returns the tag and the location that processors wrapped the
+// record in, in any order. Missing annotations are empty.
+func annotations(record adaptor.Record) (string, string, uint32) {
+	tag := ""
+	country := ""
+	asn := uint32(0)
+	for {
+		switch r := record.(type) {
+		case *TaggedRecord:
+			tag = r.Tag()
+			record = r.Record
+
+		case *GeoRecord:
+			country = r.Country()
+			asn = r.ASN()
+			record = r.Record
+
+		default:
+			return tag, country, asn
+		}
+	}
+}
@@ -22,11 +22,16 @@ package records
 
 import (
 	"bytes"
+	"encoding/binary"
 	"net"
 	"strconv"
 	"time"
 
 	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
 )
 
 type AddressRecord struct {
@@ -36,10 +41,10 @@ type AddressRecord struct {
 }
 
 func NewAddressRecord(msg *wire.MsgAddr, ra *net.TCPAddr,
-	la *net.TCPAddr) *AddressRecord {
+	la *net.TCPAddr, stamp time.Time) *AddressRecord {
 	ar := &AddressRecord{
 		Record: Record{
-			stamp: time.Now(),
+			stamp: stamp,
 			ra:    ra,
 			la:    la,
 			cmd:   msg.Command(),
@@ -75,3 +80,49 @@ func (ar *AddressRecord) String() string {
 
 	return buf.String()
 }
+
+// Bytes returns the binary representation of the address entries: a 2 byte
+// entry count (little endian) followed by the 30 byte representation of each
+// entry, including the timestamp advertised for it.
+func (ar *AddressRecord) Bytes() []byte {
+	buf := make([]byte, 2, 2+len(ar.addrs)*EntrySize)
+	binary.LittleEndian.PutUint16(buf, uint16(len(ar.addrs)))
+
+	for _, addr := range ar.addrs {
+		buf = append(buf, addr.Bytes()...)
+	}
+
+	return buf
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (ar *AddressRecord) Proto() ([]byte, error) {
+	return marshal(ar.protoMessage())
+}
+
+func (ar *AddressRecord) protoMessage() *pb.Record {
+	body := &pb.Addr{}
+	for _, entry := range ar.addrs {
+		body.Entries = append(body.Entries, entry.proto())
+	}
+
+	msg := ar.proto()
+	msg.Body = &pb.Record_Addr{Addr: body}
+
+	return msg
+}
+
+func (ar *AddressRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	entries := make([]flatbuffers.UOffsetT, 0, len(ar.addrs))
+	for _, entry := range ar.addrs {
+		entries = append(entries, entry.flat(b))
+	}
+
+	vec := flatTables(b, entries)
+	fb.AddrStart(b)
+	fb.AddrAddEntries(b, vec)
+
+	return fb.BodyAddr, fb.AddrEnd(b)
+}
@@ -28,6 +28,10 @@ import (
 	"time"
 
 	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
 )
 
 type AlertRecord struct {
@@ -49,10 +53,10 @@ type AlertRecord struct {
 }
 
 func NewAlertRecord(msg *wire.MsgAlert, ra *net.TCPAddr,
-	la *net.TCPAddr) *AlertRecord {
+	la *net.TCPAddr, stamp time.Time) *AlertRecord {
 	record := &AlertRecord{
 		Record: Record{
-			stamp: time.Now(),
+			stamp: stamp,
 			ra:    ra,
 			la:    la,
 			cmd:   msg.Command(),
@@ -136,3 +140,92 @@ func (ar *AlertRecord) String() string {
 
 	return buf.String()
 }
+
+// Bytes returns the binary representation of the alert, with its numbers in
+// order of the alert message, followed by the cancel set, the sub-version set
+// and the strings.
+func (ar *AlertRecord) Bytes() []byte {
+	buf := putUint32(nil, uint32(ar.version))
+	buf = putUint64(buf, uint64(ar.relayUntil))
+	buf = putUint64(buf, uint64(ar.expiration))
+	buf = putUint32(buf, uint32(ar.id))
+	buf = putUint32(buf, uint32(ar.cancel))
+	buf = putUint32(buf, uint32(ar.minVer))
+	buf = putUint32(buf, uint32(ar.maxVer))
+	buf = putUint32(buf, uint32(ar.priority))
+	buf = putUint16(buf, uint16(len(ar.setCancel)))
+	for _, cancel := range ar.setCancel {
+		buf = putUint32(buf, uint32(cancel))
+	}
+
+	buf = putUint16(buf, uint16(len(ar.setSubVer)))
+	for _, subVer := range ar.setSubVer {
+		buf = putString(buf, subVer)
+	}
+
+	buf = putString(buf, ar.comment)
+	buf = putString(buf, ar.statusBar)
+	buf = putString(buf, ar.reserved)
+
+	return buf
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (ar *AlertRecord) Proto() ([]byte, error) {
+	return marshal(ar.protoMessage())
+}
+
+func (ar *AlertRecord) protoMessage() *pb.Record {
+	body := &pb.Alert{
+		Version:    ar.version,
+		RelayUntil: ar.relayUntil,
+		Expiration: ar.expiration,
+		Id:         ar.id,
+		Cancel:     ar.cancel,
+		MinVer:     ar.minVer,
+		MaxVer:     ar.maxVer,
+		Priority:   ar.priority,
+		SetCancel:  ar.setCancel,
+		SetSubVer:  ar.setSubVer,
+		Comment:    ar.comment,
+		StatusBar:  ar.statusBar,
+		Reserved:   ar.reserved,
+	}
+
+	msg := ar.proto()
+	msg.Body = &pb.Record_Alert{Alert: body}
+
+	return msg
+}
+
+func (ar *AlertRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	b.StartVector(4, len(ar.setCancel), 4)
+	for i := len(ar.setCancel) - 1; i >= 0; i-- {
+		b.PrependInt32(ar.setCancel[i])
+	}
+
+	setCancel := b.EndVector(len(ar.setCancel))
+	setSubVer := flatStrings(b, ar.setSubVer)
+	comment := b.CreateString(ar.comment)
+	statusBar := b.CreateString(ar.statusBar)
+	reserved := b.CreateString(ar.reserved)
+
+	fb.AlertStart(b)
+	fb.AlertAddVersion(b, ar.version)
+	fb.AlertAddRelayUntil(b, ar.relayUntil)
+	fb.AlertAddExpiration(b, ar.expiration)
+	fb.AlertAddId(b, ar.id)
+	fb.AlertAddCancel(b, ar.cancel)
+	fb.AlertAddMinVer(b, ar.minVer)
+	fb.AlertAddMaxVer(b, ar.maxVer)
+	fb.AlertAddPriority(b, ar.priority)
+	fb.AlertAddSetCancel(b, setCancel)
+	fb.AlertAddSetSubVer(b, setSubVer)
+	fb.AlertAddComment(b, comment)
+	fb.AlertAddStatusBar(b, statusBar)
+	fb.AlertAddReserved(b, reserved)
+
+	return fb.BodyAlert, fb.AlertEnd(b)
+}
@@ -27,6 +27,10 @@ import (
 	"time"
 
 	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
 )
 
 type BlockRecord struct {
@@ -37,10 +41,10 @@ type BlockRecord struct {
 }
 
 func NewBlockRecord(msg *wire.MsgBlock, ra *net.TCPAddr,
-	la *net.TCPAddr) *BlockRecord {
+	la *net.TCPAddr, stamp time.Time) *BlockRecord {
 	record := &BlockRecord{
 		Record: Record{
-			stamp: time.Now(),
+			stamp: stamp,
 			ra:    ra,
 			la:    la,
 			cmd:   msg.Command(),
@@ -80,3 +84,49 @@ func (br *BlockRecord) String() string {
 
 	return buf.String()
 }
+
+// Bytes returns the binary representation of the block: its header followed
+// by the transaction count and the details of each transaction.
+func (br *BlockRecord) Bytes() []byte {
+	buf := br.hdr.Bytes()
+	buf = putUint16(buf, uint16(len(br.details)))
+	for _, details := range br.details {
+		buf = append(buf, details.Bytes()...)
+	}
+
+	return buf
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (br *BlockRecord) Proto() ([]byte, error) {
+	return marshal(br.protoMessage())
+}
+
+func (br *BlockRecord) protoMessage() *pb.Record {
+	body := &pb.Block{Header: br.hdr.proto()}
+	for _, details := range br.details {
+		body.Transactions = append(body.Transactions, details.proto())
+	}
+
+	msg := br.proto()
+	msg.Body = &pb.Record_Block{Block: body}
+
+	return msg
+}
+
+func (br *BlockRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	hdr := br.hdr.flat(b)
+	txs := make([]flatbuffers.UOffsetT, 0, len(br.details))
+	for _, details := range br.details {
+		txs = append(txs, details.flat(b))
+	}
+
+	vec := flatTables(b, txs)
+	fb.BlockStart(b)
+	fb.BlockAddHeader(b, hdr)
+	fb.BlockAddTransactions(b, vec)
+
+	return fb.BodyBlock, fb.BlockEnd(b)
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package records
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"strconv"
+	"time"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
+)
+
+// CmdCFHeaders is the command of the message carrying the filter hashes of a
+// range of blocks, as defined by BIP 157.
+const CmdCFHeaders = "cfheaders"
+
+// CFHeadersRecord describes a cfheaders message. The range ends at the block
+// with the stop hash; the previous header commits to all filters before it.
+type CFHeadersRecord struct {
+	Record
+
+	filter uint8
+	stop   [32]byte
+	prev   [32]byte
+	hashes [][32]byte
+}
+
+func NewCFHeadersRecord(filter uint8, stop [32]byte, prev [32]byte,
+	hashes [][32]byte, ra *net.TCPAddr, la *net.TCPAddr,
+	stamp time.Time) *CFHeadersRecord {
+	record := &CFHeadersRecord{
+		Record: Record{
+			stamp: stamp,
+			ra:    ra,
+			la:    la,
+			cmd:   CmdCFHeaders,
+		},
+
+		filter: filter,
+		stop:   stop,
+		prev:   prev,
+		hashes: hashes,
+	}
+
+	return record
+}
+
+// FilterType returns the type of the filters.
+func (cr *CFHeadersRecord) FilterType() uint8 {
+	return cr.filter
+}
+
+// StopHash returns the hash of the last block of the range.
+func (cr *CFHeadersRecord) StopHash() [32]byte {
+	return cr.stop
+}
+
+// PreviousHeader returns the filter header of the block before the range.
+func (cr *CFHeadersRecord) PreviousHeader() [32]byte {
+	return cr.prev
+}
+
+// FilterHashes returns the hashes of the filters of the range, in order.
+func (cr *CFHeadersRecord) FilterHashes() [][32]byte {
+	return cr.hashes
+}
+
+func (cr *CFHeadersRecord) String() string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(cr.stamp.Format(time.RFC3339Nano))
+	buf.WriteString(Delimiter1)
+	buf.WriteString(cr.cmd)
+	buf.WriteString(Delimiter1)
+	buf.WriteString(cr.ra.String())
+	buf.WriteString(Delimiter1)
+	buf.WriteString(cr.la.String())
+	buf.WriteString(Delimiter1)
+	buf.WriteString(strconv.FormatUint(uint64(cr.filter), 10))
+	buf.WriteString(Delimiter1)
+	buf.WriteString(hex.EncodeToString(cr.stop[:]))
+	buf.WriteString(Delimiter1)
+	buf.WriteString(hex.EncodeToString(cr.prev[:]))
+	buf.WriteString(Delimiter1)
+	buf.WriteString(strconv.FormatInt(int64(len(cr.hashes)), 10))
+
+	for _, hash := range cr.hashes {
+		buf.WriteString(Delimiter2)
+		buf.WriteString(hex.EncodeToString(hash[:]))
+	}
+
+	return buf.String()
+}
+
+// Bytes returns the binary representation of the headers: 1 byte filter type,
+// 32 bytes stop hash, 32 bytes previous filter header, 2 bytes hash count
+// (little endian) and 32 bytes for each filter hash. The count fits easily,
+// as a message never has more than 2000 hashes.
+func (cr *CFHeadersRecord) Bytes() []byte {
+	buf := make([]byte, 0, 67+len(cr.hashes)*32)
+	buf = append(buf, cr.filter)
+	buf = append(buf, cr.stop[:]...)
+	buf = append(buf, cr.prev[:]...)
+	buf = putUint16(buf, uint16(len(cr.hashes)))
+	for _, hash := range cr.hashes {
+		buf = append(buf, hash[:]...)
+	}
+
+	return buf
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (cr *CFHeadersRecord) Proto() ([]byte, error) {
+	return marshal(cr.protoMessage())
+}
+
+func (cr *CFHeadersRecord) protoMessage() *pb.Record {
+	body := &pb.FilterHeaders{
+		FilterType:     uint32(cr.filter),
+		StopHash:       cr.stop[:],
+		PreviousHeader: cr.prev[:],
+		FilterHashes:   protoHashes(cr.hashes),
+	}
+
+	msg := cr.proto()
+	msg.Body = &pb.Record_Cfheaders{Cfheaders: body}
+
+	return msg
+}
+
+func (cr *CFHeadersRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	stop := b.CreateByteVector(cr.stop[:])
+	prev := b.CreateByteVector(cr.prev[:])
+	hashes := flatHashes(b, cr.hashes)
+
+	fb.CFHeadersStart(b)
+	fb.CFHeadersAddFilterType(b, uint32(cr.filter))
+	fb.CFHeadersAddStopHash(b, stop)
+	fb.CFHeadersAddPreviousHeader(b, prev)
+	fb.CFHeadersAddFilterHashes(b, hashes)
+
+	return fb.BodyCFHeaders, fb.CFHeadersEnd(b)
+}
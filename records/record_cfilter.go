@@ -0,0 +1,146 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package records
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"strconv"
+	"time"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
+)
+
+// CmdCFilter is the command of the message carrying the compact filter of a
+// block, as defined by BIP 157.
+const CmdCFilter = "cfilter"
+
+// CFilterRecord describes a cfilter message. The filter is kept as received;
+// for the basic filter type, it is the Golomb-coded set of BIP 158.
+type CFilterRecord struct {
+	Record
+
+	filter uint8
+	hash   [32]byte
+	data   []byte
+}
+
+func NewCFilterRecord(filter uint8, hash [32]byte, data []byte,
+	ra *net.TCPAddr, la *net.TCPAddr, stamp time.Time) *CFilterRecord {
+	record := &CFilterRecord{
+		Record: Record{
+			stamp: stamp,
+			ra:    ra,
+			la:    la,
+			cmd:   CmdCFilter,
+		},
+
+		filter: filter,
+		hash:   hash,
+		data:   data,
+	}
+
+	return record
+}
+
+// FilterType returns the type of the filter.
+func (cr *CFilterRecord) FilterType() uint8 {
+	return cr.filter
+}
+
+// BlockHash returns the hash of the block the filter was built from.
+func (cr *CFilterRecord) BlockHash() [32]byte {
+	return cr.hash
+}
+
+// Filter returns the serialized filter.
+func (cr *CFilterRecord) Filter() []byte {
+	return cr.data
+}
+
+func (cr *CFilterRecord) String() string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(cr.stamp.Format(time.RFC3339Nano))
+	buf.WriteString(Delimiter1)
+	buf.WriteString(cr.cmd)
+	buf.WriteString(Delimiter1)
+	buf.WriteString(cr.ra.String())
+	buf.WriteString(Delimiter1)
+	buf.WriteString(cr.la.String())
+	buf.WriteString(Delimiter1)
+	buf.WriteString(strconv.FormatUint(uint64(cr.filter), 10))
+	buf.WriteString(Delimiter1)
+	buf.WriteString(hex.EncodeToString(cr.hash[:]))
+	buf.WriteString(Delimiter1)
+	buf.WriteString(hex.EncodeToString(cr.data))
+
+	return buf.String()
+}
+
+// Bytes returns the binary representation of the filter: 1 byte filter type,
+// 32 bytes block hash, 4 bytes filter size (little endian) and the filter
+// itself. The size does not fit two bytes like other variable fields, as
+// filters of large blocks can exceed 64 kilobytes.
+func (cr *CFilterRecord) Bytes() []byte {
+	buf := make([]byte, 0, 37+len(cr.data))
+	buf = append(buf, cr.filter)
+	buf = append(buf, cr.hash[:]...)
+	buf = putUint32(buf, uint32(len(cr.data)))
+	buf = append(buf, cr.data...)
+
+	return buf
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (cr *CFilterRecord) Proto() ([]byte, error) {
+	return marshal(cr.protoMessage())
+}
+
+func (cr *CFilterRecord) protoMessage() *pb.Record {
+	body := &pb.CompactFilter{
+		FilterType: uint32(cr.filter),
+		BlockHash:  cr.hash[:],
+		Filter:     cr.data,
+	}
+
+	msg := cr.proto()
+	msg.Body = &pb.Record_Cfilter{Cfilter: body}
+
+	return msg
+}
+
+func (cr *CFilterRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	hash := b.CreateByteVector(cr.hash[:])
+	data := b.CreateByteVector(cr.data)
+
+	fb.CFilterStart(b)
+	fb.CFilterAddFilterType(b, uint32(cr.filter))
+	fb.CFilterAddBlockHash(b, hash)
+	fb.CFilterAddFilter(b, data)
+
+	return fb.BodyCFilter, fb.CFilterEnd(b)
+}
@@ -26,6 +26,10 @@ import (
 	"strconv"
 
 	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
 )
 
 type DetailsRecord struct {
@@ -73,3 +77,56 @@ func (dr *DetailsRecord) String() string {
 
 	return buf.String()
 }
+
+// Bytes returns the binary representation of the transaction details: the
+// hash, then the input count and inputs and the output count and outputs.
+func (dr *DetailsRecord) Bytes() []byte {
+	buf := append([]byte{}, dr.hash[:]...)
+	buf = putUint16(buf, uint16(len(dr.ins)))
+	for _, in := range dr.ins {
+		buf = append(buf, in.Bytes()...)
+	}
+
+	buf = putUint16(buf, uint16(len(dr.outs)))
+	for _, out := range dr.outs {
+		buf = append(buf, out.Bytes()...)
+	}
+
+	return buf
+}
+
+func (dr *DetailsRecord) proto() *pb.Details {
+	msg := &pb.Details{Hash: dr.hash[:]}
+	for _, in := range dr.ins {
+		msg.Inputs = append(msg.Inputs, in.proto())
+	}
+
+	for _, out := range dr.outs {
+		msg.Outputs = append(msg.Outputs, out.proto())
+	}
+
+	return msg
+}
+
+func (dr *DetailsRecord) flat(b *flatbuffers.Builder) flatbuffers.UOffsetT {
+	hash := b.CreateByteVector(dr.hash[:])
+	ins := make([]flatbuffers.UOffsetT, 0, len(dr.ins))
+	for _, in := range dr.ins {
+		ins = append(ins, in.flat(b))
+	}
+
+	outs := make([]flatbuffers.UOffsetT, 0, len(dr.outs))
+	for _, out := range dr.outs {
+		outs = append(outs, out.flat(b))
+	}
+
+	inVec := flatTables(b, ins)
+	outVec := flatTables(b, outs)
+
+	fb.DetailsStart(b)
+	fb.DetailsAddHash(b, hash)
+	fb.DetailsAddInputs(b, inVec)
+	fb.DetailsAddOutputs(b, outVec)
+
+	return fb.DetailsEnd(b)
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package records
+
+import (
+	"bytes"
+	"net"
+	"time"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
+)
+
+// CmdDisconnect is the command of the disconnect record, which has no
+// corresponding Bitcoin message.
+const CmdDisconnect = "disconnect"
+
+// The reasons for a peer closing the connection on us.
+const (
+	ReasonEOF     = "EOF"
+	ReasonReset   = "RESET"
+	ReasonReject  = "REJECT"
+	ReasonTimeout = "TIMEOUT"
+	ReasonError   = "ERROR"
+)
+
+// The reasons for us closing the connection on a peer that does not meet our
+// requirements.
+const (
+	ReasonOutdated = "OUTDATED"
+)
+
+// The reasons for the manager closing the connection on a peer: it reached the
+// maximum age or its lifetime, it was idle for too long, or it was dropped on
+// request.
+const (
+	ReasonExpired  = "EXPIRED"
+	ReasonLifetime = "LIFETIME"
+	ReasonIdle     = "IDLE"
+	ReasonDropped  = "DROPPED"
+)
+
+// Banned returns whether the reason is one for which we banned the peer, by
+// closing the connection because it did not meet our requirements, because it
+// was idle or on request. Rotating peers that reached their maximum age or
+// lifetime is not a ban.
+func Banned(reason string) bool {
+	switch reason {
+	case ReasonOutdated, ReasonIdle, ReasonDropped:
+		return true
+
+	default:
+		return false
+	}
+}
+
+// DisconnectRecord describes a connection that was closed by the peer rather
+// than by us. The detail holds the error we got or, if the peer sent a reject
+// message before closing the connection, the reason it gave. It also describes
+// connections we closed because the peer did not meet our requirements, or
+// because the manager let it go.
+type DisconnectRecord struct {
+	Record
+
+	reason string
+	detail string
+}
+
+func NewDisconnectRecord(reason string, detail string, ra *net.TCPAddr,
+	la *net.TCPAddr, stamp time.Time) *DisconnectRecord {
+	record := &DisconnectRecord{
+		Record: Record{
+			stamp: stamp,
+			ra:    ra,
+			la:    la,
+			cmd:   CmdDisconnect,
+		},
+
+		reason: reason,
+		detail: detail,
+	}
+
+	return record
+}
+
+func (dr *DisconnectRecord) String() string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(dr.stamp.Format(time.RFC3339Nano))
+	buf.WriteString(Delimiter1)
+	buf.WriteString(dr.cmd)
+	buf.WriteString(Delimiter1)
+	buf.WriteString(dr.ra.String())
+	buf.WriteString(Delimiter1)
+	buf.WriteString(dr.la.String())
+	buf.WriteString(Delimiter1)
+	buf.WriteString(dr.reason)
+	buf.WriteString(Delimiter1)
+	buf.WriteString(dr.detail)
+
+	return buf.String()
+}
+
+// Reason returns the reason of the disconnect.
+func (dr *DisconnectRecord) Reason() string {
+	return dr.reason
+}
+
+// Bytes returns the binary representation of the reason and the detail.
+func (dr *DisconnectRecord) Bytes() []byte {
+	buf := putString(nil, dr.reason)
+	buf = putString(buf, dr.detail)
+
+	return buf
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (dr *DisconnectRecord) Proto() ([]byte, error) {
+	return marshal(dr.protoMessage())
+}
+
+func (dr *DisconnectRecord) protoMessage() *pb.Record {
+	body := &pb.Disconnect{
+		Reason: dr.reason,
+		Detail: dr.detail,
+	}
+
+	msg := dr.proto()
+	msg.Body = &pb.Record_Disconnect{Disconnect: body}
+
+	return msg
+}
+
+func (dr *DisconnectRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	reason := b.CreateString(dr.reason)
+	detail := b.CreateString(dr.detail)
+
+	fb.DisconnectStart(b)
+	fb.DisconnectAddReason(b, reason)
+	fb.DisconnectAddDetail(b, detail)
+
+	return fb.BodyDisconnect, fb.DisconnectEnd(b)
+}
@@ -22,35 +22,51 @@ package records
 
 import (
 	"bytes"
+	"encoding/binary"
 	"net"
 	"strconv"
 	"time"
 
 	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
 
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
 	"github.com/CIRCL/pbtc/util"
 )
 
+// EntrySize is the size of the binary representation of an entry record.
+const EntrySize = 30
+
+// EntryRecord is one address entry of an addr message. Its timestamp is the
+// one advertised by the remote peer, telling us when it last heard of the
+// node, and is unrelated to the time we received the message.
 type EntryRecord struct {
-	addr     *net.TCPAddr
-	stamp    time.Time
-	services uint64
+	addr       *net.TCPAddr
+	advertised time.Time
+	services   uint64
 }
 
 func NewEntryRecord(na *wire.NetAddress) *EntryRecord {
 	record := &EntryRecord{
-		addr:     util.ParseNetAddress(na),
-		stamp:    na.Timestamp,
-		services: uint64(na.Services),
+		addr:       util.ParseNetAddress(na),
+		advertised: na.Timestamp,
+		services:   uint64(na.Services),
 	}
 
 	return record
 }
 
+// Advertised returns the timestamp advertised by the remote peer for this
+// address.
+func (er *EntryRecord) Advertised() time.Time {
+	return er.advertised
+}
+
 func (er *EntryRecord) String() string {
 	buf := new(bytes.Buffer)
 
-	buf.WriteString(strconv.FormatInt(er.stamp.Unix(), 10))
+	buf.WriteString(strconv.FormatInt(er.advertised.Unix(), 10))
 	buf.WriteString(Delimiter3)
 	buf.WriteString(strconv.FormatUint(er.services, 10))
 	buf.WriteString(Delimiter3)
@@ -58,3 +74,46 @@ func (er *EntryRecord) String() string {
 
 	return buf.String()
 }
+
+// Bytes returns the binary representation of the entry, which follows the
+// layout of the network address in the Bitcoin protocol and is 30 bytes long:
+// 4 bytes advertised timestamp (unix seconds, little endian), 8 bytes services
+// (little endian), 16 bytes IPv6 or IPv4-mapped address and 2 bytes port (big
+// endian).
+func (er *EntryRecord) Bytes() []byte {
+	buf := make([]byte, EntrySize)
+
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(er.advertised.Unix()))
+	binary.LittleEndian.PutUint64(buf[4:12], er.services)
+	copy(buf[12:28], er.addr.IP.To16())
+	binary.BigEndian.PutUint16(buf[28:30], uint16(er.addr.Port))
+
+	return buf
+}
+
+func (er *EntryRecord) proto() *pb.Entry {
+	msg := &pb.Entry{
+		Services: er.services,
+		Address:  er.addr.String(),
+	}
+
+	if !er.advertised.IsZero() {
+		msg.Advertised = er.advertised.UnixNano()
+	}
+
+	return msg
+}
+
+func (er *EntryRecord) flat(b *flatbuffers.Builder) flatbuffers.UOffsetT {
+	addr := b.CreateString(er.addr.String())
+
+	fb.EntryStart(b)
+	if !er.advertised.IsZero() {
+		fb.EntryAddAdvertised(b, er.advertised.UnixNano())
+	}
+
+	fb.EntryAddServices(b, er.services)
+	fb.EntryAddAddress(b, addr)
+
+	return fb.EntryEnd(b)
+}
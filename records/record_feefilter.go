@@ -0,0 +1,107 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package records
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"time"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
+)
+
+// CmdFeeFilter is the command of the message announcing the minimum fee rate
+// of the transactions a peer wants relayed to it, as defined by BIP 133.
+const CmdFeeFilter = "feefilter"
+
+// FeeFilterRecord describes a feefilter message. The fee is given in satoshis
+// per kilobyte.
+type FeeFilterRecord struct {
+	Record
+
+	fee int64
+}
+
+func NewFeeFilterRecord(fee int64, ra *net.TCPAddr, la *net.TCPAddr,
+	stamp time.Time) *FeeFilterRecord {
+	record := &FeeFilterRecord{
+		Record: Record{
+			stamp: stamp,
+			ra:    ra,
+			la:    la,
+			cmd:   CmdFeeFilter,
+		},
+
+		fee: fee,
+	}
+
+	return record
+}
+
+// Fee returns the minimum fee rate announced by the peer.
+func (fr *FeeFilterRecord) Fee() int64 {
+	return fr.fee
+}
+
+func (fr *FeeFilterRecord) String() string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(fr.stamp.Format(time.RFC3339Nano))
+	buf.WriteString(Delimiter1)
+	buf.WriteString(fr.cmd)
+	buf.WriteString(Delimiter1)
+	buf.WriteString(fr.ra.String())
+	buf.WriteString(Delimiter1)
+	buf.WriteString(fr.la.String())
+	buf.WriteString(Delimiter1)
+	buf.WriteString(strconv.FormatInt(fr.fee, 10))
+
+	return buf.String()
+}
+
+// Bytes returns the binary representation of the fee.
+func (fr *FeeFilterRecord) Bytes() []byte {
+	return putUint64(nil, uint64(fr.fee))
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (fr *FeeFilterRecord) Proto() ([]byte, error) {
+	return marshal(fr.protoMessage())
+}
+
+func (fr *FeeFilterRecord) protoMessage() *pb.Record {
+	msg := fr.proto()
+	msg.Body = &pb.Record_Feefilter{Feefilter: &pb.FeeFilter{Fee: fr.fee}}
+
+	return msg
+}
+
+func (fr *FeeFilterRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	fb.FeeFilterStart(b)
+	fb.FeeFilterAddFee(b, fr.fee)
+
+	return fb.BodyFeeFilter, fb.FeeFilterEnd(b)
+}
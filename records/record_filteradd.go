@@ -26,6 +26,10 @@ import (
 	"time"
 
 	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
 )
 
 type FilterAddRecord struct {
@@ -33,10 +37,10 @@ type FilterAddRecord struct {
 }
 
 func NewFilterAddRecord(msg *wire.MsgFilterAdd, ra *net.TCPAddr,
-	la *net.TCPAddr) *FilterAddRecord {
+	la *net.TCPAddr, stamp time.Time) *FilterAddRecord {
 	record := &FilterAddRecord{
 		Record: Record{
-			stamp: time.Now(),
+			stamp: stamp,
 			ra:    ra,
 			la:    la,
 			cmd:   msg.Command(),
@@ -59,3 +63,25 @@ func (fr *FilterAddRecord) String() string {
 
 	return buf.String()
 }
+
+// Bytes returns an empty payload, as we don't record the data peers add to
+// their bloom filter.
+func (fr *FilterAddRecord) Bytes() []byte {
+	return []byte{}
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (fr *FilterAddRecord) Proto() ([]byte, error) {
+	return marshal(fr.protoMessage())
+}
+
+func (fr *FilterAddRecord) protoMessage() *pb.Record {
+	return fr.protoEmpty()
+}
+
+func (fr *FilterAddRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	fb.FilterAddStart(b)
+	return fb.BodyFilterAdd, fb.FilterAddEnd(b)
+}
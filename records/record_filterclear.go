@@ -26,6 +26,10 @@ import (
 	"time"
 
 	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
 )
 
 type FilterClearRecord struct {
@@ -33,10 +37,10 @@ type FilterClearRecord struct {
 }
 
 func NewFilterClearRecord(msg *wire.MsgFilterClear, ra *net.TCPAddr,
-	la *net.TCPAddr) *FilterClearRecord {
+	la *net.TCPAddr, stamp time.Time) *FilterClearRecord {
 	record := &FilterClearRecord{
 		Record: Record{
-			stamp: time.Now(),
+			stamp: stamp,
 			ra:    ra,
 			la:    la,
 			cmd:   msg.Command(),
@@ -59,3 +63,24 @@ func (fr *FilterClearRecord) String() string {
 
 	return buf.String()
 }
+
+// Bytes returns an empty payload, as filterclear messages have none.
+func (fr *FilterClearRecord) Bytes() []byte {
+	return []byte{}
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (fr *FilterClearRecord) Proto() ([]byte, error) {
+	return marshal(fr.protoMessage())
+}
+
+func (fr *FilterClearRecord) protoMessage() *pb.Record {
+	return fr.protoEmpty()
+}
+
+func (fr *FilterClearRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	fb.FilterClearStart(b)
+	return fb.BodyFilterClear, fb.FilterClearEnd(b)
+}
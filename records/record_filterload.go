@@ -26,6 +26,10 @@ import (
 	"time"
 
 	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
 )
 
 type FilterLoadRecord struct {
@@ -33,10 +37,10 @@ type FilterLoadRecord struct {
 }
 
 func NewFilterLoadRecord(msg *wire.MsgFilterLoad, ra *net.TCPAddr,
-	la *net.TCPAddr) *FilterLoadRecord {
+	la *net.TCPAddr, stamp time.Time) *FilterLoadRecord {
 	record := &FilterLoadRecord{
 		Record: Record{
-			stamp: time.Now(),
+			stamp: stamp,
 			ra:    ra,
 			la:    la,
 			cmd:   msg.Command(),
@@ -59,3 +63,25 @@ func (fr *FilterLoadRecord) String() string {
 
 	return buf.String()
 }
+
+// Bytes returns an empty payload, as we don't record the bloom filters peers
+// load.
+func (fr *FilterLoadRecord) Bytes() []byte {
+	return []byte{}
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (fr *FilterLoadRecord) Proto() ([]byte, error) {
+	return marshal(fr.protoMessage())
+}
+
+func (fr *FilterLoadRecord) protoMessage() *pb.Record {
+	return fr.protoEmpty()
+}
+
+func (fr *FilterLoadRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	fb.FilterLoadStart(b)
+	return fb.BodyFilterLoad, fb.FilterLoadEnd(b)
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package records
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+
+	"github.com/CIRCL/pbtc/adaptor"
+	"github.com/CIRCL/pbtc/records/pb"
+)
+
+// GeoRecord wraps a record with the country and autonomous system of its
+// remote peer, as looked up in a geolocation database.
+type GeoRecord struct {
+	adaptor.Record
+
+	country string
+	asn     uint32
+}
+
+// NewGeoRecord wraps the given record with the location of its remote peer.
+// If the record was already annotated, it is returned unchanged.
+func NewGeoRecord(record adaptor.Record, country string,
+	asn uint32) adaptor.Record {
+	_, c, a := annotations(record)
+	if c != "" || a != 0 {
+		return record
+	}
+
+	gr := &GeoRecord{
+		Record:  record,
+		country: country,
+		asn:     asn,
+	}
+
+	return gr
+}
+
+// Country returns the country code of the remote peer.
+func (gr *GeoRecord) Country() string {
+	return gr.country
+}
+
+// ASN returns the autonomous system number of the remote peer.
+func (gr *GeoRecord) ASN() uint32 {
+	return gr.asn
+}
+
+func (gr *GeoRecord) String() string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(gr.Record.String())
+	buf.WriteString(Delimiter1)
+	buf.WriteString(gr.country)
+	buf.WriteString(Delimiter1)
+	buf.WriteString(strconv.FormatUint(uint64(gr.asn), 10))
+
+	return buf.String()
+}
+
+// Proto returns the wrapped record encoded as protobuf message, with the
+// location added. It fails if the wrapped record has no protobuf encoding.
+func (gr *GeoRecord) Proto() ([]byte, error) {
+	msg := gr.protoMessage()
+	if msg == nil {
+		return nil, errors.New("record has no protobuf encoding")
+	}
+
+	return marshal(msg)
+}
+
+// protoMessage returns the message of the wrapped record with the location
+// set, or nil if the wrapped record has no protobuf encoding.
+func (gr *GeoRecord) protoMessage() *pb.Record {
+	inner, ok := gr.Record.(protoMessager)
+	if !ok {
+		return nil
+	}
+
+	msg := inner.protoMessage()
+	if msg == nil {
+		return nil
+	}
+
+	msg.Country = gr.country
+	msg.Asn = gr.asn
+
+	return msg
+}
@@ -26,6 +26,10 @@ import (
 	"time"
 
 	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
 )
 
 type GetAddrRecord struct {
@@ -33,10 +37,10 @@ type GetAddrRecord struct {
 }
 
 func NewGetAddrRecord(msg *wire.MsgGetAddr, ra *net.TCPAddr,
-	la *net.TCPAddr) *GetAddrRecord {
+	la *net.TCPAddr, stamp time.Time) *GetAddrRecord {
 	record := &GetAddrRecord{
 		Record: Record{
-			stamp: time.Now(),
+			stamp: stamp,
 			ra:    ra,
 			la:    la,
 			cmd:   msg.Command(),
@@ -59,3 +63,24 @@ func (gr *GetAddrRecord) String() string {
 
 	return buf.String()
 }
+
+// Bytes returns an empty payload, as getaddr messages have none.
+func (gr *GetAddrRecord) Bytes() []byte {
+	return []byte{}
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (gr *GetAddrRecord) Proto() ([]byte, error) {
+	return marshal(gr.protoMessage())
+}
+
+func (gr *GetAddrRecord) protoMessage() *pb.Record {
+	return gr.protoEmpty()
+}
+
+func (gr *GetAddrRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	fb.GetAddrStart(b)
+	return fb.BodyGetAddr, fb.GetAddrEnd(b)
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package records
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"strconv"
+	"time"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
+)
+
+// CmdGetCFilters is the command of the message requesting the compact block
+// filters of a range of blocks, as defined by BIP 157.
+const CmdGetCFilters = "getcfilters"
+
+// GetCFiltersRecord describes a getcfilters message. The range starts at the
+// given height and ends at the block with the stop hash.
+type GetCFiltersRecord struct {
+	Record
+
+	filter uint8
+	start  uint32
+	stop   [32]byte
+}
+
+func NewGetCFiltersRecord(filter uint8, start uint32, stop [32]byte,
+	ra *net.TCPAddr, la *net.TCPAddr, stamp time.Time) *GetCFiltersRecord {
+	record := &GetCFiltersRecord{
+		Record: Record{
+			stamp: stamp,
+			ra:    ra,
+			la:    la,
+			cmd:   CmdGetCFilters,
+		},
+
+		filter: filter,
+		start:  start,
+		stop:   stop,
+	}
+
+	return record
+}
+
+// FilterType returns the type of the requested filters.
+func (gr *GetCFiltersRecord) FilterType() uint8 {
+	return gr.filter
+}
+
+// StartHeight returns the height of the first block of the range.
+func (gr *GetCFiltersRecord) StartHeight() uint32 {
+	return gr.start
+}
+
+// StopHash returns the hash of the last block of the range.
+func (gr *GetCFiltersRecord) StopHash() [32]byte {
+	return gr.stop
+}
+
+func (gr *GetCFiltersRecord) String() string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(gr.stamp.Format(time.RFC3339Nano))
+	buf.WriteString(Delimiter1)
+	buf.WriteString(gr.cmd)
+	buf.WriteString(Delimiter1)
+	buf.WriteString(gr.ra.String())
+	buf.WriteString(Delimiter1)
+	buf.WriteString(gr.la.String())
+	buf.WriteString(Delimiter1)
+	buf.WriteString(strconv.FormatUint(uint64(gr.filter), 10))
+	buf.WriteString(Delimiter1)
+	buf.WriteString(strconv.FormatUint(uint64(gr.start), 10))
+	buf.WriteString(Delimiter1)
+	buf.WriteString(hex.EncodeToString(gr.stop[:]))
+
+	return buf.String()
+}
+
+// Bytes returns the binary representation of the request, which is the same
+// as on the wire: 1 byte filter type, 4 bytes start height (little endian)
+// and 32 bytes stop hash.
+func (gr *GetCFiltersRecord) Bytes() []byte {
+	buf := make([]byte, 0, 37)
+	buf = append(buf, gr.filter)
+	buf = putUint32(buf, gr.start)
+	buf = append(buf, gr.stop[:]...)
+
+	return buf
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (gr *GetCFiltersRecord) Proto() ([]byte, error) {
+	return marshal(gr.protoMessage())
+}
+
+func (gr *GetCFiltersRecord) protoMessage() *pb.Record {
+	body := &pb.FilterRange{
+		FilterType:  uint32(gr.filter),
+		StartHeight: gr.start,
+		StopHash:    gr.stop[:],
+	}
+
+	msg := gr.proto()
+	msg.Body = &pb.Record_Getcfilters{Getcfilters: body}
+
+	return msg
+}
+
+func (gr *GetCFiltersRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	stop := b.CreateByteVector(gr.stop[:])
+
+	fb.GetCFiltersStart(b)
+	fb.GetCFiltersAddFilterType(b, uint32(gr.filter))
+	fb.GetCFiltersAddStartHeight(b, gr.start)
+	fb.GetCFiltersAddStopHash(b, stop)
+
+	return fb.BodyGetCFilters, fb.GetCFiltersEnd(b)
+}
@@ -27,6 +27,10 @@ import (
 	"time"
 
 	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
 )
 
 type GetDataRecord struct {
@@ -36,10 +40,10 @@ type GetDataRecord struct {
 }
 
 func NewGetDataRecord(msg *wire.MsgGetData, ra *net.TCPAddr,
-	la *net.TCPAddr) *GetDataRecord {
+	la *net.TCPAddr, stamp time.Time) *GetDataRecord {
 	record := &GetDataRecord{
 		Record: Record{
-			stamp: time.Now(),
+			stamp: stamp,
 			ra:    ra,
 			la:    la,
 			cmd:   msg.Command(),
@@ -75,3 +79,37 @@ func (gr *GetDataRecord) String() string {
 
 	return buf.String()
 }
+
+// Bytes returns the binary representation of the requested items: a 2 byte
+// item count followed by the 33 byte representation of each item.
+func (gr *GetDataRecord) Bytes() []byte {
+	return putItems(gr.items)
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (gr *GetDataRecord) Proto() ([]byte, error) {
+	return marshal(gr.protoMessage())
+}
+
+func (gr *GetDataRecord) protoMessage() *pb.Record {
+	body := &pb.Inventory{}
+	for _, item := range gr.items {
+		body.Items = append(body.Items, item.proto())
+	}
+
+	msg := gr.proto()
+	msg.Body = &pb.Record_Getdata{Getdata: body}
+
+	return msg
+}
+
+func (gr *GetDataRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	items := flatItems(b, gr.items)
+
+	fb.GetDataStart(b)
+	fb.GetDataAddItems(b, items)
+
+	return fb.BodyGetData, fb.GetDataEnd(b)
+}
@@ -22,33 +22,40 @@ package records
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/hex"
 	"net"
 	"strconv"
 	"time"
 
 	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
 )
 
 type GetHeadersRecord struct {
 	Record
 
-	stop   [32]byte
-	hashes [][32]byte
+	version uint32
+	stop    [32]byte
+	hashes  [][32]byte
 }
 
 func NewGetHeadersRecord(msg *wire.MsgGetHeaders, ra *net.TCPAddr,
-	la *net.TCPAddr) *GetHeadersRecord {
+	la *net.TCPAddr, stamp time.Time) *GetHeadersRecord {
 	record := &GetHeadersRecord{
 		Record: Record{
-			stamp: time.Now(),
+			stamp: stamp,
 			ra:    ra,
 			la:    la,
 			cmd:   msg.Command(),
 		},
 
-		stop:   msg.HashStop,
-		hashes: make([][32]byte, len(msg.BlockLocatorHashes)),
+		version: msg.ProtocolVersion,
+		stop:    msg.HashStop,
+		hashes:  make([][32]byte, len(msg.BlockLocatorHashes)),
 	}
 
 	for i, hash := range msg.BlockLocatorHashes {
@@ -69,6 +76,8 @@ func (gr *GetHeadersRecord) String() string {
 	buf.WriteString(Delimiter1)
 	buf.WriteString(gr.la.String())
 	buf.WriteString(Delimiter1)
+	buf.WriteString(strconv.FormatUint(uint64(gr.version), 10))
+	buf.WriteString(Delimiter1)
 	buf.WriteString(hex.EncodeToString(gr.stop[:]))
 	buf.WriteString(Delimiter1)
 	buf.WriteString(strconv.FormatInt(int64(len(gr.hashes)), 10))
@@ -80,3 +89,53 @@ func (gr *GetHeadersRecord) String() string {
 
 	return buf.String()
 }
+
+// Bytes returns the binary representation of the request: 4 bytes protocol
+// version, 2 bytes locator hash count (both little endian), 32 bytes for each
+// locator hash and 32 bytes stop hash. The count fits easily, as a locator
+// never has more than 500 hashes.
+func (gr *GetHeadersRecord) Bytes() []byte {
+	buf := make([]byte, 6, 6+(len(gr.hashes)+1)*32)
+	binary.LittleEndian.PutUint32(buf[0:4], gr.version)
+	binary.LittleEndian.PutUint16(buf[4:6], uint16(len(gr.hashes)))
+
+	for _, hash := range gr.hashes {
+		buf = append(buf, hash[:]...)
+	}
+
+	buf = append(buf, gr.stop[:]...)
+
+	return buf
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (gr *GetHeadersRecord) Proto() ([]byte, error) {
+	return marshal(gr.protoMessage())
+}
+
+func (gr *GetHeadersRecord) protoMessage() *pb.Record {
+	body := &pb.Locator{
+		Version: gr.version,
+		Hashes:  protoHashes(gr.hashes),
+		Stop:    gr.stop[:],
+	}
+
+	msg := gr.proto()
+	msg.Body = &pb.Record_Getheaders{Getheaders: body}
+
+	return msg
+}
+
+func (gr *GetHeadersRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	hashes := flatHashes(b, gr.hashes)
+	stop := b.CreateByteVector(gr.stop[:])
+
+	fb.GetHeadersStart(b)
+	fb.GetHeadersAddVersion(b, gr.version)
+	fb.GetHeadersAddHashes(b, hashes)
+	fb.GetHeadersAddStop(b, stop)
+
+	return fb.BodyGetHeaders, fb.GetHeadersEnd(b)
+}
@@ -27,8 +27,15 @@ import (
 	"time"
 
 	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
 )
 
+// HeaderSize is the size of the binary representation of a header record.
+const HeaderSize = 117
+
 type HeaderRecord struct {
 	block_hash  [32]byte
 	version     int32
@@ -76,3 +83,59 @@ func (hr *HeaderRecord) String() string {
 
 	return buf.String()
 }
+
+// Bytes returns the 117 byte binary representation of the header: the block
+// hash, version, previous block, merkle root, timestamp in unix seconds, bits,
+// nonce and transaction count.
+func (hr *HeaderRecord) Bytes() []byte {
+	buf := make([]byte, 0, HeaderSize)
+	buf = append(buf, hr.block_hash[:]...)
+	buf = putUint32(buf, uint32(hr.version))
+	buf = append(buf, hr.prev_block[:]...)
+	buf = append(buf, hr.merkle_root[:]...)
+	buf = putUint64(buf, uint64(hr.timestamp.Unix()))
+	buf = putUint32(buf, hr.bits)
+	buf = putUint32(buf, hr.nonce)
+	buf = append(buf, hr.txn_count)
+
+	return buf
+}
+
+func (hr *HeaderRecord) proto() *pb.Header {
+	msg := &pb.Header{
+		Hash:       hr.block_hash[:],
+		Version:    hr.version,
+		PrevBlock:  hr.prev_block[:],
+		MerkleRoot: hr.merkle_root[:],
+		Bits:       hr.bits,
+		Nonce:      hr.nonce,
+		TxnCount:   uint32(hr.txn_count),
+	}
+
+	if !hr.timestamp.IsZero() {
+		msg.Timestamp = hr.timestamp.UnixNano()
+	}
+
+	return msg
+}
+
+func (hr *HeaderRecord) flat(b *flatbuffers.Builder) flatbuffers.UOffsetT {
+	hash := b.CreateByteVector(hr.block_hash[:])
+	prev := b.CreateByteVector(hr.prev_block[:])
+	root := b.CreateByteVector(hr.merkle_root[:])
+
+	fb.HeaderStart(b)
+	fb.HeaderAddHash(b, hash)
+	fb.HeaderAddVersion(b, hr.version)
+	fb.HeaderAddPrevBlock(b, prev)
+	fb.HeaderAddMerkleRoot(b, root)
+	if !hr.timestamp.IsZero() {
+		fb.HeaderAddTimestamp(b, hr.timestamp.UnixNano())
+	}
+
+	fb.HeaderAddBits(b, hr.bits)
+	fb.HeaderAddNonce(b, hr.nonce)
+	fb.HeaderAddTxnCount(b, uint32(hr.txn_count))
+
+	return fb.HeaderEnd(b)
+}
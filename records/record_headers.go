@@ -27,6 +27,10 @@ import (
 	"time"
 
 	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
 )
 
 type HeadersRecord struct {
@@ -36,10 +40,10 @@ type HeadersRecord struct {
 }
 
 func NewHeadersRecord(msg *wire.MsgHeaders, ra *net.TCPAddr,
-	la *net.TCPAddr) *HeadersRecord {
+	la *net.TCPAddr, stamp time.Time) *HeadersRecord {
 	record := &HeadersRecord{
 		Record: Record{
-			stamp: time.Now(),
+			stamp: stamp,
 			ra:    ra,
 			la:    la,
 			cmd:   msg.Command(),
@@ -74,3 +78,47 @@ func (hr *HeadersRecord) String() string {
 
 	return buf.String()
 }
+
+// Bytes returns the binary representation of the headers: a 2 byte header
+// count followed by the 117 byte representation of each header.
+func (hr *HeadersRecord) Bytes() []byte {
+	buf := make([]byte, 0, 2+len(hr.hdrs)*HeaderSize)
+	buf = putUint16(buf, uint16(len(hr.hdrs)))
+	for _, hdr := range hr.hdrs {
+		buf = append(buf, hdr.Bytes()...)
+	}
+
+	return buf
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (hr *HeadersRecord) Proto() ([]byte, error) {
+	return marshal(hr.protoMessage())
+}
+
+func (hr *HeadersRecord) protoMessage() *pb.Record {
+	body := &pb.Headers{}
+	for _, hdr := range hr.hdrs {
+		body.Headers = append(body.Headers, hdr.proto())
+	}
+
+	msg := hr.proto()
+	msg.Body = &pb.Record_Headers{Headers: body}
+
+	return msg
+}
+
+func (hr *HeadersRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	hdrs := make([]flatbuffers.UOffsetT, 0, len(hr.hdrs))
+	for _, hdr := range hr.hdrs {
+		hdrs = append(hdrs, hdr.flat(b))
+	}
+
+	vec := flatTables(b, hdrs)
+	fb.HeadersStart(b)
+	fb.HeadersAddHeaders(b, vec)
+
+	return fb.BodyHeaders, fb.HeadersEnd(b)
+}
@@ -26,6 +26,10 @@ import (
 	"strconv"
 
 	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
 )
 
 type InputRecord struct {
@@ -54,3 +58,33 @@ func (ir *InputRecord) String() string {
 
 	return buf.String()
 }
+
+// Bytes returns the 40 byte binary representation of the input: the hash and
+// index of the previous output and the sequence number.
+func (ir *InputRecord) Bytes() []byte {
+	buf := make([]byte, 0, 40)
+	buf = append(buf, ir.hash[:]...)
+	buf = putUint32(buf, ir.index)
+	buf = putUint32(buf, ir.sequence)
+
+	return buf
+}
+
+func (ir *InputRecord) proto() *pb.Input {
+	return &pb.Input{
+		Hash:     ir.hash[:],
+		Index:    ir.index,
+		Sequence: ir.sequence,
+	}
+}
+
+func (ir *InputRecord) flat(b *flatbuffers.Builder) flatbuffers.UOffsetT {
+	hash := b.CreateByteVector(ir.hash[:])
+
+	fb.InputStart(b)
+	fb.InputAddHash(b, hash)
+	fb.InputAddIndex(b, ir.index)
+	fb.InputAddSequence(b, ir.sequence)
+
+	return fb.InputEnd(b)
+}
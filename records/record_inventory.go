@@ -27,6 +27,10 @@ import (
 	"time"
 
 	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
 )
 
 type InventoryRecord struct {
@@ -36,10 +40,10 @@ type InventoryRecord struct {
 }
 
 func NewInventoryRecord(msg *wire.MsgInv, ra *net.TCPAddr,
-	la *net.TCPAddr) *InventoryRecord {
+	la *net.TCPAddr, stamp time.Time) *InventoryRecord {
 	ir := &InventoryRecord{
 		Record: Record{
-			stamp: time.Now(),
+			stamp: stamp,
 			ra:    ra,
 			la:    la,
 			cmd:   msg.Command(),
@@ -74,3 +78,42 @@ func (ir *InventoryRecord) String() string {
 
 	return buf.String()
 }
+
+// Items returns the items of the inventory, in order.
+func (ir *InventoryRecord) Items() []*ItemRecord {
+	return ir.inv
+}
+
+// Bytes returns the binary representation of the inventory: a 2 byte item
+// count followed by the 33 byte representation of each item.
+func (ir *InventoryRecord) Bytes() []byte {
+	return putItems(ir.inv)
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (ir *InventoryRecord) Proto() ([]byte, error) {
+	return marshal(ir.protoMessage())
+}
+
+func (ir *InventoryRecord) protoMessage() *pb.Record {
+	body := &pb.Inventory{}
+	for _, item := range ir.inv {
+		body.Items = append(body.Items, item.proto())
+	}
+
+	msg := ir.proto()
+	msg.Body = &pb.Record_Inv{Inv: body}
+
+	return msg
+}
+
+func (ir *InventoryRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	items := flatItems(b, ir.inv)
+
+	fb.InvStart(b)
+	fb.InvAddItems(b, items)
+
+	return fb.BodyInv, fb.InvEnd(b)
+}
@@ -24,13 +24,24 @@ import (
 	"bytes"
 	"encoding/hex"
 	"strconv"
+	"time"
 
 	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
 )
 
+// ItemSize is the size of the binary representation of an item record.
+const ItemSize = 33
+
 type ItemRecord struct {
 	category uint8
 	hash     [32]byte
+
+	tracked bool
+	since   time.Duration
 }
 
 func NewItemRecord(vec *wire.InvVect) *ItemRecord {
@@ -48,5 +59,73 @@ func (ir *ItemRecord) String() string {
 	buf.WriteString(Delimiter3)
 	buf.WriteString(hex.EncodeToString(ir.hash[:]))
 
+	if ir.tracked {
+		buf.WriteString(Delimiter3)
+		since := int64(ir.since / time.Millisecond)
+		buf.WriteString(strconv.FormatInt(since, 10))
+	}
+
 	return buf.String()
 }
+
+// SetSince adds the time since the item was first seen from any peer, as
+// given by the tracker. It is only written for tracked items.
+func (ir *ItemRecord) SetSince(since time.Duration) {
+	ir.tracked = true
+	ir.since = since
+}
+
+// Since returns the time since the item was first seen from any peer, and
+// whether it is known.
+func (ir *ItemRecord) Since() (time.Duration, bool) {
+	return ir.since, ir.tracked
+}
+
+// Bytes returns the 33 byte binary representation of the item: its type
+// followed by its hash. The time since first seen is left out, so that items
+// keep a fixed size.
+func (ir *ItemRecord) Bytes() []byte {
+	buf := make([]byte, 0, ItemSize)
+	buf = append(buf, ir.category)
+	buf = append(buf, ir.hash[:]...)
+
+	return buf
+}
+
+// putItems returns the binary representation of a list of items: a 2 byte item
+// count followed by each item.
+func putItems(items []*ItemRecord) []byte {
+	buf := make([]byte, 0, 2+len(items)*ItemSize)
+	buf = putUint16(buf, uint16(len(items)))
+	for _, item := range items {
+		buf = append(buf, item.Bytes()...)
+	}
+
+	return buf
+}
+
+func (ir *ItemRecord) proto() *pb.Item {
+	msg := &pb.Item{
+		Type: uint32(ir.category),
+		Hash: ir.hash[:],
+	}
+
+	if ir.tracked {
+		msg.Since = int64(ir.since / time.Millisecond)
+	}
+
+	return msg
+}
+
+func (ir *ItemRecord) flat(b *flatbuffers.Builder) flatbuffers.UOffsetT {
+	hash := b.CreateByteVector(ir.hash[:])
+
+	fb.ItemStart(b)
+	fb.ItemAddType(b, uint32(ir.category))
+	fb.ItemAddHash(b, hash)
+	if ir.tracked {
+		fb.ItemAddSince(b, int64(ir.since/time.Millisecond))
+	}
+
+	return fb.ItemEnd(b)
+}
@@ -26,6 +26,10 @@ import (
 	"time"
 
 	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
 )
 
 type MemPoolRecord struct {
@@ -33,10 +37,10 @@ type MemPoolRecord struct {
 }
 
 func NewMemPoolRecord(msg *wire.MsgMemPool, ra *net.TCPAddr,
-	la *net.TCPAddr) *MemPoolRecord {
+	la *net.TCPAddr, stamp time.Time) *MemPoolRecord {
 	record := &MemPoolRecord{
 		Record: Record{
-			stamp: time.Now(),
+			stamp: stamp,
 			ra:    ra,
 			la:    la,
 			cmd:   msg.Command(),
@@ -58,3 +62,24 @@ func (mr *MemPoolRecord) String() string {
 
 	return buf.String()
 }
+
+// Bytes returns an empty payload, as mempool messages have none.
+func (mr *MemPoolRecord) Bytes() []byte {
+	return []byte{}
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (mr *MemPoolRecord) Proto() ([]byte, error) {
+	return marshal(mr.protoMessage())
+}
+
+func (mr *MemPoolRecord) protoMessage() *pb.Record {
+	return mr.protoEmpty()
+}
+
+func (mr *MemPoolRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	fb.MemPoolStart(b)
+	return fb.BodyMemPool, fb.MemPoolEnd(b)
+}
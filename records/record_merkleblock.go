@@ -26,6 +26,10 @@ import (
 	"time"
 
 	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
 )
 
 type MerkleBlockRecord struct {
@@ -33,10 +37,10 @@ type MerkleBlockRecord struct {
 }
 
 func NewMerkleBlockRecord(msg *wire.MsgMerkleBlock, ra *net.TCPAddr,
-	la *net.TCPAddr) *MerkleBlockRecord {
+	la *net.TCPAddr, stamp time.Time) *MerkleBlockRecord {
 	record := &MerkleBlockRecord{
 		Record: Record{
-			stamp: time.Now(),
+			stamp: stamp,
 			ra:    ra,
 			la:    la,
 			cmd:   msg.Command(),
@@ -58,3 +62,25 @@ func (mr *MerkleBlockRecord) String() string {
 
 	return buf.String()
 }
+
+// Bytes returns an empty payload, as we don't record the contents of merkle
+// blocks.
+func (mr *MerkleBlockRecord) Bytes() []byte {
+	return []byte{}
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (mr *MerkleBlockRecord) Proto() ([]byte, error) {
+	return marshal(mr.protoMessage())
+}
+
+func (mr *MerkleBlockRecord) protoMessage() *pb.Record {
+	return mr.protoEmpty()
+}
+
+func (mr *MerkleBlockRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	fb.MerkleBlockStart(b)
+	return fb.BodyMerkleBlock, fb.MerkleBlockEnd(b)
+}
@@ -27,6 +27,10 @@ import (
 	"time"
 
 	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
 )
 
 type NotFoundRecord struct {
@@ -36,10 +40,10 @@ type NotFoundRecord struct {
 }
 
 func NewNotFoundRecord(msg *wire.MsgNotFound, ra *net.TCPAddr,
-	la *net.TCPAddr) *NotFoundRecord {
+	la *net.TCPAddr, stamp time.Time) *NotFoundRecord {
 	record := &NotFoundRecord{
 		Record: Record{
-			stamp: time.Now(),
+			stamp: stamp,
 			ra:    ra,
 			la:    la,
 			cmd:   msg.Command(),
@@ -74,3 +78,37 @@ func (nr *NotFoundRecord) String() string {
 
 	return buf.String()
 }
+
+// Bytes returns the binary representation of the missing items: a 2 byte item
+// count followed by the 33 byte representation of each item.
+func (nr *NotFoundRecord) Bytes() []byte {
+	return putItems(nr.inv)
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (nr *NotFoundRecord) Proto() ([]byte, error) {
+	return marshal(nr.protoMessage())
+}
+
+func (nr *NotFoundRecord) protoMessage() *pb.Record {
+	body := &pb.Inventory{}
+	for _, item := range nr.inv {
+		body.Items = append(body.Items, item.proto())
+	}
+
+	msg := nr.proto()
+	msg.Body = &pb.Record_Notfound{Notfound: body}
+
+	return msg
+}
+
+func (nr *NotFoundRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	items := flatItems(b, nr.inv)
+
+	fb.NotFoundStart(b)
+	fb.NotFoundAddItems(b, items)
+
+	return fb.BodyNotFound, fb.NotFoundEnd(b)
+}
@@ -29,13 +29,19 @@ import (
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
 )
 
 type OutputRecord struct {
-	value int64
-	class uint8
-	sigs  uint8
-	addrs []btcutil.Address
+	value    int64
+	class    uint8
+	sigs     uint8
+	addrs    []btcutil.Address
+	nullData bool
+	data     []byte
 }
 
 func NewOutputRecord(txout *wire.TxOut) *OutputRecord {
@@ -49,6 +55,22 @@ func NewOutputRecord(txout *wire.TxOut) *OutputRecord {
 		addrs: addrs,
 	}
 
+	// keep the data embedded with OP_RETURN, so it can be filtered on; we
+	// look at the opcode rather than the class, as the class only covers
+	// scripts within the standard size and push rules
+	script := txout.PkScript
+	if len(script) > 0 && script[0] == txscript.OP_RETURN {
+		record.nullData = true
+		pushes, err := txscript.PushedData(script)
+		if err != nil {
+			record.data = script[1:]
+		}
+
+		for _, push := range pushes {
+			record.data = append(record.data, push...)
+		}
+	}
+
 	return record
 }
 
@@ -68,3 +90,49 @@ func (or *OutputRecord) String() string {
 
 	return buf.String()
 }
+
+// Bytes returns the binary representation of the output: the value, script
+// class and number of required signatures, then the address count and the
+// encoded addresses.
+func (or *OutputRecord) Bytes() []byte {
+	buf := putUint64(nil, uint64(or.value))
+	buf = append(buf, or.class, or.sigs)
+	buf = putUint16(buf, uint16(len(or.addrs)))
+	for _, addr := range or.addrs {
+		buf = putString(buf, addr.EncodeAddress())
+	}
+
+	return buf
+}
+
+func (or *OutputRecord) proto() *pb.Output {
+	msg := &pb.Output{
+		Value: or.value,
+		Class: ParseClass(or.class),
+		Sigs:  uint32(or.sigs),
+	}
+
+	for _, addr := range or.addrs {
+		msg.Addresses = append(msg.Addresses, addr.EncodeAddress())
+	}
+
+	return msg
+}
+
+func (or *OutputRecord) flat(b *flatbuffers.Builder) flatbuffers.UOffsetT {
+	addrs := make([]string, 0, len(or.addrs))
+	for _, addr := range or.addrs {
+		addrs = append(addrs, addr.EncodeAddress())
+	}
+
+	class := b.CreateString(ParseClass(or.class))
+	vec := flatStrings(b, addrs)
+
+	fb.OutputStart(b)
+	fb.OutputAddValue(b, or.value)
+	fb.OutputAddClass(b, class)
+	fb.OutputAddSigs(b, uint32(or.sigs))
+	fb.OutputAddAddresses(b, vec)
+
+	return fb.OutputEnd(b)
+}
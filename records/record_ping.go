@@ -27,6 +27,10 @@ import (
 	"time"
 
 	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
 )
 
 type PingRecord struct {
@@ -36,10 +40,10 @@ type PingRecord struct {
 }
 
 func NewPingRecord(msg *wire.MsgPing, ra *net.TCPAddr,
-	la *net.TCPAddr) *PingRecord {
+	la *net.TCPAddr, stamp time.Time) *PingRecord {
 	record := &PingRecord{
 		Record: Record{
-			stamp: time.Now(),
+			stamp: stamp,
 			ra:    ra,
 			la:    la,
 			cmd:   msg.Command(),
@@ -65,3 +69,29 @@ func (pr *PingRecord) String() string {
 
 	return buf.String()
 }
+
+// Bytes returns the binary representation of the nonce.
+func (pr *PingRecord) Bytes() []byte {
+	return putUint64(nil, pr.nonce)
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (pr *PingRecord) Proto() ([]byte, error) {
+	return marshal(pr.protoMessage())
+}
+
+func (pr *PingRecord) protoMessage() *pb.Record {
+	msg := pr.proto()
+	msg.Body = &pb.Record_Ping{Ping: &pb.Nonce{Nonce: pr.nonce}}
+
+	return msg
+}
+
+func (pr *PingRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	fb.PingStart(b)
+	fb.PingAddNonce(b, pr.nonce)
+
+	return fb.BodyPing, fb.PingEnd(b)
+}
@@ -27,6 +27,10 @@ import (
 	"time"
 
 	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
 )
 
 type PongRecord struct {
@@ -36,10 +40,10 @@ type PongRecord struct {
 }
 
 func NewPongRecord(msg *wire.MsgPong, ra *net.TCPAddr,
-	la *net.TCPAddr) *PongRecord {
+	la *net.TCPAddr, stamp time.Time) *PongRecord {
 	record := &PongRecord{
 		Record: Record{
-			stamp: time.Now(),
+			stamp: stamp,
 			ra:    ra,
 			la:    la,
 			cmd:   msg.Command(),
@@ -65,3 +69,29 @@ func (pr *PongRecord) String() string {
 
 	return buf.String()
 }
+
+// Bytes returns the binary representation of the nonce.
+func (pr *PongRecord) Bytes() []byte {
+	return putUint64(nil, pr.nonce)
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (pr *PongRecord) Proto() ([]byte, error) {
+	return marshal(pr.protoMessage())
+}
+
+func (pr *PongRecord) protoMessage() *pb.Record {
+	msg := pr.proto()
+	msg.Body = &pb.Record_Pong{Pong: &pb.Nonce{Nonce: pr.nonce}}
+
+	return msg
+}
+
+func (pr *PongRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	fb.PongStart(b)
+	fb.PongAddNonce(b, pr.nonce)
+
+	return fb.BodyPong, fb.PongEnd(b)
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package records
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"time"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
+)
+
+// RawRecord describes a well-framed message with a command we do not support.
+// It keeps the raw payload, so that new or unknown message types can still be
+// analyzed offline instead of being lost.
+type RawRecord struct {
+	Record
+
+	payload []byte
+}
+
+func NewRawRecord(command string, payload []byte, ra *net.TCPAddr,
+	la *net.TCPAddr, stamp time.Time) *RawRecord {
+	record := &RawRecord{
+		Record: Record{
+			stamp: stamp,
+			ra:    ra,
+			la:    la,
+			cmd:   command,
+		},
+
+		payload: payload,
+	}
+
+	return record
+}
+
+func (rr *RawRecord) String() string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(rr.stamp.Format(time.RFC3339Nano))
+	buf.WriteString(Delimiter1)
+	buf.WriteString(rr.cmd)
+	buf.WriteString(Delimiter1)
+	buf.WriteString(rr.ra.String())
+	buf.WriteString(Delimiter1)
+	buf.WriteString(rr.la.String())
+	buf.WriteString(Delimiter1)
+	buf.WriteString(hex.EncodeToString(rr.payload))
+
+	return buf.String()
+}
+
+// Payload returns the raw payload of the message.
+func (rr *RawRecord) Payload() []byte {
+	return rr.payload
+}
+
+// Bytes returns the raw payload of the message.
+func (rr *RawRecord) Bytes() []byte {
+	return rr.payload
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (rr *RawRecord) Proto() ([]byte, error) {
+	return marshal(rr.protoMessage())
+}
+
+func (rr *RawRecord) protoMessage() *pb.Record {
+	msg := rr.proto()
+	msg.Body = &pb.Record_Raw{Raw: &pb.Raw{Payload: rr.payload}}
+
+	return msg
+}
+
+func (rr *RawRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	payload := b.CreateByteVector(rr.payload)
+
+	fb.RawStart(b)
+	fb.RawAddPayload(b, payload)
+
+	return fb.BodyRaw, fb.RawEnd(b)
+}
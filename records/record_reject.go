@@ -28,6 +28,10 @@ import (
 	"time"
 
 	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
 )
 
 type RejectRecord struct {
@@ -40,10 +44,10 @@ type RejectRecord struct {
 }
 
 func NewRejectRecord(msg *wire.MsgReject, ra *net.TCPAddr,
-	la *net.TCPAddr) *RejectRecord {
+	la *net.TCPAddr, stamp time.Time) *RejectRecord {
 	record := &RejectRecord{
 		Record: Record{
-			stamp: time.Now(),
+			stamp: stamp,
 			ra:    ra,
 			la:    la,
 			cmd:   msg.Command(),
@@ -78,3 +82,49 @@ func (rr *RejectRecord) String() string {
 
 	return buf.String()
 }
+
+// Bytes returns the binary representation of the rejection: the code, the
+// rejected command, the hash of the rejected item and the reason.
+func (rr *RejectRecord) Bytes() []byte {
+	buf := []byte{rr.code}
+	buf = putString(buf, rr.reject)
+	buf = putBytes(buf, rr.hash)
+	buf = putString(buf, rr.reason)
+
+	return buf
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (rr *RejectRecord) Proto() ([]byte, error) {
+	return marshal(rr.protoMessage())
+}
+
+func (rr *RejectRecord) protoMessage() *pb.Record {
+	body := &pb.Reject{
+		Code:    uint32(rr.code),
+		Command: rr.reject,
+		Hash:    rr.hash,
+		Reason:  rr.reason,
+	}
+
+	msg := rr.proto()
+	msg.Body = &pb.Record_Reject{Reject: body}
+
+	return msg
+}
+
+func (rr *RejectRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	cmd := b.CreateString(rr.reject)
+	hash := b.CreateByteVector(rr.hash)
+	reason := b.CreateString(rr.reason)
+
+	fb.RejectStart(b)
+	fb.RejectAddCode(b, uint32(rr.code))
+	fb.RejectAddCommand(b, cmd)
+	fb.RejectAddHash(b, hash)
+	fb.RejectAddReason(b, reason)
+
+	return fb.BodyReject, fb.RejectEnd(b)
+}
@@ -0,0 +1,211 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package records
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
+)
+
+// CmdSummary is the command of the summary record, which has no corresponding
+// Bitcoin message.
+const CmdSummary = "summary"
+
+// SummaryRecord describes a whole capture session. It is emitted by a manager
+// when it shuts down, so that each capture ends with a self-describing footer.
+// It has the duration of the session in seconds, the number of unique peers we
+// connected to, the number of bytes we received from them, the number of bytes
+// the processors wrote before the summary, the number of peers we banned and
+// the number of messages recorded for each command.
+type SummaryRecord struct {
+	Record
+
+	duration time.Duration
+	peers    int
+	bytes    uint64
+	written  uint64
+	bans     uint64
+	commands map[string]uint64
+}
+
+func NewSummaryRecord(start time.Time, stamp time.Time, peers int,
+	bytes uint64, written uint64, bans uint64,
+	commands map[string]uint64) *SummaryRecord {
+	record := &SummaryRecord{
+		Record: Record{
+			stamp: stamp,
+			ra:    &net.TCPAddr{},
+			la:    &net.TCPAddr{},
+			cmd:   CmdSummary,
+		},
+
+		duration: stamp.Sub(start),
+		peers:    peers,
+		bytes:    bytes,
+		written:  written,
+		bans:     bans,
+		commands: commands,
+	}
+
+	return record
+}
+
+func (sr *SummaryRecord) String() string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(sr.stamp.Format(time.RFC3339Nano))
+	buf.WriteString(Delimiter1)
+	buf.WriteString(sr.cmd)
+	buf.WriteString(Delimiter1)
+	buf.WriteString(sr.ra.String())
+	buf.WriteString(Delimiter1)
+	buf.WriteString(sr.la.String())
+	buf.WriteString(Delimiter1)
+	buf.WriteString(strconv.FormatInt(int64(sr.duration.Seconds()), 10))
+	buf.WriteString(Delimiter1)
+	buf.WriteString(strconv.Itoa(sr.peers))
+	buf.WriteString(Delimiter1)
+	buf.WriteString(strconv.FormatUint(sr.bytes, 10))
+	buf.WriteString(Delimiter1)
+	buf.WriteString(strconv.FormatUint(sr.written, 10))
+	buf.WriteString(Delimiter1)
+	buf.WriteString(strconv.FormatUint(sr.bans, 10))
+	buf.WriteString(Delimiter1)
+
+	for i, cmd := range sr.sorted() {
+		if i > 0 {
+			buf.WriteString(Delimiter2)
+		}
+
+		buf.WriteString(cmd)
+		buf.WriteString(":")
+		buf.WriteString(strconv.FormatUint(sr.commands[cmd], 10))
+	}
+
+	return buf.String()
+}
+
+// sorted returns the recorded commands in order, so that the summary is
+// stable.
+func (sr *SummaryRecord) sorted() []string {
+	cmds := make([]string, 0, len(sr.commands))
+	for cmd := range sr.commands {
+		cmds = append(cmds, cmd)
+	}
+
+	sort.Strings(cmds)
+
+	return cmds
+}
+
+// Duration returns the duration of the session.
+func (sr *SummaryRecord) Duration() time.Duration {
+	return sr.duration
+}
+
+// Peers returns the number of unique peers we connected to.
+func (sr *SummaryRecord) Peers() int {
+	return sr.peers
+}
+
+// Received returns the number of bytes received from all peers.
+func (sr *SummaryRecord) Received() uint64 {
+	return sr.bytes
+}
+
+// Written returns the number of bytes the processors wrote before the summary.
+func (sr *SummaryRecord) Written() uint64 {
+	return sr.written
+}
+
+// Bans returns the number of peers we banned, by disconnecting them for not
+// meeting our requirements, for being idle or on request.
+func (sr *SummaryRecord) Bans() uint64 {
+	return sr.bans
+}
+
+// Commands returns the number of recorded messages for each command.
+func (sr *SummaryRecord) Commands() map[string]uint64 {
+	return sr.commands
+}
+
+// Bytes returns the binary representation of the summary: the duration in
+// nanoseconds, the peer count, the bytes received and written, the ban count,
+// then the command count followed by each command with its message count,
+// sorted by command.
+func (sr *SummaryRecord) Bytes() []byte {
+	buf := putUint64(nil, uint64(sr.duration))
+	buf = putUint32(buf, uint32(sr.peers))
+	buf = putUint64(buf, sr.bytes)
+	buf = putUint64(buf, sr.written)
+	buf = putUint64(buf, sr.bans)
+	buf = putUint16(buf, uint16(len(sr.commands)))
+	for _, cmd := range sr.sorted() {
+		buf = putString(buf, cmd)
+		buf = putUint64(buf, sr.commands[cmd])
+	}
+
+	return buf
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (sr *SummaryRecord) Proto() ([]byte, error) {
+	return marshal(sr.protoMessage())
+}
+
+func (sr *SummaryRecord) protoMessage() *pb.Record {
+	body := &pb.Summary{
+		Duration: int64(sr.duration),
+		Peers:    int32(sr.peers),
+		Bytes:    sr.bytes,
+		Commands: sr.commands,
+		Written:  sr.written,
+		Bans:     sr.bans,
+	}
+
+	msg := sr.proto()
+	msg.Body = &pb.Record_Summary{Summary: body}
+
+	return msg
+}
+
+func (sr *SummaryRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	commands := flatCounts(b, sr.commands)
+
+	fb.SummaryStart(b)
+	fb.SummaryAddDuration(b, int64(sr.duration))
+	fb.SummaryAddPeers(b, int32(sr.peers))
+	fb.SummaryAddBytes(b, sr.bytes)
+	fb.SummaryAddCommands(b, commands)
+	fb.SummaryAddWritten(b, sr.written)
+	fb.SummaryAddBans(b, sr.bans)
+
+	return fb.BodySummary, fb.SummaryEnd(b)
+}
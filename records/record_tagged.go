@@ -0,0 +1,96 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package records
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/CIRCL/pbtc/adaptor"
+	"github.com/CIRCL/pbtc/records/pb"
+)
+
+// TaggedRecord wraps a record with the tag of the processor it went through,
+// so that consumers of a shared output can tell which processor captured it.
+type TaggedRecord struct {
+	adaptor.Record
+
+	tag string
+}
+
+// NewTaggedRecord wraps the given record with a tag. If the record was already
+// tagged, the original tag of its source is kept.
+func NewTaggedRecord(record adaptor.Record, tag string) adaptor.Record {
+	t, _, _ := annotations(record)
+	if t != "" {
+		return record
+	}
+
+	tr := &TaggedRecord{
+		Record: record,
+		tag:    tag,
+	}
+
+	return tr
+}
+
+// Tag returns the tag of the record.
+func (tr *TaggedRecord) Tag() string {
+	return tr.tag
+}
+
+func (tr *TaggedRecord) String() string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(tr.tag)
+	buf.WriteString(Delimiter1)
+	buf.WriteString(tr.Record.String())
+
+	return buf.String()
+}
+
+// Proto returns the wrapped record encoded as protobuf message, with the tag
+// added. It fails if the wrapped record has no protobuf encoding.
+func (tr *TaggedRecord) Proto() ([]byte, error) {
+	msg := tr.protoMessage()
+	if msg == nil {
+		return nil, errors.New("record has no protobuf encoding")
+	}
+
+	return marshal(msg)
+}
+
+// protoMessage returns the message of the wrapped record with the tag
+// set, or nil if the wrapped record has no protobuf encoding.
+func (tr *TaggedRecord) protoMessage() *pb.Record {
+	inner, ok := tr.Record.(protoMessager)
+	if !ok {
+		return nil
+	}
+
+	msg := inner.protoMessage()
+	if msg == nil {
+		return nil
+	}
+
+	msg.Tag = tr.tag
+
+	return msg
+}
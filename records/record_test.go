@@ -0,0 +1,380 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package records
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/CIRCL/pbtc/adaptor"
+)
+
+// Every record type has to implement the record interface itself, as the
+// embedded base record only provides the common fields.
+var (
+	_ adaptor.Record = (*AddressRecord)(nil)
+	_ adaptor.Record = (*AlertRecord)(nil)
+	_ adaptor.Record = (*BlockRecord)(nil)
+	_ adaptor.Record = (*CFHeadersRecord)(nil)
+	_ adaptor.Record = (*CFilterRecord)(nil)
+	_ adaptor.Record = (*DisconnectRecord)(nil)
+	_ adaptor.Record = (*FeeFilterRecord)(nil)
+	_ adaptor.Record = (*FilterAddRecord)(nil)
+	_ adaptor.Record = (*FilterClearRecord)(nil)
+	_ adaptor.Record = (*FilterLoadRecord)(nil)
+	_ adaptor.Record = (*GeoRecord)(nil)
+	_ adaptor.Record = (*GetAddrRecord)(nil)
+	_ adaptor.Record = (*GetBlocksRecord)(nil)
+	_ adaptor.Record = (*GetCFiltersRecord)(nil)
+	_ adaptor.Record = (*GetDataRecord)(nil)
+	_ adaptor.Record = (*GetHeadersRecord)(nil)
+	_ adaptor.Record = (*HeadersRecord)(nil)
+	_ adaptor.Record = (*InventoryRecord)(nil)
+	_ adaptor.Record = (*MemPoolRecord)(nil)
+	_ adaptor.Record = (*MerkleBlockRecord)(nil)
+	_ adaptor.Record = (*NotFoundRecord)(nil)
+	_ adaptor.Record = (*PingRecord)(nil)
+	_ adaptor.Record = (*PongRecord)(nil)
+	_ adaptor.Record = (*RawRecord)(nil)
+	_ adaptor.Record = (*RejectRecord)(nil)
+	_ adaptor.Record = (*SummaryRecord)(nil)
+	_ adaptor.Record = (*TaggedRecord)(nil)
+	_ adaptor.Record = (*TopologySnapshotRecord)(nil)
+	_ adaptor.Record = (*TransactionRecord)(nil)
+	_ adaptor.Record = (*VerAckRecord)(nil)
+	_ adaptor.Record = (*VersionRecord)(nil)
+)
+
+func testTx() *TransactionRecord {
+	ra := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 8333}
+	la := &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 50000}
+
+	return NewTransactionRecord(&wire.MsgTx{Version: 1}, ra, la, time.Unix(1, 0))
+}
+
+func TestUnwrap(t *testing.T) {
+	tx := testTx()
+
+	tests := map[string]adaptor.Record{
+		"plain":      tx,
+		"tagged":     NewTaggedRecord(tx, "a"),
+		"located":    NewGeoRecord(tx, "LU", 6661),
+		"tagged geo": NewTaggedRecord(NewGeoRecord(tx, "LU", 6661), "a"),
+		"geo tagged": NewGeoRecord(NewTaggedRecord(tx, "a"), "LU", 6661),
+	}
+
+	for name, record := range tests {
+		inner, ok := Unwrap(record).(*TransactionRecord)
+		if !ok || inner != tx {
+			t.Errorf("%v: unwrapped to %T", name, Unwrap(record))
+		}
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	tx := testTx()
+
+	tests := []struct {
+		name    string
+		record  adaptor.Record
+		tag     string
+		country string
+		asn     uint32
+	}{
+		{"plain", tx, "", "", 0},
+		{"tagged", NewTaggedRecord(tx, "a"), "a", "", 0},
+		{"located", NewGeoRecord(tx, "LU", 6661), "", "LU", 6661},
+		{"tagged geo", NewTaggedRecord(NewGeoRecord(tx, "LU", 6661), "a"),
+			"a", "LU", 6661},
+		{"geo tagged", NewGeoRecord(NewTaggedRecord(tx, "a"), "LU", 6661),
+			"a", "LU", 6661},
+		{"retagged", NewTaggedRecord(NewGeoRecord(NewTaggedRecord(tx, "a"),
+			"LU", 6661), "b"), "a", "LU", 6661},
+		{"relocated", NewGeoRecord(NewTaggedRecord(NewGeoRecord(tx, "LU",
+			6661), "a"), "DE", 3320), "a", "LU", 6661},
+	}
+
+	for _, test := range tests {
+		tag, country, asn := annotations(test.record)
+		if tag != test.tag || country != test.country || asn != test.asn {
+			t.Errorf("%v: got %q, %q, %v", test.name, tag, country, asn)
+		}
+	}
+}
+
+func TestEmptyPayload(t *testing.T) {
+	ra := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 8333}
+	la := &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 50000}
+	stamp := time.Unix(1, 0)
+
+	tests := []adaptor.Record{
+		NewVerAckRecord(wire.NewMsgVerAck(), ra, la, stamp),
+		NewGetAddrRecord(wire.NewMsgGetAddr(), ra, la, stamp),
+		NewMemPoolRecord(wire.NewMsgMemPool(), ra, la, stamp),
+		NewFilterClearRecord(wire.NewMsgFilterClear(), ra, la, stamp),
+		NewFilterAddRecord(wire.NewMsgFilterAdd([]byte{1, 2}), ra, la,
+			stamp),
+		NewFilterLoadRecord(wire.NewMsgFilterLoad([]byte{1, 2}, 1, 0,
+			wire.BloomUpdateNone), ra, la, stamp),
+		NewMerkleBlockRecord(&wire.MsgMerkleBlock{}, ra, la, stamp),
+	}
+
+	for _, record := range tests {
+		buf := record.Bytes()
+		if buf == nil || len(buf) != 0 {
+			t.Errorf("%v: payload %x", record.Command(), buf)
+		}
+	}
+}
+
+func TestEntryAdvertised(t *testing.T) {
+	advertised := time.Unix(1400000000, 0)
+	na := wire.NewNetAddressIPPort(net.ParseIP("192.0.2.3"), 8333,
+		wire.SFNodeNetwork)
+	na.Timestamp = advertised
+
+	msg := wire.NewMsgAddr()
+	msg.AddAddress(na)
+
+	ra := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 8333}
+	la := &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 50000}
+	record := NewAddressRecord(msg, ra, la, time.Unix(1500000000, 0))
+
+	entry := record.addrs[0]
+	if !entry.Advertised().Equal(na.Timestamp) {
+		t.Errorf("advertised %v instead of %v", entry.Advertised(),
+			na.Timestamp)
+	}
+
+	expected := "1400000000" + Delimiter3 + "1" + Delimiter3 +
+		"192.0.2.3:8333"
+	if entry.String() != expected {
+		t.Errorf("entry string %q instead of %q", entry.String(),
+			expected)
+	}
+
+	buf := record.Bytes()
+	if len(buf) != 2+EntrySize {
+		t.Fatalf("%v bytes for one entry", len(buf))
+	}
+
+	if binary.LittleEndian.Uint16(buf[0:2]) != 1 {
+		t.Errorf("entry count %v", binary.LittleEndian.Uint16(buf[0:2]))
+	}
+
+	buf = buf[2:]
+	stamp := time.Unix(int64(binary.LittleEndian.Uint32(buf[0:4])), 0)
+	if !stamp.Equal(advertised) {
+		t.Errorf("advertised %v in bytes instead of %v", stamp,
+			advertised)
+	}
+
+	services := binary.LittleEndian.Uint64(buf[4:12])
+	if services != uint64(wire.SFNodeNetwork) {
+		t.Errorf("services %v in bytes", services)
+	}
+
+	ip := net.IP(buf[12:28])
+	port := binary.BigEndian.Uint16(buf[28:30])
+	if !ip.Equal(na.IP) || port != na.Port {
+		t.Errorf("address %v:%v in bytes", ip, port)
+	}
+}
+
+func TestGetBlocks(t *testing.T) {
+	msg := wire.NewMsgGetBlocks(&wire.ShaHash{})
+	for i := 1; i <= 3; i++ {
+		err := msg.AddBlockLocatorHash(&wire.ShaHash{byte(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ra := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 8333}
+	la := &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 50000}
+	record := NewGetBlocksRecord(msg, ra, la, time.Unix(1, 0))
+
+	zero := strings.Repeat("00", 32)
+	fields := strings.Split(record.String(), Delimiter1)
+	expected := strconv.FormatUint(uint64(wire.ProtocolVersion), 10)
+	if len(fields) != 7 || fields[4] != expected || fields[5] != zero {
+		t.Fatalf("string %q", record.String())
+	}
+
+	hashes := strings.Split(fields[6], Delimiter2)
+	if len(hashes) != 4 || hashes[0] != "3" {
+		t.Fatalf("locator %q", fields[6])
+	}
+
+	for i, hash := range hashes[1:] {
+		if hash != fmt.Sprintf("%02x", i+1)+zero[2:] {
+			t.Errorf("locator hash %v is %v", i, hash)
+		}
+	}
+
+	buf := record.Bytes()
+	if len(buf) != 6+4*32 {
+		t.Fatalf("%v bytes for three locator hashes", len(buf))
+	}
+
+	if binary.LittleEndian.Uint32(buf[0:4]) != wire.ProtocolVersion ||
+		binary.LittleEndian.Uint16(buf[4:6]) != 3 {
+		t.Errorf("header %x", buf[0:6])
+	}
+
+	for i := 0; i < 3; i++ {
+		hash := buf[6+i*32 : 6+(i+1)*32]
+		if hash[0] != byte(i+1) {
+			t.Errorf("locator hash %v is %x", i, hash)
+		}
+	}
+
+	if !bytes.Equal(buf[6+3*32:], make([]byte, 32)) {
+		t.Errorf("stop hash %x", buf[6+3*32:])
+	}
+}
+
+func TestCompactFilters(t *testing.T) {
+	ra := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 8333}
+	la := &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 50000}
+	stamp := time.Unix(1, 0)
+	prefix := stamp.Format(time.RFC3339Nano) + Delimiter1 + "%v" +
+		Delimiter1 + ra.String() + Delimiter1 + la.String() + Delimiter1
+
+	stop := [32]byte{0xaa, 0xbb}
+	prev := [32]byte{0xcc}
+	zero := strings.Repeat("00", 30)
+
+	getcfilters := NewGetCFiltersRecord(0, 375000, stop, ra, la, stamp)
+	expected := fmt.Sprintf(prefix, CmdGetCFilters) + "0" + Delimiter1 +
+		"375000" + Delimiter1 + "aabb" + zero
+	if getcfilters.String() != expected {
+		t.Errorf("getcfilters string %q", getcfilters.String())
+	}
+
+	buf := getcfilters.Bytes()
+	if len(buf) != 37 || buf[0] != 0 ||
+		binary.LittleEndian.Uint32(buf[1:5]) != 375000 ||
+		!bytes.Equal(buf[5:], stop[:]) {
+		t.Errorf("getcfilters bytes %x", buf)
+	}
+
+	// a basic filter of a large block, which does not fit the two byte
+	// size of other variable fields
+	filter := make([]byte, 70000)
+	filter[0], filter[1], filter[2] = 0xfd, 0x2c, 0x01
+	for i := 3; i < len(filter); i++ {
+		filter[i] = byte(i * 31)
+	}
+
+	cfilter := NewCFilterRecord(0, stop, filter, ra, la, stamp)
+	fields := strings.Split(cfilter.String(), Delimiter1)
+	if len(fields) != 7 || fields[1] != CmdCFilter || fields[4] != "0" ||
+		fields[5] != "aabb"+zero ||
+		fields[6] != fmt.Sprintf("%x", filter) {
+		t.Errorf("cfilter string fields %.100q", fields)
+	}
+
+	buf = cfilter.Bytes()
+	if len(buf) != 37+len(filter) || buf[0] != 0 ||
+		!bytes.Equal(buf[1:33], stop[:]) ||
+		binary.LittleEndian.Uint32(buf[33:37]) != uint32(len(filter)) ||
+		!bytes.Equal(buf[37:], filter) {
+		t.Errorf("cfilter bytes %.100x", buf)
+	}
+
+	hashes := [][32]byte{{1}, {2}, {3}}
+	cfheaders := NewCFHeadersRecord(0, stop, prev, hashes, ra, la, stamp)
+	expected = fmt.Sprintf(prefix, CmdCFHeaders) + "0" + Delimiter1 +
+		"aabb" + zero + Delimiter1 + "cc" + zero + "00" + Delimiter1 + "3"
+	for i := range hashes {
+		expected += Delimiter2 + fmt.Sprintf("%02x", i+1) + zero + "00"
+	}
+	if cfheaders.String() != expected {
+		t.Errorf("cfheaders string %q", cfheaders.String())
+	}
+
+	buf = cfheaders.Bytes()
+	if len(buf) != 67+3*32 || buf[0] != 0 ||
+		!bytes.Equal(buf[1:33], stop[:]) ||
+		!bytes.Equal(buf[33:65], prev[:]) ||
+		binary.LittleEndian.Uint16(buf[65:67]) != 3 {
+		t.Fatalf("cfheaders bytes %x", buf)
+	}
+
+	for i, hash := range hashes {
+		if !bytes.Equal(buf[67+i*32:67+(i+1)*32], hash[:]) {
+			t.Errorf("filter hash %v is %x", i, buf[67+i*32:67+(i+1)*32])
+		}
+	}
+}
+
+func TestTransactionWitness(t *testing.T) {
+	plain := testTx()
+	if plain.Segwit() || plain.WitnessHash() != plain.Hash() ||
+		plain.WitnessSizes() != nil {
+		t.Errorf("transaction without witness has wtxid %x",
+			plain.WitnessHash())
+	}
+
+	segwit := testTx()
+	wtxid := [32]byte{0xee}
+	segwit.SetWitness(wtxid, []uint32{107, 0})
+	if !segwit.Segwit() || segwit.WitnessHash() == segwit.Hash() {
+		t.Errorf("segwit transaction has wtxid %x", segwit.WitnessHash())
+	}
+
+	// the output ends with the witness hash and sizes, only for segwit
+	suffix := Delimiter1 + fmt.Sprintf("%x", wtxid) + Delimiter2 + "107" +
+		Delimiter2 + "0"
+	if !strings.HasSuffix(segwit.String(), suffix) {
+		t.Errorf("segwit string %q", segwit.String())
+	}
+
+	if strings.Contains(plain.String(), fmt.Sprintf("%x", wtxid)) ||
+		plain.String() != strings.TrimSuffix(segwit.String(), suffix) {
+		t.Errorf("plain string %q", plain.String())
+	}
+
+	buf := segwit.Bytes()
+	tail := buf[len(buf)-(1+32+2+4+4):]
+	if tail[0] != 1 || !bytes.Equal(tail[1:33], wtxid[:]) ||
+		binary.LittleEndian.Uint16(tail[33:35]) != 2 ||
+		binary.LittleEndian.Uint32(tail[35:39]) != 107 {
+		t.Errorf("segwit bytes end with %x", tail)
+	}
+
+	buf = plain.Bytes()
+	hash := plain.Hash()
+	tail = buf[len(buf)-(1+32+2):]
+	if tail[0] != 0 || !bytes.Equal(tail[1:33], hash[:]) ||
+		binary.LittleEndian.Uint16(tail[33:35]) != 0 {
+		t.Errorf("plain bytes end with %x", tail)
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package records
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
+)
+
+// CmdTopology is the command of the topology record, which has no
+// corresponding Bitcoin message.
+const CmdTopology = "topology"
+
+// TopologySnapshotRecord describes the peers a manager is connected to at one
+// point in time. It is emitted periodically, so that the composition of our
+// view of the network can be charted from the dumps. It has the number of
+// connected peers, split by direction, and the number of peers for each
+// network group and software family.
+type TopologySnapshotRecord struct {
+	Record
+
+	peers    int
+	inbound  int
+	outbound int
+	groups   map[string]uint64
+	software map[string]uint64
+}
+
+func NewTopologySnapshotRecord(stamp time.Time, inbound int, outbound int,
+	groups map[string]uint64,
+	software map[string]uint64) *TopologySnapshotRecord {
+	record := &TopologySnapshotRecord{
+		Record: Record{
+			stamp: stamp,
+			ra:    &net.TCPAddr{},
+			la:    &net.TCPAddr{},
+			cmd:   CmdTopology,
+		},
+
+		peers:    inbound + outbound,
+		inbound:  inbound,
+		outbound: outbound,
+		groups:   groups,
+		software: software,
+	}
+
+	return record
+}
+
+func (tr *TopologySnapshotRecord) String() string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(tr.stamp.Format(time.RFC3339Nano))
+	buf.WriteString(Delimiter1)
+	buf.WriteString(tr.cmd)
+	buf.WriteString(Delimiter1)
+	buf.WriteString(tr.ra.String())
+	buf.WriteString(Delimiter1)
+	buf.WriteString(tr.la.String())
+	buf.WriteString(Delimiter1)
+	buf.WriteString(strconv.Itoa(tr.peers))
+	buf.WriteString(Delimiter1)
+	buf.WriteString(strconv.Itoa(tr.inbound))
+	buf.WriteString(Delimiter1)
+	buf.WriteString(strconv.Itoa(tr.outbound))
+	buf.WriteString(Delimiter1)
+	writeCounts(buf, tr.groups)
+	buf.WriteString(Delimiter1)
+	writeCounts(buf, tr.software)
+
+	return buf.String()
+}
+
+// writeCounts writes the counts in key order, so that the output is stable.
+func writeCounts(buf *bytes.Buffer, counts map[string]uint64) {
+	for i, key := range sortedKeys(counts) {
+		if i > 0 {
+			buf.WriteString(Delimiter2)
+		}
+
+		buf.WriteString(key)
+		buf.WriteString(":")
+		buf.WriteString(strconv.FormatUint(counts[key], 10))
+	}
+}
+
+// sortedKeys returns the keys of the counts in order.
+func sortedKeys(counts map[string]uint64) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Peers returns the number of connected peers.
+func (tr *TopologySnapshotRecord) Peers() int {
+	return tr.peers
+}
+
+// Inbound returns the number of peers that connected to us.
+func (tr *TopologySnapshotRecord) Inbound() int {
+	return tr.inbound
+}
+
+// Outbound returns the number of peers we connected to.
+func (tr *TopologySnapshotRecord) Outbound() int {
+	return tr.outbound
+}
+
+// Groups returns the number of peers for each network group.
+func (tr *TopologySnapshotRecord) Groups() map[string]uint64 {
+	return tr.groups
+}
+
+// Software returns the number of peers for each software family.
+func (tr *TopologySnapshotRecord) Software() map[string]uint64 {
+	return tr.software
+}
+
+// Bytes returns the binary representation of the snapshot: the inbound and
+// outbound counts, then the group count followed by each group with its peer
+// count, and the same for the software families, sorted by name.
+func (tr *TopologySnapshotRecord) Bytes() []byte {
+	buf := putUint32(nil, uint32(tr.inbound))
+	buf = putUint32(buf, uint32(tr.outbound))
+	buf = putCounts(buf, tr.groups)
+	buf = putCounts(buf, tr.software)
+
+	return buf
+}
+
+// putCounts appends the number of counts followed by each key with its count,
+// in key order.
+func putCounts(buf []byte, counts map[string]uint64) []byte {
+	buf = putUint16(buf, uint16(len(counts)))
+	for _, key := range sortedKeys(counts) {
+		buf = putString(buf, key)
+		buf = putUint64(buf, counts[key])
+	}
+
+	return buf
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (tr *TopologySnapshotRecord) Proto() ([]byte, error) {
+	return marshal(tr.protoMessage())
+}
+
+func (tr *TopologySnapshotRecord) protoMessage() *pb.Record {
+	body := &pb.Topology{
+		Peers:    int32(tr.peers),
+		Inbound:  int32(tr.inbound),
+		Outbound: int32(tr.outbound),
+		Groups:   tr.groups,
+		Software: tr.software,
+	}
+
+	msg := tr.proto()
+	msg.Body = &pb.Record_Topology{Topology: body}
+
+	return msg
+}
+
+func (tr *TopologySnapshotRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	groups := flatCounts(b, tr.groups)
+	software := flatCounts(b, tr.software)
+
+	fb.TopologyStart(b)
+	fb.TopologyAddPeers(b, int32(tr.peers))
+	fb.TopologyAddInbound(b, int32(tr.inbound))
+	fb.TopologyAddOutbound(b, int32(tr.outbound))
+	fb.TopologyAddGroups(b, groups)
+	fb.TopologyAddSoftware(b, software)
+
+	return fb.BodyTopology, fb.TopologyEnd(b)
+}
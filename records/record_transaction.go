@@ -22,23 +22,46 @@ package records
 
 import (
 	"bytes"
+	"encoding/hex"
 	"net"
+	"strconv"
 	"time"
 
 	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/adaptor"
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
+)
+
+const (
+	ClassFirstSeen = "FIRST_SEEN"
+	ClassDuplicate = "DUPLICATE"
 )
 
 type TransactionRecord struct {
 	Record
 
 	details *DetailsRecord
+
+	tracked   bool
+	duplicate bool
+	peers     int
+	since     time.Duration
+
+	announcers []adaptor.Announcement
+
+	segwit  bool
+	wtxid   [32]byte
+	witness []uint32
 }
 
 func NewTransactionRecord(msg *wire.MsgTx, ra *net.TCPAddr,
-	la *net.TCPAddr) *TransactionRecord {
+	la *net.TCPAddr, stamp time.Time) *TransactionRecord {
 	record := &TransactionRecord{
 		Record: Record{
-			stamp: time.Now(),
+			stamp: stamp,
 			ra:    ra,
 			la:    la,
 			cmd:   msg.Command(),
@@ -47,9 +70,35 @@ func NewTransactionRecord(msg *wire.MsgTx, ra *net.TCPAddr,
 		details: NewDetailsRecord(msg),
 	}
 
+	// without witness, the witness hash is the transaction id
+	record.wtxid = record.details.hash
+
 	return record
 }
 
+// Hash returns the transaction id, which is the hash of the transaction
+// without its witness.
+func (tr *TransactionRecord) Hash() [32]byte {
+	return tr.details.hash
+}
+
+// WitnessHash returns the hash of the transaction including its witness. It
+// equals the transaction id for transactions without witness.
+func (tr *TransactionRecord) WitnessHash() [32]byte {
+	return tr.wtxid
+}
+
+// Segwit returns whether the transaction came with witness data.
+func (tr *TransactionRecord) Segwit() bool {
+	return tr.segwit
+}
+
+// WitnessSizes returns the size in bytes of the witness of each input,
+// including the count of its items, or nil for transactions without witness.
+func (tr *TransactionRecord) WitnessSizes() []uint32 {
+	return tr.witness
+}
+
 func (tr *TransactionRecord) String() string {
 	buf := new(bytes.Buffer)
 	buf.WriteString(tr.stamp.Format(time.RFC3339Nano))
@@ -62,9 +111,81 @@ func (tr *TransactionRecord) String() string {
 	buf.WriteString(Delimiter1)
 	buf.WriteString(tr.details.String())
 
+	if tr.tracked {
+		buf.WriteString(Delimiter1)
+		if tr.duplicate {
+			buf.WriteString(ClassDuplicate)
+		} else {
+			buf.WriteString(ClassFirstSeen)
+		}
+		buf.WriteString(Delimiter1)
+		buf.WriteString(strconv.Itoa(tr.peers))
+		buf.WriteString(Delimiter1)
+		since := int64(tr.since / time.Millisecond)
+		buf.WriteString(strconv.FormatInt(since, 10))
+	}
+
+	if len(tr.announcers) > 0 {
+		buf.WriteString(Delimiter1)
+		for i, ann := range tr.announcers {
+			if i > 0 {
+				buf.WriteString(Delimiter2)
+			}
+
+			buf.WriteString(ann.Peer)
+			buf.WriteString("@")
+			buf.WriteString(strconv.FormatInt(tr.offset(ann), 10))
+		}
+	}
+
+	if tr.segwit {
+		buf.WriteString(Delimiter1)
+		buf.WriteString(hex.EncodeToString(tr.wtxid[:]))
+		for _, size := range tr.witness {
+			buf.WriteString(Delimiter2)
+			buf.WriteString(strconv.FormatUint(uint64(size), 10))
+		}
+	}
+
 	return buf.String()
 }
 
+// SetWitness marks the record as segwit transaction and adds its witness hash
+// and the witness size of each input. The wire package only decodes stripped
+// transactions, so the witness is decoded by the peer instead. In the output,
+// the witness hash is followed by the sizes.
+func (tr *TransactionRecord) SetWitness(wtxid [32]byte, sizes []uint32) {
+	tr.segwit = true
+	tr.wtxid = wtxid
+	tr.witness = sizes
+}
+
+// SetPropagation adds the propagation details from the tracker to the record:
+// whether the transaction was received before, the number of peers that have
+// announced it and the time since it was first seen. These fields are only
+// part of the output if they were set.
+func (tr *TransactionRecord) SetPropagation(duplicate bool, peers int,
+	first time.Time) {
+	tr.tracked = true
+	tr.duplicate = duplicate
+	tr.peers = peers
+	tr.since = tr.stamp.Sub(first)
+}
+
+// SetAnnouncers adds the first peers that announced the transaction to the
+// record. In the output, each peer is followed by the time of its announcement
+// in milliseconds, relative to when the transaction was first seen.
+func (tr *TransactionRecord) SetAnnouncers(announcers []adaptor.Announcement) {
+	tr.announcers = announcers
+}
+
+// offset returns the time of an announcement in milliseconds since the
+// transaction was first seen.
+func (tr *TransactionRecord) offset(ann adaptor.Announcement) int64 {
+	first := tr.stamp.Add(-tr.since)
+	return int64(ann.Stamp.Sub(first) / time.Millisecond)
+}
+
 func (tr *TransactionRecord) HasAddress(addr string) bool {
 	for _, out := range tr.details.outs {
 		for _, a := range out.addrs {
@@ -76,3 +197,122 @@ func (tr *TransactionRecord) HasAddress(addr string) bool {
 
 	return false
 }
+
+// Bytes returns the binary representation of the transaction: its details,
+// then the propagation flags, peer count and time since first seen in
+// nanoseconds, the announcer count and each announcer with its offset in
+// milliseconds, and finally the segwit flag, the witness hash and the count
+// and witness size of each input.
+func (tr *TransactionRecord) Bytes() []byte {
+	buf := tr.details.Bytes()
+	buf = putBool(buf, tr.tracked)
+	buf = putBool(buf, tr.duplicate)
+	buf = putUint32(buf, uint32(tr.peers))
+	buf = putUint64(buf, uint64(tr.since))
+	buf = putUint16(buf, uint16(len(tr.announcers)))
+	for _, ann := range tr.announcers {
+		buf = putString(buf, ann.Peer)
+		buf = putUint64(buf, uint64(tr.offset(ann)))
+	}
+
+	buf = putBool(buf, tr.segwit)
+	buf = append(buf, tr.wtxid[:]...)
+	buf = putUint16(buf, uint16(len(tr.witness)))
+	for _, size := range tr.witness {
+		buf = putUint32(buf, size)
+	}
+
+	return buf
+}
+
+// HasNullData returns whether the transaction has an OP_RETURN output whose
+// embedded data starts with the given prefix. An empty prefix matches any
+// OP_RETURN output.
+func (tr *TransactionRecord) HasNullData(prefix []byte) bool {
+	for _, out := range tr.details.outs {
+		if !out.nullData {
+			continue
+		}
+
+		if bytes.HasPrefix(out.data, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (tr *TransactionRecord) Proto() ([]byte, error) {
+	return marshal(tr.protoMessage())
+}
+
+func (tr *TransactionRecord) protoMessage() *pb.Record {
+	body := &pb.Transaction{
+		Details:   tr.details.proto(),
+		Tracked:   tr.tracked,
+		Duplicate: tr.duplicate,
+		Peers:     int32(tr.peers),
+		Since:     int64(tr.since),
+		Segwit:    tr.segwit,
+		Witness:   tr.witness,
+	}
+
+	if tr.segwit {
+		body.Wtxid = tr.wtxid[:]
+	}
+
+	for _, ann := range tr.announcers {
+		body.Announcers = append(body.Announcers, &pb.Announcer{
+			Peer:   ann.Peer,
+			Offset: tr.offset(ann) * int64(time.Millisecond),
+		})
+	}
+
+	msg := tr.proto()
+	msg.Body = &pb.Record_Tx{Tx: body}
+
+	return msg
+}
+
+func (tr *TransactionRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	details := tr.details.flat(b)
+	anns := make([]flatbuffers.UOffsetT, 0, len(tr.announcers))
+	for _, ann := range tr.announcers {
+		peer := b.CreateString(ann.Peer)
+		fb.AnnouncerStart(b)
+		fb.AnnouncerAddPeer(b, peer)
+		fb.AnnouncerAddOffset(b, tr.offset(ann)*int64(time.Millisecond))
+		anns = append(anns, fb.AnnouncerEnd(b))
+	}
+
+	vec := flatTables(b, anns)
+
+	var wtxid, witness flatbuffers.UOffsetT
+	if tr.segwit {
+		wtxid = b.CreateByteVector(tr.wtxid[:])
+		b.StartVector(4, len(tr.witness), 4)
+		for i := len(tr.witness) - 1; i >= 0; i-- {
+			b.PrependUint32(tr.witness[i])
+		}
+
+		witness = b.EndVector(len(tr.witness))
+	}
+
+	fb.TxStart(b)
+	fb.TxAddDetails(b, details)
+	fb.TxAddTracked(b, tr.tracked)
+	fb.TxAddDuplicate(b, tr.duplicate)
+	fb.TxAddPeers(b, int32(tr.peers))
+	fb.TxAddSince(b, int64(tr.since))
+	fb.TxAddAnnouncers(b, vec)
+	fb.TxAddSegwit(b, tr.segwit)
+	if tr.segwit {
+		fb.TxAddWtxid(b, wtxid)
+		fb.TxAddWitness(b, witness)
+	}
+
+	return fb.BodyTx, fb.TxEnd(b)
+}
@@ -26,6 +26,10 @@ import (
 	"time"
 
 	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
 )
 
 type VerAckRecord struct {
@@ -33,10 +37,10 @@ type VerAckRecord struct {
 }
 
 func NewVerAckRecord(msg *wire.MsgVerAck, ra *net.TCPAddr,
-	la *net.TCPAddr) *VerAckRecord {
+	la *net.TCPAddr, stamp time.Time) *VerAckRecord {
 	record := &VerAckRecord{
 		Record: Record{
-			stamp: time.Now(),
+			stamp: stamp,
 			ra:    ra,
 			la:    la,
 			cmd:   msg.Command(),
@@ -58,3 +62,24 @@ func (vr *VerAckRecord) String() string {
 
 	return buf.String()
 }
+
+// Bytes returns an empty payload, as verack messages have none.
+func (vr *VerAckRecord) Bytes() []byte {
+	return []byte{}
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (vr *VerAckRecord) Proto() ([]byte, error) {
+	return marshal(vr.protoMessage())
+}
+
+func (vr *VerAckRecord) protoMessage() *pb.Record {
+	return vr.protoEmpty()
+}
+
+func (vr *VerAckRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	fb.VerAckStart(b)
+	return fb.BodyVerAck, fb.VerAckEnd(b)
+}
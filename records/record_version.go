@@ -26,9 +26,12 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/CIRCL/pbtc/records/fb"
+	"github.com/CIRCL/pbtc/records/pb"
 	"github.com/CIRCL/pbtc/util"
 
 	"github.com/btcsuite/btcd/wire"
+	flatbuffers "github.com/google/flatbuffers/go"
 )
 
 type VersionRecord struct {
@@ -46,10 +49,10 @@ type VersionRecord struct {
 }
 
 func NewVersionRecord(msg *wire.MsgVersion, ra *net.TCPAddr,
-	la *net.TCPAddr) *VersionRecord {
+	la *net.TCPAddr, stamp time.Time) *VersionRecord {
 	vr := &VersionRecord{
 		Record: Record{
-			stamp: time.Now(),
+			stamp: stamp,
 			ra:    ra,
 			la:    la,
 			cmd:   msg.Command(),
@@ -99,3 +102,82 @@ func (vr *VersionRecord) String() string {
 
 	return buf.String()
 }
+
+// Advertised returns the address the sender advertised for itself.
+func (vr *VersionRecord) Advertised() *net.TCPAddr {
+	return vr.laddr
+}
+
+// Misadvertised returns whether the address the sender advertised for itself
+// differs from the remote address we received the message from.
+func (vr *VersionRecord) Misadvertised() bool {
+	return util.IsMisadvertised(vr.ra, vr.laddr)
+}
+
+// Bytes returns the binary representation of the version message: protocol
+// version, services, timestamp in unix seconds, both addresses, user agent,
+// block height, relay flag and nonce.
+func (vr *VersionRecord) Bytes() []byte {
+	buf := putUint32(nil, uint32(vr.version))
+	buf = putUint64(buf, vr.services)
+	buf = putUint64(buf, uint64(vr.sent.Unix()))
+	buf = putAddr(buf, vr.raddr)
+	buf = putAddr(buf, vr.laddr)
+	buf = putString(buf, vr.agent)
+	buf = putUint32(buf, uint32(vr.block))
+	buf = putBool(buf, vr.relay)
+	buf = putUint64(buf, vr.nonce)
+
+	return buf
+}
+
+// Proto returns the record encoded as protobuf message, as described in
+// record.proto.
+func (vr *VersionRecord) Proto() ([]byte, error) {
+	return marshal(vr.protoMessage())
+}
+
+func (vr *VersionRecord) protoMessage() *pb.Record {
+	body := &pb.Version{
+		Version:  vr.version,
+		Services: vr.services,
+		Remote:   vr.raddr.String(),
+		Local:    vr.laddr.String(),
+		Agent:    vr.agent,
+		Block:    vr.block,
+		Relay:    vr.relay,
+		Nonce:    vr.nonce,
+	}
+
+	if !vr.sent.IsZero() {
+		body.Sent = vr.sent.UnixNano()
+	}
+
+	msg := vr.proto()
+	msg.Body = &pb.Record_Version{Version: body}
+
+	return msg
+}
+
+func (vr *VersionRecord) flat(
+	b *flatbuffers.Builder) (fb.Body, flatbuffers.UOffsetT) {
+	remote := b.CreateString(vr.raddr.String())
+	local := b.CreateString(vr.laddr.String())
+	agent := b.CreateString(vr.agent)
+
+	fb.VersionStart(b)
+	fb.VersionAddVersion(b, vr.version)
+	fb.VersionAddServices(b, vr.services)
+	if !vr.sent.IsZero() {
+		fb.VersionAddSent(b, vr.sent.UnixNano())
+	}
+
+	fb.VersionAddRemote(b, remote)
+	fb.VersionAddLocal(b, local)
+	fb.VersionAddAgent(b, agent)
+	fb.VersionAddBlock(b, vr.block)
+	fb.VersionAddRelay(b, vr.relay)
+	fb.VersionAddNonce(b, vr.nonce)
+
+	return fb.BodyVersion, fb.VersionEnd(b)
+}
@@ -0,0 +1,219 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package replay reads back records written by the file writer, so that
+// captures can be analysed offline.
+package replay
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/CIRCL/pbtc/records"
+)
+
+var (
+	errMagic   = errors.New("file is not a compact record file")
+	errVersion = errors.New("compact record file has unsupported version")
+	errEntry   = errors.New("compact record file has unknown entry")
+	errIndex   = errors.New("compact record refers to undefined string")
+	errRecord  = errors.New("compact record is truncated")
+	errAddress = errors.New("compact record has invalid address")
+)
+
+// Entry is a record read back from a compact file. The payload is what the
+// Bytes method of the original record returned. The location is only known
+// for files of version 2 or later.
+type Entry struct {
+	Stamp   time.Time
+	Command string
+	Remote  *net.TCPAddr
+	Local   *net.TCPAddr
+	Tag     string
+	Country string
+	ASN     uint32
+	Payload []byte
+}
+
+// Reader reads the records of a compact file, as written by the file writer
+// with the compact encoding.
+type Reader struct {
+	r       *bufio.Reader
+	dict    []string
+	version byte
+}
+
+// NewReader returns a reader for the compact file read from r, after checking
+// its header.
+func NewReader(r io.Reader) (*Reader, error) {
+	rd := &Reader{
+		r:    bufio.NewReader(r),
+		dict: []string{""},
+	}
+
+	header := make([]byte, len(records.CompactMagic)+1)
+	_, err := io.ReadFull(rd.r, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(header[:len(records.CompactMagic)]) != records.CompactMagic {
+		return nil, errMagic
+	}
+
+	rd.version = header[len(records.CompactMagic)]
+	if rd.version < 1 || rd.version > records.CompactVersion {
+		return nil, errVersion
+	}
+
+	return rd, nil
+}
+
+// Next returns the next record of the file. It returns io.EOF once all records
+// have been read.
+func (rd *Reader) Next() (*Entry, error) {
+	for {
+		kind, err := rd.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := binary.ReadUvarint(rd.r)
+		if err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		content := make([]byte, size)
+		_, err = io.ReadFull(rd.r, content)
+		if err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		switch kind {
+		case records.CompactDefine:
+			rd.dict = append(rd.dict, string(content))
+
+		case records.CompactRecord:
+			return rd.decode(content)
+
+		default:
+			return nil, errEntry
+		}
+	}
+}
+
+// decode decodes the content of a record entry.
+func (rd *Reader) decode(content []byte) (*Entry, error) {
+	if len(content) < 8 {
+		return nil, errRecord
+	}
+
+	stamp := int64(binary.LittleEndian.Uint64(content[0:8]))
+	content = content[8:]
+
+	// the command, addresses and tag, followed by the country since version 2
+	strs := make([]string, 4, 5)
+	if rd.version >= 2 {
+		strs = strs[:5]
+	}
+
+	for i := range strs {
+		index, n := binary.Uvarint(content)
+		if n <= 0 {
+			return nil, errRecord
+		}
+
+		if index >= uint64(len(rd.dict)) {
+			return nil, errIndex
+		}
+
+		strs[i] = rd.dict[index]
+		content = content[n:]
+	}
+
+	entry := &Entry{
+		Stamp:   time.Unix(0, stamp),
+		Command: strs[0],
+		Tag:     strs[3],
+	}
+
+	if rd.version >= 2 {
+		asn, n := binary.Uvarint(content)
+		if n <= 0 || asn > math.MaxUint32 {
+			return nil, errRecord
+		}
+
+		entry.Country = strs[4]
+		entry.ASN = uint32(asn)
+		content = content[n:]
+	}
+
+	size, n := binary.Uvarint(content)
+	if n <= 0 || uint64(len(content)-n) != size {
+		return nil, errRecord
+	}
+
+	remote, err := parseAddr(strs[1])
+	if err != nil {
+		return nil, err
+	}
+
+	local, err := parseAddr(strs[2])
+	if err != nil {
+		return nil, err
+	}
+
+	entry.Remote = remote
+	entry.Local = local
+	entry.Payload = content[n:]
+
+	return entry, nil
+}
+
+// parseAddr parses an address written by the encoder, without resolving any
+// names. The empty string stands for no address.
+func parseAddr(s string) (*net.TCPAddr, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return nil, err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, errAddress
+	}
+
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return nil, err
+	}
+
+	return &net.TCPAddr{IP: ip, Port: p}, nil
+}
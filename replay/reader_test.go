@@ -0,0 +1,104 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package replay
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/CIRCL/pbtc/records"
+)
+
+func TestReaderLocation(t *testing.T) {
+	ra := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 8333}
+	la := &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 50000}
+	ping := records.NewPingRecord(wire.NewMsgPing(7), ra, la, time.Unix(1, 0))
+	record := records.NewTaggedRecord(records.NewGeoRecord(ping, "LU", 6661),
+		"capture")
+
+	file := records.CompactHeader()
+	e := records.NewCompactEncoder()
+	file = append(file, e.Encode(record)...)
+	file = append(file, e.Encode(ping)...)
+
+	rd, err := NewReader(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := rd.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entry.Tag != "capture" || entry.Country != "LU" || entry.ASN != 6661 {
+		t.Errorf("got tag %q, country %q, ASN %v", entry.Tag, entry.Country,
+			entry.ASN)
+	}
+
+	if !bytes.Equal(entry.Payload, ping.Bytes()) {
+		t.Errorf("got payload %x, want %x", entry.Payload, ping.Bytes())
+	}
+
+	entry, err = rd.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entry.Tag != "" || entry.Country != "" || entry.ASN != 0 {
+		t.Errorf("got tag %q, country %q, ASN %v", entry.Tag, entry.Country,
+			entry.ASN)
+	}
+
+	_, err = rd.Next()
+	if err != io.EOF {
+		t.Errorf("got %v after last record, want EOF", err)
+	}
+}
+
+func TestReaderVersion1(t *testing.T) {
+	file := append([]byte(records.CompactMagic), 1)
+	file = append(file, records.CompactDefine, 4)
+	file = append(file, "ping"...)
+	file = append(file, records.CompactRecord, 14)
+	file = append(file, 0, 0, 0, 0, 0, 0, 0, 0)
+	file = append(file, 1, 0, 0, 0, 1, 7)
+
+	rd, err := NewReader(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := rd.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entry.Command != "ping" || entry.Country != "" ||
+		!bytes.Equal(entry.Payload, []byte{7}) {
+		t.Errorf("got %+v", entry)
+	}
+}
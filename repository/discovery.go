@@ -0,0 +1,87 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package repository
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// discoveryBuffer is the number of discoveries buffered for each subscriber.
+const discoveryBuffer = 1024
+
+// Discovery describes an address that was added to the repository for the
+// first time. Source is the peer that told us about it, or nil if it came from
+// a DNS seed, and Stamp is the time the source advertised for the address.
+type Discovery struct {
+	Addr   *net.TCPAddr
+	Source *net.TCPAddr
+	Stamp  time.Time
+}
+
+// SubscribeDiscoveries returns a stream of the addresses that are new to the
+// repository, which is lighter than recording all messages when we only want
+// to map the topology. The stream is buffered; if a subscriber does not keep
+// up, further discoveries are dropped for it and counted. The channel is
+// closed when the repository stops.
+func (repo *Repository) SubscribeDiscoveries() <-chan Discovery {
+	c := make(chan Discovery, discoveryBuffer)
+
+	repo.subMutex.Lock()
+	defer repo.subMutex.Unlock()
+
+	repo.subscribers = append(repo.subscribers, c)
+
+	return c
+}
+
+// DroppedDiscoveries returns the number of discoveries that were dropped
+// because a subscriber did not keep up.
+func (repo *Repository) DroppedDiscoveries() uint64 {
+	return atomic.LoadUint64(&repo.subDropped)
+}
+
+// publish sends a discovery to all subscribers without blocking.
+func (repo *Repository) publish(d Discovery) {
+	repo.subMutex.Lock()
+	defer repo.subMutex.Unlock()
+
+	for _, c := range repo.subscribers {
+		select {
+		case c <- d:
+		default:
+			atomic.AddUint64(&repo.subDropped, 1)
+		}
+	}
+}
+
+// unsubscribeAll closes the streams of all subscribers.
+func (repo *Repository) unsubscribeAll() {
+	repo.subMutex.Lock()
+	defer repo.subMutex.Unlock()
+
+	for _, c := range repo.subscribers {
+		close(c)
+	}
+
+	repo.subscribers = nil
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package repository
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSubscribeDiscoveries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nodes.dat")
+	repo := newTestRepository(t, SetBackupPath(path))
+	repo.Start()
+
+	discoveries := repo.SubscribeDiscoveries()
+	slow := repo.SubscribeDiscoveries()
+
+	src := &net.TCPAddr{IP: net.IPv4(9, 9, 9, 9), Port: 8333}
+	stamp := time.Unix(1443650000, 0)
+	first := &net.TCPAddr{IP: net.IPv4(8, 8, 8, 8), Port: 8333}
+	second := &net.TCPAddr{IP: net.IPv4(8, 8, 4, 4), Port: 8333}
+
+	// the duplicate comes in between, so it would show up before the second
+	repo.Discovered(first, src, stamp)
+	repo.Discovered(first, src, stamp.Add(time.Minute))
+	repo.Discovered(second, src, stamp)
+
+	for _, addr := range []*net.TCPAddr{first, second} {
+		select {
+		case d := <-discoveries:
+			if d.Addr.String() != addr.String() ||
+				d.Source.String() != src.String() || !d.Stamp.Equal(stamp) {
+				t.Errorf("discovery %+v instead of %v", d, addr)
+			}
+
+		case <-time.After(time.Second):
+			t.Fatalf("no discovery for %v", addr)
+		}
+	}
+
+	// a subscriber that does not keep up loses discoveries, but does not
+	// hold up the others
+	for i := 0; i < discoveryBuffer; i++ {
+		addr := &net.TCPAddr{IP: net.IPv4(8, 9, byte(i>>8), byte(i)),
+			Port: 8333}
+		repo.Discovered(addr, src, stamp)
+		<-discoveries
+	}
+
+	repo.Stop()
+
+	if repo.DroppedDiscoveries() != 2 {
+		t.Errorf("dropped %v discoveries", repo.DroppedDiscoveries())
+	}
+
+	if len(slow) != discoveryBuffer {
+		t.Errorf("%v discoveries buffered", len(slow))
+	}
+
+	_, ok := <-discoveries
+	if ok {
+		t.Error("stream not closed on stop")
+	}
+}
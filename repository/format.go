@@ -0,0 +1,223 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package repository
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+type BackupFormat int
+
+const (
+	BinaryFormat BackupFormat = iota
+	GobFormat
+)
+
+// ParseFormat returns the backup format for the given configuration string.
+func ParseFormat(format string) (BackupFormat, error) {
+	switch format {
+	case "BINARY":
+		return BinaryFormat, nil
+
+	case "GOB":
+		return GobFormat, nil
+
+	default:
+		return -1, errors.New("invalid backup format string")
+	}
+}
+
+// The binary node file starts with a header made of the magic number and the
+// format version. It is followed by the payload, made of the node count and
+// the node records, and ends with the CRC32 (IEEE) of the payload. All numbers
+// are big endian. Each node record is 54 bytes long:
+//
+// network         4 bytes
+// ip             16 bytes (IPv4 addresses are IPv4-mapped)
+// port            2 bytes
+// seen            4 bytes
+// attempts        4 bytes
+// last attempted  8 bytes (unix nanoseconds, zero if never)
+// last connected  8 bytes (unix nanoseconds, zero if never)
+// last succeeded  8 bytes (unix nanoseconds, zero if never)
+const (
+	fileMagic   = 0x50425443 // "PBTC"
+	fileVersion = 1
+	headerSize  = 5
+	recordSize  = 54
+)
+
+var (
+	errFileMagic    = errors.New("node file has no valid magic")
+	errFileVersion  = errors.New("node file has unsupported version")
+	errFileSize     = errors.New("node file is truncated")
+	errFileChecksum = errors.New("node file checksum mismatch")
+	errFileCount    = errors.New("node file has too many nodes")
+)
+
+// hasMagic checks whether the given data starts with the magic number of the
+// binary node file.
+func hasMagic(data []byte) bool {
+	return len(data) >= 4 && binary.BigEndian.Uint32(data[0:4]) == fileMagic
+}
+
+// encodeNodes serializes the given nodes into the binary node file format.
+func encodeNodes(nodes []*node) []byte {
+	payload := make([]byte, 4, 4+len(nodes)*recordSize)
+	binary.BigEndian.PutUint32(payload, uint32(len(nodes)))
+
+	record := make([]byte, recordSize)
+	for _, n := range nodes {
+		binary.BigEndian.PutUint32(record[0:4], uint32(n.network))
+		copy(record[4:20], n.addr.IP.To16())
+		binary.BigEndian.PutUint16(record[20:22], uint16(n.addr.Port))
+		binary.BigEndian.PutUint32(record[22:26], n.numSeen)
+		binary.BigEndian.PutUint32(record[26:30], n.numAttempts)
+		binary.BigEndian.PutUint64(record[30:38], encodeTime(n.lastAttempted))
+		binary.BigEndian.PutUint64(record[38:46], encodeTime(n.lastConnected))
+		binary.BigEndian.PutUint64(record[46:54], encodeTime(n.lastSucceeded))
+		payload = append(payload, record...)
+	}
+
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, uint32(fileMagic))
+	buf.WriteByte(fileVersion)
+	buf.Write(payload)
+	binary.Write(buf, binary.BigEndian, crc32.ChecksumIEEE(payload))
+
+	return buf.Bytes()
+}
+
+// decodeNodes parses the nodes from data in the binary node file format. It
+// refuses data with the wrong magic, version or checksum.
+func decodeNodes(data []byte) ([]*node, error) {
+	if !hasMagic(data) {
+		return nil, errFileMagic
+	}
+
+	if len(data) < headerSize+4+4 {
+		return nil, errFileSize
+	}
+
+	if data[4] != fileVersion {
+		return nil, errFileVersion
+	}
+
+	payload := data[headerSize : len(data)-4]
+	checksum := binary.BigEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return nil, errFileChecksum
+	}
+
+	count := int(binary.BigEndian.Uint32(payload[0:4]))
+	if len(payload) != 4+count*recordSize {
+		return nil, errFileSize
+	}
+
+	nodes := make([]*node, 0, count)
+	for i := 0; i < count; i++ {
+		record := payload[4+i*recordSize : 4+(i+1)*recordSize]
+
+		ip := make(net.IP, net.IPv6len)
+		copy(ip, record[4:20])
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+
+		n := &node{
+			network: wire.BitcoinNet(binary.BigEndian.Uint32(record[0:4])),
+			addr: &net.TCPAddr{
+				IP:   ip,
+				Port: int(binary.BigEndian.Uint16(record[20:22])),
+			},
+			numSeen:       binary.BigEndian.Uint32(record[22:26]),
+			numAttempts:   binary.BigEndian.Uint32(record[26:30]),
+			lastAttempted: decodeTime(binary.BigEndian.Uint64(record[30:38])),
+			lastConnected: decodeTime(binary.BigEndian.Uint64(record[38:46])),
+			lastSucceeded: decodeTime(binary.BigEndian.Uint64(record[46:54])),
+		}
+
+		nodes = append(nodes, n)
+	}
+
+	return nodes, nil
+}
+
+// readNodes reads exactly one message in the binary node file format from the
+// given reader, so that several of them can follow each other on a stream.
+func readNodes(r io.Reader, limit uint32) ([]*node, error) {
+	head := make([]byte, headerSize+4)
+	_, err := io.ReadFull(r, head)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasMagic(head) {
+		return nil, errFileMagic
+	}
+
+	if head[4] != fileVersion {
+		return nil, errFileVersion
+	}
+
+	// the count comes from the peer on the other end of the stream, so we
+	// check it before allocating the buffer for the records
+	count := binary.BigEndian.Uint32(head[headerSize:])
+	if count > limit {
+		return nil, errFileCount
+	}
+
+	data := make([]byte, len(head)+int(count)*recordSize+4)
+	copy(data, head)
+	_, err = io.ReadFull(r, data[len(head):])
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeNodes(data)
+}
+
+func encodeTime(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return uint64(t.UnixNano())
+}
+
+func decodeTime(stamp uint64) time.Time {
+	if stamp == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, int64(stamp))
+}
@@ -0,0 +1,211 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package repository
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+func testNodes() []*node {
+	v4 := newNode(wire.TestNet3, &net.TCPAddr{IP: net.IPv4(8, 8, 8, 8),
+		Port: 18333})
+	v4.numAttempts = 2
+	v4.lastAttempted = time.Unix(1400000000, 5)
+
+	v6 := newNode(wire.MainNet, &net.TCPAddr{IP: net.ParseIP("2001:db8::1"),
+		Port: 8333})
+	v6.numSeen = 7
+	v6.lastConnected = time.Unix(1400000100, 0)
+	v6.lastSucceeded = time.Unix(1400000200, 0)
+
+	return []*node{v4, v6}
+}
+
+func TestEncodeNodes(t *testing.T) {
+	nodes := testNodes()
+	decoded, err := decodeNodes(encodeNodes(nodes))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(decoded) != len(nodes) {
+		t.Fatalf("decoded %v nodes", len(decoded))
+	}
+
+	for i, n := range nodes {
+		d := decoded[i]
+		if d.network != n.network || d.addr.String() != n.addr.String() ||
+			d.numSeen != n.numSeen || d.numAttempts != n.numAttempts ||
+			!d.lastAttempted.Equal(n.lastAttempted) ||
+			!d.lastConnected.Equal(n.lastConnected) ||
+			!d.lastSucceeded.Equal(n.lastSucceeded) {
+			t.Errorf("decoded %+v instead of %+v", d, n)
+		}
+	}
+
+	// messages can follow each other on a stream
+	buf := bytes.NewBuffer(encodeNodes(nodes[:1]))
+	buf.Write(encodeNodes(nodes[1:]))
+	for i := range nodes {
+		read, err := readNodes(buf, 1)
+		if err != nil || len(read) != 1 ||
+			read[0].addr.String() != nodes[i].addr.String() {
+			t.Errorf("read %v from stream (%v)", read, err)
+		}
+	}
+}
+
+func TestDecodeNodesInvalid(t *testing.T) {
+	valid := encodeNodes(testNodes())
+
+	version := append([]byte{}, valid...)
+	version[4] = fileVersion + 1
+
+	corrupt := append([]byte{}, valid...)
+	corrupt[headerSize+10] ^= 0xff
+
+	magic := append([]byte{}, valid...)
+	magic[0] = 'X'
+
+	tests := map[string]struct {
+		data []byte
+		err  error
+	}{
+		"version":   {version, errFileVersion},
+		"corrupt":   {corrupt, errFileChecksum},
+		"magic":     {magic, errFileMagic},
+		"truncated": {valid[:headerSize+4+recordSize], errFileChecksum},
+		"empty":     {valid[:headerSize], errFileSize},
+	}
+
+	for name, test := range tests {
+		nodes, err := decodeNodes(test.data)
+		if err != test.err || nodes != nil {
+			t.Errorf("%v: decoded %v nodes (%v)", name, len(nodes), err)
+		}
+	}
+}
+
+func TestRestoreFallback(t *testing.T) {
+	valid := encodeNodes(testNodes())
+
+	version := append([]byte{}, valid...)
+	version[4] = fileVersion + 1
+
+	corrupt := append([]byte{}, valid...)
+	corrupt[len(corrupt)-1] ^= 0xff
+
+	// a broken node file falls back to the previous generation
+	for name, data := range map[string][]byte{
+		"version": version,
+		"corrupt": corrupt,
+	} {
+		path := filepath.Join(t.TempDir(), "nodes.dat")
+		err := ioutil.WriteFile(path, data, 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = ioutil.WriteFile(path+".bak", valid, 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		repo := newTestRepository(t, SetBackupPath(path))
+		repo.SetNetwork(wire.TestNet3)
+		repo.Start()
+
+		stats := repo.Stats()
+		if stats.Nodes != 1 || stats.Foreign != 1 {
+			t.Errorf("%v: restored %v nodes and %v foreign ones", name,
+				stats.Nodes, stats.Foreign)
+		}
+
+		repo.Stop()
+	}
+
+	// without a good generation, we start empty
+	path := filepath.Join(t.TempDir(), "nodes.dat")
+	err := ioutil.WriteFile(path, corrupt, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := newTestRepository(t, SetBackupPath(path))
+	repo.SetNetwork(wire.TestNet3)
+	repo.Start()
+	defer repo.Stop()
+
+	stats := repo.Stats()
+	if stats.Nodes != 0 || stats.Foreign != 0 {
+		t.Errorf("restored %v nodes and %v foreign ones from corrupt file",
+			stats.Nodes, stats.Foreign)
+	}
+}
+
+// TestRestoreTruncatedGob restores a node file in the old gob format that was
+// cut off in the middle of the list. None of the nodes decoded before the cut
+// may end up in the index.
+func TestRestoreTruncatedGob(t *testing.T) {
+	nodes := make([]*node, 0, 64)
+	for i := 0; i < 64; i++ {
+		nodes = append(nodes, newNode(wire.TestNet3,
+			&net.TCPAddr{IP: net.IPv4(8, 8, 8, byte(i)), Port: 18333}))
+	}
+
+	buf := new(bytes.Buffer)
+	err := gob.NewEncoder(buf).Encode(nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()[:buf.Len()*2/3]
+	decoded, err := decodeFile(data)
+	if err == nil || decoded != nil {
+		t.Errorf("decoded %v nodes from truncated file (%v)", len(decoded),
+			err)
+	}
+
+	path := filepath.Join(t.TempDir(), "nodes.dat")
+	err = ioutil.WriteFile(path, data, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := newTestRepository(t, SetBackupPath(path))
+	repo.SetNetwork(wire.TestNet3)
+	repo.Start()
+	defer repo.Stop()
+
+	stats := repo.Stats()
+	if stats.Nodes != 0 || stats.Foreign != 0 {
+		t.Errorf("restored %v nodes and %v foreign ones from truncated file",
+			stats.Nodes, stats.Foreign)
+	}
+}
@@ -23,21 +23,31 @@ package repository
 import (
 	"bytes"
 	"encoding/gob"
+	"io"
 	"net"
 	"time"
+
+	"github.com/btcsuite/btcd/wire"
 )
 
 type node struct {
+	network       wire.BitcoinNet
 	addr          *net.TCPAddr
 	numSeen       uint32
 	numAttempts   uint32
 	lastAttempted time.Time
 	lastConnected time.Time
 	lastSucceeded time.Time
+
+	// failures and the address advertised in the version message are only
+	// kept for the current session and not persisted
+	numFailures uint32
+	advertised  *net.TCPAddr
 }
 
-func newNode(addr *net.TCPAddr) *node {
+func newNode(network wire.BitcoinNet, addr *net.TCPAddr) *node {
 	n := &node{
+		network: network,
 		addr:    addr,
 		numSeen: 1,
 	}
@@ -82,6 +92,11 @@ func (node *node) GobEncode() ([]byte, error) {
 		return nil, err
 	}
 
+	err = enc.Encode(node.network)
+	if err != nil {
+		return nil, err
+	}
+
 	return buffer.Bytes(), nil
 }
 
@@ -116,5 +131,31 @@ func (node *node) GobDecode(buf []byte) error {
 		return err
 	}
 
+	// nodes saved before we kept track of the network have no network value,
+	// so they will never match the network of a repository
+	err = dec.Decode(&node.network)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
+
+// bySucceeded sorts nodes by their last successful connection, most recent
+// first.
+type bySucceeded []*node
+
+func (nodes bySucceeded) Len() int {
+	return len(nodes)
+}
+
+func (nodes bySucceeded) Less(i, j int) bool {
+	return nodes[i].lastSucceeded.After(nodes[j].lastSucceeded)
+}
+
+func (nodes bySucceeded) Swap(i, j int) {
+	nodes[i], nodes[j] = nodes[j], nodes[i]
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package repository
+
+import (
+	"errors"
+)
+
+// AddressPreference defines which IP family is favoured when handing out
+// nodes for connection on a dual-stack host.
+type AddressPreference int
+
+const (
+	NoPreference AddressPreference = iota
+	PreferIPv4
+	PreferIPv6
+	Balanced
+)
+
+// ParsePreference returns the address preference for the given configuration
+// string.
+func ParsePreference(preference string) (AddressPreference, error) {
+	switch preference {
+	case "NONE":
+		return NoPreference, nil
+
+	case "IPV4":
+		return PreferIPv4, nil
+
+	case "IPV6":
+		return PreferIPv6, nil
+
+	case "BALANCED":
+		return Balanced, nil
+
+	default:
+		return -1, errors.New("invalid address preference string")
+	}
+}
+
+type family int
+
+const (
+	familyAny family = iota
+	familyIPv4
+	familyIPv6
+)
+
+// matches returns whether the address of the node belongs to the family.
+func (f family) matches(n *node) bool {
+	switch f {
+	case familyIPv4:
+		return n.addr.IP.To4() != nil
+
+	case familyIPv6:
+		return n.addr.IP.To4() == nil
+
+	default:
+		return true
+	}
+}
+
+// families returns the IP families to select nodes from, in order of
+// preference. The preferred family comes first, but we fall back to the other
+// one if no node of the preferred family is eligible. When balancing, the
+// family that is short of its share of the handed out nodes comes first.
+func (repo *Repository) families() []family {
+	switch repo.preference {
+	case PreferIPv4:
+		return []family{familyIPv4, familyIPv6}
+
+	case PreferIPv6:
+		return []family{familyIPv6, familyIPv4}
+
+	case Balanced:
+		share := 0.0
+		total := repo.numIPv4 + repo.numIPv6
+		if total > 0 {
+			share = float64(repo.numIPv6) / float64(total)
+		}
+
+		if share < repo.ratio {
+			return []family{familyIPv6, familyIPv4}
+		}
+
+		return []family{familyIPv4, familyIPv6}
+
+	default:
+		return []family{familyAny}
+	}
+}
+
+// count remembers the family of a node that was handed out, so that balancing
+// can aim for the configured ratio.
+func (repo *Repository) count(n *node) {
+	if familyIPv6.matches(n) {
+		repo.numIPv6++
+		return
+	}
+
+	repo.numIPv4++
+}
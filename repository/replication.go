@@ -0,0 +1,107 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package repository
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// ExportStream writes the live node index to the given writer, so that a
+// standby instance can import it with ImportStream and take over with a warm
+// pool. Unlike backups, nothing is written to disk; calling it repeatedly on
+// the same connection keeps the standby in sync with us. The nodes are encoded
+// in the binary node file format, one complete message per call.
+func (repo *Repository) ExportStream(w io.Writer) error {
+	var nodes []*node
+	if atomic.LoadUint32(&repo.state) == stateRunning {
+		c := make(chan []*node, 1)
+		repo.snapshotQ <- c
+		nodes = <-c
+	} else {
+		nodes = repo.nodes()
+	}
+
+	_, err := w.Write(encodeNodes(nodes))
+	if err != nil {
+		return err
+	}
+
+	repo.log.Debug("[REP] Exported %v nodes", len(nodes))
+
+	return nil
+}
+
+// ImportStream reads one message written by ExportStream from the given
+// reader and merges its nodes into our index. Nodes we already know take over
+// the state of the imported ones, as the exporting instance is the one
+// actually connecting to them, while new nodes are added up to the node limit.
+// Nodes of other networks are skipped. Nothing is merged if the message can
+// not be read completely or holds more nodes than the node limit.
+func (repo *Repository) ImportStream(r io.Reader) error {
+	nodes, err := readNodes(r, repo.nodeLimit)
+	if err != nil {
+		return err
+	}
+
+	if atomic.LoadUint32(&repo.state) == stateRunning {
+		repo.importQ <- nodes
+	} else {
+		repo.merge(nodes)
+	}
+
+	return nil
+}
+
+// merge adds imported nodes to the index and returns whether it changed. It
+// has to be called from the go routine managing the nodes.
+func (repo *Repository) merge(nodes []*node) bool {
+	added := 0
+	updated := 0
+	for _, n := range nodes {
+		if n.network != repo.network {
+			continue
+		}
+
+		key := n.addr.String()
+		old, ok := repo.nodeIndex[key]
+		if ok {
+			old.numSeen = n.numSeen
+			old.numAttempts = n.numAttempts
+			old.lastAttempted = n.lastAttempted
+			old.lastConnected = n.lastConnected
+			old.lastSucceeded = n.lastSucceeded
+			updated++
+			continue
+		}
+
+		if uint32(len(repo.nodeIndex)) >= repo.nodeLimit {
+			continue
+		}
+
+		repo.nodeIndex[key] = n
+		added++
+	}
+
+	repo.log.Info("[REP] Imported %v new and %v known nodes", added, updated)
+
+	return added > 0 || updated > 0
+}
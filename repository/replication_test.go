@@ -0,0 +1,162 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package repository
+
+import (
+	"bytes"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/CIRCL/pbtc/adaptor"
+)
+
+// waitStats waits for the statistics of a running repository to satisfy the
+// given condition, as updates are processed in the background.
+func waitStats(t *testing.T, repo *Repository,
+	ok func(adaptor.RepositoryStats) bool) adaptor.RepositoryStats {
+	var stats adaptor.RepositoryStats
+	for i := 0; i < 1000; i++ {
+		stats = repo.Stats()
+		if ok(stats) {
+			break
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	return stats
+}
+
+// TestReplication keeps a standby in sync with a primary over a single
+// stream and checks that its pool matches the one of the primary.
+func TestReplication(t *testing.T) {
+	primary := newTestRepository(t,
+		SetBackupPath(filepath.Join(t.TempDir(), "nodes.dat")))
+	primary.Start()
+	defer primary.Stop()
+
+	standby := newTestRepository(t,
+		SetBackupPath(filepath.Join(t.TempDir(), "nodes.dat")))
+	standby.Start()
+	defer standby.Stop()
+
+	addrs := make([]*net.TCPAddr, 0, 4)
+	for i := 1; i <= 4; i++ {
+		addrs = append(addrs, &net.TCPAddr{IP: net.IPv4(8, 8, 8, byte(i)),
+			Port: 18333})
+	}
+
+	for _, addr := range addrs[:3] {
+		primary.Discovered(addr, nil, time.Now())
+	}
+	primary.Attempted(addrs[0])
+
+	synced := func(nodes int, attempted int) func(
+		adaptor.RepositoryStats) bool {
+		return func(stats adaptor.RepositoryStats) bool {
+			return stats.Nodes == nodes && stats.Attempted == attempted
+		}
+	}
+
+	stream := &bytes.Buffer{}
+	steps := []struct {
+		name      string
+		update    func()
+		nodes     int
+		attempted int
+	}{
+		{"initial", func() {}, 3, 1},
+		{"update", func() {
+			primary.Discovered(addrs[3], nil, time.Now())
+			primary.Succeeded(addrs[0])
+		}, 4, 0},
+	}
+
+	for _, step := range steps {
+		step.update()
+		stats := waitStats(t, primary, synced(step.nodes, step.attempted))
+		if !synced(step.nodes, step.attempted)(stats) {
+			t.Fatalf("%v: primary has %v nodes, %v attempted", step.name,
+				stats.Nodes, stats.Attempted)
+		}
+
+		err := primary.ExportStream(stream)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = standby.ImportStream(stream)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stats = waitStats(t, standby, synced(step.nodes, step.attempted))
+		if !synced(step.nodes, step.attempted)(stats) {
+			t.Errorf("%v: standby has %v nodes, %v attempted", step.name,
+				stats.Nodes, stats.Attempted)
+		}
+	}
+
+	// a message cut short is not merged at all
+	err := primary.ExportStream(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream.Truncate(stream.Len() - 1)
+	fresh := newTestRepository(t,
+		SetBackupPath(filepath.Join(t.TempDir(), "nodes.dat")))
+	err = fresh.ImportStream(stream)
+	if err == nil || len(fresh.nodeIndex) != 0 {
+		t.Errorf("imported %v nodes from truncated stream (%v)",
+			len(fresh.nodeIndex), err)
+	}
+}
+
+// TestImportForgedCount checks that the node count announced by a stream is
+// bounded by the node limit before anything is allocated for it.
+func TestImportForgedCount(t *testing.T) {
+	valid := encodeNodes(testNodes())
+
+	// the checksum doesn't matter, the count is rejected before it is read
+	forged := append([]byte{}, valid[:headerSize]...)
+	forged = append(forged, 0xff, 0xff, 0xff, 0xff)
+
+	tests := map[string]struct {
+		data  []byte
+		limit uint32
+	}{
+		"forged": {forged, 100000},
+		"limit":  {valid, 1},
+	}
+
+	for name, test := range tests {
+		repo := newTestRepository(t, SetNodeLimit(test.limit),
+			SetBackupPath(filepath.Join(t.TempDir(), "nodes.dat")))
+		err := repo.ImportStream(bytes.NewReader(test.data))
+		if err != errFileCount || len(repo.nodeIndex) != 0 {
+			t.Errorf("%v: imported %v nodes (%v)", name,
+				len(repo.nodeIndex), err)
+		}
+	}
+}
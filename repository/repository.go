@@ -21,39 +21,94 @@
 package repository
 
 import (
+	"bytes"
 	"encoding/gob"
+	"errors"
+	"io/ioutil"
+	"math/rand"
 	"net"
 	"os"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/btcsuite/btcd/wire"
+
 	"github.com/CIRCL/pbtc/adaptor"
+	"github.com/CIRCL/pbtc/util"
+)
+
+const (
+	stateIdle = iota
+	stateRunning
 )
 
+// statsCandidates is the number of best nodes listed in the statistics.
+const statsCandidates = 10
+
 // Repository is the default implementation of the repository interface of the
 // Manager module. It creates a simply in-repoory mapping for known nodes and
 // regularly save them on the disk.
 type Repository struct {
 	wg             *sync.WaitGroup
-	addrDiscovered chan *net.TCPAddr
+	state          uint32
+	addrDiscovered chan Discovery
 	addrAttempted  chan *net.TCPAddr
 	addrConnected  chan *net.TCPAddr
 	addrSucceeded  chan *net.TCPAddr
+	addrFailed     chan *net.TCPAddr
+	addrAdvertised chan [2]*net.TCPAddr
 	addrRetrieve   chan chan<- *net.TCPAddr
+	statsQ         chan chan<- adaptor.RepositoryStats
+	snapshotQ      chan chan<- []*node
+	importQ        chan []*node
 	sigAddr        chan struct{}
 	sigRetrieval   chan struct{}
-	tickerBackup   *time.Ticker
-	tickerPoll     *time.Ticker
+	timerBackup    *time.Timer
+	timerPoll      *time.Timer
 	nodeIndex      map[string]*node
-	file           *os.File
-
-	log adaptor.Log
-
-	seedsList  []string
-	seedsPort  uint16
-	backupPath string
-	backupRate time.Duration
-	nodeLimit  uint32
+	nodeForeign    []*node
+	backupFailures uint32
+	changes        uint64
+	saving         uint32
+	numIPv4        uint64
+	numIPv6        uint64
+	subMutex       *sync.Mutex
+	subscribers    []chan Discovery
+	subDropped     uint64
+	seedsMutex     *sync.Mutex
+	seedsCache     map[string]seedEntry
+	faultMutex     *sync.Mutex
+	fault          error
+
+	log  adaptor.Log
+	rep  adaptor.ReputationSource
+	name string
+
+	network      wire.BitcoinNet
+	seedsList    []string
+	seedsPort    uint16
+	seedsTTL     time.Duration
+	seedsRetry   time.Duration
+	seedsTimeout time.Duration
+	seedsRetries int
+	seedsLookup  func(string) ([]net.IP, error)
+	backupPath   string
+	format       BackupFormat
+	backupRate   time.Duration
+	pollRate     time.Duration
+	jitter       float64
+	nodeLimit    uint32
+	failLimit    uint32
+	minChanges   uint64
+	triedOnly    bool
+	newSample    int
+	retryRate    time.Duration
+	failNotify   func(error)
+	onState      func(adaptor.State, adaptor.State)
+	preference   AddressPreference
+	ratio        float64
 
 	invalidRange []*ipRange
 }
@@ -64,20 +119,37 @@ func New(options ...func(repo *Repository)) (*Repository, error) {
 	repo := &Repository{
 		wg:             &sync.WaitGroup{},
 		nodeIndex:      make(map[string]*node),
-		addrDiscovered: make(chan *net.TCPAddr, 1),
+		addrDiscovered: make(chan Discovery, 1),
 		addrAttempted:  make(chan *net.TCPAddr, 1),
 		addrConnected:  make(chan *net.TCPAddr, 1),
 		addrSucceeded:  make(chan *net.TCPAddr, 1),
+		addrFailed:     make(chan *net.TCPAddr, 1),
+		addrAdvertised: make(chan [2]*net.TCPAddr, 1),
 		addrRetrieve:   make(chan chan<- *net.TCPAddr, 1),
+		statsQ:         make(chan chan<- adaptor.RepositoryStats, 1),
+		snapshotQ:      make(chan chan<- []*node, 1),
+		importQ:        make(chan []*node, 1),
 		sigAddr:        make(chan struct{}),
 		sigRetrieval:   make(chan struct{}),
-		tickerPoll:     time.NewTicker(30 * time.Minute),
-
-		seedsList:  []string{"testnet-seed.bitcoin.petertodd.org"},
-		seedsPort:  18333,
-		backupRate: 90 * time.Second,
-		backupPath: "nodes.dat",
-		nodeLimit:  100000,
+		subMutex:       &sync.Mutex{},
+		seedsMutex:     &sync.Mutex{},
+		seedsCache:     make(map[string]seedEntry),
+		faultMutex:     &sync.Mutex{},
+
+		network:      wire.TestNet3,
+		seedsTTL:     5 * time.Minute,
+		seedsRetry:   10 * time.Second,
+		seedsTimeout: 10 * time.Second,
+		seedsRetries: 2,
+		seedsLookup:  net.LookupIP,
+		backupRate:   90 * time.Second,
+		pollRate:     30 * time.Minute,
+		jitter:       0.1,
+		backupPath:   "nodes.dat",
+		nodeLimit:    100000,
+		failLimit:    3,
+		ratio:        0.5,
+		retryRate:    5 * time.Minute,
 
 		invalidRange: make([]*ipRange, 0, 16),
 	}
@@ -86,11 +158,15 @@ func New(options ...func(repo *Repository)) (*Repository, error) {
 		option(repo)
 	}
 
-	file, err := os.Create(repo.backupPath)
+	// backups are written to a temporary file that is renamed to the backup
+	// path, so this fails right away if we can't create files next to it
+	temp := repo.backupPath + ".tmp"
+	file, err := os.OpenFile(temp, os.O_RDWR|os.O_CREATE, 0666)
 	if err != nil {
 		return nil, err
 	}
-	repo.file = file
+	file.Close()
+	os.Remove(temp)
 
 	repo.addRange(newIPRange("0.0.0.0", "0.255.255.255"))       // RFC1700
 	repo.addRange(newIPRange("10.0.0.0", "10.255.255.255"))     // RFC1918
@@ -112,6 +188,7 @@ func New(options ...func(repo *Repository)) (*Repository, error) {
 }
 
 // SetSeeds provides a list of DNS seeds to be used in case of bootstrapping.
+// By default, the seeds of the network of the repository are used.
 func SetSeedsList(seeds ...string) func(*Repository) {
 	return func(repo *Repository) {
 		repo.seedsList = seeds
@@ -119,13 +196,62 @@ func SetSeedsList(seeds ...string) func(*Repository) {
 }
 
 // SetDefaultPort sets the default port to be used for addresses discovered
-// through DNS seeds.
+// through DNS seeds. By default, it is the port of the network of the
+// repository.
 func SetSeedsPort(port uint16) func(*Repository) {
 	return func(repo *Repository) {
 		repo.seedsPort = port
 	}
 }
 
+// SetSeedsTTL sets for how long the IPs resolved from a DNS seed are reused
+// before querying the seed again. Zero means seeds are queried on every
+// bootstrap.
+func SetSeedsTTL(ttl time.Duration) func(*Repository) {
+	return func(repo *Repository) {
+		repo.seedsTTL = ttl
+	}
+}
+
+// SetSeedsRetry sets the delay before the bootstrap is retried if none of the
+// DNS seeds could be resolved, like when we start before the network is up.
+// The delay doubles with every failed retry, up to the interval at which the
+// seeds are polled anyway, until a seed resolves. Zero or less disables the
+// retries.
+func SetSeedsRetry(delay time.Duration) func(*Repository) {
+	return func(repo *Repository) {
+		repo.seedsRetry = delay
+	}
+}
+
+// SetSeedsTimeout sets the time after which the lookup of a DNS seed is given
+// up. Zero or less lets lookups take as long as the resolver does.
+func SetSeedsTimeout(timeout time.Duration) func(*Repository) {
+	return func(repo *Repository) {
+		repo.seedsTimeout = timeout
+	}
+}
+
+// SetSeedsRetries sets how many times a failed or timed out lookup of a DNS
+// seed is retried within one bootstrap, before the seed is skipped. Retries
+// are spread by a jittered delay that starts at a second and doubles each
+// time.
+func SetSeedsRetries(retries int) func(*Repository) {
+	return func(repo *Repository) {
+		repo.seedsRetries = retries
+	}
+}
+
+// SetSeedsResolver replaces the way DNS seeds are resolved, which defaults to
+// the resolver of the system. The seeds are resolved concurrently, so it has to
+// be safe for concurrent use.
+func SetSeedsResolver(lookup func(string) ([]net.IP,
+	error)) func(*Repository) {
+	return func(repo *Repository) {
+		repo.seedsLookup = lookup
+	}
+}
+
 // SetBackupPath sets the path for saving current address & node information.
 func SetBackupPath(path string) func(*Repository) {
 	return func(repo *Repository) {
@@ -133,28 +259,157 @@ func SetBackupPath(path string) func(*Repository) {
 	}
 }
 
+// SetBackupFormat sets the format used to save the node file. Files in any of
+// the formats can be restored, regardless of this setting.
+func SetBackupFormat(format BackupFormat) func(*Repository) {
+	return func(repo *Repository) {
+		repo.format = format
+	}
+}
+
 func SetBackupRate(rate time.Duration) func(*Repository) {
 	return func(repo *Repository) {
 		repo.backupRate = rate
 	}
 }
 
+// SetTimerJitter sets the fraction by which the backup and DNS seed timers are
+// randomly spread, so that several instances do not hit the disk and the seeds
+// at the same time.
+func SetTimerJitter(fraction float64) func(*Repository) {
+	return func(repo *Repository) {
+		repo.jitter = fraction
+	}
+}
+
+// SetReputationSource sets an external source of reputation that biases which
+// nodes are handed out for connection. Among the nodes that are eligible, each
+// is selected with a probability proportional to its score. Without a source,
+// all eligible nodes are treated the same.
+func SetReputationSource(source adaptor.ReputationSource) func(*Repository) {
+	return func(repo *Repository) {
+		repo.rep = source
+	}
+}
+
+// SetAddressPreference sets which IP family is favoured when handing out nodes
+// for connection. With PreferIPv4 or PreferIPv6, nodes of the other family are
+// only handed out if none of the preferred family is eligible. With Balanced,
+// we aim for the ratio set with SetIPv6Ratio among the nodes handed out.
+func SetAddressPreference(preference AddressPreference) func(*Repository) {
+	return func(repo *Repository) {
+		repo.preference = preference
+	}
+}
+
+// SetIPv6Ratio sets the share of IPv6 nodes among the nodes handed out that
+// the Balanced address preference aims for, between zero and one.
+func SetIPv6Ratio(ratio float64) func(*Repository) {
+	return func(repo *Repository) {
+		repo.ratio = ratio
+	}
+}
+
+// SetMinRetryInterval sets the minimum time between two connection attempts to
+// the same address, so that we never hammer a node, even if it keeps dropping
+// our connections right after the handshake.
+func SetMinRetryInterval(interval time.Duration) func(*Repository) {
+	return func(repo *Repository) {
+		repo.retryRate = interval
+	}
+}
+
 func SetNodeLimit(limit uint32) func(*Repository) {
 	return func(repo *Repository) {
 		repo.nodeLimit = limit
 	}
 }
 
+// SetBackupChanges sets the number of changes to the node pool after which it
+// is saved right away, without waiting for the backup interval. Whichever comes
+// first triggers the save. Zero means we only save at the backup interval.
+// Either way, saves are skipped if nothing changed since the last one.
+func SetBackupChanges(changes uint64) func(*Repository) {
+	return func(repo *Repository) {
+		repo.minChanges = changes
+	}
+}
+
+// SetSaveTriedOnly makes backups only keep the nodes we successfully connected
+// to before, plus a random sample of up to the given number of other nodes.
+// It keeps the node file small and fast to restore on a large pool; fresh
+// nodes are found again through the DNS seeds and our peers. Snapshots still
+// contain all nodes.
+func SetSaveTriedOnly(tried bool, sample int) func(*Repository) {
+	return func(repo *Repository) {
+		repo.triedOnly = tried
+		repo.newSample = sample
+	}
+}
+
+// SetBackupFailureLimit sets the number of consecutive failed backups after
+// which the problem is considered persistent and escalated.
+func SetBackupFailureLimit(limit uint32) func(*Repository) {
+	return func(repo *Repository) {
+		repo.failLimit = limit
+	}
+}
+
+// SetStateHandler sets a function that is called with the old and the new
+// state whenever the repository is started or stopped, or becomes faulty
+// because its backups keep failing, and when it recovers. It is called without
+// holding any lock of the repository, so it may call its methods.
+func SetStateHandler(
+	handler func(adaptor.State, adaptor.State)) func(*Repository) {
+	return func(repo *Repository) {
+		repo.onState = handler
+	}
+}
+
+// SetBackupFailureHandler sets a function to be called when backups failed
+// persistently, so that the caller can raise an alert or shut down.
+func SetBackupFailureHandler(handler func(error)) func(*Repository) {
+	return func(repo *Repository) {
+		repo.failNotify = handler
+	}
+}
+
+// SetName sets the name of the repository, which is added to its log
+// messages, so that several repositories can be told apart.
+func SetName(name string) func(*Repository) {
+	return func(repo *Repository) {
+		repo.name = name
+	}
+}
+
 func (repo *Repository) Start() {
 	repo.log.Info("[REP] Start: begin")
 
-	repo.tickerBackup = time.NewTicker(repo.backupRate)
+	repo.restore()
 
+	repo.log.Info("[REP] Start: restored %v nodes for %v", len(repo.nodeIndex),
+		repo.network)
+
+	repo.timerBackup = time.NewTimer(util.Jitter(repo.backupRate, repo.jitter))
+	repo.timerPoll = time.NewTimer(util.Jitter(repo.pollRate, repo.jitter))
+
+	// the handlers have to run before anything is submitted to the
+	// repository, including the addresses from the DNS seeds, and we are
+	// usable as soon as they do
 	repo.wg.Add(2)
 	go repo.goRetrieval()
 	go repo.goAddresses()
 
-	repo.bootstrap()
+	repo.transition(func() {
+		atomic.StoreUint32(&repo.state, stateRunning)
+	})
+
+	if !repo.bootstrap() && repo.seedsRetry > 0 {
+		repo.log.Warning("[REP] Start: no DNS seed resolved, retrying in %v",
+			repo.seedsRetry)
+		repo.wg.Add(1)
+		go repo.goBootstrap()
+	}
 
 	repo.log.Info("[REP] Start: completed")
 }
@@ -163,28 +418,118 @@ func (repo *Repository) Start() {
 func (repo *Repository) Stop() {
 	repo.log.Info("[REP] Stop: begin")
 
+	repo.transition(func() {
+		atomic.StoreUint32(&repo.state, stateIdle)
+	})
+
 	close(repo.sigRetrieval)
 	close(repo.sigAddr)
 
 	repo.wg.Wait()
 
-	repo.log.Info("[REP] Stop: saving node information")
+	repo.timerBackup.Stop()
+	repo.timerPoll.Stop()
 
-	repo.save()
+	repo.unsubscribeAll()
+
+	changes := atomic.SwapUint64(&repo.changes, 0)
+	if changes > 0 {
+		repo.log.Info("[REP] Stop: saving node information")
+		repo.save(repo.nodes(), changes)
+	}
 
 	repo.log.Info("[REP] Stop: completed")
 }
 
+// Fault returns the error of the last backup, if it failed. After the
+// repository was stopped, this tells whether the final backup was saved.
+func (repo *Repository) Fault() error {
+	repo.faultMutex.Lock()
+	defer repo.faultMutex.Unlock()
+
+	return repo.fault
+}
+
+// transition applies a change to the state or the backup failures of the
+// repository and reports the resulting change of state to the state handler,
+// once the fault mutex is released.
+func (repo *Repository) transition(change func()) {
+	repo.faultMutex.Lock()
+	old := repo.current()
+	change()
+	state := repo.current()
+	repo.faultMutex.Unlock()
+
+	if repo.onState != nil && state != old {
+		repo.onState(old, state)
+	}
+}
+
+// current returns the state of the repository as reported to the state
+// handler. It has to be called with the fault mutex held.
+func (repo *Repository) current() adaptor.State {
+	if atomic.LoadUint32(&repo.state) == stateIdle {
+		return adaptor.StateIdle
+	}
+
+	if atomic.LoadUint32(&repo.backupFailures) >= repo.failLimit {
+		return adaptor.StateFaulty
+	}
+
+	return adaptor.StateRunning
+}
+
+// Healthy returns whether the repository is running and able to back up its
+// nodes.
+func (repo *Repository) Healthy() (bool, error) {
+	if atomic.LoadUint32(&repo.state) != stateRunning {
+		return false, errors.New("repository not running")
+	}
+
+	if atomic.LoadUint32(&repo.backupFailures) >= repo.failLimit {
+		return false, errors.New("repository backup failing")
+	}
+
+	return true, nil
+}
+
+// Name returns the name the repository was created with.
+func (repo *Repository) Name() string {
+	return repo.name
+}
+
 func (repo *Repository) SetLog(log adaptor.Log) {
-	repo.log = log
+	repo.log = util.NamedLog(log, repo.name)
+}
+
+// SetNetwork sets the Bitcoin network of the nodes in this repository. The
+// backup keeps the nodes of all networks, but only those of the network set
+// here are restored and handed out, so address pools of different networks
+// never mix. It has to be called before the repository is started.
+func (repo *Repository) SetNetwork(network wire.BitcoinNet) {
+	repo.network = network
 }
 
 // Discovered will submit an address that has been discovered on the Bitcoin
-// network.
-func (repo *Repository) Discovered(addr *net.TCPAddr) {
+// network, together with the peer that sent it and the time it advertised.
+// Addresses from DNS seeds have no source.
+func (repo *Repository) Discovered(addr *net.TCPAddr, source *net.TCPAddr,
+	stamp time.Time) {
 	repo.log.Debug("[REP] Discovered: %v", addr)
 
-	repo.addrDiscovered <- addr
+	addr, err := normalize(addr)
+	if err != nil {
+		repo.log.Debug("[REP] Discovered: rejected (%v)", err)
+		return
+	}
+
+	// a bootstrap in progress may still submit addresses after we stopped
+	select {
+	case repo.addrDiscovered <- Discovery{Addr: addr, Source: source,
+		Stamp: stamp}:
+
+	case <-repo.sigAddr:
+	}
 }
 
 // Attempted will mark an address as having been attempted for connection.
@@ -210,6 +555,23 @@ func (repo *Repository) Succeeded(addr *net.TCPAddr) {
 	repo.addrSucceeded <- addr
 }
 
+// Failed will mark an address as having dropped an established connection on
+// us.
+func (repo *Repository) Failed(addr *net.TCPAddr) {
+	repo.log.Debug("[REP] Failed: %v", addr)
+
+	repo.addrFailed <- addr
+}
+
+// Advertised will mark an address as having advertised another address for
+// itself in its version message.
+func (repo *Repository) Advertised(addr *net.TCPAddr,
+	advertised *net.TCPAddr) {
+	repo.log.Debug("[REP] Advertised: %v as %v", addr, advertised)
+
+	repo.addrAdvertised <- [2]*net.TCPAddr{addr, advertised}
+}
+
 // Retrieve will send a good candidate address for connecting on the given
 // channel.
 func (repo *Repository) Retrieve(c chan<- *net.TCPAddr) {
@@ -218,75 +580,412 @@ func (repo *Repository) Retrieve(c chan<- *net.TCPAddr) {
 	repo.addrRetrieve <- c
 }
 
-// bootstrap will use a number of dns seeds to discover nodes.
-func (repo *Repository) bootstrap() {
-	repo.log.Info("[REP] Bootstrap: getting IPs from %v seeds",
-		len(repo.seedsList))
+// Stats returns a snapshot of the node pool. It is answered by the go routine
+// managing the nodes, so it is safe to call while the repository is running.
+func (repo *Repository) Stats() adaptor.RepositoryStats {
+	if atomic.LoadUint32(&repo.state) != stateRunning {
+		return adaptor.RepositoryStats{}
+	}
 
-	// iterate over the seeds and try to get the ips
-	for _, seed := range repo.seedsList {
-		// check if we can look up the ip addresses
-		ips, err := net.LookupIP(seed)
-		if err != nil {
+	c := make(chan adaptor.RepositoryStats, 1)
+	repo.statsQ <- c
+
+	return <-c
+}
+
+// Snapshot writes all nodes to the given path in the backup format, without
+// touching the regular backup file and its rotation. The nodes are copied by
+// the go routine managing them, so the snapshot is consistent and it is safe
+// to call while the repository is running. Like for backups, the file is
+// written under a temporary name first, so it appears complete or not at all.
+func (repo *Repository) Snapshot(path string) error {
+	repo.log.Info("[REP] Snapshot: writing nodes to %v", path)
+
+	var nodes []*node
+	if atomic.LoadUint32(&repo.state) == stateRunning {
+		c := make(chan []*node, 1)
+		repo.snapshotQ <- c
+		nodes = <-c
+	} else {
+		nodes = repo.nodes()
+	}
+
+	temp, err := repo.writeTemp(path, nodes)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(temp, path)
+}
+
+// stats computes the statistics of the node pool. It has to be called from the
+// go routine managing the nodes.
+func (repo *Repository) stats() adaptor.RepositoryStats {
+	stats := adaptor.RepositoryStats{
+		Nodes:   len(repo.nodeIndex),
+		Foreign: len(repo.nodeForeign),
+	}
+
+	succeeded := make([]*node, 0)
+	for _, n := range repo.nodeIndex {
+		if n.numAttempts > 0 {
+			stats.Attempted++
+		}
+
+		if n.numFailures > 0 {
+			stats.Failed++
+		}
+
+		if n.advertised != nil {
+			stats.Misadvertised++
+		}
+
+		if !n.lastSucceeded.IsZero() {
+			stats.Succeeded++
+			succeeded = append(succeeded, n)
+		}
+	}
+
+	sort.Sort(bySucceeded(succeeded))
+	if len(succeeded) > statsCandidates {
+		succeeded = succeeded[:statsCandidates]
+	}
+
+	stats.Candidates = make([]string, 0, len(succeeded))
+	for _, n := range succeeded {
+		stats.Candidates = append(stats.Candidates, n.String())
+	}
+
+	return stats
+}
+
+// bootstrap will use a number of dns seeds to discover nodes. It returns false
+// if there are seeds, but none of them could be resolved.
+func (repo *Repository) bootstrap() bool {
+	seeds := repo.seedsList
+	if seeds == nil {
+		seeds = util.DefaultSeeds(repo.network)
+	}
+
+	port := repo.seedsPort
+	if port == 0 {
+		port = util.DefaultPort(repo.network)
+	}
+
+	repo.log.Info("[REP] Bootstrap: getting IPs from %v seeds", len(seeds))
+
+	// resolve the seeds at the same time, so that slow ones don't hold up
+	// the others, and add their ips as they come in
+	results := make(chan seedResult, len(seeds))
+	for _, seed := range seeds {
+		go func(seed string) {
+			ips, err := repo.resolveSeed(seed)
+			results <- seedResult{seed: seed, ips: ips, err: err}
+		}(seed)
+	}
+
+	resolved := 0
+	for range seeds {
+		res := <-results
+		if res.err != nil {
+			repo.log.Debug("[REP] Bootstrap: could not resolve %v (%v)",
+				res.seed, res.err)
 			continue
 		}
 
-		repo.log.Info("[REP] Bootstrap: found %v IPs from %v", len(ips), seed)
+		resolved++
+
+		repo.log.Info("[REP] Bootstrap: found %v IPs from %v", len(res.ips),
+			res.seed)
 
 		// range over the ips and add them to the repository
-		for _, ip := range ips {
-			addr := &net.TCPAddr{IP: ip, Port: int(repo.seedsPort)}
-			repo.Discovered(addr)
+		for _, ip := range res.ips {
+			addr := &net.TCPAddr{IP: ip, Port: int(port)}
+			repo.Discovered(addr, nil, time.Now())
+		}
+	}
+
+	return len(seeds) == 0 || resolved > 0
+}
+
+// goBootstrap is launched as a go routine if no DNS seed could be resolved on
+// start. It retries the bootstrap with exponential backoff until one of the
+// seeds resolves, so that we fill the pool once the network is up.
+func (repo *Repository) goBootstrap() {
+	defer repo.wg.Done()
+
+	delay := repo.seedsRetry
+	for {
+		timer := time.NewTimer(util.Jitter(delay, repo.jitter))
+		select {
+		case <-repo.sigAddr:
+			timer.Stop()
+			return
+
+		case <-timer.C:
+		}
+
+		if repo.bootstrap() {
+			repo.log.Info("[REP] Bootstrap: DNS seeds resolved on retry")
+			return
+		}
+
+		delay *= 2
+		if delay > repo.pollRate {
+			delay = repo.pollRate
 		}
+
+		repo.log.Warning("[REP] Bootstrap: no DNS seed resolved, retrying in "+
+			"%v", delay)
 	}
 }
 
-// save will try to save all current nodes to a file on disk.
-func (repo *Repository) save() {
-	// create the file, truncating if it already exists
-	if repo.file == nil {
+// changed counts a change to the node pool. If enough changes accumulated, a
+// save is started right away and the backup timer is restarted. It has to be
+// called from the go routine managing the nodes.
+func (repo *Repository) changed() {
+	changes := atomic.AddUint64(&repo.changes, 1)
+	if repo.minChanges == 0 || changes < repo.minChanges {
 		return
 	}
 
-	//
-	err := repo.file.Truncate(0)
-	if err != nil {
-		repo.log.Error("failed to truncate repo backup")
+	if repo.saveChanges() {
+		repo.timerBackup.Reset(util.Jitter(repo.backupRate, repo.jitter))
+	}
+}
+
+// saveChanges starts saving the nodes in the background and returns whether
+// it did. Nothing is saved if the pool did not change since the last save, or
+// if a save is still running. The nodes are copied before, so it has to be
+// called from the go routine managing the nodes.
+func (repo *Repository) saveChanges() bool {
+	if !atomic.CompareAndSwapUint32(&repo.saving, 0, 1) {
+		return false
+	}
+
+	changes := atomic.SwapUint64(&repo.changes, 0)
+	if changes == 0 {
+		atomic.StoreUint32(&repo.saving, 0)
+		repo.log.Debug("[REP] Node index unchanged, skipping save")
+		return false
+	}
+
+	// the save is waited for on stop, so that it can't run at the same time
+	// as the final one, which writes to the same files
+	repo.log.Info("[REP] Saving node index (%v changes)", changes)
+	nodes := repo.nodes()
+	repo.wg.Add(1)
+	go func() {
+		defer repo.wg.Done()
+		repo.save(nodes, changes)
+		atomic.StoreUint32(&repo.saving, 0)
+	}()
+
+	return true
+}
+
+// save will try to save the given nodes to a file on disk. If it fails, the
+// changes are counted again so that the next save is not skipped. If it fails
+// too many times in a row, the failure is escalated.
+func (repo *Repository) save(nodes []*node, changes uint64) {
+	err := repo.backup(repo.persisted(nodes))
+
+	var failures uint32
+	repo.transition(func() {
+		repo.fault = err
+		if err == nil {
+			atomic.StoreUint32(&repo.backupFailures, 0)
+			return
+		}
+
+		failures = atomic.AddUint32(&repo.backupFailures, 1)
+	})
+
+	if err == nil {
 		return
 	}
 
-	_, err = repo.file.Seek(0, 0)
-	if err != nil {
-		repo.log.Error("failed to reset repo.file pointer")
+	atomic.AddUint64(&repo.changes, changes)
+
+	if failures < repo.failLimit {
+		repo.log.Error("[REP] Could not save node index (%v)", err)
 		return
 	}
 
-	// encode the entire index using gob outputting into repo.file
-	enc := gob.NewEncoder(repo.file)
-	err = enc.Encode(repo.nodeIndex)
+	repo.log.Critical("[REP] Could not save node index %v times (%v)",
+		failures, err)
+
+	if repo.failNotify != nil {
+		repo.failNotify(err)
+	}
+}
+
+// backup writes the node index to the backup file. The file is written under
+// a temporary name and then renamed, so that an interrupted backup never
+// leaves a broken file behind; the previous file is kept as a fallback.
+func (repo *Repository) backup(nodes []*node) error {
+	temp, err := repo.writeTemp(repo.backupPath, nodes)
 	if err != nil {
-		repo.log.Error("failed to encode repo backup")
-		return
+		return err
+	}
+
+	err = os.Rename(repo.backupPath, repo.backupPath+".bak")
+	if err != nil && !os.IsNotExist(err) {
+		return err
 	}
+
+	return os.Rename(temp, repo.backupPath)
 }
 
-// restore will try to load the previously saved node file.
-func (repo *Repository) restore() {
-	if repo.file == nil {
-		return
+// persisted selects the nodes to be written on backups. Unless only tried nodes
+// are saved, it is all of them. The nodes are in random order, so the sample of
+// untried nodes is random as well.
+func (repo *Repository) persisted(nodes []*node) []*node {
+	if !repo.triedOnly {
+		return nodes
+	}
+
+	selected := make([]*node, 0, len(nodes))
+	sampled := 0
+	for _, n := range nodes {
+		if n.lastSucceeded.IsZero() {
+			if sampled >= repo.newSample {
+				continue
+			}
+
+			sampled++
+		}
+
+		selected = append(selected, n)
+	}
+
+	return selected
+}
+
+// nodes returns copies of the nodes of our network merged with those of other
+// networks, so they can be encoded while the originals change.
+func (repo *Repository) nodes() []*node {
+	nodes := make([]*node, 0, len(repo.nodeIndex)+len(repo.nodeForeign))
+	for _, n := range repo.nodeIndex {
+		c := *n
+		nodes = append(nodes, &c)
+	}
+
+	for _, n := range repo.nodeForeign {
+		c := *n
+		nodes = append(nodes, &c)
+	}
+
+	return nodes
+}
+
+// writeTemp encodes the nodes in the backup format and writes them to a
+// temporary file next to the given path, which is returned on success.
+func (repo *Repository) writeTemp(path string, nodes []*node) (string,
+	error) {
+	var data []byte
+	switch repo.format {
+	case GobFormat:
+		buf := &bytes.Buffer{}
+		enc := gob.NewEncoder(buf)
+		err := enc.Encode(nodes)
+		if err != nil {
+			return "", err
+		}
+
+		data = buf.Bytes()
+
+	default:
+		data = encodeNodes(nodes)
 	}
 
-	_, err := repo.file.Seek(0, 0)
+	temp := path + ".tmp"
+	file, err := os.Create(temp)
 	if err != nil {
-		return
+		return "", err
+	}
+
+	_, err = file.Write(data)
+	if err != nil {
+		file.Close()
+		return "", err
+	}
+
+	err = file.Sync()
+	if err != nil {
+		file.Close()
+		return "", err
 	}
 
-	// decode the entire index using gob reading from the file
-	dec := gob.NewDecoder(repo.file)
-	err = dec.Decode(&repo.nodeIndex)
+	err = file.Close()
 	if err != nil {
+		return "", err
+	}
+
+	return temp, nil
+}
+
+// restore will try to load the previously saved node file. If it is corrupt or
+// of an unknown version, the previous generation is used instead. Files are
+// decoded completely before any node goes into the index, so a truncated file
+// never leaves us with part of its nodes; if no generation can be decoded, we
+// start with an empty index.
+func (repo *Repository) restore() {
+	var nodes []*node
+	restored := false
+	failed := false
+	for _, path := range []string{repo.backupPath, repo.backupPath + ".bak"} {
+		data, err := ioutil.ReadFile(path)
+		if err != nil || len(data) == 0 {
+			continue
+		}
+
+		nodes, err = decodeFile(data)
+		if err == nil {
+			repo.log.Info("[REP] Restored %v nodes from %v", len(nodes), path)
+			restored = true
+			break
+		}
+
+		repo.log.Warning("[REP] Could not restore %v (%v)", path, err)
+		failed = true
+	}
+
+	if failed && !restored {
+		repo.log.Error("[REP] No node file could be restored, starting empty")
 		return
 	}
+
+	// only nodes of our network go into the index, the others are kept aside
+	// so that they are not lost on the next backup
+	for _, n := range nodes {
+		if n.network != repo.network {
+			repo.nodeForeign = append(repo.nodeForeign, n)
+			continue
+		}
+
+		repo.nodeIndex[n.addr.String()] = n
+	}
+}
+
+// decodeFile decodes the content of a node file, detecting its format by the
+// magic number of the binary format. On error, no nodes are returned, even if
+// some of them could be decoded.
+func decodeFile(data []byte) ([]*node, error) {
+	if hasMagic(data) {
+		return decodeNodes(data)
+	}
+
+	// gob can fail after decoding part of the list, so we decode into a list
+	// of our own and only hand it out once the whole file is read
+	var nodes []*node
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	err := dec.Decode(&nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	return nodes, nil
 }
 
 func (repo *Repository) addRange(ipRange *ipRange) {
@@ -305,24 +1004,14 @@ retrievalLoop:
 			}
 
 		case c := <-repo.addrRetrieve:
-			for _, node := range repo.nodeIndex {
-				if node.numAttempts >= 1 {
-					continue
-				}
-
-				if node.lastAttempted.Add(time.Minute * 5).After(time.Now()) {
-					continue
-				}
-
-				if node.lastConnected.Before(node.lastSucceeded) {
-					continue
-				}
-
-				if node.lastSucceeded.Add(time.Minute * 15).After(time.Now()) {
+			for _, f := range repo.families() {
+				node := repo.pick(f)
+				if node == nil {
 					continue
 				}
 
 				repo.log.Debug("[REP] %v retrieved", node)
+				repo.count(node)
 				c <- node.addr
 				continue retrievalLoop
 			}
@@ -330,6 +1019,84 @@ retrievalLoop:
 	}
 }
 
+// pick selects an eligible node of the given family. With a reputation source,
+// the selection is weighted by score, otherwise the first one found is used.
+func (repo *Repository) pick(f family) *node {
+	if repo.rep != nil {
+		return repo.weighted(f)
+	}
+
+	for _, node := range repo.nodeIndex {
+		if !f.matches(node) || !repo.eligible(node) {
+			continue
+		}
+
+		return node
+	}
+
+	return nil
+}
+
+// eligible checks whether a node can be handed out for a connection attempt.
+// Whatever else applies, a node is never attempted again before the minimum
+// retry interval has passed.
+func (repo *Repository) eligible(node *node) bool {
+	if node.lastAttempted.Add(repo.retryRate).After(time.Now()) {
+		return false
+	}
+
+	if node.numAttempts >= 1 {
+		return false
+	}
+
+	if node.lastConnected.Before(node.lastSucceeded) {
+		return false
+	}
+
+	if node.lastSucceeded.Add(time.Minute * 15).After(time.Now()) {
+		return false
+	}
+
+	return true
+}
+
+// weighted selects one of the eligible nodes of the given family at random,
+// with a probability proportional to its score from the reputation source.
+// Nodes with a score of zero or less are never selected.
+func (repo *Repository) weighted(f family) *node {
+	var total float64
+	nodes := make([]*node, 0)
+	scores := make([]float64, 0)
+	for _, node := range repo.nodeIndex {
+		if !f.matches(node) || !repo.eligible(node) {
+			continue
+		}
+
+		score := repo.rep.Score(node.addr)
+		if score <= 0 {
+			continue
+		}
+
+		total += score
+		nodes = append(nodes, node)
+		scores = append(scores, score)
+	}
+
+	pick := rand.Float64() * total
+	for i, score := range scores {
+		pick -= score
+		if pick < 0 {
+			return nodes[i]
+		}
+	}
+
+	if len(nodes) > 0 {
+		return nodes[len(nodes)-1]
+	}
+
+	return nil
+}
+
 func (repo *Repository) goAddresses() {
 	defer repo.wg.Done()
 
@@ -341,23 +1108,27 @@ addrLoop:
 				break addrLoop
 			}
 
-		case <-repo.tickerBackup.C:
-			repo.log.Info("[REP] Saving node index")
-			go repo.save()
+		case <-repo.timerBackup.C:
+			repo.saveChanges()
+			repo.timerBackup.Reset(util.Jitter(repo.backupRate, repo.jitter))
 
-		case <-repo.tickerPoll.C:
+		case <-repo.timerPoll.C:
 			repo.log.Info("[REP] Polling DNS seeds")
 			go repo.bootstrap()
+			repo.timerPoll.Reset(util.Jitter(repo.pollRate, repo.jitter))
 
-		case addr := <-repo.addrDiscovered:
+		case d := <-repo.addrDiscovered:
+			addr := d.Addr
 			n, ok := repo.nodeIndex[addr.String()]
+			// seeing a known address again only bumps its counter, which is
+			// written along with the next save but doesn't warrant one
 			if ok {
 				n.numSeen++
 				continue
 			}
 
 			if uint32(len(repo.nodeIndex)) >= repo.nodeLimit {
-				return
+				continue
 			}
 
 			ip := addr.IP.To4()
@@ -370,8 +1141,21 @@ addrLoop:
 			}
 
 			repo.log.Debug("[REP] %v discovered", addr)
-			n = newNode(addr)
+			n = newNode(repo.network, addr)
 			repo.nodeIndex[addr.String()] = n
+			repo.changed()
+			repo.publish(d)
+
+		case c := <-repo.statsQ:
+			c <- repo.stats()
+
+		case c := <-repo.snapshotQ:
+			c <- repo.nodes()
+
+		case nodes := <-repo.importQ:
+			if repo.merge(nodes) {
+				repo.changed()
+			}
 
 		case addr := <-repo.addrAttempted:
 			n, ok := repo.nodeIndex[addr.String()]
@@ -383,6 +1167,7 @@ addrLoop:
 			repo.log.Debug("[REP] %v attempted", addr)
 			n.numAttempts++
 			n.lastAttempted = time.Now()
+			repo.changed()
 
 		case addr := <-repo.addrConnected:
 			n, ok := repo.nodeIndex[addr.String()]
@@ -393,6 +1178,7 @@ addrLoop:
 
 			repo.log.Debug("[REP] %v connected", addr)
 			n.lastConnected = time.Now()
+			repo.changed()
 
 		case addr := <-repo.addrSucceeded:
 			n, ok := repo.nodeIndex[addr.String()]
@@ -404,6 +1190,57 @@ addrLoop:
 			repo.log.Debug("[REP] %v succeeded", addr)
 			n.numAttempts = 0
 			n.lastSucceeded = time.Now()
+			repo.changed()
+
+		case addr := <-repo.addrFailed:
+			n, ok := repo.nodeIndex[addr.String()]
+			if !ok {
+				repo.log.Warning("[REP] %v failed unknown", addr)
+				continue
+			}
+
+			repo.log.Debug("[REP] %v failed", addr)
+			n.numFailures++
+
+		case pair := <-repo.addrAdvertised:
+			addr := pair[0]
+			n, ok := repo.nodeIndex[addr.String()]
+			if !ok {
+				repo.log.Debug("[REP] %v advertised unknown", addr)
+				continue
+			}
+
+			repo.log.Debug("[REP] %v advertised as %v", addr, pair[1])
+			n.advertised = pair[1]
 		}
 	}
 }
+
+// normalize returns the canonical form of an address, with IPv4-mapped IPv6
+// addresses turned into plain IPv4 addresses. Addresses that we can never
+// connect to, like those with a zero port, an unspecified or a multicast IP,
+// are rejected.
+func normalize(addr *net.TCPAddr) (*net.TCPAddr, error) {
+	if addr == nil || addr.IP == nil {
+		return nil, errors.New("missing address")
+	}
+
+	if addr.Port <= 0 || addr.Port > 65535 {
+		return nil, errors.New("invalid port for " + addr.String())
+	}
+
+	if addr.IP.IsUnspecified() {
+		return nil, errors.New("unspecified ip for " + addr.String())
+	}
+
+	if addr.IP.IsMulticast() {
+		return nil, errors.New("multicast ip for " + addr.String())
+	}
+
+	ip := util.NormalizeIP(addr.IP)
+	if ip == nil {
+		return nil, errors.New("invalid ip length")
+	}
+
+	return &net.TCPAddr{IP: ip, Port: addr.Port}, nil
+}
@@ -0,0 +1,718 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package repository
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/CIRCL/pbtc/adaptor"
+	"github.com/CIRCL/pbtc/pbtctest"
+)
+
+func newTestRepository(t *testing.T,
+	options ...func(*Repository)) *Repository {
+	// tests never go to the network for seeds and never save into the
+	// package directory
+	options = append([]func(*Repository){
+		SetSeedsList([]string{}...),
+		SetSeedsRetry(0),
+		SetBackupPath(filepath.Join(t.TempDir(), "nodes.dat")),
+	}, options...)
+
+	repo, err := New(options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo.SetLog(pbtctest.Log{})
+
+	return repo
+}
+
+// TestStopWaitsForSave saves after every change, so that background saves are
+// running while the repository stops. The final save must not overlap with
+// them, or the file can end up broken or missing nodes.
+func TestStopWaitsForSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nodes.dat")
+	repo := newTestRepository(t, SetBackupPath(path), SetBackupChanges(1))
+	repo.Start()
+
+	for i := 0; i < 50; i++ {
+		addr := &net.TCPAddr{IP: net.IPv4(8, 8, 8, byte(i+1)), Port: 8333}
+		repo.Discovered(addr, nil, time.Now())
+	}
+
+	repo.Stop()
+
+	if atomic.LoadUint32(&repo.saving) != 0 {
+		t.Error("background save still running after stop")
+	}
+
+	err := repo.Fault()
+	if err != nil {
+		t.Fatalf("final save failed (%v)", err)
+	}
+
+	restored := newTestRepository(t, SetBackupPath(path))
+	restored.restore()
+	if len(restored.nodeIndex) != len(repo.nodeIndex) {
+		t.Errorf("restored %v nodes, want %v", len(restored.nodeIndex),
+			len(repo.nodeIndex))
+	}
+}
+
+func TestSkipUnchangedSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nodes.dat")
+	repo := newTestRepository(t, SetBackupPath(path),
+		SetBackupRate(5*time.Millisecond))
+	repo.Start()
+
+	addr := &net.TCPAddr{IP: net.IPv4(8, 8, 8, 8), Port: 8333}
+	repo.Discovered(addr, nil, time.Now())
+
+	for i := 0; i < 100; i++ {
+		info, err := os.Stat(path)
+		if err == nil && info.Size() > 0 {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	repo.Stop()
+
+	before, err := os.Stat(path)
+	if err != nil || before.Size() == 0 {
+		t.Fatalf("changed pool not saved (%v)", err)
+	}
+
+	files, _ := filepath.Glob(path + "*")
+
+	// the restored pool does not change, so none of the timer ticks nor the
+	// stop writes it again; seeing a known address again is no change
+	restored := newTestRepository(t, SetBackupPath(path),
+		SetBackupRate(5*time.Millisecond))
+	restored.Start()
+	for i := 0; i < 3; i++ {
+		restored.Discovered(addr, nil, time.Now())
+	}
+	time.Sleep(50 * time.Millisecond)
+	restored.Stop()
+
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !after.ModTime().Equal(before.ModTime()) {
+		t.Error("unchanged pool was saved")
+	}
+
+	rotated, _ := filepath.Glob(path + "*")
+	if len(rotated) != len(files) {
+		t.Errorf("backups %v became %v without changes", files, rotated)
+	}
+}
+
+func TestStartUsable(t *testing.T) {
+	// the seed yields more addresses than the handlers buffer, so Start only
+	// returns if they already run
+	var ips []net.IP
+	for i := 0; i < 200; i++ {
+		ips = append(ips, net.IPv4(8, 8, byte(i>>8), byte(i+1)))
+	}
+
+	lookup := func(seed string) ([]net.IP, error) {
+		return ips, nil
+	}
+
+	path := filepath.Join(t.TempDir(), "nodes.dat")
+	repo := newTestRepository(t, SetBackupPath(path), SetSeedsList("seed"),
+		SetSeedsResolver(lookup))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		repo.Start()
+
+		addr := &net.TCPAddr{IP: net.IPv4(9, 9, 9, 9), Port: 8333}
+		repo.Discovered(addr, nil, time.Now())
+	}()
+
+	select {
+	case <-done:
+
+	case <-time.After(time.Second):
+		t.Fatal("start or first update blocked")
+	}
+	defer repo.Stop()
+
+	for i := 0; i < 100 && repo.Stats().Nodes < len(ips)+1; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	if repo.Stats().Nodes != len(ips)+1 {
+		t.Errorf("%v nodes after start", repo.Stats().Nodes)
+	}
+}
+
+func TestBackupFailureEscalated(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nodes.dat")
+
+	failed := make(chan error, 4)
+	repo := newTestRepository(t, SetBackupPath(path),
+		SetBackupFailureLimit(2),
+		SetBackupFailureHandler(func(err error) { failed <- err }))
+
+	err := os.Chmod(dir, 0555)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(dir, 0755)
+
+	// root writes to read-only directories anyway, so we also put a
+	// directory where the backup is written first
+	if os.Geteuid() == 0 {
+		os.Chmod(dir, 0755)
+		err = os.Mkdir(path+".tmp", 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repo.save(repo.nodes(), 1)
+	select {
+	case err = <-failed:
+		t.Fatalf("escalated after one failure (%v)", err)
+	default:
+	}
+
+	repo.save(repo.nodes(), 1)
+	select {
+	case <-failed:
+	default:
+		t.Fatal("persistent failure not escalated")
+	}
+
+	ok, _ := repo.Healthy()
+	if ok {
+		t.Error("repository healthy without backups")
+	}
+
+	// an unwritable path is reported on creation
+	_, err = New(SetBackupPath(filepath.Join(dir, "missing", "nodes.dat")))
+	if err == nil {
+		t.Error("created repository with unwritable backup path")
+	}
+}
+
+func TestBackupDirectoryChecked(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nodes.dat")
+	_, err := New(SetBackupPath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the check leaves no files behind
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 0 {
+		t.Errorf("check left %v behind", files)
+	}
+
+	// a writable backup file does not help if we can't write the temporary
+	// file that is renamed into place
+	err = ioutil.WriteFile(path, nil, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Chmod(dir, 0555)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(dir, 0755)
+
+	// root writes to read-only directories anyway, so we put a directory
+	// where the temporary file goes instead
+	if os.Geteuid() == 0 {
+		os.Chmod(dir, 0755)
+		err = os.Mkdir(path+".tmp", 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = New(SetBackupPath(path))
+	if err == nil {
+		t.Error("created repository without writable temporary file")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nodes.dat")
+	repo := newTestRepository(t, SetBackupPath(path))
+	repo.Start()
+
+	for i := 0; i < 20; i++ {
+		addr := &net.TCPAddr{IP: net.IPv4(8, 8, 8, byte(i+1)), Port: 8333}
+		repo.Discovered(addr, nil, time.Now())
+	}
+
+	for i := 0; i < 100 && repo.Stats().Nodes < 20; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	before, _ := filepath.Glob(path + "*")
+
+	// nodes keep coming in while we take the snapshot
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			addr := &net.TCPAddr{IP: net.IPv4(8, 8, 4, byte(i+1)),
+				Port: 8333}
+			repo.Discovered(addr, nil, time.Now())
+		}
+	}()
+
+	snapshot := filepath.Join(dir, "snapshot.dat")
+	err := repo.Snapshot(snapshot)
+	<-done
+
+	// the snapshot does not rotate the backups
+	after, _ := filepath.Glob(path + "*")
+	repo.Stop()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(after) != len(before) {
+		t.Errorf("backups %v became %v with the snapshot", before, after)
+	}
+
+	restored := newTestRepository(t, SetBackupPath(snapshot))
+	restored.restore()
+	if len(restored.nodeIndex) < 20 || len(restored.nodeIndex) > 40 {
+		t.Errorf("restored %v nodes from snapshot", len(restored.nodeIndex))
+	}
+}
+
+func TestAdvertised(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nodes.dat")
+	repo := newTestRepository(t, SetBackupPath(path))
+	repo.Start()
+	defer repo.Stop()
+
+	addr := &net.TCPAddr{IP: net.IPv4(8, 8, 8, 8), Port: 8333}
+	repo.Discovered(addr, nil, time.Now())
+	for i := 0; i < 100 && repo.Stats().Nodes < 1; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	// unknown nodes are not flagged
+	unknown := &net.TCPAddr{IP: net.IPv4(8, 8, 4, 4), Port: 8333}
+	advertised := &net.TCPAddr{IP: net.IPv4(9, 9, 9, 9), Port: 8333}
+	repo.Advertised(unknown, advertised)
+	repo.Advertised(addr, advertised)
+
+	for i := 0; i < 100 && repo.Stats().Misadvertised < 1; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	stats := repo.Stats()
+	if stats.Misadvertised != 1 || stats.Nodes != 1 {
+		t.Errorf("%v of %v nodes misadvertised", stats.Misadvertised,
+			stats.Nodes)
+	}
+}
+
+func TestSaveTriedOnly(t *testing.T) {
+	for _, sample := range []int{0, 1} {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "nodes.dat")
+		repo := newTestRepository(t, SetBackupPath(path),
+			SetSaveTriedOnly(true, sample))
+
+		tried := make(map[string]bool)
+		for i := 1; i <= 5; i++ {
+			addr := &net.TCPAddr{IP: net.IPv4(8, 8, 8, byte(i)), Port: 8333}
+			n := newNode(repo.network, addr)
+			if i <= 2 {
+				n.lastSucceeded = time.Unix(1400000000, 0)
+				tried[addr.String()] = true
+			}
+
+			repo.nodeIndex[addr.String()] = n
+		}
+
+		repo.save(repo.nodes(), 1)
+
+		restored := newTestRepository(t, SetBackupPath(path))
+		restored.restore()
+		if len(restored.nodeIndex) != len(tried)+sample {
+			t.Errorf("saved %v nodes with a sample of %v",
+				len(restored.nodeIndex), sample)
+		}
+
+		for addr := range tried {
+			if restored.nodeIndex[addr] == nil {
+				t.Errorf("tried node %v not saved", addr)
+			}
+		}
+
+		// snapshots still have all nodes
+		snapshot := filepath.Join(dir, "snapshot.dat")
+		err := repo.Snapshot(snapshot)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		restored = newTestRepository(t, SetBackupPath(snapshot))
+		restored.restore()
+		if len(restored.nodeIndex) != 5 {
+			t.Errorf("snapshot has %v nodes", len(restored.nodeIndex))
+		}
+	}
+}
+
+func TestNetworkPools(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nodes.dat")
+	testnet := newTestRepository(t, SetBackupPath(path))
+	testnet.SetNetwork(wire.TestNet3)
+	testnet.Start()
+	for i := 0; i < 5; i++ {
+		addr := &net.TCPAddr{IP: net.IPv4(8, 8, 8, byte(i+1)), Port: 18333}
+		testnet.Discovered(addr, nil, time.Now())
+	}
+	testnet.Stop()
+
+	mainnet := newTestRepository(t, SetBackupPath(path))
+	mainnet.SetNetwork(wire.MainNet)
+	mainnet.Start()
+
+	stats := mainnet.Stats()
+	if stats.Nodes != 0 || stats.Foreign != 5 {
+		t.Errorf("mainnet has %v nodes and %v foreign ones", stats.Nodes,
+			stats.Foreign)
+	}
+
+	addr := &net.TCPAddr{IP: net.IPv4(9, 9, 9, 9), Port: 8333}
+	mainnet.Discovered(addr, nil, time.Now())
+
+	c := make(chan *net.TCPAddr, 8)
+	for i := 0; i < 8; i++ {
+		mainnet.Retrieve(c)
+	}
+
+Retrieval:
+	for {
+		select {
+		case got := <-c:
+			if got.String() != addr.String() {
+				t.Errorf("mainnet offered %v", got)
+			}
+
+		case <-time.After(100 * time.Millisecond):
+			break Retrieval
+		}
+	}
+
+	mainnet.Stop()
+
+	// both pools survive the backups of either network
+	testnet = newTestRepository(t, SetBackupPath(path))
+	testnet.SetNetwork(wire.TestNet3)
+	testnet.Start()
+	defer testnet.Stop()
+
+	stats = testnet.Stats()
+	if stats.Nodes != 5 || stats.Foreign != 1 {
+		t.Errorf("testnet has %v nodes and %v foreign ones", stats.Nodes,
+			stats.Foreign)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		addr       *net.TCPAddr
+		normalized string
+	}{
+		{&net.TCPAddr{IP: net.ParseIP("::ffff:1.2.3.4"), Port: 8333},
+			"1.2.3.4:8333"},
+		{&net.TCPAddr{IP: net.ParseIP("1.2.3.4").To4(), Port: 8333},
+			"1.2.3.4:8333"},
+		{&net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 8333},
+			"[2001:db8::1]:8333"},
+		{&net.TCPAddr{IP: net.ParseIP("1.2.3.4"), Port: 0}, ""},
+		{&net.TCPAddr{IP: net.ParseIP("1.2.3.4"), Port: 65536}, ""},
+		{&net.TCPAddr{IP: net.IPv4zero, Port: 8333}, ""},
+		{&net.TCPAddr{IP: net.IPv6unspecified, Port: 8333}, ""},
+		{&net.TCPAddr{IP: net.ParseIP("224.0.0.1"), Port: 8333}, ""},
+		{&net.TCPAddr{IP: net.ParseIP("ff02::1"), Port: 8333}, ""},
+		{&net.TCPAddr{IP: net.IP{1, 2, 3}, Port: 8333}, ""},
+		{&net.TCPAddr{Port: 8333}, ""},
+		{nil, ""},
+	}
+
+	for _, test := range tests {
+		addr, err := normalize(test.addr)
+		if test.normalized == "" {
+			if err == nil {
+				t.Errorf("accepted %v as %v", test.addr, addr)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("rejected %v (%v)", test.addr, err)
+			continue
+		}
+
+		if addr.String() != test.normalized {
+			t.Errorf("normalized %v to %v", test.addr, addr)
+		}
+	}
+
+	// the mapped form of an address does not end up as a second node
+	repo := newTestRepository(t)
+	repo.Start()
+	defer repo.Stop()
+
+	repo.Discovered(&net.TCPAddr{IP: net.ParseIP("8.8.8.8"), Port: 8333},
+		nil, time.Now())
+	repo.Discovered(&net.TCPAddr{IP: net.ParseIP("::ffff:8.8.8.8"),
+		Port: 8333}, nil, time.Now())
+	repo.Discovered(&net.TCPAddr{IP: net.ParseIP("8.8.4.4"), Port: 0},
+		nil, time.Now())
+	repo.Discovered(&net.TCPAddr{IP: net.ParseIP("8.8.4.4"), Port: 8333},
+		nil, time.Now())
+
+	for i := 0; i < 100 && repo.Stats().Nodes < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if repo.Stats().Nodes != 2 {
+		t.Errorf("%v nodes in the repository", repo.Stats().Nodes)
+	}
+}
+
+// testReputation down-ranks one address and excludes another.
+type testReputation struct {
+	bad      string
+	excluded string
+}
+
+func (rep testReputation) Score(addr *net.TCPAddr) float64 {
+	switch addr.String() {
+	case rep.bad:
+		return 0.1
+
+	case rep.excluded:
+		return 0
+
+	default:
+		return 1
+	}
+}
+
+func TestReputationSource(t *testing.T) {
+	rep := testReputation{bad: "8.8.8.2:8333", excluded: "8.8.8.3:8333"}
+	repo := newTestRepository(t, SetReputationSource(rep))
+	for i := 1; i <= 3; i++ {
+		addr := &net.TCPAddr{IP: net.IPv4(8, 8, 8, byte(i)), Port: 8333}
+		repo.nodeIndex[addr.String()] = newNode(repo.network, addr)
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		counts[repo.weighted(familyAny).String()]++
+	}
+
+	if counts[rep.excluded] != 0 {
+		t.Errorf("excluded node selected %v times", counts[rep.excluded])
+	}
+
+	// the good node has ten times the weight of the bad one
+	good := counts["8.8.8.1:8333"]
+	bad := counts[rep.bad]
+	if bad == 0 || good < 5*bad {
+		t.Errorf("good node selected %v times, bad one %v times", good, bad)
+	}
+}
+
+func TestAddressPreference(t *testing.T) {
+	tests := []struct {
+		name       string
+		preference AddressPreference
+		ips        []string
+		ipv6       int
+	}{
+		{"ipv4", PreferIPv4, []string{"8.8.8.1", "2001:4860::1"}, 0},
+		{"ipv6", PreferIPv6, []string{"8.8.8.1", "2001:4860::1"}, 8},
+		{"fallback", PreferIPv6, []string{"8.8.8.1", "8.8.8.2"}, 0},
+		{"balanced", Balanced, []string{"8.8.8.1", "2001:4860::1"}, 2},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "nodes.dat")
+		repo := newTestRepository(t, SetBackupPath(path),
+			SetAddressPreference(test.preference), SetIPv6Ratio(0.25))
+		repo.Start()
+
+		for _, ip := range test.ips {
+			addr := &net.TCPAddr{IP: net.ParseIP(ip), Port: 8333}
+			repo.Discovered(addr, nil, time.Now())
+		}
+
+		for i := 0; i < 100 && repo.Stats().Nodes < len(test.ips); i++ {
+			time.Sleep(time.Millisecond)
+		}
+
+		// nodes are handed out again as long as we do not attempt them
+		ipv6 := 0
+		c := make(chan *net.TCPAddr, 1)
+		for i := 0; i < 8; i++ {
+			repo.Retrieve(c)
+			addr := <-c
+			if addr.IP.To4() == nil {
+				ipv6++
+			}
+		}
+
+		repo.Stop()
+
+		if ipv6 != test.ipv6 {
+			t.Errorf("%v: %v of 8 nodes handed out were IPv6, want %v",
+				test.name, ipv6, test.ipv6)
+		}
+	}
+}
+
+func TestHealthy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nodes.dat")
+	repo := newTestRepository(t, SetBackupPath(path))
+
+	ok, _ := repo.Healthy()
+	if ok {
+		t.Error("healthy before start")
+	}
+
+	repo.Start()
+
+	ok, err := repo.Healthy()
+	if !ok {
+		t.Errorf("unhealthy while running (%v)", err)
+	}
+
+	repo.Stop()
+
+	ok, _ = repo.Healthy()
+	if ok {
+		t.Error("healthy after stop")
+	}
+}
+
+// TestMinRetryInterval attempts an address twice in quick succession. The
+// attempt counter is left at zero, so only the minimum interval keeps the
+// second attempt back until it has elapsed.
+func TestMinRetryInterval(t *testing.T) {
+	repo := newTestRepository(t, SetMinRetryInterval(50*time.Millisecond))
+
+	addr := &net.TCPAddr{IP: net.ParseIP("8.8.8.1"), Port: 8333}
+	n := newNode(wire.MainNet, addr)
+	if !repo.eligible(n) {
+		t.Fatal("new node not eligible")
+	}
+
+	n.lastAttempted = time.Now()
+	if repo.eligible(n) {
+		t.Error("node eligible right after an attempt")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	if !repo.eligible(n) {
+		t.Error("node not eligible after the interval elapsed")
+	}
+
+	// the default interval still withholds it
+	repo = newTestRepository(t)
+	n.lastAttempted = time.Now().Add(-time.Minute)
+	if repo.eligible(n) {
+		t.Error("node eligible within the default interval")
+	}
+}
+
+func TestStateHandler(t *testing.T) {
+	var repo *Repository
+	var transitions [][2]adaptor.State
+	handler := func(old adaptor.State, state adaptor.State) {
+		// the handler may call back into the repository
+		repo.Fault()
+		transitions = append(transitions, [2]adaptor.State{old, state})
+	}
+
+	path := filepath.Join(t.TempDir(), "nodes.dat")
+	repo = newTestRepository(t, SetBackupPath(path),
+		SetBackupFailureLimit(2), SetStateHandler(handler))
+	repo.Start()
+
+	// a directory where the backup is written first makes it fail
+	err := os.Mkdir(path+".tmp", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo.save(repo.nodes(), 1)
+	repo.save(repo.nodes(), 1)
+	repo.save(repo.nodes(), 1)
+
+	os.Remove(path + ".tmp")
+	repo.save(repo.nodes(), 1)
+	repo.Stop()
+
+	// the repository is faulty once the failure limit is reached
+	expected := [][2]adaptor.State{
+		{adaptor.StateIdle, adaptor.StateRunning},
+		{adaptor.StateRunning, adaptor.StateFaulty},
+		{adaptor.StateFaulty, adaptor.StateRunning},
+		{adaptor.StateRunning, adaptor.StateIdle},
+	}
+
+	if !reflect.DeepEqual(transitions, expected) {
+		t.Errorf("transitions %v instead of %v", transitions, expected)
+	}
+}
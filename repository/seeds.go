@@ -0,0 +1,137 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package repository
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/CIRCL/pbtc/util"
+)
+
+// seedsBackoff is the delay before the first retry of a failed seed lookup. It
+// doubles with every further retry.
+const seedsBackoff = time.Second
+
+// seedResult is the outcome of resolving one DNS seed during a bootstrap.
+type seedResult struct {
+	seed string
+	ips  []net.IP
+	err  error
+}
+
+// seedEntry holds the IPs a DNS seed resolved to and until when they are used.
+type seedEntry struct {
+	ips     []net.IP
+	expires time.Time
+}
+
+// resolveSeed returns the IPs of a DNS seed. Results are cached for the seeds
+// TTL, so that repeated bootstraps don't hammer the resolver and the seeds,
+// which are run by volunteers. Failed lookups are not cached.
+func (repo *Repository) resolveSeed(seed string) ([]net.IP, error) {
+	now := time.Now()
+
+	repo.seedsMutex.Lock()
+	entry, ok := repo.seedsCache[seed]
+	repo.seedsMutex.Unlock()
+
+	if ok && now.Before(entry.expires) {
+		repo.log.Debug("[REP] Bootstrap: using cached IPs of %v", seed)
+		return entry.ips, nil
+	}
+
+	ips, err := repo.lookupSeed(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	if repo.seedsTTL > 0 {
+		repo.seedsMutex.Lock()
+		repo.seedsCache[seed] = seedEntry{
+			ips:     ips,
+			expires: now.Add(repo.seedsTTL),
+		}
+		repo.seedsMutex.Unlock()
+	}
+
+	return ips, nil
+}
+
+// lookupSeed resolves a DNS seed, retrying failed lookups with a jittered and
+// growing delay, so that a seed is only given up on for this bootstrap if it
+// keeps failing.
+func (repo *Repository) lookupSeed(seed string) ([]net.IP, error) {
+	delay := seedsBackoff
+	for attempt := 0; ; attempt++ {
+		ips, err := repo.lookupOnce(seed)
+		if err == nil {
+			return ips, nil
+		}
+
+		if attempt >= repo.seedsRetries {
+			return nil, err
+		}
+
+		repo.log.Debug("[REP] Bootstrap: lookup of %v failed, retrying (%v)",
+			seed, err)
+
+		select {
+		case <-time.After(util.Jitter(delay, repo.jitter)):
+
+		case <-repo.sigAddr:
+			return nil, err
+		}
+
+		delay *= 2
+	}
+}
+
+// lookupOnce resolves a DNS seed, giving up after the lookup timeout. A lookup
+// that hangs is left to finish in the background.
+func (repo *Repository) lookupOnce(seed string) ([]net.IP, error) {
+	if repo.seedsTimeout <= 0 {
+		return repo.seedsLookup(seed)
+	}
+
+	type result struct {
+		ips []net.IP
+		err error
+	}
+
+	c := make(chan result, 1)
+	go func() {
+		ips, err := repo.seedsLookup(seed)
+		c <- result{ips: ips, err: err}
+	}()
+
+	timer := time.NewTimer(repo.seedsTimeout)
+	defer timer.Stop()
+
+	select {
+	case res := <-c:
+		return res.ips, res.err
+
+	case <-timer.C:
+		return nil, errors.New("lookup timed out")
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package repository
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CIRCL/pbtc/adaptor"
+)
+
+// countingResolver resolves every seed to one IP and counts the lookups.
+type countingResolver struct {
+	mutex   sync.Mutex
+	lookups map[string]int
+}
+
+func (res *countingResolver) lookup(seed string) ([]net.IP, error) {
+	res.mutex.Lock()
+	defer res.mutex.Unlock()
+
+	res.lookups[seed]++
+	if seed == "broken" {
+		return nil, errors.New("no such host")
+	}
+
+	return []net.IP{net.IPv4(8, 8, 8, 8)}, nil
+}
+
+func (res *countingResolver) count(seed string) int {
+	res.mutex.Lock()
+	defer res.mutex.Unlock()
+
+	return res.lookups[seed]
+}
+
+func TestSeedsCache(t *testing.T) {
+	res := &countingResolver{lookups: make(map[string]int)}
+	repo := newTestRepository(t, SetSeedsResolver(res.lookup),
+		SetSeedsTTL(50*time.Millisecond), SetSeedsRetries(0))
+
+	for i := 0; i < 3; i++ {
+		ips, err := repo.resolveSeed("seed")
+		if err != nil || len(ips) != 1 {
+			t.Fatalf("resolved %v (%v)", ips, err)
+		}
+
+		repo.resolveSeed("other")
+		repo.resolveSeed("broken")
+	}
+
+	// within the TTL, the cached result is used for each seed
+	if res.count("seed") != 1 || res.count("other") != 1 {
+		t.Errorf("resolved seeds %v and %v times within the TTL",
+			res.count("seed"), res.count("other"))
+	}
+
+	// failed lookups are not cached
+	if res.count("broken") != 3 {
+		t.Errorf("resolved failing seed %v times", res.count("broken"))
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	repo.resolveSeed("seed")
+	if res.count("seed") != 2 {
+		t.Errorf("cached result used after the TTL")
+	}
+
+	// without a TTL, nothing is cached
+	res = &countingResolver{lookups: make(map[string]int)}
+	repo = newTestRepository(t, SetSeedsResolver(res.lookup), SetSeedsTTL(0))
+	repo.resolveSeed("seed")
+	repo.resolveSeed("seed")
+	if res.count("seed") != 2 {
+		t.Errorf("resolved seed %v times without TTL", res.count("seed"))
+	}
+}
+
+// flakyResolver fails the given number of lookups before it resolves every
+// seed to one IP, like a resolver on a host that comes online late.
+type flakyResolver struct {
+	mutex    sync.Mutex
+	failures int
+	lookups  int
+}
+
+func (res *flakyResolver) lookup(seed string) ([]net.IP, error) {
+	res.mutex.Lock()
+	defer res.mutex.Unlock()
+
+	res.lookups++
+	if res.lookups <= res.failures {
+		return nil, errors.New("network is unreachable")
+	}
+
+	return []net.IP{net.IPv4(8, 8, 8, 8)}, nil
+}
+
+func (res *flakyResolver) count() int {
+	res.mutex.Lock()
+	defer res.mutex.Unlock()
+
+	return res.lookups
+}
+
+func TestBootstrapRetry(t *testing.T) {
+	res := &flakyResolver{failures: 3}
+	repo := newTestRepository(t, SetSeedsList("seed"),
+		SetSeedsResolver(res.lookup), SetSeedsRetries(0),
+		SetSeedsRetry(10*time.Millisecond))
+	repo.Start()
+	defer repo.Stop()
+
+	if res.count() < 1 {
+		t.Fatal("no lookup on start")
+	}
+
+	// the bootstrap is retried with backoff until the seed resolves
+	stats := waitStats(t, repo, func(stats adaptor.RepositoryStats) bool {
+		return stats.Nodes > 0
+	})
+	if stats.Nodes != 1 {
+		t.Fatalf("%v nodes after the seed resolved, want 1", stats.Nodes)
+	}
+
+	// once the pool is filled, we stop retrying
+	lookups := res.count()
+	time.Sleep(200 * time.Millisecond)
+	if res.count() != lookups {
+		t.Errorf("%v lookups after the bootstrap succeeded",
+			res.count()-lookups)
+	}
+
+	// without retries, a failed bootstrap leaves the pool empty
+	res = &flakyResolver{failures: 1}
+	repo = newTestRepository(t, SetSeedsList("seed"),
+		SetSeedsResolver(res.lookup), SetSeedsRetries(0))
+	repo.Start()
+	time.Sleep(50 * time.Millisecond)
+	repo.Stop()
+
+	if res.count() != 1 || repo.Stats().Nodes != 0 {
+		t.Errorf("%v lookups and %v nodes without retries", res.count(),
+			repo.Stats().Nodes)
+	}
+}
+
+func TestLookupRetry(t *testing.T) {
+	// a seed that fails once is recovered by a retry
+	res := &flakyResolver{failures: 1}
+	repo := newTestRepository(t, SetSeedsResolver(res.lookup),
+		SetSeedsRetries(1))
+
+	ips, err := repo.resolveSeed("seed")
+	if err != nil || len(ips) != 1 || res.count() != 2 {
+		t.Errorf("resolved %v after %v lookups (%v)", ips, res.count(), err)
+	}
+
+	// without retries, it is given up on for this bootstrap
+	res = &flakyResolver{failures: 1}
+	repo = newTestRepository(t, SetSeedsResolver(res.lookup),
+		SetSeedsRetries(0))
+
+	_, err = repo.resolveSeed("seed")
+	if err == nil || res.count() != 1 {
+		t.Errorf("resolved failing seed after %v lookups", res.count())
+	}
+
+	// a seed that hangs times out without holding up the others
+	hang := make(chan struct{})
+	defer close(hang)
+
+	lookup := func(seed string) ([]net.IP, error) {
+		if seed == "hanging" {
+			<-hang
+		}
+
+		return []net.IP{net.IPv4(8, 8, 8, 8)}, nil
+	}
+
+	repo = newTestRepository(t, SetSeedsList("hanging", "seed"),
+		SetSeedsResolver(lookup), SetSeedsRetries(0),
+		SetSeedsTimeout(20*time.Millisecond))
+
+	start := time.Now()
+	if !repo.bootstrap() {
+		t.Error("bootstrap failed with one seed resolving")
+	}
+
+	if time.Since(start) > time.Second {
+		t.Errorf("bootstrap took %v with a hanging seed", time.Since(start))
+	}
+}
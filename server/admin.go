@@ -0,0 +1,334 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CIRCL/pbtc/adaptor"
+)
+
+// timeoutAdmin is the time after which an idle admin connection is closed.
+const timeoutAdmin = time.Minute
+
+// Admin serves queries about running repositories and managers on a local Unix
+// socket. Each query is a single line containing a command and its arguments,
+// and is answered with a single line of JSON. The following commands are
+// available:
+//
+// repository        statistics of the node pool of every repository
+// peers             statistics of every peer of every manager
+// summary           aggregate peer statistics of every manager
+// latency           ping round-trip times over all peers of every manager
+// drop <addr>       disconnect the peer with the address from every manager
+// reconnect <addr>  dial the address again right away from every manager
+type Admin struct {
+	wg       *sync.WaitGroup
+	sig      chan struct{}
+	path     string
+	log      adaptor.Log
+	repos    map[string]adaptor.Repository
+	mgrs     map[string]adaptor.Manager
+	listener *net.UnixListener
+
+	connMutex sync.Mutex
+	conns     map[*net.UnixConn]struct{}
+}
+
+// ManagerSummary is the answer of the admin interface to the summary command
+// for one manager.
+type ManagerSummary struct {
+	Peers        int
+	Routines     int
+	BytesRead    uint64
+	MessagesRead uint64
+	ByteRate     float64
+	MessageRate  float64
+}
+
+// LatencySummary is the answer of the admin interface to the latency command
+// for one manager. The percentiles are given as upper bounds of the buckets of
+// the merged histogram.
+type LatencySummary struct {
+	Histogram adaptor.Latency
+	P50       time.Duration
+	P90       time.Duration
+	P99       time.Duration
+}
+
+// NewAdmin creates a new admin interface with the given options.
+func NewAdmin(options ...func(*Admin)) (*Admin, error) {
+	admin := &Admin{
+		wg:    &sync.WaitGroup{},
+		sig:   make(chan struct{}),
+		path:  "pbtc.sock",
+		repos: make(map[string]adaptor.Repository),
+		mgrs:  make(map[string]adaptor.Manager),
+		conns: make(map[*net.UnixConn]struct{}),
+	}
+
+	for _, option := range options {
+		option(admin)
+	}
+
+	if admin.path == "" {
+		return nil, errors.New("admin: need socket path")
+	}
+
+	return admin, nil
+}
+
+// SetAdminSocket sets the path of the Unix socket to listen on.
+func SetAdminSocket(path string) func(*Admin) {
+	return func(admin *Admin) {
+		admin.path = path
+	}
+}
+
+func (admin *Admin) SetLog(log adaptor.Log) {
+	admin.log = log
+}
+
+// AddRepository makes the given repository available for queries.
+func (admin *Admin) AddRepository(name string, repo adaptor.Repository) {
+	admin.repos[name] = repo
+}
+
+// AddManager makes the given manager available for queries.
+func (admin *Admin) AddManager(name string, mgr adaptor.Manager) {
+	admin.mgrs[name] = mgr
+}
+
+func (admin *Admin) Start() {
+	admin.log.Info("[ADM] Start: begin")
+
+	// a socket file left behind by an earlier run would block the listener
+	os.Remove(admin.path)
+
+	addr := &net.UnixAddr{Name: admin.path, Net: "unix"}
+	listener, err := net.ListenUnix("unix", addr)
+	if err != nil {
+		admin.log.Error("[ADM] %v: could not listen (%v)", admin.path, err)
+		return
+	}
+
+	admin.listener = listener
+
+	admin.wg.Add(1)
+	go admin.goListen()
+
+	admin.log.Info("[ADM] Start: completed")
+}
+
+func (admin *Admin) Stop() {
+	admin.log.Info("[ADM] Stop: begin")
+
+	close(admin.sig)
+	if admin.listener != nil {
+		admin.listener.Close()
+	}
+
+	// open connections would otherwise keep us waiting until they time out
+	admin.connMutex.Lock()
+	for conn := range admin.conns {
+		conn.Close()
+	}
+	admin.connMutex.Unlock()
+
+	admin.wg.Wait()
+
+	admin.log.Info("[ADM] Stop: completed")
+}
+
+func (admin *Admin) goListen() {
+	defer admin.wg.Done()
+
+	for {
+		conn, err := admin.listener.AcceptUnix()
+		if err != nil {
+			// the listener is closed on shutdown, so that is no error
+			select {
+			case <-admin.sig:
+				return
+			default:
+			}
+
+			admin.log.Warning("[ADM] Could not accept connection (%v)", err)
+			return
+		}
+
+		admin.wg.Add(1)
+		go admin.serve(conn)
+	}
+}
+
+// track adds a connection to the open ones, so that it is closed on shutdown.
+// It returns false if we are already shutting down.
+func (admin *Admin) track(conn *net.UnixConn) bool {
+	admin.connMutex.Lock()
+	defer admin.connMutex.Unlock()
+
+	select {
+	case <-admin.sig:
+		return false
+	default:
+	}
+
+	admin.conns[conn] = struct{}{}
+
+	return true
+}
+
+// untrack removes a connection from the open ones once it is closed.
+func (admin *Admin) untrack(conn *net.UnixConn) {
+	admin.connMutex.Lock()
+	defer admin.connMutex.Unlock()
+
+	delete(admin.conns, conn)
+}
+
+// serve answers the queries of one admin connection until it is closed or
+// stays idle for too long.
+func (admin *Admin) serve(conn *net.UnixConn) {
+	defer admin.wg.Done()
+	defer conn.Close()
+
+	if !admin.track(conn) {
+		return
+	}
+
+	defer admin.untrack(conn)
+
+	scanner := bufio.NewScanner(conn)
+	encoder := json.NewEncoder(conn)
+
+	for {
+		conn.SetDeadline(time.Now().Add(timeoutAdmin))
+		if !scanner.Scan() {
+			return
+		}
+
+		command := strings.TrimSpace(scanner.Text())
+		admin.log.Debug("[ADM] Query: %v", command)
+
+		err := encoder.Encode(admin.query(command))
+		if err != nil {
+			return
+		}
+	}
+}
+
+// query returns the answer to a single admin command.
+func (admin *Admin) query(line string) interface{} {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return map[string]string{"error": "missing command"}
+	}
+
+	command := fields[0]
+	switch command {
+	case "repository":
+		answer := make(map[string]adaptor.RepositoryStats)
+		for name, repo := range admin.repos {
+			answer[name] = repo.Stats()
+		}
+
+		return answer
+
+	case "peers":
+		answer := make(map[string][]adaptor.PeerStats)
+		for name, mgr := range admin.mgrs {
+			answer[name] = mgr.GetPeers()
+		}
+
+		return answer
+
+	case "summary":
+		answer := make(map[string]ManagerSummary)
+		for name, mgr := range admin.mgrs {
+			summary := ManagerSummary{}
+			for _, stats := range mgr.GetPeers() {
+				summary.Peers++
+				summary.Routines += stats.Routines
+				summary.BytesRead += stats.BytesRead
+				summary.MessagesRead += stats.MessagesRead
+				summary.ByteRate += stats.ByteRate
+				summary.MessageRate += stats.MessageRate
+			}
+
+			answer[name] = summary
+		}
+
+		return answer
+
+	case "latency":
+		answer := make(map[string]LatencySummary)
+		for name, mgr := range admin.mgrs {
+			summary := LatencySummary{}
+			for _, stats := range mgr.GetPeers() {
+				summary.Histogram.Merge(stats.Latency)
+			}
+
+			summary.P50 = summary.Histogram.Percentile(0.5)
+			summary.P90 = summary.Histogram.Percentile(0.9)
+			summary.P99 = summary.Histogram.Percentile(0.99)
+			answer[name] = summary
+		}
+
+		return answer
+
+	case "drop", "reconnect":
+		if len(fields) != 2 {
+			return map[string]string{"error": "missing address"}
+		}
+
+		addr, err := net.ResolveTCPAddr("tcp", fields[1])
+		if err != nil {
+			return map[string]string{"error": err.Error()}
+		}
+
+		answer := make(map[string]string)
+		for name, mgr := range admin.mgrs {
+			if command == "drop" {
+				err = mgr.DropPeer(addr)
+			} else {
+				err = mgr.ReconnectPeer(addr)
+			}
+
+			answer[name] = "ok"
+			if err != nil {
+				answer[name] = err.Error()
+			}
+		}
+
+		return answer
+
+	default:
+		return map[string]string{"error": "unknown command " + command}
+	}
+}
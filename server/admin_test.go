@@ -0,0 +1,161 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/CIRCL/pbtc/adaptor"
+	"github.com/CIRCL/pbtc/pbtctest"
+)
+
+type testRepository struct{ adaptor.Repository }
+
+func (testRepository) Stats() adaptor.RepositoryStats {
+	return adaptor.RepositoryStats{Nodes: 3, Candidates: []string{"a"}}
+}
+
+type testManager struct {
+	adaptor.Manager
+
+	dropped chan *net.TCPAddr
+}
+
+func (mgr *testManager) GetPeers() []adaptor.PeerStats {
+	return []adaptor.PeerStats{
+		{Routines: 3, BytesRead: 100, MessagesRead: 2},
+		{Routines: 4, BytesRead: 50, MessagesRead: 1},
+	}
+}
+
+func (mgr *testManager) DropPeer(addr *net.TCPAddr) error {
+	if addr.Port != 8333 {
+		return errors.New("unknown peer")
+	}
+
+	mgr.dropped <- addr
+	return nil
+}
+
+func TestAdminQueries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pbtc.sock")
+	admin, err := NewAdmin(SetAdminSocket(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mgr := &testManager{dropped: make(chan *net.TCPAddr, 1)}
+	admin.SetLog(pbtctest.Log{})
+	admin.AddRepository("repo", testRepository{})
+	admin.AddManager("mgr", mgr)
+	admin.Start()
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	query := func(command string, answer interface{}) {
+		_, err := conn.Write([]byte(command + "\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = json.Unmarshal(line, answer)
+		if err != nil {
+			t.Fatalf("%v: %v (%s)", command, err, line)
+		}
+	}
+
+	var repos map[string]adaptor.RepositoryStats
+	query("repository", &repos)
+	if repos["repo"].Nodes != 3 || len(repos["repo"].Candidates) != 1 {
+		t.Errorf("repository answer %v", repos)
+	}
+
+	var summaries map[string]ManagerSummary
+	query("summary", &summaries)
+	summary := summaries["mgr"]
+	if summary.Peers != 2 || summary.Routines != 7 ||
+		summary.BytesRead != 150 || summary.MessagesRead != 3 {
+		t.Errorf("summary answer %v", summaries)
+	}
+
+	var answer map[string]string
+	query("drop 192.0.2.1:8333", &answer)
+	if answer["mgr"] != "ok" {
+		t.Errorf("drop answer %v", answer)
+	}
+
+	select {
+	case addr := <-mgr.dropped:
+		if addr.String() != "192.0.2.1:8333" {
+			t.Errorf("dropped %v", addr)
+		}
+
+	default:
+		t.Error("peer not dropped")
+	}
+
+	answer = nil
+	query("drop 192.0.2.1:1", &answer)
+	if answer["mgr"] != "unknown peer" {
+		t.Errorf("drop answer %v", answer)
+	}
+
+	answer = nil
+	query("bogus", &answer)
+	if answer["error"] == "" {
+		t.Errorf("answer %v to unknown command", answer)
+	}
+
+	// the connection is still open, which must not hold up the shutdown
+	stopped := make(chan struct{})
+	go func() {
+		admin.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+
+	case <-time.After(time.Second):
+		t.Fatal("stop waits for open connections")
+	}
+
+	_, err = reader.ReadByte()
+	if err == nil {
+		t.Error("connection still open after stop")
+	}
+}
@@ -26,18 +26,38 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/CIRCL/pbtc/adaptor"
-	"github.com/CIRCL/pbtc/peer"
+	"github.com/CIRCL/pbtc/util"
 )
 
+const (
+	stateIdle = iota
+	stateRunning
+)
+
+// acceptDelay is the time we wait before accepting connections again after a
+// temporary error.
+const acceptDelay = time.Second
+
+// tcpListener is the part of a TCP listener used to accept connections.
+type tcpListener interface {
+	AcceptTCP() (*net.TCPConn, error)
+	Addr() net.Addr
+}
+
 type Server struct {
-	wg       *sync.WaitGroup
-	sig      chan struct{}
-	host     string
-	log      adaptor.Log
-	mgr      adaptor.Manager
-	listener *net.TCPListener
+	state     uint32
+	wg        *sync.WaitGroup
+	sig       chan struct{}
+	host      string
+	addresses []string
+	name      string
+	log       adaptor.Log
+	mgr       adaptor.Manager
+	listeners []*net.TCPListener
 }
 
 func New(options ...func(*Server)) (*Server, error) {
@@ -54,82 +74,229 @@ func New(options ...func(*Server)) (*Server, error) {
 		return nil, errors.New("server: need host address")
 	}
 
+	for _, address := range server.addresses {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return nil, errors.New("server: invalid listen address " + address)
+		}
+
+		if !isLocalIP(ip) {
+			return nil, errors.New("server: listen address " + address +
+				" is not local")
+		}
+	}
+
 	return server, nil
 }
 
+// SetHostAddress sets the address the server listens on, in host:port format.
+// If the port is left out, the default port of the network of the manager is
+// used.
 func SetHostAddress(host string) func(*Server) {
 	return func(server *Server) {
 		server.host = host
 	}
 }
 
+// SetName sets the name of the server, which is added to its log messages, so
+// that several servers can be told apart.
+func SetName(name string) func(*Server) {
+	return func(server *Server) {
+		server.name = name
+	}
+}
+
+// SetListenAddresses restricts the IPs the server listens on to the given list.
+// The port is still taken from the host address. If no listen addresses are
+// set and the host address uses an unspecified IP, the server listens on the
+// wildcard address.
+func SetListenAddresses(addresses []string) func(*Server) {
+	return func(server *Server) {
+		server.addresses = addresses
+	}
+}
+
 func (server *Server) Start() {
-	server.wg.Add(1)
-	go server.goListen()
+	server.log.Info("[SVR] Start: begin")
+
+	addrs, err := server.resolve()
+	if err != nil {
+		server.log.Error("[SVR] Start: could not resolve addresses (%v)", err)
+		return
+	}
+
+	for _, addr := range addrs {
+		listener, err := net.ListenTCP("tcp", addr)
+		if err != nil {
+			server.log.Warning("[SVR] %v: could not listen (%v)", addr, err)
+			continue
+		}
+
+		server.log.Info("[SVR] %v: listening", addr)
+
+		server.listeners = append(server.listeners, listener)
+		server.wg.Add(1)
+		go server.goListen(listener)
+	}
+
+	if len(server.listeners) > 0 {
+		atomic.StoreUint32(&server.state, stateRunning)
+	}
+
+	server.log.Info("[SVR] Start: completed")
 }
 
 func (server *Server) Stop() {
+	server.log.Info("[SVR] Stop: begin")
+
+	atomic.StoreUint32(&server.state, stateIdle)
+
 	close(server.sig)
-	server.listener.Close()
+	for _, listener := range server.listeners {
+		listener.Close()
+	}
 	server.wg.Wait()
+
+	server.log.Info("[SVR] Stop: completed")
+}
+
+// Healthy returns whether the server is listening for connections.
+func (server *Server) Healthy() (bool, error) {
+	if atomic.LoadUint32(&server.state) != stateRunning {
+		return false, errors.New("server not listening")
+	}
+
+	return true, nil
 }
 
 func (server *Server) SetLog(log adaptor.Log) {
-	server.log = log
+	server.log = util.NamedLog(log, server.name)
+}
+
+// Name returns the name the server was created with.
+func (server *Server) Name() string {
+	return server.name
 }
 
 func (server *Server) SetManager(mgr adaptor.Manager) {
 	server.mgr = mgr
 }
 
-func (server *Server) goListen() {
-	defer server.wg.Done()
+// resolve returns the list of TCP addresses the server should listen on.
+func (server *Server) resolve() ([]*net.TCPAddr, error) {
+	// without a port, we listen on the default port of our manager's network,
+	// so that servers for several networks can share a host address
+	address := server.host
+	bare := strings.Trim(address, "[]")
+	if bare == "" || net.ParseIP(bare) != nil {
+		port := util.DefaultPort(server.mgr.Network())
+		address = net.JoinHostPort(bare, strconv.Itoa(int(port)))
+	}
 
-	ips, ports, err := net.SplitHostPort(server.host)
+	host, ports, err := net.SplitHostPort(address)
 	if err != nil {
-		return
+		return nil, err
 	}
 
 	port, err := strconv.ParseInt(ports, 10, 32)
 	if err != nil {
-		return
+		return nil, err
 	}
 
-	ip := net.ParseIP(ips)
-	if ip == nil {
-		return
+	var ips []net.IP
+	switch {
+	case len(server.addresses) > 0:
+		for _, address := range server.addresses {
+			ips = append(ips, net.ParseIP(address))
+		}
+
+	// a single wildcard listener also covers loopback and interfaces that
+	// come up later
+	case host == "":
+		ips = append(ips, net.IPv4zero)
+
+	default:
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return nil, errors.New("invalid host ip")
+		}
+
+		ips = append(ips, ip)
 	}
 
-	addr := &net.TCPAddr{IP: ip, Port: int(port)}
-	listener, err := net.ListenTCP("tcp", addr)
-	if err != nil {
-		server.log.Warning("%v: could not listen (%v)", server.host, err)
-		return
+	addrs := make([]*net.TCPAddr, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, &net.TCPAddr{IP: ip, Port: int(port)})
 	}
 
-	server.listener = listener
+	return addrs, nil
+}
+
+// goListen accepts connections on the given listener until the server is
+// stopped. Temporary errors, like running out of file descriptors, are retried
+// after a short delay, while other errors stop the listener.
+func (server *Server) goListen(listener tcpListener) {
+	defer server.wg.Done()
 
 	for {
 		conn, err := listener.AcceptTCP()
-		// unfortunately, listener does not follow the convention of returning
-		// an io.EOF on closed connection, so we need to find out like this
-		if err != nil &&
-			strings.Contains(err.Error(), "use of closed network connection") {
-			break
-		}
 		if err != nil {
-			server.log.Warning("%v: could not accept connection (%v)", server.host, err)
-			break
+			// unfortunately, listener does not follow the convention of
+			// returning an io.EOF on closed connection, so we need to find out
+			// like this
+			closed := "use of closed network connection"
+			if strings.Contains(err.Error(), closed) {
+				return
+			}
+
+			nerr, ok := err.(net.Error)
+			if ok && (nerr.Temporary() || nerr.Timeout()) {
+				server.log.Warning("[SVR] %v: could not accept connection, "+
+					"retrying (%v)", listener.Addr(), err)
+
+				select {
+				case <-server.sig:
+					return
+
+				case <-time.After(acceptDelay):
+					continue
+				}
+			}
+
+			server.log.Error("[SVR] %v: could not accept connection, "+
+				"stopping listener (%v)", listener.Addr(), err)
+			return
 		}
 
-		// we submit the connection for peer creation
-		p, err := peer.New(
-			peer.SetConnection(conn),
-		)
+		// the manager wraps the connection in a peer with its settings and
+		// manages it like the peers it connected to itself
+		err = server.mgr.AdoptConn(conn, false)
 		if err != nil {
-			server.log.Warning("%v", err)
+			server.log.Warning("[SVR] %v: could not create peer (%v)",
+				conn.RemoteAddr(), err)
+			conn.Close()
+			continue
 		}
+	}
+}
+
+// isLocalIP checks whether the given IP is a loopback IP or belongs to one of
+// the local interfaces.
+func isLocalIP(ip net.IP) bool {
+	if ip.IsLoopback() {
+		return true
+	}
+
+	ips, err := util.FindLocalIPs()
+	if err != nil {
+		return false
+	}
 
-		server.mgr.Incoming(p)
+	for _, local := range ips {
+		if local.Equal(ip) {
+			return true
+		}
 	}
+
+	return false
 }
@@ -0,0 +1,277 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/CIRCL/pbtc/adaptor"
+	"github.com/CIRCL/pbtc/pbtctest"
+)
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		host      string
+		addresses []string
+		want      []string
+	}{
+		{":8333", nil, []string{"0.0.0.0:8333"}},
+		{"0.0.0.0:8333", nil, []string{"0.0.0.0:8333"}},
+		{"[::]:8333", nil, []string{"[::]:8333"}},
+		{"127.0.0.1:18333", nil, []string{"127.0.0.1:18333"}},
+		{"0.0.0.0:8333", []string{"127.0.0.1", "::1"},
+			[]string{"127.0.0.1:8333", "[::1]:8333"}},
+	}
+
+	for _, test := range tests {
+		server, err := New(SetHostAddress(test.host),
+			SetListenAddresses(test.addresses))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		addrs, err := server.resolve()
+		if err != nil {
+			t.Errorf("%v: %v", test.host, err)
+			continue
+		}
+
+		if len(addrs) != len(test.want) {
+			t.Errorf("%v: got %v, want %v", test.host, addrs, test.want)
+			continue
+		}
+
+		for i, addr := range addrs {
+			if addr.String() != test.want[i] {
+				t.Errorf("%v: got %v, want %v", test.host, addrs, test.want)
+			}
+		}
+	}
+}
+
+// networkManager is a manager on the given network.
+type networkManager struct {
+	adaptor.Manager
+
+	network wire.BitcoinNet
+}
+
+func (mgr networkManager) Network() wire.BitcoinNet {
+	return mgr.network
+}
+
+// TestResolveDefaultPort leaves out the port, so that servers for several
+// networks listen on the default port of their manager's network.
+func TestResolveDefaultPort(t *testing.T) {
+	tests := []struct {
+		host    string
+		network wire.BitcoinNet
+		want    string
+	}{
+		{"127.0.0.1", wire.MainNet, "127.0.0.1:8333"},
+		{"127.0.0.1", wire.TestNet3, "127.0.0.1:18333"},
+		{"[::1]", wire.TestNet3, "[::1]:18333"},
+		{"127.0.0.1:9000", wire.TestNet3, "127.0.0.1:9000"},
+	}
+
+	for _, test := range tests {
+		server, err := New(SetHostAddress(test.host))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		server.SetManager(networkManager{network: test.network})
+		addrs, err := server.resolve()
+		if err != nil || len(addrs) != 1 || addrs[0].String() != test.want {
+			t.Errorf("%v on %v: got %v (%v), want %v", test.host,
+				test.network, addrs, err, test.want)
+		}
+	}
+}
+
+// TestResolveWildcard makes sure the wildcard listener accepts connections on
+// loopback, which a listener per interface address would not.
+func TestResolveWildcard(t *testing.T) {
+	server, err := New(SetHostAddress(":0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addrs, err := server.resolve()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.ListenTCP("tcp", addrs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	conn, err := net.DialTCP("tcp", nil, &net.TCPAddr{IP: net.IPv4(127, 0, 0,
+		1), Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn.Close()
+}
+
+func TestHealthy(t *testing.T) {
+	server, err := New(SetHostAddress("127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.SetLog(pbtctest.Log{})
+
+	ok, _ := server.Healthy()
+	if ok {
+		t.Error("healthy before listening")
+	}
+
+	server.Start()
+
+	ok, err = server.Healthy()
+	if !ok {
+		t.Errorf("unhealthy while listening (%v)", err)
+	}
+
+	server.Stop()
+
+	ok, _ = server.Healthy()
+	if ok {
+		t.Error("healthy after stop")
+	}
+}
+
+func TestListenAddresses(t *testing.T) {
+	server, err := New(SetHostAddress("0.0.0.0:0"),
+		SetListenAddresses([]string{"127.0.0.1"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.SetLog(pbtctest.Log{})
+	server.Start()
+	defer server.Stop()
+
+	if len(server.listeners) != 1 {
+		t.Fatalf("%v listeners", len(server.listeners))
+	}
+
+	addr := server.listeners[0].Addr().(*net.TCPAddr)
+	if !addr.IP.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("listening on %v", addr)
+	}
+
+	// addresses that are not ours can't be listened on
+	_, err = New(SetHostAddress("0.0.0.0:0"),
+		SetListenAddresses([]string{"192.0.2.1"}))
+	if err == nil {
+		t.Error("accepted foreign listen address")
+	}
+
+	_, err = New(SetHostAddress("0.0.0.0:0"),
+		SetListenAddresses([]string{"localhost"}))
+	if err == nil {
+		t.Error("accepted listen address that is no IP")
+	}
+}
+
+// temporaryError is an accept error that goes away by itself, like running
+// out of file descriptors.
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "too many open files" }
+func (temporaryError) Timeout() bool   { return false }
+func (temporaryError) Temporary() bool { return true }
+
+// flakyListener fails its first accept with a temporary error.
+type flakyListener struct {
+	*net.TCPListener
+
+	failed bool
+}
+
+func (l *flakyListener) AcceptTCP() (*net.TCPConn, error) {
+	if !l.failed {
+		l.failed = true
+		return nil, temporaryError{}
+	}
+
+	return l.TCPListener.AcceptTCP()
+}
+
+type adoptManager struct {
+	adaptor.Manager
+
+	conns chan net.Conn
+}
+
+func (mgr *adoptManager) AdoptConn(conn net.Conn, trusted bool) error {
+	mgr.conns <- conn
+	return nil
+}
+
+func TestListenTemporaryError(t *testing.T) {
+	server, err := New(SetHostAddress("127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mgr := &adoptManager{conns: make(chan net.Conn, 1)}
+	server.SetLog(pbtctest.Log{})
+	server.SetManager(mgr)
+
+	listener, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0,
+		0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.wg.Add(1)
+	go server.goListen(&flakyListener{TCPListener: listener})
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// the listener retries after the error and accepts the connection
+	select {
+	case adopted := <-mgr.conns:
+		adopted.Close()
+
+	case <-time.After(3 * acceptDelay):
+		t.Fatal("listener did not recover from temporary error")
+	}
+
+	close(server.sig)
+	listener.Close()
+	server.wg.Wait()
+}
@@ -31,20 +31,56 @@ type Config struct {
 }
 
 type SupervisorConfig struct {
-	Log_level string
+	Log_level    string
+	Admin_socket string
 }
 
 type ManagerConfig struct {
-	Logger           string
-	Repository       string
-	Tracker          string
-	Processor        []string
-	Log_level        string
-	Protocol_magic   uint32
-	Protocol_version uint32
-	Connection_rate  int
-	Connection_limit int
-	Ticker_interval  int
+	Logger            string
+	Repository        string
+	Tracker           string
+	Processor         []string
+	Log_level         string
+	Protocol_magic    uint32
+	Protocol_version  uint32
+	Protocol_minimum  uint32
+	Connection_rate   int
+	Connection_limit  int
+	Dial_concurrency  int
+	Routine_limit     int
+	Peer_maxage       int
+	Lifetime_min      int
+	Lifetime_max      int
+	Idle_timeout      int
+	Idle_inbound      int
+	Addr_maxage       int
+	Addr_limit        int
+	Message_cap       uint64
+	Poll_cooldown     int
+	Tcp_keepalive     int
+	Group_limit       int
+	Group_prefix      int
+	Topology_rate     int
+	Version_timeout   int
+	Handshake_timeout int
+	Timer_jitter      int
+	Bandwidth_budget  uint64
+	Ticker_interval   int
+	Proxy_list        []string
+	Proxy_rotation    bool
+	Proxy_fallback    bool
+	Local_addresses   []string
+	Trusted_peers     []string
+	History_path      string
+	History_limit     int
+	Passive_only      bool
+	Disable_relay     bool
+	Record_raw        bool
+	Listen_only       bool
+	Ignore_addr       bool
+	Ignore_inbound    bool
+	Learn_version     bool
+	Ignore_services   bool
 }
 
 type LoggerConfig struct {
@@ -56,28 +92,49 @@ type LoggerConfig struct {
 	File_format     string
 	File_level      string
 	File_path       string
+	File_split      bool
+	File_sizelimit  int64
+	File_agelimit   int
 }
 
 type RepositoryConfig struct {
-	Logger      string
-	Log_level   string
-	Seeds_list  []string
-	Seeds_port  uint16
-	Backup_rate uint32
-	Backup_path string
-	Node_limit  uint32
+	Logger             string
+	Log_level          string
+	Seeds_list         []string
+	Seeds_port         uint16
+	Seeds_ttl          int
+	Seeds_retry        int
+	Seeds_timeout      int
+	Seeds_retries      int
+	Backup_rate        uint32
+	Backup_path        string
+	Backup_format      string
+	Backup_failures    uint32
+	Backup_halt        bool
+	Backup_changes     uint64
+	Backup_tried       bool
+	Backup_sample      int
+	Node_limit         uint32
+	Retry_interval     int
+	Timer_jitter       int
+	Address_preference string
+	Ipv6_ratio         int
 }
 
 type TrackerConfig struct {
-	Logger    string
-	Log_level string
+	Logger          string
+	Log_level       string
+	Tx_window       int
+	Announcer_limit int
+	Tip_quorum      int
 }
 
 type ServerConfig struct {
-	Logger       string
-	Manager      string
-	Log_level    string
-	Host_address string
+	Logger           string
+	Manager          string
+	Log_level        string
+	Host_address     string
+	Listen_addresses []string
 }
 
 type ProcessorConfig struct {
@@ -85,9 +142,16 @@ type ProcessorConfig struct {
 	Next             []string
 	Log_level        string
 	Processor_type   string
+	Tag              string
+	Geo_db           string
 	Address_list     []string
 	IP_list          []string
 	Command_list     []string
+	Opreturn_list    []string
+	Burst_rate       int
+	Burst_window     int
+	Burst_triggers   []string
+	Burst_sample     int
 	File_path        string
 	File_prefix      string
 	File_name        string
@@ -95,8 +159,16 @@ type ProcessorConfig struct {
 	File_compression string
 	File_sizelimit   int64
 	File_agelimit    int
+	File_disklimit   int64
+	File_encoding    string
+	File_stream      bool
+	File_index       string
+	Peer_limit       int
 	Redis_host       string
 	Redis_password   string
 	Redis_database   int64
 	Zeromq_host      string
+	Zeromq_warn      bool
+	Zeromq_buffer    int
+	Fifo_path        string
 }
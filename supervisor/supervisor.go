@@ -22,6 +22,8 @@ package supervisor
 
 import (
 	"errors"
+	"net"
+	"strings"
 	"time"
 
 	"code.google.com/p/gcfg"
@@ -29,8 +31,11 @@ import (
 	"github.com/op/go-logging"
 
 	"github.com/CIRCL/pbtc/adaptor"
+	"github.com/CIRCL/pbtc/compressor"
+	"github.com/CIRCL/pbtc/geo"
 	"github.com/CIRCL/pbtc/logger"
 	"github.com/CIRCL/pbtc/manager"
+	"github.com/CIRCL/pbtc/peer"
 	"github.com/CIRCL/pbtc/processor"
 	"github.com/CIRCL/pbtc/repository"
 	"github.com/CIRCL/pbtc/server"
@@ -44,6 +49,9 @@ type Supervisor struct {
 	svr     map[string]adaptor.Server
 	pro     map[string]adaptor.Processor
 	mgr     map[string]adaptor.Manager
+	admin   *server.Admin
+	halt    chan error
+	order   []string
 	log     adaptor.Log
 	options []interface{}
 }
@@ -64,6 +72,7 @@ func New() (*Supervisor, error) {
 		svr:  make(map[string]adaptor.Server),
 		pro:  make(map[string]adaptor.Processor),
 		mgr:  make(map[string]adaptor.Manager),
+		halt: make(chan error, 1),
 	}
 
 	if len(cfg.Logger) == 0 {
@@ -120,8 +129,24 @@ func New() (*Supervisor, error) {
 		supervisor.logr[name] = logr
 	}
 
+	// repositories writing to the same backup file would overwrite each
+	// other's nodes, like when monitoring several networks
+	backups := make(map[string]string)
 	for name, repo_cfg := range cfg.Repository {
-		repo, err := initRepository(repo_cfg)
+		path := repo_cfg.Backup_path
+		if path == "" {
+			path = "nodes.dat"
+		}
+
+		other, ok := backups[path]
+		if ok {
+			return nil, errors.New("repositories " + other + " and " + name +
+				" share backup path " + path)
+		}
+
+		backups[path] = name
+
+		repo, err := initRepository(name, repo_cfg, supervisor.fail)
 		if err != nil {
 			supervisor.log.Warning("[SUP] Init: repo init failed (%v)", err)
 			continue
@@ -131,7 +156,7 @@ func New() (*Supervisor, error) {
 	}
 
 	for name, tkr_cfg := range cfg.Tracker {
-		tkr, err := initTracker(tkr_cfg)
+		tkr, err := initTracker(name, tkr_cfg)
 		if err != nil {
 			supervisor.log.Warning("[SUP] Init: tracker init failed (%v)", err)
 			continue
@@ -141,7 +166,7 @@ func New() (*Supervisor, error) {
 	}
 
 	for name, svr_cfg := range cfg.Server {
-		svr, err := initServer(svr_cfg)
+		svr, err := initServer(name, svr_cfg)
 		if err != nil {
 			supervisor.log.Warning("[SUP] Init: server init failed (%v)", err)
 			continue
@@ -151,17 +176,28 @@ func New() (*Supervisor, error) {
 	}
 
 	for name, pro_cfg := range cfg.Processor {
-		pro, err := initProcessor(pro_cfg)
+		pro, err := initProcessor(name, pro_cfg)
 		if err != nil {
 			supervisor.log.Warning("[SUP] Init: proc init failed (%v)", err)
 			continue
 		}
 
+		// a processor without its database still works, so we only warn
+		if pro_cfg.Geo_db != "" {
+			db, err := geo.Open(pro_cfg.Geo_db)
+			if err != nil {
+				supervisor.log.Warning("[SUP] Init: geo db load failed "+
+					"for %v (%v)", name, err)
+			} else {
+				processor.SetGeo(db)(pro)
+			}
+		}
+
 		supervisor.pro[name] = pro
 	}
 
 	for name, mgr_cfg := range cfg.Manager {
-		mgr, err := initManager(mgr_cfg)
+		mgr, err := initManager(name, mgr_cfg)
 		if err != nil {
 			supervisor.log.Warning("[SUP] Init: manager init failed (%v)", err)
 			continue
@@ -175,7 +211,7 @@ func New() (*Supervisor, error) {
 	// check remaining modules for missing values
 	if len(supervisor.repo) == 0 {
 		supervisor.log.Warning("[SUP] Init: missing repository module")
-		repo, err := repository.New()
+		repo, err := repository.New(repository.SetName("default"))
 		if err != nil {
 			return nil, err
 		}
@@ -185,7 +221,7 @@ func New() (*Supervisor, error) {
 
 	if len(supervisor.tkr) == 0 {
 		supervisor.log.Warning("[SUP] Init: missing tracker module")
-		tkr, err := tracker.New()
+		tkr, err := tracker.New(tracker.SetName("default"))
 		if err != nil {
 			return nil, err
 		}
@@ -195,7 +231,7 @@ func New() (*Supervisor, error) {
 
 	if len(supervisor.mgr) == 0 {
 		supervisor.log.Warning("[SUP] Init: missing manager module")
-		mgr, err := manager.New()
+		mgr, err := manager.New(manager.SetName("default"))
 		if err != nil {
 			return nil, err
 		}
@@ -333,6 +369,11 @@ func New() (*Supervisor, error) {
 		log := "mgr___" + key
 		mgr.SetLog(logr.GetLog(log))
 		logr.SetLevel(log, level)
+
+		// peers get their own module, as they log the most by far
+		log = "peer___" + key
+		mgr.SetPeerLog(logr.GetLog(log))
+		logr.SetLevel(log, level)
 	}
 
 	supervisor.log.Info("[SUP] Init: injecting module dependencies")
@@ -356,6 +397,7 @@ func New() (*Supervisor, error) {
 	}
 
 	// inject repository into manager
+	networks := make(map[adaptor.Repository]wire.BitcoinNet)
 	for key, mgr := range supervisor.mgr {
 		mgr_cfg, ok := cfg.Manager[key]
 		if !ok {
@@ -370,10 +412,20 @@ func New() (*Supervisor, error) {
 			}
 		}
 
+		// a repository only hands out the nodes of one network, so managers
+		// on different networks need their own
+		network, ok := networks[repo]
+		if ok && network != mgr.Network() {
+			return nil, errors.New("manager " + key + " shares its " +
+				"repository with a manager on another network")
+		}
+
+		networks[repo] = mgr.Network()
 		mgr.SetRepository(repo)
 	}
 
 	// inject tracker into manager
+	trackers := make(map[adaptor.Tracker]wire.BitcoinNet)
 	for key, mgr := range supervisor.mgr {
 		mgr_cfg, ok := cfg.Manager[key]
 		if !ok {
@@ -388,10 +440,20 @@ func New() (*Supervisor, error) {
 			}
 		}
 
+		// the chain tip of a shared tracker would mix the heights of the
+		// networks, so we only warn, as everything else still works
+		network, ok := trackers[tkr]
+		if ok && network != mgr.Network() {
+			supervisor.log.Warning("[SUP] Init: manager %v shares its "+
+				"tracker with a manager on another network", key)
+		}
+
+		trackers[tkr] = mgr.Network()
 		mgr.SetTracker(tkr)
 	}
 
-	// inject processors into managers
+	// inject processors into managers, remembering which processors are fed
+	fed := make(map[string]bool)
 	for key, mgr := range supervisor.mgr {
 		mgr_cfg, ok := cfg.Manager[key]
 		if !ok {
@@ -401,10 +463,13 @@ func New() (*Supervisor, error) {
 		for _, name := range mgr_cfg.Processor {
 			pro, ok := supervisor.pro[name]
 			if !ok {
+				supervisor.log.Warning("[SUP] Init: manager %v references "+
+					"unknown processor %v", key, name)
 				continue
 			}
 
 			mgr.AddProcessor(pro)
+			fed[name] = true
 		}
 	}
 
@@ -418,11 +483,48 @@ func New() (*Supervisor, error) {
 		for _, name := range pro_cfg.Next {
 			next, ok := supervisor.pro[name]
 			if !ok {
+				supervisor.log.Warning("[SUP] Init: processor %v references "+
+					"unknown processor %v", key, name)
 				continue
 			}
 
 			pro.AddNext(next)
+			fed[name] = true
+		}
+	}
+
+	// processors that nothing feeds into will never see any records
+	for name := range supervisor.pro {
+		if !fed[name] {
+			supervisor.log.Warning("[SUP] Init: processor %v is not used by "+
+				"any manager or processor", name)
+		}
+	}
+
+	// processors have to be started after and stopped before the processors
+	// they feed into, so we order them along their dependencies
+	supervisor.order = supervisor.orderProcessors(cfg)
+
+	// the admin interface answers queries about repositories and managers
+	if cfg.Supervisor.Admin_socket != "" {
+		path := cfg.Supervisor.Admin_socket
+		admin, err := server.NewAdmin(server.SetAdminSocket(path))
+		if err != nil {
+			return nil, err
 		}
+
+		admin.SetLog(supervisor.logr[""].GetLog("admin"))
+		supervisor.logr[""].SetLevel("admin", level)
+
+		for name, repo := range supervisor.repo {
+			admin.AddRepository(name, repo)
+		}
+
+		for name, mgr := range supervisor.mgr {
+			admin.AddManager(name, mgr)
+		}
+
+		supervisor.admin = admin
 	}
 
 	supervisor.log.Info("[SUP] Init: completed")
@@ -477,11 +579,27 @@ func initLogger(lgr_cfg *LoggerConfig) (adaptor.Logger, error) {
 		options = append(options, logger.SetFilePath(path))
 	}
 
+	if lgr_cfg.File_split != false {
+		split := lgr_cfg.File_split
+		options = append(options, logger.SetFileSplit(split))
+	}
+
+	if lgr_cfg.File_sizelimit != 0 {
+		sizelimit := lgr_cfg.File_sizelimit
+		options = append(options, logger.SetFileSizelimit(sizelimit))
+	}
+
+	if lgr_cfg.File_agelimit != 0 {
+		agelimit := time.Duration(lgr_cfg.File_agelimit) * time.Second
+		options = append(options, logger.SetFileAgelimit(agelimit))
+	}
+
 	return logger.NewGologging(options...)
 }
 
-func initRepository(repo_cfg *RepositoryConfig) (adaptor.Repository, error) {
-	options := make([]func(*repository.Repository), 0)
+func initRepository(name string, repo_cfg *RepositoryConfig,
+	fail func(error)) (adaptor.Repository, error) {
+	options := []func(*repository.Repository){repository.SetName(name)}
 
 	if repo_cfg.Seeds_list != nil {
 		seeds := repo_cfg.Seeds_list
@@ -495,11 +613,54 @@ func initRepository(repo_cfg *RepositoryConfig) (adaptor.Repository, error) {
 		}
 	}
 
+	if repo_cfg.Seeds_ttl != 0 {
+		ttl := time.Duration(repo_cfg.Seeds_ttl) * time.Second
+		options = append(options, repository.SetSeedsTTL(ttl))
+	}
+
+	if repo_cfg.Seeds_retry != 0 {
+		retry := time.Duration(repo_cfg.Seeds_retry) * time.Second
+		options = append(options, repository.SetSeedsRetry(retry))
+	}
+
+	if repo_cfg.Seeds_timeout != 0 {
+		timeout := time.Duration(repo_cfg.Seeds_timeout) * time.Second
+		options = append(options, repository.SetSeedsTimeout(timeout))
+	}
+
+	if repo_cfg.Seeds_retries != 0 {
+		retries := repo_cfg.Seeds_retries
+		options = append(options, repository.SetSeedsRetries(retries))
+	}
+
 	if repo_cfg.Backup_path != "" {
 		path := repo_cfg.Backup_path
 		options = append(options, repository.SetBackupPath(path))
 	}
 
+	if repo_cfg.Backup_format != "" {
+		format, err := repository.ParseFormat(repo_cfg.Backup_format)
+		if err != nil {
+			return nil, err
+		}
+
+		options = append(options, repository.SetBackupFormat(format))
+	}
+
+	if repo_cfg.Address_preference != "" {
+		pref, err := repository.ParsePreference(repo_cfg.Address_preference)
+		if err != nil {
+			return nil, err
+		}
+
+		options = append(options, repository.SetAddressPreference(pref))
+	}
+
+	if repo_cfg.Ipv6_ratio != 0 {
+		ratio := float64(repo_cfg.Ipv6_ratio) / 100
+		options = append(options, repository.SetIPv6Ratio(ratio))
+	}
+
 	if repo_cfg.Backup_rate != 0 {
 		rate := time.Duration(repo_cfg.Backup_rate) * time.Second
 		if rate > time.Minute*15 && rate < time.Hour*24 {
@@ -507,6 +668,34 @@ func initRepository(repo_cfg *RepositoryConfig) (adaptor.Repository, error) {
 		}
 	}
 
+	if repo_cfg.Backup_changes != 0 {
+		changes := repo_cfg.Backup_changes
+		options = append(options, repository.SetBackupChanges(changes))
+	}
+
+	if repo_cfg.Backup_tried != false {
+		sample := repo_cfg.Backup_sample
+		options = append(options, repository.SetSaveTriedOnly(true, sample))
+	}
+
+	if repo_cfg.Backup_failures != 0 {
+		limit := repo_cfg.Backup_failures
+		options = append(options, repository.SetBackupFailureLimit(limit))
+	}
+
+	if repo_cfg.Backup_halt {
+		handler := func(err error) {
+			fail(healthError("repository", name, err))
+		}
+
+		options = append(options, repository.SetBackupFailureHandler(handler))
+	}
+
+	if repo_cfg.Timer_jitter != 0 {
+		jitter := float64(repo_cfg.Timer_jitter) / 100
+		options = append(options, repository.SetTimerJitter(jitter))
+	}
+
 	if repo_cfg.Node_limit != 0 {
 		limit := repo_cfg.Node_limit
 		if limit > 1000 && limit < 1000000 {
@@ -514,27 +703,54 @@ func initRepository(repo_cfg *RepositoryConfig) (adaptor.Repository, error) {
 		}
 	}
 
+	if repo_cfg.Retry_interval != 0 {
+		interval := time.Duration(repo_cfg.Retry_interval) * time.Second
+		options = append(options, repository.SetMinRetryInterval(interval))
+	}
+
 	return repository.New(options...)
 }
 
-func initTracker(tkr_cfg *TrackerConfig) (adaptor.Tracker, error) {
-	options := make([]func(*tracker.Tracker), 0)
+func initTracker(name string, tkr_cfg *TrackerConfig) (adaptor.Tracker,
+	error) {
+	options := []func(*tracker.Tracker){tracker.SetName(name)}
+
+	if tkr_cfg.Tx_window != 0 {
+		window := time.Duration(tkr_cfg.Tx_window) * time.Second
+		options = append(options, tracker.SetTxWindow(window))
+	}
+
+	if tkr_cfg.Announcer_limit != 0 {
+		limit := tkr_cfg.Announcer_limit
+		options = append(options, tracker.SetAnnouncerLimit(limit))
+	}
+
+	if tkr_cfg.Tip_quorum != 0 {
+		quorum := tkr_cfg.Tip_quorum
+		options = append(options, tracker.SetTipQuorum(quorum))
+	}
 
 	return tracker.New(options...)
 }
 
-func initServer(svr_cfg *ServerConfig) (adaptor.Server, error) {
-	options := make([]func(*server.Server), 0)
+func initServer(name string, svr_cfg *ServerConfig) (adaptor.Server, error) {
+	options := []func(*server.Server){server.SetName(name)}
 
 	if svr_cfg.Host_address != "" {
 		host := svr_cfg.Host_address
 		options = append(options, server.SetHostAddress(host))
 	}
 
+	if len(svr_cfg.Listen_addresses) > 0 {
+		addresses := svr_cfg.Listen_addresses
+		options = append(options, server.SetListenAddresses(addresses))
+	}
+
 	return server.New(options...)
 }
 
-func initProcessor(pro_cfg *ProcessorConfig) (adaptor.Processor, error) {
+func initProcessor(name string, pro_cfg *ProcessorConfig) (adaptor.Processor,
+	error) {
 	pType, err := processor.ParseType(pro_cfg.Processor_type)
 	if err != nil {
 		return nil, err
@@ -542,30 +758,56 @@ func initProcessor(pro_cfg *ProcessorConfig) (adaptor.Processor, error) {
 
 	switch pType {
 	case processor.AddressFilterType:
-		return initAddressFilter(pro_cfg)
+		return initAddressFilter(name, pro_cfg)
 
 	case processor.CommandFilterType:
-		return initCommandFilter(pro_cfg)
+		return initCommandFilter(name, pro_cfg)
 
 	case processor.IPFilterType:
-		return initIPFilter(pro_cfg)
+		return initIPFilter(name, pro_cfg)
+
+	case processor.OpReturnFilterType:
+		return initOpReturnFilter(name, pro_cfg)
+
+	case processor.BurstFilterType:
+		return initBurstFilter(name, pro_cfg)
 
 	case processor.FileWriterType:
-		return initFileWriter(pro_cfg)
+		return initFileWriter(name, pro_cfg)
 
 	case processor.RedisWriterType:
-		return initRedisWriter(pro_cfg)
+		return initRedisWriter(name, pro_cfg)
 
 	case processor.ZeroMQWriterType:
-		return initZeroMQWriter(pro_cfg)
+		return initZeroMQWriter(name, pro_cfg)
+
+	case processor.FifoWriterType:
+		return initFifoWriter(name, pro_cfg)
+
+	case processor.PeerWriterType:
+		return initPeerWriter(name, pro_cfg)
 
 	default:
 		return nil, errors.New("invalid processor type")
 	}
 }
 
-func initAddressFilter(pro_cfg *ProcessorConfig) (adaptor.Processor, error) {
-	options := make([]func(adaptor.Processor), 0)
+// initProcessorOptions returns the options common to all processor types.
+func initProcessorOptions(name string,
+	pro_cfg *ProcessorConfig) []func(adaptor.Processor) {
+	options := []func(adaptor.Processor){processor.SetName(name)}
+
+	if pro_cfg.Tag != "" {
+		tag := pro_cfg.Tag
+		options = append(options, processor.SetTag(tag))
+	}
+
+	return options
+}
+
+func initAddressFilter(name string, pro_cfg *ProcessorConfig) (
+	adaptor.Processor, error) {
+	options := initProcessorOptions(name, pro_cfg)
 
 	if len(pro_cfg.Address_list) > 0 {
 		addresses := pro_cfg.Address_list
@@ -575,8 +817,9 @@ func initAddressFilter(pro_cfg *ProcessorConfig) (adaptor.Processor, error) {
 	return processor.NewAddressFilter(options...)
 }
 
-func initCommandFilter(pro_cfg *ProcessorConfig) (adaptor.Processor, error) {
-	options := make([]func(adaptor.Processor), 0)
+func initCommandFilter(name string, pro_cfg *ProcessorConfig) (
+	adaptor.Processor, error) {
+	options := initProcessorOptions(name, pro_cfg)
 
 	if len(pro_cfg.Command_list) > 0 {
 		commands := pro_cfg.Command_list
@@ -586,8 +829,9 @@ func initCommandFilter(pro_cfg *ProcessorConfig) (adaptor.Processor, error) {
 	return processor.NewCommandFilter(options...)
 }
 
-func initIPFilter(pro_cfg *ProcessorConfig) (adaptor.Processor, error) {
-	options := make([]func(adaptor.Processor), 0)
+func initIPFilter(name string, pro_cfg *ProcessorConfig) (adaptor.Processor,
+	error) {
+	options := initProcessorOptions(name, pro_cfg)
 
 	if len(pro_cfg.IP_list) > 0 {
 		ips := pro_cfg.IP_list
@@ -597,7 +841,86 @@ func initIPFilter(pro_cfg *ProcessorConfig) (adaptor.Processor, error) {
 	return processor.NewIPFilter(options...)
 }
 
-func initFileWriter(pro_cfg *ProcessorConfig) (adaptor.Processor, error) {
+func initOpReturnFilter(name string, pro_cfg *ProcessorConfig) (
+	adaptor.Processor, error) {
+	options := initProcessorOptions(name, pro_cfg)
+
+	if len(pro_cfg.Opreturn_list) > 0 {
+		prefixes := pro_cfg.Opreturn_list
+		options = append(options, processor.SetOpReturnPrefixes(prefixes...))
+	}
+
+	return processor.NewOpReturnFilter(options...)
+}
+
+func initBurstFilter(name string, pro_cfg *ProcessorConfig) (adaptor.Processor,
+	error) {
+	options := initProcessorOptions(name, pro_cfg)
+
+	if pro_cfg.Burst_rate != 0 || pro_cfg.Burst_window != 0 {
+		rate := float64(pro_cfg.Burst_rate)
+		if rate == 0 {
+			rate = 100
+		}
+
+		window := time.Duration(pro_cfg.Burst_window) * time.Second
+		if window == 0 {
+			window = time.Minute
+		}
+
+		options = append(options, processor.SetBurstCapture(rate, window))
+	}
+
+	if len(pro_cfg.Burst_triggers) > 0 {
+		triggers := pro_cfg.Burst_triggers
+		options = append(options, processor.SetBurstTriggers(triggers...))
+	}
+
+	if pro_cfg.Burst_sample != 0 {
+		sample := pro_cfg.Burst_sample
+		options = append(options, processor.SetBurstSample(sample))
+	}
+
+	return processor.NewBurstFilter(options...)
+}
+
+func initFileWriter(name string, pro_cfg *ProcessorConfig) (adaptor.Processor,
+	error) {
+	options := initProcessorOptions(name, pro_cfg)
+
+	fileOptions, err := initFileOptions(pro_cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	options = append(options, fileOptions...)
+
+	return processor.NewFileWriter(options...)
+}
+
+func initPeerWriter(name string, pro_cfg *ProcessorConfig) (adaptor.Processor,
+	error) {
+	options := initProcessorOptions(name, pro_cfg)
+
+	fileOptions, err := initFileOptions(pro_cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	options = append(options, processor.SetPeerFileOptions(fileOptions...))
+
+	if pro_cfg.Peer_limit != 0 {
+		limit := pro_cfg.Peer_limit
+		options = append(options, processor.SetPeerLimit(limit))
+	}
+
+	return processor.NewPeerWriter(options...)
+}
+
+// initFileOptions returns the options of the file writer, which are shared by
+// the peer writer for the files of each peer.
+func initFileOptions(pro_cfg *ProcessorConfig) ([]func(adaptor.Processor),
+	error) {
 	options := make([]func(adaptor.Processor), 0)
 
 	if pro_cfg.File_path != "" {
@@ -620,6 +943,27 @@ func initFileWriter(pro_cfg *ProcessorConfig) (adaptor.Processor, error) {
 		options = append(options, processor.SetFileSuffix(suffix))
 	}
 
+	if pro_cfg.File_compression != "" {
+		cType, err := compressor.ParseType(pro_cfg.File_compression)
+		if err != nil {
+			return nil, err
+		}
+
+		var comp adaptor.Compressor
+		switch cType {
+		case compressor.NoneType:
+			comp = compressor.NewDummy()
+
+		case compressor.LZ4Type:
+			comp = compressor.NewLZ4()
+
+		case compressor.GzipType:
+			comp = compressor.NewGzip()
+		}
+
+		options = append(options, processor.SetFileCompressor(comp))
+	}
+
 	if pro_cfg.File_sizelimit != 0 {
 		sizelimit := pro_cfg.File_sizelimit
 		options = append(options, processor.SetFileSizelimit(sizelimit))
@@ -630,11 +974,35 @@ func initFileWriter(pro_cfg *ProcessorConfig) (adaptor.Processor, error) {
 		options = append(options, processor.SetFileAgelimit(agelimit))
 	}
 
-	return processor.NewFileWriter(options...)
+	if pro_cfg.File_disklimit != 0 {
+		limit := pro_cfg.File_disklimit
+		options = append(options, processor.SetMaxDiskUsage(limit))
+	}
+
+	if pro_cfg.File_stream != false {
+		stream := pro_cfg.File_stream
+		options = append(options, processor.SetFileStream(stream))
+	}
+
+	if pro_cfg.File_encoding != "" {
+		encoding, err := processor.ParseEncoding(pro_cfg.File_encoding)
+		if err != nil {
+			return nil, err
+		}
+
+		options = append(options, processor.SetFileEncoding(encoding))
+	}
+
+	if pro_cfg.File_index != "" {
+		options = append(options, processor.SetIndexPath(pro_cfg.File_index))
+	}
+
+	return options, nil
 }
 
-func initRedisWriter(pro_cfg *ProcessorConfig) (adaptor.Processor, error) {
-	options := make([]func(adaptor.Processor), 0)
+func initRedisWriter(name string, pro_cfg *ProcessorConfig) (adaptor.Processor,
+	error) {
+	options := initProcessorOptions(name, pro_cfg)
 
 	if pro_cfg.Redis_host != "" {
 		host := pro_cfg.Redis_host
@@ -654,25 +1022,133 @@ func initRedisWriter(pro_cfg *ProcessorConfig) (adaptor.Processor, error) {
 	return processor.NewRedisWriter(options...)
 }
 
-func initZeroMQWriter(pro_cfg *ProcessorConfig) (adaptor.Processor, error) {
-	options := make([]func(adaptor.Processor), 0)
+func initZeroMQWriter(name string, pro_cfg *ProcessorConfig) (adaptor.Processor,
+	error) {
+	options := initProcessorOptions(name, pro_cfg)
 
 	if pro_cfg.Zeromq_host != "" {
 		host := pro_cfg.Zeromq_host
 		options = append(options, processor.SetZeromqHost(host))
 	}
 
+	if pro_cfg.Zeromq_warn != false {
+		warn := pro_cfg.Zeromq_warn
+		options = append(options,
+			processor.SetZeromqWarnOnNoSubscribers(warn))
+	}
+
+	if pro_cfg.Zeromq_buffer != 0 {
+		limit := pro_cfg.Zeromq_buffer
+		options = append(options, processor.SetZeromqBuffer(limit))
+	}
+
 	return processor.NewZeroMQWriter(options...)
 }
 
-func initManager(mgr_cfg *ManagerConfig) (adaptor.Manager, error) {
-	options := make([]func(*manager.Manager), 0)
+func initFifoWriter(name string, pro_cfg *ProcessorConfig) (adaptor.Processor,
+	error) {
+	options := initProcessorOptions(name, pro_cfg)
+
+	if pro_cfg.Fifo_path != "" {
+		path := pro_cfg.Fifo_path
+		options = append(options, processor.SetFifoPath(path))
+	}
+
+	return processor.NewFifoWriter(options...)
+}
+
+func initManager(name string, mgr_cfg *ManagerConfig) (adaptor.Manager,
+	error) {
+	options := []func(*manager.Manager){manager.SetName(name)}
 
 	if mgr_cfg.Connection_limit != 0 {
 		limit := mgr_cfg.Connection_limit
 		options = append(options, manager.SetConnectionLimit(limit))
 	}
 
+	if mgr_cfg.Dial_concurrency != 0 {
+		concurrency := mgr_cfg.Dial_concurrency
+		options = append(options, manager.SetDialConcurrency(concurrency))
+	}
+
+	if mgr_cfg.Routine_limit != 0 {
+		limit := mgr_cfg.Routine_limit
+		options = append(options, manager.SetRoutineLimit(limit))
+	}
+
+	if mgr_cfg.Peer_maxage != 0 {
+		maxage := time.Duration(mgr_cfg.Peer_maxage) * time.Second
+		options = append(options, manager.SetPeerMaxAge(maxage))
+	}
+
+	if mgr_cfg.Addr_maxage != 0 {
+		maxage := time.Duration(mgr_cfg.Addr_maxage) * time.Second
+		options = append(options, manager.SetAddrMaxAge(maxage))
+	}
+
+	if mgr_cfg.Addr_limit != 0 {
+		options = append(options, manager.SetAddrLimit(mgr_cfg.Addr_limit))
+	}
+
+	if mgr_cfg.Message_cap != 0 {
+		limit := mgr_cfg.Message_cap
+		options = append(options, manager.SetPerPeerMessageCap(limit))
+	}
+
+	if mgr_cfg.Poll_cooldown != 0 {
+		cooldown := time.Duration(mgr_cfg.Poll_cooldown) * time.Second
+		options = append(options, manager.SetPollCooldown(cooldown))
+	}
+
+	if mgr_cfg.Group_limit != 0 || mgr_cfg.Group_prefix != 0 {
+		limit := mgr_cfg.Group_limit
+		prefix := mgr_cfg.Group_prefix
+		options = append(options, manager.SetMaxPeersPerGroup(limit, prefix))
+	}
+
+	if mgr_cfg.Version_timeout != 0 {
+		timeout := time.Duration(mgr_cfg.Version_timeout) * time.Second
+		options = append(options, manager.SetVersionTimeout(timeout))
+	}
+
+	if mgr_cfg.Handshake_timeout != 0 {
+		timeout := time.Duration(mgr_cfg.Handshake_timeout) * time.Second
+		options = append(options, manager.SetHandshakeTimeout(timeout))
+	}
+
+	if mgr_cfg.Topology_rate != 0 {
+		rate := time.Duration(mgr_cfg.Topology_rate) * time.Second
+		options = append(options, manager.SetTopologyInterval(rate))
+	}
+
+	if mgr_cfg.Tcp_keepalive != 0 {
+		idle := time.Duration(mgr_cfg.Tcp_keepalive) * time.Second
+		options = append(options, manager.SetTCPKeepAlive(idle))
+	}
+
+	if mgr_cfg.Lifetime_max != 0 {
+		min := time.Duration(mgr_cfg.Lifetime_min) * time.Second
+		max := time.Duration(mgr_cfg.Lifetime_max) * time.Second
+		options = append(options, manager.SetConnectionLifetime(min, max))
+	}
+
+	if mgr_cfg.Idle_timeout != 0 || mgr_cfg.Idle_inbound != 0 {
+		outbound := time.Duration(mgr_cfg.Idle_timeout) * time.Second
+		inbound := time.Duration(mgr_cfg.Idle_inbound) * time.Second
+		options = append(options, manager.SetPeerIdleTimeout(outbound,
+			inbound))
+	}
+
+	if mgr_cfg.Timer_jitter != 0 {
+		jitter := float64(mgr_cfg.Timer_jitter) / 100
+		options = append(options, manager.SetTimerJitter(jitter))
+	}
+
+	if mgr_cfg.Bandwidth_budget != 0 {
+		budget := mgr_cfg.Bandwidth_budget
+		options = append(options, manager.SetBandwidthBudget(budget))
+	}
+
 	if mgr_cfg.Connection_rate != 0 {
 		rate := time.Second / time.Duration(mgr_cfg.Connection_rate)
 		options = append(options, manager.SetConnectionRate(rate))
@@ -688,16 +1164,104 @@ func initManager(mgr_cfg *ManagerConfig) (adaptor.Manager, error) {
 		options = append(options, manager.SetProtocolVersion(version))
 	}
 
+	if mgr_cfg.Protocol_minimum != 0 {
+		version := mgr_cfg.Protocol_minimum
+		options = append(options, manager.SetMinProtocolVersion(version))
+	}
+
 	if mgr_cfg.Ticker_interval != 0 {
 		interval := time.Second * time.Duration(mgr_cfg.Ticker_interval)
 		options = append(options, manager.SetTickerInterval(interval))
 	}
 
+	if len(mgr_cfg.Proxy_list) > 0 {
+		proxies := make([]peer.ProxySpec, 0, len(mgr_cfg.Proxy_list))
+		for _, proxy := range mgr_cfg.Proxy_list {
+			spec, err := peer.ParseProxy(proxy)
+			if err != nil {
+				return nil, err
+			}
+
+			proxies = append(proxies, spec)
+		}
+
+		options = append(options, manager.SetProxies(proxies))
+	}
+
+	if mgr_cfg.Proxy_rotation != false {
+		rotation := mgr_cfg.Proxy_rotation
+		options = append(options, manager.SetProxyRotation(rotation))
+	}
+
+	if mgr_cfg.Proxy_fallback != false {
+		fallback := mgr_cfg.Proxy_fallback
+		options = append(options, manager.SetProxyFallback(fallback))
+	}
+
+	if len(mgr_cfg.Local_addresses) > 0 {
+		ips := make([]net.IP, 0, len(mgr_cfg.Local_addresses))
+		for _, address := range mgr_cfg.Local_addresses {
+			ip := net.ParseIP(address)
+			if ip == nil {
+				return nil, errors.New("invalid local address")
+			}
+
+			ips = append(ips, ip)
+		}
+
+		options = append(options, manager.SetLocalAddrs(ips))
+	}
+
+	if len(mgr_cfg.Trusted_peers) > 0 {
+		peers := mgr_cfg.Trusted_peers
+		options = append(options, manager.SetTrustedPeers(peers))
+	}
+
+	if mgr_cfg.History_limit != 0 {
+		path := mgr_cfg.History_path
+		limit := mgr_cfg.History_limit
+		options = append(options, manager.SetPeerHistory(path, limit))
+	}
+
+	if mgr_cfg.Passive_only != false {
+		passive := mgr_cfg.Passive_only
+		options = append(options, manager.SetPassiveOnly(passive))
+	}
+
+	if mgr_cfg.Disable_relay != false {
+		relay := !mgr_cfg.Disable_relay
+		options = append(options, manager.SetRelay(relay))
+	}
+
+	if mgr_cfg.Record_raw != false {
+		raw := mgr_cfg.Record_raw
+		options = append(options, manager.SetRecordRaw(raw))
+	}
+
+	if mgr_cfg.Listen_only != false {
+		out := !mgr_cfg.Listen_only
+		options = append(options, manager.SetConnectOut(out))
+	}
+
+	if mgr_cfg.Ignore_addr != false || mgr_cfg.Ignore_inbound != false ||
+		mgr_cfg.Learn_version != false {
+		addr := !mgr_cfg.Ignore_addr
+		version := mgr_cfg.Learn_version
+		inbound := !mgr_cfg.Ignore_inbound
+		options = append(options, manager.SetAddrSources(addr, version,
+			inbound))
+	}
+
+	if mgr_cfg.Ignore_services != false {
+		check := !mgr_cfg.Ignore_services
+		options = append(options, manager.SetServiceChecks(check))
+	}
+
 	return manager.New(options...)
 }
 
 func (supervisor *Supervisor) Start() {
-	// start the module execution
+	// start the module execution, each module after the modules it depends on
 	supervisor.log.Info("[SUP] Start: begin")
 	supervisor.log.Info("[SUP] Start: starting loggers")
 
@@ -717,16 +1281,10 @@ func (supervisor *Supervisor) Start() {
 		tkr.Start()
 	}
 
-	supervisor.log.Info("[SUP] Start: starting servers")
-
-	for _, svr := range supervisor.svr {
-		svr.Start()
-	}
-
 	supervisor.log.Info("[SUP] Start: starting processors")
 
-	for _, pro := range supervisor.pro {
-		pro.Start()
+	for _, name := range supervisor.order {
+		supervisor.pro[name].Start()
 	}
 
 	supervisor.log.Info("[SUP] Start: starting managers")
@@ -735,40 +1293,74 @@ func (supervisor *Supervisor) Start() {
 		mgr.Start()
 	}
 
+	supervisor.log.Info("[SUP] Start: starting servers")
+
+	for _, svr := range supervisor.svr {
+		svr.Start()
+	}
+
+	if supervisor.admin != nil {
+		supervisor.log.Info("[SUP] Start: starting admin interface")
+		supervisor.admin.Start()
+	}
+
 	supervisor.log.Info("[SUP] Start: completed")
 }
 
-func (supervisor *Supervisor) Stop() {
-	// stop the module execution
+// Stop stops all modules. If any of them reports a failure once stopped, like
+// a file or backup that could not be written, the failures are returned as
+// StopError.
+func (supervisor *Supervisor) Stop() error {
+	// stop the module execution in the reverse order of the start, so that no
+	// module is stopped while another one still depends on it
 	supervisor.log.Info("[SUP] Stop: begin")
+
+	var faults []error
+
+	if supervisor.admin != nil {
+		supervisor.log.Info("[SUP] Stop: stopping admin interface")
+		supervisor.admin.Stop()
+	}
+
+	supervisor.log.Info("[SUP] Stop: stopping servers")
+
+	for name, svr := range supervisor.svr {
+		svr.Stop()
+		faults = supervisor.checkFault(faults, "server", name, svr)
+	}
+
 	supervisor.log.Info("[SUP] Stop: stopping managers")
 
-	for _, mgr := range supervisor.mgr {
+	for name, mgr := range supervisor.mgr {
 		mgr.Stop()
+		faults = supervisor.checkFault(faults, "manager", name, mgr)
 	}
 
 	supervisor.log.Info("[SUP] Stop: stopping processors")
 
-	for _, pro := range supervisor.pro {
+	for i := len(supervisor.order) - 1; i >= 0; i-- {
+		name := supervisor.order[i]
+		pro := supervisor.pro[name]
 		pro.Stop()
-	}
-
-	supervisor.log.Info("[SUP] Stop: stopping servers")
-
-	for _, svr := range supervisor.svr {
-		svr.Stop()
+		faults = supervisor.checkFault(faults, "processor", name, pro)
 	}
 
 	supervisor.log.Info("[SUP] Stop: stopping trackers")
 
-	for _, tkr := range supervisor.tkr {
+	for name, tkr := range supervisor.tkr {
 		tkr.Stop()
+		faults = supervisor.checkFault(faults, "tracker", name, tkr)
 	}
 
 	supervisor.log.Info("[SUP] Stop: stopping repositories")
 
-	for _, repo := range supervisor.repo {
+	for name, repo := range supervisor.repo {
 		repo.Stop()
+		faults = supervisor.checkFault(faults, "repository", name, repo)
+	}
+
+	if len(faults) > 0 {
+		supervisor.log.Error("[SUP] Stop: %v modules failed", len(faults))
 	}
 
 	supervisor.log.Info("[SUP] Stop: stopping loggers")
@@ -778,4 +1370,161 @@ func (supervisor *Supervisor) Stop() {
 	}
 
 	supervisor.log.Info("[SUP] Stop: completed")
+
+	if len(faults) > 0 {
+		return &StopError{Faults: faults}
+	}
+
+	return nil
+}
+
+// Halted returns a channel that receives the error of a module that failed in
+// a way that should stop the collector, like a repository that can no longer
+// save its nodes.
+func (supervisor *Supervisor) Halted() <-chan error {
+	return supervisor.halt
+}
+
+// fail reports a module failure that should stop the collector. Only the
+// first one is kept, as the collector stops anyway.
+func (supervisor *Supervisor) fail(err error) {
+	supervisor.log.Critical("[SUP] Halt: %v", err)
+
+	select {
+	case supervisor.halt <- err:
+	default:
+	}
+}
+
+// faulter is implemented by modules that can still report a failure once they
+// are stopped, like a writer that could not close its file or a repository
+// that could not save its nodes.
+type faulter interface {
+	Fault() error
+}
+
+// StopError lists the modules that reported a failure on shutdown.
+type StopError struct {
+	Faults []error
+}
+
+func (e *StopError) Error() string {
+	msgs := make([]string, 0, len(e.Faults))
+	for _, fault := range e.Faults {
+		msgs = append(msgs, fault.Error())
+	}
+
+	return "shutdown failed: " + strings.Join(msgs, "; ")
+}
+
+// checkFault adds the failure of a stopped module to the given faults, if it
+// reports one, and logs it while the loggers are still running.
+func (supervisor *Supervisor) checkFault(faults []error, module string,
+	name string, m interface{}) []error {
+	f, ok := m.(faulter)
+	if !ok {
+		return faults
+	}
+
+	err := f.Fault()
+	if err == nil {
+		return faults
+	}
+
+	err = healthError(module, name, err)
+	supervisor.log.Error("[SUP] Stop: %v", err)
+
+	return append(faults, err)
+}
+
+// orderProcessors returns the names of all processors, ordered so that every
+// processor comes after the processors it forwards records to. Processors that
+// are part of a cycle are logged and ordered arbitrarily within the cycle.
+func (supervisor *Supervisor) orderProcessors(cfg *Config) []string {
+	order := make([]string, 0, len(supervisor.pro))
+	visited := make(map[string]bool)
+	visiting := make(map[string]bool)
+
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+
+		if visiting[name] {
+			supervisor.log.Warning("[SUP] Init: processor %v is part of a "+
+				"cycle", name)
+			return
+		}
+
+		visiting[name] = true
+		pro_cfg, ok := cfg.Processor[name]
+		if ok {
+			for _, next := range pro_cfg.Next {
+				_, ok := supervisor.pro[next]
+				if ok {
+					visit(next)
+				}
+			}
+		}
+		visiting[name] = false
+
+		visited[name] = true
+		order = append(order, name)
+	}
+
+	for name := range supervisor.pro {
+		visit(name)
+	}
+
+	return order
+}
+
+// Healthy checks the health of all modules and returns the first problem that
+// was found, prefixed with the type and name of the module.
+func (supervisor *Supervisor) Healthy() (bool, error) {
+	for name, repo := range supervisor.repo {
+		ok, err := repo.Healthy()
+		if !ok {
+			return false, healthError("repository", name, err)
+		}
+	}
+
+	for name, tkr := range supervisor.tkr {
+		ok, err := tkr.Healthy()
+		if !ok {
+			return false, healthError("tracker", name, err)
+		}
+	}
+
+	for name, svr := range supervisor.svr {
+		ok, err := svr.Healthy()
+		if !ok {
+			return false, healthError("server", name, err)
+		}
+	}
+
+	for name, pro := range supervisor.pro {
+		ok, err := pro.Healthy()
+		if !ok {
+			return false, healthError("processor", name, err)
+		}
+	}
+
+	for name, mgr := range supervisor.mgr {
+		ok, err := mgr.Healthy()
+		if !ok {
+			return false, healthError("manager", name, err)
+		}
+	}
+
+	return true, nil
+}
+
+func healthError(module string, name string, err error) error {
+	if err == nil {
+		err = errors.New("unhealthy")
+	}
+
+	return errors.New(module + " " + name + ": " + err.Error())
 }
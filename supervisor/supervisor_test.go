@@ -0,0 +1,471 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package supervisor
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/CIRCL/pbtc/adaptor"
+	"github.com/CIRCL/pbtc/pbtctest"
+	"github.com/CIRCL/pbtc/records"
+)
+
+// testScript pays to the key hash of testAddress.
+const testAddress = "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH"
+
+var testScript, _ = hex.DecodeString(
+	"76a914751e76e8199196d454941c45d1b3a323f1433bd688ac")
+
+// newTestSupervisor creates a supervisor from the given configuration, in a
+// temporary directory that holds all of its files.
+func newTestSupervisor(t *testing.T, cfg string) *Supervisor {
+	dir := t.TempDir()
+	err := ioutil.WriteFile(filepath.Join(dir, "pbtc.cfg"), []byte(cfg),
+		0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Chdir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	supervisor, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return supervisor
+}
+
+// testTx returns a transaction record with the given output scripts.
+func testTx(scripts ...[]byte) adaptor.Record {
+	msg := &wire.MsgTx{Version: 1}
+	for _, script := range scripts {
+		msg.AddTxOut(wire.NewTxOut(1000, script))
+	}
+
+	ra := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 8333}
+	la := &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 50000}
+
+	return records.NewTransactionRecord(msg, ra, la, time.Unix(1, 0))
+}
+
+func TestProcessorsFromConfig(t *testing.T) {
+	supervisor := newTestSupervisor(t, `
+[logger]
+console-enabled=false
+
+[repository]
+
+[tracker]
+
+[manager]
+processor="filter"
+
+[processor "filter"]
+processor-type=ADDRESS_FILTER
+address-list="`+testAddress+`"
+next="writer"
+
+[processor "writer"]
+processor-type=FILE_WRITER
+file-path="dump/"
+file-suffix=".txt"
+`)
+
+	filter, ok := supervisor.pro["filter"]
+	if !ok {
+		t.Fatal("no filter")
+	}
+
+	writer, ok := supervisor.pro["writer"]
+	if !ok {
+		t.Fatal("no writer")
+	}
+
+	next := filter.Next()
+	if len(next) != 1 || next[0] != writer {
+		t.Errorf("filter forwards to %v", next)
+	}
+
+	processors := supervisor.mgr[""].Processors()
+	if len(processors) != 1 || processors[0] != filter {
+		t.Errorf("manager feeds %v", processors)
+	}
+
+	// the writer has to be started first, so the filter can forward to it
+	writer.Start()
+	filter.Start()
+
+	filter.Process(testTx(testScript))
+	filter.Process(testTx())
+
+	filter.Stop()
+	writer.Stop()
+
+	files, err := filepath.Glob("dump/*.txt")
+	if err != nil || len(files) != 1 {
+		t.Fatalf("wrote files %v (%v)", files, err)
+	}
+
+	data, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only the transaction paying to the address makes it to the file
+	output := string(data)
+	if strings.Count(output, "|tx|") != 1 ||
+		!strings.Contains(output, testAddress) {
+		t.Errorf("wrote %q", output)
+	}
+}
+
+func TestOrderProcessors(t *testing.T) {
+	supervisor := &Supervisor{
+		pro: map[string]adaptor.Processor{
+			"filter":  nil,
+			"tagger":  nil,
+			"writer":  nil,
+			"archive": nil,
+			"loop1":   nil,
+			"loop2":   nil,
+		},
+		log: pbtctest.Log{},
+	}
+
+	cfg := &Config{
+		Processor: map[string]*ProcessorConfig{
+			"filter":  {Next: []string{"tagger", "archive", "missing"}},
+			"tagger":  {Next: []string{"writer"}},
+			"archive": {Next: []string{"writer"}},
+			"loop1":   {Next: []string{"loop2"}},
+			"loop2":   {Next: []string{"loop1"}},
+		},
+	}
+
+	order := supervisor.orderProcessors(cfg)
+	if len(order) != len(supervisor.pro) {
+		t.Fatalf("ordered %v", order)
+	}
+
+	index := make(map[string]int)
+	for i, name := range order {
+		index[name] = i
+	}
+
+	// processors start after and stop before the ones they feed into
+	deps := [][2]string{
+		{"filter", "tagger"},
+		{"filter", "archive"},
+		{"tagger", "writer"},
+		{"archive", "writer"},
+	}
+	for _, dep := range deps {
+		if index[dep[0]] < index[dep[1]] {
+			t.Errorf("%v ordered before %v in %v", dep[0], dep[1], order)
+		}
+	}
+}
+
+// faultyProcessor reports a failure once it is stopped, like a writer that
+// could not flush its file.
+type faultyProcessor struct {
+	*pbtctest.Processor
+
+	fault error
+}
+
+func (pro *faultyProcessor) Fault() error {
+	return pro.fault
+}
+
+func TestStopFaults(t *testing.T) {
+	supervisor := &Supervisor{
+		pro: map[string]adaptor.Processor{
+			"good":   pbtctest.NewProcessor(),
+			"writer": &faultyProcessor{pbtctest.NewProcessor(), nil},
+			"broken": &faultyProcessor{pbtctest.NewProcessor(),
+				errors.New("flush failed")},
+		},
+		order: []string{"good", "writer", "broken"},
+		log:   pbtctest.Log{},
+	}
+
+	err := supervisor.Stop()
+	stopErr, ok := err.(*StopError)
+	if !ok {
+		t.Fatalf("stopped with %v", err)
+	}
+
+	if len(stopErr.Faults) != 1 ||
+		stopErr.Faults[0].Error() != "processor broken: flush failed" {
+		t.Errorf("stopped with faults %v", stopErr.Faults)
+	}
+
+	// without faults, the shutdown is clean
+	delete(supervisor.pro, "broken")
+	supervisor.order = supervisor.order[:2]
+	err = supervisor.Stop()
+	if err != nil {
+		t.Errorf("clean shutdown failed (%v)", err)
+	}
+}
+
+func TestNetworks(t *testing.T) {
+	supervisor := newTestSupervisor(t, `
+[logger]
+console-enabled=false
+
+[repository "main"]
+backup-path="main.dat"
+
+[repository "test"]
+backup-path="test.dat"
+
+[tracker "main"]
+
+[tracker "test"]
+
+[manager "main"]
+protocol-magic=0xd9b4bef9
+repository="main"
+tracker="main"
+
+[manager "test"]
+protocol-magic=0x0709110b
+repository="test"
+tracker="test"
+`)
+
+	main, test := supervisor.mgr["main"], supervisor.mgr["test"]
+	if main == nil || test == nil {
+		t.Fatalf("managers %v", supervisor.mgr)
+	}
+
+	if main.Network() != wire.MainNet || test.Network() != wire.TestNet3 {
+		t.Errorf("managers on %v and %v", main.Network(), test.Network())
+	}
+
+	if supervisor.repo["main"] == supervisor.repo["test"] {
+		t.Error("networks share a repository")
+	}
+}
+
+func TestNetworksConflicts(t *testing.T) {
+	configs := map[string]string{
+		"backup path": `
+[logger]
+console-enabled=false
+
+[repository "main"]
+backup-path="nodes.dat"
+
+[repository "test"]
+backup-path="nodes.dat"
+`,
+		"repository": `
+[logger]
+console-enabled=false
+
+[repository]
+
+[tracker]
+
+[manager "main"]
+protocol-magic=0xd9b4bef9
+
+[manager "test"]
+protocol-magic=0x0709110b
+`,
+	}
+
+	for name, cfg := range configs {
+		dir := t.TempDir()
+		err := ioutil.WriteFile(filepath.Join(dir, "pbtc.cfg"), []byte(cfg),
+			0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		wd, err := os.Getwd()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.Chdir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = New()
+		os.Chdir(wd)
+		if err == nil {
+			t.Errorf("%v: networks in conflict accepted", name)
+		}
+	}
+}
+
+// namesLog keeps the messages logged to it.
+type namesLog struct {
+	pbtctest.Log
+
+	messages []string
+}
+
+func (log *namesLog) Info(format string, args ...interface{}) {
+	log.messages = append(log.messages, fmt.Sprintf(format, args...))
+}
+
+func TestModuleNames(t *testing.T) {
+	supervisor := newTestSupervisor(t, `
+[logger]
+console-enabled=false
+
+[repository "main"]
+backup-path="main.dat"
+
+[tracker "main"]
+
+[manager "main"]
+repository="main"
+tracker="main"
+processor="first"
+
+[processor "first"]
+processor-type=FILE_WRITER
+file-path="first/"
+
+[processor "second"]
+processor-type=FILE_WRITER
+file-path="second/"
+`)
+
+	// every module knows the name of its configuration entry
+	tests := []struct {
+		module string
+		name   string
+		want   string
+	}{
+		{"repository", supervisor.repo["main"].Name(), "main"},
+		{"tracker", supervisor.tkr["main"].Name(), "main"},
+		{"manager", supervisor.mgr["main"].Name(), "main"},
+		{"processor", supervisor.pro["first"].Name(), "first"},
+		{"processor", supervisor.pro["second"].Name(), "second"},
+	}
+
+	for _, test := range tests {
+		if test.name != test.want {
+			t.Errorf("%v %q named %q", test.module, test.want, test.name)
+		}
+	}
+
+	// two writers of one type log under different tags
+	for _, name := range []string{"first", "second"} {
+		log := &namesLog{}
+		pro := supervisor.pro[name]
+		pro.SetLog(log)
+		pro.Start()
+		pro.Stop()
+
+		if len(log.messages) == 0 {
+			t.Errorf("writer %q logged nothing", name)
+		}
+
+		for _, message := range log.messages {
+			if !strings.Contains(message, ":"+name+"]") {
+				t.Errorf("writer %q logged %q", name, message)
+			}
+		}
+	}
+}
+
+func TestGeoDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geo.csv")
+	err := ioutil.WriteFile(path, []byte("8.8.8.0/24,US,15169\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	supervisor := newTestSupervisor(t, `
+[logger]
+console-enabled=false
+
+[processor "geo"]
+processor-type=COMMAND_FILTER
+command-list=ping
+geo-db="`+path+`"
+
+[processor "missing"]
+processor-type=COMMAND_FILTER
+command-list=ping
+geo-db="`+path+`.missing"
+`)
+
+	// a database that can't be loaded leaves the processor without it
+	for name, annotated := range map[string]bool{"geo": true,
+		"missing": false} {
+		pro, ok := supervisor.pro[name]
+		if !ok {
+			t.Errorf("processor %q not created", name)
+			continue
+		}
+
+		next := pbtctest.NewProcessor()
+		pro.SetLog(pbtctest.Log{})
+		pro.AddNext(next)
+		pro.Start()
+
+		ra := &net.TCPAddr{IP: net.ParseIP("8.8.8.8"), Port: 8333}
+		pro.Process(records.NewPingRecord(wire.NewMsgPing(1), ra, nil,
+			time.Unix(1, 0)))
+		pro.Stop()
+
+		forwarded := next.Records()
+		if len(forwarded) != 1 {
+			t.Fatalf("%v: forwarded %v records", name, len(forwarded))
+		}
+
+		_, ok = forwarded[0].(*records.GeoRecord)
+		if ok != annotated {
+			t.Errorf("%v: forwarded %v", name, forwarded[0])
+		}
+	}
+}
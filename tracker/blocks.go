@@ -0,0 +1,142 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package tracker
+
+import (
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/CIRCL/pbtc/adaptor"
+)
+
+// deliveryWindow is how long we wait for an announced block to be delivered
+// before the announcements are forgotten without a sample.
+const deliveryWindow = time.Hour
+
+// blockDelivery keeps the time at which each peer first announced a block we
+// have not received yet, and the delivery times measured per peer.
+type blockDelivery struct {
+	pending map[wire.ShaHash]map[string]time.Time
+	peers   map[string]*adaptor.Latency
+	network adaptor.Latency
+}
+
+func newBlockDelivery() *blockDelivery {
+	return &blockDelivery{
+		pending: make(map[wire.ShaHash]map[string]time.Time),
+		peers:   make(map[string]*adaptor.Latency),
+	}
+}
+
+// announce registers the first announcement of a block by a peer. Blocks we
+// already received are ignored, as there is no delivery left to wait for.
+func (bd *blockDelivery) announce(hash wire.ShaHash, peer string,
+	stamp time.Time) {
+	announced, ok := bd.pending[hash]
+	if !ok {
+		announced = make(map[string]time.Time)
+		bd.pending[hash] = announced
+	}
+
+	_, ok = announced[peer]
+	if ok {
+		return
+	}
+
+	announced[peer] = stamp
+}
+
+// deliver adds the time between announcement and delivery of the block to the
+// statistics of every peer that announced it, and forgets the announcements.
+func (bd *blockDelivery) deliver(hash wire.ShaHash, stamp time.Time) {
+	announced, ok := bd.pending[hash]
+	if !ok {
+		return
+	}
+
+	delete(bd.pending, hash)
+	for peer, first := range announced {
+		delay := stamp.Sub(first)
+		if delay < 0 {
+			delay = 0
+		}
+
+		l, ok := bd.peers[peer]
+		if !ok {
+			l = &adaptor.Latency{}
+			bd.peers[peer] = l
+		}
+
+		l.Add(delay)
+		bd.network.Add(delay)
+	}
+}
+
+// prune forgets announcements of blocks that were never delivered.
+func (bd *blockDelivery) prune(now time.Time) {
+	for hash, announced := range bd.pending {
+		expired := true
+		for _, first := range announced {
+			if now.Sub(first) <= deliveryWindow {
+				expired = false
+				break
+			}
+		}
+
+		if expired {
+			delete(bd.pending, hash)
+		}
+	}
+}
+
+// DeliverBlock registers that we received the full block from a peer, which
+// completes the delivery of the block for all peers that announced it.
+func (tracker *Tracker) DeliverBlock(hash wire.ShaHash, peer string,
+	stamp time.Time) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	tracker.delivery.deliver(hash, stamp)
+}
+
+// BlockDelivery returns the histogram of the times between the peer announcing
+// a block and us receiving the full block, from whichever peer.
+func (tracker *Tracker) BlockDelivery(peer string) adaptor.Latency {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	l, ok := tracker.delivery.peers[peer]
+	if !ok {
+		return adaptor.Latency{}
+	}
+
+	return *l
+}
+
+// BlockDeliveryDistribution returns the histogram of the block delivery times
+// of all peers, which shows how fast blocks propagate after announcement.
+func (tracker *Tracker) BlockDeliveryDistribution() adaptor.Latency {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	return tracker.delivery.network
+}
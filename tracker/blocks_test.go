@@ -0,0 +1,98 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestBlockDelivery(t *testing.T) {
+	tkr, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	block := wire.ShaHash{1}
+	tkr.AnnounceBlock(block, "a", now)
+	tkr.AnnounceBlock(block, "b", now.Add(300*time.Millisecond))
+
+	// only the first announcement of a peer counts
+	tkr.AnnounceBlock(block, "a", now.Add(time.Second))
+
+	tkr.DeliverBlock(block, "b", now.Add(40*time.Millisecond+
+		300*time.Millisecond))
+
+	a := tkr.BlockDelivery("a")
+	if a.Samples != 1 || a.Percentile(1) != 500*time.Millisecond {
+		t.Errorf("delivery for a %+v, want one sample up to 500ms", a)
+	}
+
+	b := tkr.BlockDelivery("b")
+	if b.Samples != 1 || b.Percentile(1) != 50*time.Millisecond {
+		t.Errorf("delivery for b %+v, want one sample up to 50ms", b)
+	}
+
+	network := tkr.BlockDeliveryDistribution()
+	if network.Samples != 2 {
+		t.Errorf("%v samples for the network, want 2", network.Samples)
+	}
+
+	// a delivered block is not measured twice
+	tkr.DeliverBlock(block, "a", now.Add(time.Minute))
+	if tkr.BlockDeliveryDistribution().Samples != 2 {
+		t.Errorf("second delivery added samples")
+	}
+
+	// announcements of known blocks have no delivery to wait for
+	known := wire.ShaHash{2}
+	tkr.AddBlock(known)
+	tkr.AnnounceBlock(known, "c", now)
+	tkr.DeliverBlock(known, "c", now.Add(time.Second))
+	if tkr.BlockDelivery("c").Samples != 0 {
+		t.Errorf("delivery measured for a known block")
+	}
+}
+
+func TestBlockDeliveryPrune(t *testing.T) {
+	bd := newBlockDelivery()
+
+	now := time.Now()
+	block := wire.ShaHash{1}
+	bd.announce(block, "a", now)
+	bd.prune(now.Add(deliveryWindow))
+	if len(bd.pending) != 1 {
+		t.Fatalf("announcement pruned within the delivery window")
+	}
+
+	bd.prune(now.Add(deliveryWindow + time.Second))
+	if len(bd.pending) != 0 {
+		t.Fatalf("announcement kept after the delivery window")
+	}
+
+	bd.deliver(block, now.Add(2*deliveryWindow))
+	if bd.network.Samples != 0 {
+		t.Errorf("delivery measured after pruning")
+	}
+}
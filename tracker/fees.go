@@ -0,0 +1,84 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package tracker
+
+import (
+	"sort"
+
+	"github.com/CIRCL/pbtc/adaptor"
+)
+
+// ReportFeeFilter registers the minimum relay fee a peer announced in a
+// feefilter message. Only the latest fee of each peer is kept.
+func (tracker *Tracker) ReportFeeFilter(peer string, fee int64) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	tracker.fees[peer] = fee
+}
+
+// MinRelayFeeDistribution returns the distribution of the minimum relay fees
+// last announced by our peers, which shows how far their mempool policies
+// diverge. The quartiles are taken from the sorted fees, rounding down.
+func (tracker *Tracker) MinRelayFeeDistribution() adaptor.FeeDistribution {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	dist := adaptor.FeeDistribution{
+		Peers: len(tracker.fees),
+		Fees:  make(map[int64]int),
+	}
+
+	if len(tracker.fees) == 0 {
+		return dist
+	}
+
+	fees := make([]int64, 0, len(tracker.fees))
+	for _, fee := range tracker.fees {
+		fees = append(fees, fee)
+		dist.Fees[fee]++
+	}
+
+	sort.Sort(byFee(fees))
+	last := len(fees) - 1
+	dist.Min = fees[0]
+	dist.Lower = fees[last/4]
+	dist.Median = fees[last/2]
+	dist.Upper = fees[last*3/4]
+	dist.Max = fees[last]
+
+	return dist
+}
+
+// byFee sorts fees in ascending order.
+type byFee []int64
+
+func (fees byFee) Len() int {
+	return len(fees)
+}
+
+func (fees byFee) Less(i, j int) bool {
+	return fees[i] < fees[j]
+}
+
+func (fees byFee) Swap(i, j int) {
+	fees[i], fees[j] = fees[j], fees[i]
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package tracker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/CIRCL/pbtc/adaptor"
+)
+
+func TestMinRelayFeeDistribution(t *testing.T) {
+	tkr, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	empty := tkr.MinRelayFeeDistribution()
+	if empty.Peers != 0 || len(empty.Fees) != 0 {
+		t.Errorf("distribution without peers %+v", empty)
+	}
+
+	// only the latest fee of a peer counts
+	tkr.ReportFeeFilter("a", 5000)
+	tkr.ReportFeeFilter("a", 1000)
+	tkr.ReportFeeFilter("b", 1000)
+	tkr.ReportFeeFilter("c", 2000)
+	tkr.ReportFeeFilter("d", 3000)
+	tkr.ReportFeeFilter("e", 10000)
+
+	expected := adaptor.FeeDistribution{
+		Peers:  5,
+		Min:    1000,
+		Lower:  1000,
+		Median: 2000,
+		Upper:  3000,
+		Max:    10000,
+		Fees:   map[int64]int{1000: 2, 2000: 1, 3000: 1, 10000: 1},
+	}
+
+	dist := tkr.MinRelayFeeDistribution()
+	if !reflect.DeepEqual(dist, expected) {
+		t.Errorf("distribution %+v instead of %+v", dist, expected)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package tracker
+
+import (
+	"sort"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// chainTip keeps the best block heights claimed by our peers and the blocks
+// they announced, so that we can follow the network's best chain without
+// validating it. A height only becomes the tip once enough peers claim it.
+type chainTip struct {
+	height  int32
+	hash    wire.ShaHash
+	quorum  int
+	heights map[string]int32
+	blocks  map[wire.ShaHash]*blockSighting
+}
+
+// blockSighting records the height we inferred for an announced block and the
+// peers that announced it.
+type blockSighting struct {
+	height int32
+	peers  map[string]struct{}
+}
+
+func newChainTip() *chainTip {
+	return &chainTip{
+		quorum:  2,
+		heights: make(map[string]int32),
+		blocks:  make(map[wire.ShaHash]*blockSighting),
+	}
+}
+
+// SetTipQuorum sets the number of distinct peers that need to claim a block
+// height, or announce a block, before the tip is advanced to it. This guards
+// against single peers lying about the state of the chain.
+func SetTipQuorum(quorum int) func(*Tracker) {
+	return func(tracker *Tracker) {
+		tracker.tip.quorum = quorum
+	}
+}
+
+// ReportHeight registers the best block height a peer claims to have, as
+// advertised in its version message.
+func (tracker *Tracker) ReportHeight(peer string, height int32) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	tracker.tip.claim(peer, height)
+	tracker.tip.update()
+}
+
+// AnnounceBlock registers that a peer announced a block, either by inventory
+// or by header. A block we have not seen before is assumed to extend the best
+// chain of the announcing peer; a known block raises the peer's height to the
+// height of the block. The time of the announcement is kept to measure how
+// long the block takes to be delivered.
+func (tracker *Tracker) AnnounceBlock(hash wire.ShaHash, peer string,
+	stamp time.Time) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	if !tracker.blocks.Has(hash) {
+		tracker.delivery.announce(hash, peer, stamp)
+	}
+
+	tip := tracker.tip
+	b, ok := tip.blocks[hash]
+	if !ok {
+		height, known := tip.heights[peer]
+		if !known {
+			return
+		}
+
+		b = &blockSighting{
+			height: height + 1,
+			peers:  make(map[string]struct{}),
+		}
+		tip.blocks[hash] = b
+	}
+
+	b.peers[peer] = struct{}{}
+	tip.claim(peer, b.height)
+	tip.update()
+}
+
+// RemovePeer forgets the height claimed by a peer that disconnected and its
+// block announcements, so that it no longer counts toward the quorum.
+func (tracker *Tracker) RemovePeer(peer string) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	tracker.tip.forget(peer)
+}
+
+// TipHeight returns the best block height corroborated by enough peers.
+func (tracker *Tracker) TipHeight() int32 {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	return tracker.tip.height
+}
+
+// TipHash returns the hash of the block at the tip. It is the zero hash if
+// the tip height was only learned from version messages and no block at that
+// height has been corroborated yet.
+func (tracker *Tracker) TipHash() wire.ShaHash {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	return tracker.tip.hash
+}
+
+// claim raises the height of the peer's best chain; heights never go down.
+func (tip *chainTip) claim(peer string, height int32) {
+	if height > tip.heights[peer] {
+		tip.heights[peer] = height
+	}
+}
+
+// forget removes the claims of a peer; blocks nobody announces anymore are
+// dropped. The tip itself stays where it is.
+func (tip *chainTip) forget(peer string) {
+	delete(tip.heights, peer)
+	for hash, b := range tip.blocks {
+		delete(b.peers, peer)
+		if len(b.peers) == 0 {
+			delete(tip.blocks, hash)
+		}
+	}
+}
+
+// update advances the tip to the highest height that at least quorum peers
+// claim to have reached, and to the block at that height announced by at
+// least quorum peers.
+func (tip *chainTip) update() {
+	if len(tip.heights) < tip.quorum || tip.quorum < 1 {
+		return
+	}
+
+	heights := make([]int, 0, len(tip.heights))
+	for _, height := range tip.heights {
+		heights = append(heights, int(height))
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(heights)))
+	height := int32(heights[tip.quorum-1])
+	if height > tip.height {
+		tip.height = height
+		tip.hash = wire.ShaHash{}
+	}
+
+	for hash, b := range tip.blocks {
+		if b.height == tip.height && len(b.peers) >= tip.quorum {
+			tip.hash = hash
+			break
+		}
+	}
+}
+
+// prune forgets the blocks below the tip, as they can no longer advance it.
+func (tip *chainTip) prune() {
+	for hash, b := range tip.blocks {
+		if b.height < tip.height {
+			delete(tip.blocks, hash)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestTipCorroboration(t *testing.T) {
+	tkr, err := New(SetTipQuorum(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tkr.ReportHeight("a", 100)
+	if tkr.TipHeight() != 0 {
+		t.Errorf("tip at %v from a single peer", tkr.TipHeight())
+	}
+
+	tkr.ReportHeight("b", 100)
+	tkr.ReportHeight("c", 100)
+	if tkr.TipHeight() != 100 {
+		t.Fatalf("tip at %v, want 100", tkr.TipHeight())
+	}
+
+	now := time.Now()
+	block := wire.ShaHash{1}
+	tkr.AnnounceBlock(block, "a", now)
+	if tkr.TipHeight() != 100 {
+		t.Errorf("tip at %v after one announcement", tkr.TipHeight())
+	}
+
+	tkr.AnnounceBlock(block, "b", now)
+	if tkr.TipHeight() != 101 || tkr.TipHash() != block {
+		t.Errorf("tip at %v (%v), want 101 (%v)", tkr.TipHeight(),
+			tkr.TipHash(), block)
+	}
+
+	// a competing block at the same height keeps the corroborated one
+	tkr.AnnounceBlock(wire.ShaHash{2}, "c", now)
+	if tkr.TipHash() != block {
+		t.Errorf("tip hash changed to %v", tkr.TipHash())
+	}
+
+	// a lying peer does not move the tip on its own
+	tkr.ReportHeight("liar", 5000)
+	if tkr.TipHeight() != 101 {
+		t.Errorf("tip at %v after lying peer", tkr.TipHeight())
+	}
+}
+
+func TestTipRemovePeer(t *testing.T) {
+	tkr, err := New(SetTipQuorum(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tkr.ReportHeight("a", 100)
+	tkr.ReportHeight("b", 100)
+	tkr.ReportHeight("stale", 300)
+	tkr.AnnounceBlock(wire.ShaHash{1}, "stale", time.Now())
+	tkr.RemovePeer("stale")
+
+	if len(tkr.tip.heights) != 2 || len(tkr.tip.blocks) != 0 {
+		t.Errorf("stale peer left %v heights and %v blocks",
+			len(tkr.tip.heights), len(tkr.tip.blocks))
+	}
+
+	// the disconnected peer no longer corroborates the liar
+	tkr.ReportHeight("liar", 300)
+	if tkr.TipHeight() != 100 {
+		t.Errorf("tip at %v, want 100", tkr.TipHeight())
+	}
+
+	tkr.RemovePeer("a")
+	if tkr.TipHeight() != 100 {
+		t.Errorf("tip moved back to %v", tkr.TipHeight())
+	}
+}
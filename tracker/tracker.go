@@ -21,22 +21,63 @@
 package tracker
 
 import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/btcsuite/btcd/wire"
 
 	"github.com/CIRCL/pbtc/adaptor"
 	"github.com/CIRCL/pbtc/parmap"
+	"github.com/CIRCL/pbtc/util"
+)
+
+const (
+	stateIdle = iota
+	stateRunning
 )
 
 type Tracker struct {
+	wg     *sync.WaitGroup
+	sig    chan struct{}
+	state  uint32
 	blocks *parmap.ParMap
 	txs    *parmap.ParMap
 	log    adaptor.Log
+	name   string
+
+	mutex     *sync.Mutex
+	sightings map[wire.ShaHash]*sighting
+	txWindow  time.Duration
+	annLimit  int
+	tip       *chainTip
+	fees      map[string]int64
+	delivery  *blockDelivery
+}
+
+// sighting keeps track of the peers that have announced or sent a transaction
+// within the tracking window. The first announcers are kept in order.
+type sighting struct {
+	first      time.Time
+	peers      map[string]struct{}
+	received   bool
+	announcers []adaptor.Announcement
 }
 
 func New(options ...func(*Tracker)) (*Tracker, error) {
 	tracker := &Tracker{
+		wg:     &sync.WaitGroup{},
+		sig:    make(chan struct{}),
 		blocks: parmap.New(),
 		txs:    parmap.New(),
+
+		mutex:     &sync.Mutex{},
+		sightings: make(map[wire.ShaHash]*sighting),
+		txWindow:  10 * time.Minute,
+		tip:       newChainTip(),
+		fees:      make(map[string]int64),
+		delivery:  newBlockDelivery(),
 	}
 
 	for _, option := range options {
@@ -46,20 +87,72 @@ func New(options ...func(*Tracker)) (*Tracker, error) {
 	return tracker, nil
 }
 
+// SetTxWindow sets the time window in which a transaction that is received
+// again is classified as duplicate. After this time, a transaction is
+// forgotten and will be classified as first seen again.
+func SetTxWindow(window time.Duration) func(*Tracker) {
+	return func(tracker *Tracker) {
+		tracker.txWindow = window
+	}
+}
+
+// SetAnnouncerLimit sets the number of peers that are kept, in order, for each
+// transaction announced within the tracking window. They are forgotten with
+// the transaction, so memory use is bounded by the limit and the window. The
+// announcers are added to the transaction records to allow reconstructing the
+// propagation. Zero disables the announcer log.
+func SetAnnouncerLimit(limit int) func(*Tracker) {
+	return func(tracker *Tracker) {
+		tracker.annLimit = limit
+	}
+}
+
+// SetName sets the name of the tracker, which is added to its log messages, so
+// that several trackers can be told apart.
+func SetName(name string) func(*Tracker) {
+	return func(tracker *Tracker) {
+		tracker.name = name
+	}
+}
+
 func (tracker *Tracker) Start() {
 	tracker.log.Info("[TKR] Start: begin")
 
+	tracker.wg.Add(1)
+	go tracker.goPrune()
+
+	atomic.StoreUint32(&tracker.state, stateRunning)
+
 	tracker.log.Info("[TKR] Start: completed")
 }
 
 func (tracker *Tracker) Stop() {
 	tracker.log.Info("[TKR] Stop: begin")
 
+	atomic.StoreUint32(&tracker.state, stateIdle)
+
+	close(tracker.sig)
+	tracker.wg.Wait()
+
 	tracker.log.Info("[TKR] Stop: completed")
 }
 
+// Healthy returns whether the tracker is running.
+func (tracker *Tracker) Healthy() (bool, error) {
+	if atomic.LoadUint32(&tracker.state) != stateRunning {
+		return false, errors.New("tracker not running")
+	}
+
+	return true, nil
+}
+
 func (tracker *Tracker) SetLog(log adaptor.Log) {
-	tracker.log = log
+	tracker.log = util.NamedLog(log, tracker.name)
+}
+
+// Name returns the name the tracker was created with.
+func (tracker *Tracker) Name() string {
+	return tracker.name
 }
 
 func (tracker *Tracker) AddTx(hash wire.ShaHash) {
@@ -70,6 +163,137 @@ func (tracker *Tracker) KnowsTx(hash wire.ShaHash) bool {
 	return tracker.txs.Has(hash)
 }
 
+// AnnounceTx registers that the given peer has announced a transaction and
+// returns when the transaction was first seen from any peer within the
+// tracking window.
+func (tracker *Tracker) AnnounceTx(hash wire.ShaHash, peer string,
+	stamp time.Time) time.Time {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	s := tracker.sight(hash, peer, stamp)
+	if len(s.announcers) >= tracker.annLimit {
+		return s.first
+	}
+
+	for _, ann := range s.announcers {
+		if ann.Peer == peer {
+			return s.first
+		}
+	}
+
+	s.announcers = append(s.announcers, adaptor.Announcement{
+		Peer:  peer,
+		Stamp: stamp,
+	})
+
+	return s.first
+}
+
+// Announcers returns the first peers that announced the given transaction
+// within the tracking window, in order of announcement.
+func (tracker *Tracker) Announcers(hash wire.ShaHash) []adaptor.Announcement {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	s, ok := tracker.sightings[hash]
+	if !ok {
+		return nil
+	}
+
+	return s.copyAnnouncers()
+}
+
+// SightTx registers that the given peer has sent us a transaction and returns
+// its propagation details. The transaction is classified as duplicate if it
+// was already received from any peer within the tracking window.
+func (tracker *Tracker) SightTx(hash wire.ShaHash, peer string,
+	stamp time.Time) adaptor.Sighting {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	s := tracker.sight(hash, peer, stamp)
+	duplicate := s.received
+	s.received = true
+
+	return adaptor.Sighting{
+		Duplicate:  duplicate,
+		Peers:      len(s.peers),
+		First:      s.first,
+		Announcers: s.copyAnnouncers(),
+	}
+}
+
+// copyAnnouncers returns a copy of the announcers, so that they can be handed
+// out while the sighting goes on. It needs to be called with the mutex held.
+func (s *sighting) copyAnnouncers() []adaptor.Announcement {
+	if len(s.announcers) == 0 {
+		return nil
+	}
+
+	announcers := make([]adaptor.Announcement, len(s.announcers))
+	copy(announcers, s.announcers)
+
+	return announcers
+}
+
+// sight adds the peer to the sighting of a transaction, starting a new
+// sighting if there is none or the current one is outside of the window. It
+// needs to be called with the mutex held.
+func (tracker *Tracker) sight(hash wire.ShaHash, peer string,
+	stamp time.Time) *sighting {
+	s, ok := tracker.sightings[hash]
+	if !ok || stamp.Sub(s.first) > tracker.txWindow {
+		s = &sighting{
+			first: stamp,
+			peers: make(map[string]struct{}),
+		}
+		tracker.sightings[hash] = s
+	}
+
+	s.peers[peer] = struct{}{}
+
+	return s
+}
+
+// goPrune is to be launched as a go routine. It regularly removes sightings
+// that are outside of the tracking window.
+func (tracker *Tracker) goPrune() {
+	defer tracker.wg.Done()
+
+	ticker := time.NewTicker(tracker.txWindow)
+	defer ticker.Stop()
+
+PruneLoop:
+	for {
+		select {
+		case _, ok := <-tracker.sig:
+			if !ok {
+				break PruneLoop
+			}
+
+		case <-ticker.C:
+			tracker.prune(time.Now())
+		}
+	}
+}
+
+// prune removes all sightings that started before the tracking window, as
+// well as the announced blocks below the chain tip.
+func (tracker *Tracker) prune(now time.Time) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	for hash, s := range tracker.sightings {
+		if now.Sub(s.first) > tracker.txWindow {
+			delete(tracker.sightings, hash)
+		}
+	}
+
+	tracker.tip.prune()
+	tracker.delivery.prune(now)
+}
+
 func (tracker *Tracker) AddBlock(hash wire.ShaHash) {
 	tracker.blocks.Insert(hash)
 }
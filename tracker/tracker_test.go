@@ -0,0 +1,147 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/CIRCL/pbtc/pbtctest"
+)
+
+func TestHealthy(t *testing.T) {
+	tkr, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tkr.SetLog(pbtctest.Log{})
+
+	ok, _ := tkr.Healthy()
+	if ok {
+		t.Error("healthy before start")
+	}
+
+	tkr.Start()
+
+	ok, err = tkr.Healthy()
+	if !ok {
+		t.Errorf("unhealthy while running (%v)", err)
+	}
+
+	tkr.Stop()
+
+	ok, _ = tkr.Healthy()
+	if ok {
+		t.Error("healthy after stop")
+	}
+}
+
+func TestSightTx(t *testing.T) {
+	tkr, err := New(SetTxWindow(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash := wire.ShaHash{1}
+	start := time.Unix(1000, 0)
+
+	s := tkr.SightTx(hash, "a", start)
+	if s.Duplicate || s.Peers != 1 || !s.First.Equal(start) {
+		t.Errorf("first sighting %+v", s)
+	}
+
+	s = tkr.SightTx(hash, "b", start.Add(time.Second))
+	if !s.Duplicate || s.Peers != 2 || !s.First.Equal(start) {
+		t.Errorf("second sighting %+v", s)
+	}
+
+	// other transactions are not affected
+	s = tkr.SightTx(wire.ShaHash{2}, "a", start.Add(time.Second))
+	if s.Duplicate {
+		t.Error("other transaction classified as duplicate")
+	}
+
+	// outside of the window, the transaction is seen for the first time again
+	later := start.Add(2 * time.Minute)
+	s = tkr.SightTx(hash, "a", later)
+	if s.Duplicate || s.Peers != 1 || !s.First.Equal(later) {
+		t.Errorf("sighting after window %+v", s)
+	}
+}
+
+func TestAnnouncers(t *testing.T) {
+	tkr, err := New(SetTxWindow(time.Minute), SetAnnouncerLimit(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash := wire.ShaHash{1}
+	start := time.Unix(1000, 0)
+
+	peers := []string{"a", "b", "a", "c", "d"}
+	for i, peer := range peers {
+		stamp := start.Add(time.Duration(i) * time.Second)
+		first := tkr.AnnounceTx(hash, peer, stamp)
+		if !first.Equal(start) {
+			t.Errorf("first seen at %v instead of %v", first, start)
+		}
+	}
+
+	// repeated announcements are ignored and the log is bounded by the limit
+	expected := []string{"a", "b", "c"}
+	anns := tkr.Announcers(hash)
+	if len(anns) != len(expected) {
+		t.Fatalf("%v announcers instead of %v", len(anns),
+			len(expected))
+	}
+
+	for i, ann := range anns {
+		if ann.Peer != expected[i] {
+			t.Errorf("announcer %v is %v instead of %v", i, ann.Peer,
+				expected[i])
+		}
+	}
+
+	if !anns[2].Stamp.Equal(start.Add(3 * time.Second)) {
+		t.Errorf("announcer stamp %v", anns[2].Stamp)
+	}
+
+	// the announcers are added to the sighting of the transaction
+	s := tkr.SightTx(hash, "e", start.Add(5*time.Second))
+	if len(s.Announcers) != len(expected) {
+		t.Errorf("sighting with %v announcers", len(s.Announcers))
+	}
+
+	// the log is forgotten with the transaction after the window
+	later := start.Add(2 * time.Minute)
+	tkr.AnnounceTx(hash, "d", later)
+	anns = tkr.Announcers(hash)
+	if len(anns) != 1 || anns[0].Peer != "d" {
+		t.Errorf("announcers after window %+v", anns)
+	}
+
+	if tkr.Announcers(wire.ShaHash{2}) != nil {
+		t.Error("announcers for unknown transaction")
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// The network classes that start a group key, as used by Bitcoin Core.
+const (
+	groupUnroutable = 0
+	groupIPv4       = 1
+	groupIPv6       = 2
+	groupTor        = 3
+)
+
+// The prefixes of the special address ranges considered for grouping. All of
+// them are compared against the 16 byte form of the IP.
+var (
+	prefixIPv4  = []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff}
+	prefix6145  = []byte{0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0, 0}
+	prefix6052  = []byte{0, 0x64, 0xff, 0x9b, 0, 0, 0, 0, 0, 0, 0, 0}
+	prefix3964  = []byte{0x20, 0x02}
+	prefix4380  = []byte{0x20, 0x01, 0, 0}
+	prefixTor   = []byte{0xfd, 0x87, 0xd8, 0x7e, 0xeb, 0x43}
+	prefixHENet = []byte{0x20, 0x01, 0x04, 0x70}
+	prefix3849  = []byte{0x20, 0x01, 0x0d, 0xb8}
+	prefix4862  = []byte{0xfe, 0x80, 0, 0, 0, 0, 0, 0}
+)
+
+// GroupKey returns the network group of an IP the way Bitcoin Core's GetGroup
+// computes it, so that we spread our peers like a regular node would. The key
+// starts with the network class, followed by the /16 for IPv4, /32 for IPv6
+// and /36 for the Hurricane Electric tunnel broker. Tunneled and translated
+// addresses are grouped by the IPv4 address they embed, Tor addresses by the
+// first four bits after the OnionCat prefix, and all local and unroutable IPs
+// share one group.
+func GroupKey(ip net.IP) []byte {
+	ip = ip.To16()
+	if ip == nil || !isCoreRoutable(ip) {
+		return []byte{groupUnroutable}
+	}
+
+	class := groupIPv6
+	start := 0
+	bits := 32
+
+	switch {
+	// the 16 higher bits of IPv4, including mapped and translated IPv4
+	case isCoreIPv4(ip) || bytes.HasPrefix(ip, prefix6145) ||
+		bytes.HasPrefix(ip, prefix6052):
+		class = groupIPv4
+		start = 12
+		bits = 16
+
+	// for 6to4, the IPv4 address of the tunnel endpoint
+	case bytes.HasPrefix(ip, prefix3964):
+		class = groupIPv4
+		start = 2
+		bits = 16
+
+	// for Teredo, the IPv4 address of the client, which is obfuscated
+	case bytes.HasPrefix(ip, prefix4380):
+		return []byte{groupIPv4, ip[12] ^ 0xff, ip[13] ^ 0xff}
+
+	case bytes.HasPrefix(ip, prefixTor):
+		class = groupTor
+		start = 6
+		bits = 4
+
+	case bytes.HasPrefix(ip, prefixHENet):
+		bits = 36
+	}
+
+	key := []byte{byte(class)}
+	for ; bits >= 8; bits -= 8 {
+		key = append(key, ip[start])
+		start++
+	}
+
+	if bits > 0 {
+		key = append(key, ip[start]|byte(1<<uint(8-bits)-1))
+	}
+
+	return key
+}
+
+// GroupName returns the network group of an IP as readable text, with the
+// class and the bytes of the group key, like "ipv4:1.2" or "ipv6:20010db8".
+func GroupName(ip net.IP) string {
+	key := GroupKey(ip)
+	switch key[0] {
+	case groupIPv4:
+		parts := make([]string, 0, len(key)-1)
+		for _, b := range key[1:] {
+			parts = append(parts, strconv.Itoa(int(b)))
+		}
+
+		return "ipv4:" + strings.Join(parts, ".")
+
+	case groupIPv6:
+		return "ipv6:" + hex.EncodeToString(key[1:])
+
+	case groupTor:
+		return "tor:" + hex.EncodeToString(key[1:])
+
+	default:
+		return "unroutable"
+	}
+}
+
+// isCoreIPv4 checks whether the IP is an IPv4 address mapped to IPv6.
+func isCoreIPv4(ip net.IP) bool {
+	return bytes.HasPrefix(ip, prefixIPv4)
+}
+
+// isCoreRoutable checks whether an IP is routable the way Bitcoin Core decides
+// it, which differs from IsRoutable: it accepts Tor addresses, multicast and
+// some reserved networks, as nodes in them can still be grouped.
+func isCoreRoutable(ip net.IP) bool {
+	// invalid addresses: shifted garbage from old clients, which looks like
+	// the IPv4 prefix moved by three bytes, unspecified, documentation and the
+	// IPv4 broadcast and zero addresses
+	if bytes.HasPrefix(ip, prefixIPv4[3:]) ||
+		ip.Equal(net.IPv6unspecified) || bytes.HasPrefix(ip, prefix3849) {
+		return false
+	}
+
+	if isCoreIPv4(ip) {
+		ip4 := ip[12:]
+		switch {
+		case ip4.Equal(net.IPv4bcast):
+			return false
+
+		// local
+		case ip4[0] == 127, ip4[0] == 0:
+			return false
+
+		// RFC1918
+		case ip4[0] == 10, ip4[0] == 192 && ip4[1] == 168,
+			ip4[0] == 172 && ip4[1] >= 16 && ip4[1] <= 31:
+			return false
+
+		// RFC2544
+		case ip4[0] == 198 && (ip4[1] == 18 || ip4[1] == 19):
+			return false
+
+		// RFC3927
+		case ip4[0] == 169 && ip4[1] == 254:
+			return false
+		}
+
+		return true
+	}
+
+	switch {
+	case ip.Equal(net.IPv6loopback):
+		return false
+
+	// RFC4862
+	case bytes.HasPrefix(ip, prefix4862):
+		return false
+
+	// RFC4193, except for Tor, which uses part of its range
+	case ip[0]&0xfe == 0xfc && !bytes.HasPrefix(ip, prefixTor):
+		return false
+
+	// RFC4843
+	case ip[0] == 0x20 && ip[1] == 0x01 && ip[2] == 0x00 &&
+		ip[3]&0xf0 == 0x10:
+		return false
+	}
+
+	return true
+}
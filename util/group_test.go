@@ -0,0 +1,71 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+// TestGroupKey checks the groups against the ones Bitcoin Core computes for
+// the same addresses in its network tests.
+func TestGroupKey(t *testing.T) {
+	tests := []struct {
+		ip   string
+		key  []byte
+		name string
+	}{
+		{"127.0.0.1", []byte{0}, "unroutable"},
+		{"10.0.0.1", []byte{0}, "unroutable"},
+		{"169.254.1.1", []byte{0}, "unroutable"},
+		{"2001:db8::1", []byte{0}, "unroutable"},
+		{"0:0:0:ff:ff00:102:304:0", []byte{0}, "unroutable"},
+		{"1.2.3.4", []byte{1, 1, 2}, "ipv4:1.2"},
+		{"::ffff:0:102:304", []byte{1, 1, 2}, "ipv4:1.2"},
+		{"64:ff9b::102:304", []byte{1, 1, 2}, "ipv4:1.2"},
+		{"2002:102:304:9999:9999:9999:9999:9999", []byte{1, 1, 2},
+			"ipv4:1.2"},
+		{"2001:0:9999:9999:9999:9999:fefd:fcfb", []byte{1, 1, 2},
+			"ipv4:1.2"},
+		{"fd87:d87e:eb43:edb1:8e4:3588:e546:35ca", []byte{3, 239},
+			"tor:ef"},
+		{"2001:470:abcd:9999:9999:9999:9999:9999",
+			[]byte{2, 32, 1, 4, 112, 175}, "ipv6:20010470af"},
+		{"2001:2001:9999:9999:9999:9999:9999:9999",
+			[]byte{2, 32, 1, 32, 1}, "ipv6:20012001"},
+	}
+
+	for _, test := range tests {
+		ip := net.ParseIP(test.ip)
+		key := GroupKey(ip)
+		if !bytes.Equal(key, test.key) {
+			t.Errorf("%v has group key %v instead of %v", test.ip, key,
+				test.key)
+		}
+
+		name := GroupName(ip)
+		if name != test.name {
+			t.Errorf("%v has group name %v instead of %v", test.ip, name,
+				test.name)
+		}
+	}
+}
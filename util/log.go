@@ -0,0 +1,78 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"strings"
+
+	"github.com/CIRCL/pbtc/adaptor"
+)
+
+// namedLog adds the name of a module to its log messages.
+type namedLog struct {
+	log  adaptor.Log
+	name string
+}
+
+// NamedLog returns a log that adds the given module name to the tag of every
+// message, so that "[MGR] Start: begin" becomes "[MGR:main] Start: begin" and
+// instances of the same module can be told apart. Messages without tag are
+// prefixed with the name. Without name, the log is returned unchanged.
+func NamedLog(log adaptor.Log, name string) adaptor.Log {
+	if name == "" || log == nil {
+		return log
+	}
+
+	return &namedLog{log: log, name: name}
+}
+
+func (nl *namedLog) format(format string) string {
+	end := strings.Index(format, "]")
+	if !strings.HasPrefix(format, "[") || end < 0 {
+		return nl.name + ": " + format
+	}
+
+	return format[:end] + ":" + nl.name + format[end:]
+}
+
+func (nl *namedLog) Debug(format string, args ...interface{}) {
+	nl.log.Debug(nl.format(format), args...)
+}
+
+func (nl *namedLog) Info(format string, args ...interface{}) {
+	nl.log.Info(nl.format(format), args...)
+}
+
+func (nl *namedLog) Notice(format string, args ...interface{}) {
+	nl.log.Notice(nl.format(format), args...)
+}
+
+func (nl *namedLog) Warning(format string, args ...interface{}) {
+	nl.log.Warning(nl.format(format), args...)
+}
+
+func (nl *namedLog) Error(format string, args ...interface{}) {
+	nl.log.Error(nl.format(format), args...)
+}
+
+func (nl *namedLog) Critical(format string, args ...interface{}) {
+	nl.log.Critical(nl.format(format), args...)
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// testLog keeps the messages logged to it.
+type testLog struct {
+	messages []string
+}
+
+func (log *testLog) add(format string, args ...interface{}) {
+	log.messages = append(log.messages, fmt.Sprintf(format, args...))
+}
+
+func (log *testLog) Debug(format string, args ...interface{}) {
+	log.add(format, args...)
+}
+
+func (log *testLog) Info(format string, args ...interface{}) {
+	log.add(format, args...)
+}
+
+func (log *testLog) Notice(format string, args ...interface{}) {
+	log.add(format, args...)
+}
+
+func (log *testLog) Warning(format string, args ...interface{}) {
+	log.add(format, args...)
+}
+
+func (log *testLog) Error(format string, args ...interface{}) {
+	log.add(format, args...)
+}
+
+func (log *testLog) Critical(format string, args ...interface{}) {
+	log.add(format, args...)
+}
+
+func TestNamedLog(t *testing.T) {
+	log := &testLog{}
+	if NamedLog(log, "") != log {
+		t.Error("log without name wrapped")
+	}
+
+	// two instances of one module log under different tags
+	main := NamedLog(log, "main")
+	test := NamedLog(log, "test")
+	main.Info("[MGR] Start: begin")
+	test.Info("[MGR] Start: begin")
+	main.Debug("%v: done", "192.0.2.1:8333")
+	test.Warning("[MGR] %v rejected", "192.0.2.1:8333")
+
+	expected := []string{
+		"[MGR:main] Start: begin",
+		"[MGR:test] Start: begin",
+		"main: 192.0.2.1:8333: done",
+		"[MGR:test] 192.0.2.1:8333 rejected",
+	}
+
+	if !reflect.DeepEqual(log.messages, expected) {
+		t.Errorf("logged %q instead of %q", log.messages, expected)
+	}
+}
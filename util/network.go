@@ -0,0 +1,68 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"github.com/btcsuite/btcd/wire"
+)
+
+// DefaultPort returns the port that nodes of the given network listen on by
+// default, or zero if the network is unknown.
+func DefaultPort(network wire.BitcoinNet) uint16 {
+	switch network {
+	case wire.MainNet:
+		return 8333
+
+	case wire.TestNet3:
+		return 18333
+
+	case wire.TestNet:
+		return 18444
+
+	case wire.SimNet:
+		return 18555
+
+	default:
+		return 0
+	}
+}
+
+// DefaultSeeds returns the DNS seeds used to bootstrap nodes of the given
+// network. Networks meant for local testing have none.
+func DefaultSeeds(network wire.BitcoinNet) []string {
+	switch network {
+	case wire.MainNet:
+		return []string{
+			"seed.bitcoin.sipa.be",
+			"dnsseed.bluematt.me",
+			"dnsseed.bitcoin.dashjr.org",
+			"seed.bitcoinstats.com",
+			"seed.bitnodes.io",
+			"bitseed.xf2.org",
+		}
+
+	case wire.TestNet3:
+		return []string{"testnet-seed.bitcoin.petertodd.org"}
+
+	default:
+		return nil
+	}
+}
@@ -21,7 +21,11 @@
 package util
 
 import (
+	"math/rand"
 	"net"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/btcsuite/btcd/wire"
 )
@@ -112,3 +116,165 @@ func ParseNetAddress(na *wire.NetAddress) *net.TCPAddr {
 
 	return addr
 }
+
+// NormalizeIP returns the canonical form of an IP, with IPv4-mapped IPv6
+// addresses turned into plain IPv4 addresses, so that the same address always
+// has the same bytes and text. It returns nil for IPs of invalid length.
+func NormalizeIP(ip net.IP) net.IP {
+	ip4 := ip.To4()
+	if ip4 != nil {
+		return ip4
+	}
+
+	return ip.To16()
+}
+
+// ParseIP parses a textual IP in any of its forms, including surrounding
+// whitespace, brackets, upper case hexadecimal and IPv4-mapped IPv6, and
+// returns its canonical form. It returns nil if the text is no valid IP.
+func ParseIP(text string) net.IP {
+	text = strings.TrimSpace(text)
+	text = strings.TrimSuffix(strings.TrimPrefix(text, "["), "]")
+
+	return NormalizeIP(net.ParseIP(text))
+}
+
+// CanonicalAddr returns the text of an address with its IP in canonical form,
+// to be used as key wherever addresses are matched or deduplicated.
+func CanonicalAddr(addr *net.TCPAddr) string {
+	ip := NormalizeIP(addr.IP)
+	if ip == nil {
+		return addr.String()
+	}
+
+	return (&net.TCPAddr{IP: ip, Port: addr.Port, Zone: addr.Zone}).String()
+}
+
+// Jitter randomly spreads a duration by up to the given fraction in either
+// direction, so that timers of several modules or instances do not fire in
+// lockstep. A fraction of zero returns the duration unchanged.
+func Jitter(d time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return d
+	}
+
+	if fraction > 1 {
+		fraction = 1
+	}
+
+	offset := (rand.Float64()*2 - 1) * fraction * float64(d)
+	jittered := d + time.Duration(offset)
+	if jittered <= 0 {
+		return time.Millisecond
+	}
+
+	return jittered
+}
+
+// unroutable lists the private and reserved networks that can not be reached
+// over the public internet.
+var unroutable = []*net.IPNet{
+	parseCIDR("10.0.0.0/8"),      // RFC1918
+	parseCIDR("100.64.0.0/10"),   // RFC6598
+	parseCIDR("172.16.0.0/12"),   // RFC1918
+	parseCIDR("192.0.2.0/24"),    // RFC5737
+	parseCIDR("192.168.0.0/16"),  // RFC1918
+	parseCIDR("198.18.0.0/15"),   // RFC2544
+	parseCIDR("198.51.100.0/24"), // RFC5737
+	parseCIDR("203.0.113.0/24"),  // RFC5737
+	parseCIDR("240.0.0.0/4"),     // RFC6890
+	parseCIDR("2001:db8::/32"),   // RFC3849
+	parseCIDR("fc00::/7"),        // RFC4193
+}
+
+func parseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+
+	return network
+}
+
+// IsRoutable checks whether an IP can be reached over the public internet. It
+// rejects unspecified, loopback, multicast and link-local IPs, as well as
+// private and reserved networks.
+func IsRoutable(ip net.IP) bool {
+	if ip == nil || ip.IsUnspecified() || ip.IsLoopback() ||
+		ip.IsMulticast() || ip.IsLinkLocalUnicast() {
+		return false
+	}
+
+	for _, network := range unroutable {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NetworkGroup returns the network an IP belongs to, keeping the given number
+// of leading bits for IPv4 and twice as many for IPv6, which is allocated in
+// larger blocks. IPs in the same group are likely run by the same operator.
+func NetworkGroup(ip net.IP, prefix int) string {
+	if prefix < 0 {
+		prefix = 0
+	}
+
+	ip4 := ip.To4()
+	if ip4 != nil {
+		if prefix > 32 {
+			prefix = 32
+		}
+
+		network := ip4.Mask(net.CIDRMask(prefix, 32))
+		return network.String() + "/" + strconv.Itoa(prefix)
+	}
+
+	prefix *= 2
+	if prefix > 128 {
+		prefix = 128
+	}
+
+	network := ip.Mask(net.CIDRMask(prefix, 128))
+	return network.String() + "/" + strconv.Itoa(prefix)
+}
+
+// maxFamily is the maximum length of a software family name, as user agents
+// are chosen freely by the nodes.
+const maxFamily = 32
+
+// SoftwareFamily returns the name of the software a node runs, taken from the
+// first component of its user agent in the BIP14 format, like "Satoshi" for
+// "/Satoshi:0.10.0/". Agents without a name are "unknown".
+func SoftwareFamily(agent string) string {
+	agent = strings.TrimPrefix(agent, "/")
+	end := strings.IndexAny(agent, ":/(")
+	if end >= 0 {
+		agent = agent[:end]
+	}
+
+	if len(agent) > maxFamily {
+		agent = agent[:maxFamily]
+	}
+
+	if agent == "" {
+		return "unknown"
+	}
+
+	return agent
+}
+
+// IsMisadvertised checks whether the address a node advertises for itself does
+// not match the address we observe it on, which hints at a NAT, a proxy or a
+// misconfigured node. Only the IPs are compared, as the observed port of an
+// inbound connection is ephemeral. Advertised addresses that are not routable,
+// which many nodes send when they don't know their own, never count.
+func IsMisadvertised(observed *net.TCPAddr, advertised *net.TCPAddr) bool {
+	if observed == nil || advertised == nil || !IsRoutable(advertised.IP) {
+		return false
+	}
+
+	return !observed.IP.Equal(advertised.IP)
+}
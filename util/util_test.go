@@ -0,0 +1,161 @@
+// Copyright (c) 2015 Max Wolter
+// Copyright (c) 2015 CIRCL - Computer Incident Response Center Luxembourg
+//                           (c/o smile, security made in Lëtzebuerg, Groupement
+//                           d'Intérêt Economique)
+//
+// This file is part of PBTC.
+//
+// PBTC is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// PBTC is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with PBTC.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestJitter(t *testing.T) {
+	d := 10 * time.Second
+	if Jitter(d, 0) != d {
+		t.Error("jitter without fraction")
+	}
+
+	// successive timers vary, but stay within the fraction
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 1000; i++ {
+		jittered := Jitter(d, 0.1)
+		if jittered < 9*time.Second || jittered > 11*time.Second {
+			t.Fatalf("jittered %v out of bounds", jittered)
+		}
+
+		seen[jittered] = true
+	}
+
+	if len(seen) < 100 {
+		t.Errorf("only %v different durations", len(seen))
+	}
+
+	// a fraction above one never makes the duration negative
+	for i := 0; i < 1000; i++ {
+		jittered := Jitter(d, 5)
+		if jittered <= 0 || jittered > 2*d {
+			t.Fatalf("jittered %v out of bounds", jittered)
+		}
+	}
+}
+
+func TestIsMisadvertised(t *testing.T) {
+	observed := &net.TCPAddr{IP: net.ParseIP("8.8.8.8"), Port: 50000}
+	tests := []struct {
+		advertised *net.TCPAddr
+		result     bool
+	}{
+		{&net.TCPAddr{IP: net.ParseIP("8.8.8.8"), Port: 8333}, false},
+		{&net.TCPAddr{IP: net.ParseIP("8.8.4.4"), Port: 8333}, true},
+		{&net.TCPAddr{IP: net.ParseIP("0.0.0.0"), Port: 8333}, false},
+		{&net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 8333}, false},
+		{nil, false},
+	}
+
+	for _, test := range tests {
+		if IsMisadvertised(observed, test.advertised) != test.result {
+			t.Errorf("%v advertised as %v: want %v", observed,
+				test.advertised, test.result)
+		}
+	}
+
+	if IsMisadvertised(nil, tests[1].advertised) {
+		t.Error("misadvertised without observed address")
+	}
+}
+
+func TestNetworkGroup(t *testing.T) {
+	tests := []struct {
+		ip     string
+		prefix int
+		group  string
+	}{
+		{"8.8.8.8", 16, "8.8.0.0/16"},
+		{"8.8.8.8", 24, "8.8.8.0/24"},
+		{"8.8.8.8", 40, "8.8.8.8/32"},
+		{"8.8.8.8", -1, "0.0.0.0/0"},
+		{"2001:4860:4860::8888", 16, "2001:4860::/32"},
+		{"2001:4860:4860::8888", 80, "2001:4860:4860::8888/128"},
+	}
+
+	for _, test := range tests {
+		group := NetworkGroup(net.ParseIP(test.ip), test.prefix)
+		if group != test.group {
+			t.Errorf("%v/%v in group %v instead of %v", test.ip, test.prefix,
+				group, test.group)
+		}
+	}
+}
+
+func TestParseIP(t *testing.T) {
+	tests := []struct {
+		text string
+		ip   string
+	}{
+		{"192.0.2.1", "192.0.2.1"},
+		{" 192.0.2.1\n", "192.0.2.1"},
+		{"::ffff:192.0.2.1", "192.0.2.1"},
+		{"::FFFF:C000:0201", "192.0.2.1"},
+		{"2001:DB8::1", "2001:db8::1"},
+		{"[2001:db8:0:0::1]", "2001:db8::1"},
+		{"192.0.2.256", ""},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		ip := ParseIP(test.text)
+		if test.ip == "" {
+			if ip != nil {
+				t.Errorf("%q parsed as %v", test.text, ip)
+			}
+			continue
+		}
+
+		if ip.String() != test.ip {
+			t.Errorf("%q parsed as %v instead of %v", test.text, ip, test.ip)
+		}
+	}
+}
+
+func TestCanonicalAddr(t *testing.T) {
+	forms := []net.IP{
+		net.IP{192, 0, 2, 1},
+		net.ParseIP("192.0.2.1"),
+		net.ParseIP("::ffff:c000:201"),
+	}
+
+	// all forms of an address give one key
+	for _, ip := range forms {
+		key := CanonicalAddr(&net.TCPAddr{IP: ip, Port: 8333})
+		if key != "192.0.2.1:8333" {
+			t.Errorf("%#v keyed as %v", ip, key)
+		}
+	}
+
+	key := CanonicalAddr(&net.TCPAddr{IP: net.ParseIP("2001:DB8::1"),
+		Port: 8333})
+	if key != "[2001:db8::1]:8333" {
+		t.Errorf("IPv6 address keyed as %v", key)
+	}
+
+	if NormalizeIP(net.IP{1, 2, 3}) != nil {
+		t.Error("IP of invalid length normalized")
+	}
+}